@@ -51,6 +51,19 @@ type Agent struct {
 	middlewares    *middleware.MiddlewareChain
 	toolSupervisor *runtimeprovider.ToolSupervisor
 	logger         *slog.Logger
+	promptLog      *promptLogging
+	sequenceRules  *SequenceRules
+	postProcessors []ResponsePostProcessor
+	currentTime    *currentTime
+	toolFilter     ToolFilter
+	promptCache    *message.CacheControl
+	retriever      Retriever
+	runs           runRegistry
+	usage          usageMeter
+
+	toolConcurrency int            // Tool calls of one turn executed at once; values below 1 mean sequential
+	retryPolicy     *RetryPolicy   // Retries transient LLM failures when set
+	responseSchema  map[string]any // JSON Schema final answers must match
 }
 
 var agentTracer = otel.Tracer("github.com/sweetpotato0/ai-allin/agent")
@@ -101,6 +114,15 @@ func WithTools(enable bool) Option {
 	}
 }
 
+// WithToolArgValidation controls whether tool arguments from the model are
+// validated against the tool parameters before the handler runs (default true).
+// When validation fails the model receives the error instead of the handler running.
+func WithToolArgValidation(enabled bool) Option {
+	return func(a *Agent) {
+		a.tools.SetArgValidation(enabled)
+	}
+}
+
 // WithProvider sets the LLM provider
 func WithProvider(provider LLMClient) Option {
 	return func(a *Agent) {
@@ -121,6 +143,17 @@ func WithToolProvider(provider tool.Provider) Option {
 	}
 }
 
+// ToolFilter selects which of the registered tools are advertised to the model for input.
+type ToolFilter func(input string, tools []*tool.Tool) []*tool.Tool
+
+// WithToolFilter narrows the tools sent with each request to those returned by filter.
+// The filter runs once per run and receives the registered tools sorted by name.
+func WithToolFilter(filter ToolFilter) Option {
+	return func(a *Agent) {
+		a.toolFilter = filter
+	}
+}
+
 // WithMiddleware adds a middleware to the agent
 func WithMiddleware(m middleware.Middleware) Option {
 	return func(a *Agent) {
@@ -202,7 +235,7 @@ func New(opts ...Option) *Agent {
 
 	// Add system prompt as first message if set
 	if agent.systemPrompt != "" {
-		agent.ctx.AddMessage(message.NewMessage(message.RoleSystem, agent.systemPrompt))
+		agent.ctx.AddMessage(agent.systemMessage())
 	}
 
 	return agent
@@ -253,7 +286,7 @@ func (a *Agent) ClearMessages() {
 	a.ctx.Clear()
 	// Re-add system prompt
 	if a.systemPrompt != "" {
-		a.ctx.AddMessage(message.NewMessage(message.RoleSystem, a.systemPrompt))
+		a.ctx.AddMessage(a.systemMessage())
 	}
 }
 
@@ -264,7 +297,7 @@ func (a *Agent) RestoreMessages(messages []*message.Message) {
 	a.ctx.Clear()
 	if len(messages) == 0 {
 		if a.systemPrompt != "" {
-			a.ctx.AddMessage(message.NewMessage(message.RoleSystem, a.systemPrompt))
+			a.ctx.AddMessage(a.systemMessage())
 		}
 		return
 	}
@@ -286,6 +319,7 @@ func (a *Agent) Run(ctx context.Context, input string) (*message.Message, error)
 		))
 	var spanErr error
 	defer func() { telemetry.End(span, spanErr) }()
+	a.usage.startRun()
 
 	if a.logger != nil {
 		a.logger.Info("agent run started", "input", trimLogText(input, 160))
@@ -300,52 +334,40 @@ func (a *Agent) Run(ctx context.Context, input string) (*message.Message, error)
 
 	mwCtx := middleware.NewContext(ctx)
 	mwCtx.Input = input
+	mwCtx.Messages = a.GetMessages()
 
 	err := a.middlewares.Execute(mwCtx, func(mwCtx *middleware.Context) error {
-		userMsg := message.NewMessage(message.RoleUser, input)
-		a.AddMessage(userMsg)
+		// Middlewares may rewrite the input before it reaches the model.
+		input := mwCtx.Input
+		a.prepareTurn(mwCtx.Context(), input)
 		mwCtx.Messages = a.GetMessages()
 
-		if a.enableMemory && a.memory != nil {
-			memories, err := a.memory.SearchMemory(mwCtx.Context(), input)
-			if err == nil && len(memories) > 0 {
-				if a.logger != nil {
-					a.logger.Debug("memory hits found", "count", len(memories))
-				}
-				span.AddEvent("memory_hits", oteltrace.WithAttributes(attribute.Int("count", len(memories))))
-				memoryContext := "Relevant memories:\n"
-				for _, mem := range memories {
-					memoryContext += fmt.Sprintf("- %v\n", mem)
-				}
-				contextMsg := message.NewMessage(message.RoleSystem, memoryContext)
-				a.ctx.AddMessage(contextMsg)
-			} else if err != nil {
-				if a.logger != nil {
-					a.logger.Warn("memory search failed", "error", err)
-				}
-				span.AddEvent("memory_search_failed", oteltrace.WithAttributes(attribute.String("error", err.Error())))
-			}
+		toolSchemas := a.toolSchemas(input)
+		if a.enableTools && a.logger != nil {
+			a.logger.Debug("tools available", "count", len(toolSchemas))
 		}
 
+		corrected := false
 		for i := 0; i < a.maxIterations; i++ {
 			if a.logger != nil {
 				a.logger.Debug("llm turn started", "iteration", i+1)
 			}
 			span.AddEvent("agent_iteration", oteltrace.WithAttributes(attribute.Int("iteration", i+1)))
 
-			var toolSchemas []map[string]any
-			if a.enableTools {
-				toolSchemas = a.tools.ToJSONSchemas()
-				if a.logger != nil {
-					a.logger.Debug("tools available", "count", len(toolSchemas))
-				}
-			}
-
 			req := &GenerateRequest{
-				Messages: a.ctx.GetMessages(),
+				Messages: a.requestMessages(),
 				Tools:    toolSchemas,
 			}
-			resp, err := a.llm.Generate(mwCtx.Context(), req)
+			applyCallOptions(mwCtx.Context(), req)
+			a.applyResponseFormat(req)
+			if err := a.checkSequence(req); err != nil {
+				if a.logger != nil {
+					a.logger.Error("message sequence rejected", "iteration", i+1, "error", err)
+				}
+				return err
+			}
+			a.logPrompt(mwCtx.Context(), req)
+			resp, err := a.generate(mwCtx.Context(), req)
 			if err != nil {
 				if a.logger != nil {
 					a.logger.Error("llm generation failed", "iteration", i+1, "error", err)
@@ -353,44 +375,37 @@ func (a *Agent) Run(ctx context.Context, input string) (*message.Message, error)
 				return fmt.Errorf("LLM generation failed: %w", err)
 			}
 
+			a.recordResponse(resp)
+			if resp.Provider != "" || resp.Model != "" {
+				span.SetAttributes(attribute.String("llm.provider", resp.Provider), attribute.String("llm.model", resp.Model))
+			}
+			if len(resp.Message.ToolCalls) == 0 {
+				retry, err := a.checkAnswer(resp.Message, &corrected)
+				if err != nil {
+					return err
+				}
+				if retry {
+					i-- // the correction turn does not use up an iteration
+					continue
+				}
+				if err := a.postProcess(resp.Message); err != nil {
+					return err
+				}
+			}
 			a.AddMessage(resp.Message)
 			mwCtx.Response = resp.Message
 
 			if len(resp.Message.ToolCalls) == 0 {
-				if a.enableMemory && a.memory != nil {
-					conversationContent := fmt.Sprintf("User: %s\nAssistant: %s", input, resp.Message.Text())
-					mem := &memory.Memory{
-						ID:       memory.GenerateMemoryID(),
-						Content:  conversationContent,
-						Metadata: map[string]any{"input": input, "response": resp.Message.Text()},
-					}
-					a.memory.AddMemory(mwCtx.Context(), mem)
-				}
+				a.rememberTurn(mwCtx.Context(), input, resp.Message)
 				if a.logger != nil {
 					a.logger.Info("agent run completed without tool calls", "iteration", i+1)
 				}
 				return nil
 			}
 
-			for _, toolCall := range resp.Message.ToolCalls {
-				if a.logger != nil {
-					a.logger.Info("executing tool call", "tool", toolCall.Name)
-				}
-				span.AddEvent("tool_call", oteltrace.WithAttributes(attribute.String("tool.name", toolCall.Name)))
-				result, err := a.tools.Execute(mwCtx.Context(), toolCall.Name, toolCall.Args)
-				if err != nil {
-					if a.logger != nil {
-						a.logger.Error("tool execution failed", "tool", toolCall.Name, "error", err)
-					}
-					span.AddEvent("tool_error",
-						oteltrace.WithAttributes(
-							attribute.String("tool.name", toolCall.Name),
-							attribute.String("error", err.Error()),
-						))
-					result = fmt.Sprintf("Error executing tool %s: %v", toolCall.Name, err)
-				}
-
-				toolMsg := message.NewToolResponseMessage(toolCall.ID, result)
+			results := a.executeToolCalls(mwCtx.Context(), resp.Message.ToolCalls)
+			for idx, toolCall := range resp.Message.ToolCalls {
+				toolMsg := message.NewToolResponseMessage(toolCall.ID, results[idx])
 				a.AddMessage(toolMsg)
 			}
 		}
@@ -421,10 +436,14 @@ func (a *Agent) Run(ctx context.Context, input string) (*message.Message, error)
 	return nil, spanErr
 }
 
-// Stream executes the agent with streaming responses
+// Stream executes the agent and passes messages to callback as they arrive.
+// With a streaming provider the callback receives each incremental chunk, the
+// assistant tool call message and tool responses of intermediate turns, and
+// finally the complete assistant message (Completed is true). The final message
+// is added to the agent context once. Providers without streaming support, and
+// agents with middlewares registered, fall back to Run so the middlewares apply;
+// the callback then receives the complete message once.
 func (a *Agent) Stream(ctx context.Context, input string, callback func(*message.Message) error) error {
-	// This is a placeholder for streaming implementation
-	// In a real implementation, this would stream tokens as they're generated
 	ctx, span := agentTracer.Start(ctx, "Agent.Stream",
 		oteltrace.WithAttributes(
 			attribute.String("agent.name", a.name),
@@ -433,29 +452,55 @@ func (a *Agent) Stream(ctx context.Context, input string, callback func(*message
 	var spanErr error
 	defer func() { telemetry.End(span, spanErr) }()
 	if a.logger != nil {
-		a.logger.Info("agent stream started", "input", trimLogText(input, 160))
+		a.logger.Info("agent stream started", "input", trimLogText(input, 160), "streaming", a.SupportsStreaming())
 	}
-	result, err := a.Run(ctx, input)
-	if err != nil {
+	if callback == nil {
+		callback = func(*message.Message) error { return nil }
+	}
+	if _, ok := a.streamProvider(); !ok {
+		result, err := a.Run(ctx, input)
+		if err != nil {
+			if a.logger != nil {
+				a.logger.Error("agent stream failed", "error", err)
+			}
+			spanErr = err
+			return err
+		}
 		if a.logger != nil {
-			a.logger.Error("agent stream failed", "error", err)
+			a.logger.Info("agent stream callback", "output", trimLogText(result.Text(), 160))
 		}
-		spanErr = err
-		return err
+		if err := callback(result); err != nil {
+			spanErr = err
+			return err
+		}
+		return nil
 	}
-	if a.logger != nil {
-		a.logger.Info("agent stream callback", "output", trimLogText(result.Text(), 160))
+
+	chunks := 0
+	for msg, err := range a.RunStream(ctx, input, nil) {
+		if err != nil {
+			if a.logger != nil {
+				a.logger.Error("agent stream failed", "error", err)
+			}
+			spanErr = err
+			return err
+		}
+		chunks++
+		if err := callback(msg); err != nil {
+			spanErr = err
+			return err
+		}
 	}
-	if err := callback(result); err != nil {
-		spanErr = err
-		return err
+	span.SetAttributes(attribute.Int("stream.messages", chunks))
+	if a.logger != nil {
+		a.logger.Info("agent stream completed", "messages", chunks)
 	}
 	return nil
 }
 
 // Clone creates a copy of the agent with the same configuration
 func (a *Agent) Clone() *Agent {
-	cloned := New(
+	opts := []Option{
 		WithName(a.name),
 		WithSystemPrompt(a.systemPrompt),
 		WithMaxIterations(a.maxIterations),
@@ -463,7 +508,22 @@ func (a *Agent) Clone() *Agent {
 		WithProvider(a.llm),
 		WithTools(a.enableTools),
 		WithLogger(a.logger),
-	)
+		WithResponseSchema(a.responseSchema),
+	}
+	if a.promptCache != nil {
+		opts = append(opts, WithPromptCaching(a.promptCache.TTL))
+	}
+	cloned := New(opts...)
+	cloned.promptLog = a.promptLog
+	cloned.sequenceRules = a.sequenceRules
+	cloned.postProcessors = append([]ResponsePostProcessor(nil), a.postProcessors...)
+	cloned.currentTime = a.currentTime
+	cloned.toolFilter = a.toolFilter
+	cloned.toolConcurrency = a.toolConcurrency
+	cloned.retryPolicy = a.retryPolicy
+	cloned.retriever = a.retriever
+	cloned.tools.SetArgValidation(a.tools.ArgValidation())
+	cloned.tools.SetDefaultTimeout(a.tools.DefaultTimeout())
 
 	// Clone memory store if set
 	if a.memory != nil {
@@ -1,11 +1,24 @@
 package agent
 
 import (
+	"bytes"
 	"context"
+	"errors"
+	"fmt"
+	"iter"
+	"log/slog"
+	"regexp"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/sweetpotato0/ai-allin/contrib/memory/inmemory"
+	"github.com/sweetpotato0/ai-allin/memory"
 	"github.com/sweetpotato0/ai-allin/message"
+	"github.com/sweetpotato0/ai-allin/middleware"
+	"github.com/sweetpotato0/ai-allin/parser"
+	"github.com/sweetpotato0/ai-allin/rag/document"
+	"github.com/sweetpotato0/ai-allin/rag/reranker"
 	"github.com/sweetpotato0/ai-allin/tool"
 )
 
@@ -231,3 +244,1322 @@ func TestAgentRestoreMessages(t *testing.T) {
 		t.Errorf("expected fallback to default system prompt, got %+v", messages)
 	}
 }
+
+func TestRunStreamFallsBackWithoutStreamingProvider(t *testing.T) {
+	agent := New(WithProvider(NewMockLLMClient()))
+	if agent.SupportsStreaming() {
+		t.Fatalf("mock provider should not report streaming support")
+	}
+
+	var callbacks []*message.Message
+	var yielded []*message.Message
+	for msg, err := range agent.RunStream(context.Background(), "hello", func(m *message.Message) error {
+		callbacks = append(callbacks, m)
+		return nil
+	}) {
+		if err != nil {
+			t.Fatalf("unexpected stream error: %v", err)
+		}
+		yielded = append(yielded, msg)
+	}
+
+	if len(callbacks) != 1 || callbacks[0].Text() != "Mock response" {
+		t.Fatalf("expected callback once with full message, got %+v", callbacks)
+	}
+	if len(yielded) != 1 || yielded[0] != callbacks[0] {
+		t.Fatalf("expected the same message to be yielded once, got %+v", yielded)
+	}
+
+	// A nil callback must not panic on the fallback path.
+	for _, err := range agent.RunStream(context.Background(), "again", nil) {
+		if err != nil {
+			t.Fatalf("unexpected stream error: %v", err)
+		}
+	}
+}
+
+// countingMiddleware counts the runs it wraps and tags their responses.
+type countingMiddleware struct{ calls int }
+
+func (m *countingMiddleware) Name() string { return "counting" }
+
+func (m *countingMiddleware) Execute(ctx *middleware.Context, next middleware.Handler) error {
+	m.calls++
+	if err := next(ctx); err != nil {
+		return err
+	}
+	if ctx.Response != nil {
+		ctx.Response = message.NewMessage(message.RoleAssistant, ctx.Response.Text()+" (checked)")
+	}
+	return nil
+}
+
+func TestStreamingPathsRunMiddlewares(t *testing.T) {
+	newAgent := func(mw *countingMiddleware) *Agent {
+		llm := &scriptedStreamLLM{
+			MockLLMClient: *NewMockLLMClient(),
+			turns: [][]*GenerateResponse{{
+				{Message: message.NewMessage(message.RoleAssistant, "hel")},
+				{Message: message.NewMessage(message.RoleAssistant, "hello")},
+			}},
+		}
+		llm.response = "hello"
+		return New(WithProvider(llm), WithMiddleware(mw))
+	}
+
+	mw := &countingMiddleware{}
+	var got []string
+	if err := newAgent(mw).Stream(context.Background(), "hi", func(m *message.Message) error {
+		got = append(got, m.Text())
+		return nil
+	}); err != nil {
+		t.Fatalf("Stream failed: %v", err)
+	}
+	if mw.calls != 1 || len(got) != 1 || got[0] != "hello (checked)" {
+		t.Fatalf("expected Stream to run the middleware once, got %d calls and %q", mw.calls, got)
+	}
+
+	mw = &countingMiddleware{}
+	var last *message.Message
+	for msg, err := range newAgent(mw).RunStream(context.Background(), "hi", nil) {
+		if err != nil {
+			t.Fatalf("RunStream failed: %v", err)
+		}
+		last = msg
+	}
+	if mw.calls != 1 || last == nil || last.Text() != "hello (checked)" {
+		t.Fatalf("expected RunStream to run the middleware once, got %d calls and %+v", mw.calls, last)
+	}
+
+	mw = &countingMiddleware{}
+	var final *message.Message
+	for ev, err := range newAgent(mw).RunStreamEvents(context.Background(), "hi") {
+		if err != nil {
+			t.Fatalf("RunStreamEvents failed: %v", err)
+		}
+		if ev.Type == StreamEventMessage {
+			final = ev.Message
+		}
+	}
+	if mw.calls != 1 || final == nil || final.Text() != "hello (checked)" {
+		t.Fatalf("expected RunStreamEvents to run the middleware once, got %d calls and %+v", mw.calls, final)
+	}
+}
+
+func TestAPIErrorRetriable(t *testing.T) {
+	cases := []struct {
+		err  error
+		want bool
+	}{
+		{NewAPIError("test", 429, errors.New("slow down")), true},
+		{NewAPIError("test", 503, errors.New("unavailable")), true},
+		{NewAPIError("test", 401, errors.New("unauthorized")), false},
+		{fmt.Errorf("wrapped: %w", NewAPIError("test", 502, errors.New("bad gateway"))), true},
+		{errors.New("plain"), false},
+		{nil, false},
+	}
+	for _, tc := range cases {
+		if got := IsRetriable(tc.err); got != tc.want {
+			t.Errorf("IsRetriable(%v) = %v, want %v", tc.err, got, tc.want)
+		}
+	}
+}
+
+func TestIsContextLengthExceeded(t *testing.T) {
+	cases := []struct {
+		err  error
+		want bool
+	}{
+		{NewAPIError("openai", 400, errors.New("This model's maximum context length is 8192 tokens")), true},
+		{NewAPIError("claude", 400, errors.New("prompt is too long: 210000 tokens > 200000 maximum")), true},
+		{NewAPIError("test", 413, errors.New("Request too large: too many tokens")), true},
+		{fmt.Errorf("synthesizer failed: %w", NewAPIError("test", 400, errors.New("context_length_exceeded"))), true},
+		{fmt.Errorf("wrapped: %w", ErrContextLengthExceeded), true},
+		{NewAPIError("test", 400, errors.New("invalid temperature")), false},
+		{NewAPIError("test", 500, errors.New("context length unknown")), false},
+		{errors.New("plain"), false},
+		{nil, false},
+	}
+	for _, tc := range cases {
+		if got := IsContextLengthExceeded(tc.err); got != tc.want {
+			t.Errorf("IsContextLengthExceeded(%v) = %v, want %v", tc.err, got, tc.want)
+		}
+	}
+}
+
+func TestResolveRole(t *testing.T) {
+	supported := []message.Role{message.RoleUser, message.RoleAssistant}
+
+	if role, err := ResolveRole(message.RoleUser, "", supported...); err != nil || role != message.RoleUser {
+		t.Errorf("expected supported role to pass through, got %q, %v", role, err)
+	}
+	if role, err := ResolveRole(message.RoleTool, message.RoleUser, supported...); err != nil || role != message.RoleUser {
+		t.Errorf("expected fallback role, got %q, %v", role, err)
+	}
+	if _, err := ResolveRole(message.Role("developer"), "", supported...); !errors.Is(err, ErrUnknownRole) {
+		t.Errorf("expected ErrUnknownRole, got %v", err)
+	}
+	if _, err := ResolveRole(message.RoleTool, message.RoleSystem, supported...); !errors.Is(err, ErrUnknownRole) {
+		t.Errorf("expected unsupported fallback to fail, got %v", err)
+	}
+}
+
+func TestRetryPolicyBackoff(t *testing.T) {
+	policy := RetryPolicy{InitialBackoff: 100 * time.Millisecond, MaxBackoff: 300 * time.Millisecond, Multiplier: 2}
+	expected := []time.Duration{100 * time.Millisecond, 200 * time.Millisecond, 300 * time.Millisecond, 300 * time.Millisecond}
+	for i, want := range expected {
+		if got := policy.Backoff(i + 1); got != want {
+			t.Errorf("Backoff(%d) = %v, want %v", i+1, got, want)
+		}
+	}
+}
+
+// mockStreamLLM streams each chunk as a partial message followed by a completed message.
+type mockStreamLLM struct {
+	MockLLMClient
+	chunks   []string
+	produced int
+}
+
+func newMockStreamLLM(chunks ...string) *mockStreamLLM {
+	return &mockStreamLLM{MockLLMClient: *NewMockLLMClient(), chunks: chunks}
+}
+
+func (m *mockStreamLLM) GenerateStream(ctx context.Context, req *GenerateRequest) iter.Seq2[*GenerateResponse, error] {
+	return func(yield func(*GenerateResponse, error) bool) {
+		full := ""
+		for _, chunk := range m.chunks {
+			m.produced++
+			full += chunk
+			if !yield(&GenerateResponse{Message: message.NewMessage(message.RoleAssistant, chunk)}, nil) {
+				return
+			}
+		}
+		final := message.NewMessage(message.RoleAssistant, full)
+		final.Completed = true
+		yield(&GenerateResponse{Message: final}, nil)
+	}
+}
+
+func TestRunStreamCallbackOrderingAndBackpressure(t *testing.T) {
+	llm := newMockStreamLLM("a", "b", "c")
+	agent := New(WithProvider(llm))
+
+	var received []string
+	for _, err := range agent.RunStream(context.Background(), "hi", func(m *message.Message) error {
+		received = append(received, m.Text())
+		if llm.produced != len(received) {
+			t.Errorf("provider ran ahead of callback: produced %d, delivered %d", llm.produced, len(received))
+		}
+		return nil
+	}) {
+		if err != nil {
+			t.Fatalf("unexpected stream error: %v", err)
+		}
+	}
+	if strings.Join(received, "") != "abc" {
+		t.Fatalf("expected chunks in order, got %v", received)
+	}
+}
+
+func TestBufferedCallback(t *testing.T) {
+	var received []string
+	buffered := NewBufferedCallback(func(m *message.Message) error {
+		received = append(received, m.Text())
+		return nil
+	}, 2, OverflowBlock)
+	for _, chunk := range []string{"1", "2", "3", "4"} {
+		if err := buffered.Callback()(message.NewMessage(message.RoleAssistant, chunk)); err != nil {
+			t.Fatalf("unexpected push error: %v", err)
+		}
+	}
+	if err := buffered.Close(); err != nil {
+		t.Fatalf("unexpected close error: %v", err)
+	}
+	if strings.Join(received, "") != "1234" {
+		t.Fatalf("expected ordered delivery, got %v", received)
+	}
+
+	release := make(chan struct{})
+	blocking := NewBufferedCallback(func(m *message.Message) error {
+		<-release
+		return nil
+	}, 1, OverflowError)
+	var overflow error
+	for i := 0; i < 4 && overflow == nil; i++ {
+		overflow = blocking.Callback()(message.NewMessage(message.RoleAssistant, "x"))
+	}
+	close(release)
+	_ = blocking.Close()
+	if !errors.Is(overflow, ErrStreamBufferFull) {
+		t.Fatalf("expected ErrStreamBufferFull, got %v", overflow)
+	}
+}
+
+// scriptedStreamLLM replays one scripted response sequence per call.
+type scriptedStreamLLM struct {
+	MockLLMClient
+	turns [][]*GenerateResponse
+	call  int
+}
+
+func (s *scriptedStreamLLM) GenerateStream(ctx context.Context, req *GenerateRequest) iter.Seq2[*GenerateResponse, error] {
+	return func(yield func(*GenerateResponse, error) bool) {
+		turn := s.turns[s.call]
+		s.call++
+		for _, resp := range turn {
+			if !yield(resp, nil) {
+				return
+			}
+		}
+	}
+}
+
+func TestRunStreamEventsToolArgumentDeltas(t *testing.T) {
+	toolCallMsg := message.NewEmptyMessage(message.RoleAssistant)
+	toolCallMsg.Completed = true
+	toolCallMsg.ToolCalls = []message.ToolCall{{ID: "call-1", Name: "search", Args: map[string]any{"query": "go iterators"}}}
+	answer := message.NewMessage(message.RoleAssistant, "done")
+	answer.Completed = true
+
+	llm := &scriptedStreamLLM{
+		MockLLMClient: *NewMockLLMClient(),
+		turns: [][]*GenerateResponse{
+			{
+				{Message: message.NewEmptyMessage(message.RoleAssistant), ToolCallDeltas: []ToolCallDelta{{Index: 0, ID: "call-1", Name: "search", Arguments: `{"query":`}}},
+				{Message: message.NewEmptyMessage(message.RoleAssistant), ToolCallDeltas: []ToolCallDelta{{Index: 0, Arguments: `"go iterators"}`}}},
+				{Message: toolCallMsg},
+			},
+			{
+				{Message: message.NewMessage(message.RoleAssistant, "do")},
+				{Message: message.NewMessage(message.RoleAssistant, "ne")},
+				{Message: answer},
+			},
+		},
+	}
+
+	ag := New(WithProvider(llm))
+	_ = ag.RegisterTool(&tool.Tool{
+		Name:       "search",
+		Parameters: []tool.Parameter{{Name: "query", Type: "string", Required: true}},
+		Handler: func(ctx context.Context, args map[string]any) (string, error) {
+			return "results for " + args["query"].(string), nil
+		},
+	})
+
+	var types []StreamEventType
+	var argJSON, text string
+	var result string
+	for ev, err := range ag.RunStreamEvents(context.Background(), "search please") {
+		if err != nil {
+			t.Fatalf("unexpected stream error: %v", err)
+		}
+		types = append(types, ev.Type)
+		switch ev.Type {
+		case StreamEventToolArgsDelta:
+			argJSON += ev.ToolDelta.Arguments
+		case StreamEventText:
+			text += ev.Delta
+		case StreamEventToolResult:
+			result = ev.ToolCall.Response
+		}
+	}
+
+	expected := []StreamEventType{
+		StreamEventToolArgsDelta, StreamEventToolArgsDelta, StreamEventToolCall, StreamEventToolResult,
+		StreamEventText, StreamEventText, StreamEventMessage,
+	}
+	if fmt.Sprint(types) != fmt.Sprint(expected) {
+		t.Fatalf("unexpected event sequence %v", types)
+	}
+	if argJSON != `{"query":"go iterators"}` {
+		t.Errorf("unexpected accumulated arguments %q", argJSON)
+	}
+	if result != "results for go iterators" || text != "done" {
+		t.Errorf("unexpected result %q or text %q", result, text)
+	}
+}
+
+func TestRunStreamExecutesToolsUntilFinalAnswer(t *testing.T) {
+	toolCallMsg := message.NewEmptyMessage(message.RoleAssistant)
+	toolCallMsg.Completed = true
+	toolCallMsg.ToolCalls = []message.ToolCall{{ID: "call-1", Name: "lookup", Args: map[string]any{"key": "answer"}}}
+	answer := message.NewMessage(message.RoleAssistant, "it is 42")
+	answer.Completed = true
+
+	llm := &scriptedStreamLLM{
+		MockLLMClient: *NewMockLLMClient(),
+		turns: [][]*GenerateResponse{
+			{{Message: toolCallMsg}},
+			{
+				{Message: message.NewMessage(message.RoleAssistant, "it is ")},
+				{Message: message.NewMessage(message.RoleAssistant, "42")},
+				{Message: answer},
+			},
+		},
+	}
+	ag := New(WithProvider(llm))
+	_ = ag.RegisterTool(&tool.Tool{
+		Name:       "lookup",
+		Parameters: []tool.Parameter{{Name: "key", Type: "string", Required: true}},
+		Handler: func(ctx context.Context, args map[string]any) (string, error) {
+			return "42", nil
+		},
+	})
+
+	var tokens string
+	var yielded []*message.Message
+	for msg, err := range ag.RunStream(context.Background(), "what is the answer?", func(m *message.Message) error {
+		tokens += m.Text()
+		return nil
+	}) {
+		if err != nil {
+			t.Fatalf("unexpected stream error: %v", err)
+		}
+		yielded = append(yielded, msg)
+	}
+
+	if llm.call != 2 {
+		t.Fatalf("expected two LLM calls, got %d", llm.call)
+	}
+	if tokens != "it is 42" {
+		t.Errorf("unexpected streamed tokens %q", tokens)
+	}
+	if len(yielded) == 0 || yielded[len(yielded)-1] != answer {
+		t.Fatalf("expected the final answer to be yielded last, got %+v", yielded)
+	}
+	var toolResult *message.Message
+	for _, msg := range yielded {
+		if msg.Role == message.RoleTool {
+			toolResult = msg
+		}
+	}
+	if toolResult == nil || toolResult.Text() != "42" {
+		t.Fatalf("expected the tool result to be yielded, got %+v", toolResult)
+	}
+}
+
+func TestStreamForwardsChunksAndAssemblesToolCalls(t *testing.T) {
+	// The provider streams the tool call only as deltas and never sends a completed message.
+	llm := &scriptedStreamLLM{
+		MockLLMClient: *NewMockLLMClient(),
+		turns: [][]*GenerateResponse{
+			{
+				{Message: message.NewEmptyMessage(message.RoleAssistant), ToolCallDeltas: []ToolCallDelta{{Index: 0, ID: "call-1", Name: "lookup", Arguments: `{"key":`}}},
+				{Message: message.NewEmptyMessage(message.RoleAssistant), ToolCallDeltas: []ToolCallDelta{{Index: 0, Arguments: `"answer"}`}}},
+			},
+			{
+				{Message: message.NewMessage(message.RoleAssistant, "it is ")},
+				{Message: message.NewMessage(message.RoleAssistant, "42")},
+			},
+		},
+	}
+	ag := New(WithProvider(llm))
+	var gotArgs map[string]any
+	_ = ag.RegisterTool(&tool.Tool{
+		Name:       "lookup",
+		Parameters: []tool.Parameter{{Name: "key", Type: "string", Required: true}},
+		Handler: func(ctx context.Context, args map[string]any) (string, error) {
+			gotArgs = args
+			return "42", nil
+		},
+	})
+
+	var deltas []string
+	var final *message.Message
+	err := ag.Stream(context.Background(), "what is the answer?", func(m *message.Message) error {
+		if m.Role == message.RoleAssistant && !m.Completed && m.Text() != "" {
+			deltas = append(deltas, m.Text())
+		}
+		final = m
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Stream failed: %v", err)
+	}
+	if gotArgs["key"] != "answer" {
+		t.Fatalf("expected tool call assembled from deltas, got args %v", gotArgs)
+	}
+	if strings.Join(deltas, "|") != "it is |42" {
+		t.Fatalf("expected chunks forwarded as they arrive, got %q", deltas)
+	}
+	if final == nil || !final.Completed || final.Text() != "it is 42" {
+		t.Fatalf("expected assembled final message last, got %+v", final)
+	}
+	answers := 0
+	for _, msg := range ag.GetMessages() {
+		if msg.Role == message.RoleAssistant && msg.Text() == "it is 42" {
+			answers++
+		}
+	}
+	if answers != 1 {
+		t.Fatalf("expected the final message in context exactly once, got %d", answers)
+	}
+}
+
+func TestRunStreamText(t *testing.T) {
+	llm := newMockStreamLLM("a", "b", "c")
+	ag := New(WithProvider(llm))
+
+	var tokens []string
+	final, err := ag.RunStreamText(context.Background(), "letters", func(token string) error {
+		tokens = append(tokens, token)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("RunStreamText failed: %v", err)
+	}
+	if strings.Join(tokens, "") != "abc" || final.Text() != "abc" {
+		t.Fatalf("unexpected tokens %q and final %q", tokens, final.Text())
+	}
+
+	stop := errors.New("stop")
+	llm = newMockStreamLLM("a", "b", "c")
+	ag = New(WithProvider(llm))
+	_, err = ag.RunStreamText(context.Background(), "letters", func(token string) error {
+		if token == "b" {
+			return stop
+		}
+		return nil
+	})
+	if !errors.Is(err, stop) {
+		t.Fatalf("expected callback error to propagate, got %v", err)
+	}
+	if llm.produced != 2 {
+		t.Fatalf("expected the provider stream to stop after the failing token, produced %d", llm.produced)
+	}
+}
+
+func TestPromptLoggingRedactsContent(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	ag := New(
+		WithProvider(NewMockLLMClient()),
+		WithLogger(logger),
+		WithPromptLogging(slog.LevelDebug, RedactPatterns(regexp.MustCompile(`sk-[a-z0-9]+`))),
+	)
+
+	if _, err := ag.Run(context.Background(), "my key is sk-abc123"); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+
+	var out string
+	for _, line := range strings.Split(buf.String(), "\n") {
+		if strings.Contains(line, "llm prompt") {
+			out = line
+		}
+	}
+	if out == "" {
+		t.Fatalf("expected prompt log entry, got %q", buf.String())
+	}
+	if strings.Contains(out, "sk-abc123") || !strings.Contains(out, "[REDACTED]") {
+		t.Errorf("expected secret to be redacted, got %q", out)
+	}
+}
+
+type stubRetriever struct {
+	results []reranker.Result
+	queries []string
+}
+
+func (s *stubRetriever) Search(ctx context.Context, query string) ([]reranker.Result, error) {
+	s.queries = append(s.queries, query)
+	return s.results, nil
+}
+
+type recordingLLM struct {
+	*MockLLMClient
+	last *GenerateRequest
+}
+
+func (r *recordingLLM) Generate(ctx context.Context, req *GenerateRequest) (*GenerateResponse, error) {
+	r.last = req
+	return r.MockLLMClient.Generate(ctx, req)
+}
+
+func TestWithRetrieverInjectsContext(t *testing.T) {
+	ret := &stubRetriever{results: []reranker.Result{
+		{Chunk: document.Chunk{DocumentID: "returns", Content: "Returns are accepted within 30 days."}, Score: 0.9},
+	}}
+	llm := &recordingLLM{MockLLMClient: NewMockLLMClient()}
+	ag := New(WithProvider(llm), WithRetriever(ret))
+
+	if _, err := ag.Run(context.Background(), "What is the return window?"); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	if len(ret.queries) != 1 || ret.queries[0] != "What is the return window?" {
+		t.Fatalf("expected retriever to be queried with the input, got %v", ret.queries)
+	}
+	var found bool
+	for _, msg := range llm.last.Messages {
+		if msg.Role == message.RoleSystem && strings.Contains(msg.Text(), "[Doc:returns] Returns are accepted within 30 days.") {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("expected retrieved chunk in request messages")
+	}
+}
+
+type waitingLLM struct {
+	*MockLLMClient
+	started chan struct{}
+}
+
+func (w *waitingLLM) Generate(ctx context.Context, req *GenerateRequest) (*GenerateResponse, error) {
+	close(w.started)
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
+func TestRunWithIDCancel(t *testing.T) {
+	llm := &waitingLLM{MockLLMClient: NewMockLLMClient(), started: make(chan struct{})}
+	ag := New(WithProvider(llm))
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := ag.RunWithID(context.Background(), "run-1", "hello")
+		done <- err
+	}()
+	<-llm.started
+
+	if ids := ag.ActiveRuns(); len(ids) != 1 || ids[0] != "run-1" {
+		t.Fatalf("expected run-1 to be active, got %v", ids)
+	}
+	if _, err := ag.RunWithID(context.Background(), "run-1", "again"); err == nil {
+		t.Errorf("expected duplicate run ID to be rejected")
+	}
+	if !ag.CancelRun("run-1") {
+		t.Fatalf("expected CancelRun to find run-1")
+	}
+
+	select {
+	case err := <-done:
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("expected context.Canceled, got %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("run was not cancelled")
+	}
+	if ag.CancelRun("run-1") {
+		t.Errorf("expected finished run to be removed from the registry")
+	}
+}
+
+func TestMergeParams(t *testing.T) {
+	base := map[string]any{"top_p": 0.9, "seed": 1}
+	merged := MergeParams(base, map[string]any{"seed": 2})
+	if merged["top_p"] != 0.9 || merged["seed"] != 2 {
+		t.Errorf("unexpected merge result %v", merged)
+	}
+	if base["seed"] != 1 {
+		t.Errorf("expected base to be unchanged, got %v", base)
+	}
+	if MergeParams(nil, nil) != nil {
+		t.Errorf("expected nil for empty inputs")
+	}
+}
+
+func TestSequenceValidation(t *testing.T) {
+	restored := []*message.Message{
+		message.NewMessage(message.RoleSystem, "sys"),
+		message.NewMessage(message.RoleAssistant, "welcome back"),
+		message.NewMessage(message.RoleUser, "first"),
+	}
+
+	err := ValidateSequence(append(restored, message.NewMessage(message.RoleUser, "second")), SequenceRules{UserFirst: true, Alternate: true})
+	var seqErr *SequenceError
+	if !errors.As(err, &seqErr) || seqErr.Index != 1 || !errors.Is(err, ErrInvalidSequence) {
+		t.Fatalf("expected sequence error at index 1, got %v", err)
+	}
+
+	llm := &recordingLLM{MockLLMClient: NewMockLLMClient()}
+	strict := New(WithProvider(llm), WithSequenceValidation(SequenceRules{Alternate: true}))
+	strict.RestoreMessages(restored)
+	if _, err := strict.Run(context.Background(), "again"); !errors.Is(err, ErrInvalidSequence) {
+		t.Fatalf("expected run to fail on consecutive user messages, got %v", err)
+	}
+	if llm.last != nil {
+		t.Fatalf("expected provider not to be called")
+	}
+
+	repairing := New(WithProvider(llm), WithSequenceValidation(SequenceRules{Alternate: true, Repair: true}))
+	repairing.RestoreMessages(restored)
+	if _, err := repairing.Run(context.Background(), "again"); err != nil {
+		t.Fatalf("expected repaired history to pass, got %v", err)
+	}
+	last := llm.last.Messages[len(llm.last.Messages)-1]
+	if last.Role != message.RoleUser || last.Text() != "first\n\nagain" {
+		t.Fatalf("expected consecutive user messages to be merged, got %q", last.Text())
+	}
+	if len(repairing.GetMessages()) != 5 {
+		t.Fatalf("expected repair not to rewrite the stored history, got %d messages", len(repairing.GetMessages()))
+	}
+}
+
+type attributedLLM struct {
+	*MockLLMClient
+}
+
+func (a *attributedLLM) Generate(ctx context.Context, req *GenerateRequest) (*GenerateResponse, error) {
+	resp, err := a.MockLLMClient.Generate(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	resp.Provider, resp.Model = "backup", "model-b"
+	return resp, nil
+}
+
+func TestRunRecordsServingBackend(t *testing.T) {
+	ag := New(WithProvider(&attributedLLM{MockLLMClient: NewMockLLMClient()}))
+	msg, err := ag.Run(context.Background(), "hello")
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	if msg.Metadata[MetadataProvider] != "backup" || msg.Metadata[MetadataModel] != "model-b" {
+		t.Fatalf("expected provider and model in metadata, got %v", msg.Metadata)
+	}
+}
+
+func TestResponsePostProcessor(t *testing.T) {
+	llm := NewMockLLMClient()
+	llm.response = "<think>plan</think>Final answer"
+	stripThinking := regexp.MustCompile(`(?s)<think>.*?</think>`)
+	ag := New(
+		WithProvider(llm),
+		WithResponsePostProcessor(func(msg *message.Message) error {
+			msg.SetText(stripThinking.ReplaceAllString(msg.Text(), ""))
+			return nil
+		}),
+		WithResponsePostProcessor(func(msg *message.Message) error {
+			msg.SetText(strings.ToUpper(msg.Text()))
+			return nil
+		}),
+	)
+
+	msg, err := ag.Run(context.Background(), "hi")
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	if msg.Text() != "FINAL ANSWER" {
+		t.Fatalf("expected processors to run in order, got %q", msg.Text())
+	}
+	history := ag.GetMessages()
+	if history[len(history)-1].Text() != "FINAL ANSWER" {
+		t.Fatalf("expected processed message in history")
+	}
+
+	failing := New(WithProvider(llm), WithResponsePostProcessor(func(*message.Message) error {
+		return errors.New("too long")
+	}))
+	if _, err := failing.Run(context.Background(), "hi"); err == nil || !strings.Contains(err.Error(), "too long") {
+		t.Fatalf("expected post-processor error, got %v", err)
+	}
+}
+
+func TestWithCurrentTimeInjectsTimestamp(t *testing.T) {
+	fixed := time.Date(2025, time.March, 14, 9, 30, 0, 0, time.UTC)
+	llm := &recordingLLM{MockLLMClient: NewMockLLMClient()}
+	tokyo := time.FixedZone("JST", 9*60*60)
+	ag := New(WithProvider(llm), WithCurrentTime(func() time.Time { return fixed }), WithCurrentTimeLocation(tokyo))
+
+	if _, err := ag.Run(context.Background(), "what day is it?"); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	want := "Current date and time: Friday, 2025-03-14 18:30:00 JST (UTC+09:00)"
+	var found bool
+	for _, msg := range llm.last.Messages {
+		if msg.Role == message.RoleSystem && msg.Text() == want {
+			found = true
+		}
+	}
+	if !found {
+		t.Fatalf("expected %q in request messages", want)
+	}
+
+	// The timestamp is sent with each request but never stored in the context.
+	if _, err := ag.Run(context.Background(), "and now?"); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	for _, msg := range ag.GetMessages() {
+		if strings.HasPrefix(msg.Text(), "Current date and time:") {
+			t.Fatalf("expected no timestamp in the context, got %q", msg.Text())
+		}
+	}
+	stamps := 0
+	for _, msg := range llm.last.Messages {
+		if strings.HasPrefix(msg.Text(), "Current date and time:") {
+			stamps++
+		}
+	}
+	if stamps != 1 {
+		t.Fatalf("expected one timestamp per request, got %d", stamps)
+	}
+}
+
+func TestWithToolFilterNarrowsAdvertisedTools(t *testing.T) {
+	llm := &recordingLLM{MockLLMClient: NewMockLLMClient()}
+	var gotInput string
+	var gotNames []string
+	ag := New(WithProvider(llm), WithToolFilter(func(input string, tools []*tool.Tool) []*tool.Tool {
+		gotInput = input
+		var kept []*tool.Tool
+		for _, t := range tools {
+			gotNames = append(gotNames, t.Name)
+			if strings.Contains(input, t.Name) {
+				kept = append(kept, t)
+			}
+		}
+		return kept
+	}))
+	for _, name := range []string{"weather", "calendar", "search"} {
+		if err := ag.RegisterTool(&tool.Tool{Name: name, Description: name}); err != nil {
+			t.Fatalf("RegisterTool failed: %v", err)
+		}
+	}
+
+	if _, err := ag.Run(context.Background(), "check the weather"); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	if gotInput != "check the weather" || strings.Join(gotNames, ",") != "calendar,search,weather" {
+		t.Fatalf("unexpected filter arguments: %q %v", gotInput, gotNames)
+	}
+	if len(llm.last.Tools) != 1 || llm.last.Tools[0]["function"].(map[string]any)["name"] != "weather" {
+		t.Fatalf("expected only the weather tool, got %v", llm.last.Tools)
+	}
+}
+
+// queuedLLM answers with its replies in order and records every request.
+type queuedLLM struct {
+	*MockLLMClient
+	replies  []string
+	requests []*GenerateRequest
+}
+
+func (q *queuedLLM) Generate(ctx context.Context, req *GenerateRequest) (*GenerateResponse, error) {
+	q.requests = append(q.requests, req)
+	msg := message.NewMessage(message.RoleAssistant, q.replies[len(q.requests)-1])
+	msg.Completed = true
+	return &GenerateResponse{Message: msg}, nil
+}
+
+func TestRunParsedRepromptsOnParseError(t *testing.T) {
+	llm := &queuedLLM{MockLLMClient: NewMockLLMClient(), replies: []string{"apples and pears", "- apples\n- pears"}}
+	ag := New(WithProvider(llm))
+
+	value, err := ag.RunParsed(context.Background(), "list two fruits", parser.NewListParser())
+	if err != nil {
+		t.Fatalf("RunParsed failed: %v", err)
+	}
+	if fmt.Sprint(value) != "[apples pears]" {
+		t.Fatalf("unexpected parsed value %v", value)
+	}
+	if len(llm.requests) != 2 {
+		t.Fatalf("expected one re-prompt, got %d requests", len(llm.requests))
+	}
+	msgs := llm.requests[1].Messages
+	if retry := msgs[len(msgs)-1].Text(); !strings.Contains(retry, "could not be parsed") {
+		t.Fatalf("expected re-prompt to mention the parse error, got %q", retry)
+	}
+
+	failing := New(WithProvider(&queuedLLM{MockLLMClient: NewMockLLMClient(), replies: []string{"no", "still no"}}))
+	if _, err := failing.RunParsed(context.Background(), "list two fruits", parser.NewListParser()); !errors.Is(err, parser.ErrNoMatch) {
+		t.Fatalf("expected ErrNoMatch after the retry, got %v", err)
+	}
+}
+
+// structuredLLM is a queuedLLM that enforces response formats natively.
+type structuredLLM struct {
+	*queuedLLM
+}
+
+func (s *structuredLLM) SupportsResponseFormat() bool { return true }
+
+func TestWithResponseSchema(t *testing.T) {
+	schema := map[string]any{"type": "object", "required": []any{"name"}}
+
+	native := &structuredLLM{&queuedLLM{MockLLMClient: NewMockLLMClient(), replies: []string{`{"name": "go"}`}}}
+	if _, err := New(WithProvider(native), WithResponseSchema(schema)).Run(context.Background(), "pick a language"); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	req := native.requests[0]
+	if req.ResponseFormat == nil || req.ResponseFormat.Schema["type"] != "object" {
+		t.Fatalf("expected schema on native request, got %+v", req.ResponseFormat)
+	}
+	if strings.Contains(req.Messages[0].Text(), "JSON Schema") {
+		t.Fatalf("expected no prompt fallback for native provider, got %q", req.Messages[0].Text())
+	}
+
+	fallback := &queuedLLM{MockLLMClient: NewMockLLMClient(), replies: []string{`{"lang": "go"}`, "```json\n{\"name\": \"go\"}\n```"}}
+	ag := New(WithProvider(fallback), WithResponseSchema(schema))
+	resp, err := ag.Run(context.Background(), "pick a language")
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	if !strings.Contains(resp.Text(), `"name"`) || len(fallback.requests) != 2 {
+		t.Fatalf("expected corrected answer after one retry, got %q after %d requests", resp.Text(), len(fallback.requests))
+	}
+	first := fallback.requests[0]
+	if first.ResponseFormat != nil || !strings.Contains(first.Messages[0].Text(), `"required":["name"]`) {
+		t.Fatalf("expected schema in system prompt only, got %q", first.Messages[0].Text())
+	}
+	msgs := fallback.requests[1].Messages
+	if retry := msgs[len(msgs)-1].Text(); !strings.Contains(retry, `missing required field "name"`) {
+		t.Fatalf("expected correction prompt, got %q", retry)
+	}
+
+	failing := New(WithProvider(&queuedLLM{MockLLMClient: NewMockLLMClient(), replies: []string{"no", "still no"}}), WithResponseSchema(schema))
+	if _, err := failing.Run(context.Background(), "pick a language"); !errors.Is(err, ErrResponseSchema) {
+		t.Fatalf("expected ErrResponseSchema after the retry, got %v", err)
+	}
+}
+
+func TestWithResponseSchemaCorrectsOutsideIterationBudget(t *testing.T) {
+	schema := map[string]any{"type": "object", "required": []any{"name"}}
+	llm := &queuedLLM{MockLLMClient: NewMockLLMClient(), replies: []string{`{"lang": "go"}`, `{"name": "go"}`}}
+	ag := New(WithProvider(llm), WithResponseSchema(schema), WithMaxIterations(1))
+	if resp, err := ag.Run(context.Background(), "pick a language"); err != nil || !strings.Contains(resp.Text(), `"name"`) {
+		t.Fatalf("expected the correction to run with a single iteration, got %v (%v)", resp, err)
+	}
+}
+
+func TestWithResponseSchemaOnStreamingPaths(t *testing.T) {
+	schema := map[string]any{"type": "object", "required": []any{"name"}}
+	newAgent := func(replies ...string) *Agent {
+		llm := &scriptedStreamLLM{MockLLMClient: *NewMockLLMClient()}
+		for _, reply := range replies {
+			llm.turns = append(llm.turns, []*GenerateResponse{{Message: message.NewMessage(message.RoleAssistant, reply)}})
+		}
+		return New(WithProvider(llm), WithResponseSchema(schema), WithMaxIterations(1))
+	}
+
+	var last *message.Message
+	for msg, err := range newAgent(`{"lang": "go"}`, `{"name": "go"}`).RunStream(context.Background(), "pick a language", nil) {
+		if err != nil {
+			t.Fatalf("RunStream failed: %v", err)
+		}
+		last = msg
+	}
+	if last == nil || last.Text() != `{"name": "go"}` {
+		t.Fatalf("expected RunStream to end with the corrected answer, got %+v", last)
+	}
+	var streamErr error
+	for _, err := range newAgent("no", "still no").RunStream(context.Background(), "pick a language", nil) {
+		if err != nil {
+			streamErr = err
+		}
+	}
+	if !errors.Is(streamErr, ErrResponseSchema) {
+		t.Fatalf("expected ErrResponseSchema from RunStream, got %v", streamErr)
+	}
+
+	var final *message.Message
+	for ev, err := range newAgent(`{"lang": "go"}`, `{"name": "go"}`).RunStreamEvents(context.Background(), "pick a language") {
+		if err != nil {
+			t.Fatalf("RunStreamEvents failed: %v", err)
+		}
+		if ev.Type == StreamEventMessage {
+			final = ev.Message
+		}
+	}
+	if final == nil || final.Text() != `{"name": "go"}` {
+		t.Fatalf("expected RunStreamEvents to end with the corrected answer, got %+v", final)
+	}
+	streamErr = nil
+	for _, err := range newAgent("no", "still no").RunStreamEvents(context.Background(), "pick a language") {
+		if err != nil {
+			streamErr = err
+		}
+	}
+	if !errors.Is(streamErr, ErrResponseSchema) {
+		t.Fatalf("expected ErrResponseSchema from RunStreamEvents, got %v", streamErr)
+	}
+}
+
+func TestWithPromptCachingMarksSystemPrompt(t *testing.T) {
+	llm := &recordingLLM{MockLLMClient: NewMockLLMClient()}
+	ag := New(WithProvider(llm), WithSystemPrompt("long stable instructions"), WithPromptCaching("1h"))
+
+	for _, a := range []*Agent{ag, ag.Clone()} {
+		if _, err := a.Run(context.Background(), "hi"); err != nil {
+			t.Fatalf("Run failed: %v", err)
+		}
+		system := llm.last.Messages[0]
+		if system.Role != message.RoleSystem || system.CacheControl == nil || system.CacheControl.TTL != "1h" {
+			t.Fatalf("expected cacheable system prompt, got %+v", system)
+		}
+		if user := llm.last.Messages[len(llm.last.Messages)-1]; user.CacheControl != nil {
+			t.Fatalf("expected user message without cache control")
+		}
+	}
+}
+
+type meteredLLM struct {
+	*MockLLMClient
+}
+
+func (m *meteredLLM) Generate(ctx context.Context, req *GenerateRequest) (*GenerateResponse, error) {
+	resp, err := m.MockLLMClient.Generate(ctx, req)
+	if err == nil {
+		resp.Usage = Usage{PromptTokens: 7, CompletionTokens: 2}
+	}
+	return resp, err
+}
+
+func TestAgentAccumulatesUsage(t *testing.T) {
+	ag := New(WithProvider(&meteredLLM{MockLLMClient: NewMockLLMClient()}))
+	var reply *message.Message
+	for _, input := range []string{"a", "b"} {
+		msg, err := ag.Run(context.Background(), input)
+		if err != nil {
+			t.Fatalf("Run failed: %v", err)
+		}
+		reply = msg
+	}
+	if got := ag.Usage(); got != (Usage{PromptTokens: 14, CompletionTokens: 4, TotalTokens: 18}) {
+		t.Fatalf("unexpected accumulated usage %+v", got)
+	}
+	if reply.Metadata[MetadataUsage] != (Usage{PromptTokens: 7, CompletionTokens: 2, TotalTokens: 9}) {
+		t.Fatalf("expected per-response usage in metadata, got %v", reply.Metadata[MetadataUsage])
+	}
+	if !ag.Clone().Usage().IsZero() {
+		t.Fatal("expected clone to start with zero usage")
+	}
+	if got := ag.LastRunUsage(); got != (Usage{PromptTokens: 7, CompletionTokens: 2, TotalTokens: 9}) {
+		t.Fatalf("expected last run usage of a single call, got %+v", got)
+	}
+}
+
+func TestUsageKeepsReportedTotal(t *testing.T) {
+	// Reasoning tokens count towards a provider's total but not its parts.
+	reported := Usage{PromptTokens: 10, CompletionTokens: 5, TotalTokens: 40}
+	if got := reported.Add(Usage{PromptTokens: 1, CompletionTokens: 1}); got != (Usage{PromptTokens: 11, CompletionTokens: 6, TotalTokens: 42}) {
+		t.Fatalf("unexpected sum %+v", got)
+	}
+}
+
+func TestLastRunUsageSumsIterations(t *testing.T) {
+	toolCallMsg := message.NewEmptyMessage(message.RoleAssistant)
+	toolCallMsg.Completed = true
+	toolCallMsg.ToolCalls = []message.ToolCall{{ID: "call-1", Name: "lookup", Args: map[string]any{}}}
+	answer := message.NewMessage(message.RoleAssistant, "done")
+	answer.Completed = true
+
+	llm := &scriptedStreamLLM{
+		MockLLMClient: *NewMockLLMClient(),
+		turns: [][]*GenerateResponse{
+			{{Message: toolCallMsg, Usage: Usage{PromptTokens: 5, CompletionTokens: 1}}},
+			{{Message: answer, Usage: Usage{PromptTokens: 8, CompletionTokens: 3}}},
+		},
+	}
+	ag := New(WithProvider(llm))
+	_ = ag.RegisterTool(&tool.Tool{
+		Name: "lookup",
+		Handler: func(ctx context.Context, args map[string]any) (string, error) {
+			return "ok", nil
+		},
+	})
+	if _, err := ag.RunStreamText(context.Background(), "go", nil); err != nil {
+		t.Fatalf("RunStreamText failed: %v", err)
+	}
+	if got := ag.LastRunUsage(); got != (Usage{PromptTokens: 13, CompletionTokens: 4, TotalTokens: 17}) {
+		t.Fatalf("expected usage summed across iterations, got %+v", got)
+	}
+}
+
+func TestRunWithOptionsOverridesOnlyThatCall(t *testing.T) {
+	llm := &queuedLLM{MockLLMClient: NewMockLLMClient(), replies: []string{"first", "second"}}
+	ag := New(WithProvider(llm))
+
+	if _, err := ag.RunWithOptions(context.Background(), "hi", WithCallTemperature(0), WithCallModel("gpt-4o"), WithCallMaxTokens(64)); err != nil {
+		t.Fatalf("RunWithOptions failed: %v", err)
+	}
+	if _, err := ag.Run(context.Background(), "again"); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+
+	first, second := llm.requests[0], llm.requests[1]
+	if first.Model != "gpt-4o" || first.MaxTokens != 64 || first.Temperature == nil || *first.Temperature != 0 {
+		t.Fatalf("expected overrides on the first request, got model=%q max=%d temp=%v", first.Model, first.MaxTokens, first.Temperature)
+	}
+	if second.Model != "" || second.MaxTokens != 0 || second.Temperature != nil {
+		t.Fatalf("expected no overrides on the next call, got %+v", second)
+	}
+}
+
+type toolCallingLLM struct {
+	*MockLLMClient
+	calls []message.ToolCall
+	turns int
+}
+
+func (l *toolCallingLLM) Generate(ctx context.Context, req *GenerateRequest) (*GenerateResponse, error) {
+	l.turns++
+	msg := message.NewMessage(message.RoleAssistant, "done")
+	if l.turns == 1 {
+		msg = message.NewEmptyMessage(message.RoleAssistant)
+		msg.ToolCalls = l.calls
+	}
+	msg.Completed = true
+	return &GenerateResponse{Message: msg}, nil
+}
+
+func TestToolConcurrencyRunsCallsInParallelInOrder(t *testing.T) {
+	llm := &toolCallingLLM{MockLLMClient: NewMockLLMClient(), calls: []message.ToolCall{
+		{ID: "c1", Name: "slow", Args: map[string]any{"n": "1"}},
+		{ID: "c2", Name: "slow", Args: map[string]any{"n": "2"}},
+		{ID: "c3", Name: "broken", Args: map[string]any{}},
+	}}
+	ag := New(WithProvider(llm), WithToolConcurrency(3))
+
+	started := make(chan struct{}, 2)
+	release := make(chan struct{})
+	_ = ag.RegisterTool(&tool.Tool{
+		Name:       "slow",
+		Parameters: []tool.Parameter{{Name: "n", Type: "string", Required: true}},
+		Handler: func(ctx context.Context, args map[string]any) (string, error) {
+			started <- struct{}{}
+			<-release
+			return "result " + args["n"].(string), nil
+		},
+	})
+	_ = ag.RegisterTool(&tool.Tool{
+		Name: "broken",
+		Handler: func(ctx context.Context, args map[string]any) (string, error) {
+			return "", errors.New("boom")
+		},
+	})
+	overlapped := make(chan bool, 1)
+	go func() {
+		// Both slow calls must be in flight at once before either may finish.
+		defer close(release)
+		for range 2 {
+			select {
+			case <-started:
+			case <-time.After(time.Second):
+				overlapped <- false
+				return
+			}
+		}
+		overlapped <- true
+	}()
+
+	if _, err := ag.Run(context.Background(), "go"); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	var results []string
+	for _, msg := range ag.GetMessages() {
+		if msg.Role == message.RoleTool {
+			results = append(results, msg.Text())
+		}
+	}
+	want := []string{"result 1", "result 2", "Error executing tool broken: boom"}
+	if strings.Join(results, "|") != strings.Join(want, "|") {
+		t.Fatalf("expected results in call order %q, got %q", want, results)
+	}
+	if !<-overlapped {
+		t.Fatal("expected both slow calls to run concurrently")
+	}
+}
+
+type failingLLM struct {
+	*MockLLMClient
+	failures []error
+	calls    int
+}
+
+func (f *failingLLM) Generate(ctx context.Context, req *GenerateRequest) (*GenerateResponse, error) {
+	f.calls++
+	if len(f.failures) > 0 {
+		err := f.failures[0]
+		f.failures = f.failures[1:]
+		return nil, err
+	}
+	return f.MockLLMClient.Generate(ctx, req)
+}
+
+func TestWithRetryRetriesTransientErrors(t *testing.T) {
+	unavailable := NewAPIError("test", 503, errors.New("unavailable"))
+	llm := &failingLLM{MockLLMClient: NewMockLLMClient(), failures: []error{unavailable, unavailable}}
+	ag := New(WithProvider(llm), WithRetry(3, time.Millisecond))
+	if _, err := ag.Run(context.Background(), "hi"); err != nil {
+		t.Fatalf("expected retries to recover, got %v", err)
+	}
+	if llm.calls != 3 {
+		t.Fatalf("expected 3 calls, got %d", llm.calls)
+	}
+
+	llm = &failingLLM{MockLLMClient: NewMockLLMClient(), failures: []error{NewAPIError("test", 400, errors.New("bad request"))}}
+	ag = New(WithProvider(llm), WithRetry(3, time.Millisecond))
+	if _, err := ag.Run(context.Background(), "hi"); err == nil {
+		t.Fatal("expected non-retriable error to fail")
+	}
+	if llm.calls != 1 {
+		t.Fatalf("expected no retry for non-retriable error, got %d calls", llm.calls)
+	}
+}
+
+func TestWithRetryKeepsContextErrorWhenWaitIsAborted(t *testing.T) {
+	unavailable := NewAPIError("test", 503, errors.New("unavailable"))
+	llm := &failingLLM{MockLLMClient: NewMockLLMClient(), failures: []error{unavailable}}
+	ag := New(WithProvider(llm), WithRetry(3, time.Hour))
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	_, err := ag.Run(ctx, "hi")
+	var apiErr *APIError
+	if !errors.Is(err, context.DeadlineExceeded) || !errors.As(err, &apiErr) {
+		t.Fatalf("expected both the deadline and the provider error, got %v", err)
+	}
+}
+
+// flakyStreamLLM fails its first streams before sending anything.
+type flakyStreamLLM struct {
+	*MockLLMClient
+	failures []error
+	calls    int
+}
+
+func (f *flakyStreamLLM) GenerateStream(ctx context.Context, req *GenerateRequest) iter.Seq2[*GenerateResponse, error] {
+	return func(yield func(*GenerateResponse, error) bool) {
+		f.calls++
+		if len(f.failures) > 0 {
+			err := f.failures[0]
+			f.failures = f.failures[1:]
+			yield(nil, err)
+			return
+		}
+		if yield(&GenerateResponse{Message: message.NewMessage(message.RoleAssistant, "he")}, nil) {
+			yield(&GenerateResponse{Message: message.NewMessage(message.RoleAssistant, "llo")}, nil)
+		}
+	}
+}
+
+func TestWithRetryRetriesStreamsBeforeFirstChunk(t *testing.T) {
+	unavailable := NewAPIError("test", 503, errors.New("unavailable"))
+	llm := &flakyStreamLLM{MockLLMClient: NewMockLLMClient(), failures: []error{unavailable}}
+	ag := New(WithProvider(llm), WithRetry(3, time.Millisecond))
+	var last *message.Message
+	for msg, err := range ag.RunStream(context.Background(), "hi", nil) {
+		if err != nil {
+			t.Fatalf("expected the retried stream to succeed, got %v", err)
+		}
+		last = msg
+	}
+	if llm.calls != 2 || last == nil || last.Text() != "hello" {
+		t.Fatalf("expected one retry and the full reply, got %d calls and %+v", llm.calls, last)
+	}
+
+	llm = &flakyStreamLLM{MockLLMClient: NewMockLLMClient(), failures: []error{unavailable}}
+	ag = New(WithProvider(llm))
+	var streamErr error
+	for _, err := range ag.RunStream(context.Background(), "hi", nil) {
+		if err != nil {
+			streamErr = err
+		}
+	}
+	if !errors.Is(streamErr, unavailable) || llm.calls != 1 {
+		t.Fatalf("expected no retry without a policy, got %v after %d calls", streamErr, llm.calls)
+	}
+}
+
+func TestToolArgValidationFeedsErrorsToModel(t *testing.T) {
+	calls := []message.ToolCall{{ID: "c1", Name: "count", Args: map[string]any{"n": "three"}}}
+	counter := &tool.Tool{
+		Name:       "count",
+		Parameters: []tool.Parameter{{Name: "n", Type: "integer", Required: true}},
+		Handler: func(ctx context.Context, args map[string]any) (string, error) {
+			return fmt.Sprintf("counted %v", args["n"]), nil
+		},
+	}
+	toolResult := func(ag *Agent) string {
+		for _, msg := range ag.GetMessages() {
+			if msg.Role == message.RoleTool {
+				return msg.Text()
+			}
+		}
+		return ""
+	}
+
+	ag := New(WithProvider(&toolCallingLLM{MockLLMClient: NewMockLLMClient(), calls: calls}))
+	_ = ag.RegisterTool(counter)
+	if _, err := ag.Run(context.Background(), "go"); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	if got := toolResult(ag); !strings.Contains(got, "n must be of type integer, got string") {
+		t.Fatalf("expected the validation error as tool result, got %q", got)
+	}
+
+	raw := New(WithProvider(&toolCallingLLM{MockLLMClient: NewMockLLMClient(), calls: calls}), WithToolArgValidation(false))
+	_ = raw.RegisterTool(counter)
+	if _, err := raw.Run(context.Background(), "go"); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	if got := toolResult(raw); got != "counted three" || raw.Clone().tools.ArgValidation() {
+		t.Fatalf("expected raw arguments to reach the handler, got %q", got)
+	}
+}
+
+func TestToolTimeoutFeedsErrorToModel(t *testing.T) {
+	llm := &toolCallingLLM{MockLLMClient: NewMockLLMClient(), calls: []message.ToolCall{{ID: "c1", Name: "hang", Args: map[string]any{}}}}
+	ag := New(WithProvider(llm), WithToolTimeout(20*time.Millisecond))
+	release := make(chan struct{})
+	defer close(release)
+	_ = ag.RegisterTool(&tool.Tool{
+		Name: "hang",
+		Handler: func(ctx context.Context, args map[string]any) (string, error) {
+			<-release
+			return "never", nil
+		},
+	})
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := ag.Run(context.Background(), "go")
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("Run failed: %v", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Run blocked on a hanging tool")
+	}
+
+	var result string
+	for _, msg := range ag.GetMessages() {
+		if msg.Role == message.RoleTool {
+			result = msg.Text()
+		}
+	}
+	if !strings.Contains(result, tool.ErrTimeout.Error()) || ag.Clone().tools.DefaultTimeout() != 20*time.Millisecond {
+		t.Fatalf("expected the timeout error as tool result, got %q", result)
+	}
+}
+
+// failingMemoryStore recalls nothing and rejects every write.
+type failingMemoryStore struct {
+	*inmemory.InMemoryStore
+}
+
+func (failingMemoryStore) AddMemory(context.Context, *memory.Memory) error {
+	return errors.New("disk full")
+}
+
+func TestRunLogsMemoryStoreFailures(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewTextHandler(&buf, nil))
+	ag := New(
+		WithProvider(NewMockLLMClient()),
+		WithLogger(logger),
+		WithMemory(failingMemoryStore{inmemory.NewInMemoryStore()}),
+	)
+
+	if _, err := ag.Run(context.Background(), "hello"); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	if !strings.Contains(buf.String(), "memory store failed") || !strings.Contains(buf.String(), "disk full") {
+		t.Fatalf("expected the failed memory write to be logged, got %q", buf.String())
+	}
+}
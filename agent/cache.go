@@ -0,0 +1,31 @@
+package agent
+
+import (
+	"strings"
+
+	"github.com/sweetpotato0/ai-allin/message"
+)
+
+// WithPromptCaching marks the system prompt as a cacheable prefix so providers with
+// explicit prompt caching (such as Claude) can reuse it across requests. ttl is passed
+// to the provider as-is; empty uses the provider default. Further breakpoints can be
+// set on individual messages through message.Message.CacheControl.
+func WithPromptCaching(ttl string) Option {
+	return func(a *Agent) {
+		a.promptCache = &message.CacheControl{TTL: ttl}
+	}
+}
+
+// systemMessage builds the system prompt message, marked cacheable when enabled.
+func (a *Agent) systemMessage() *message.Message {
+	prompt := a.systemPrompt
+	if instructions := a.schemaInstructions(); instructions != "" {
+		prompt = strings.TrimSpace(prompt + "\n\n" + instructions)
+	}
+	msg := message.NewMessage(message.RoleSystem, prompt)
+	if a.promptCache != nil {
+		cc := *a.promptCache
+		msg.CacheControl = &cc
+	}
+	return msg
+}
@@ -0,0 +1,72 @@
+package agent
+
+import (
+	"context"
+
+	"github.com/sweetpotato0/ai-allin/message"
+)
+
+// CallOption overrides generation settings for a single RunWithOptions call.
+type CallOption func(*callOptions)
+
+type callOptions struct {
+	model       string
+	temperature *float64
+	maxTokens   int64
+}
+
+// WithCallTemperature overrides the sampling temperature for one call.
+func WithCallTemperature(temperature float64) CallOption {
+	return func(o *callOptions) {
+		o.temperature = &temperature
+	}
+}
+
+// WithCallMaxTokens overrides the completion token limit for one call.
+func WithCallMaxTokens(maxTokens int64) CallOption {
+	return func(o *callOptions) {
+		if maxTokens > 0 {
+			o.maxTokens = maxTokens
+		}
+	}
+}
+
+// WithCallModel overrides the model for one call.
+func WithCallModel(model string) CallOption {
+	return func(o *callOptions) {
+		o.model = model
+	}
+}
+
+type callOptionsKey struct{}
+
+// RunWithOptions runs the agent like Run with generation settings overridden for
+// this call only. The overrides travel on each GenerateRequest instead of
+// mutating the shared provider, so concurrent calls through agents that share an
+// LLMClient do not affect each other. Providers that ignore the request fields
+// keep their configured settings.
+func (a *Agent) RunWithOptions(ctx context.Context, input string, opts ...CallOption) (*message.Message, error) {
+	o := &callOptions{}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return a.Run(context.WithValue(ctx, callOptionsKey{}, o), input)
+}
+
+// applyCallOptions copies the per-call overrides carried by ctx onto req.
+func applyCallOptions(ctx context.Context, req *GenerateRequest) {
+	o, ok := ctx.Value(callOptionsKey{}).(*callOptions)
+	if !ok {
+		return
+	}
+	if o.model != "" {
+		req.Model = o.model
+	}
+	if o.temperature != nil {
+		temperature := *o.temperature
+		req.Temperature = &temperature
+	}
+	if o.maxTokens > 0 {
+		req.MaxTokens = o.maxTokens
+	}
+}
@@ -0,0 +1,64 @@
+package agent
+
+import (
+	"slices"
+	"time"
+
+	"github.com/sweetpotato0/ai-allin/message"
+)
+
+// CurrentTimeLayout formats the timestamp injected by WithCurrentTime.
+const CurrentTimeLayout = "Monday, 2006-01-02 15:04:05 MST (UTC-07:00)"
+
+type currentTime struct {
+	clock    func() time.Time
+	location *time.Location
+}
+
+// WithCurrentTime adds a system message with the current date and time to every
+// request sent to the model, so it can reason about "today". clock supplies the
+// time; nil uses time.Now. The timestamp is rendered in the clock's location
+// unless WithCurrentTimeLocation overrides it.
+func WithCurrentTime(clock func() time.Time) Option {
+	return func(a *Agent) {
+		if clock == nil {
+			clock = time.Now
+		}
+		if a.currentTime == nil {
+			a.currentTime = &currentTime{}
+		}
+		a.currentTime.clock = clock
+	}
+}
+
+// WithCurrentTimeLocation renders the timestamp injected by WithCurrentTime in loc.
+// It enables WithCurrentTime with time.Now if no clock was configured.
+func WithCurrentTimeLocation(loc *time.Location) Option {
+	return func(a *Agent) {
+		if a.currentTime == nil {
+			a.currentTime = &currentTime{clock: time.Now}
+		}
+		a.currentTime.location = loc
+	}
+}
+
+// requestMessages returns the context messages to send to the model. When
+// WithCurrentTime is enabled the timestamp is added after the leading system
+// messages of the request only, so it never accumulates in the context or the
+// session history and leaves the stored system prompt unchanged.
+func (a *Agent) requestMessages() []*message.Message {
+	msgs := a.ctx.GetMessages()
+	if a.currentTime == nil {
+		return msgs
+	}
+	now := a.currentTime.clock()
+	if a.currentTime.location != nil {
+		now = now.In(a.currentTime.location)
+	}
+	stamp := message.NewMessage(message.RoleSystem, "Current date and time: "+now.Format(CurrentTimeLayout))
+	at := 0
+	for at < len(msgs) && msgs[at].Role == message.RoleSystem {
+		at++
+	}
+	return slices.Insert(msgs, at, stamp)
+}
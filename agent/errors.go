@@ -0,0 +1,118 @@
+package agent
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// ErrContextLengthExceeded reports that a request did not fit in the model's
+// context window. Providers may wrap it; APIError also detects it from the
+// provider's error message.
+var ErrContextLengthExceeded = errors.New("context length exceeded")
+
+// contextLengthPhrases are fragments providers use when rejecting oversized prompts.
+var contextLengthPhrases = []string{
+	"context_length_exceeded",
+	"context length",
+	"context window",
+	"maximum context",
+	"prompt is too long",
+	"too many tokens",
+	"input is too long",
+	"exceeds the maximum number of tokens",
+}
+
+// APIError describes a failed call to an upstream LLM or embedding provider.
+type APIError struct {
+	Provider   string // Provider name, e.g. "openai"
+	StatusCode int    // HTTP status code returned by the provider (0 when unknown)
+	Err        error  // Underlying SDK error
+}
+
+// NewAPIError wraps err with provider and status information.
+func NewAPIError(provider string, statusCode int, err error) *APIError {
+	return &APIError{Provider: provider, StatusCode: statusCode, Err: err}
+}
+
+// Error implements the error interface.
+func (e *APIError) Error() string {
+	if e.StatusCode > 0 {
+		return fmt.Sprintf("%s API error (status %d): %v", e.Provider, e.StatusCode, e.Err)
+	}
+	return fmt.Sprintf("%s API error: %v", e.Provider, e.Err)
+}
+
+// Unwrap returns the underlying error.
+func (e *APIError) Unwrap() error {
+	return e.Err
+}
+
+// Retriable reports whether the failure is transient (timeouts, throttling, server errors).
+func (e *APIError) Retriable() bool {
+	switch {
+	case e.StatusCode == http.StatusRequestTimeout,
+		e.StatusCode == http.StatusConflict,
+		e.StatusCode == http.StatusTooManyRequests,
+		e.StatusCode >= http.StatusInternalServerError:
+		return true
+	case e.StatusCode == 0:
+		return isTimeout(e.Err)
+	default:
+		return false
+	}
+}
+
+// IsRetriable reports whether err is a transient provider failure worth retrying.
+func IsRetriable(err error) bool {
+	if err == nil {
+		return false
+	}
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		return apiErr.Retriable()
+	}
+	return isTimeout(err)
+}
+
+// ContextLengthExceeded reports whether the provider rejected the request
+// because the prompt exceeded the model's context window.
+func (e *APIError) ContextLengthExceeded() bool {
+	if errors.Is(e.Err, ErrContextLengthExceeded) {
+		return true
+	}
+	if e.Err == nil || (e.StatusCode != 0 && e.StatusCode != http.StatusBadRequest && e.StatusCode != http.StatusRequestEntityTooLarge) {
+		return false
+	}
+	return mentionsContextLength(e.Err.Error())
+}
+
+// IsContextLengthExceeded reports whether err means the prompt was too long for
+// the model. Callers can shrink the prompt and retry.
+func IsContextLengthExceeded(err error) bool {
+	if err == nil {
+		return false
+	}
+	if errors.Is(err, ErrContextLengthExceeded) {
+		return true
+	}
+	var apiErr *APIError
+	return errors.As(err, &apiErr) && apiErr.ContextLengthExceeded()
+}
+
+func mentionsContextLength(msg string) bool {
+	msg = strings.ToLower(msg)
+	for _, phrase := range contextLengthPhrases {
+		if strings.Contains(msg, phrase) {
+			return true
+		}
+	}
+	return false
+}
+
+func isTimeout(err error) bool {
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
@@ -0,0 +1,262 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"iter"
+	"sort"
+	"strings"
+
+	"github.com/sweetpotato0/ai-allin/memory"
+	"github.com/sweetpotato0/ai-allin/message"
+	"go.opentelemetry.io/otel/attribute"
+	oteltrace "go.opentelemetry.io/otel/trace"
+)
+
+// StreamEventType identifies the kind of event emitted by RunStreamEvents.
+type StreamEventType string
+
+const (
+	// StreamEventText carries an incremental piece of assistant text in Delta.
+	StreamEventText StreamEventType = "text_delta"
+	// StreamEventToolArgsDelta carries a partial tool call in ToolDelta as the model streams it.
+	StreamEventToolArgsDelta StreamEventType = "tool_args_delta"
+	// StreamEventToolCall carries a finalized tool call with parsed arguments.
+	StreamEventToolCall StreamEventType = "tool_call"
+	// StreamEventToolResult carries a tool call whose Response field holds the tool output.
+	StreamEventToolResult StreamEventType = "tool_result"
+	// StreamEventMessage carries the final assistant message of the run.
+	StreamEventMessage StreamEventType = "message"
+)
+
+// StreamEvent is a single typed update emitted by RunStreamEvents.
+type StreamEvent struct {
+	Type      StreamEventType
+	Delta     string            // Text fragment for StreamEventText
+	ToolDelta *ToolCallDelta    // Partial tool call for StreamEventToolArgsDelta
+	ToolCall  *message.ToolCall // Finalized call for StreamEventToolCall/StreamEventToolResult
+	Message   *message.Message  // Final assistant message for StreamEventMessage
+}
+
+// RunStreamEvents executes the agent and yields typed events as they happen:
+// text deltas, partial tool arguments, finalized tool calls, tool results and the
+// final assistant message. Tool calls are executed and fed back to the model until
+// it answers without tools or maxIterations is reached. Events are delivered in order.
+// Providers without streaming support emit the complete text as a single delta.
+// Agents with middlewares registered run through Run so the middlewares apply, and
+// emit only the complete text and the final message.
+func (a *Agent) RunStreamEvents(ctx context.Context, input string) iter.Seq2[*StreamEvent, error] {
+	return func(yield func(*StreamEvent, error) bool) {
+		if len(a.middlewares.List()) > 0 {
+			final, err := a.Run(ctx, input)
+			if err != nil {
+				yield(nil, err)
+				return
+			}
+			if text := final.Text(); text != "" {
+				if !yield(&StreamEvent{Type: StreamEventText, Delta: text}, nil) {
+					return
+				}
+			}
+			yield(&StreamEvent{Type: StreamEventMessage, Message: final}, nil)
+			return
+		}
+		a.usage.startRun()
+		if err := a.ensureToolProviders(ctx); err != nil {
+			yield(nil, err)
+			return
+		}
+		a.prepareTurn(ctx, input)
+		toolSchemas := a.toolSchemas(input)
+
+		corrected := false
+		for i := 0; i < a.maxIterations; i++ {
+			req := &GenerateRequest{
+				Messages: a.requestMessages(),
+				Tools:    toolSchemas,
+			}
+			applyCallOptions(ctx, req)
+			a.applyResponseFormat(req)
+			final, ok, err := a.streamTurn(ctx, req, yield)
+			if err != nil {
+				yield(nil, err)
+				return
+			}
+			if !ok {
+				return
+			}
+			if len(final.ToolCalls) == 0 {
+				retry, err := a.checkAnswer(final, &corrected)
+				if err != nil {
+					yield(nil, err)
+					return
+				}
+				if retry {
+					i-- // the correction turn does not use up an iteration
+					continue
+				}
+				if err := a.postProcess(final); err != nil {
+					yield(nil, err)
+					return
+				}
+			}
+			a.AddMessage(final)
+
+			if len(final.ToolCalls) == 0 {
+				a.rememberTurn(ctx, input, final)
+				yield(&StreamEvent{Type: StreamEventMessage, Message: final}, nil)
+				return
+			}
+
+			for idx := range final.ToolCalls {
+				call := final.ToolCalls[idx]
+				if !yield(&StreamEvent{Type: StreamEventToolCall, ToolCall: &call}, nil) {
+					return
+				}
+			}
+			results := a.executeToolCalls(ctx, final.ToolCalls)
+			for idx, call := range final.ToolCalls {
+				result := results[idx]
+				a.AddMessage(message.NewToolResponseMessage(call.ID, result))
+				call.Response = result
+				if !yield(&StreamEvent{Type: StreamEventToolResult, ToolCall: &call}, nil) {
+					return
+				}
+			}
+		}
+		yield(nil, fmt.Errorf("max iterations (%d) reached", a.maxIterations))
+	}
+}
+
+// streamTurn performs one LLM call, emitting delta events, and returns the final message.
+// ok is false when the consumer stopped iteration.
+func (a *Agent) streamTurn(ctx context.Context, req *GenerateRequest, yield func(*StreamEvent, error) bool) (final *message.Message, ok bool, err error) {
+	if err := a.checkSequence(req); err != nil {
+		return nil, false, err
+	}
+	a.logPrompt(ctx, req)
+	streamProvider, streaming := a.llm.(StreamLLMClient)
+	if !streaming {
+		resp, err := a.generate(ctx, req)
+		if err != nil {
+			return nil, false, fmt.Errorf("LLM generation failed: %w", err)
+		}
+		if resp == nil || resp.Message == nil {
+			return nil, false, fmt.Errorf("LLM returned empty response")
+		}
+		if text := resp.Message.Text(); text != "" {
+			if !yield(&StreamEvent{Type: StreamEventText, Delta: text}, nil) {
+				return nil, false, nil
+			}
+		}
+		a.recordResponse(resp)
+		return resp.Message, true, nil
+	}
+
+	seq := a.generateStream(ctx, streamProvider, req)
+	var (
+		text      strings.Builder
+		toolCalls toolCallAccumulator
+	)
+	for resp, err := range seq {
+		if err != nil {
+			return nil, false, err
+		}
+		if resp == nil || resp.Message == nil {
+			continue
+		}
+		if resp.Message.Completed {
+			a.recordResponse(resp)
+			final = resp.Message
+			continue
+		}
+		if delta := resp.Message.Text(); delta != "" {
+			text.WriteString(delta)
+			if !yield(&StreamEvent{Type: StreamEventText, Delta: delta}, nil) {
+				return nil, false, nil
+			}
+		}
+		toolCalls.add(resp.ToolCallDeltas)
+		for idx := range resp.ToolCallDeltas {
+			delta := resp.ToolCallDeltas[idx]
+			if !yield(&StreamEvent{Type: StreamEventToolArgsDelta, ToolDelta: &delta}, nil) {
+				return nil, false, nil
+			}
+		}
+	}
+	final, err = assembleStreamed(final, text.String(), &toolCalls)
+	if err != nil {
+		return nil, false, err
+	}
+	return final, true, nil
+}
+
+// prepareTurn records the user input and injects relevant memories and retrieved
+// chunks into the context. Hits and failures are recorded on the span in ctx.
+func (a *Agent) prepareTurn(ctx context.Context, input string) {
+	a.AddMessage(message.NewMessage(message.RoleUser, input))
+	span := oteltrace.SpanFromContext(ctx)
+	defer func() {
+		if hits := a.injectRetrieval(ctx, input); hits > 0 {
+			span.AddEvent("retrieval_hits", oteltrace.WithAttributes(attribute.Int("count", hits)))
+		}
+	}()
+	if !a.enableMemory || a.memory == nil {
+		return
+	}
+	memories, err := a.memory.SearchMemory(ctx, input)
+	if err != nil {
+		if a.logger != nil {
+			a.logger.Warn("memory search failed", "error", err)
+		}
+		span.AddEvent("memory_search_failed", oteltrace.WithAttributes(attribute.String("error", err.Error())))
+		return
+	}
+	if len(memories) == 0 {
+		return
+	}
+	if a.logger != nil {
+		a.logger.Debug("memory hits found", "count", len(memories))
+	}
+	span.AddEvent("memory_hits", oteltrace.WithAttributes(attribute.Int("count", len(memories))))
+	memoryContext := "Relevant memories:\n"
+	for _, mem := range memories {
+		memoryContext += fmt.Sprintf("- %v\n", mem)
+	}
+	a.ctx.AddMessage(message.NewMessage(message.RoleSystem, memoryContext))
+}
+
+// rememberTurn stores the completed exchange in memory when enabled.
+func (a *Agent) rememberTurn(ctx context.Context, input string, reply *message.Message) {
+	if !a.enableMemory || a.memory == nil || reply == nil {
+		return
+	}
+	mem := &memory.Memory{
+		ID:       memory.GenerateMemoryID(),
+		Content:  fmt.Sprintf("User: %s\nAssistant: %s", input, reply.Text()),
+		Metadata: map[string]any{"input": input, "response": reply.Text()},
+	}
+	if err := a.memory.AddMemory(ctx, mem); err != nil && a.logger != nil {
+		a.logger.Warn("memory store failed", "error", err)
+	}
+}
+
+// toolSchemas returns the schemas of the tools advertised for input, applying the tool filter.
+func (a *Agent) toolSchemas(input string) []map[string]any {
+	if !a.enableTools {
+		return nil
+	}
+	if a.toolFilter == nil {
+		return a.tools.ToJSONSchemas()
+	}
+	tools := a.tools.List()
+	sort.Slice(tools, func(i, j int) bool { return tools[i].Name < tools[j].Name })
+	selected := a.toolFilter(input, tools)
+	names := make([]string, 0, len(selected))
+	for _, t := range selected {
+		if t != nil {
+			names = append(names, t.Name)
+		}
+	}
+	return a.tools.ToJSONSchemasFor(names...)
+}
@@ -1,16 +1,128 @@
 package agent
 
-import "github.com/sweetpotato0/ai-allin/message"
+import (
+	"errors"
+	"fmt"
+	"slices"
+
+	"github.com/sweetpotato0/ai-allin/message"
+)
+
+// ErrUnknownRole is returned by providers for a message whose role they cannot
+// represent when no fallback role is configured.
+var ErrUnknownRole = errors.New("unknown message role")
+
+// ResolveRole returns role when the provider supports it. Otherwise it returns
+// fallback when that is supported, or an error wrapping ErrUnknownRole so that
+// messages are never dropped silently.
+func ResolveRole(role, fallback message.Role, supported ...message.Role) (message.Role, error) {
+	if slices.Contains(supported, role) {
+		return role, nil
+	}
+	if fallback != "" && slices.Contains(supported, fallback) {
+		return fallback, nil
+	}
+	return "", fmt.Errorf("%w: %q", ErrUnknownRole, role)
+}
+
+// ErrImagesUnsupported is returned by providers for image content parts they
+// cannot send, so images are never dropped silently.
+var ErrImagesUnsupported = errors.New("image input not supported")
+
+// RejectImages returns an error wrapping ErrImagesUnsupported when any message
+// carries image parts. Providers without vision support call it before sending.
+func RejectImages(provider string, msgs []*message.Message) error {
+	for _, msg := range msgs {
+		if msg.HasImages() {
+			return fmt.Errorf("%w by provider %s", ErrImagesUnsupported, provider)
+		}
+	}
+	return nil
+}
 
 // GenerateRequest bundles inputs for a LLM invocation.
 type GenerateRequest struct {
 	Messages []*message.Message
 	Tools    []map[string]any
+	// ExtraParams carries provider-specific request parameters (e.g. "top_p",
+	// "presence_penalty", "top_k"). They override the provider config's ExtraParams.
+	ExtraParams map[string]any
+
+	// Per-call overrides of the provider configuration; zero values keep the
+	// configured setting. Set them through Agent.RunWithOptions.
+	Model       string
+	Temperature *float64
+	MaxTokens   int64
+
+	// ResponseFormat constrains the answer to JSON matching a schema. Only
+	// providers implementing StructuredOutputClient receive it.
+	ResponseFormat *ResponseFormat
+}
+
+// ResponseFormat describes the JSON Schema an answer must conform to.
+type ResponseFormat struct {
+	Name   string         // Identifier some providers require, e.g. "response"
+	Schema map[string]any // JSON Schema of the answer
+}
+
+// MergeParams returns base overlaid with override. The inputs are not modified.
+func MergeParams(base, override map[string]any) map[string]any {
+	if len(base) == 0 && len(override) == 0 {
+		return nil
+	}
+	out := make(map[string]any, len(base)+len(override))
+	for k, v := range base {
+		out[k] = v
+	}
+	for k, v := range override {
+		out[k] = v
+	}
+	return out
 }
 
 // GenerateResponse captures the LLM reply for calls.
 type GenerateResponse struct {
-	Message *message.Message
+	Message        *message.Message
+	ToolCallDeltas []ToolCallDelta // Partial tool calls carried by streaming chunks
+	// Provider and Model identify the backend that served the response. Wrappers
+	// that route between clients should preserve or set them.
+	Provider string
+	Model    string
+	// Usage reports the tokens consumed by the call. Streaming providers set it on
+	// the completed response.
+	Usage Usage
+}
+
+// Metadata keys under which the agent records the serving backend on response messages.
+const (
+	MetadataProvider = "provider"
+	MetadataModel    = "model"
+)
+
+// annotate copies Provider and Model onto the response message metadata.
+func (r *GenerateResponse) annotate() {
+	if r == nil || r.Message == nil || (r.Provider == "" && r.Model == "") {
+		return
+	}
+	if r.Message.Metadata == nil {
+		r.Message.Metadata = make(map[string]any)
+	}
+	if r.Provider != "" {
+		r.Message.Metadata[MetadataProvider] = r.Provider
+	}
+	if r.Model != "" {
+		r.Message.Metadata[MetadataModel] = r.Model
+	}
+}
+
+// ToolCallDelta is a fragment of a tool call streamed by the provider.
+// Fragments sharing an Index belong to the same call; Arguments holds the next
+// piece of the JSON argument string.
+type ToolCallDelta struct {
+	Index     int
+	ID        string
+	Name      string
+	Arguments string
 }
 
 // StreamResponse returns both the accumulated assistant message and a token iterator.
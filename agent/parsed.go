@@ -0,0 +1,47 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/sweetpotato0/ai-allin/parser"
+)
+
+// RunParsed runs the agent with input and parses the final answer with p. The
+// parser's format instructions are appended to the input. When parsing fails the
+// agent is re-prompted once with the parse error; a second failure is returned.
+func (a *Agent) RunParsed(ctx context.Context, input string, p parser.OutputParser) (any, error) {
+	if p == nil {
+		return nil, fmt.Errorf("output parser cannot be nil")
+	}
+	instructions := p.FormatInstructions()
+	if instructions != "" {
+		input = input + "\n\n" + instructions
+	}
+
+	resp, err := a.Run(ctx, input)
+	if err != nil {
+		return nil, err
+	}
+	value, parseErr := p.Parse(resp.Text())
+	if parseErr == nil {
+		return value, nil
+	}
+	if a.logger != nil {
+		a.logger.Warn("response parsing failed, re-prompting", "error", parseErr)
+	}
+
+	retry := fmt.Sprintf("Your previous answer could not be parsed: %v\nAnswer again using the required format.", parseErr)
+	if instructions != "" {
+		retry += "\n\n" + instructions
+	}
+	resp, err = a.Run(ctx, retry)
+	if err != nil {
+		return nil, err
+	}
+	value, parseErr = p.Parse(resp.Text())
+	if parseErr != nil {
+		return nil, fmt.Errorf("failed to parse response: %w", parseErr)
+	}
+	return value, nil
+}
@@ -0,0 +1,32 @@
+package agent
+
+import (
+	"fmt"
+
+	"github.com/sweetpotato0/ai-allin/message"
+)
+
+// ResponsePostProcessor transforms the final assistant message of a run in place.
+type ResponsePostProcessor func(*message.Message) error
+
+// WithResponsePostProcessor registers fn to run on the final assistant message of
+// Run, RunStream and RunStreamEvents before it is stored in history and returned.
+// Processors run in registration order, inside the middleware chain; an error fails
+// the run. Streamed deltas are delivered unprocessed.
+func WithResponsePostProcessor(fn ResponsePostProcessor) Option {
+	return func(a *Agent) {
+		if fn != nil {
+			a.postProcessors = append(a.postProcessors, fn)
+		}
+	}
+}
+
+// postProcess applies the registered post-processors to msg.
+func (a *Agent) postProcess(msg *message.Message) error {
+	for _, fn := range a.postProcessors {
+		if err := fn(msg); err != nil {
+			return fmt.Errorf("response post-processor failed: %w", err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,80 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"regexp"
+)
+
+// Redactor rewrites text before it is written to prompt logs.
+type Redactor func(string) string
+
+// RedactPatterns returns a Redactor that replaces every match of the given patterns with "[REDACTED]".
+func RedactPatterns(patterns ...*regexp.Regexp) Redactor {
+	return func(text string) string {
+		for _, re := range patterns {
+			if re != nil {
+				text = re.ReplaceAllString(text, "[REDACTED]")
+			}
+		}
+		return text
+	}
+}
+
+type promptLogging struct {
+	level  slog.Level
+	redact Redactor
+}
+
+// WithPromptLogging logs the full GenerateRequest (every message plus tool schemas)
+// sent to the provider at the given level. Message content and tool arguments pass
+// through redactor first; a nil redactor logs them unchanged.
+func WithPromptLogging(level slog.Level, redactor Redactor) Option {
+	return func(a *Agent) {
+		a.promptLog = &promptLogging{level: level, redact: redactor}
+	}
+}
+
+// logPrompt writes req to the agent logger when prompt logging is enabled.
+func (a *Agent) logPrompt(ctx context.Context, req *GenerateRequest) {
+	if a.promptLog == nil || a.logger == nil || req == nil || !a.logger.Enabled(ctx, a.promptLog.level) {
+		return
+	}
+	redact := a.promptLog.redact
+	if redact == nil {
+		redact = func(s string) string { return s }
+	}
+
+	messages := make([]map[string]any, 0, len(req.Messages))
+	for _, msg := range req.Messages {
+		if msg == nil {
+			continue
+		}
+		entry := map[string]any{
+			"role":    string(msg.Role),
+			"content": redact(msg.Text()),
+		}
+		if msg.ToolID != "" {
+			entry["tool_id"] = msg.ToolID
+		}
+		if len(msg.ToolCalls) > 0 {
+			calls := make([]map[string]any, 0, len(msg.ToolCalls))
+			for _, call := range msg.ToolCalls {
+				args, _ := json.Marshal(call.Args)
+				calls = append(calls, map[string]any{
+					"id":   call.ID,
+					"name": call.Name,
+					"args": redact(string(args)),
+				})
+			}
+			entry["tool_calls"] = calls
+		}
+		messages = append(messages, entry)
+	}
+
+	a.logger.Log(ctx, a.promptLog.level, "llm prompt",
+		"messages", messages,
+		"tools", req.Tools,
+	)
+}
@@ -0,0 +1,52 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/sweetpotato0/ai-allin/message"
+	"github.com/sweetpotato0/ai-allin/rag/reranker"
+)
+
+// Retriever finds knowledge-base chunks relevant to a query.
+// *retriever.Retriever from rag/retriever satisfies this interface.
+type Retriever interface {
+	Search(ctx context.Context, query string) ([]reranker.Result, error)
+}
+
+// WithRetriever enables retrieval-augmented runs: before each turn the agent searches
+// the retriever with the user input and injects the matching chunks as a context message.
+func WithRetriever(r Retriever) Option {
+	return func(a *Agent) {
+		a.retriever = r
+	}
+}
+
+// injectRetrieval searches the configured retriever and adds the results to the
+// conversation. It returns the number of injected chunks.
+func (a *Agent) injectRetrieval(ctx context.Context, input string) int {
+	if a.retriever == nil {
+		return 0
+	}
+	results, err := a.retriever.Search(ctx, input)
+	if err != nil {
+		if a.logger != nil {
+			a.logger.Warn("retrieval failed", "error", err)
+		}
+		return 0
+	}
+	if len(results) == 0 {
+		return 0
+	}
+	var sb strings.Builder
+	sb.WriteString("Relevant context:\n")
+	for _, res := range results {
+		fmt.Fprintf(&sb, "- [Doc:%s] %s\n", res.Chunk.DocumentID, strings.TrimSpace(res.Chunk.Content))
+	}
+	a.ctx.AddMessage(message.NewMessage(message.RoleSystem, sb.String()))
+	if a.logger != nil {
+		a.logger.Debug("retrieval hits injected", "count", len(results))
+	}
+	return len(results)
+}
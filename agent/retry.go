@@ -0,0 +1,162 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"iter"
+	"math/rand/v2"
+	"time"
+)
+
+// RetryPolicy controls how transient failures are retried.
+type RetryPolicy struct {
+	MaxAttempts    int           // Total attempts including the first one
+	InitialBackoff time.Duration // Delay before the first retry
+	MaxBackoff     time.Duration // Upper bound for a single delay
+	Multiplier     float64       // Growth factor applied after each retry
+	Jitter         float64       // Fraction of each delay randomised by Wait, e.g. 0.2 for ±20%
+}
+
+// DefaultRetryPolicy returns a policy with three attempts and exponential backoff.
+func DefaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{
+		MaxAttempts:    3,
+		InitialBackoff: 500 * time.Millisecond,
+		MaxBackoff:     5 * time.Second,
+		Multiplier:     2,
+	}
+}
+
+// Backoff returns the delay to wait before the given retry (1-based).
+func (p RetryPolicy) Backoff(retry int) time.Duration {
+	if retry <= 0 || p.InitialBackoff <= 0 {
+		return 0
+	}
+	multiplier := p.Multiplier
+	if multiplier < 1 {
+		multiplier = 1
+	}
+	delay := float64(p.InitialBackoff)
+	for i := 1; i < retry; i++ {
+		delay *= multiplier
+		if p.MaxBackoff > 0 && delay >= float64(p.MaxBackoff) {
+			return p.MaxBackoff
+		}
+	}
+	if p.MaxBackoff > 0 && time.Duration(delay) > p.MaxBackoff {
+		return p.MaxBackoff
+	}
+	return time.Duration(delay)
+}
+
+// Wait blocks for the backoff of the given retry, spread by Jitter, or until ctx is done.
+func (p RetryPolicy) Wait(ctx context.Context, retry int) error {
+	d := p.Backoff(retry)
+	if p.Jitter > 0 && d > 0 {
+		spread := min(p.Jitter, 1) * float64(d)
+		d += time.Duration((rand.Float64()*2 - 1) * spread)
+	}
+	if d <= 0 {
+		return ctx.Err()
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// WithRetry retries LLM calls that fail with a transient error (see IsRetriable)
+// up to attempts times in total, waiting backoff before the first retry and
+// doubling it afterwards with ±20% jitter. Other errors fail immediately.
+// Streaming calls are only retried while no chunk has been received yet.
+func WithRetry(attempts int, backoff time.Duration) Option {
+	return WithRetryPolicy(RetryPolicy{
+		MaxAttempts:    attempts,
+		InitialBackoff: backoff,
+		MaxBackoff:     DefaultRetryPolicy().MaxBackoff,
+		Multiplier:     2,
+		Jitter:         0.2,
+	})
+}
+
+// WithRetryPolicy retries transient LLM call failures according to policy.
+func WithRetryPolicy(policy RetryPolicy) Option {
+	return func(a *Agent) {
+		if policy.MaxAttempts > 1 {
+			a.retryPolicy = &policy
+		} else {
+			a.retryPolicy = nil
+		}
+	}
+}
+
+// generate calls the provider, retrying transient failures when a retry policy is set.
+func (a *Agent) generate(ctx context.Context, req *GenerateRequest) (*GenerateResponse, error) {
+	resp, err := a.llm.Generate(ctx, req)
+	if err == nil || a.retryPolicy == nil {
+		return resp, err
+	}
+	policy := *a.retryPolicy
+	for attempt := 2; attempt <= policy.MaxAttempts && IsRetriable(err); attempt++ {
+		if a.logger != nil {
+			a.logger.Warn("retrying llm call", "attempt", attempt, "error", err)
+		}
+		if waitErr := policy.Wait(ctx, attempt-1); waitErr != nil {
+			return nil, fmt.Errorf("retry aborted: %w", errors.Join(waitErr, err))
+		}
+		resp, err = a.llm.Generate(ctx, req)
+		if err == nil {
+			return resp, nil
+		}
+	}
+	return resp, err
+}
+
+// generateStream opens a provider stream, retrying transient failures that occur
+// before the first chunk arrives when a retry policy is set. Once a chunk has been
+// delivered errors pass through unchanged, since the caller has already seen output.
+func (a *Agent) generateStream(ctx context.Context, provider StreamLLMClient, req *GenerateRequest) iter.Seq2[*GenerateResponse, error] {
+	return func(yield func(*GenerateResponse, error) bool) {
+		for attempt := 1; ; attempt++ {
+			seq := provider.GenerateStream(ctx, req)
+			if seq == nil {
+				yield(nil, fmt.Errorf("LLM streaming returned empty sequence"))
+				return
+			}
+			var (
+				started bool
+				failed  error
+			)
+			for resp, err := range seq {
+				if err != nil && !started {
+					failed = err
+					break
+				}
+				started = true
+				if !yield(resp, err) || err != nil {
+					return
+				}
+			}
+			if failed == nil {
+				return
+			}
+			policy := a.retryPolicy
+			if policy == nil || attempt >= policy.MaxAttempts || !IsRetriable(failed) {
+				yield(nil, failed)
+				return
+			}
+			if a.logger != nil {
+				a.logger.Warn("retrying llm stream", "attempt", attempt+1, "error", failed)
+			}
+			if waitErr := policy.Wait(ctx, attempt); waitErr != nil {
+				yield(nil, fmt.Errorf("retry aborted: %w", errors.Join(waitErr, failed)))
+				return
+			}
+		}
+	}
+}
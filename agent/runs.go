@@ -0,0 +1,81 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/sweetpotato0/ai-allin/message"
+)
+
+// runRegistry tracks cancel functions of in-flight runs keyed by run ID.
+type runRegistry struct {
+	mu   sync.Mutex
+	runs map[string]context.CancelFunc
+}
+
+func (r *runRegistry) add(id string, cancel context.CancelFunc) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.runs == nil {
+		r.runs = make(map[string]context.CancelFunc)
+	}
+	if _, exists := r.runs[id]; exists {
+		return fmt.Errorf("run %s is already in progress", id)
+	}
+	r.runs[id] = cancel
+	return nil
+}
+
+func (r *runRegistry) remove(id string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.runs, id)
+}
+
+func (r *runRegistry) cancel(id string) bool {
+	r.mu.Lock()
+	cancel, ok := r.runs[id]
+	r.mu.Unlock()
+	if ok {
+		cancel()
+	}
+	return ok
+}
+
+// RunWithID executes Run under a context that CancelRun(id) can cancel.
+// The ID must be unique among the agent's in-flight runs.
+func (a *Agent) RunWithID(ctx context.Context, id string, input string) (*message.Message, error) {
+	if id == "" {
+		return nil, fmt.Errorf("run ID cannot be empty")
+	}
+	runCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	if err := a.runs.add(id, cancel); err != nil {
+		return nil, err
+	}
+	defer a.runs.remove(id)
+	return a.Run(runCtx, input)
+}
+
+// CancelRun cancels the in-flight run started with RunWithID.
+// It reports whether a run with that ID was found.
+func (a *Agent) CancelRun(id string) bool {
+	if a.logger != nil {
+		a.logger.Info("cancelling run", "run_id", id)
+	}
+	return a.runs.cancel(id)
+}
+
+// ActiveRuns returns the IDs of in-flight runs started with RunWithID, sorted.
+func (a *Agent) ActiveRuns() []string {
+	a.runs.mu.Lock()
+	defer a.runs.mu.Unlock()
+	ids := make([]string, 0, len(a.runs.runs))
+	for id := range a.runs.runs {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
@@ -0,0 +1,98 @@
+package agent
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/sweetpotato0/ai-allin/message"
+	"github.com/sweetpotato0/ai-allin/parser"
+)
+
+// ErrResponseSchema is returned by Run, RunStream and RunStreamEvents when the
+// final answer does not match the schema set with WithResponseSchema, even after
+// a correction prompt.
+var ErrResponseSchema = errors.New("response does not match schema")
+
+// StructuredOutputClient is implemented by providers that can constrain answers
+// to a JSON Schema natively through GenerateRequest.ResponseFormat.
+type StructuredOutputClient interface {
+	LLMClient
+	// SupportsResponseFormat reports whether ResponseFormat is honoured.
+	SupportsResponseFormat() bool
+}
+
+// WithResponseSchema makes the agent answer with JSON matching schema. Providers
+// implementing StructuredOutputClient receive the schema on every request; for
+// other providers it is described in the system prompt instead. Every run checks
+// the final answer against the schema's top-level "type" and "required" keywords
+// and asks the model to correct an invalid answer once before failing with
+// ErrResponseSchema. The correction turn does not count against WithMaxIterations.
+// Streaming runs have already delivered the invalid answer's chunks when the
+// correction starts; only the corrected answer is yielded as the final message.
+func WithResponseSchema(schema map[string]any) Option {
+	return func(a *Agent) {
+		a.responseSchema = schema
+	}
+}
+
+// nativeResponseFormat reports whether the provider enforces the schema itself.
+func (a *Agent) nativeResponseFormat() bool {
+	client, ok := a.llm.(StructuredOutputClient)
+	return ok && client.SupportsResponseFormat()
+}
+
+// applyResponseFormat sets the schema constraint on req for native providers.
+func (a *Agent) applyResponseFormat(req *GenerateRequest) {
+	if a.responseSchema == nil || !a.nativeResponseFormat() {
+		return
+	}
+	req.ResponseFormat = &ResponseFormat{Name: "response", Schema: a.responseSchema}
+}
+
+// schemaInstructions describes the expected answer format in the system prompt
+// when the provider cannot enforce the schema.
+func (a *Agent) schemaInstructions() string {
+	if a.responseSchema == nil || a.nativeResponseFormat() {
+		return ""
+	}
+	schema, err := json.Marshal(a.responseSchema)
+	if err != nil {
+		return ""
+	}
+	return fmt.Sprintf("Respond with a single valid JSON value matching this JSON Schema and nothing else:\n%s", schema)
+}
+
+// checkResponseSchema validates a final answer against the response schema.
+func (a *Agent) checkResponseSchema(msg *message.Message) error {
+	if a.responseSchema == nil {
+		return nil
+	}
+	value, err := parser.NewJSONParser().Parse(msg.Text())
+	if err != nil {
+		return err
+	}
+	return parser.ValidateSchema(value, a.responseSchema)
+}
+
+// checkAnswer validates a final answer against the response schema. The first
+// invalid answer is kept in the context followed by a correction prompt and retry
+// is true, so the caller asks the model again. A second invalid answer fails with
+// ErrResponseSchema. corrected tracks whether the correction was already used.
+func (a *Agent) checkAnswer(msg *message.Message, corrected *bool) (retry bool, err error) {
+	err = a.checkResponseSchema(msg)
+	if err == nil {
+		return false, nil
+	}
+	a.AddMessage(msg)
+	if *corrected {
+		return false, fmt.Errorf("%w: %v", ErrResponseSchema, err)
+	}
+	*corrected = true
+	if a.logger != nil {
+		a.logger.Warn("response does not match schema, re-prompting", "error", err)
+	}
+	a.AddMessage(message.NewMessage(message.RoleUser,
+		fmt.Sprintf("Your previous answer did not match the required JSON Schema: %v\nAnswer again with JSON only.", err)))
+	return true, nil
+}
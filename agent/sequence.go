@@ -0,0 +1,122 @@
+package agent
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/sweetpotato0/ai-allin/message"
+)
+
+// ErrInvalidSequence is matched by every *SequenceError.
+var ErrInvalidSequence = errors.New("invalid message sequence")
+
+// SequenceError reports the first message that breaks a SequenceRules constraint.
+type SequenceError struct {
+	Index  int
+	Reason string
+}
+
+func (e *SequenceError) Error() string {
+	return fmt.Sprintf("invalid message sequence at index %d: %s", e.Index, e.Reason)
+}
+
+func (e *SequenceError) Unwrap() error {
+	return ErrInvalidSequence
+}
+
+// SequenceRules describes the message ordering a provider expects.
+// System messages are ignored by every rule.
+type SequenceRules struct {
+	// UserFirst requires the first non-system message to come from the user.
+	UserFirst bool
+	// Alternate forbids two consecutive user-side (user or tool) or assistant
+	// messages. Consecutive tool results are allowed.
+	Alternate bool
+	// Repair merges consecutive same-role user and assistant messages before validating.
+	Repair bool
+}
+
+// WithSequenceValidation checks the history against rules before every LLM call,
+// failing the call with a *SequenceError instead of sending a malformed request.
+func WithSequenceValidation(rules SequenceRules) Option {
+	return func(a *Agent) {
+		a.sequenceRules = &rules
+	}
+}
+
+// ValidateSequence checks msgs against rules and returns a *SequenceError for the
+// first violation.
+func ValidateSequence(msgs []*message.Message, rules SequenceRules) error {
+	var prev *message.Message
+	for i, msg := range msgs {
+		if msg == nil || msg.Role == message.RoleSystem {
+			continue
+		}
+		if prev == nil {
+			if rules.UserFirst && msg.Role != message.RoleUser {
+				return &SequenceError{Index: i, Reason: fmt.Sprintf("first message must be from the user, got %q", msg.Role)}
+			}
+			prev = msg
+			continue
+		}
+		if rules.Alternate && sameSide(prev.Role, msg.Role) && !(prev.Role == message.RoleTool && msg.Role == message.RoleTool) {
+			return &SequenceError{Index: i, Reason: fmt.Sprintf("%q message follows %q message", msg.Role, prev.Role)}
+		}
+		prev = msg
+	}
+	return nil
+}
+
+// RepairSequence returns msgs with consecutive user or assistant messages of the
+// same role merged into one; text is joined with a blank line and tool calls are
+// concatenated. The input messages are not modified.
+func RepairSequence(msgs []*message.Message) []*message.Message {
+	repaired := make([]*message.Message, 0, len(msgs))
+	for _, msg := range msgs {
+		if msg == nil {
+			continue
+		}
+		if n := len(repaired); n > 0 && mergeable(repaired[n-1], msg) {
+			repaired[n-1] = mergeMessages(repaired[n-1], msg)
+			continue
+		}
+		repaired = append(repaired, msg)
+	}
+	return repaired
+}
+
+// checkSequence applies the configured rules to req, repairing its messages when enabled.
+func (a *Agent) checkSequence(req *GenerateRequest) error {
+	if a.sequenceRules == nil {
+		return nil
+	}
+	if a.sequenceRules.Repair {
+		req.Messages = RepairSequence(req.Messages)
+	}
+	return ValidateSequence(req.Messages, *a.sequenceRules)
+}
+
+func sameSide(a, b message.Role) bool {
+	userSide := func(r message.Role) bool { return r == message.RoleUser || r == message.RoleTool }
+	if userSide(a) {
+		return userSide(b)
+	}
+	return a == b
+}
+
+func mergeable(prev, next *message.Message) bool {
+	if prev.Role != next.Role {
+		return false
+	}
+	return prev.Role == message.RoleUser || prev.Role == message.RoleAssistant
+}
+
+func mergeMessages(prev, next *message.Message) *message.Message {
+	merged := message.Clone(prev)
+	if merged.Text() != "" && next.Text() != "" {
+		merged.AppendText("\n\n")
+	}
+	merged.Content.Parts = append(merged.Content.Parts, next.Content.Parts...)
+	merged.ToolCalls = append(merged.ToolCalls, message.Clone(next).ToolCalls...)
+	return merged
+}
@@ -2,11 +2,14 @@ package agent
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"strings"
+	"sync"
 
 	"iter"
 
-	"github.com/sweetpotato0/ai-allin/memory"
 	"github.com/sweetpotato0/ai-allin/message"
 )
 
@@ -20,144 +23,408 @@ type StreamLLMClient interface {
 	GenerateStream(ctx context.Context, req *GenerateRequest) iter.Seq2[*GenerateResponse, error]
 }
 
+// SupportsStreaming reports whether the configured provider implements StreamLLMClient.
+func (a *Agent) SupportsStreaming() bool {
+	_, ok := a.llm.(StreamLLMClient)
+	return ok
+}
+
+// streamProvider returns the provider's streaming interface. Streaming is skipped
+// while middlewares are registered: they wrap a whole Run, so the streaming paths
+// fall back to Run rather than bypass them.
+func (a *Agent) streamProvider() (StreamLLMClient, bool) {
+	if len(a.middlewares.List()) > 0 {
+		return nil, false
+	}
+	sp, ok := a.llm.(StreamLLMClient)
+	return sp, ok
+}
+
 // RunStream executes the agent with streaming output
-// It calls the callback function for each token received from the LLM
+// It calls the callback function for each token received from the LLM.
+// The callback runs synchronously inside the stream loop, so chunks are delivered
+// strictly in the order the provider produced them and a slow callback applies
+// backpressure: the next chunk is not pulled until the callback returns. Wrap the
+// callback with NewBufferedCallback to decouple a slow consumer with a bounded buffer.
+// Tool calls are handled within the same call: the assistant tool call message and
+// each tool response message are yielded, the results are fed back to the model and
+// streaming continues until it answers without tools or maxIterations is reached.
+// The final assistant message is always yielded last.
+// Providers without GenerateStream, and agents with middlewares registered, fall
+// back to Run: the callback receives the complete message exactly once and the
+// same message is yielded.
 func (a *Agent) RunStream(ctx context.Context, input string, callback StreamCallback) iter.Seq2[*message.Message, error] {
 	return func(yield func(*message.Message, error) bool) {
+		a.usage.startRun()
 		if err := a.ensureToolProviders(ctx); err != nil {
 			yield(nil, err)
 			return
 		}
 
 		// Check if LLM client supports streaming
-		streamProvider, ok := a.llm.(StreamLLMClient)
+		streamProvider, ok := a.streamProvider()
 		if !ok {
-			// Fallback to regular Run if streaming not supported
+			// Fallback to regular Run if streaming not supported or middlewares must run
 			result, err := a.Run(ctx, input)
 			if err != nil {
 				yield(nil, err)
 				return
 			}
 			// Still call the callback with the complete result
-			if err := callback(result); err != nil {
-				yield(nil, err)
-				return
+			if callback != nil {
+				if err := callback(result); err != nil {
+					yield(nil, err)
+					return
+				}
 			}
 			yield(result, nil)
 			return
 		}
 
-		// Add user message
-		userMsg := message.NewMessage(message.RoleUser, input)
-		a.AddMessage(userMsg)
+		a.prepareTurn(ctx, input)
+		toolSchemas := a.toolSchemas(input)
 
-		// Search relevant memories if enabled
-		if a.enableMemory && a.memory != nil {
-			memories, err := a.memory.SearchMemory(ctx, input)
-			if err == nil && len(memories) > 0 {
-				// Add memories as context (simplified)
-				memoryContext := "Relevant memories:\n"
-				for _, mem := range memories {
-					memoryContext += fmt.Sprintf("- %v\n", mem)
+		corrected := false
+		for i := 0; i < a.maxIterations; i++ {
+			finalResp, ok := a.streamMessages(ctx, streamProvider, toolSchemas, callback, yield)
+			if !ok {
+				return
+			}
+			if len(finalResp.ToolCalls) == 0 {
+				retry, err := a.checkAnswer(finalResp, &corrected)
+				if err != nil {
+					yield(nil, err)
+					return
+				}
+				if retry {
+					i-- // the correction turn does not use up an iteration
+					continue
+				}
+				if err := a.postProcess(finalResp); err != nil {
+					yield(nil, err)
+					return
 				}
-				contextMsg := message.NewMessage(message.RoleSystem, memoryContext)
-				a.ctx.AddMessage(contextMsg)
 			}
-		}
+			a.AddMessage(finalResp)
 
-		// Execution loop with tool calls
-		// Get tool schemas if enabled
-		var toolSchemas []map[string]any
-		if a.enableTools {
-			toolSchemas = a.tools.ToJSONSchemas()
+			// Check if there are tool calls
+			if len(finalResp.ToolCalls) == 0 {
+				// No tool calls, return the response
+				a.rememberTurn(ctx, input, finalResp)
+
+				yield(finalResp, nil)
+				return
+			}
+
+			// Yield the tool call message, then execute the calls and feed the results back
+			if !yield(finalResp, nil) {
+				return
+			}
+			results := a.executeToolCalls(ctx, finalResp.ToolCalls)
+			for idx, toolCall := range finalResp.ToolCalls {
+				// Add tool response
+				toolMsg := message.NewToolResponseMessage(toolCall.ID, results[idx])
+				a.AddMessage(toolMsg)
+				if !yield(toolMsg, nil) {
+					return
+				}
+			}
 		}
+		yield(nil, fmt.Errorf("max iterations (%d) reached", a.maxIterations))
+	}
+}
 
-		// Call LLM with streaming
-		streamSeq := streamProvider.GenerateStream(ctx, &GenerateRequest{
-			Messages: a.ctx.GetMessages(),
-			Tools:    toolSchemas,
-		})
-		if streamSeq == nil {
-			yield(nil, fmt.Errorf("LLM streaming returned empty sequence"))
-			return
+// RunStreamText runs the agent like RunStream, calls onToken with each text delta
+// as it arrives and returns the final assistant message. Tool calls are executed
+// between streamed turns. A non-nil error from onToken stops the provider stream
+// and is returned unchanged. Providers without streaming support deliver the
+// complete answer as a single token.
+func (a *Agent) RunStreamText(ctx context.Context, input string, onToken func(token string) error) (*message.Message, error) {
+	callback := func(msg *message.Message) error {
+		if onToken == nil {
+			return nil
+		}
+		if token := msg.Text(); token != "" {
+			return onToken(token)
+		}
+		return nil
+	}
+	var final *message.Message
+	for msg, err := range a.RunStream(ctx, input, callback) {
+		if err != nil {
+			return nil, err
 		}
+		final = msg
+	}
+	if final == nil {
+		return nil, fmt.Errorf("no response generated")
+	}
+	return final, nil
+}
 
-		var (
-			streamErr error
-			finalResp *message.Message
-		)
+// streamMessages performs one streaming LLM call for RunStream, passing chunks to the
+// callback and yield, and returns the assembled final message. ok is false when the
+// run must stop, either because an error was yielded or the consumer stopped iteration.
+func (a *Agent) streamMessages(ctx context.Context, streamProvider StreamLLMClient, toolSchemas []map[string]any, callback StreamCallback, yield func(*message.Message, error) bool) (*message.Message, bool) {
+	req := &GenerateRequest{
+		Messages: a.requestMessages(),
+		Tools:    toolSchemas,
+	}
+	applyCallOptions(ctx, req)
+	a.applyResponseFormat(req)
+	if err := a.checkSequence(req); err != nil {
+		yield(nil, err)
+		return nil, false
+	}
+	a.logPrompt(ctx, req)
+	streamSeq := a.generateStream(ctx, streamProvider, req)
 
-		for resp, err := range streamSeq {
-			if err != nil {
+	var (
+		streamErr error
+		finalResp *message.Message
+		text      strings.Builder
+		toolCalls toolCallAccumulator
+	)
+
+	for resp, err := range streamSeq {
+		if err != nil {
+			streamErr = err
+			break
+		}
+		if resp == nil || resp.Message == nil {
+			continue
+		}
+		if !resp.Message.Completed {
+			text.WriteString(resp.Message.Text())
+			toolCalls.add(resp.ToolCallDeltas)
+		}
+
+		if callback != nil && !resp.Message.Completed {
+			if err := callback(resp.Message); err != nil {
 				streamErr = err
 				break
 			}
-			if resp == nil {
-				continue
-			}
+		}
 
-			if callback != nil && !resp.Message.Completed {
-				if err := callback(resp.Message); err != nil {
-					streamErr = err
-					break
-				}
+		if resp.Message.Completed {
+			a.recordResponse(resp)
+			finalResp = resp.Message
+		} else {
+			if !yield(resp.Message, nil) {
+				return nil, false
 			}
+		}
+	}
 
-			if streamErr != nil {
-				yield(nil, streamErr)
-				return
-			}
+	if streamErr != nil {
+		yield(nil, streamErr)
+		return nil, false
+	}
 
-			if resp.Message.Completed {
-				finalResp = resp.Message
-			} else {
-				if !yield(resp.Message, nil) {
-					return
-				}
+	finalResp, err := assembleStreamed(finalResp, text.String(), &toolCalls)
+	if err != nil {
+		yield(nil, err)
+		return nil, false
+	}
+
+	return finalResp, true
+}
+
+// toolCallAccumulator assembles tool calls from streamed ToolCallDelta chunks.
+type toolCallAccumulator struct {
+	calls []*ToolCallDelta
+	index map[int]*ToolCallDelta
+}
+
+// add merges deltas into the calls they belong to, keyed by Index.
+func (t *toolCallAccumulator) add(deltas []ToolCallDelta) {
+	for _, delta := range deltas {
+		if t.index == nil {
+			t.index = make(map[int]*ToolCallDelta)
+		}
+		call, ok := t.index[delta.Index]
+		if !ok {
+			call = &ToolCallDelta{Index: delta.Index}
+			t.index[delta.Index] = call
+			t.calls = append(t.calls, call)
+		}
+		if delta.ID != "" {
+			call.ID = delta.ID
+		}
+		if delta.Name != "" {
+			call.Name = delta.Name
+		}
+		call.Arguments += delta.Arguments
+	}
+}
+
+// toolCalls returns the assembled calls with their JSON arguments decoded.
+func (t *toolCallAccumulator) toolCalls() ([]message.ToolCall, error) {
+	if len(t.calls) == 0 {
+		return nil, nil
+	}
+	calls := make([]message.ToolCall, 0, len(t.calls))
+	for _, call := range t.calls {
+		args := make(map[string]any)
+		if strings.TrimSpace(call.Arguments) != "" {
+			if err := json.Unmarshal([]byte(call.Arguments), &args); err != nil {
+				return nil, fmt.Errorf("failed to parse streamed arguments for tool %s: %w", call.Name, err)
 			}
 		}
+		calls = append(calls, message.ToolCall{ID: call.ID, Name: call.Name, Args: args})
+	}
+	return calls, nil
+}
 
-		if finalResp == nil {
-			yield(nil, fmt.Errorf("LLM streaming ended without final response"))
-			return
+// assembleStreamed completes the final message of a streamed turn with the text and
+// tool calls accumulated from its chunks when the provider left them out. When the
+// provider never sent a completed message, one is built from the chunks.
+func assembleStreamed(final *message.Message, text string, acc *toolCallAccumulator) (*message.Message, error) {
+	calls, err := acc.toolCalls()
+	if err != nil {
+		return nil, err
+	}
+	if final == nil {
+		if text == "" && len(calls) == 0 {
+			return nil, fmt.Errorf("LLM streaming ended without final response")
 		}
+		final = message.NewMessage(message.RoleAssistant, text)
+		final.Completed = true
+	}
+	if final.Text() == "" && text != "" {
+		final.SetText(text)
+	}
+	if len(final.ToolCalls) == 0 && len(calls) > 0 {
+		final.ToolCalls = calls
+	}
+	return final, nil
+}
 
-		a.AddMessage(finalResp)
+// ErrStreamBufferFull is returned by a buffered callback using OverflowError when the buffer is full.
+var ErrStreamBufferFull = errors.New("stream callback buffer is full")
 
-		// Check if there are tool calls
-		if len(finalResp.ToolCalls) == 0 {
-			// No tool calls, return the response
-			if a.enableMemory && a.memory != nil {
-				// Store conversation in memory
-				conversationContent := fmt.Sprintf("User: %s\nAssistant: %s", input, finalResp.Text())
-				mem := &memory.Memory{
-					ID:       memory.GenerateMemoryID(),
-					Content:  conversationContent,
-					Metadata: map[string]any{"input": input, "response": finalResp.Text()},
-				}
-				a.memory.AddMemory(ctx, mem)
-			}
+// OverflowPolicy decides what a buffered callback does when its buffer is full.
+type OverflowPolicy int
 
-			yield(finalResp, nil)
-			return
-		}
+const (
+	// OverflowBlock waits for room in the buffer, propagating backpressure to the stream.
+	OverflowBlock OverflowPolicy = iota
+	// OverflowDropNewest discards the incoming chunk; delivered chunks stay in order.
+	OverflowDropNewest
+	// OverflowError aborts the stream with ErrStreamBufferFull.
+	OverflowError
+)
 
-		// Execute tool calls
-		for _, toolCall := range finalResp.ToolCalls {
-			result, err := a.tools.Execute(ctx, toolCall.Name, toolCall.Args)
-			if err != nil {
-				result = fmt.Sprintf("Error executing tool %s: %v", toolCall.Name, err)
-			}
+// BufferedCallback delivers stream chunks to a consumer on a separate goroutine
+// through a bounded buffer. Chunks are delivered in order.
+type BufferedCallback struct {
+	consumer StreamCallback
+	policy   OverflowPolicy
+	ch       chan *message.Message
+	done     chan struct{}
+
+	sendMu sync.Mutex // guards closed and sends on ch
+	closed bool
+
+	mu      sync.Mutex // guards err and dropped
+	err     error
+	dropped int
+}
+
+// NewBufferedCallback wraps consumer with a bounded buffer of the given size.
+// Call Close after the stream finishes to wait for pending chunks to drain.
+func NewBufferedCallback(consumer StreamCallback, size int, policy OverflowPolicy) *BufferedCallback {
+	if size <= 0 {
+		size = 1
+	}
+	b := &BufferedCallback{
+		consumer: consumer,
+		policy:   policy,
+		ch:       make(chan *message.Message, size),
+		done:     make(chan struct{}),
+	}
+	go b.drain()
+	return b
+}
+
+// Callback returns the StreamCallback to pass to RunStream.
+func (b *BufferedCallback) Callback() StreamCallback {
+	return b.push
+}
+
+// Close stops accepting chunks, waits for the buffer to drain and returns the first consumer error.
+func (b *BufferedCallback) Close() error {
+	b.sendMu.Lock()
+	if !b.closed {
+		b.closed = true
+		close(b.ch)
+	}
+	b.sendMu.Unlock()
+	<-b.done
+	return b.firstErr()
+}
+
+// Dropped reports how many chunks were discarded by OverflowDropNewest.
+func (b *BufferedCallback) Dropped() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.dropped
+}
 
-			// Add tool response
-			toolMsg := message.NewToolResponseMessage(toolCall.ID, result)
-			a.AddMessage(toolMsg)
+func (b *BufferedCallback) push(msg *message.Message) error {
+	if err := b.firstErr(); err != nil {
+		return err
+	}
+	b.sendMu.Lock()
+	defer b.sendMu.Unlock()
+	if b.closed {
+		return fmt.Errorf("stream callback buffer is closed")
+	}
+	switch b.policy {
+	case OverflowDropNewest:
+		select {
+		case b.ch <- msg:
+		default:
+			b.mu.Lock()
+			b.dropped++
+			b.mu.Unlock()
+		}
+		return nil
+	case OverflowError:
+		select {
+		case b.ch <- msg:
+			return nil
+		default:
+			return ErrStreamBufferFull
 		}
+	default:
+		b.ch <- msg
+		return nil
+	}
+}
 
-		yield(finalResp, nil)
+func (b *BufferedCallback) drain() {
+	defer close(b.done)
+	for msg := range b.ch {
+		if b.firstErr() != nil || b.consumer == nil {
+			continue
+		}
+		if err := b.consumer(msg); err != nil {
+			b.mu.Lock()
+			if b.err == nil {
+				b.err = err
+			}
+			b.mu.Unlock()
+		}
 	}
 }
 
+func (b *BufferedCallback) firstErr() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.err
+}
+
 // StreamingOptions holds configuration for streaming operations
 type StreamingOptions struct {
 	BufferSize      int   // Size of token buffer before calling callback
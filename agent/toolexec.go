@@ -0,0 +1,87 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/sweetpotato0/ai-allin/message"
+	"go.opentelemetry.io/otel/attribute"
+	oteltrace "go.opentelemetry.io/otel/trace"
+)
+
+// WithToolConcurrency sets how many tool calls from a single model turn run at
+// once (default 1, sequential). Results are always added to the context in the
+// order the model issued the calls. With n > 1 tool handlers, including several
+// calls to the same tool, run concurrently and must be safe for concurrent use.
+// Tools built with tool.FromAgent serialize calls to their shared agent.
+func WithToolConcurrency(n int) Option {
+	return func(a *Agent) {
+		if n > 0 {
+			a.toolConcurrency = n
+		}
+	}
+}
+
+// WithToolTimeout bounds each execution of tools that have no Timeout of their
+// own. A tool that times out reports the error to the model instead of blocking the run.
+func WithToolTimeout(d time.Duration) Option {
+	return func(a *Agent) {
+		a.tools.SetDefaultTimeout(d)
+	}
+}
+
+// executeToolCalls runs calls with up to toolConcurrency workers and returns their
+// results in call order. A failing tool yields an error string for the model
+// instead of aborting the run; calls not yet started when ctx is done are skipped
+// with the context error.
+func (a *Agent) executeToolCalls(ctx context.Context, calls []message.ToolCall) []string {
+	results := make([]string, len(calls))
+	workers := min(max(a.toolConcurrency, 1), len(calls))
+	if workers <= 1 {
+		for i, call := range calls {
+			results[i] = a.executeToolCall(ctx, call)
+		}
+		return results
+	}
+
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, workers)
+	for i, call := range calls {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+			results[i] = a.executeToolCall(ctx, call)
+		}()
+	}
+	wg.Wait()
+	return results
+}
+
+// executeToolCall runs a single tool call and renders its result or error.
+func (a *Agent) executeToolCall(ctx context.Context, call message.ToolCall) string {
+	span := oteltrace.SpanFromContext(ctx)
+	if err := ctx.Err(); err != nil {
+		return fmt.Sprintf("Error executing tool %s: %v", call.Name, err)
+	}
+	if a.logger != nil {
+		a.logger.Info("executing tool call", "tool", call.Name)
+	}
+	span.AddEvent("tool_call", oteltrace.WithAttributes(attribute.String("tool.name", call.Name)))
+	result, err := a.tools.Execute(ctx, call.Name, call.Args)
+	if err != nil {
+		if a.logger != nil {
+			a.logger.Error("tool execution failed", "tool", call.Name, "error", err)
+		}
+		span.AddEvent("tool_error",
+			oteltrace.WithAttributes(
+				attribute.String("tool.name", call.Name),
+				attribute.String("error", err.Error()),
+			))
+		return fmt.Sprintf("Error executing tool %s: %v", call.Name, err)
+	}
+	return result
+}
@@ -0,0 +1,103 @@
+package agent
+
+import "sync"
+
+// MetadataUsage is the message metadata key holding the Usage of the response
+// that produced an assistant message.
+const MetadataUsage = "usage"
+
+// Usage reports the tokens consumed by one or more LLM calls. Providers fill
+// TotalTokens when the API reports it; the agent computes it from the prompt
+// and completion tokens otherwise.
+type Usage struct {
+	PromptTokens     int64 `json:"prompt_tokens"`
+	CompletionTokens int64 `json:"completion_tokens"`
+	TotalTokens      int64 `json:"total_tokens"`
+}
+
+// WithTotal returns u with TotalTokens set to the sum of prompt and completion
+// tokens when no total was reported.
+func (u Usage) WithTotal() Usage {
+	if u.TotalTokens == 0 {
+		u.TotalTokens = u.PromptTokens + u.CompletionTokens
+	}
+	return u
+}
+
+// Add returns the sum of u and other.
+func (u Usage) Add(other Usage) Usage {
+	u, other = u.WithTotal(), other.WithTotal()
+	return Usage{
+		PromptTokens:     u.PromptTokens + other.PromptTokens,
+		CompletionTokens: u.CompletionTokens + other.CompletionTokens,
+		TotalTokens:      u.TotalTokens + other.TotalTokens,
+	}
+}
+
+// IsZero reports whether no tokens were recorded.
+func (u Usage) IsZero() bool {
+	return u.PromptTokens == 0 && u.CompletionTokens == 0 && u.TotalTokens == 0
+}
+
+// usageMeter accumulates usage across the LLM calls made by an agent.
+type usageMeter struct {
+	mu    sync.Mutex
+	total Usage
+	last  Usage // usage of the run in progress or most recently finished
+}
+
+func (m *usageMeter) add(u Usage) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.total = m.total.Add(u)
+	m.last = m.last.Add(u)
+}
+
+// startRun resets the per-run total.
+func (m *usageMeter) startRun() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.last = Usage{}
+}
+
+func (m *usageMeter) lastRun() Usage {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.last
+}
+
+func (m *usageMeter) get() Usage {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.total
+}
+
+// Usage returns the tokens consumed by every LLM call this agent has made.
+// Clones start from zero, so a clone used for a single run reports that run's usage.
+func (a *Agent) Usage() Usage {
+	return a.usage.get()
+}
+
+// LastRunUsage returns the tokens consumed across all iterations of the most
+// recent Run, RunStream or RunStreamEvents call. Providers that do not report
+// usage leave it zero.
+func (a *Agent) LastRunUsage() Usage {
+	return a.usage.lastRun()
+}
+
+// recordResponse annotates resp's message with its serving backend and usage and
+// adds the usage to the agent total.
+func (a *Agent) recordResponse(resp *GenerateResponse) {
+	resp.annotate()
+	if resp == nil || resp.Usage.IsZero() {
+		return
+	}
+	resp.Usage = resp.Usage.WithTotal()
+	a.usage.add(resp.Usage)
+	if resp.Message != nil {
+		if resp.Message.Metadata == nil {
+			resp.Message.Metadata = make(map[string]any)
+		}
+		resp.Message.Metadata[MetadataUsage] = resp.Usage
+	}
+}
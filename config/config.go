@@ -0,0 +1,73 @@
+// Package config loads provider configurations from conventional environment variables.
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// LLMConfig holds the provider-independent settings shared by all LLM providers.
+type LLMConfig struct {
+	APIKey      string
+	BaseURL     string
+	Model       string
+	MaxTokens   int64
+	Temperature float64
+}
+
+// FieldError describes an invalid LLMConfig field.
+type FieldError struct {
+	Field  string
+	Reason string
+}
+
+func (e *FieldError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Field, e.Reason)
+}
+
+// ValidateLLMConfig checks cfg and returns the joined FieldErrors of every invalid field.
+func ValidateLLMConfig(cfg *LLMConfig) error {
+	if cfg == nil {
+		return fmt.Errorf("llm config is nil")
+	}
+	var errs []error
+	if strings.TrimSpace(cfg.APIKey) == "" {
+		errs = append(errs, &FieldError{Field: "APIKey", Reason: "is required"})
+	}
+	if strings.TrimSpace(cfg.Model) == "" {
+		errs = append(errs, &FieldError{Field: "Model", Reason: "is required"})
+	}
+	if cfg.BaseURL != "" {
+		if u, err := url.Parse(cfg.BaseURL); err != nil || u.Scheme == "" || u.Host == "" {
+			errs = append(errs, &FieldError{Field: "BaseURL", Reason: "must be an absolute URL"})
+		}
+	}
+	if cfg.MaxTokens < 0 {
+		errs = append(errs, &FieldError{Field: "MaxTokens", Reason: "must not be negative"})
+	}
+	if cfg.Temperature < 0 || cfg.Temperature > 2 {
+		errs = append(errs, &FieldError{Field: "Temperature", Reason: "must be between 0 and 2"})
+	}
+	return errors.Join(errs...)
+}
+
+// EnvError lists the environment variables that were missing or invalid while
+// loading a provider configuration.
+type EnvError struct {
+	Provider string
+	Missing  []string
+	Invalid  []string
+}
+
+func (e *EnvError) Error() string {
+	var parts []string
+	if len(e.Missing) > 0 {
+		parts = append(parts, "missing "+strings.Join(e.Missing, ", "))
+	}
+	if len(e.Invalid) > 0 {
+		parts = append(parts, "invalid "+strings.Join(e.Invalid, ", "))
+	}
+	return fmt.Sprintf("config: %s environment: %s", e.Provider, strings.Join(parts, "; "))
+}
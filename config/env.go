@@ -0,0 +1,198 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/sweetpotato0/ai-allin/contrib/provider/claude"
+	"github.com/sweetpotato0/ai-allin/contrib/provider/gemini"
+	"github.com/sweetpotato0/ai-allin/contrib/provider/openai"
+)
+
+// envVars names the environment variables read for one provider.
+// An empty name means the provider does not support that setting.
+type envVars struct {
+	APIKey      string
+	BaseURL     string
+	Model       string
+	MaxTokens   string
+	Temperature string
+}
+
+var (
+	openAIEnv = envVars{
+		APIKey:      "OPENAI_API_KEY",
+		BaseURL:     "OPENAI_API_BASE_URL",
+		Model:       "OPENAI_MODEL",
+		MaxTokens:   "OPENAI_MAX_TOKENS",
+		Temperature: "OPENAI_TEMPERATURE",
+	}
+	claudeEnv = envVars{
+		APIKey:      "ANTHROPIC_API_KEY",
+		BaseURL:     "ANTHROPIC_BASE_URL",
+		Model:       "ANTHROPIC_MODEL",
+		MaxTokens:   "ANTHROPIC_MAX_TOKENS",
+		Temperature: "ANTHROPIC_TEMPERATURE",
+	}
+	geminiEnv = envVars{
+		APIKey:      "GEMINI_API_KEY",
+		Model:       "GEMINI_MODEL",
+		MaxTokens:   "GEMINI_MAX_TOKENS",
+		Temperature: "GEMINI_TEMPERATURE",
+	}
+)
+
+// LoadOpenAIFromEnv builds an OpenAI config from OPENAI_API_KEY (required),
+// OPENAI_API_BASE_URL, OPENAI_MODEL, OPENAI_MAX_TOKENS and OPENAI_TEMPERATURE.
+// Unset optional variables keep the values of openai.DefaultConfig.
+func LoadOpenAIFromEnv() (*openai.Config, error) {
+	cfg := openai.DefaultConfig()
+	llm, err := loadFromEnv("openai", openAIEnv, LLMConfig{
+		BaseURL:     cfg.BaseURL,
+		Model:       cfg.Model,
+		MaxTokens:   cfg.MaxTokens,
+		Temperature: cfg.Temperature,
+	})
+	if err != nil {
+		return nil, err
+	}
+	cfg.APIKey = llm.APIKey
+	cfg.BaseURL = llm.BaseURL
+	cfg.Model = llm.Model
+	cfg.MaxTokens = llm.MaxTokens
+	cfg.Temperature = llm.Temperature
+	return cfg, nil
+}
+
+// LoadClaudeFromEnv builds a Claude config from ANTHROPIC_API_KEY (required),
+// ANTHROPIC_BASE_URL, ANTHROPIC_MODEL, ANTHROPIC_MAX_TOKENS and ANTHROPIC_TEMPERATURE.
+// Unset optional variables keep the values of claude.DefaultConfig.
+func LoadClaudeFromEnv() (*claude.Config, error) {
+	cfg := claude.DefaultConfig()
+	llm, err := loadFromEnv("claude", claudeEnv, LLMConfig{
+		BaseURL:     cfg.BaseURL,
+		Model:       cfg.Model,
+		MaxTokens:   cfg.MaxTokens,
+		Temperature: cfg.Temperature,
+	})
+	if err != nil {
+		return nil, err
+	}
+	cfg.APIKey = llm.APIKey
+	cfg.BaseURL = llm.BaseURL
+	cfg.Model = llm.Model
+	cfg.MaxTokens = llm.MaxTokens
+	cfg.Temperature = llm.Temperature
+	return cfg, nil
+}
+
+// LoadGeminiFromEnv builds a Gemini config from GEMINI_API_KEY (required),
+// GEMINI_MODEL, GEMINI_MAX_TOKENS and GEMINI_TEMPERATURE.
+// Unset optional variables keep the values of gemini.DefaultConfig.
+func LoadGeminiFromEnv() (*gemini.Config, error) {
+	cfg := gemini.DefaultConfig("")
+	llm, err := loadFromEnv("gemini", geminiEnv, LLMConfig{
+		Model:       cfg.Model,
+		MaxTokens:   int64(cfg.MaxTokens),
+		Temperature: float64(cfg.Temperature),
+	})
+	if err != nil {
+		return nil, err
+	}
+	cfg.APIKey = llm.APIKey
+	cfg.Model = llm.Model
+	cfg.MaxTokens = int(llm.MaxTokens)
+	cfg.Temperature = float32(llm.Temperature)
+	return cfg, nil
+}
+
+// loadFromEnv overlays the variables in vars onto defaults and validates the result.
+func loadFromEnv(provider string, vars envVars, defaults LLMConfig) (*LLMConfig, error) {
+	cfg := defaults
+	envErr := &EnvError{Provider: provider}
+
+	cfg.APIKey = lookup(vars.APIKey)
+	if cfg.APIKey == "" {
+		envErr.Missing = append(envErr.Missing, vars.APIKey)
+	}
+	if v := lookup(vars.BaseURL); v != "" {
+		cfg.BaseURL = v
+	}
+	if v := lookup(vars.Model); v != "" {
+		cfg.Model = v
+	}
+	if v := lookup(vars.MaxTokens); v != "" {
+		n, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			envErr.Invalid = append(envErr.Invalid, fmt.Sprintf("%s=%q (not an integer)", vars.MaxTokens, v))
+		} else {
+			cfg.MaxTokens = n
+		}
+	}
+	if v := lookup(vars.Temperature); v != "" {
+		f, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			envErr.Invalid = append(envErr.Invalid, fmt.Sprintf("%s=%q (not a number)", vars.Temperature, v))
+		} else {
+			cfg.Temperature = f
+		}
+	}
+
+	for _, err := range unwrapAll(ValidateLLMConfig(&cfg)) {
+		var fieldErr *FieldError
+		if !errors.As(err, &fieldErr) {
+			envErr.Invalid = append(envErr.Invalid, err.Error())
+			continue
+		}
+		name := vars.field(fieldErr.Field)
+		if name == vars.APIKey && cfg.APIKey == "" {
+			continue // already reported as missing
+		}
+		if name == "" {
+			name = fieldErr.Field
+		}
+		envErr.Invalid = append(envErr.Invalid, fmt.Sprintf("%s (%s)", name, fieldErr.Reason))
+	}
+
+	if len(envErr.Missing) > 0 || len(envErr.Invalid) > 0 {
+		return nil, envErr
+	}
+	return &cfg, nil
+}
+
+func (v envVars) field(name string) string {
+	switch name {
+	case "APIKey":
+		return v.APIKey
+	case "BaseURL":
+		return v.BaseURL
+	case "Model":
+		return v.Model
+	case "MaxTokens":
+		return v.MaxTokens
+	case "Temperature":
+		return v.Temperature
+	default:
+		return ""
+	}
+}
+
+func lookup(name string) string {
+	if name == "" {
+		return ""
+	}
+	return strings.TrimSpace(os.Getenv(name))
+}
+
+func unwrapAll(err error) []error {
+	if err == nil {
+		return nil
+	}
+	if joined, ok := err.(interface{ Unwrap() []error }); ok {
+		return joined.Unwrap()
+	}
+	return []error{err}
+}
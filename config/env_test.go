@@ -0,0 +1,62 @@
+package config
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestLoadOpenAIFromEnv(t *testing.T) {
+	t.Setenv("OPENAI_API_KEY", "sk-test")
+	t.Setenv("OPENAI_API_BASE_URL", "https://proxy.example.com/v1")
+	t.Setenv("OPENAI_MODEL", "gpt-4o")
+	t.Setenv("OPENAI_MAX_TOKENS", "")
+	t.Setenv("OPENAI_TEMPERATURE", "0.2")
+
+	cfg, err := LoadOpenAIFromEnv()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.APIKey != "sk-test" || cfg.BaseURL != "https://proxy.example.com/v1" || cfg.Model != "gpt-4o" {
+		t.Fatalf("unexpected config: %+v", cfg)
+	}
+	if cfg.Temperature != 0.2 {
+		t.Fatalf("expected temperature 0.2, got %v", cfg.Temperature)
+	}
+	if cfg.MaxTokens != 2000 {
+		t.Fatalf("expected default max tokens, got %d", cfg.MaxTokens)
+	}
+}
+
+func TestLoadClaudeFromEnvReportsAllProblems(t *testing.T) {
+	t.Setenv("ANTHROPIC_API_KEY", "")
+	t.Setenv("ANTHROPIC_BASE_URL", "not a url")
+	t.Setenv("ANTHROPIC_MODEL", "")
+	t.Setenv("ANTHROPIC_MAX_TOKENS", "lots")
+	t.Setenv("ANTHROPIC_TEMPERATURE", "5")
+
+	_, err := LoadClaudeFromEnv()
+	var envErr *EnvError
+	if !errors.As(err, &envErr) {
+		t.Fatalf("expected EnvError, got %v", err)
+	}
+	if len(envErr.Missing) != 1 || envErr.Missing[0] != "ANTHROPIC_API_KEY" {
+		t.Fatalf("expected missing API key, got %v", envErr.Missing)
+	}
+	invalid := strings.Join(envErr.Invalid, " ")
+	for _, name := range []string{"ANTHROPIC_BASE_URL", "ANTHROPIC_MAX_TOKENS", "ANTHROPIC_TEMPERATURE"} {
+		if !strings.Contains(invalid, name) {
+			t.Errorf("expected %s in invalid list, got %v", name, envErr.Invalid)
+		}
+	}
+}
+
+func TestValidateLLMConfig(t *testing.T) {
+	if err := ValidateLLMConfig(&LLMConfig{APIKey: "k", Model: "m", Temperature: 0.7}); err != nil {
+		t.Fatalf("expected valid config, got %v", err)
+	}
+	err := ValidateLLMConfig(&LLMConfig{MaxTokens: -1})
+	if got := len(unwrapAll(err)); got != 3 {
+		t.Fatalf("expected 3 field errors, got %d: %v", got, err)
+	}
+}
@@ -0,0 +1,125 @@
+package context
+
+import (
+	"encoding/json"
+	"unicode/utf8"
+
+	"github.com/sweetpotato0/ai-allin/message"
+)
+
+// TokenCounter estimates how many tokens a piece of text uses.
+type TokenCounter interface {
+	Count(text string) int
+}
+
+// TokenCounterFunc adapts a function, such as a tokenizer's CountTokens method,
+// to TokenCounter.
+type TokenCounterFunc func(text string) int
+
+// Count implements TokenCounter.
+func (f TokenCounterFunc) Count(text string) int {
+	return f(text)
+}
+
+// approxCounter estimates roughly four characters per token.
+type approxCounter struct{}
+
+func (approxCounter) Count(text string) int {
+	return (utf8.RuneCountInString(text) + 3) / 4
+}
+
+// NewWithTokenBudget creates a context that keeps at most maxTokens estimated
+// tokens. When a message pushes the total over budget, the oldest non-system
+// messages are evicted until it fits; system messages and the newest message are
+// always kept. A nil counter uses an estimate of four characters per token.
+func NewWithTokenBudget(maxTokens int, counter TokenCounter, opts ...Option) *Context {
+	if counter == nil {
+		counter = approxCounter{}
+	}
+	c := &Context{
+		messages:    make([]*message.Message, 0),
+		tokenBudget: maxTokens,
+		counter:     counter,
+	}
+	return c.apply(opts)
+}
+
+// TokenCount returns the estimated tokens of all messages in the context. It
+// uses the budget's counter, or the four-characters-per-token estimate when the
+// context has no token budget.
+func (c *Context) TokenCount() int {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	total := 0
+	for _, msg := range c.messages {
+		total += c.messageTokens(msg)
+	}
+	return total
+}
+
+// TokenBudget returns the configured token budget, or 0 when there is none.
+func (c *Context) TokenBudget() int {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.tokenBudget
+}
+
+// trimToBudget evicts the oldest non-system messages, except the newest message,
+// until the estimated total fits the token budget, and returns them. With a
+// summarizer messages are evicted down to half the budget so a block can be
+// folded into the running summary at once.
+func (c *Context) trimToBudget() []*message.Message {
+	if c.tokenBudget <= 0 || len(c.messages) == 0 {
+		return nil
+	}
+	counts := make([]int, len(c.messages))
+	total := 0
+	for i, msg := range c.messages {
+		counts[i] = c.messageTokens(msg)
+		total += counts[i]
+	}
+	if total <= c.tokenBudget {
+		return nil
+	}
+
+	target := c.tokenBudget
+	if c.summarizer != nil {
+		target = c.tokenBudget / 2
+	}
+	last := len(c.messages) - 1
+	evict := make([]bool, len(c.messages))
+	var dropped []*message.Message
+	for i := 0; i < last && total > target; i++ {
+		if c.messages[i].Role == message.RoleSystem {
+			continue
+		}
+		evict[i] = true
+		total -= counts[i]
+		dropped = append(dropped, c.messages[i])
+	}
+	kept := make([]*message.Message, 0, len(c.messages)-len(dropped))
+	for i, msg := range c.messages {
+		if !evict[i] {
+			kept = append(kept, msg)
+		}
+	}
+	c.messages = kept
+	return dropped
+}
+
+// messageTokens estimates the tokens of msg's text and tool call arguments.
+func (c *Context) messageTokens(msg *message.Message) int {
+	counter := c.counter
+	if counter == nil {
+		counter = approxCounter{}
+	}
+	tokens := counter.Count(msg.Text())
+	for _, call := range msg.ToolCalls {
+		tokens += counter.Count(call.Name)
+		if args, err := json.Marshal(call.Args); err == nil {
+			tokens += counter.Count(string(args))
+		}
+	}
+	return tokens
+}
@@ -1,6 +1,7 @@
 package context
 
 import (
+	stdcontext "context"
 	"sync"
 
 	"github.com/sweetpotato0/ai-allin/message"
@@ -11,56 +12,162 @@ import (
 type Context struct {
 	mu       sync.RWMutex // Protects messages and maxSize
 	messages []*message.Message
-	maxSize  int // Maximum number of messages to keep
+	maxSize  int // Maximum number of messages to keep (0 means unlimited)
+
+	tokenBudget int          // Maximum estimated tokens to keep (0 disables)
+	counter     TokenCounter // Estimates tokens for the budget
+	summarizer  Summarizer   // Replaces evicted messages with a summary when set
+
+	foldMu sync.Mutex // Serializes summarizer calls, which run without mu held
 }
 
 // New creates a new context with default settings
-func New() *Context {
-	return &Context{
+func New(opts ...Option) *Context {
+	c := &Context{
 		messages: make([]*message.Message, 0),
 		maxSize:  100, // Default max size
 	}
+	return c.apply(opts)
 }
 
 // NewWithMaxSize creates a new context with specified max size
-func NewWithMaxSize(maxSize int) *Context {
-	return &Context{
+func NewWithMaxSize(maxSize int, opts ...Option) *Context {
+	c := &Context{
 		messages: make([]*message.Message, 0),
 		maxSize:  maxSize,
 	}
+	return c.apply(opts)
 }
 
 // AddMessage adds a message to the context
 func (c *Context) AddMessage(msg *message.Message) {
-	c.mu.Lock()
-	defer c.mu.Unlock()
+	c.AddMessageContext(stdcontext.Background(), msg)
+}
 
+// AddMessageContext adds a message to the context. ctx is passed to the
+// summarizer when the message causes older messages to be evicted.
+func (c *Context) AddMessageContext(ctx stdcontext.Context, msg *message.Message) {
+	c.mu.Lock()
 	c.messages = append(c.messages, msg)
+	dropped := c.trim()
+	c.mu.Unlock()
+
+	c.fold(ctx, dropped)
+}
+
+// Append adds msgs to the context in order, skipping nil entries. The size
+// limit is applied once after all messages are added, keeping system messages.
+func (c *Context) Append(msgs []*message.Message) {
+	c.AppendContext(stdcontext.Background(), msgs)
+}
+
+// AppendContext is Append with a ctx passed to the summarizer.
+func (c *Context) AppendContext(ctx stdcontext.Context, msgs []*message.Message) {
+	c.mu.Lock()
+	for _, msg := range msgs {
+		if msg != nil {
+			c.messages = append(c.messages, msg)
+		}
+	}
+	dropped := c.trim()
+	c.mu.Unlock()
+
+	c.fold(ctx, dropped)
+}
+
+// MergeFrom appends a snapshot of other's messages to the context in order,
+// subject to the same size limit and system-message preservation as Append.
+// Messages the context already holds are skipped: those with the same ID, and
+// system messages with the same text, so merging twice or merging a context
+// with the same system prompt does not duplicate them.
+func (c *Context) MergeFrom(other *Context) {
+	if other == nil || other == c {
+		return
+	}
+	incoming := other.GetMessages()
+
+	c.mu.RLock()
+	ids := make(map[string]bool, len(c.messages))
+	systems := make(map[string]bool)
+	for _, m := range c.messages {
+		if m.ID != "" {
+			ids[m.ID] = true
+		}
+		if m.Role == message.RoleSystem {
+			systems[m.Text()] = true
+		}
+	}
+	c.mu.RUnlock()
 
-	// Trim old messages if exceeds max size
-	if len(c.messages) > c.maxSize {
-		// Keep system messages and recent messages
-		systemMsgs := make([]*message.Message, 0)
-		for _, m := range c.messages {
-			if m.Role == message.RoleSystem {
-				systemMsgs = append(systemMsgs, m)
-			}
+	msgs := make([]*message.Message, 0, len(incoming))
+	for _, m := range incoming {
+		if (m.ID != "" && ids[m.ID]) || (m.Role == message.RoleSystem && systems[m.Text()]) {
+			continue
 		}
+		msgs = append(msgs, m)
+	}
+	c.Append(msgs)
+}
+
+// trim enforces the size limit and then the token budget, returning the
+// evicted messages. The caller must hold the write lock.
+func (c *Context) trim() []*message.Message {
+	dropped := c.trimToSize()
+	return append(dropped, c.trimToBudget()...)
+}
 
-		// Calculate how many non-system messages to keep
-		keepCount := c.maxSize - len(systemMsgs)
-		recentMsgs := c.messages[len(c.messages)-keepCount:]
-
-		// Rebuild messages: system messages + recent messages
-		newMessages := make([]*message.Message, 0, c.maxSize)
-		newMessages = append(newMessages, systemMsgs...)
-		for _, m := range recentMsgs {
-			if m.Role != message.RoleSystem {
-				newMessages = append(newMessages, m)
-			}
+// trimToSize drops the oldest non-system messages once the context exceeds maxSize
+// and returns them. System messages and the running summary are moved to the
+// front. With a summarizer the oldest half of the conversation is evicted at once
+// so it can be folded into the summary, which takes one slot.
+func (c *Context) trimToSize() []*message.Message {
+	if c.maxSize <= 0 || len(c.messages) <= c.maxSize {
+		return nil
+	}
+
+	// Keep system messages and recent messages
+	systemMsgs := make([]*message.Message, 0)
+	var summary *message.Message
+	for _, m := range c.messages {
+		switch {
+		case IsSummary(m):
+			summary = m
+		case m.Role == message.RoleSystem:
+			systemMsgs = append(systemMsgs, m)
+		}
+	}
+
+	// Calculate how many non-system messages to keep
+	keepCount := max(c.maxSize-len(systemMsgs), 0)
+	if c.summarizer != nil {
+		// Summarize a block at once rather than one message per overflow.
+		capacity := max(keepCount-1, 0)
+		keepCount = max(capacity/2, min(capacity, 1))
+	} else if summary != nil {
+		keepCount = max(keepCount-1, 0)
+	}
+	cut := max(len(c.messages)-keepCount, 0)
+	recentMsgs := c.messages[cut:]
+	dropped := make([]*message.Message, 0, cut)
+	for _, m := range c.messages[:cut] {
+		if m.Role != message.RoleSystem {
+			dropped = append(dropped, m)
+		}
+	}
+
+	// Rebuild messages: system messages + summary + recent messages
+	newMessages := make([]*message.Message, 0, c.maxSize)
+	newMessages = append(newMessages, systemMsgs...)
+	if summary != nil {
+		newMessages = append(newMessages, summary)
+	}
+	for _, m := range recentMsgs {
+		if m.Role != message.RoleSystem {
+			newMessages = append(newMessages, m)
 		}
-		c.messages = newMessages
 	}
+	c.messages = newMessages
+	return dropped
 }
 
 // GetMessages returns a copy of all messages in the context
@@ -0,0 +1,234 @@
+package context
+
+import (
+	stdcontext "context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sweetpotato0/ai-allin/message"
+)
+
+func sys(text string) *message.Message  { return message.NewMessage(message.RoleSystem, text) }
+func user(text string) *message.Message { return message.NewMessage(message.RoleUser, text) }
+
+func texts(c *Context) []string {
+	var out []string
+	for _, m := range c.GetMessages() {
+		out = append(out, m.Text())
+	}
+	return out
+}
+
+// words counts whitespace-separated words, so budgets are easy to reason about.
+var words = TokenCounterFunc(func(text string) int { return len(strings.Fields(text)) })
+
+func TestAppendTrimsToSize(t *testing.T) {
+	cases := []struct {
+		name    string
+		maxSize int
+		msgs    []*message.Message
+		want    []string
+	}{
+		{"under limit", 3, []*message.Message{sys("rules"), user("u1")}, []string{"rules", "u1"}},
+		{"drops oldest", 3, []*message.Message{user("u1"), user("u2"), user("u3"), user("u4"), user("u5")}, []string{"u3", "u4", "u5"}},
+		{"keeps system", 3, []*message.Message{sys("rules"), user("u1"), user("u2"), user("u3")}, []string{"rules", "u2", "u3"}},
+		{"moves system first", 3, []*message.Message{sys("rules"), user("u1"), sys("notes"), user("u2")}, []string{"rules", "notes", "u2"}},
+		{"skips nil", 3, []*message.Message{user("u1"), nil, user("u2")}, []string{"u1", "u2"}},
+		{"unlimited", 0, []*message.Message{user("u1"), user("u2"), user("u3"), user("u4")}, []string{"u1", "u2", "u3", "u4"}},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			c := NewWithMaxSize(tc.maxSize)
+			c.Append(tc.msgs)
+			if got := texts(c); fmt.Sprint(got) != fmt.Sprint(tc.want) {
+				t.Fatalf("got %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestTokenBudgetTrimming(t *testing.T) {
+	cases := []struct {
+		name      string
+		budget    int
+		msgs      []*message.Message
+		want      []string
+		wantCount int
+	}{
+		{"fits", 10, []*message.Message{sys("be brief"), user("one two")}, []string{"be brief", "one two"}, 4},
+		{"evicts oldest", 4, []*message.Message{sys("be brief"), user("one two"), user("three four")}, []string{"be brief", "three four"}, 4},
+		{"evicts until it fits", 5, []*message.Message{user("a"), user("b c"), user("d e f")}, []string{"b c", "d e f"}, 5},
+		{"keeps newest over budget", 2, []*message.Message{sys("be brief"), user("a b c")}, []string{"be brief", "a b c"}, 5},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			c := NewWithTokenBudget(tc.budget, words)
+			for _, m := range tc.msgs {
+				c.AddMessage(m)
+			}
+			if got := texts(c); fmt.Sprint(got) != fmt.Sprint(tc.want) {
+				t.Fatalf("got %q, want %q", got, tc.want)
+			}
+			if got := c.TokenCount(); got != tc.wantCount {
+				t.Fatalf("TokenCount() = %d, want %d", got, tc.wantCount)
+			}
+			if c.TokenBudget() != tc.budget {
+				t.Fatalf("TokenBudget() = %d, want %d", c.TokenBudget(), tc.budget)
+			}
+		})
+	}
+}
+
+func TestMergeFrom(t *testing.T) {
+	shared := user("hello")
+	cases := []struct {
+		name   string
+		target []*message.Message
+		source []*message.Message
+		want   []string
+	}{
+		{"appends in order", []*message.Message{sys("rules"), user("a")}, []*message.Message{user("b"), user("c")}, []string{"rules", "a", "b", "c"}},
+		{"skips known ids", []*message.Message{shared}, []*message.Message{shared, user("b")}, []string{"hello", "b"}},
+		{"skips duplicate system prompt", []*message.Message{sys("rules")}, []*message.Message{sys("rules"), user("b")}, []string{"rules", "b"}},
+		{"keeps new system messages", []*message.Message{sys("rules")}, []*message.Message{sys("notes"), user("b")}, []string{"rules", "notes", "b"}},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			target, source := New(), New()
+			target.Append(tc.target)
+			source.Append(tc.source)
+			target.MergeFrom(source)
+			target.MergeFrom(source) // merging again adds nothing
+			if got := texts(target); fmt.Sprint(got) != fmt.Sprint(tc.want) {
+				t.Fatalf("got %q, want %q", got, tc.want)
+			}
+		})
+	}
+
+	limited := NewWithMaxSize(3)
+	limited.Append([]*message.Message{sys("rules"), user("a")})
+	source := New()
+	source.Append([]*message.Message{user("b"), user("c"), user("d")})
+	limited.MergeFrom(source)
+	if got := texts(limited); fmt.Sprint(got) != fmt.Sprint([]string{"rules", "c", "d"}) {
+		t.Fatalf("expected the size limit to apply to merged messages, got %q", got)
+	}
+}
+
+// recordingSummarizer joins the texts it is given into the summary.
+type recordingSummarizer struct {
+	calls [][]string
+}
+
+func (r *recordingSummarizer) summarize(ctx stdcontext.Context, msgs []*message.Message) (*message.Message, error) {
+	var in []string
+	for _, m := range msgs {
+		in = append(in, m.Text())
+	}
+	r.calls = append(r.calls, in)
+	return message.NewMessage(message.RoleAssistant, "summary of "+strings.Join(in, ",")), nil
+}
+
+func TestSummarizerTriggersAtSizeLimit(t *testing.T) {
+	cases := []struct {
+		adds      int
+		wantCalls int
+		wantSize  int
+	}{
+		{adds: 4, wantCalls: 0, wantSize: 5},
+		{adds: 5, wantCalls: 1, wantSize: 3},
+		{adds: 7, wantCalls: 1, wantSize: 5},
+		{adds: 8, wantCalls: 2, wantSize: 3},
+	}
+	for _, tc := range cases {
+		t.Run(fmt.Sprintf("%d messages", tc.adds), func(t *testing.T) {
+			rec := &recordingSummarizer{}
+			c := NewWithMaxSize(5, WithSummarizer(rec.summarize))
+			c.AddMessage(sys("rules"))
+			for i := 1; i <= tc.adds; i++ {
+				c.AddMessage(user(fmt.Sprintf("u%d", i)))
+			}
+			if len(rec.calls) != tc.wantCalls || c.Size() != tc.wantSize {
+				t.Fatalf("got %d calls and %d messages %q, want %d and %d", len(rec.calls), c.Size(), texts(c), tc.wantCalls, tc.wantSize)
+			}
+		})
+	}
+
+	// The running summary is extended, not summarized as conversation.
+	rec := &recordingSummarizer{}
+	c := NewWithMaxSize(5, WithSummarizer(rec.summarize))
+	c.AddMessage(sys("rules"))
+	for i := 1; i <= 8; i++ {
+		c.AddMessage(user(fmt.Sprintf("u%d", i)))
+	}
+	msgs := c.GetMessages()
+	if msgs[0].Text() != "rules" || !IsSummary(msgs[1]) || msgs[1].Role != message.RoleSystem || msgs[2].Text() != "u8" {
+		t.Fatalf("unexpected messages %q", texts(c))
+	}
+	if want := "summary of summary of u1,u2,u3,u4,u5,u6,u7"; fmt.Sprint(rec.calls[1]) != fmt.Sprint([]string{"summary of u1,u2,u3,u4", "u5", "u6", "u7"}) || msgs[1].Text() != want {
+		t.Fatalf("expected the previous summary to be folded in, got calls %q and %q", rec.calls, msgs[1].Text())
+	}
+}
+
+func TestSummarizerWithTokenBudget(t *testing.T) {
+	rec := &recordingSummarizer{}
+	c := NewWithTokenBudget(6, words, WithSummarizer(rec.summarize))
+	c.AddMessage(sys("be brief"))
+	c.AddMessage(user("a b"))
+	c.AddMessage(user("c d"))
+	c.AddMessage(user("e f"))
+	// 8 tokens exceed the budget of 6; eviction goes down to half the budget.
+	if len(rec.calls) != 1 || fmt.Sprint(rec.calls[0]) != fmt.Sprint([]string{"a b", "c d"}) {
+		t.Fatalf("expected the two oldest messages to be summarized, got %q", rec.calls)
+	}
+	if got := texts(c); fmt.Sprint(got) != fmt.Sprint([]string{"be brief", "summary of a b,c d", "e f"}) {
+		t.Fatalf("unexpected messages %q", got)
+	}
+}
+
+func TestSummarizerRunsWithoutLockAndHonoursContext(t *testing.T) {
+	started, release := make(chan struct{}), make(chan struct{})
+	c := NewWithMaxSize(3, WithSummarizer(func(ctx stdcontext.Context, msgs []*message.Message) (*message.Message, error) {
+		close(started)
+		select {
+		case <-release:
+			return user("summary"), nil
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}))
+	c.Append([]*message.Message{user("u1"), user("u2"), user("u3")})
+
+	ctx, cancel := stdcontext.WithCancel(stdcontext.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		c.AddMessageContext(ctx, user("u4"))
+	}()
+	<-started
+
+	// Readers and writers are not blocked while the summarizer works.
+	read := make(chan []string)
+	go func() {
+		c.AddMessage(user("u5"))
+		read <- texts(c)
+	}()
+	select {
+	case got := <-read:
+		if fmt.Sprint(got) != fmt.Sprint([]string{"u4", "u5"}) {
+			t.Fatalf("unexpected messages while summarizing %q", got)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("context blocked while the summarizer was running")
+	}
+
+	// Cancelling the caller's context stops the summarizer; the messages are dropped.
+	cancel()
+	<-done
+	if got := texts(c); fmt.Sprint(got) != fmt.Sprint([]string{"u4", "u5"}) {
+		t.Fatalf("expected no summary after cancellation, got %q", got)
+	}
+	close(release)
+}
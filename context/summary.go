@@ -0,0 +1,115 @@
+package context
+
+import (
+	stdcontext "context"
+	"slices"
+
+	"github.com/sweetpotato0/ai-allin/message"
+)
+
+// MetadataSummary is the metadata key set to true on summary messages created by
+// a Summarizer, so they can be told apart from ordinary system messages.
+const MetadataSummary = "context_summary"
+
+// Summarizer condenses messages that are about to be evicted into one message.
+type Summarizer func(ctx stdcontext.Context, msgs []*message.Message) (*message.Message, error)
+
+// Option configures a Context.
+type Option func(*Context)
+
+// WithSummarizer folds messages that would be evicted by the size limit or token
+// budget into a single running summary produced by fn. Eviction then removes a
+// block of old messages at once, so fn is not called on every new message.
+//
+// The context keeps one summary, stored as a system message flagged with
+// MetadataSummary. When a summary already exists it is passed to fn as the first
+// message (see IsSummary) and replaced by the result, so earlier summaries are
+// extended rather than summarized as conversation. A new summary is placed after
+// the leading system messages. fn runs after the evicted messages have been
+// removed and without the context locked, so readers and writers are not blocked
+// while it works; calls to fn are serialized. It receives the ctx given to
+// AddMessageContext or AppendContext (context.Background for AddMessage and
+// Append). When fn fails, the messages are dropped as they would be without a
+// summarizer.
+func WithSummarizer(fn Summarizer) Option {
+	return func(c *Context) {
+		c.summarizer = fn
+	}
+}
+
+func (c *Context) apply(opts []Option) *Context {
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// fold merges dropped into the running summary and swaps the result into the
+// context. It must be called without holding mu.
+func (c *Context) fold(ctx stdcontext.Context, dropped []*message.Message) {
+	if c.summarizer == nil || len(dropped) == 0 {
+		return
+	}
+	c.foldMu.Lock()
+	defer c.foldMu.Unlock()
+
+	c.mu.RLock()
+	var prev *message.Message
+	for _, m := range c.messages {
+		if IsSummary(m) {
+			prev = m
+		}
+	}
+	c.mu.RUnlock()
+
+	summary := c.summarize(ctx, prev, dropped)
+	if summary == prev {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if prev != nil {
+		for i, m := range c.messages {
+			if m == prev {
+				c.messages[i] = summary
+				return
+			}
+		}
+		// The context was cleared while summarizing; the summary is stale.
+		return
+	}
+	at := 0
+	for at < len(c.messages) && c.messages[at].Role == message.RoleSystem {
+		at++
+	}
+	c.messages = slices.Insert(c.messages, at, summary)
+}
+
+// summarize folds dropped into the running summary prev and returns the new
+// summary. It returns prev unchanged when the summarizer fails.
+func (c *Context) summarize(ctx stdcontext.Context, prev *message.Message, dropped []*message.Message) *message.Message {
+	msgs := dropped
+	if prev != nil {
+		msgs = append([]*message.Message{prev}, dropped...)
+	}
+	summary, err := c.summarizer(ctx, msgs)
+	if err != nil || summary == nil {
+		return prev
+	}
+	summary.Role = message.RoleSystem
+	if summary.Metadata == nil {
+		summary.Metadata = make(map[string]any)
+	}
+	summary.Metadata[MetadataSummary] = true
+	return summary
+}
+
+// IsSummary reports whether msg was produced by the context's summarizer.
+func IsSummary(msg *message.Message) bool {
+	if msg == nil {
+		return false
+	}
+	flagged, _ := msg.Metadata[MetadataSummary].(bool)
+	return flagged
+}
@@ -0,0 +1,243 @@
+package recursive
+
+import (
+	"context"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/sweetpotato0/ai-allin/rag/chunking"
+	"github.com/sweetpotato0/ai-allin/rag/document"
+	"github.com/sweetpotato0/ai-allin/rag/tokenizer"
+)
+
+// DefaultSeparators splits on paragraphs, then lines, sentences and words.
+var DefaultSeparators = []string{"\n\n", "\n", ". ", " "}
+
+var _ chunking.Chunker = (*Chunker)(nil)
+
+// Chunker recursively splits text on a priority list of separators until every
+// piece fits the chunk size, then merges neighbouring pieces back into chunks
+// close to that size. Text that still does not fit once the separators are
+// exhausted is split into atomic tokens: words and single CJK characters.
+// A chunk only exceeds the size when one atomic token is larger on its own.
+type Chunker struct {
+	separators []string
+	chunkSize  int
+	overlap    int
+	propagate  bool
+	tk         tokenizer.Tokenizer
+	byTokens   bool
+}
+
+// Option customizes the recursive chunker.
+type Option func(*Chunker)
+
+// WithSeparators sets the separators tried in order, from coarsest to finest.
+// Empty separators are ignored.
+func WithSeparators(separators ...string) Option {
+	return func(c *Chunker) {
+		var seps []string
+		for _, sep := range separators {
+			if sep != "" {
+				seps = append(seps, sep)
+			}
+		}
+		if len(seps) > 0 {
+			c.separators = seps
+		}
+	}
+}
+
+// WithChunkSize sets the maximum chunk size, in characters unless a tokenizer is configured.
+func WithChunkSize(size int) Option {
+	return func(c *Chunker) {
+		if size > 0 {
+			c.chunkSize = size
+		}
+	}
+}
+
+// WithOverlap sets how much trailing text of a chunk is repeated at the start
+// of the next one, measured like the chunk size.
+func WithOverlap(overlap int) Option {
+	return func(c *Chunker) {
+		if overlap >= 0 {
+			c.overlap = overlap
+		}
+	}
+}
+
+// WithTokenizer measures chunk size and overlap in tokens instead of characters.
+func WithTokenizer(t tokenizer.Tokenizer) Option {
+	return func(c *Chunker) {
+		if t != nil {
+			c.tk, c.byTokens = t, true
+		}
+	}
+}
+
+// WithPropagateMetadata controls whether each chunk receives the metadata built by
+// chunking.ChunkMetadata. Enabled by default.
+func WithPropagateMetadata(enabled bool) Option {
+	return func(c *Chunker) {
+		c.propagate = enabled
+	}
+}
+
+// New constructs a recursive chunker. It defaults to DefaultSeparators, chunks of
+// 1000 characters and an overlap of 100. The overlap is capped below the chunk size.
+func New(opts ...Option) *Chunker {
+	c := &Chunker{
+		separators: DefaultSeparators,
+		chunkSize:  1000,
+		overlap:    100,
+		propagate:  true,
+		tk:         tokenizer.NewSimpleTokenizer(),
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	if c.overlap >= c.chunkSize {
+		c.overlap = c.chunkSize - 1
+	}
+	return c
+}
+
+// piece is a byte range of the document content with its measured size.
+type piece struct {
+	start, end int
+	size       int
+}
+
+// Chunk splits the document into chunks of at most the configured size.
+// Every chunk records StartRune/EndRune, the half-open rune range of doc.Content it was cut from.
+func (c *Chunker) Chunk(ctx context.Context, doc document.Document) ([]document.Chunk, error) {
+	pieces := c.split(doc.Content, 0, len(doc.Content), 0)
+
+	var (
+		chunks []document.Chunk
+		window []piece
+		total  int
+	)
+	for _, p := range pieces {
+		if len(window) > 0 && total+p.size > c.chunkSize {
+			if chunk, ok := c.newChunk(doc, window); ok {
+				chunks = append(chunks, chunk)
+			}
+			for len(window) > 0 && (total > c.overlap || total+p.size > c.chunkSize) {
+				total -= window[0].size
+				window = window[1:]
+			}
+		}
+		window = append(window, p)
+		total += p.size
+	}
+	if chunk, ok := c.newChunk(doc, window); ok {
+		chunks = append(chunks, chunk)
+	}
+
+	for i := range chunks {
+		chunks[i].Ordinal = i
+		if c.propagate {
+			chunks[i].Metadata = chunking.ChunkMetadata(doc, i)
+		}
+	}
+	return chunks, nil
+}
+
+// split cuts text[start:end] on the separators from index sep onwards. Each
+// separator stays attached to the piece before it, so the pieces cover the
+// range without gaps.
+func (c *Chunker) split(text string, start, end, sep int) []piece {
+	if size := c.measure(text[start:end]); size <= c.chunkSize {
+		return []piece{{start: start, end: end, size: size}}
+	}
+	for ; sep < len(c.separators); sep++ {
+		if strings.Contains(text[start:end], c.separators[sep]) {
+			break
+		}
+	}
+	if sep == len(c.separators) {
+		return c.splitAtomic(text, start, end)
+	}
+
+	var out []piece
+	separator := c.separators[sep]
+	for start < end {
+		next := end
+		if idx := strings.Index(text[start:end], separator); idx >= 0 {
+			next = start + idx + len(separator)
+		}
+		out = append(out, c.split(text, start, next, sep+1)...)
+		start = next
+	}
+	return out
+}
+
+// splitAtomic cuts text[start:end] into words and single CJK characters, each
+// keeping the whitespace that follows it.
+func (c *Chunker) splitAtomic(text string, start, end int) []piece {
+	var out []piece
+	from, inWord := start, false
+	cut := func(at int) {
+		if at > from {
+			out = append(out, piece{start: from, end: at, size: c.measure(text[from:at])})
+			from = at
+		}
+	}
+	for i := start; i < end; {
+		r, n := utf8.DecodeRuneInString(text[i:end])
+		switch {
+		case unicode.IsSpace(r):
+			inWord = false
+		case unicode.Is(unicode.Han, r):
+			cut(i)
+			inWord = false
+			i += n
+			cut(i)
+			continue
+		default:
+			if !inWord && i > from {
+				cut(i)
+			}
+			inWord = true
+		}
+		i += n
+	}
+	cut(end)
+	return out
+}
+
+// newChunk joins the window into a chunk, trimming surrounding whitespace. It
+// reports false when the window holds only whitespace.
+func (c *Chunker) newChunk(doc document.Document, window []piece) (document.Chunk, bool) {
+	if len(window) == 0 {
+		return document.Chunk{}, false
+	}
+	start, end := window[0].start, window[len(window)-1].end
+	raw := doc.Content[start:end]
+	content := strings.TrimLeftFunc(raw, unicode.IsSpace)
+	start += len(raw) - len(content)
+	content = strings.TrimRightFunc(content, unicode.IsSpace)
+	if content == "" {
+		return document.Chunk{}, false
+	}
+	startRune := utf8.RuneCountInString(doc.Content[:start])
+	return document.Chunk{
+		ID:         document.GenChunkID("", doc.ID),
+		DocumentID: doc.ID,
+		Content:    content,
+		StartRune:  startRune,
+		EndRune:    startRune + utf8.RuneCountInString(content),
+		TokenCount: c.tk.CountTokens(content),
+	}, true
+}
+
+// measure returns the size of text in characters, or tokens when a tokenizer is set.
+func (c *Chunker) measure(text string) int {
+	if c.byTokens {
+		return c.tk.CountTokens(text)
+	}
+	return utf8.RuneCountInString(text)
+}
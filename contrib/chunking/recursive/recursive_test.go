@@ -0,0 +1,126 @@
+package recursive
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/sweetpotato0/ai-allin/rag/chunking"
+	"github.com/sweetpotato0/ai-allin/rag/document"
+	"github.com/sweetpotato0/ai-allin/rag/tokenizer"
+)
+
+const mixed = "Retrieval augmented generation grounds answers in documents. It needs good chunks.\n\n" +
+	"检索增强生成会先检索相关文档，再把它们交给大模型回答问题。分块质量直接影响召回效果。\n" +
+	"Chunks should keep sentences together. 中文句子之间通常没有空格。\n\n" +
+	"The end."
+
+func checkChunks(t *testing.T, content string, chunks []document.Chunk, size int, measure func(string) int) {
+	t.Helper()
+	if len(chunks) == 0 {
+		t.Fatal("expected chunks")
+	}
+	runes := []rune(content)
+	for i, chunk := range chunks {
+		if n := measure(chunk.Content); n > size {
+			t.Fatalf("chunk %d has size %d > %d: %q", i, n, size, chunk.Content)
+		}
+		if string(runes[chunk.StartRune:chunk.EndRune]) != chunk.Content {
+			t.Fatalf("chunk %d offsets [%d,%d) do not match its content %q", i, chunk.StartRune, chunk.EndRune, chunk.Content)
+		}
+		if chunk.Content != strings.TrimSpace(chunk.Content) || chunk.Ordinal != i {
+			t.Fatalf("unexpected chunk %d: %+v", i, chunk)
+		}
+	}
+}
+
+func TestChunkMixedLanguageText(t *testing.T) {
+	for _, size := range []int{10, 25, 60, 200} {
+		chunks, err := New(WithChunkSize(size), WithOverlap(0)).Chunk(context.Background(), document.Document{ID: "doc", Content: mixed})
+		if err != nil {
+			t.Fatalf("Chunk failed: %v", err)
+		}
+		checkChunks(t, mixed, chunks, size, utf8.RuneCountInString)
+
+		// Without overlap the chunks cover the whole text in order.
+		var joined strings.Builder
+		for _, chunk := range chunks {
+			joined.WriteString(chunk.Content)
+		}
+		if strings.Join(strings.Fields(joined.String()), "") != strings.Join(strings.Fields(mixed), "") {
+			t.Fatalf("size %d: chunks do not cover the text: %q", size, joined.String())
+		}
+	}
+}
+
+func TestChunkPrefersCoarseSeparators(t *testing.T) {
+	chunks, err := New(WithChunkSize(120), WithOverlap(0)).Chunk(context.Background(), document.Document{ID: "doc", Content: mixed})
+	if err != nil {
+		t.Fatalf("Chunk failed: %v", err)
+	}
+	want := []string{
+		"Retrieval augmented generation grounds answers in documents. It needs good chunks.",
+		"检索增强生成会先检索相关文档，再把它们交给大模型回答问题。分块质量直接影响召回效果。\nChunks should keep sentences together. 中文句子之间通常没有空格。\n\nThe end.",
+	}
+	if len(chunks) != len(want) {
+		t.Fatalf("expected %d chunks, got %+v", len(want), chunks)
+	}
+	for i := range want {
+		if chunks[i].Content != want[i] {
+			t.Fatalf("chunk %d: expected %q, got %q", i, want[i], chunks[i].Content)
+		}
+	}
+}
+
+func TestChunkOverlap(t *testing.T) {
+	content := "one two three four five six seven eight nine ten"
+	chunks, err := New(WithChunkSize(15), WithOverlap(6), WithPropagateMetadata(false)).Chunk(context.Background(), document.Document{ID: "doc", Content: content})
+	if err != nil {
+		t.Fatalf("Chunk failed: %v", err)
+	}
+	checkChunks(t, content, chunks, 15, utf8.RuneCountInString)
+	for i := 1; i < len(chunks); i++ {
+		if chunks[i].StartRune >= chunks[i-1].EndRune {
+			t.Fatalf("chunks %d and %d do not overlap: %+v", i-1, i, chunks)
+		}
+		if chunks[i].Metadata != nil {
+			t.Fatalf("expected no metadata, got %v", chunks[i].Metadata)
+		}
+	}
+	if chunks[0].Content != "one two three" || chunks[1].Content != "three four" {
+		t.Fatalf("unexpected chunks %q, %q", chunks[0].Content, chunks[1].Content)
+	}
+}
+
+func TestChunkKeepsOversizedAtomicToken(t *testing.T) {
+	long := strings.Repeat("x", 30)
+	content := "short words " + long + " 汉字"
+	chunks, err := New(WithChunkSize(12), WithOverlap(0)).Chunk(context.Background(), document.Document{ID: "doc", Content: content})
+	if err != nil {
+		t.Fatalf("Chunk failed: %v", err)
+	}
+	var got []string
+	for _, chunk := range chunks {
+		got = append(got, chunk.Content)
+	}
+	if strings.Join(got, "|") != "short words|"+long+"|汉字" {
+		t.Fatalf("unexpected chunks %q", got)
+	}
+}
+
+func TestChunkMeasuresTokens(t *testing.T) {
+	tk := tokenizer.NewSimpleTokenizer()
+	doc := document.Document{ID: "doc", Content: mixed, Source: "kb.md", Metadata: map[string]any{"lang": "mixed"}}
+	chunks, err := New(WithChunkSize(12), WithOverlap(3), WithTokenizer(tk), WithSeparators("\n\n", "\n", "。", ". ", " ")).Chunk(context.Background(), doc)
+	if err != nil {
+		t.Fatalf("Chunk failed: %v", err)
+	}
+	checkChunks(t, mixed, chunks, 12, tk.CountTokens)
+	for i, chunk := range chunks {
+		if chunk.TokenCount != tk.CountTokens(chunk.Content) || chunk.Metadata[chunking.ChunkIndexKey] != i ||
+			chunk.Metadata["source"] != "kb.md" || chunk.Metadata["lang"] != "mixed" {
+			t.Fatalf("unexpected chunk %d: %+v", i, chunk)
+		}
+	}
+}
@@ -0,0 +1,325 @@
+package semantic
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/sweetpotato0/ai-allin/rag/chunking"
+	"github.com/sweetpotato0/ai-allin/rag/document"
+	"github.com/sweetpotato0/ai-allin/rag/tokenizer"
+	"github.com/sweetpotato0/ai-allin/vector"
+)
+
+var _ chunking.Chunker = (*Chunker)(nil)
+
+// Chunker splits documents at semantic boundaries. It embeds every sentence and
+// starts a new chunk where the cosine similarity between adjacent sentences
+// drops below the threshold or the chunk would exceed the token budget.
+// Sentences longer than the budget on their own are split into token windows.
+type Chunker struct {
+	embedder  vector.Embedder
+	threshold float32
+	maxTokens int
+	propagate bool
+	tk        tokenizer.Tokenizer
+	fallback  chunking.Chunker
+}
+
+// Option customizes the semantic chunker.
+type Option func(*Chunker)
+
+// WithThreshold sets the similarity below which adjacent sentences are split
+// into different chunks. Values outside (0, 1] are ignored.
+func WithThreshold(threshold float32) Option {
+	return func(c *Chunker) {
+		if threshold > 0 && threshold <= 1 {
+			c.threshold = threshold
+		}
+	}
+}
+
+// WithMaxTokens caps the size of each chunk.
+func WithMaxTokens(maxTokens int) Option {
+	return func(c *Chunker) {
+		if maxTokens > 0 {
+			c.maxTokens = maxTokens
+		}
+	}
+}
+
+// WithTokenizer sets the tokenizer used to measure chunk size.
+func WithTokenizer(t tokenizer.Tokenizer) Option {
+	return func(c *Chunker) {
+		if t != nil {
+			c.tk = t
+		}
+	}
+}
+
+// WithFallback sets the chunker used for sentences that exceed the token budget
+// on their own. By default such sentences are cut into windows of maxTokens tokens.
+func WithFallback(ch chunking.Chunker) Option {
+	return func(c *Chunker) {
+		if ch != nil {
+			c.fallback = ch
+		}
+	}
+}
+
+// WithPropagateMetadata controls whether each chunk receives the metadata built by
+// chunking.ChunkMetadata. Enabled by default.
+func WithPropagateMetadata(enabled bool) Option {
+	return func(c *Chunker) {
+		c.propagate = enabled
+	}
+}
+
+// New constructs a semantic chunker that embeds sentences with embedder.
+func New(embedder vector.Embedder, opts ...Option) *Chunker {
+	c := &Chunker{
+		embedder:  embedder,
+		threshold: 0.75,
+		maxTokens: 512,
+		propagate: true,
+		tk:        tokenizer.NewSimpleTokenizer(),
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// sentence is a span of the document content.
+type sentence struct {
+	text      string
+	startRune int
+	endRune   int
+	tokens    int
+}
+
+// Chunk splits the document into semantically coherent chunks.
+// Every chunk records StartRune/EndRune, the half-open rune range of doc.Content it was cut from.
+func (c *Chunker) Chunk(ctx context.Context, doc document.Document) ([]document.Chunk, error) {
+	sentences := splitSentences(doc.Content)
+	if len(sentences) == 0 {
+		return nil, nil
+	}
+	texts := make([]string, len(sentences))
+	for i := range sentences {
+		sentences[i].tokens = c.tk.CountTokens(sentences[i].text)
+		texts[i] = sentences[i].text
+	}
+	vecs, err := c.embedder.EmbedBatch(ctx, texts)
+	if err != nil {
+		return nil, fmt.Errorf("embed sentences: %w", err)
+	}
+	if len(vecs) != len(sentences) {
+		return nil, fmt.Errorf("embed sentences: got %d vectors for %d sentences", len(vecs), len(sentences))
+	}
+
+	var (
+		chunks []document.Chunk
+		group  []sentence
+		tokens int
+	)
+	flush := func() {
+		if len(group) == 0 {
+			return
+		}
+		chunks = append(chunks, c.newChunk(doc, group, tokens))
+		group, tokens = nil, 0
+	}
+	for i, s := range sentences {
+		if s.tokens > c.maxTokens {
+			flush()
+			split, err := c.splitOversized(ctx, doc, s)
+			if err != nil {
+				return nil, err
+			}
+			chunks = append(chunks, split...)
+			continue
+		}
+		if len(group) > 0 && (tokens+s.tokens > c.maxTokens || similarity(vecs[i-1], vecs[i]) < c.threshold) {
+			flush()
+		}
+		group = append(group, s)
+		tokens += s.tokens
+	}
+	flush()
+
+	for i := range chunks {
+		chunks[i].Ordinal = i
+		if c.propagate {
+			chunks[i].Metadata = chunking.ChunkMetadata(doc, i)
+		}
+	}
+	return chunks, nil
+}
+
+// newChunk joins the sentences of a group into a chunk, keeping the source text between them.
+func (c *Chunker) newChunk(doc document.Document, group []sentence, tokens int) document.Chunk {
+	first, last := group[0], group[len(group)-1]
+	return document.Chunk{
+		ID:         document.GenChunkID("", doc.ID),
+		DocumentID: doc.ID,
+		Content:    runeSlice(doc.Content, first.startRune, last.endRune),
+		StartRune:  first.startRune,
+		EndRune:    last.endRune,
+		TokenCount: tokens,
+	}
+}
+
+// splitOversized cuts a sentence larger than the token budget with the fallback
+// chunker and shifts the resulting offsets into the document.
+func (c *Chunker) splitOversized(ctx context.Context, doc document.Document, s sentence) ([]document.Chunk, error) {
+	if c.fallback == nil {
+		return c.splitTokens(doc, s), nil
+	}
+	parts, err := c.fallback.Chunk(ctx, document.Document{ID: doc.ID, Content: s.text})
+	if err != nil {
+		return nil, fmt.Errorf("split oversized sentence: %w", err)
+	}
+	for i := range parts {
+		parts[i].ID = document.GenChunkID("", doc.ID)
+		parts[i].DocumentID = doc.ID
+		parts[i].Section = ""
+		parts[i].StartRune += s.startRune
+		parts[i].EndRune += s.startRune
+	}
+	return parts, nil
+}
+
+// splitTokens cuts a sentence into consecutive windows of at most maxTokens tokens.
+// Windows are sliced from the source text when every token can be located in it;
+// otherwise the decoded tokens are used and the window spans the whole sentence.
+func (c *Chunker) splitTokens(doc document.Document, s sentence) []document.Chunk {
+	ids := c.tk.Encode(s.text)
+	spans := c.tokenSpans(s.text, ids)
+	var out []document.Chunk
+	for i := 0; i < len(ids); i += c.maxTokens {
+		end := min(i+c.maxTokens, len(ids))
+		chunk := document.Chunk{
+			ID:         document.GenChunkID("", doc.ID),
+			DocumentID: doc.ID,
+			Content:    c.tk.DecodeIds(ids[i:end]),
+			StartRune:  s.startRune,
+			EndRune:    s.endRune,
+			TokenCount: end - i,
+		}
+		if spans != nil {
+			from, to := spans[i][0], spans[end-1][1]
+			chunk.Content = s.text[from:to]
+			chunk.StartRune = s.startRune + utf8.RuneCountInString(s.text[:from])
+			chunk.EndRune = chunk.StartRune + utf8.RuneCountInString(chunk.Content)
+		}
+		out = append(out, chunk)
+	}
+	return out
+}
+
+// tokenSpans locates each token in text and returns its byte range, or nil when
+// a decoded token cannot be found, e.g. for tokenizers that normalize their output.
+func (c *Chunker) tokenSpans(text string, ids []int) [][2]int {
+	spans := make([][2]int, len(ids))
+	cursor := 0
+	for i, id := range ids {
+		tok := c.tk.DecodeIds([]int{id})
+		idx := strings.Index(text[cursor:], tok)
+		if tok == "" || idx < 0 {
+			return nil
+		}
+		spans[i] = [2]int{cursor + idx, cursor + idx + len(tok)}
+		cursor = spans[i][1]
+	}
+	return spans
+}
+
+// similarity returns the cosine similarity of a and b, or 0 when either is
+// empty, zero or their dimensions differ.
+func similarity(a, b []float32) float32 {
+	if len(a) != len(b) {
+		return 0
+	}
+	var dot, normA, normB float64
+	for i := range a {
+		dot += float64(a[i]) * float64(b[i])
+		normA += float64(a[i]) * float64(a[i])
+		normB += float64(b[i]) * float64(b[i])
+	}
+	if normA == 0 || normB == 0 {
+		return 0
+	}
+	return float32(dot / math.Sqrt(normA*normB))
+}
+
+// splitSentences splits text after sentence terminators (including the CJK
+// full-width forms) and at blank lines, dropping surrounding whitespace.
+func splitSentences(text string) []sentence {
+	var out []sentence
+	start, startRune := -1, 0
+	runeIdx := 0
+	emit := func(end, endRune int) {
+		if start < 0 {
+			return
+		}
+		seg := text[start:end]
+		trimmed := strings.TrimRightFunc(seg, unicode.IsSpace)
+		if trimmed != "" {
+			out = append(out, sentence{
+				text:      trimmed,
+				startRune: startRune,
+				endRune:   endRune - (utf8.RuneCountInString(seg) - utf8.RuneCountInString(trimmed)),
+			})
+		}
+		start = -1
+	}
+	for i, r := range text {
+		if start < 0 && !unicode.IsSpace(r) {
+			start, startRune = i, runeIdx
+		}
+		runeIdx++
+		next := i + utf8.RuneLen(r)
+		switch {
+		case isCJKTerminator(r):
+			emit(next, runeIdx)
+		case r == '.' || r == '!' || r == '?':
+			if after, _ := utf8.DecodeRuneInString(text[next:]); next == len(text) || unicode.IsSpace(after) {
+				emit(next, runeIdx)
+			}
+		case r == '\n':
+			if strings.HasPrefix(text[next:], "\n") {
+				emit(next, runeIdx)
+			}
+		}
+	}
+	emit(len(text), runeIdx)
+	return out
+}
+
+func isCJKTerminator(r rune) bool {
+	switch r {
+	case '。', '！', '？', '；':
+		return true
+	}
+	return false
+}
+
+// runeSlice returns the runes of s in the half-open range [start, end).
+func runeSlice(s string, start, end int) string {
+	i, from, to := 0, len(s), len(s)
+	for pos := range s {
+		if i == start {
+			from = pos
+		}
+		if i == end {
+			to = pos
+			break
+		}
+		i++
+	}
+	return s[from:to]
+}
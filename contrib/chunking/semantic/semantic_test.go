@@ -0,0 +1,108 @@
+package semantic
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/sweetpotato0/ai-allin/contrib/retrieval/hybrid"
+	"github.com/sweetpotato0/ai-allin/contrib/vector/inmemory"
+	"github.com/sweetpotato0/ai-allin/rag/chunking"
+	"github.com/sweetpotato0/ai-allin/rag/document"
+	"github.com/sweetpotato0/ai-allin/rag/embedder"
+	"github.com/sweetpotato0/ai-allin/rag/tokenizer"
+)
+
+// topicEmbedder maps text onto one axis per topic keyword.
+type topicEmbedder struct{}
+
+var topics = [][]string{{"cat", "猫"}, {"rust", "编译"}}
+
+func (topicEmbedder) Embed(ctx context.Context, text string) ([]float32, error) {
+	vec := make([]float32, len(topics)+1)
+	for i, words := range topics {
+		for _, w := range words {
+			vec[i] += float32(strings.Count(strings.ToLower(text), w))
+		}
+	}
+	vec[len(topics)] = 0.01
+	return vec, nil
+}
+
+func (e topicEmbedder) EmbedBatch(ctx context.Context, texts []string) ([][]float32, error) {
+	out := make([][]float32, len(texts))
+	for i, text := range texts {
+		out[i], _ = e.Embed(ctx, text)
+	}
+	return out, nil
+}
+
+func (topicEmbedder) Dimension() int { return len(topics) + 1 }
+
+func TestChunkSplitsAtTopicShifts(t *testing.T) {
+	content := "Cats sleep a lot. A cat purrs when happy. 猫喜欢晒太阳。\n\nRust has a strict compiler. 编译器会检查借用。Rust avoids data races!"
+	doc := document.Document{ID: "doc", Content: content, Metadata: map[string]any{"lang": "mixed"}}
+
+	chunks, err := New(topicEmbedder{}).Chunk(context.Background(), doc)
+	if err != nil {
+		t.Fatalf("Chunk failed: %v", err)
+	}
+	if len(chunks) != 2 {
+		t.Fatalf("expected 2 chunks, got %d: %+v", len(chunks), chunks)
+	}
+	if chunks[0].Content != "Cats sleep a lot. A cat purrs when happy. 猫喜欢晒太阳。" || chunks[1].Content != "Rust has a strict compiler. 编译器会检查借用。Rust avoids data races!" {
+		t.Fatalf("unexpected chunk contents %q / %q", chunks[0].Content, chunks[1].Content)
+	}
+	runes := []rune(content)
+	for i, chunk := range chunks {
+		if string(runes[chunk.StartRune:chunk.EndRune]) != chunk.Content {
+			t.Fatalf("chunk %d offsets [%d,%d) do not match its content", i, chunk.StartRune, chunk.EndRune)
+		}
+		if chunk.Ordinal != i || chunk.Metadata[chunking.ChunkIndexKey] != i || chunk.Metadata["lang"] != "mixed" || chunk.DocumentID != "doc" {
+			t.Fatalf("unexpected chunk %d: %+v", i, chunk)
+		}
+	}
+}
+
+func TestChunkRespectsMaxTokens(t *testing.T) {
+	long := strings.Repeat("cat ", 30)
+	content := "The cat naps. The cat eats. The cat plays. " + long + "end."
+	chunks, err := New(topicEmbedder{}, WithMaxTokens(8)).Chunk(context.Background(), document.Document{ID: "doc", Content: content})
+	if err != nil {
+		t.Fatalf("Chunk failed: %v", err)
+	}
+	tk := tokenizer.NewSimpleTokenizer()
+	runes := []rune(content)
+	for i, chunk := range chunks {
+		if n := tk.CountTokens(chunk.Content); n > 8 {
+			t.Fatalf("chunk %d has %d tokens: %q", i, n, chunk.Content)
+		}
+		if string(runes[chunk.StartRune:chunk.EndRune]) != chunk.Content {
+			t.Fatalf("chunk %d offsets do not match its content %q", i, chunk.Content)
+		}
+	}
+	if len(chunks) < 5 || chunks[0].Content != "The cat naps. The cat eats." {
+		t.Fatalf("expected the long sentence to be split by the fallback chunker, got %+v", chunks)
+	}
+}
+
+func TestChunkerPlugsIntoHybridEngine(t *testing.T) {
+	ctx := context.Background()
+	emb := topicEmbedder{}
+	engine, err := hybrid.New(inmemory.NewInMemoryVectorStore(), tokenizer.NewSimpleTokenizer(), embedder.NewVectorAdapter(emb),
+		hybrid.WithChunker(New(emb)))
+	if err != nil {
+		t.Fatalf("hybrid.New failed: %v", err)
+	}
+	err = engine.IndexDocuments(ctx, document.Document{ID: "doc", Content: "Cats purr. A cat sleeps.\n\nRust compiles fast. The rust compiler is strict."})
+	if err != nil {
+		t.Fatalf("IndexDocuments failed: %v", err)
+	}
+	results, err := engine.Search(ctx, "rust compiler")
+	if err != nil || len(results) == 0 {
+		t.Fatalf("Search failed: %v", err)
+	}
+	if !strings.HasPrefix(results[0].Chunk.Content, "Rust compiles fast.") {
+		t.Fatalf("expected the rust chunk first, got %+v", results[0].Chunk)
+	}
+}
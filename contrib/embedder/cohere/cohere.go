@@ -0,0 +1,175 @@
+package cohere
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/sweetpotato0/ai-allin/agent"
+	"github.com/sweetpotato0/ai-allin/vector"
+)
+
+// DefaultBaseURL is the Cohere API endpoint used when no base URL is given.
+const DefaultBaseURL = "https://api.cohere.com"
+
+// MaxBatchSize is the largest number of texts Cohere accepts per embed request.
+const MaxBatchSize = 96
+
+// InputType tells Cohere how the embedded text will be used.
+type InputType string
+
+const (
+	// InputTypeSearchDocument is for texts stored in a vector database.
+	InputTypeSearchDocument InputType = "search_document"
+	// InputTypeSearchQuery is for search queries run against stored documents.
+	InputTypeSearchQuery InputType = "search_query"
+	// InputTypeClassification is for texts passed to a classifier.
+	InputTypeClassification InputType = "classification"
+	// InputTypeClustering is for texts that will be clustered.
+	InputTypeClustering InputType = "clustering"
+)
+
+// CohereEmbedder implements vector.Embedder by using Cohere's v2 embed API.
+type CohereEmbedder struct {
+	client    *http.Client
+	apiKey    string
+	baseURL   string
+	model     string
+	inputType InputType
+	dimension int
+}
+
+// New create CohereEmbedder. Use InputTypeSearchDocument when indexing and
+// InputTypeSearchQuery for the embedder that encodes queries; an empty input
+// type defaults to InputTypeSearchDocument.
+func New(apiKey, baseURL, model string, inputType InputType, dimension int) vector.Embedder {
+	if strings.TrimSpace(baseURL) == "" {
+		baseURL = DefaultBaseURL
+	}
+	if inputType == "" {
+		inputType = InputTypeSearchDocument
+	}
+	return &CohereEmbedder{
+		client:    &http.Client{Timeout: 30 * time.Second},
+		apiKey:    apiKey,
+		baseURL:   strings.TrimRight(baseURL, "/"),
+		model:     model,
+		inputType: inputType,
+		dimension: dimension,
+	}
+}
+
+// Dimension return number of embedding dimensions
+func (e *CohereEmbedder) Dimension() int {
+	return e.dimension
+}
+
+// Embed converts text to a vector embedding
+func (e *CohereEmbedder) Embed(ctx context.Context, text string) ([]float32, error) {
+	vectors, err := e.embedBatch(ctx, []string{text})
+	if err != nil {
+		return nil, err
+	}
+	if len(vectors) == 0 {
+		return nil, errors.New("no embedding returned")
+	}
+	return vectors[0], nil
+}
+
+// EmbedBatch converts multiple texts to embeddings, splitting them into
+// requests of at most MaxBatchSize texts.
+func (e *CohereEmbedder) EmbedBatch(ctx context.Context, texts []string) ([][]float32, error) {
+	out := make([][]float32, 0, len(texts))
+	for start := 0; start < len(texts); start += MaxBatchSize {
+		end := min(start+MaxBatchSize, len(texts))
+		vectors, err := e.embedBatch(ctx, texts[start:end])
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, vectors...)
+	}
+	return out, nil
+}
+
+type embedRequest struct {
+	Model          string    `json:"model"`
+	Texts          []string  `json:"texts"`
+	InputType      InputType `json:"input_type"`
+	EmbeddingTypes []string  `json:"embedding_types"`
+}
+
+type embedResponse struct {
+	Embeddings struct {
+		Float [][]float64 `json:"float"`
+	} `json:"embeddings"`
+	Message string `json:"message"`
+}
+
+func (e *CohereEmbedder) embedBatch(ctx context.Context, texts []string) ([][]float32, error) {
+	if len(texts) == 0 {
+		return nil, nil
+	}
+
+	body, err := json.Marshal(embedRequest{
+		Model:          e.model,
+		Texts:          texts,
+		InputType:      e.inputType,
+		EmbeddingTypes: []string{"float"},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("encode embed request: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, e.baseURL+"/v2/embed", bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("create embeddings: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("Authorization", "Bearer "+e.apiKey)
+
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("create embeddings: %w", agent.NewAPIError("cohere", 0, err))
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("read embed response: %w", err)
+	}
+
+	var decoded embedResponse
+	decodeErr := json.Unmarshal(data, &decoded)
+	if resp.StatusCode >= http.StatusBadRequest {
+		msg := decoded.Message
+		if decodeErr != nil || msg == "" {
+			msg = http.StatusText(resp.StatusCode)
+		}
+		return nil, fmt.Errorf("create embeddings: %w", agent.NewAPIError("cohere", resp.StatusCode, errors.New(msg)))
+	}
+	if decodeErr != nil {
+		return nil, fmt.Errorf("decode embed response: %w", decodeErr)
+	}
+	if len(decoded.Embeddings.Float) != len(texts) {
+		return nil, fmt.Errorf("expected %d embeddings, got %d", len(texts), len(decoded.Embeddings.Float))
+	}
+
+	out := make([][]float32, len(decoded.Embeddings.Float))
+	for i, emb := range decoded.Embeddings.Float {
+		out[i] = convertVector(emb, e.dimension)
+	}
+	return out, nil
+}
+
+func convertVector(input []float64, expected int) []float32 {
+	vec := make([]float32, expected)
+	for i := 0; i < len(input) && i < expected; i++ {
+		vec[i] = float32(input[i])
+	}
+	return vec
+}
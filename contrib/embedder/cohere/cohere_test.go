@@ -0,0 +1,69 @@
+package cohere
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/sweetpotato0/ai-allin/agent"
+)
+
+func TestEmbedBatchChunksRequests(t *testing.T) {
+	var batches []embedRequest
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v2/embed" || r.Header.Get("Authorization") != "Bearer key" {
+			t.Errorf("unexpected request %s %v", r.URL.Path, r.Header)
+		}
+		var req embedRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Errorf("decode request: %v", err)
+		}
+		batches = append(batches, req)
+		vectors := make([][]float64, len(req.Texts))
+		for i, text := range req.Texts {
+			var n float64
+			fmt.Sscanf(text, "text-%g", &n)
+			vectors[i] = []float64{n, 1}
+		}
+		json.NewEncoder(w).Encode(map[string]any{"embeddings": map[string]any{"float": vectors}})
+	}))
+	defer srv.Close()
+
+	embedder := New("key", srv.URL, "embed-english-v3.0", InputTypeSearchQuery, 2)
+	texts := make([]string, MaxBatchSize+4)
+	for i := range texts {
+		texts[i] = fmt.Sprintf("text-%d", i)
+	}
+	vectors, err := embedder.EmbedBatch(context.Background(), texts)
+	if err != nil {
+		t.Fatalf("EmbedBatch failed: %v", err)
+	}
+
+	if len(batches) != 2 || len(batches[0].Texts) != MaxBatchSize || len(batches[1].Texts) != 4 {
+		t.Fatalf("expected batches of %d and 4 texts, got %d batches", MaxBatchSize, len(batches))
+	}
+	if batches[0].Model != "embed-english-v3.0" || batches[0].InputType != InputTypeSearchQuery || batches[0].EmbeddingTypes[0] != "float" {
+		t.Fatalf("unexpected request %+v", batches[0])
+	}
+	if len(vectors) != len(texts) || vectors[MaxBatchSize+3][0] != float32(MaxBatchSize+3) || embedder.Dimension() != 2 {
+		t.Fatalf("unexpected vectors %v", vectors[len(vectors)-1])
+	}
+}
+
+func TestEmbedReportsAPIErrors(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTooManyRequests)
+		fmt.Fprint(w, `{"message":"trial key rate limit"}`)
+	}))
+	defer srv.Close()
+
+	_, err := New("key", srv.URL, "embed-english-v3.0", "", 2).Embed(context.Background(), "hi")
+	var apiErr *agent.APIError
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusTooManyRequests || apiErr.Err.Error() != "trial key rate limit" || !agent.IsRetriable(err) {
+		t.Fatalf("expected retriable API error with server message, got %v", err)
+	}
+}
@@ -6,21 +6,71 @@ import (
 	"sort"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/sweetpotato0/ai-allin/memory"
+	errorskg "github.com/sweetpotato0/ai-allin/pkg/errors"
+	"github.com/sweetpotato0/ai-allin/pkg/id"
 )
 
 // InMemoryStore implements MemoryStore using in-memory storage
 type InMemoryStore struct {
 	memories []*memory.Memory
-	mu       sync.RWMutex
+	idGen    id.Generator
+	mu       sync.Mutex
+
+	ttl        time.Duration
+	maxEntries int
+	now        func() time.Time
+	lastUsed   map[*memory.Memory]uint64 // use sequence for LRU eviction
+	tick       uint64
+}
+
+// Option configures an InMemoryStore.
+type Option func(*InMemoryStore)
+
+// WithIDGenerator sets the generator used for memories added without an ID.
+func WithIDGenerator(gen id.Generator) Option {
+	return func(s *InMemoryStore) {
+		if gen != nil {
+			s.idGen = gen
+		}
+	}
+}
+
+// WithTTL drops memories whose last write (UpdatedAt, or CreatedAt when unset)
+// is older than d. Expired memories are pruned whenever the store is accessed.
+func WithTTL(d time.Duration) Option {
+	return func(s *InMemoryStore) {
+		if d > 0 {
+			s.ttl = d
+		}
+	}
+}
+
+// WithMaxEntries caps the store at n memories, evicting the least recently used
+// one when a new memory would exceed the cap. Adding, updating and being
+// returned by SearchMemory count as use.
+func WithMaxEntries(n int) Option {
+	return func(s *InMemoryStore) {
+		if n > 0 {
+			s.maxEntries = n
+		}
+	}
 }
 
 // NewInMemoryStore creates a new in-memory memory store
-func NewInMemoryStore() *InMemoryStore {
-	return &InMemoryStore{
+func NewInMemoryStore(opts ...Option) *InMemoryStore {
+	s := &InMemoryStore{
 		memories: make([]*memory.Memory, 0),
+		idGen:    memory.DefaultIDGenerator,
+		now:      time.Now,
+		lastUsed: make(map[*memory.Memory]uint64),
+	}
+	for _, opt := range opts {
+		opt(s)
 	}
+	return s
 }
 
 // AddMemory adds a memory to the store
@@ -31,15 +81,31 @@ func (s *InMemoryStore) AddMemory(ctx context.Context, mem *memory.Memory) error
 	if mem == nil {
 		return fmt.Errorf("memory cannot be nil")
 	}
+	if mem.ID == "" {
+		mem.ID = s.idGen.New()
+	}
+	if mem.CreatedAt.IsZero() {
+		mem.CreatedAt = s.now()
+	}
+	if mem.UpdatedAt.IsZero() {
+		mem.UpdatedAt = mem.CreatedAt
+	}
 
+	s.pruneExpired()
 	s.memories = append(s.memories, mem)
+	s.touch(mem)
+	for s.maxEntries > 0 && len(s.memories) > s.maxEntries {
+		s.evictLeastRecentlyUsed()
+	}
 	return nil
 }
 
 // SearchMemory searches for memories matching the query
 func (s *InMemoryStore) SearchMemory(ctx context.Context, query string) ([]*memory.Memory, error) {
-	s.mu.RLock()
-	defer s.mu.RUnlock()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.pruneExpired()
 
 	// If query is empty, return all memories
 	if query == "" {
@@ -50,6 +116,7 @@ func (s *InMemoryStore) SearchMemory(ctx context.Context, query string) ([]*memo
 		sort.Slice(results, func(i, j int) bool {
 			return results[i].CreatedAt.After(results[j].CreatedAt)
 		})
+		s.touchAll(results)
 		return results, nil
 	}
 
@@ -58,9 +125,7 @@ func (s *InMemoryStore) SearchMemory(ctx context.Context, query string) ([]*memo
 	lowerQuery := strings.ToLower(query)
 
 	for _, mem := range s.memories {
-		// Search in content and ID
-		if strings.Contains(strings.ToLower(mem.Content), lowerQuery) ||
-			strings.Contains(strings.ToLower(mem.ID), lowerQuery) {
+		if matches(mem, lowerQuery) {
 			results = append(results, mem)
 		}
 	}
@@ -70,22 +135,148 @@ func (s *InMemoryStore) SearchMemory(ctx context.Context, query string) ([]*memo
 		return results[i].CreatedAt.After(results[j].CreatedAt)
 	})
 
+	s.touchAll(results)
+	return results, nil
+}
+
+// DeleteMemory removes the memory with the given ID
+func (s *InMemoryStore) DeleteMemory(ctx context.Context, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.pruneExpired()
+	i := s.indexOf(id)
+	if i < 0 {
+		return fmt.Errorf("memory %s: %w", id, errorskg.ErrNotFound)
+	}
+	s.removeAt(i)
+	return nil
+}
+
+// UpdateMemory replaces the content and metadata of an existing memory. The
+// store swaps in an updated copy, so memories already returned by SearchMemory
+// or ListMemories keep their previous values and can be read without locking.
+func (s *InMemoryStore) UpdateMemory(ctx context.Context, mem *memory.Memory) error {
+	if mem == nil {
+		return fmt.Errorf("memory cannot be nil")
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.pruneExpired()
+	i := s.indexOf(mem.ID)
+	if i < 0 {
+		return fmt.Errorf("memory %s: %w", mem.ID, errorskg.ErrNotFound)
+	}
+	updated := *s.memories[i]
+	updated.Content = mem.Content
+	updated.Metadata = mem.Metadata
+	updated.UpdatedAt = s.now()
+	delete(s.lastUsed, s.memories[i])
+	s.memories[i] = &updated
+	s.touch(&updated)
+	mem.CreatedAt = updated.CreatedAt
+	mem.UpdatedAt = updated.UpdatedAt
+	return nil
+}
+
+// ListMemories returns a page of memories, newest first
+func (s *InMemoryStore) ListMemories(ctx context.Context, limit, offset int) ([]*memory.Memory, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.pruneExpired()
+
+	results := make([]*memory.Memory, len(s.memories))
+	copy(results, s.memories)
+	sort.SliceStable(results, func(i, j int) bool {
+		return results[i].CreatedAt.After(results[j].CreatedAt)
+	})
+
+	offset = min(max(offset, 0), len(results))
+	results = results[offset:]
+	if limit > 0 && limit < len(results) {
+		results = results[:limit]
+	}
 	return results, nil
 }
 
+// matches reports whether the memory content or ID contains the lowercased query.
+func matches(mem *memory.Memory, lowerQuery string) bool {
+	return strings.Contains(strings.ToLower(mem.Content), lowerQuery) ||
+		strings.Contains(strings.ToLower(mem.ID), lowerQuery)
+}
+
+// touch marks mem as the most recently used memory.
+func (s *InMemoryStore) touch(mem *memory.Memory) {
+	s.tick++
+	s.lastUsed[mem] = s.tick
+}
+
+func (s *InMemoryStore) touchAll(memories []*memory.Memory) {
+	for _, mem := range memories {
+		s.touch(mem)
+	}
+}
+
+// pruneExpired drops memories older than the TTL.
+func (s *InMemoryStore) pruneExpired() {
+	if s.ttl <= 0 {
+		return
+	}
+	cutoff := s.now().Add(-s.ttl)
+	for i := len(s.memories) - 1; i >= 0; i-- {
+		mem := s.memories[i]
+		written := mem.UpdatedAt
+		if written.IsZero() {
+			written = mem.CreatedAt
+		}
+		if written.Before(cutoff) {
+			s.removeAt(i)
+		}
+	}
+}
+
+func (s *InMemoryStore) evictLeastRecentlyUsed() {
+	oldest := 0
+	for i, mem := range s.memories {
+		if s.lastUsed[mem] < s.lastUsed[s.memories[oldest]] {
+			oldest = i
+		}
+	}
+	s.removeAt(oldest)
+}
+
+func (s *InMemoryStore) removeAt(i int) {
+	delete(s.lastUsed, s.memories[i])
+	s.memories = append(s.memories[:i], s.memories[i+1:]...)
+}
+
+func (s *InMemoryStore) indexOf(id string) int {
+	for i, mem := range s.memories {
+		if mem.ID == id {
+			return i
+		}
+	}
+	return -1
+}
+
 // Clear removes all memories from the store
 func (s *InMemoryStore) Clear() error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
 	s.memories = make([]*memory.Memory, 0)
+	s.lastUsed = make(map[*memory.Memory]uint64)
 	return nil
 }
 
 // Count returns the number of memories in the store
 func (s *InMemoryStore) Count(ctx context.Context) (int, error) {
-	s.mu.RLock()
-	defer s.mu.RUnlock()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.pruneExpired()
 
 	return len(s.memories), nil
 }
@@ -0,0 +1,147 @@
+package inmemory
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/sweetpotato0/ai-allin/memory"
+	errorskg "github.com/sweetpotato0/ai-allin/pkg/errors"
+)
+
+func TestUpdateDeleteAndList(t *testing.T) {
+	ctx := context.Background()
+	store := NewInMemoryStore()
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i, content := range []string{"lives in Berlin", "likes tea", "ordered a kettle"} {
+		mem := &memory.Memory{ID: content, Content: content, CreatedAt: base.Add(time.Duration(i) * time.Hour)}
+		if err := store.AddMemory(ctx, mem); err != nil {
+			t.Fatalf("AddMemory failed: %v", err)
+		}
+	}
+
+	update := &memory.Memory{ID: "lives in Berlin", Content: "moved to Munich", Metadata: map[string]any{"source": "support"}}
+	if err := store.UpdateMemory(ctx, update); err != nil {
+		t.Fatalf("UpdateMemory failed: %v", err)
+	}
+	if !update.CreatedAt.Equal(base) || !update.UpdatedAt.After(base) {
+		t.Fatalf("expected CreatedAt to be kept and UpdatedAt refreshed, got %+v", update)
+	}
+	if results, _ := store.SearchMemory(ctx, "Munich"); len(results) != 1 || results[0].Metadata["source"] != "support" {
+		t.Fatalf("expected the updated memory to be searchable, got %+v", results)
+	}
+
+	if err := store.DeleteMemory(ctx, "likes tea"); err != nil {
+		t.Fatalf("DeleteMemory failed: %v", err)
+	}
+	all, err := store.ListMemories(ctx, 0, 0)
+	if err != nil || len(all) != 2 || all[0].ID != "ordered a kettle" || all[1].Content != "moved to Munich" {
+		t.Fatalf("unexpected listing %+v (%v)", all, err)
+	}
+	if page, _ := store.ListMemories(ctx, 1, 1); len(page) != 1 || page[0].Content != "moved to Munich" {
+		t.Fatalf("unexpected second page %+v", page)
+	}
+	if page, _ := store.ListMemories(ctx, 10, 5); len(page) != 0 {
+		t.Fatalf("expected an empty page past the end, got %+v", page)
+	}
+
+	if err := store.DeleteMemory(ctx, "likes tea"); !errors.Is(err, errorskg.ErrNotFound) {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+	if err := store.UpdateMemory(ctx, &memory.Memory{ID: "missing"}); !errors.Is(err, errorskg.ErrNotFound) {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+}
+
+func TestTTLExpiresOldMemories(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	store := NewInMemoryStore(WithTTL(time.Hour))
+	store.now = func() time.Time { return now }
+
+	store.AddMemory(ctx, &memory.Memory{ID: "old", Content: "customer address"})
+	now = now.Add(40 * time.Minute)
+	store.AddMemory(ctx, &memory.Memory{ID: "new", Content: "customer phone"})
+
+	now = now.Add(30 * time.Minute)
+	results, _ := store.SearchMemory(ctx, "customer")
+	if len(results) != 1 || results[0].ID != "new" {
+		t.Fatalf("expected only the unexpired memory, got %+v", results)
+	}
+	if count, _ := store.Count(ctx); count != 1 {
+		t.Fatalf("expected the expired memory to be pruned, got count %d", count)
+	}
+
+	// Updating a memory restarts its TTL.
+	now = now.Add(20 * time.Minute)
+	if err := store.UpdateMemory(ctx, &memory.Memory{ID: "new", Content: "customer mobile"}); err != nil {
+		t.Fatalf("UpdateMemory failed: %v", err)
+	}
+	now = now.Add(50 * time.Minute)
+	if all, _ := store.ListMemories(ctx, 0, 0); len(all) != 1 {
+		t.Fatalf("expected the updated memory to survive, got %+v", all)
+	}
+	now = now.Add(11 * time.Minute)
+	if all, _ := store.ListMemories(ctx, 0, 0); len(all) != 0 {
+		t.Fatalf("expected every memory to have expired, got %+v", all)
+	}
+}
+
+func TestMaxEntriesEvictsLeastRecentlyUsed(t *testing.T) {
+	ctx := context.Background()
+	store := NewInMemoryStore(WithMaxEntries(2))
+
+	store.AddMemory(ctx, &memory.Memory{ID: "a", Content: "likes tea"})
+	store.AddMemory(ctx, &memory.Memory{ID: "b", Content: "lives in Berlin"})
+	// Recalling "a" makes "b" the least recently used memory.
+	if results, _ := store.SearchMemory(ctx, "tea"); len(results) != 1 {
+		t.Fatalf("expected to recall a, got %+v", results)
+	}
+	store.AddMemory(ctx, &memory.Memory{ID: "c", Content: "ordered a kettle"})
+
+	all, _ := store.ListMemories(ctx, 0, 0)
+	ids := make(map[string]bool)
+	for _, mem := range all {
+		ids[mem.ID] = true
+	}
+	if len(all) != 2 || !ids["a"] || !ids["c"] {
+		t.Fatalf("expected b to be evicted, got %+v", all)
+	}
+}
+
+func TestUpdateDoesNotMutateReturnedMemories(t *testing.T) {
+	ctx := context.Background()
+	store := NewInMemoryStore()
+	if err := store.AddMemory(ctx, &memory.Memory{ID: "m", Content: "v0"}); err != nil {
+		t.Fatalf("AddMemory failed: %v", err)
+	}
+	before, err := store.SearchMemory(ctx, "")
+	if err != nil {
+		t.Fatalf("SearchMemory failed: %v", err)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 1; i <= 50; i++ {
+			_ = store.UpdateMemory(ctx, &memory.Memory{ID: "m", Content: fmt.Sprintf("v%d", i)})
+		}
+	}()
+	for i := 0; i < 50; i++ {
+		listed, _ := store.ListMemories(ctx, 0, 0)
+		for _, mem := range listed {
+			_ = mem.Content // read without the store's lock
+		}
+	}
+	<-done
+
+	if before[0].Content != "v0" {
+		t.Fatalf("expected a returned memory to keep its content, got %q", before[0].Content)
+	}
+	after, _ := store.ListMemories(ctx, 0, 0)
+	if len(after) != 1 || after[0].Content != "v50" {
+		t.Fatalf("expected the latest update to be stored, got %+v", after)
+	}
+}
@@ -8,6 +8,7 @@ import (
 	"github.com/sweetpotato0/ai-allin/memory"
 	"github.com/sweetpotato0/ai-allin/pkg/env"
 	errorskg "github.com/sweetpotato0/ai-allin/pkg/errors"
+	"github.com/sweetpotato0/ai-allin/pkg/id"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
@@ -27,6 +28,7 @@ type MongoStore struct {
 	client     *mongo.Client
 	db         *mongo.Database
 	collection *mongo.Collection
+	idGen      id.Generator
 }
 
 // MongoConfig holds MongoDB connection configuration
@@ -34,6 +36,7 @@ type MongoConfig struct {
 	URI        string
 	Database   string
 	Collection string
+	IDGen      id.Generator // Generator for memories added without an ID (defaults to memory.DefaultIDGenerator)
 }
 
 // DefaultMongoConfig returns default MongoDB configuration
@@ -81,6 +84,10 @@ func NewMongoStore(config *MongoConfig) (*MongoStore, error) {
 		client:     client,
 		db:         db,
 		collection: collection,
+		idGen:      config.IDGen,
+	}
+	if store.idGen == nil {
+		store.idGen = memory.DefaultIDGenerator
 	}
 
 	// Create index
@@ -109,7 +116,7 @@ func (s *MongoStore) AddMemory(ctx context.Context, mem *memory.Memory) error {
 
 	// Generate ID if not provided
 	if mem.ID == "" {
-		mem.ID = fmt.Sprintf("mem:%d", time.Now().UnixNano())
+		mem.ID = s.idGen.New()
 	}
 
 	// Set timestamps
@@ -232,6 +239,68 @@ func (s *MongoStore) DeleteMemory(ctx context.Context, id string) error {
 	return nil
 }
 
+// UpdateMemory replaces the content and metadata of an existing memory
+func (s *MongoStore) UpdateMemory(ctx context.Context, mem *memory.Memory) error {
+	if mem == nil {
+		return fmt.Errorf("memory cannot be nil")
+	}
+	if mem.Metadata == nil {
+		mem.Metadata = make(map[string]any)
+	}
+	now := time.Now()
+
+	var updated mongoMemory
+	err := s.collection.FindOneAndUpdate(ctx,
+		bson.M{"_id": mem.ID},
+		bson.M{"$set": bson.M{"content": mem.Content, "metadata": mem.Metadata, "updated_at": now}},
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(&updated)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return fmt.Errorf("memory %s: %w", mem.ID, errorskg.ErrNotFound)
+		}
+		return fmt.Errorf("failed to update memory: %w", err)
+	}
+
+	mem.CreatedAt = updated.CreatedAt
+	mem.UpdatedAt = updated.UpdatedAt
+	return nil
+}
+
+// ListMemories returns a page of memories, newest first
+func (s *MongoStore) ListMemories(ctx context.Context, limit, offset int) ([]*memory.Memory, error) {
+	opts := options.Find().SetSort(bson.M{"created_at": -1})
+	if offset > 0 {
+		opts.SetSkip(int64(offset))
+	}
+	if limit > 0 {
+		opts.SetLimit(int64(limit))
+	}
+
+	cursor, err := s.collection.Find(ctx, bson.M{}, opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list memories: %w", err)
+	}
+	defer cursor.Close(ctx)
+
+	var mongoMemories []mongoMemory
+	if err := cursor.All(ctx, &mongoMemories); err != nil {
+		return nil, fmt.Errorf("failed to decode memories: %w", err)
+	}
+
+	memories := make([]*memory.Memory, len(mongoMemories))
+	for i, m := range mongoMemories {
+		memories[i] = &memory.Memory{
+			ID:        m.ID,
+			Content:   m.Content,
+			Metadata:  m.Metadata,
+			CreatedAt: m.CreatedAt,
+			UpdatedAt: m.UpdatedAt,
+		}
+	}
+	return memories, nil
+}
+
 // GetMemoryByID retrieves a specific memory by ID
 func (s *MongoStore) GetMemoryByID(ctx context.Context, id string) (*memory.Memory, error) {
 	var mongoMem mongoMemory
@@ -2,10 +2,13 @@ package mongo
 
 import (
 	"context"
+	"errors"
 	"os"
 	"testing"
+	"time"
 
 	"github.com/sweetpotato0/ai-allin/memory"
+	errorskg "github.com/sweetpotato0/ai-allin/pkg/errors"
 )
 
 // TestMongoStore tests MongoDB store functionality
@@ -149,4 +152,33 @@ func TestMongoStore(t *testing.T) {
 			t.Errorf("Expected %q, got %q", mem.Content, retrieved.Content)
 		}
 	})
+
+	t.Run("update and list memories", func(t *testing.T) {
+		ctx := context.Background()
+		store.Clear(ctx)
+
+		first := &memory.Memory{Content: "Customer lives in Berlin", CreatedAt: time.Now().Add(-time.Hour)}
+		second := &memory.Memory{Content: "Customer likes tea"}
+		store.AddMemory(ctx, first)
+		store.AddMemory(ctx, second)
+
+		first.Content = "Customer moved to Munich"
+		if err := store.UpdateMemory(ctx, first); err != nil {
+			t.Fatalf("UpdateMemory failed: %v", err)
+		}
+		if err := store.UpdateMemory(ctx, &memory.Memory{ID: "missing"}); !errors.Is(err, errorskg.ErrNotFound) {
+			t.Errorf("Expected ErrNotFound, got %v", err)
+		}
+
+		page, err := store.ListMemories(ctx, 1, 1)
+		if err != nil {
+			t.Fatalf("ListMemories failed: %v", err)
+		}
+		if len(page) != 1 || page[0].Content != "Customer moved to Munich" {
+			t.Errorf("Expected the updated older memory on the second page, got %+v", page)
+		}
+		if err := store.DeleteMemory(ctx, "missing"); !errors.Is(err, errorskg.ErrNotFound) {
+			t.Errorf("Expected ErrNotFound, got %v", err)
+		}
+	})
 }
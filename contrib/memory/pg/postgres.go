@@ -11,6 +11,7 @@ import (
 	"github.com/sweetpotato0/ai-allin/memory"
 	"github.com/sweetpotato0/ai-allin/pkg/env"
 	errorskg "github.com/sweetpotato0/ai-allin/pkg/errors"
+	"github.com/sweetpotato0/ai-allin/pkg/id"
 )
 
 // PostgresConfigFromEnv loads PostgreSQL configuration from environment variables
@@ -27,7 +28,8 @@ func PostgresConfigFromEnv() *PostgresConfig {
 
 // PostgresStore implements MemoryStore using PostgreSQL
 type PostgresStore struct {
-	db *sql.DB
+	db    *sql.DB
+	idGen id.Generator
 }
 
 // PostgresConfig holds PostgreSQL connection configuration
@@ -38,6 +40,7 @@ type PostgresConfig struct {
 	Password string
 	DBName   string
 	SSLMode  string
+	IDGen    id.Generator // Generator for memories added without an ID (defaults to memory.DefaultIDGenerator)
 }
 
 // DefaultPostgresConfig returns default PostgreSQL configuration
@@ -83,7 +86,10 @@ func NewPostgresStore(config *PostgresConfig) (*PostgresStore, error) {
 		return nil, fmt.Errorf("failed to ping PostgreSQL: %w", err)
 	}
 
-	store := &PostgresStore{db: db}
+	store := &PostgresStore{db: db, idGen: config.IDGen}
+	if store.idGen == nil {
+		store.idGen = memory.DefaultIDGenerator
+	}
 
 	// Create table and indexes with timeout
 	if err := store.createTable(ctx); err != nil {
@@ -121,7 +127,7 @@ func (s *PostgresStore) AddMemory(ctx context.Context, mem *memory.Memory) error
 
 	// Generate ID if not provided
 	if mem.ID == "" {
-		mem.ID = memory.GenerateMemoryID()
+		mem.ID = s.idGen.New()
 	}
 
 	// Set timestamps
@@ -275,13 +281,86 @@ func (s *PostgresStore) Ping(ctx context.Context) error {
 
 // DeleteMemory deletes a memory by ID
 func (s *PostgresStore) DeleteMemory(ctx context.Context, id string) error {
-	_, err := s.db.ExecContext(ctx, "DELETE FROM memories WHERE id = $1", id)
+	result, err := s.db.ExecContext(ctx, "DELETE FROM memories WHERE id = $1", id)
 	if err != nil {
 		return fmt.Errorf("failed to delete memory: %w", err)
 	}
+	if n, err := result.RowsAffected(); err == nil && n == 0 {
+		return fmt.Errorf("memory %s: %w", id, errorskg.ErrNotFound)
+	}
+	return nil
+}
+
+// UpdateMemory replaces the content and metadata of an existing memory
+func (s *PostgresStore) UpdateMemory(ctx context.Context, mem *memory.Memory) error {
+	if mem == nil {
+		return fmt.Errorf("memory cannot be nil")
+	}
+
+	metadataJSON := []byte("{}")
+	if len(mem.Metadata) > 0 {
+		var err error
+		metadataJSON, err = json.Marshal(mem.Metadata)
+		if err != nil {
+			return fmt.Errorf("failed to marshal metadata: %w", err)
+		}
+	}
+
+	err := s.db.QueryRowContext(ctx,
+		`UPDATE memories SET content = $2, metadata = $3, updated_at = $4
+		 WHERE id = $1
+		 RETURNING created_at, updated_at`,
+		mem.ID, mem.Content, string(metadataJSON), time.Now(),
+	).Scan(&mem.CreatedAt, &mem.UpdatedAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return fmt.Errorf("memory %s: %w", mem.ID, errorskg.ErrNotFound)
+		}
+		return fmt.Errorf("failed to update memory: %w", err)
+	}
 	return nil
 }
 
+// ListMemories returns a page of memories, newest first
+func (s *PostgresStore) ListMemories(ctx context.Context, limit, offset int) ([]*memory.Memory, error) {
+	// A NULL limit means no limit in PostgreSQL.
+	var pageLimit sql.NullInt64
+	if limit > 0 {
+		pageLimit = sql.NullInt64{Int64: int64(limit), Valid: true}
+	}
+
+	rows, err := s.db.QueryContext(ctx,
+		`SELECT id, content, metadata, created_at, updated_at
+		 FROM memories
+		 ORDER BY created_at DESC
+		 LIMIT $1 OFFSET $2`,
+		pageLimit, max(offset, 0))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list memories: %w", err)
+	}
+	defer rows.Close()
+
+	memories := make([]*memory.Memory, 0)
+	for rows.Next() {
+		mem := &memory.Memory{}
+		var metadataJSON string
+		if err := rows.Scan(&mem.ID, &mem.Content, &metadataJSON, &mem.CreatedAt, &mem.UpdatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan memory: %w", err)
+		}
+		mem.Metadata = make(map[string]any)
+		if metadataJSON != "" && metadataJSON != "{}" {
+			if err := json.Unmarshal([]byte(metadataJSON), &mem.Metadata); err != nil {
+				return nil, fmt.Errorf("failed to unmarshal metadata: %w", err)
+			}
+		}
+		memories = append(memories, mem)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating memories: %w", err)
+	}
+	return memories, nil
+}
+
 // GetMemoryByID retrieves a specific memory by ID
 func (s *PostgresStore) GetMemoryByID(ctx context.Context, id string) (*memory.Memory, error) {
 	mem := &memory.Memory{}
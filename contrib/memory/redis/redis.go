@@ -11,6 +11,8 @@ import (
 	"github.com/redis/go-redis/v9"
 	"github.com/sweetpotato0/ai-allin/memory"
 	"github.com/sweetpotato0/ai-allin/pkg/env"
+	errorskg "github.com/sweetpotato0/ai-allin/pkg/errors"
+	"github.com/sweetpotato0/ai-allin/pkg/id"
 )
 
 // RedisConfigFromEnv loads Redis configuration from environment variables
@@ -40,6 +42,7 @@ type RedisStore struct {
 	client *redis.Client
 	prefix string // Key prefix for namespacing
 	ttl    time.Duration
+	idGen  id.Generator
 }
 
 // RedisConfig holds Redis configuration
@@ -49,6 +52,7 @@ type RedisConfig struct {
 	DB       int           // Redis database number
 	Prefix   string        // Key prefix for namespacing
 	TTL      time.Duration // Time-to-live for keys (0 means no expiration)
+	IDGen    id.Generator  // Generator for memories added without an ID (defaults to memory.DefaultIDGenerator)
 }
 
 // NewRedisStore creates a new Redis-based memory store
@@ -61,6 +65,11 @@ func NewRedisStore(config *RedisConfig) *RedisStore {
 		}
 	}
 
+	idGen := config.IDGen
+	if idGen == nil {
+		idGen = memory.DefaultIDGenerator
+	}
+
 	client := redis.NewClient(&redis.Options{
 		Addr:     config.Addr,
 		Password: config.Password,
@@ -71,6 +80,7 @@ func NewRedisStore(config *RedisConfig) *RedisStore {
 		client: client,
 		prefix: config.Prefix,
 		ttl:    config.TTL,
+		idGen:  idGen,
 	}
 }
 
@@ -80,8 +90,11 @@ func (s *RedisStore) AddMemory(ctx context.Context, mem *memory.Memory) error {
 		return fmt.Errorf("memory cannot be nil")
 	}
 
-	// Generate a unique key
-	key := fmt.Sprintf("%smem:%d", s.prefix, time.Now().UnixNano())
+	// Generate ID if not provided
+	if mem.ID == "" {
+		mem.ID = s.idGen.New()
+	}
+	key := fmt.Sprintf("%smem:%s", s.prefix, mem.ID)
 
 	// Serialize memory to JSON
 	data, err := json.Marshal(mem)
@@ -94,15 +107,28 @@ func (s *RedisStore) AddMemory(ctx context.Context, mem *memory.Memory) error {
 		return fmt.Errorf("failed to store memory in Redis: %w", err)
 	}
 
-	// Add key to a set for easy retrieval
+	// Add key to a set for easy retrieval and to the creation-time index for paging
 	setKey := fmt.Sprintf("%sset", s.prefix)
 	if err := s.client.SAdd(ctx, setKey, key).Err(); err != nil {
 		return fmt.Errorf("failed to add memory key to set: %w", err)
 	}
+	if err := s.client.ZAdd(ctx, s.createdKey(), createdMember(key, mem.CreatedAt)).Err(); err != nil {
+		return fmt.Errorf("failed to index memory key: %w", err)
+	}
 
 	return nil
 }
 
+// createdKey names the sorted set of memory keys scored by creation time.
+func (s *RedisStore) createdKey() string {
+	return fmt.Sprintf("%screated", s.prefix)
+}
+
+func createdMember(key string, createdAt time.Time) redis.Z {
+	// Microseconds stay exact in a float64 score.
+	return redis.Z{Score: float64(createdAt.UnixMicro()), Member: key}
+}
+
 // SearchMemory searches for memories matching the query
 func (s *RedisStore) SearchMemory(ctx context.Context, query string) ([]*memory.Memory, error) {
 	// Get all memory keys from the set
@@ -124,6 +150,7 @@ func (s *RedisStore) SearchMemory(ctx context.Context, query string) ([]*memory.
 			if err == redis.Nil {
 				// Key expired or doesn't exist, remove from set
 				s.client.SRem(ctx, setKey, key)
+				s.client.ZRem(ctx, s.createdKey(), key)
 				continue
 			}
 			return nil, fmt.Errorf("failed to get memory: %w", err)
@@ -159,6 +186,165 @@ func (s *RedisStore) SearchMemory(ctx context.Context, query string) ([]*memory.
 	return memories, nil
 }
 
+// DeleteMemory deletes a memory by ID
+func (s *RedisStore) DeleteMemory(ctx context.Context, id string) error {
+	key := fmt.Sprintf("%smem:%s", s.prefix, id)
+	setKey := fmt.Sprintf("%sset", s.prefix)
+
+	deleted, err := s.client.Del(ctx, key).Result()
+	if err != nil {
+		return fmt.Errorf("failed to delete memory: %w", err)
+	}
+	if err := s.client.SRem(ctx, setKey, key).Err(); err != nil {
+		return fmt.Errorf("failed to remove memory key from set: %w", err)
+	}
+	if err := s.client.ZRem(ctx, s.createdKey(), key).Err(); err != nil {
+		return fmt.Errorf("failed to remove memory key from index: %w", err)
+	}
+	if deleted == 0 {
+		return fmt.Errorf("memory %s: %w", id, errorskg.ErrNotFound)
+	}
+	return nil
+}
+
+// UpdateMemory replaces the content and metadata of an existing memory
+func (s *RedisStore) UpdateMemory(ctx context.Context, mem *memory.Memory) error {
+	if mem == nil {
+		return fmt.Errorf("memory cannot be nil")
+	}
+	key := fmt.Sprintf("%smem:%s", s.prefix, mem.ID)
+
+	data, err := s.client.Get(ctx, key).Result()
+	if err != nil {
+		if err == redis.Nil {
+			return fmt.Errorf("memory %s: %w", mem.ID, errorskg.ErrNotFound)
+		}
+		return fmt.Errorf("failed to get memory: %w", err)
+	}
+	var stored memory.Memory
+	if err := json.Unmarshal([]byte(data), &stored); err != nil {
+		return fmt.Errorf("failed to unmarshal memory: %w", err)
+	}
+
+	stored.Content = mem.Content
+	stored.Metadata = mem.Metadata
+	stored.UpdatedAt = time.Now()
+	updated, err := json.Marshal(&stored)
+	if err != nil {
+		return fmt.Errorf("failed to marshal memory: %w", err)
+	}
+	if err := s.client.Set(ctx, key, updated, s.ttl).Err(); err != nil {
+		return fmt.Errorf("failed to store memory in Redis: %w", err)
+	}
+
+	mem.CreatedAt = stored.CreatedAt
+	mem.UpdatedAt = stored.UpdatedAt
+	return nil
+}
+
+// ListMemories returns a page of memories, newest first. Pages are read from
+// a sorted set keyed by creation time, so only the requested memories are loaded.
+func (s *RedisStore) ListMemories(ctx context.Context, limit, offset int) ([]*memory.Memory, error) {
+	if err := s.indexMissing(ctx); err != nil {
+		return nil, err
+	}
+	offset = max(offset, 0)
+	memories := make([]*memory.Memory, 0)
+	for limit <= 0 || len(memories) < limit {
+		stop := int64(-1)
+		if limit > 0 {
+			stop = int64(offset + limit - len(memories) - 1)
+		}
+		keys, err := s.client.ZRevRange(ctx, s.createdKey(), int64(offset), stop).Result()
+		if err != nil {
+			return nil, fmt.Errorf("failed to get memory keys: %w", err)
+		}
+		if len(keys) == 0 {
+			break
+		}
+		values, err := s.client.MGet(ctx, keys...).Result()
+		if err != nil {
+			return nil, fmt.Errorf("failed to get memories: %w", err)
+		}
+
+		var expired []any
+		for i, value := range values {
+			data, ok := value.(string)
+			if !ok {
+				expired = append(expired, keys[i])
+				continue
+			}
+			var mem memory.Memory
+			if err := json.Unmarshal([]byte(data), &mem); err != nil {
+				return nil, fmt.Errorf("failed to unmarshal memory: %w", err)
+			}
+			memories = append(memories, &mem)
+		}
+		if len(expired) == 0 {
+			break
+		}
+		// Drop expired keys and read on to fill the page; removing them shifts
+		// the remaining ranks down, so the offset only advances past live keys.
+		s.client.SRem(ctx, fmt.Sprintf("%sset", s.prefix), expired...)
+		s.client.ZRem(ctx, s.createdKey(), expired...)
+		offset += len(keys) - len(expired)
+	}
+	return memories, nil
+}
+
+// indexMissing adds memories stored before the creation-time index existed. It
+// only scans the set when the index holds fewer keys than the set.
+func (s *RedisStore) indexMissing(ctx context.Context) error {
+	setKey := fmt.Sprintf("%sset", s.prefix)
+	pipe := s.client.Pipeline()
+	total := pipe.SCard(ctx, setKey)
+	indexed := pipe.ZCard(ctx, s.createdKey())
+	if _, err := pipe.Exec(ctx); err != nil {
+		return fmt.Errorf("failed to count memory keys: %w", err)
+	}
+	if indexed.Val() >= total.Val() {
+		return nil
+	}
+
+	var cursor uint64
+	for {
+		keys, next, err := s.client.SScan(ctx, setKey, cursor, "", 100).Result()
+		if err != nil {
+			return fmt.Errorf("failed to scan memory keys: %w", err)
+		}
+		if len(keys) > 0 {
+			values, err := s.client.MGet(ctx, keys...).Result()
+			if err != nil {
+				return fmt.Errorf("failed to get memories: %w", err)
+			}
+			members := make([]redis.Z, 0, len(keys))
+			for i, value := range values {
+				data, ok := value.(string)
+				if !ok {
+					// Key expired, remove it so the counts line up next time
+					s.client.SRem(ctx, setKey, keys[i])
+					continue
+				}
+				var mem memory.Memory
+				if err := json.Unmarshal([]byte(data), &mem); err != nil {
+					return fmt.Errorf("failed to unmarshal memory: %w", err)
+				}
+				members = append(members, createdMember(keys[i], mem.CreatedAt))
+			}
+			// NX keeps the scores of keys that are already indexed.
+			if len(members) > 0 {
+				if err := s.client.ZAddNX(ctx, s.createdKey(), members...).Err(); err != nil {
+					return fmt.Errorf("failed to index memory keys: %w", err)
+				}
+			}
+		}
+		if next == 0 {
+			return nil
+		}
+		cursor = next
+	}
+}
+
 // Clear removes all memories from Redis using a transaction
 func (s *RedisStore) Clear(ctx context.Context) error {
 	setKey := fmt.Sprintf("%sset", s.prefix)
@@ -177,8 +363,8 @@ func (s *RedisStore) Clear(ctx context.Context) error {
 			if len(keys) > 0 {
 				pipe.Del(ctx, keys...)
 			}
-			// Clear the set
-			pipe.Del(ctx, setKey)
+			// Clear the set and the creation-time index
+			pipe.Del(ctx, setKey, s.createdKey())
 			return nil
 		})
 		return err
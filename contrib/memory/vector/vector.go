@@ -0,0 +1,234 @@
+// Package vector implements memory.MemoryStore with semantic recall: memories
+// are embedded on write and SearchMemory returns the ones closest in meaning
+// to the query instead of substring matches.
+package vector
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/sweetpotato0/ai-allin/memory"
+	errorskg "github.com/sweetpotato0/ai-allin/pkg/errors"
+	"github.com/sweetpotato0/ai-allin/pkg/id"
+	"github.com/sweetpotato0/ai-allin/vector"
+)
+
+// Metadata keys used to round-trip memory timestamps through the vector store.
+const (
+	createdAtKey = "memory_created_at"
+	updatedAtKey = "memory_updated_at"
+)
+
+// Store keeps memories in a vector.VectorStore. Give it a dedicated collection
+// (see vector.VectorStore.WithCollection) when the store also holds documents.
+type Store struct {
+	embedder  vector.Embedder
+	store     vector.VectorStore
+	topK      int
+	threshold float32
+	idGen     id.Generator
+}
+
+var _ memory.MemoryStore = (*Store)(nil)
+
+// Option configures a Store.
+type Option func(*Store)
+
+// WithTopK sets how many memories SearchMemory returns at most (default 5).
+func WithTopK(k int) Option {
+	return func(s *Store) {
+		if k > 0 {
+			s.topK = k
+		}
+	}
+}
+
+// WithScoreThreshold drops memories whose cosine similarity to the query is
+// below threshold (default 0, which keeps every hit).
+func WithScoreThreshold(threshold float32) Option {
+	return func(s *Store) {
+		s.threshold = threshold
+	}
+}
+
+// WithIDGenerator sets the generator used for memories added without an ID.
+func WithIDGenerator(gen id.Generator) Option {
+	return func(s *Store) {
+		if gen != nil {
+			s.idGen = gen
+		}
+	}
+}
+
+// New creates a Store that embeds memories with embedder and keeps them in store.
+func New(embedder vector.Embedder, store vector.VectorStore, opts ...Option) *Store {
+	s := &Store{
+		embedder: embedder,
+		store:    store,
+		topK:     5,
+		idGen:    memory.DefaultIDGenerator,
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// AddMemory embeds the memory content and stores it. Adding a memory with an
+// existing ID replaces it.
+func (s *Store) AddMemory(ctx context.Context, mem *memory.Memory) error {
+	if mem == nil {
+		return fmt.Errorf("memory cannot be nil")
+	}
+	if mem.ID == "" {
+		mem.ID = s.idGen.New()
+	}
+	now := time.Now()
+	if mem.CreatedAt.IsZero() {
+		mem.CreatedAt = now
+	}
+	if mem.UpdatedAt.IsZero() {
+		mem.UpdatedAt = mem.CreatedAt
+	}
+
+	vec, err := s.embedder.Embed(ctx, mem.Content)
+	if err != nil {
+		return fmt.Errorf("failed to embed memory %s: %w", mem.ID, err)
+	}
+	metadata := make(map[string]any, len(mem.Metadata)+2)
+	maps.Copy(metadata, mem.Metadata)
+	metadata[createdAtKey] = mem.CreatedAt.Format(time.RFC3339Nano)
+	metadata[updatedAtKey] = mem.UpdatedAt.Format(time.RFC3339Nano)
+
+	if err := s.store.AddEmbedding(ctx, &vector.Embedding{
+		ID:       mem.ID,
+		Vector:   vec,
+		Text:     mem.Content,
+		Metadata: metadata,
+	}); err != nil {
+		return fmt.Errorf("failed to store memory %s: %w", mem.ID, err)
+	}
+	return nil
+}
+
+// SearchMemory returns the memories most similar to query, best match first.
+// An empty query returns no memories since there is nothing to compare against.
+func (s *Store) SearchMemory(ctx context.Context, query string) ([]*memory.Memory, error) {
+	if query == "" {
+		return []*memory.Memory{}, nil
+	}
+	queryVec, err := s.embedder.Embed(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to embed query: %w", err)
+	}
+	hits, err := s.store.Search(ctx, queryVec, s.topK)
+	if err != nil {
+		return nil, fmt.Errorf("failed to search memories: %w", err)
+	}
+
+	type scored struct {
+		mem   *memory.Memory
+		score float32
+	}
+	results := make([]scored, 0, len(hits))
+	for _, hit := range hits {
+		if hit == nil {
+			continue
+		}
+		score := cosine(queryVec, hit.Vector)
+		if s.threshold > 0 && score < s.threshold {
+			continue
+		}
+		results = append(results, scored{mem: toMemory(hit), score: score})
+	}
+	sort.SliceStable(results, func(i, j int) bool {
+		return results[i].score > results[j].score
+	})
+
+	memories := make([]*memory.Memory, 0, min(len(results), s.topK))
+	for _, r := range results[:min(len(results), s.topK)] {
+		memories = append(memories, r.mem)
+	}
+	return memories, nil
+}
+
+// DeleteMemory removes the memory's embedding.
+func (s *Store) DeleteMemory(ctx context.Context, id string) error {
+	if err := s.store.DeleteEmbedding(ctx, id); err != nil {
+		if errors.Is(err, errorskg.ErrNotFound) {
+			return fmt.Errorf("memory %s: %w", id, errorskg.ErrNotFound)
+		}
+		return fmt.Errorf("failed to delete memory %s: %w", id, err)
+	}
+	return nil
+}
+
+// UpdateMemory re-embeds an existing memory with its new content and metadata.
+func (s *Store) UpdateMemory(ctx context.Context, mem *memory.Memory) error {
+	if mem == nil {
+		return fmt.Errorf("memory cannot be nil")
+	}
+	existing, err := s.store.GetEmbedding(ctx, mem.ID)
+	if err != nil {
+		if errors.Is(err, errorskg.ErrNotFound) {
+			return fmt.Errorf("memory %s: %w", mem.ID, errorskg.ErrNotFound)
+		}
+		return fmt.Errorf("failed to load memory %s: %w", mem.ID, err)
+	}
+	mem.CreatedAt = toMemory(existing).CreatedAt
+	mem.UpdatedAt = time.Now()
+	return s.AddMemory(ctx, mem)
+}
+
+// ListMemories is not supported: vector.VectorStore offers no way to
+// enumerate embeddings, so it always fails with errors.ErrUnsupported.
+func (s *Store) ListMemories(ctx context.Context, limit, offset int) ([]*memory.Memory, error) {
+	return nil, fmt.Errorf("listing memories from a vector store: %w", errors.ErrUnsupported)
+}
+
+// toMemory rebuilds a memory from its stored embedding.
+func toMemory(emb *vector.Embedding) *memory.Memory {
+	mem := &memory.Memory{ID: emb.ID, Content: emb.Text}
+	if len(emb.Metadata) > 0 {
+		mem.Metadata = make(map[string]any, len(emb.Metadata))
+		for k, v := range emb.Metadata {
+			switch k {
+			case createdAtKey:
+				mem.CreatedAt = parseTime(v)
+			case updatedAtKey:
+				mem.UpdatedAt = parseTime(v)
+			default:
+				mem.Metadata[k] = v
+			}
+		}
+	}
+	return mem
+}
+
+func parseTime(v any) time.Time {
+	s, _ := v.(string)
+	t, _ := time.Parse(time.RFC3339Nano, s)
+	return t
+}
+
+// cosine returns the cosine similarity of a and b without assuming unit vectors.
+func cosine(a, b []float32) float32 {
+	if len(a) != len(b) || len(a) == 0 {
+		return 0
+	}
+	var dot, normA, normB float64
+	for i := range a {
+		dot += float64(a[i]) * float64(b[i])
+		normA += float64(a[i]) * float64(a[i])
+		normB += float64(b[i]) * float64(b[i])
+	}
+	if normA == 0 || normB == 0 {
+		return 0
+	}
+	return float32(dot / (math.Sqrt(normA) * math.Sqrt(normB)))
+}
@@ -0,0 +1,155 @@
+package vector
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sweetpotato0/ai-allin/contrib/vector/inmemory"
+	"github.com/sweetpotato0/ai-allin/memory"
+	errorskg "github.com/sweetpotato0/ai-allin/pkg/errors"
+)
+
+// topicEmbedder maps text onto one axis per topic so related wording lands
+// close together without sharing substrings.
+type topicEmbedder struct{ err error }
+
+var topics = [][]string{
+	{"shipping", "deliver", "express", "快递"},
+	{"colour", "blue", "color"},
+	{"refund", "money back"},
+}
+
+func (e topicEmbedder) Embed(ctx context.Context, text string) ([]float32, error) {
+	if e.err != nil {
+		return nil, e.err
+	}
+	vec := make([]float32, len(topics)+1)
+	for i, words := range topics {
+		for _, w := range words {
+			vec[i] += float32(strings.Count(strings.ToLower(text), w))
+		}
+	}
+	vec[len(topics)] = 0.1
+	return vec, nil
+}
+
+func (e topicEmbedder) EmbedBatch(ctx context.Context, texts []string) ([][]float32, error) {
+	out := make([][]float32, len(texts))
+	for i, text := range texts {
+		out[i], _ = e.Embed(ctx, text)
+	}
+	return out, nil
+}
+
+func (topicEmbedder) Dimension() int { return len(topics) + 1 }
+
+func seed(t *testing.T, store *Store) {
+	t.Helper()
+	for _, content := range []string{
+		"Customer prefers express shipping",
+		"Customer's favourite colour is blue",
+		"Order 42 was refunded",
+	} {
+		if err := store.AddMemory(context.Background(), &memory.Memory{Content: content, Metadata: map[string]any{"user": "u1"}}); err != nil {
+			t.Fatalf("AddMemory failed: %v", err)
+		}
+	}
+}
+
+func TestSearchMemoryRanksBySimilarity(t *testing.T) {
+	store := New(topicEmbedder{}, inmemory.NewInMemoryVectorStore(), WithTopK(2))
+	seed(t, store)
+
+	results, err := store.SearchMemory(context.Background(), "How fast can you deliver my parcel?")
+	if err != nil {
+		t.Fatalf("SearchMemory failed: %v", err)
+	}
+	if len(results) != 2 || results[0].Content != "Customer prefers express shipping" {
+		t.Fatalf("expected the shipping memory first, got %+v", results)
+	}
+	top := results[0]
+	if top.ID == "" || top.Metadata["user"] != "u1" || top.CreatedAt.IsZero() || !top.UpdatedAt.Equal(top.CreatedAt) {
+		t.Fatalf("memory fields were not round-tripped: %+v", top)
+	}
+	if _, ok := top.Metadata[createdAtKey]; ok {
+		t.Fatal("internal timestamp keys must not leak into metadata")
+	}
+
+	if results, _ := store.SearchMemory(context.Background(), ""); len(results) != 0 {
+		t.Fatalf("expected no results for an empty query, got %d", len(results))
+	}
+}
+
+func TestSearchMemoryThreshold(t *testing.T) {
+	store := New(topicEmbedder{}, inmemory.NewInMemoryVectorStore(), WithScoreThreshold(0.8))
+	seed(t, store)
+
+	results, err := store.SearchMemory(context.Background(), "Did they get their money back?")
+	if err != nil {
+		t.Fatalf("SearchMemory failed: %v", err)
+	}
+	if len(results) != 1 || results[0].Content != "Order 42 was refunded" {
+		t.Fatalf("expected only the refund memory, got %+v", results)
+	}
+	if results, _ := store.SearchMemory(context.Background(), "What is the weather like?"); len(results) != 0 {
+		t.Fatalf("expected unrelated queries to recall nothing, got %+v", results)
+	}
+}
+
+func TestAddMemoryKeepsIDAndTimestamps(t *testing.T) {
+	store := New(topicEmbedder{}, inmemory.NewInMemoryVectorStore())
+	created := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	mem := &memory.Memory{ID: "m1", Content: "ship it by express", CreatedAt: created}
+	if err := store.AddMemory(context.Background(), mem); err != nil {
+		t.Fatalf("AddMemory failed: %v", err)
+	}
+	results, _ := store.SearchMemory(context.Background(), "shipping")
+	if len(results) != 1 || results[0].ID != "m1" || !results[0].CreatedAt.Equal(created) {
+		t.Fatalf("unexpected results %+v", results)
+	}
+
+	if err := store.AddMemory(context.Background(), nil); err == nil {
+		t.Fatal("expected nil memory to be rejected")
+	}
+	failing := New(topicEmbedder{err: errors.New("boom")}, inmemory.NewInMemoryVectorStore())
+	if err := failing.AddMemory(context.Background(), &memory.Memory{Content: "x"}); err == nil || !strings.Contains(err.Error(), "boom") {
+		t.Fatalf("expected the embedder error, got %v", err)
+	}
+}
+
+func TestUpdateAndDeleteMemory(t *testing.T) {
+	ctx := context.Background()
+	store := New(topicEmbedder{}, inmemory.NewInMemoryVectorStore())
+	created := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	if err := store.AddMemory(ctx, &memory.Memory{ID: "pref", Content: "Customer prefers express shipping", CreatedAt: created}); err != nil {
+		t.Fatalf("AddMemory failed: %v", err)
+	}
+
+	update := &memory.Memory{ID: "pref", Content: "Customer's favourite colour is now blue"}
+	if err := store.UpdateMemory(ctx, update); err != nil {
+		t.Fatalf("UpdateMemory failed: %v", err)
+	}
+	results, _ := store.SearchMemory(ctx, "what colour?")
+	if len(results) != 1 || results[0].Content != update.Content || !results[0].CreatedAt.Equal(created) || !results[0].UpdatedAt.After(created) {
+		t.Fatalf("expected the re-embedded memory with its original creation time, got %+v", results)
+	}
+
+	if err := store.DeleteMemory(ctx, "pref"); err != nil {
+		t.Fatalf("DeleteMemory failed: %v", err)
+	}
+	if results, _ := store.SearchMemory(ctx, "what colour?"); len(results) != 0 {
+		t.Fatalf("expected no memories after delete, got %+v", results)
+	}
+	if err := store.DeleteMemory(ctx, "pref"); !errors.Is(err, errorskg.ErrNotFound) {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+	if err := store.UpdateMemory(ctx, &memory.Memory{ID: "pref"}); !errors.Is(err, errorskg.ErrNotFound) {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+	if _, err := store.ListMemories(ctx, 10, 0); !errors.Is(err, errors.ErrUnsupported) {
+		t.Fatalf("expected ErrUnsupported, got %v", err)
+	}
+}
@@ -1,10 +1,15 @@
 package claude
 
 import (
+	"cmp"
 	"context"
+	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"iter"
+	"sort"
+	"strings"
 
 	"github.com/anthropics/anthropic-sdk-go"
 	"github.com/anthropics/anthropic-sdk-go/option"
@@ -20,6 +25,15 @@ type Config struct {
 	BaseURL     string
 	MaxTokens   int64
 	Temperature float64
+	// UnknownRoleFallback is used for messages whose role Claude does not support.
+	// When empty such messages fail the request with agent.ErrUnknownRole.
+	UnknownRoleFallback message.Role
+	// ExtraParams are merged into every request body as-is, so any parameter the
+	// Claude API accepts (e.g. "top_p") can be set without a dedicated field.
+	ExtraParams map[string]any
+	// RepairSequence merges consecutive same-role messages before sending, since
+	// Claude rejects histories where user and assistant turns do not alternate.
+	RepairSequence bool
 }
 
 // WithBaseURL set BaseURL.
@@ -40,6 +54,24 @@ func (cfg *Config) WithAPIKey(apiKey string) *Config {
 	return cfg
 }
 
+// WithExtraParams sets provider-specific request parameters.
+func (cfg *Config) WithExtraParams(params map[string]any) *Config {
+	cfg.ExtraParams = params
+	return cfg
+}
+
+// WithRepairSequence enables merging of consecutive same-role messages.
+func (cfg *Config) WithRepairSequence(enabled bool) *Config {
+	cfg.RepairSequence = enabled
+	return cfg
+}
+
+// WithUnknownRoleFallback maps unsupported message roles to role instead of failing.
+func (cfg *Config) WithUnknownRoleFallback(role message.Role) *Config {
+	cfg.UnknownRoleFallback = role
+	return cfg
+}
+
 // DefaultConfig returns default Claude configuration
 func DefaultConfig() *Config {
 	return &Config{
@@ -49,6 +81,9 @@ func DefaultConfig() *Config {
 	}
 }
 
+// ProviderName is reported in GenerateResponse.Provider.
+const ProviderName = "claude"
+
 // Provider implements the LLMClient interface for Claude
 type Provider struct {
 	config *Config
@@ -78,7 +113,7 @@ func New(config *Config) *Provider {
 	}
 }
 
-var _ agent.LLMClient = (*Provider)(nil)
+var _ agent.StreamLLMClient = (*Provider)(nil)
 
 // Generate implements agent.LLMClient interface
 func (p *Provider) Generate(ctx context.Context, req *agent.GenerateRequest) (*agent.GenerateResponse, error) {
@@ -86,47 +121,27 @@ func (p *Provider) Generate(ctx context.Context, req *agent.GenerateRequest) (*a
 		return nil, fmt.Errorf("generate request cannot be nil")
 	}
 	// Separate system messages from conversation
-	var systemPrompts []string
-	conversationMessages := make([]anthropic.MessageParam, 0)
-
-	for _, msg := range req.Messages {
-		if msg.Role == message.RoleSystem {
-			systemPrompts = append(systemPrompts, msg.Text())
-		} else {
-			switch msg.Role {
-			case message.RoleUser:
-				conversationMessages = append(conversationMessages,
-					anthropic.NewUserMessage(anthropic.NewTextBlock(msg.Text())))
-			case message.RoleAssistant:
-				conversationMessages = append(conversationMessages,
-					anthropic.NewAssistantMessage(anthropic.NewTextBlock(msg.Text())))
-			}
-		}
+	systemPrompts, conversationMessages, err := p.convertMessages(req.Messages)
+	if err != nil {
+		return nil, err
 	}
 
 	// Build message creation params
 	params := anthropic.MessageNewParams{
-		Model:     anthropic.Model(p.config.Model),
+		Model:     anthropic.Model(cmp.Or(req.Model, p.config.Model)),
 		Messages:  conversationMessages,
-		MaxTokens: p.config.MaxTokens,
+		MaxTokens: cmp.Or(req.MaxTokens, p.config.MaxTokens),
 	}
 
 	// Add system prompts if present
 	if len(systemPrompts) > 0 {
-		systemText := ""
-		for i, sp := range systemPrompts {
-			if i > 0 {
-				systemText += "\n"
-			}
-			systemText += sp
-		}
-		params.System = []anthropic.TextBlockParam{
-			{Text: systemText},
-		}
+		params.System = systemPrompts
 	}
 
 	// Add temperature if set
-	if p.config.Temperature > 0 {
+	if req.Temperature != nil {
+		params.Temperature = param.NewOpt(*req.Temperature)
+	} else if p.config.Temperature > 0 {
 		params.Temperature = param.NewOpt(p.config.Temperature)
 	}
 
@@ -154,9 +169,9 @@ func (p *Provider) Generate(ctx context.Context, req *agent.GenerateRequest) (*a
 	}
 
 	// Call Claude API
-	apiMessage, err := p.client.Messages.New(ctx, params)
+	apiMessage, err := p.client.Messages.New(ctx, params, p.extraParamOptions(req)...)
 	if err != nil {
-		return nil, fmt.Errorf("Claude API error: %w", err)
+		return nil, wrapAPIError(err)
 	}
 
 	// Extract text and tool uses from content blocks
@@ -189,7 +204,16 @@ func (p *Provider) Generate(ctx context.Context, req *agent.GenerateRequest) (*a
 	}
 
 	responseMsg.Completed = true
-	return &agent.GenerateResponse{Message: responseMsg}, nil
+	model := string(apiMessage.Model)
+	if model == "" {
+		model = cmp.Or(req.Model, p.config.Model)
+	}
+	return &agent.GenerateResponse{
+		Message:  responseMsg,
+		Provider: ProviderName,
+		Model:    model,
+		Usage:    agent.Usage{PromptTokens: apiMessage.Usage.InputTokens, CompletionTokens: apiMessage.Usage.OutputTokens}.WithTotal(),
+	}, nil
 }
 
 // SetTemperature updates the temperature setting
@@ -208,49 +232,32 @@ func (p *Provider) SetModel(model string) {
 }
 
 // GenerateStream implements agent.StreamLLMClient interface for streaming responses
-func (p *Provider) GenerateStream(ctx context.Context, req *agent.GenerateRequest) iter.Seq2[*message.Message, error] {
-	return func(yield func(*message.Message, error) bool) {
+func (p *Provider) GenerateStream(ctx context.Context, req *agent.GenerateRequest) iter.Seq2[*agent.GenerateResponse, error] {
+	return func(yield func(*agent.GenerateResponse, error) bool) {
 		if req == nil {
 			yield(nil, fmt.Errorf("stream request cannot be nil"))
 			return
 		}
 
-		var systemPrompts []string
-		conversationMessages := make([]anthropic.MessageParam, 0, len(req.Messages))
-
-		for _, msg := range req.Messages {
-			if msg.Role == message.RoleSystem {
-				systemPrompts = append(systemPrompts, msg.Text())
-				continue
-			}
-			switch msg.Role {
-			case message.RoleUser:
-				conversationMessages = append(conversationMessages,
-					anthropic.NewUserMessage(anthropic.NewTextBlock(msg.Text())))
-			case message.RoleAssistant:
-				conversationMessages = append(conversationMessages,
-					anthropic.NewAssistantMessage(anthropic.NewTextBlock(msg.Text())))
-			}
+		systemPrompts, conversationMessages, err := p.convertMessages(req.Messages)
+		if err != nil {
+			yield(nil, err)
+			return
 		}
 
 		params := anthropic.MessageNewParams{
-			Model:     anthropic.Model(p.config.Model),
+			Model:     anthropic.Model(cmp.Or(req.Model, p.config.Model)),
 			Messages:  conversationMessages,
-			MaxTokens: p.config.MaxTokens,
+			MaxTokens: cmp.Or(req.MaxTokens, p.config.MaxTokens),
 		}
 
 		if len(systemPrompts) > 0 {
-			systemText := ""
-			for i, sp := range systemPrompts {
-				if i > 0 {
-					systemText += "\n"
-				}
-				systemText += sp
-			}
-			params.System = []anthropic.TextBlockParam{{Text: systemText}}
+			params.System = systemPrompts
 		}
 
-		if p.config.Temperature > 0 {
+		if req.Temperature != nil {
+			params.Temperature = param.NewOpt(*req.Temperature)
+		} else if p.config.Temperature > 0 {
 			params.Temperature = param.NewOpt(p.config.Temperature)
 		}
 
@@ -274,59 +281,259 @@ func (p *Provider) GenerateStream(ctx context.Context, req *agent.GenerateReques
 			params.Tools = claudeTools
 		}
 
-		stream := p.client.Messages.NewStreaming(ctx, params)
+		// Closing the stream on return also aborts the underlying request when the
+		// consumer stops early or ctx is cancelled.
+		stream := p.client.Messages.NewStreaming(ctx, params, p.extraParamOptions(req)...)
 		defer stream.Close()
 
-		finalMsg := message.NewMessage(message.RoleAssistant, "")
-		var finalMessage *anthropic.Message
-
+		var acc anthropic.Message
 		for stream.Next() {
+			if err := ctx.Err(); err != nil {
+				yield(nil, err)
+				return
+			}
 			event := stream.Current()
-			switch event.Type {
-			case "content_block_delta":
-				contentDelta := event.AsContentBlockDelta()
-				if contentDelta.Delta.Type == "text_delta" && contentDelta.Delta.Text != "" {
-					finalMsg.AppendText(contentDelta.Delta.Text)
-					chunk := message.NewMessage(message.RoleAssistant, contentDelta.Delta.Text)
-					chunk.Completed = false
-					if !yield(chunk, nil) {
-						return
+			if err := acc.Accumulate(event); err != nil {
+				yield(nil, fmt.Errorf("failed to accumulate Claude stream: %w", err))
+				return
+			}
+
+			response := &agent.GenerateResponse{
+				Message: message.NewEmptyMessage(message.RoleAssistant),
+			}
+			switch event := event.AsAny().(type) {
+			case anthropic.ContentBlockStartEvent:
+				if event.ContentBlock.Type != "tool_use" {
+					continue
+				}
+				response.ToolCallDeltas = []agent.ToolCallDelta{{
+					Index: int(event.Index),
+					ID:    event.ContentBlock.ID,
+					Name:  event.ContentBlock.Name,
+				}}
+			case anthropic.ContentBlockDeltaEvent:
+				switch delta := event.Delta.AsAny().(type) {
+				case anthropic.TextDelta:
+					if delta.Text == "" {
+						continue
+					}
+					response.Message.SetText(delta.Text)
+				case anthropic.InputJSONDelta:
+					if delta.PartialJSON == "" {
+						continue
 					}
+					response.ToolCallDeltas = []agent.ToolCallDelta{{
+						Index:     int(event.Index),
+						Arguments: delta.PartialJSON,
+					}}
+				default:
+					continue
 				}
-			case "message_start":
-				msgStart := event.AsMessageStart()
-				finalMessage = &msgStart.Message
-			case "message_stop":
-				// no-op
+			default:
+				continue
+			}
+
+			if !yield(response, nil) {
+				return
 			}
 		}
 
 		if err := stream.Err(); err != nil {
-			yield(nil, fmt.Errorf("Claude streaming error: %w", err))
+			if ctxErr := ctx.Err(); ctxErr != nil {
+				yield(nil, ctxErr)
+				return
+			}
+			yield(nil, fmt.Errorf("Claude streaming error: %w", wrapAPIError(err)))
 			return
 		}
 
-		if finalMessage != nil {
-			var toolCalls []message.ToolCall
-			for _, content := range finalMessage.Content {
-				if content.Type != "tool_use" {
-					continue
+		finalMsg := message.NewEmptyMessage(message.RoleAssistant)
+		var text strings.Builder
+		for _, content := range acc.Content {
+			switch content.Type {
+			case "text":
+				text.WriteString(content.Text)
+			case "tool_use":
+				args := make(map[string]any)
+				if len(content.Input) > 0 {
+					if err := json.Unmarshal(content.Input, &args); err != nil {
+						yield(nil, fmt.Errorf("failed to parse tool input: %w", err))
+						return
+					}
 				}
-				var args map[string]any
-				if err := json.Unmarshal(content.Input, &args); err == nil {
-					toolCalls = append(toolCalls, message.ToolCall{
-						ID:   content.ID,
-						Name: content.Name,
-						Args: args,
-					})
+				finalMsg.ToolCalls = append(finalMsg.ToolCalls, message.ToolCall{
+					ID:   content.ID,
+					Name: content.Name,
+					Args: args,
+				})
+			}
+		}
+		if text.Len() > 0 {
+			finalMsg.SetText(text.String())
+		}
+		finalMsg.FinishReason = string(acc.StopReason)
+		finalMsg.Completed = true
+
+		yield(&agent.GenerateResponse{
+			Message:  finalMsg,
+			Provider: ProviderName,
+			Model:    cmp.Or(string(acc.Model), req.Model, p.config.Model),
+			Usage:    agent.Usage{PromptTokens: acc.Usage.InputTokens, CompletionTokens: acc.Usage.OutputTokens}.WithTotal(),
+		}, nil)
+	}
+}
+
+// convertMessages splits system prompts from the conversation and maps the remaining
+// messages to Claude's format. Tool calls become tool_use blocks and tool responses
+// become tool_result blocks; unsupported roles go through the configured fallback.
+// System prompts are joined into one block, split after every message carrying
+// CacheControl; cacheable conversation messages get cache_control on their last block.
+func (p *Provider) convertMessages(msgs []*message.Message) ([]anthropic.TextBlockParam, []anthropic.MessageParam, error) {
+	if p.config.RepairSequence {
+		msgs = agent.RepairSequence(msgs)
+	}
+	var (
+		systemBlocks []anthropic.TextBlockParam
+		systemText   string
+		systemOpen   bool
+	)
+	out := make([]anthropic.MessageParam, 0, len(msgs))
+	lastWasToolResult := false
+	for _, msg := range msgs {
+		role, err := agent.ResolveRole(msg.Role, p.config.UnknownRoleFallback,
+			message.RoleSystem, message.RoleUser, message.RoleAssistant, message.RoleTool)
+		if err != nil {
+			return nil, nil, err
+		}
+		if role != message.RoleUser && msg.HasImages() {
+			return nil, nil, fmt.Errorf("%w in %s messages", agent.ErrImagesUnsupported, role)
+		}
+		switch role {
+		case message.RoleSystem:
+			if systemOpen {
+				systemText += "\n"
+			}
+			systemText += msg.Text()
+			systemOpen = true
+			if msg.CacheControl != nil {
+				systemBlocks = append(systemBlocks, anthropic.TextBlockParam{Text: systemText, CacheControl: cacheControl(msg.CacheControl)})
+				systemText, systemOpen = "", false
+			}
+		case message.RoleUser:
+			if msg.HasImages() {
+				out = append(out, anthropic.NewUserMessage(contentBlocks(msg.Content.Parts)...))
+			} else {
+				out = append(out, anthropic.NewUserMessage(anthropic.NewTextBlock(msg.Text())))
+			}
+		case message.RoleAssistant:
+			blocks := make([]anthropic.ContentBlockParamUnion, 0, len(msg.ToolCalls)+1)
+			if text := msg.Text(); text != "" || len(msg.ToolCalls) == 0 {
+				blocks = append(blocks, anthropic.NewTextBlock(text))
+			}
+			for _, call := range msg.ToolCalls {
+				args := call.Args
+				if args == nil {
+					args = map[string]any{}
 				}
+				blocks = append(blocks, anthropic.NewToolUseBlock(call.ID, args, call.Name))
+			}
+			out = append(out, anthropic.NewAssistantMessage(blocks...))
+		case message.RoleTool:
+			block := anthropic.NewToolResultBlock(msg.ToolID, msg.Text(), false)
+			// Results for parallel tool calls belong in a single user turn.
+			if lastWasToolResult {
+				last := &out[len(out)-1]
+				last.Content = append(last.Content, block)
+			} else {
+				out = append(out, anthropic.NewUserMessage(block))
 			}
-			if len(toolCalls) > 0 {
-				finalMsg.ToolCalls = toolCalls
+		}
+		if role != message.RoleSystem && msg.CacheControl != nil {
+			content := out[len(out)-1].Content
+			if cc := content[len(content)-1].GetCacheControl(); cc != nil {
+				*cc = cacheControl(msg.CacheControl)
 			}
 		}
+		lastWasToolResult = role == message.RoleTool
+	}
+	if systemOpen {
+		systemBlocks = append(systemBlocks, anthropic.TextBlockParam{Text: systemText})
+	}
+	return systemBlocks, out, nil
+}
 
-		finalMsg.Completed = true
-		yield(finalMsg, nil)
+// contentBlocks maps text and image parts to Claude content blocks. Inline bytes
+// and data: URLs become base64 image sources; other URLs are passed by reference.
+func contentBlocks(parts []message.Part) []anthropic.ContentBlockParamUnion {
+	out := make([]anthropic.ContentBlockParamUnion, 0, len(parts))
+	for _, part := range parts {
+		img := part.Image
+		switch {
+		case img == nil:
+			out = append(out, anthropic.NewTextBlock(part.Text))
+		case img.URL == "":
+			out = append(out, anthropic.NewImageBlockBase64(img.MediaType, base64.StdEncoding.EncodeToString(img.Data)))
+		default:
+			if mediaType, data, ok := parseDataURL(img.URL); ok {
+				out = append(out, anthropic.NewImageBlockBase64(mediaType, data))
+			} else {
+				out = append(out, anthropic.NewImageBlock(anthropic.URLImageSourceParam{URL: img.URL}))
+			}
+		}
+	}
+	return out
+}
+
+// parseDataURL splits a base64 data: URL into its media type and payload.
+func parseDataURL(url string) (mediaType, data string, ok bool) {
+	rest, ok := strings.CutPrefix(url, "data:")
+	if !ok {
+		return "", "", false
+	}
+	header, data, ok := strings.Cut(rest, ",")
+	if !ok {
+		return "", "", false
+	}
+	mediaType, ok = strings.CutSuffix(header, ";base64")
+	return mediaType, data, ok
+}
+
+// cacheControl maps a message cache breakpoint to Claude's ephemeral cache_control.
+func cacheControl(cc *message.CacheControl) anthropic.CacheControlEphemeralParam {
+	param := anthropic.NewCacheControlEphemeralParam()
+	param.TTL = anthropic.CacheControlEphemeralTTL(cc.TTL)
+	return param
+}
+
+// extraParamOptions turns the merged config and request extra parameters into
+// request options that set the corresponding JSON body fields.
+func (p *Provider) extraParamOptions(req *agent.GenerateRequest) []option.RequestOption {
+	params := agent.MergeParams(p.config.ExtraParams, req.ExtraParams)
+	if len(params) == 0 {
+		return nil
+	}
+	keys := make([]string, 0, len(params))
+	for k := range params {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	opts := make([]option.RequestOption, 0, len(keys))
+	for _, k := range keys {
+		opts = append(opts, option.WithJSONSet(k, params[k]))
 	}
+	return opts
+}
+
+func wrapAPIError(err error) error {
+	var apiErr *anthropic.Error
+	if errors.As(err, &apiErr) {
+		return agent.NewAPIError("Claude", apiErr.StatusCode, err)
+	}
+	return agent.NewAPIError("Claude", 0, err)
+}
+
+// IsRetriable reports whether err from this provider is transient (rate limits,
+// timeouts, server errors) and worth retrying.
+func IsRetriable(err error) bool {
+	return agent.IsRetriable(err)
 }
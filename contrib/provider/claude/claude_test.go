@@ -0,0 +1,205 @@
+package claude
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/sweetpotato0/ai-allin/agent"
+	"github.com/sweetpotato0/ai-allin/message"
+)
+
+// recordedStream is a Messages API SSE transcript with a text block followed by
+// a tool_use block whose input arrives in two fragments.
+var recordedStream = []string{
+	`event: message_start
+data: {"type":"message_start","message":{"id":"msg_1","type":"message","role":"assistant","model":"claude-sonnet-4-5-20250929","content":[],"stop_reason":null,"stop_sequence":null,"usage":{"input_tokens":25,"output_tokens":1}}}`,
+	`event: content_block_start
+data: {"type":"content_block_start","index":0,"content_block":{"type":"text","text":""}}`,
+	`event: ping
+data: {"type":"ping"}`,
+	`event: content_block_delta
+data: {"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":"Let me "}}`,
+	`event: content_block_delta
+data: {"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":"check."}}`,
+	`event: content_block_stop
+data: {"type":"content_block_stop","index":0}`,
+	`event: content_block_start
+data: {"type":"content_block_start","index":1,"content_block":{"type":"tool_use","id":"toolu_1","name":"weather","input":{}}}`,
+	`event: content_block_delta
+data: {"type":"content_block_delta","index":1,"delta":{"type":"input_json_delta","partial_json":"{\"city\":"}}`,
+	`event: content_block_delta
+data: {"type":"content_block_delta","index":1,"delta":{"type":"input_json_delta","partial_json":"\"Paris\"}"}}`,
+	`event: content_block_stop
+data: {"type":"content_block_stop","index":1}`,
+	`event: message_delta
+data: {"type":"message_delta","delta":{"stop_reason":"tool_use","stop_sequence":null},"usage":{"output_tokens":18}}`,
+	`event: message_stop
+data: {"type":"message_stop"}`,
+}
+
+// newStreamServer replays events as an SSE response; block, when non-nil, is
+// awaited after the first text delta.
+func newStreamServer(t *testing.T, events []string, block <-chan struct{}) *Provider {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/messages" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "text/event-stream")
+		for i, event := range events {
+			fmt.Fprint(w, event+"\n\n")
+			w.(http.Flusher).Flush()
+			if i == 3 && block != nil {
+				select {
+				case <-block:
+				case <-r.Context().Done():
+					return
+				}
+			}
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return New(&Config{APIKey: "test", BaseURL: srv.URL, MaxTokens: 100})
+}
+
+func TestGenerateStreamReplaysRecordedEvents(t *testing.T) {
+	p := newStreamServer(t, recordedStream, nil)
+
+	var text string
+	var deltas []agent.ToolCallDelta
+	var final *agent.GenerateResponse
+	for resp, err := range p.GenerateStream(context.Background(), &agent.GenerateRequest{
+		Messages: []*message.Message{message.NewMessage(message.RoleUser, "weather in Paris?")},
+	}) {
+		if err != nil {
+			t.Fatalf("stream failed: %v", err)
+		}
+		if resp.Message.Completed {
+			final = resp
+			continue
+		}
+		text += resp.Message.Text()
+		deltas = append(deltas, resp.ToolCallDeltas...)
+	}
+
+	if text != "Let me check." {
+		t.Fatalf("unexpected streamed text %q", text)
+	}
+	if len(deltas) != 3 || deltas[0].ID != "toolu_1" || deltas[0].Name != "weather" || deltas[1].Arguments+deltas[2].Arguments != `{"city":"Paris"}` {
+		t.Fatalf("unexpected tool call deltas %+v", deltas)
+	}
+	if final == nil {
+		t.Fatal("expected a final response")
+	}
+	msg := final.Message
+	if msg.Text() != "Let me check." || msg.FinishReason != "tool_use" {
+		t.Fatalf("unexpected final message %+v", msg)
+	}
+	if len(msg.ToolCalls) != 1 || msg.ToolCalls[0].ID != "toolu_1" || msg.ToolCalls[0].Name != "weather" || msg.ToolCalls[0].Args["city"] != "Paris" {
+		t.Fatalf("unexpected tool calls %+v", msg.ToolCalls)
+	}
+	if final.Provider != ProviderName || final.Model != "claude-sonnet-4-5-20250929" || final.Usage.PromptTokens != 25 || final.Usage.CompletionTokens != 18 || final.Usage.TotalTokens != 43 {
+		t.Fatalf("unexpected final response %+v", final)
+	}
+}
+
+func TestGenerateStreamStopsOnCancel(t *testing.T) {
+	block := make(chan struct{})
+	defer close(block)
+	p := newStreamServer(t, recordedStream, block)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	var gotErr error
+	for _, err := range p.GenerateStream(ctx, &agent.GenerateRequest{
+		Messages: []*message.Message{message.NewMessage(message.RoleUser, "hi")},
+	}) {
+		if err != nil {
+			gotErr = err
+			break
+		}
+		cancel()
+	}
+	cancel()
+	if !errors.Is(gotErr, context.Canceled) {
+		t.Fatalf("expected context.Canceled, got %v", gotErr)
+	}
+}
+
+func TestGenerateStreamReportsAPIErrors(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprint(w, `{"type":"error","error":{"type":"invalid_request_error","message":"bad"}}`)
+	}))
+	defer srv.Close()
+	p := New(&Config{APIKey: "test", BaseURL: srv.URL, MaxTokens: 100})
+
+	for _, err := range p.GenerateStream(context.Background(), &agent.GenerateRequest{
+		Messages: []*message.Message{message.NewMessage(message.RoleUser, "hi")},
+	}) {
+		var apiErr *agent.APIError
+		if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusBadRequest || !strings.Contains(err.Error(), "bad") {
+			t.Fatalf("expected API error, got %v", err)
+		}
+		return
+	}
+	t.Fatal("expected an error")
+}
+
+func TestGenerateSendsImageBlocks(t *testing.T) {
+	var got struct {
+		Messages []struct {
+			Content []map[string]any `json:"content"`
+		} `json:"messages"`
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := json.NewDecoder(r.Body).Decode(&got); err != nil {
+			t.Errorf("decode request: %v", err)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"id":"msg_1","type":"message","role":"assistant","model":"claude-sonnet-4-5-20250929","content":[{"type":"text","text":"A cat."}],"stop_reason":"end_turn","usage":{"input_tokens":30,"output_tokens":3}}`)
+	}))
+	defer srv.Close()
+	p := New(&Config{APIKey: "test", BaseURL: srv.URL, MaxTokens: 100})
+
+	resp, err := p.Generate(context.Background(), &agent.GenerateRequest{
+		Messages: []*message.Message{message.NewMultimodalMessage(message.RoleUser,
+			message.TextPart("What are these?"),
+			message.ImageURLPart("https://example.com/cat.png"),
+			message.ImageDataPart("image/png", []byte("png")),
+		)},
+	})
+	if err != nil {
+		t.Fatalf("Generate failed: %v", err)
+	}
+	if resp.Message.Text() != "A cat." {
+		t.Fatalf("unexpected response %+v", resp.Message)
+	}
+
+	if len(got.Messages) != 1 || len(got.Messages[0].Content) != 3 {
+		t.Fatalf("unexpected request messages %+v", got.Messages)
+	}
+	blocks := got.Messages[0].Content
+	urlSource, _ := blocks[1]["source"].(map[string]any)
+	dataSource, _ := blocks[2]["source"].(map[string]any)
+	if blocks[0]["text"] != "What are these?" || blocks[1]["type"] != "image" || urlSource["url"] != "https://example.com/cat.png" {
+		t.Fatalf("unexpected text or URL image blocks %+v", blocks)
+	}
+	if dataSource["type"] != "base64" || dataSource["media_type"] != "image/png" || dataSource["data"] != "cG5n" {
+		t.Fatalf("unexpected base64 image block %+v", blocks[2])
+	}
+
+	_, err = p.Generate(context.Background(), &agent.GenerateRequest{
+		Messages: []*message.Message{message.NewMultimodalMessage(message.RoleAssistant, message.ImageURLPart("https://example.com/cat.png"))},
+	})
+	if !errors.Is(err, agent.ErrImagesUnsupported) {
+		t.Fatalf("expected ErrImagesUnsupported for assistant images, got %v", err)
+	}
+}
@@ -0,0 +1,181 @@
+// Package fallback provides an agent.LLMClient that fails over between providers.
+// Requests go to the primary client first; when it fails, the next client is
+// tried, and so on until one succeeds.
+package fallback
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"iter"
+
+	"github.com/sweetpotato0/ai-allin/agent"
+)
+
+var (
+	_ agent.StreamLLMClient        = (*Client)(nil)
+	_ agent.StructuredOutputClient = (*Client)(nil)
+)
+
+// ErrNoClients is returned when the chain has no clients to try.
+var ErrNoClients = errors.New("fallback: no clients configured")
+
+// Client tries its clients in order until one succeeds.
+type Client struct {
+	clients        []agent.LLMClient
+	shouldFailover func(error) bool
+}
+
+// New returns a client that calls primary and falls back to secondaries in order.
+// Nil clients are skipped. By default every error triggers failover; use
+// WithFailoverPredicate to narrow that down.
+func New(primary agent.LLMClient, secondaries ...agent.LLMClient) *Client {
+	c := &Client{}
+	for _, client := range append([]agent.LLMClient{primary}, secondaries...) {
+		if client != nil {
+			c.clients = append(c.clients, client)
+		}
+	}
+	return c
+}
+
+// WithFailoverPredicate sets the function deciding whether an error moves on to
+// the next client. Errors it rejects are returned immediately. Pass
+// agent.IsRetriable to fail over only on transient errors such as rate limits,
+// timeouts and 5xx responses.
+func (c *Client) WithFailoverPredicate(fn func(error) bool) *Client {
+	c.shouldFailover = fn
+	return c
+}
+
+// Generate implements agent.LLMClient.
+func (c *Client) Generate(ctx context.Context, req *agent.GenerateRequest) (*agent.GenerateResponse, error) {
+	var errs []error
+	for i, client := range c.clients {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		resp, err := client.Generate(ctx, req)
+		if err == nil {
+			return resp, nil
+		}
+		errs = append(errs, err)
+		if !c.failover(ctx, err) || i == len(c.clients)-1 {
+			break
+		}
+	}
+	return nil, c.chainError(errs)
+}
+
+// GenerateStream implements agent.StreamLLMClient. A client may be replaced only
+// until it has produced its first chunk; later errors are yielded as they are.
+// Clients without streaming support answer through Generate with one chunk.
+func (c *Client) GenerateStream(ctx context.Context, req *agent.GenerateRequest) iter.Seq2[*agent.GenerateResponse, error] {
+	return func(yield func(*agent.GenerateResponse, error) bool) {
+		var errs []error
+		for i, client := range c.clients {
+			if err := ctx.Err(); err != nil {
+				yield(nil, err)
+				return
+			}
+			started, err := streamFrom(ctx, client, req, yield)
+			if err == nil {
+				return
+			}
+			if started {
+				yield(nil, err)
+				return
+			}
+			errs = append(errs, err)
+			if !c.failover(ctx, err) || i == len(c.clients)-1 {
+				break
+			}
+		}
+		yield(nil, c.chainError(errs))
+	}
+}
+
+// streamFrom forwards the responses of client to yield. It reports whether any
+// response was forwarded and returns the error that ended the stream. A consumer
+// stopping early is not an error.
+func streamFrom(ctx context.Context, client agent.LLMClient, req *agent.GenerateRequest, yield func(*agent.GenerateResponse, error) bool) (bool, error) {
+	streamer, ok := client.(agent.StreamLLMClient)
+	if !ok {
+		resp, err := client.Generate(ctx, req)
+		if err != nil {
+			return false, err
+		}
+		yield(resp, nil)
+		return true, nil
+	}
+	started := false
+	for resp, err := range streamer.GenerateStream(ctx, req) {
+		if err != nil {
+			return started, err
+		}
+		started = true
+		if !yield(resp, nil) {
+			return true, nil
+		}
+	}
+	return started, nil
+}
+
+// failover reports whether err should move on to the next client.
+func (c *Client) failover(ctx context.Context, err error) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+	return c.shouldFailover == nil || c.shouldFailover(err)
+}
+
+// chainError reports the errors collected while walking the chain. A single
+// error is returned unwrapped so callers see it exactly as the provider did.
+func (c *Client) chainError(errs []error) error {
+	switch len(errs) {
+	case 0:
+		return ErrNoClients
+	case 1:
+		return errs[0]
+	default:
+		return fmt.Errorf("fallback: all %d clients failed: %w", len(errs), errors.Join(errs...))
+	}
+}
+
+// SetTemperature updates the temperature of every client.
+func (c *Client) SetTemperature(temp float64) {
+	for _, client := range c.clients {
+		client.SetTemperature(temp)
+	}
+}
+
+// SetMaxTokens updates the max tokens of every client.
+func (c *Client) SetMaxTokens(max int64) {
+	for _, client := range c.clients {
+		client.SetMaxTokens(max)
+	}
+}
+
+// SetModel updates the model of every client. Providers rarely share model
+// names, so prefer configuring each client before building the chain.
+func (c *Client) SetModel(model string) {
+	for _, client := range c.clients {
+		client.SetModel(model)
+	}
+}
+
+// SupportsResponseFormat implements agent.StructuredOutputClient. It is true only
+// when every client enforces response formats, so the agent keeps its prompt
+// fallback whenever a request might land on a client that does not.
+func (c *Client) SupportsResponseFormat() bool {
+	if len(c.clients) == 0 {
+		return false
+	}
+	for _, client := range c.clients {
+		structured, ok := client.(agent.StructuredOutputClient)
+		if !ok || !structured.SupportsResponseFormat() {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,121 @@
+package fallback
+
+import (
+	"context"
+	"errors"
+	"iter"
+	"testing"
+
+	"github.com/sweetpotato0/ai-allin/agent"
+	"github.com/sweetpotato0/ai-allin/message"
+)
+
+// stubClient answers with reply or fails with err, optionally after streaming chunks.
+type stubClient struct {
+	reply  string
+	err    error
+	chunks []string
+	calls  int
+}
+
+func (s *stubClient) Generate(ctx context.Context, req *agent.GenerateRequest) (*agent.GenerateResponse, error) {
+	s.calls++
+	if s.err != nil {
+		return nil, s.err
+	}
+	return &agent.GenerateResponse{Message: message.NewMessage(message.RoleAssistant, s.reply)}, nil
+}
+
+func (s *stubClient) GenerateStream(ctx context.Context, req *agent.GenerateRequest) iter.Seq2[*agent.GenerateResponse, error] {
+	return func(yield func(*agent.GenerateResponse, error) bool) {
+		s.calls++
+		for _, chunk := range s.chunks {
+			if !yield(&agent.GenerateResponse{Message: message.NewMessage(message.RoleAssistant, chunk)}, nil) {
+				return
+			}
+		}
+		if s.err != nil {
+			yield(nil, s.err)
+			return
+		}
+		msg := message.NewMessage(message.RoleAssistant, s.reply)
+		msg.Completed = true
+		yield(&agent.GenerateResponse{Message: msg}, nil)
+	}
+}
+
+func (s *stubClient) SetTemperature(float64) {}
+func (s *stubClient) SetMaxTokens(int64)     {}
+func (s *stubClient) SetModel(string)        {}
+
+// plainClient exposes only the agent.LLMClient methods of a client.
+type plainClient struct{ agent.LLMClient }
+
+var errDown = errors.New("service unavailable")
+
+func TestGenerateFallsBackInOrder(t *testing.T) {
+	primary := &stubClient{err: errDown}
+	secondary := &stubClient{reply: "from secondary"}
+	tertiary := &stubClient{reply: "unused"}
+
+	resp, err := New(primary, secondary, tertiary).Generate(context.Background(), &agent.GenerateRequest{})
+	if err != nil {
+		t.Fatalf("Generate failed: %v", err)
+	}
+	if resp.Message.Text() != "from secondary" || primary.calls != 1 || tertiary.calls != 0 {
+		t.Fatalf("unexpected response %q, calls %d/%d", resp.Message.Text(), primary.calls, tertiary.calls)
+	}
+
+	last := &stubClient{err: errors.New("quota exceeded")}
+	_, err = New(&stubClient{err: errDown}, last).Generate(context.Background(), &agent.GenerateRequest{})
+	if !errors.Is(err, errDown) || !errors.Is(err, last.err) {
+		t.Fatalf("expected both errors when every client fails, got %v", err)
+	}
+}
+
+func TestFailoverPredicateAndCancellation(t *testing.T) {
+	badRequest := errors.New("invalid request")
+	secondary := &stubClient{reply: "unused"}
+	client := New(&stubClient{err: badRequest}, secondary).WithFailoverPredicate(func(err error) bool {
+		return errors.Is(err, errDown)
+	})
+	if _, err := client.Generate(context.Background(), &agent.GenerateRequest{}); !errors.Is(err, badRequest) || secondary.calls != 0 {
+		t.Fatalf("expected non-failover error to propagate, got %v after %d secondary calls", err, secondary.calls)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	primary := &stubClient{reply: "unused"}
+	if _, err := New(primary, secondary).Generate(ctx, &agent.GenerateRequest{}); !errors.Is(err, context.Canceled) || primary.calls != 0 {
+		t.Fatalf("expected cancellation before any call, got %v", err)
+	}
+}
+
+func TestGenerateStreamFallsBackBeforeFirstChunk(t *testing.T) {
+	collect := func(c *Client) (string, error) {
+		var text string
+		for resp, err := range c.GenerateStream(context.Background(), &agent.GenerateRequest{}) {
+			if err != nil {
+				return text, err
+			}
+			text += resp.Message.Text()
+		}
+		return text, nil
+	}
+
+	text, err := collect(New(&stubClient{err: errDown}, &stubClient{chunks: []string{"he", "llo"}, reply: "!"}))
+	if err != nil || text != "hello!" {
+		t.Fatalf("expected secondary stream, got %q (%v)", text, err)
+	}
+
+	secondary := &stubClient{reply: "unused"}
+	text, err = collect(New(&stubClient{chunks: []string{"par"}, err: errDown}, secondary))
+	if !errors.Is(err, errDown) || text != "par" || secondary.calls != 0 {
+		t.Fatalf("expected mid-stream error without failover, got %q (%v)", text, err)
+	}
+
+	text, err = collect(New(&stubClient{err: errDown}, plainClient{&stubClient{reply: "whole"}}))
+	if err != nil || text != "whole" {
+		t.Fatalf("expected non-streaming fallback, got %q (%v)", text, err)
+	}
+}
@@ -1,15 +1,21 @@
 package gemini
 
 import (
+	"cmp"
 	"context"
+	"errors"
 	"fmt"
+	"net/http"
 	"sync"
 
 	"iter"
 
 	"github.com/google/generative-ai-go/genai"
+	"google.golang.org/api/googleapi"
 	"google.golang.org/api/iterator"
 	"google.golang.org/api/option"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 
 	"github.com/sweetpotato0/ai-allin/agent"
 	"github.com/sweetpotato0/ai-allin/message"
@@ -21,6 +27,15 @@ type Config struct {
 	Model       string
 	MaxTokens   int
 	Temperature float32
+	// UnknownRoleFallback is used for messages whose role Gemini does not support.
+	// When empty such messages fail the request with agent.ErrUnknownRole.
+	UnknownRoleFallback message.Role
+	// ExtraParams sets generation options without dedicated fields. Recognized keys:
+	// "top_p", "top_k", "candidate_count", "stop_sequences", "response_mime_type".
+	ExtraParams map[string]any
+	// RepairSequence merges consecutive same-role messages before sending, since
+	// Gemini expects user and model turns to alternate.
+	RepairSequence bool
 }
 
 // DefaultConfig returns default Gemini configuration
@@ -33,8 +48,12 @@ func DefaultConfig(apiKey string) *Config {
 	}
 }
 
+// ProviderName is reported in GenerateResponse.Provider.
+const ProviderName = "gemini"
+
 var (
-	_ agent.StreamLLMClient = (*Provider)(nil)
+	_ agent.StreamLLMClient        = (*Provider)(nil)
+	_ agent.StructuredOutputClient = (*Provider)(nil)
 )
 
 // Provider implements the LLMClient interface for Google Gemini
@@ -69,12 +88,18 @@ func (p *Provider) Generate(ctx context.Context, req *agent.GenerateRequest) (*a
 		return nil, fmt.Errorf("generate request cannot be nil")
 	}
 
-	model, err := p.ensureModel(ctx)
+	model, err := p.ensureModel(ctx, req)
 	if err != nil {
 		return nil, err
 	}
+	if err := applyExtraParams(model, agent.MergeParams(p.config.ExtraParams, req.ExtraParams)); err != nil {
+		return nil, err
+	}
 
-	contents := toGeminiContents(req.Messages)
+	contents, err := toGeminiContents(p.prepareMessages(req.Messages), p.config.UnknownRoleFallback)
+	if err != nil {
+		return nil, err
+	}
 	if len(contents) == 0 {
 		return nil, fmt.Errorf("no messages provided")
 	}
@@ -90,9 +115,14 @@ func (p *Provider) Generate(ctx context.Context, req *agent.GenerateRequest) (*a
 
 	resp, err := session.SendMessage(ctx, last.Parts...)
 	if err != nil {
-		return nil, fmt.Errorf("Gemini generate call failed: %w", err)
+		return nil, fmt.Errorf("Gemini generate call failed: %w", wrapAPIError(err))
 	}
-	return convertResponse(resp)
+	out, err := convertResponse(resp)
+	if err != nil {
+		return nil, err
+	}
+	out.Provider, out.Model = ProviderName, cmp.Or(req.Model, p.config.Model)
+	return out, nil
 }
 
 // GenerateStream implements agent.StreamLLMClient for Gemini.
@@ -107,13 +137,21 @@ func (p *Provider) GenerateStream(ctx context.Context, req *agent.GenerateReques
 			return
 		}
 
-		model, err := p.ensureModel(ctx)
+		model, err := p.ensureModel(ctx, req)
 		if err != nil {
 			yield(nil, err)
 			return
 		}
+		if err := applyExtraParams(model, agent.MergeParams(p.config.ExtraParams, req.ExtraParams)); err != nil {
+			yield(nil, err)
+			return
+		}
 
-		contents := toGeminiContents(req.Messages)
+		contents, err := toGeminiContents(p.prepareMessages(req.Messages), p.config.UnknownRoleFallback)
+		if err != nil {
+			yield(nil, err)
+			return
+		}
 		if len(contents) == 0 {
 			yield(nil, fmt.Errorf("no messages provided"))
 			return
@@ -136,7 +174,7 @@ func (p *Provider) GenerateStream(ctx context.Context, req *agent.GenerateReques
 				break
 			}
 			if err != nil {
-				yield(nil, fmt.Errorf("Gemini streaming error: %w", err))
+				yield(nil, fmt.Errorf("Gemini streaming error: %w", wrapAPIError(err)))
 				return
 			}
 			chunk, ok := chunkResponse(resp)
@@ -158,6 +196,7 @@ func (p *Provider) GenerateStream(ctx context.Context, req *agent.GenerateReques
 			yield(nil, err)
 			return
 		}
+		genResp.Provider, genResp.Model = ProviderName, cmp.Or(req.Model, p.config.Model)
 		yield(genResp, nil)
 	}
 }
@@ -177,24 +216,195 @@ func (p *Provider) SetModel(model string) {
 	p.config.Model = model
 }
 
-func (p *Provider) ensureModel(ctx context.Context) (*genai.GenerativeModel, error) {
+// SupportsResponseFormat implements agent.StructuredOutputClient. Gemini cannot
+// combine a response schema with function calling, so the schema is only sent
+// on requests without tools.
+func (p *Provider) SupportsResponseFormat() bool {
+	return true
+}
+
+// ensureModel returns a model handle configured for req, applying its per-call
+// model, max tokens, temperature and response format over the provider config.
+func (p *Provider) ensureModel(ctx context.Context, req *agent.GenerateRequest) (*genai.GenerativeModel, error) {
 	client, err := p.ensureClient(ctx)
 	if err != nil {
 		return nil, err
 	}
 
-	model := client.GenerativeModel(p.config.Model)
-	if p.config.MaxTokens > 0 {
-		mt := int32(p.config.MaxTokens)
+	model := client.GenerativeModel(cmp.Or(req.Model, p.config.Model))
+	if maxTokens := cmp.Or(int(req.MaxTokens), p.config.MaxTokens); maxTokens > 0 {
+		mt := int32(maxTokens)
 		model.GenerationConfig.MaxOutputTokens = &mt
 	}
-	if p.config.Temperature > 0 {
+	if req.Temperature != nil {
+		temp := float32(*req.Temperature)
+		model.GenerationConfig.Temperature = &temp
+	} else if p.config.Temperature > 0 {
 		temp := p.config.Temperature
 		model.GenerationConfig.Temperature = &temp
 	}
+	if req.ResponseFormat != nil && len(req.Tools) == 0 {
+		model.GenerationConfig.ResponseMIMEType = "application/json"
+		model.GenerationConfig.ResponseSchema = toGeminiSchema(req.ResponseFormat.Schema)
+	}
 	return model, nil
 }
 
+// toGeminiSchema converts the subset of JSON Schema Gemini understands. Unknown
+// keywords are dropped.
+func toGeminiSchema(schema map[string]any) *genai.Schema {
+	if len(schema) == 0 {
+		return nil
+	}
+	out := &genai.Schema{}
+	switch t := schema["type"].(type) {
+	case string:
+		out.Type = geminiType(t)
+	case []any:
+		// ["string", "null"] style unions map to a nullable type.
+		for _, item := range t {
+			if name, ok := item.(string); ok {
+				if name == "null" {
+					out.Nullable = true
+				} else if out.Type == genai.TypeUnspecified {
+					out.Type = geminiType(name)
+				}
+			}
+		}
+	}
+	out.Format, _ = schema["format"].(string)
+	out.Description, _ = schema["description"].(string)
+	if nullable, ok := schema["nullable"].(bool); ok {
+		out.Nullable = nullable
+	}
+	out.Enum = stringList(schema["enum"])
+	out.Required = stringList(schema["required"])
+	if items, ok := schema["items"].(map[string]any); ok {
+		out.Items = toGeminiSchema(items)
+	}
+	if props, ok := schema["properties"].(map[string]any); ok {
+		out.Properties = make(map[string]*genai.Schema, len(props))
+		for name, raw := range props {
+			if prop, ok := raw.(map[string]any); ok {
+				out.Properties[name] = toGeminiSchema(prop)
+			}
+		}
+	}
+	return out
+}
+
+func geminiType(name string) genai.Type {
+	switch name {
+	case "string":
+		return genai.TypeString
+	case "number":
+		return genai.TypeNumber
+	case "integer":
+		return genai.TypeInteger
+	case "boolean":
+		return genai.TypeBoolean
+	case "array":
+		return genai.TypeArray
+	case "object":
+		return genai.TypeObject
+	default:
+		return genai.TypeUnspecified
+	}
+}
+
+func stringList(raw any) []string {
+	switch v := raw.(type) {
+	case []string:
+		return v
+	case []any:
+		out := make([]string, 0, len(v))
+		for _, item := range v {
+			if s, ok := item.(string); ok {
+				out = append(out, s)
+			}
+		}
+		return out
+	default:
+		return nil
+	}
+}
+
+// applyExtraParams copies recognized extra parameters onto the model's generation config.
+// Unrecognized keys are ignored because the Gemini SDK has no raw passthrough.
+func applyExtraParams(model *genai.GenerativeModel, params map[string]any) error {
+	for key, value := range params {
+		switch key {
+		case "top_p":
+			f, ok := toFloat(value)
+			if !ok {
+				return fmt.Errorf("gemini extra param %s: expected number, got %T", key, value)
+			}
+			v := float32(f)
+			model.GenerationConfig.TopP = &v
+		case "top_k", "candidate_count":
+			f, ok := toFloat(value)
+			if !ok {
+				return fmt.Errorf("gemini extra param %s: expected number, got %T", key, value)
+			}
+			v := int32(f)
+			if key == "top_k" {
+				model.GenerationConfig.TopK = &v
+			} else {
+				model.GenerationConfig.CandidateCount = &v
+			}
+		case "stop_sequences":
+			stops, ok := toStrings(value)
+			if !ok {
+				return fmt.Errorf("gemini extra param %s: expected a list of strings, got %T", key, value)
+			}
+			model.GenerationConfig.StopSequences = stops
+		case "response_mime_type":
+			mime, ok := value.(string)
+			if !ok {
+				return fmt.Errorf("gemini extra param %s: expected string, got %T", key, value)
+			}
+			model.GenerationConfig.ResponseMIMEType = mime
+		}
+	}
+	return nil
+}
+
+// toStrings accepts a []string, or a []any holding only strings as decoded from JSON.
+func toStrings(value any) ([]string, bool) {
+	switch v := value.(type) {
+	case []string:
+		return v, true
+	case []any:
+		out := make([]string, len(v))
+		for i, item := range v {
+			s, ok := item.(string)
+			if !ok {
+				return nil, false
+			}
+			out[i] = s
+		}
+		return out, true
+	}
+	return nil, false
+}
+
+func toFloat(value any) (float64, bool) {
+	switch v := value.(type) {
+	case float64:
+		return v, true
+	case float32:
+		return float64(v), true
+	case int:
+		return float64(v), true
+	case int32:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	default:
+		return 0, false
+	}
+}
+
 func (p *Provider) ensureClient(ctx context.Context) (*genai.Client, error) {
 	p.mu.Lock()
 	defer p.mu.Unlock()
@@ -212,12 +422,28 @@ func (p *Provider) ensureClient(ctx context.Context) (*genai.Client, error) {
 	return p.client, nil
 }
 
-func toGeminiContents(msgs []*message.Message) []*genai.Content {
+// prepareMessages applies the configured sequence repair.
+func (p *Provider) prepareMessages(msgs []*message.Message) []*message.Message {
+	if p.config.RepairSequence {
+		return agent.RepairSequence(msgs)
+	}
+	return msgs
+}
+
+func toGeminiContents(msgs []*message.Message, fallback message.Role) ([]*genai.Content, error) {
+	if err := agent.RejectImages(ProviderName, msgs); err != nil {
+		return nil, err
+	}
 	contents := make([]*genai.Content, 0, len(msgs))
 	for _, msg := range msgs {
 		if msg == nil || len(msg.Content.Parts) == 0 {
 			continue
 		}
+		role, err := agent.ResolveRole(msg.Role, fallback,
+			message.RoleSystem, message.RoleUser, message.RoleAssistant, message.RoleTool)
+		if err != nil {
+			return nil, err
+		}
 
 		parts := make([]genai.Part, 0, len(msg.Content.Parts))
 		for _, part := range msg.Content.Parts {
@@ -231,20 +457,20 @@ func toGeminiContents(msgs []*message.Message) []*genai.Content {
 		}
 
 		contents = append(contents, &genai.Content{
-			Role:  mapRole(msg.Role),
+			Role:  mapRole(role),
 			Parts: parts,
 		})
 	}
-	return contents
+	return contents, nil
 }
 
+// mapRole converts a resolved role to Gemini's two-party roles; system and tool
+// messages are sent as user turns.
 func mapRole(role message.Role) string {
-	switch role {
-	case message.RoleAssistant:
+	if role == message.RoleAssistant {
 		return "model"
-	default:
-		return "user"
 	}
+	return "user"
 }
 
 func convertResponse(resp *genai.GenerateContentResponse) (*agent.GenerateResponse, error) {
@@ -263,7 +489,11 @@ func convertResponse(resp *genai.GenerateContentResponse) (*agent.GenerateRespon
 		msg.Completed = true
 	}
 
-	return &agent.GenerateResponse{Message: msg}, nil
+	out := &agent.GenerateResponse{Message: msg}
+	if usage := resp.UsageMetadata; usage != nil {
+		out.Usage = agent.Usage{PromptTokens: int64(usage.PromptTokenCount), CompletionTokens: int64(usage.CandidatesTokenCount), TotalTokens: int64(usage.TotalTokenCount)}
+	}
+	return out, nil
 }
 
 func chunkResponse(resp *genai.GenerateContentResponse) (*agent.GenerateResponse, bool) {
@@ -305,3 +535,32 @@ func firstCandidate(resp *genai.GenerateContentResponse) *genai.Candidate {
 	}
 	return resp.Candidates[0]
 }
+
+// grpcHTTPStatus maps gRPC codes returned by the Gemini API to HTTP status codes.
+var grpcHTTPStatus = map[codes.Code]int{
+	codes.InvalidArgument:   http.StatusBadRequest,
+	codes.Unauthenticated:   http.StatusUnauthorized,
+	codes.PermissionDenied:  http.StatusForbidden,
+	codes.NotFound:          http.StatusNotFound,
+	codes.ResourceExhausted: http.StatusTooManyRequests,
+	codes.Internal:          http.StatusInternalServerError,
+	codes.Unavailable:       http.StatusServiceUnavailable,
+	codes.DeadlineExceeded:  http.StatusGatewayTimeout,
+}
+
+func wrapAPIError(err error) error {
+	var apiErr *googleapi.Error
+	if errors.As(err, &apiErr) {
+		return agent.NewAPIError("Gemini", apiErr.Code, err)
+	}
+	if st, ok := status.FromError(err); ok {
+		return agent.NewAPIError("Gemini", grpcHTTPStatus[st.Code()], err)
+	}
+	return agent.NewAPIError("Gemini", 0, err)
+}
+
+// IsRetriable reports whether err from this provider is transient (rate limits,
+// timeouts, server errors) and worth retrying.
+func IsRetriable(err error) bool {
+	return agent.IsRetriable(err)
+}
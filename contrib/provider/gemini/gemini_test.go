@@ -0,0 +1,41 @@
+package gemini
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/google/generative-ai-go/genai"
+)
+
+func TestApplyExtraParamsStopSequences(t *testing.T) {
+	cases := []struct {
+		name    string
+		value   any
+		want    []string
+		wantErr bool
+	}{
+		{name: "string slice", value: []string{"END", "STOP"}, want: []string{"END", "STOP"}},
+		{name: "decoded json list", value: []any{"END", "STOP"}, want: []string{"END", "STOP"}},
+		{name: "empty list", value: []any{}, want: []string{}},
+		{name: "mixed list", value: []any{"END", 1}, wantErr: true},
+		{name: "single string", value: "END", wantErr: true},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			model := &genai.GenerativeModel{}
+			err := applyExtraParams(model, map[string]any{"stop_sequences": tc.value})
+			if tc.wantErr {
+				if err == nil {
+					t.Fatalf("expected an error, got stop sequences %q", model.GenerationConfig.StopSequences)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("applyExtraParams: %v", err)
+			}
+			if got := model.GenerationConfig.StopSequences; fmt.Sprint(got) != fmt.Sprint(tc.want) {
+				t.Fatalf("StopSequences = %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,426 @@
+// Package ollama provides an agent.LLMClient for models served locally by
+// Ollama through its /api/chat endpoint.
+package ollama
+
+import (
+	"bufio"
+	"bytes"
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"iter"
+	"net/http"
+	"strings"
+
+	"github.com/sweetpotato0/ai-allin/agent"
+	"github.com/sweetpotato0/ai-allin/message"
+)
+
+// Config holds Ollama provider configuration
+type Config struct {
+	BaseURL     string
+	Model       string
+	MaxTokens   int64
+	Temperature float64
+	// UnknownRoleFallback is used for messages whose role Ollama does not support.
+	// When empty such messages fail the request with agent.ErrUnknownRole.
+	UnknownRoleFallback message.Role
+	// ExtraParams are merged into the request "options" object, so any model
+	// option Ollama accepts (e.g. "top_k", "num_ctx") can be set.
+	ExtraParams map[string]any
+	// HTTPClient sends the requests. Nil selects a client without a timeout, as
+	// local models can take long to load and generate.
+	HTTPClient *http.Client
+}
+
+// WithBaseURL set BaseURL.
+func (cfg *Config) WithBaseURL(url string) *Config {
+	cfg.BaseURL = url
+	return cfg
+}
+
+// WithModel set model.
+func (cfg *Config) WithModel(model string) *Config {
+	cfg.Model = model
+	return cfg
+}
+
+// WithExtraParams sets model options sent with every request.
+func (cfg *Config) WithExtraParams(params map[string]any) *Config {
+	cfg.ExtraParams = params
+	return cfg
+}
+
+// WithUnknownRoleFallback maps unsupported message roles to role instead of failing.
+func (cfg *Config) WithUnknownRoleFallback(role message.Role) *Config {
+	cfg.UnknownRoleFallback = role
+	return cfg
+}
+
+// WithHTTPClient sets the HTTP client used for requests.
+func (cfg *Config) WithHTTPClient(client *http.Client) *Config {
+	cfg.HTTPClient = client
+	return cfg
+}
+
+// DefaultBaseURL is the address of a local Ollama server.
+const DefaultBaseURL = "http://localhost:11434"
+
+// DefaultConfig returns default Ollama configuration
+func DefaultConfig() *Config {
+	return &Config{
+		BaseURL:     DefaultBaseURL,
+		Model:       "llama3.2",
+		MaxTokens:   2000,
+		Temperature: 0.7,
+	}
+}
+
+// ProviderName is reported in GenerateResponse.Provider.
+const ProviderName = "ollama"
+
+var (
+	_ agent.StreamLLMClient        = (*Provider)(nil)
+	_ agent.StructuredOutputClient = (*Provider)(nil)
+)
+
+// Provider implements the LLMClient interface for Ollama
+type Provider struct {
+	config *Config
+	client *http.Client
+}
+
+// New creates a new Ollama provider
+func New(config *Config) *Provider {
+	if config.BaseURL == "" {
+		config.BaseURL = DefaultBaseURL
+	}
+	if config.Model == "" {
+		config.Model = "llama3.2"
+	}
+	client := config.HTTPClient
+	if client == nil {
+		client = &http.Client{}
+	}
+	return &Provider{config: config, client: client}
+}
+
+type chatRequest struct {
+	Model    string           `json:"model"`
+	Messages []chatMessage    `json:"messages"`
+	Tools    []map[string]any `json:"tools,omitempty"`
+	Format   map[string]any   `json:"format,omitempty"`
+	Options  map[string]any   `json:"options,omitempty"`
+	Stream   bool             `json:"stream"`
+}
+
+type chatMessage struct {
+	Role      string     `json:"role"`
+	Content   string     `json:"content"`
+	ToolCalls []toolCall `json:"tool_calls,omitempty"`
+	ToolName  string     `json:"tool_name,omitempty"`
+}
+
+type toolCall struct {
+	ID       string `json:"id,omitempty"`
+	Function struct {
+		Index     int            `json:"index,omitempty"`
+		Name      string         `json:"name"`
+		Arguments map[string]any `json:"arguments"`
+	} `json:"function"`
+}
+
+type chatResponse struct {
+	Model           string      `json:"model"`
+	Message         chatMessage `json:"message"`
+	Done            bool        `json:"done"`
+	DoneReason      string      `json:"done_reason"`
+	PromptEvalCount int64       `json:"prompt_eval_count"`
+	EvalCount       int64       `json:"eval_count"`
+	Error           string      `json:"error"`
+}
+
+// Generate implements agent.LLMClient interface
+func (p *Provider) Generate(ctx context.Context, req *agent.GenerateRequest) (*agent.GenerateResponse, error) {
+	if req == nil {
+		return nil, fmt.Errorf("generate request cannot be nil")
+	}
+	body, err := p.buildRequest(req, false)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := p.post(ctx, body)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var out chatResponse
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		return nil, fmt.Errorf("failed to decode Ollama response: %w", err)
+	}
+	if out.Error != "" {
+		return nil, agent.NewAPIError("Ollama", 0, errors.New(out.Error))
+	}
+
+	responseMsg := message.NewMessage(message.RoleAssistant, out.Message.Content)
+	responseMsg.ToolCalls = decodeToolCalls(out.Message.ToolCalls, 0)
+	responseMsg.FinishReason = out.DoneReason
+	responseMsg.Completed = true
+	return &agent.GenerateResponse{
+		Message:  responseMsg,
+		Provider: ProviderName,
+		Model:    cmp.Or(out.Model, body.Model),
+		Usage:    agent.Usage{PromptTokens: out.PromptEvalCount, CompletionTokens: out.EvalCount}.WithTotal(),
+	}, nil
+}
+
+// GenerateStream implements agent.StreamLLMClient interface for streaming responses.
+// Ollama sends each tool call whole, so every call arrives as a single delta.
+func (p *Provider) GenerateStream(ctx context.Context, req *agent.GenerateRequest) iter.Seq2[*agent.GenerateResponse, error] {
+	return func(yield func(*agent.GenerateResponse, error) bool) {
+		if req == nil {
+			yield(nil, fmt.Errorf("stream request cannot be nil"))
+			return
+		}
+		body, err := p.buildRequest(req, true)
+		if err != nil {
+			yield(nil, err)
+			return
+		}
+		resp, err := p.post(ctx, body)
+		if err != nil {
+			yield(nil, err)
+			return
+		}
+		defer resp.Body.Close()
+
+		var (
+			content strings.Builder
+			calls   []message.ToolCall
+			last    chatResponse
+		)
+		scanner := bufio.NewScanner(resp.Body)
+		scanner.Buffer(make([]byte, 0, 64*1024), 4*1024*1024)
+		for scanner.Scan() {
+			line := bytes.TrimSpace(scanner.Bytes())
+			if len(line) == 0 {
+				continue
+			}
+			var chunk chatResponse
+			if err := json.Unmarshal(line, &chunk); err != nil {
+				yield(nil, fmt.Errorf("failed to decode Ollama stream chunk: %w", err))
+				return
+			}
+			if chunk.Error != "" {
+				yield(nil, fmt.Errorf("Ollama streaming error: %w", agent.NewAPIError("Ollama", 0, errors.New(chunk.Error))))
+				return
+			}
+
+			response := &agent.GenerateResponse{Message: message.NewEmptyMessage(message.RoleAssistant)}
+			if chunk.Message.Content != "" {
+				content.WriteString(chunk.Message.Content)
+				response.Message.SetText(chunk.Message.Content)
+			}
+			for _, call := range decodeToolCalls(chunk.Message.ToolCalls, len(calls)) {
+				args, _ := json.Marshal(call.Args)
+				response.ToolCallDeltas = append(response.ToolCallDeltas, agent.ToolCallDelta{
+					Index:     len(calls),
+					ID:        call.ID,
+					Name:      call.Name,
+					Arguments: string(args),
+				})
+				calls = append(calls, call)
+			}
+			response.Message.FinishReason = chunk.DoneReason
+			if chunk.Done {
+				last = chunk
+			}
+			if chunk.Message.Content != "" || len(response.ToolCallDeltas) > 0 || chunk.Done {
+				if !yield(response, nil) {
+					return
+				}
+			}
+			if chunk.Done {
+				break
+			}
+		}
+		if err := scanner.Err(); err != nil {
+			yield(nil, fmt.Errorf("Ollama streaming error: %w", agent.NewAPIError("Ollama", 0, err)))
+			return
+		}
+		if !last.Done {
+			yield(nil, fmt.Errorf("Ollama stream ended before completion"))
+			return
+		}
+
+		finalMsg := &agent.GenerateResponse{
+			Message:  message.NewEmptyMessage(message.RoleAssistant),
+			Provider: ProviderName,
+			Model:    cmp.Or(last.Model, body.Model),
+			Usage:    agent.Usage{PromptTokens: last.PromptEvalCount, CompletionTokens: last.EvalCount}.WithTotal(),
+		}
+		if content.Len() > 0 {
+			finalMsg.Message.SetText(content.String())
+		}
+		finalMsg.Message.FinishReason = last.DoneReason
+		finalMsg.Message.ToolCalls = calls
+		finalMsg.Message.Completed = true
+		yield(finalMsg, nil)
+	}
+}
+
+// SetTemperature updates the temperature setting
+func (p *Provider) SetTemperature(temp float64) {
+	p.config.Temperature = temp
+}
+
+// SetMaxTokens updates the max tokens setting
+func (p *Provider) SetMaxTokens(max int64) {
+	p.config.MaxTokens = max
+}
+
+// SetModel updates the model
+func (p *Provider) SetModel(model string) {
+	p.config.Model = model
+}
+
+// SupportsResponseFormat implements agent.StructuredOutputClient; schemas are
+// sent as the request "format".
+func (p *Provider) SupportsResponseFormat() bool {
+	return true
+}
+
+// buildRequest converts req into an /api/chat request body.
+func (p *Provider) buildRequest(req *agent.GenerateRequest, stream bool) (*chatRequest, error) {
+	if err := agent.RejectImages(ProviderName, req.Messages); err != nil {
+		return nil, err
+	}
+	msgs, err := p.convertMessages(req.Messages)
+	if err != nil {
+		return nil, err
+	}
+	body := &chatRequest{
+		Model:    cmp.Or(req.Model, p.config.Model),
+		Messages: msgs,
+		Tools:    req.Tools,
+		Stream:   stream,
+	}
+	options := agent.MergeParams(p.config.ExtraParams, req.ExtraParams)
+	if options == nil {
+		options = make(map[string]any)
+	}
+	if req.Temperature != nil {
+		options["temperature"] = *req.Temperature
+	} else if p.config.Temperature > 0 {
+		options["temperature"] = p.config.Temperature
+	}
+	if maxTokens := cmp.Or(req.MaxTokens, p.config.MaxTokens); maxTokens > 0 {
+		options["num_predict"] = maxTokens
+	}
+	if len(options) > 0 {
+		body.Options = options
+	}
+	if req.ResponseFormat != nil {
+		body.Format = req.ResponseFormat.Schema
+	}
+	return body, nil
+}
+
+// post sends body to /api/chat and returns the response when it succeeded.
+func (p *Provider) post(ctx context.Context, body *chatRequest) (*http.Response, error) {
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal Ollama request: %w", err)
+	}
+	endpoint := strings.TrimRight(p.config.BaseURL, "/") + "/api/chat"
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(payload))
+	if err != nil {
+		return nil, err
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	resp, err := p.client.Do(httpReq)
+	if err != nil {
+		return nil, agent.NewAPIError("Ollama", 0, err)
+	}
+	if resp.StatusCode >= 300 {
+		defer resp.Body.Close()
+		return nil, agent.NewAPIError("Ollama", resp.StatusCode, errors.New(errorMessage(resp)))
+	}
+	return resp, nil
+}
+
+// errorMessage extracts the "error" field of a failed response, falling back to its status.
+func errorMessage(resp *http.Response) string {
+	data, _ := io.ReadAll(io.LimitReader(resp.Body, 64*1024))
+	var body struct {
+		Error string `json:"error"`
+	}
+	if json.Unmarshal(data, &body) == nil && body.Error != "" {
+		return body.Error
+	}
+	if text := strings.TrimSpace(string(data)); text != "" {
+		return text
+	}
+	return resp.Status
+}
+
+// convertMessages maps messages to Ollama chat messages, resolving unsupported roles
+// through the configured fallback. Tool results carry the name of the tool
+// that was called, which Ollama uses instead of call IDs.
+func (p *Provider) convertMessages(msgs []*message.Message) ([]chatMessage, error) {
+	toolNames := make(map[string]string)
+	out := make([]chatMessage, 0, len(msgs))
+	for _, msg := range msgs {
+		role, err := agent.ResolveRole(msg.Role, p.config.UnknownRoleFallback,
+			message.RoleSystem, message.RoleUser, message.RoleAssistant, message.RoleTool)
+		if err != nil {
+			return nil, err
+		}
+		converted := chatMessage{Role: string(role), Content: msg.Text()}
+		switch role {
+		case message.RoleAssistant:
+			for _, tc := range msg.ToolCalls {
+				toolNames[tc.ID] = tc.Name
+				var call toolCall
+				call.ID = tc.ID
+				call.Function.Name = tc.Name
+				call.Function.Arguments = tc.Args
+				if call.Function.Arguments == nil {
+					call.Function.Arguments = make(map[string]any)
+				}
+				converted.ToolCalls = append(converted.ToolCalls, call)
+			}
+		case message.RoleTool:
+			converted.ToolName = toolNames[msg.ToolID]
+		}
+		out = append(out, converted)
+	}
+	return out, nil
+}
+
+// decodeToolCalls converts Ollama tool calls, numbering calls without an ID
+// from offset so IDs stay unique within a response.
+func decodeToolCalls(calls []toolCall, offset int) []message.ToolCall {
+	if len(calls) == 0 {
+		return nil
+	}
+	out := make([]message.ToolCall, len(calls))
+	for i, tc := range calls {
+		out[i] = message.ToolCall{
+			ID:   cmp.Or(tc.ID, fmt.Sprintf("call_%d", offset+i)),
+			Name: tc.Function.Name,
+			Args: tc.Function.Arguments,
+		}
+	}
+	return out
+}
+
+// IsRetriable reports whether err from this provider is transient (rate limits,
+// timeouts, server errors) and worth retrying.
+func IsRetriable(err error) bool {
+	return agent.IsRetriable(err)
+}
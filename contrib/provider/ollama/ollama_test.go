@@ -0,0 +1,124 @@
+package ollama
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/sweetpotato0/ai-allin/agent"
+	"github.com/sweetpotato0/ai-allin/message"
+)
+
+// newServer serves /api/chat with handle and records the decoded request body.
+func newServer(t *testing.T, handle func(w http.ResponseWriter, req chatRequest)) (*Provider, *chatRequest) {
+	t.Helper()
+	var got chatRequest
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/chat" {
+			http.NotFound(w, r)
+			return
+		}
+		if err := json.NewDecoder(r.Body).Decode(&got); err != nil {
+			t.Errorf("decode request: %v", err)
+		}
+		handle(w, got)
+	}))
+	t.Cleanup(srv.Close)
+	return New(DefaultConfig().WithBaseURL(srv.URL + "/")), &got
+}
+
+func TestGenerateMapsMessagesAndToolCalls(t *testing.T) {
+	p, got := newServer(t, func(w http.ResponseWriter, req chatRequest) {
+		fmt.Fprint(w, `{"model":"llama3.2","message":{"role":"assistant","content":"","tool_calls":[{"function":{"name":"weather","arguments":{"city":"Paris"}}}]},"done":true,"done_reason":"stop","prompt_eval_count":12,"eval_count":5}`)
+	})
+
+	assistant := message.NewMessage(message.RoleAssistant, "")
+	assistant.ToolCalls = []message.ToolCall{{ID: "call_1", Name: "clock", Args: map[string]any{"tz": "UTC"}}}
+	temp := 0.2
+	resp, err := p.Generate(context.Background(), &agent.GenerateRequest{
+		Messages: []*message.Message{
+			message.NewMessage(message.RoleSystem, "be brief"),
+			message.NewMessage(message.RoleUser, "time and weather?"),
+			assistant,
+			message.NewToolResponseMessage("call_1", "12:00"),
+		},
+		Tools:       []map[string]any{{"type": "function", "function": map[string]any{"name": "weather"}}},
+		Temperature: &temp,
+		ExtraParams: map[string]any{"top_k": 20},
+	})
+	if err != nil {
+		t.Fatalf("Generate failed: %v", err)
+	}
+
+	if len(got.Messages) != 4 || got.Messages[2].ToolCalls[0].Function.Name != "clock" || got.Messages[3].Role != "tool" || got.Messages[3].ToolName != "clock" {
+		t.Fatalf("unexpected messages %+v", got.Messages)
+	}
+	if got.Stream || len(got.Tools) != 1 || got.Options["temperature"] != 0.2 || got.Options["num_predict"] != 2000.0 || got.Options["top_k"] != 20.0 {
+		t.Fatalf("unexpected request %+v", got)
+	}
+	calls := resp.Message.ToolCalls
+	if len(calls) != 1 || calls[0].ID != "call_0" || calls[0].Name != "weather" || calls[0].Args["city"] != "Paris" {
+		t.Fatalf("unexpected tool calls %+v", calls)
+	}
+	if resp.Provider != ProviderName || resp.Usage.PromptTokens != 12 || resp.Usage.CompletionTokens != 5 || resp.Usage.TotalTokens != 17 || !resp.Message.Completed {
+		t.Fatalf("unexpected response %+v", resp)
+	}
+}
+
+func TestGenerateStreamYieldsDeltas(t *testing.T) {
+	p, got := newServer(t, func(w http.ResponseWriter, req chatRequest) {
+		fmt.Fprintln(w, `{"model":"llama3.2","message":{"role":"assistant","content":"Hel"},"done":false}`)
+		fmt.Fprintln(w, `{"model":"llama3.2","message":{"role":"assistant","content":"lo"},"done":false}`)
+		fmt.Fprintln(w, `{"model":"llama3.2","message":{"role":"assistant","content":""},"done":true,"done_reason":"stop","eval_count":2}`)
+	})
+
+	var text string
+	var final *agent.GenerateResponse
+	for resp, err := range p.GenerateStream(context.Background(), &agent.GenerateRequest{
+		Messages: []*message.Message{message.NewMessage(message.RoleUser, "hi")},
+	}) {
+		if err != nil {
+			t.Fatalf("stream failed: %v", err)
+		}
+		if resp.Message.Completed {
+			final = resp
+			continue
+		}
+		text += resp.Message.Text()
+	}
+	if !got.Stream || text != "Hello" {
+		t.Fatalf("expected streamed deltas, got %q (stream=%v)", text, got.Stream)
+	}
+	if final == nil || final.Message.Text() != "Hello" || final.Message.FinishReason != "stop" || final.Usage.CompletionTokens != 2 {
+		t.Fatalf("unexpected final response %+v", final)
+	}
+}
+
+func TestGenerateReportsAPIErrors(t *testing.T) {
+	p, _ := newServer(t, func(w http.ResponseWriter, req chatRequest) {
+		w.WriteHeader(http.StatusNotFound)
+		fmt.Fprint(w, `{"error":"model \"missing\" not found"}`)
+	})
+	_, err := p.Generate(context.Background(), &agent.GenerateRequest{Model: "missing"})
+	var apiErr *agent.APIError
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusNotFound || apiErr.Err.Error() != `model "missing" not found` {
+		t.Fatalf("expected API error with server message, got %v", err)
+	}
+}
+
+func TestGenerateRejectsImages(t *testing.T) {
+	p, _ := newServer(t, func(w http.ResponseWriter, req chatRequest) {
+		t.Error("request should not be sent")
+	})
+	_, err := p.Generate(context.Background(), &agent.GenerateRequest{
+		Messages: []*message.Message{message.NewMultimodalMessage(message.RoleUser,
+			message.TextPart("what is this?"), message.ImageURLPart("https://example.com/cat.png"))},
+	})
+	if !errors.Is(err, agent.ErrImagesUnsupported) {
+		t.Fatalf("expected ErrImagesUnsupported, got %v", err)
+	}
+}
@@ -1,14 +1,20 @@
 package openai
 
 import (
+	"cmp"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"iter"
+	"net/url"
+	"sort"
+	"strings"
 
 	"github.com/openai/openai-go/v3"
 	"github.com/openai/openai-go/v3/option"
 	"github.com/openai/openai-go/v3/packages/param"
+	"github.com/openai/openai-go/v3/shared"
 	"github.com/sweetpotato0/ai-allin/agent"
 	"github.com/sweetpotato0/ai-allin/message"
 )
@@ -20,8 +26,25 @@ type Config struct {
 	Model       string
 	MaxTokens   int64
 	Temperature float64
+	// UnknownRoleFallback is used for messages whose role OpenAI does not support.
+	// When empty such messages fail the request with agent.ErrUnknownRole.
+	UnknownRoleFallback message.Role
+	// ExtraParams are merged into every request body as-is, so any parameter the
+	// OpenAI API accepts (e.g. "top_p") can be set without a dedicated field.
+	ExtraParams map[string]any
+
+	// AzureEndpoint, when set, targets an Azure OpenAI resource such as
+	// https://my-resource.openai.azure.com instead of the OpenAI API. Model then
+	// names the deployment and APIKey is sent in the api-key header.
+	AzureEndpoint string
+	// AzureAPIVersion is sent as the api-version query parameter (default
+	// DefaultAzureAPIVersion).
+	AzureAPIVersion string
 }
 
+// DefaultAzureAPIVersion is the Azure OpenAI API version used when none is configured.
+const DefaultAzureAPIVersion = "2024-10-21"
+
 // WithBaseURL set BaseURL.
 func (cfg *Config) WithBaseURL(url string) *Config {
 	cfg.BaseURL = url
@@ -40,6 +63,26 @@ func (cfg *Config) WithModel(model string) *Config {
 	return cfg
 }
 
+// WithExtraParams sets provider-specific request parameters.
+func (cfg *Config) WithExtraParams(params map[string]any) *Config {
+	cfg.ExtraParams = params
+	return cfg
+}
+
+// WithUnknownRoleFallback maps unsupported message roles to role instead of failing.
+func (cfg *Config) WithUnknownRoleFallback(role message.Role) *Config {
+	cfg.UnknownRoleFallback = role
+	return cfg
+}
+
+// WithAzure switches to Azure OpenAI: requests go to the deployment named by
+// Model on endpoint, with apiVersion (or DefaultAzureAPIVersion when empty).
+func (cfg *Config) WithAzure(endpoint, apiVersion string) *Config {
+	cfg.AzureEndpoint = endpoint
+	cfg.AzureAPIVersion = apiVersion
+	return cfg
+}
+
 // DefaultConfig returns default OpenAI configuration
 func DefaultConfig() *Config {
 	return &Config{
@@ -50,12 +93,22 @@ func DefaultConfig() *Config {
 	}
 }
 
-var _ agent.LLMClient = (*Provider)(nil)
+// ProviderName is reported in GenerateResponse.Provider.
+const ProviderName = "openai"
+
+// AzureProviderName is reported in GenerateResponse.Provider in Azure mode.
+const AzureProviderName = "azure-openai"
+
+var (
+	_ agent.LLMClient              = (*Provider)(nil)
+	_ agent.StructuredOutputClient = (*Provider)(nil)
+)
 
 // Provider implements the LLMClient interface for OpenAI
 type Provider struct {
 	config *Config
 	client openai.Client
+	name   string
 }
 
 // New creates a new OpenAI provider using official SDK
@@ -64,6 +117,19 @@ func New(config *Config) *Provider {
 		config.Model = "gpt-4o-mini"
 	}
 
+	if config.AzureEndpoint != "" {
+		options := []option.RequestOption{
+			option.WithHeaderDel("authorization"),
+			option.WithHeader("api-key", config.APIKey),
+			option.WithQuery("api-version", cmp.Or(config.AzureAPIVersion, DefaultAzureAPIVersion)),
+		}
+		return &Provider{
+			config: config,
+			client: openai.NewClient(options...),
+			name:   AzureProviderName,
+		}
+	}
+
 	options := []option.RequestOption{option.WithAPIKey(config.APIKey)}
 	if config.BaseURL != "" {
 		options = append(options, option.WithBaseURL(config.BaseURL))
@@ -73,6 +139,7 @@ func New(config *Config) *Provider {
 	return &Provider{
 		config: config,
 		client: client,
+		name:   ProviderName,
 	}
 }
 
@@ -82,32 +149,13 @@ func (p *Provider) Generate(ctx context.Context, req *agent.GenerateRequest) (*a
 		return nil, fmt.Errorf("generate request cannot be nil")
 	}
 	// Convert messages to OpenAI format
-	openAIMessages := make([]openai.ChatCompletionMessageParamUnion, 0, len(req.Messages))
-	for _, msg := range req.Messages {
-		switch msg.Role {
-		case message.RoleSystem:
-			openAIMessages = append(openAIMessages, openai.SystemMessage(msg.Text()))
-		case message.RoleUser:
-			openAIMessages = append(openAIMessages, openai.UserMessage(msg.Text()))
-		case message.RoleAssistant:
-			assistantMsg := openai.AssistantMessage(msg.Text())
-			if len(msg.ToolCalls) > 0 {
-				toolCalls, err := encodeToolCalls(msg.ToolCalls)
-				if err != nil {
-					return nil, fmt.Errorf("failed to encode tool calls: %w", err)
-				}
-				if assistantMsg.OfAssistant != nil {
-					assistantMsg.OfAssistant.ToolCalls = toolCalls
-				}
-			}
-			openAIMessages = append(openAIMessages, assistantMsg)
-		case message.RoleTool:
-			openAIMessages = append(openAIMessages, openai.ToolMessage(msg.Text(), msg.ToolID))
-		}
+	openAIMessages, err := p.convertMessages(req.Messages)
+	if err != nil {
+		return nil, err
 	}
 
 	// Build chat completion request
-	model := p.config.Model
+	model := firstNonEmpty(req.Model, p.config.Model)
 	if model == "" {
 		model = string(openai.ChatModelGPT4oMini)
 	}
@@ -117,13 +165,19 @@ func (p *Provider) Generate(ctx context.Context, req *agent.GenerateRequest) (*a
 	}
 
 	// Set temperature if provided
-	if p.config.Temperature > 0 {
+	if req.Temperature != nil {
+		params.Temperature = param.NewOpt(*req.Temperature)
+	} else if p.config.Temperature > 0 {
 		params.Temperature = param.NewOpt(p.config.Temperature)
 	}
 
 	// Set max tokens if provided
-	if p.config.MaxTokens > 0 {
-		params.MaxCompletionTokens = param.NewOpt(p.config.MaxTokens)
+	if maxTokens := cmp.Or(req.MaxTokens, p.config.MaxTokens); maxTokens > 0 {
+		params.MaxCompletionTokens = param.NewOpt(maxTokens)
+	}
+
+	if req.ResponseFormat != nil {
+		params.ResponseFormat = responseFormatParam(req.ResponseFormat)
 	}
 
 	// Add tools if provided
@@ -147,9 +201,9 @@ func (p *Provider) Generate(ctx context.Context, req *agent.GenerateRequest) (*a
 	}
 
 	// Call OpenAI API
-	completion, err := p.client.Chat.Completions.New(ctx, params)
+	completion, err := p.client.Chat.Completions.New(ctx, params, p.requestOptions(req, model)...)
 	if err != nil {
-		return nil, fmt.Errorf("OpenAI API error: %w", err)
+		return nil, wrapAPIError(err)
 	}
 
 	if len(completion.Choices) == 0 {
@@ -179,7 +233,12 @@ func (p *Provider) Generate(ctx context.Context, req *agent.GenerateRequest) (*a
 	}
 
 	responseMsg.Completed = true
-	return &agent.GenerateResponse{Message: responseMsg}, nil
+	return &agent.GenerateResponse{
+		Message:  responseMsg,
+		Provider: p.name,
+		Model:    firstNonEmpty(completion.Model, model),
+		Usage:    agent.Usage{PromptTokens: completion.Usage.PromptTokens, CompletionTokens: completion.Usage.CompletionTokens, TotalTokens: completion.Usage.TotalTokens},
+	}, nil
 }
 
 // SetTemperature updates the temperature setting
@@ -197,6 +256,12 @@ func (p *Provider) SetModel(model string) {
 	p.config.Model = model
 }
 
+// SupportsResponseFormat implements agent.StructuredOutputClient; schemas are
+// sent as a json_schema response_format.
+func (p *Provider) SupportsResponseFormat() bool {
+	return true
+}
+
 // GenerateStream implements agent.StreamLLMClient interface for streaming responses
 func (p *Provider) GenerateStream(ctx context.Context, req *agent.GenerateRequest) iter.Seq2[*agent.GenerateResponse, error] {
 	return func(yield func(*agent.GenerateResponse, error) bool) {
@@ -205,32 +270,13 @@ func (p *Provider) GenerateStream(ctx context.Context, req *agent.GenerateReques
 			return
 		}
 
-		openAIMessages := make([]openai.ChatCompletionMessageParamUnion, 0, len(req.Messages))
-		for _, msg := range req.Messages {
-			switch msg.Role {
-			case message.RoleSystem:
-				openAIMessages = append(openAIMessages, openai.SystemMessage(msg.Text()))
-			case message.RoleUser:
-				openAIMessages = append(openAIMessages, openai.UserMessage(msg.Text()))
-			case message.RoleAssistant:
-				assistantMsg := openai.AssistantMessage(msg.Text())
-				if len(msg.ToolCalls) > 0 {
-					toolCalls, err := encodeToolCalls(msg.ToolCalls)
-					if err != nil {
-						yield(nil, fmt.Errorf("failed to encode tool calls: %w", err))
-						return
-					}
-					if assistantMsg.OfAssistant != nil {
-						assistantMsg.OfAssistant.ToolCalls = toolCalls
-					}
-				}
-				openAIMessages = append(openAIMessages, assistantMsg)
-			case message.RoleTool:
-				openAIMessages = append(openAIMessages, openai.ToolMessage(msg.Text(), msg.ToolID))
-			}
+		openAIMessages, err := p.convertMessages(req.Messages)
+		if err != nil {
+			yield(nil, err)
+			return
 		}
 
-		model := p.config.Model
+		model := firstNonEmpty(req.Model, p.config.Model)
 		if model == "" {
 			model = string(openai.ChatModelGPT4oMini)
 		}
@@ -239,12 +285,18 @@ func (p *Provider) GenerateStream(ctx context.Context, req *agent.GenerateReques
 			Model:    openai.ChatModel(model),
 		}
 
-		if p.config.Temperature > 0 {
+		if req.Temperature != nil {
+			params.Temperature = param.NewOpt(*req.Temperature)
+		} else if p.config.Temperature > 0 {
 			params.Temperature = param.NewOpt(p.config.Temperature)
 		}
 
-		if p.config.MaxTokens > 0 {
-			params.MaxCompletionTokens = param.NewOpt(p.config.MaxTokens)
+		if maxTokens := cmp.Or(req.MaxTokens, p.config.MaxTokens); maxTokens > 0 {
+			params.MaxCompletionTokens = param.NewOpt(maxTokens)
+		}
+
+		if req.ResponseFormat != nil {
+			params.ResponseFormat = responseFormatParam(req.ResponseFormat)
 		}
 
 		if len(req.Tools) > 0 {
@@ -267,12 +319,18 @@ func (p *Provider) GenerateStream(ctx context.Context, req *agent.GenerateReques
 			params.Tools = openAITools
 		}
 
-		stream := p.client.Chat.Completions.NewStreaming(ctx, params)
+		// Ask for the trailing usage chunk so streamed calls report token usage.
+		params.StreamOptions = openai.ChatCompletionStreamOptionsParam{IncludeUsage: param.NewOpt(true)}
+		stream := p.client.Chat.Completions.NewStreaming(ctx, params, p.requestOptions(req, model)...)
 		defer stream.Close()
 
 		acc := openai.ChatCompletionAccumulator{}
+		var usage agent.Usage
 		for stream.Next() {
 			event := stream.Current()
+			if event.Usage.TotalTokens > 0 {
+				usage = agent.Usage{PromptTokens: event.Usage.PromptTokens, CompletionTokens: event.Usage.CompletionTokens, TotalTokens: event.Usage.TotalTokens}
+			}
 			if len(event.Choices) == 0 {
 				continue
 			}
@@ -290,6 +348,14 @@ func (p *Provider) GenerateStream(ctx context.Context, req *agent.GenerateReques
 			if choice.FinishReason != "" {
 				response.Message.FinishReason = choice.FinishReason
 			}
+			for _, tc := range choice.Delta.ToolCalls {
+				response.ToolCallDeltas = append(response.ToolCallDeltas, agent.ToolCallDelta{
+					Index:     int(tc.Index),
+					ID:        tc.ID,
+					Name:      tc.Function.Name,
+					Arguments: tc.Function.Arguments,
+				})
+			}
 
 			if !yield(response, nil) {
 				return
@@ -297,13 +363,24 @@ func (p *Provider) GenerateStream(ctx context.Context, req *agent.GenerateReques
 		}
 
 		if err := stream.Err(); err != nil {
-			yield(nil, fmt.Errorf("OpenAI streaming error: %w", err))
+			yield(nil, fmt.Errorf("OpenAI streaming error: %w", wrapAPIError(err)))
 			return
 		}
 
+		if len(acc.Choices) == 0 {
+			yield(nil, fmt.Errorf("no choices returned from OpenAI stream"))
+			return
+		}
 		finalMsg := &agent.GenerateResponse{
-			Message: message.NewEmptyMessage(message.RoleAssistant),
+			Message:  message.NewEmptyMessage(message.RoleAssistant),
+			Provider: p.name,
+			Model:    firstNonEmpty(acc.Model, model),
+			Usage:    usage,
+		}
+		if content := acc.Choices[0].Message.Content; content != "" {
+			finalMsg.Message.SetText(content)
 		}
+		finalMsg.Message.FinishReason = acc.Choices[0].FinishReason
 		tcs := acc.Choices[0].Message.ToolCalls
 		finalMsg.Message.ToolCalls = make([]message.ToolCall, len(tcs))
 		finalMsg.Message.Completed = true
@@ -324,6 +401,64 @@ func (p *Provider) GenerateStream(ctx context.Context, req *agent.GenerateReques
 	}
 }
 
+// convertMessages maps messages to OpenAI chat messages, resolving unsupported roles
+// through the configured fallback.
+func (p *Provider) convertMessages(msgs []*message.Message) ([]openai.ChatCompletionMessageParamUnion, error) {
+	out := make([]openai.ChatCompletionMessageParamUnion, 0, len(msgs))
+	for _, msg := range msgs {
+		role, err := agent.ResolveRole(msg.Role, p.config.UnknownRoleFallback,
+			message.RoleSystem, message.RoleUser, message.RoleAssistant, message.RoleTool)
+		if err != nil {
+			return nil, err
+		}
+		if role != message.RoleUser && msg.HasImages() {
+			return nil, fmt.Errorf("%w in %s messages", agent.ErrImagesUnsupported, role)
+		}
+		switch role {
+		case message.RoleSystem:
+			out = append(out, openai.SystemMessage(msg.Text()))
+		case message.RoleUser:
+			if msg.HasImages() {
+				out = append(out, openai.UserMessage(contentParts(msg.Content.Parts)))
+			} else {
+				out = append(out, openai.UserMessage(msg.Text()))
+			}
+		case message.RoleAssistant:
+			assistantMsg := openai.AssistantMessage(msg.Text())
+			if len(msg.ToolCalls) > 0 {
+				toolCalls, err := encodeToolCalls(msg.ToolCalls)
+				if err != nil {
+					return nil, fmt.Errorf("failed to encode tool calls: %w", err)
+				}
+				if assistantMsg.OfAssistant != nil {
+					assistantMsg.OfAssistant.ToolCalls = toolCalls
+				}
+			}
+			out = append(out, assistantMsg)
+		case message.RoleTool:
+			out = append(out, openai.ToolMessage(msg.Text(), msg.ToolID))
+		}
+	}
+	return out, nil
+}
+
+// contentParts maps text and image parts to OpenAI content parts; inline image
+// bytes are sent as base64 data URLs.
+func contentParts(parts []message.Part) []openai.ChatCompletionContentPartUnionParam {
+	out := make([]openai.ChatCompletionContentPartUnionParam, 0, len(parts))
+	for _, part := range parts {
+		if part.Image == nil {
+			out = append(out, openai.TextContentPart(part.Text))
+			continue
+		}
+		out = append(out, openai.ImageContentPart(openai.ChatCompletionContentPartImageImageURLParam{
+			URL:    part.Image.DataURL(),
+			Detail: part.Image.Detail,
+		}))
+	}
+	return out
+}
+
 func encodeToolCalls(calls []message.ToolCall) ([]openai.ChatCompletionMessageToolCallUnionParam, error) {
 	if len(calls) == 0 {
 		return nil, nil
@@ -350,3 +485,68 @@ func encodeToolCalls(calls []message.ToolCall) ([]openai.ChatCompletionMessageTo
 	}
 	return params, nil
 }
+
+// responseFormatParam converts a response format into a json_schema response_format.
+func responseFormatParam(rf *agent.ResponseFormat) openai.ChatCompletionNewParamsResponseFormatUnion {
+	return openai.ChatCompletionNewParamsResponseFormatUnion{
+		OfJSONSchema: &shared.ResponseFormatJSONSchemaParam{
+			JSONSchema: shared.ResponseFormatJSONSchemaJSONSchemaParam{
+				Name:   cmp.Or(rf.Name, "response"),
+				Schema: rf.Schema,
+			},
+		},
+	}
+}
+
+// requestOptions returns the per-request options: in Azure mode the URL of the
+// deployment serving model, followed by the extra parameters.
+func (p *Provider) requestOptions(req *agent.GenerateRequest, model string) []option.RequestOption {
+	opts := p.extraParamOptions(req)
+	if p.config.AzureEndpoint == "" {
+		return opts
+	}
+	base := strings.TrimRight(p.config.AzureEndpoint, "/") + "/openai/deployments/" + url.PathEscape(model) + "/"
+	return append([]option.RequestOption{option.WithBaseURL(base)}, opts...)
+}
+
+// extraParamOptions turns the merged config and request extra parameters into
+// request options that set the corresponding JSON body fields.
+func (p *Provider) extraParamOptions(req *agent.GenerateRequest) []option.RequestOption {
+	params := agent.MergeParams(p.config.ExtraParams, req.ExtraParams)
+	if len(params) == 0 {
+		return nil
+	}
+	keys := make([]string, 0, len(params))
+	for k := range params {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	opts := make([]option.RequestOption, 0, len(keys))
+	for _, k := range keys {
+		opts = append(opts, option.WithJSONSet(k, params[k]))
+	}
+	return opts
+}
+
+func wrapAPIError(err error) error {
+	var apiErr *openai.Error
+	if errors.As(err, &apiErr) {
+		return agent.NewAPIError("OpenAI", apiErr.StatusCode, err)
+	}
+	return agent.NewAPIError("OpenAI", 0, err)
+}
+
+// IsRetriable reports whether err from this provider is transient (rate limits,
+// timeouts, server errors) and worth retrying.
+func IsRetriable(err error) bool {
+	return agent.IsRetriable(err)
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
@@ -0,0 +1,112 @@
+package openai
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/sweetpotato0/ai-allin/agent"
+	"github.com/sweetpotato0/ai-allin/message"
+)
+
+const completionBody = `{"id":"chatcmpl-1","object":"chat.completion","created":1,"model":"gpt-4o","choices":[{"index":0,"finish_reason":"stop","message":{"role":"assistant","content":"hi there"}}],"usage":{"prompt_tokens":3,"completion_tokens":2,"total_tokens":5}}`
+
+// capturedRequest is what the test server saw of a chat completion call.
+type capturedRequest struct {
+	path, apiVersion, apiKey, authorization string
+	body                                    map[string]any
+}
+
+func newCompletionServer(t *testing.T) (*httptest.Server, *capturedRequest) {
+	t.Helper()
+	got := &capturedRequest{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		raw, _ := io.ReadAll(r.Body)
+		got.path = r.URL.EscapedPath()
+		got.apiVersion = r.URL.Query().Get("api-version")
+		got.apiKey = r.Header.Get("api-key")
+		got.authorization = r.Header.Get("Authorization")
+		if err := json.Unmarshal(raw, &got.body); err != nil {
+			t.Errorf("invalid request body %q: %v", raw, err)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = io.WriteString(w, completionBody)
+	}))
+	t.Cleanup(srv.Close)
+	return srv, got
+}
+
+func TestAzureRequests(t *testing.T) {
+	cases := []struct {
+		name        string
+		apiVersion  string
+		reqModel    string
+		wantPath    string
+		wantVersion string
+	}{
+		{"default version", "", "", "/openai/deployments/my-gpt/chat/completions", DefaultAzureAPIVersion},
+		{"explicit version", "2025-01-01-preview", "", "/openai/deployments/my-gpt/chat/completions", "2025-01-01-preview"},
+		{"request model selects the deployment", "", "other deploy", "/openai/deployments/other%20deploy/chat/completions", DefaultAzureAPIVersion},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Setenv("OPENAI_API_KEY", "")
+			t.Setenv("OPENAI_BASE_URL", "")
+			srv, got := newCompletionServer(t)
+			cfg := DefaultConfig().WithAPIKey("azure-key").WithModel("my-gpt").WithAzure(srv.URL+"/", tc.apiVersion)
+			p := New(cfg)
+
+			resp, err := p.Generate(context.Background(), &agent.GenerateRequest{
+				Messages:    []*message.Message{message.NewMessage(message.RoleUser, "hello")},
+				Model:       tc.reqModel,
+				ExtraParams: map[string]any{"top_p": 0.5},
+			})
+			if err != nil {
+				t.Fatalf("Generate: %v", err)
+			}
+			if resp.Provider != AzureProviderName || resp.Message.Text() != "hi there" {
+				t.Fatalf("unexpected response %+v", resp)
+			}
+			if got.path != tc.wantPath {
+				t.Fatalf("path = %q, want %q", got.path, tc.wantPath)
+			}
+			if got.apiVersion != tc.wantVersion {
+				t.Fatalf("api-version = %q, want %q", got.apiVersion, tc.wantVersion)
+			}
+			if got.apiKey != "azure-key" || got.authorization != "" {
+				t.Fatalf("api-key = %q, Authorization = %q; want only the api-key header", got.apiKey, got.authorization)
+			}
+			if got.body["top_p"] != 0.5 {
+				t.Fatalf("expected extra params in the body, got %v", got.body)
+			}
+		})
+	}
+}
+
+func TestOpenAIRequests(t *testing.T) {
+	t.Setenv("OPENAI_API_KEY", "")
+	srv, got := newCompletionServer(t)
+	p := New(DefaultConfig().WithAPIKey("sk-test").WithBaseURL(srv.URL + "/v1/"))
+
+	resp, err := p.Generate(context.Background(), &agent.GenerateRequest{
+		Messages: []*message.Message{message.NewMessage(message.RoleUser, "hello")},
+	})
+	if err != nil {
+		t.Fatalf("Generate: %v", err)
+	}
+	if resp.Provider != ProviderName || resp.Usage.TotalTokens != 5 {
+		t.Fatalf("unexpected response %+v", resp)
+	}
+	if got.path != "/v1/chat/completions" || got.apiVersion != "" {
+		t.Fatalf("unexpected request to %q with api-version %q", got.path, got.apiVersion)
+	}
+	if got.authorization != "Bearer sk-test" || got.apiKey != "" {
+		t.Fatalf("Authorization = %q, api-key = %q; want a bearer token only", got.authorization, got.apiKey)
+	}
+	if got.body["model"] != "gpt-4o-mini" {
+		t.Fatalf("model = %v, want the configured model", got.body["model"])
+	}
+}
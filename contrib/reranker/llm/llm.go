@@ -0,0 +1,273 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/sweetpotato0/ai-allin/agent"
+	"github.com/sweetpotato0/ai-allin/message"
+	"github.com/sweetpotato0/ai-allin/rag/reranker"
+)
+
+// DefaultPrompt instructs the model to grade every passage and answer with JSON.
+const DefaultPrompt = `You are a search relevance judge. Rate how well each numbered passage answers the query on a scale from 0 (irrelevant) to 10 (fully answers it).
+Respond with JSON only, in the form {"scores":[{"index":0,"score":7}]}, with one entry per passage.`
+
+// maxScore is the top of the scale the model grades on; results are normalized to [0, 1].
+const maxScore = 10
+
+var errParse = errors.New("unparseable relevance scores")
+
+var _ reranker.Reranker = (*Reranker)(nil)
+
+// Reranker asks a chat model to grade candidates against the query and reorders
+// them by that grade. Candidates are sent in batches, one call per batch. When
+// the query is missing or an answer cannot be parsed, the candidates keep their
+// input order.
+type Reranker struct {
+	llm       agent.LLMClient
+	prompt    string
+	topK      int
+	batchSize int
+	maxChars  int
+}
+
+// Option customises the LLM reranker.
+type Option func(*Reranker)
+
+// WithTopK limits how many results Rank returns (default 8). Zero keeps them all.
+func WithTopK(k int) Option {
+	return func(r *Reranker) {
+		if k >= 0 {
+			r.topK = k
+		}
+	}
+}
+
+// WithBatchSize sets how many candidates are graded per model call (default 20).
+func WithBatchSize(n int) Option {
+	return func(r *Reranker) {
+		if n > 0 {
+			r.batchSize = n
+		}
+	}
+}
+
+// WithMaxChars truncates each candidate to n characters in the prompt (default 1000).
+func WithMaxChars(n int) Option {
+	return func(r *Reranker) {
+		if n > 0 {
+			r.maxChars = n
+		}
+	}
+}
+
+// WithPrompt overrides the system prompt. It must ask for the JSON shape of DefaultPrompt.
+func WithPrompt(prompt string) Option {
+	return func(r *Reranker) {
+		if strings.TrimSpace(prompt) != "" {
+			r.prompt = prompt
+		}
+	}
+}
+
+// New creates a reranker backed by llm.
+func New(llm agent.LLMClient, opts ...Option) *Reranker {
+	r := &Reranker{
+		llm:       llm,
+		prompt:    DefaultPrompt,
+		topK:      8,
+		batchSize: 20,
+		maxChars:  1000,
+	}
+	for _, opt := range opts {
+		opt(r)
+	}
+	return r
+}
+
+// Rank implements reranker.Reranker. The query is read from the context set by
+// reranker.ContextWithQuery. Scores are the model's grades scaled to [0, 1];
+// candidates the model skipped score 0.
+func (r *Reranker) Rank(ctx context.Context, queryVector []float32, candidates []reranker.Candidate) ([]reranker.Result, error) {
+	if len(candidates) == 0 {
+		return nil, nil
+	}
+	query, ok := reranker.QueryFromContext(ctx)
+	if !ok || strings.TrimSpace(query) == "" || r.llm == nil {
+		return r.inputOrder(candidates), nil
+	}
+
+	scores := make([]float32, len(candidates))
+	for start := 0; start < len(candidates); start += r.batchSize {
+		end := min(start+r.batchSize, len(candidates))
+		batch, err := r.score(ctx, query, candidates[start:end])
+		if errors.Is(err, errParse) {
+			return r.inputOrder(candidates), nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		copy(scores[start:end], batch)
+	}
+
+	results := make([]reranker.Result, len(candidates))
+	for i, cand := range candidates {
+		results[i] = reranker.Result{Chunk: cand.Chunk, Score: scores[i]}
+	}
+	sort.SliceStable(results, func(i, j int) bool {
+		return results[i].Score > results[j].Score
+	})
+	return r.limit(results), nil
+}
+
+// score grades one batch and returns a normalized score per candidate.
+func (r *Reranker) score(ctx context.Context, query string, batch []reranker.Candidate) ([]float32, error) {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "Query: %s\n\nPassages:\n", query)
+	for i, cand := range batch {
+		fmt.Fprintf(&sb, "[%d] %s\n\n", i, truncate(cand.Chunk.Content, r.maxChars))
+	}
+	sb.WriteString("Return JSON only.")
+
+	temperature := 0.0
+	req := &agent.GenerateRequest{
+		Messages: []*message.Message{
+			message.NewMessage(message.RoleSystem, r.prompt),
+			message.NewMessage(message.RoleUser, sb.String()),
+		},
+		Temperature: &temperature,
+	}
+	if client, ok := r.llm.(agent.StructuredOutputClient); ok && client.SupportsResponseFormat() {
+		req.ResponseFormat = &agent.ResponseFormat{Name: "relevance_scores", Schema: scoresSchema}
+	}
+	resp, err := r.llm.Generate(ctx, req)
+	if err != nil {
+		return nil, fmt.Errorf("llm rerank failed: %w", err)
+	}
+	if resp == nil || resp.Message == nil {
+		return nil, errParse
+	}
+	return parseScores(resp.Message.Text(), len(batch))
+}
+
+var scoresSchema = map[string]any{
+	"type": "object",
+	"properties": map[string]any{
+		"scores": map[string]any{
+			"type": "array",
+			"items": map[string]any{
+				"type": "object",
+				"properties": map[string]any{
+					"index": map[string]any{"type": "integer"},
+					"score": map[string]any{"type": "number"},
+				},
+				"required":             []string{"index", "score"},
+				"additionalProperties": false,
+			},
+		},
+	},
+	"required":             []string{"scores"},
+	"additionalProperties": false,
+}
+
+// parseScores extracts the grades from the model output. Besides the requested
+// {"scores":[{"index":0,"score":7}]} it accepts a bare array of such objects or
+// of numbers in passage order, code fences, surrounding prose and scores written
+// as strings. Out-of-range indexes are ignored and grades are clamped to the scale.
+func parseScores(raw string, n int) ([]float32, error) {
+	payload, ok := extractJSON(raw)
+	if !ok {
+		return nil, errParse
+	}
+	var decoded any
+	if err := json.Unmarshal([]byte(payload), &decoded); err != nil {
+		return nil, errParse
+	}
+	if obj, isObj := decoded.(map[string]any); isObj {
+		decoded = obj["scores"]
+	}
+	items, isList := decoded.([]any)
+	if !isList {
+		return nil, errParse
+	}
+
+	scores := make([]float32, n)
+	found := false
+	for pos, item := range items {
+		index, grade := pos, item
+		if obj, isObj := item.(map[string]any); isObj {
+			idx, ok := number(obj["index"])
+			if !ok {
+				continue
+			}
+			index, grade = int(idx), obj["score"]
+		}
+		value, ok := number(grade)
+		if !ok || index < 0 || index >= n {
+			continue
+		}
+		scores[index] = float32(min(max(value, 0), maxScore) / maxScore)
+		found = true
+	}
+	if !found {
+		return nil, errParse
+	}
+	return scores, nil
+}
+
+// extractJSON returns the outermost JSON object or array embedded in raw.
+func extractJSON(raw string) (string, bool) {
+	start := strings.IndexAny(raw, "{[")
+	if start < 0 {
+		return "", false
+	}
+	closing := "}"
+	if raw[start] == '[' {
+		closing = "]"
+	}
+	end := strings.LastIndex(raw, closing)
+	if end < start {
+		return "", false
+	}
+	return raw[start : end+1], true
+}
+
+func number(v any) (float64, bool) {
+	switch val := v.(type) {
+	case float64:
+		return val, true
+	case string:
+		f, err := strconv.ParseFloat(strings.TrimSpace(val), 64)
+		return f, err == nil
+	}
+	return 0, false
+}
+
+func (r *Reranker) inputOrder(candidates []reranker.Candidate) []reranker.Result {
+	results := make([]reranker.Result, len(candidates))
+	for i, cand := range candidates {
+		results[i] = reranker.Result{Chunk: cand.Chunk, Score: cand.Score}
+	}
+	return r.limit(results)
+}
+
+func (r *Reranker) limit(results []reranker.Result) []reranker.Result {
+	if r.topK > 0 && len(results) > r.topK {
+		return results[:r.topK]
+	}
+	return results
+}
+
+func truncate(text string, maxChars int) string {
+	runes := []rune(text)
+	if len(runes) <= maxChars {
+		return text
+	}
+	return string(runes[:maxChars]) + "…"
+}
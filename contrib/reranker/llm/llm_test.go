@@ -0,0 +1,147 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/sweetpotato0/ai-allin/agent"
+	"github.com/sweetpotato0/ai-allin/contrib/retrieval/hybrid"
+	"github.com/sweetpotato0/ai-allin/contrib/vector/inmemory"
+	"github.com/sweetpotato0/ai-allin/message"
+	"github.com/sweetpotato0/ai-allin/rag/document"
+	"github.com/sweetpotato0/ai-allin/rag/embedder"
+	"github.com/sweetpotato0/ai-allin/rag/reranker"
+	"github.com/sweetpotato0/ai-allin/rag/tokenizer"
+)
+
+// scriptedLLM answers each call with the next reply and records the prompts.
+type scriptedLLM struct {
+	replies []string
+	err     error
+	prompts []string
+}
+
+func (s *scriptedLLM) Generate(ctx context.Context, req *agent.GenerateRequest) (*agent.GenerateResponse, error) {
+	s.prompts = append(s.prompts, req.Messages[len(req.Messages)-1].Text())
+	if s.err != nil {
+		return nil, s.err
+	}
+	reply := s.replies[0]
+	if len(s.replies) > 1 {
+		s.replies = s.replies[1:]
+	}
+	return &agent.GenerateResponse{Message: message.NewMessage(message.RoleAssistant, reply)}, nil
+}
+
+func (s *scriptedLLM) SetTemperature(float64) {}
+func (s *scriptedLLM) SetMaxTokens(int64)     {}
+func (s *scriptedLLM) SetModel(string)        {}
+
+func candidates(texts ...string) []reranker.Candidate {
+	out := make([]reranker.Candidate, len(texts))
+	for i, text := range texts {
+		out[i] = reranker.Candidate{Chunk: document.Chunk{ID: text, Content: text}, Score: float32(len(texts) - i)}
+	}
+	return out
+}
+
+func ids(results []reranker.Result) string {
+	var out []string
+	for _, res := range results {
+		out = append(out, res.Chunk.ID)
+	}
+	return strings.Join(out, ",")
+}
+
+func TestRankOrdersByModelScores(t *testing.T) {
+	llm := &scriptedLLM{replies: []string{"```json\n{\"scores\":[{\"index\":0,\"score\":2},{\"index\":1,\"score\":9},{\"index\":2,\"score\":\"5\"}]}\n```"}}
+	ctx := reranker.ContextWithQuery(context.Background(), "what is rust")
+	results, err := New(llm, WithTopK(2)).Rank(ctx, nil, candidates("a", "b", "c"))
+	if err != nil {
+		t.Fatalf("Rank failed: %v", err)
+	}
+	if ids(results) != "b,c" || results[0].Score != 0.9 {
+		t.Fatalf("unexpected results %+v", results)
+	}
+	if len(llm.prompts) != 1 || !strings.Contains(llm.prompts[0], "Query: what is rust") || !strings.Contains(llm.prompts[0], "[2] c") {
+		t.Fatalf("expected one prompt with every candidate, got %q", llm.prompts)
+	}
+}
+
+func TestRankBatchesCandidates(t *testing.T) {
+	llm := &scriptedLLM{replies: []string{"Scores: [1, 8]", `[{"index": 0, "score": 10}, {"index": 7, "score": 10}]`}}
+	ctx := reranker.ContextWithQuery(context.Background(), "q")
+	results, err := New(llm, WithBatchSize(2), WithTopK(0)).Rank(ctx, nil, candidates("a", "b", "c"))
+	if err != nil {
+		t.Fatalf("Rank failed: %v", err)
+	}
+	if len(llm.prompts) != 2 || ids(results) != "c,b,a" {
+		t.Fatalf("unexpected results %s after %d calls", ids(results), len(llm.prompts))
+	}
+}
+
+func TestRankFallsBackToInputOrder(t *testing.T) {
+	for _, reply := range []string{"I think passage b is best.", `{"scores": "high"}`, `[{"index": 9, "score": 3}]`} {
+		llm := &scriptedLLM{replies: []string{reply}}
+		ctx := reranker.ContextWithQuery(context.Background(), "q")
+		results, err := New(llm).Rank(ctx, nil, candidates("a", "b", "c"))
+		if err != nil || ids(results) != "a,b,c" || results[0].Score != 3 {
+			t.Fatalf("reply %q: expected input order, got %+v (%v)", reply, results, err)
+		}
+	}
+
+	// Without a query the model is not consulted.
+	llm := &scriptedLLM{}
+	results, err := New(llm).Rank(context.Background(), nil, candidates("a", "b"))
+	if err != nil || ids(results) != "a,b" || len(llm.prompts) != 0 {
+		t.Fatalf("expected input order without calls, got %s (%v)", ids(results), err)
+	}
+}
+
+func TestRankReturnsModelErrors(t *testing.T) {
+	modelErr := errors.New("model unavailable")
+	llm := &scriptedLLM{err: modelErr}
+	ctx := reranker.ContextWithQuery(context.Background(), "q")
+	if _, err := New(llm).Rank(ctx, nil, candidates("a")); !errors.Is(err, modelErr) {
+		t.Fatalf("expected the model error, got %v", err)
+	}
+}
+
+// constEmbedder gives every text the same vector so only the reranker decides the order.
+type constEmbedder struct{}
+
+func (constEmbedder) Embed(ctx context.Context, text string) ([]float32, error) {
+	return []float32{1, 0}, nil
+}
+
+func (e constEmbedder) EmbedBatch(ctx context.Context, texts []string) ([][]float32, error) {
+	out := make([][]float32, len(texts))
+	for i := range texts {
+		out[i] = []float32{1, 0}
+	}
+	return out, nil
+}
+
+func (constEmbedder) Dimension() int { return 2 }
+
+func TestRerankerPlugsIntoHybridEngine(t *testing.T) {
+	llm := &scriptedLLM{replies: []string{`{"scores":[{"index":0,"score":10},{"index":1,"score":10}]}`}}
+	engine, err := hybrid.New(inmemory.NewInMemoryVectorStore(), tokenizer.NewSimpleTokenizer(), embedder.NewVectorAdapter(constEmbedder{}),
+		hybrid.WithReranker(New(llm)), hybrid.WithWeights(1, 0))
+	if err != nil {
+		t.Fatalf("hybrid.New failed: %v", err)
+	}
+	ctx := context.Background()
+	if err := engine.IndexDocuments(ctx, document.Document{ID: "doc", Content: "Paris is the capital of France."}); err != nil {
+		t.Fatalf("IndexDocuments failed: %v", err)
+	}
+	results, err := engine.Search(ctx, "capital of France")
+	if err != nil || len(results) != 1 || results[0].Score != 1 {
+		t.Fatalf("unexpected search results %+v (%v)", results, err)
+	}
+	if len(llm.prompts) != 1 || !strings.Contains(llm.prompts[0], "Query: capital of France") {
+		t.Fatalf("expected the reranker to see the query, got %q", llm.prompts)
+	}
+}
@@ -18,6 +18,8 @@ import (
 	"github.com/sweetpotato0/ai-allin/vector"
 )
 
+var _ agentic.RetrievalEngine = (*Engine)(nil)
+
 // Config configures the hybrid retrieval engine.
 type Config struct {
 	VectorTopK    int
@@ -79,7 +81,8 @@ func WithChunker(ch chunking.Chunker) Option {
 	}
 }
 
-// WithReranker overrides the reranker implementation.
+// WithReranker overrides the reranker implementation. The query text is
+// available to it through reranker.QueryFromContext.
 func WithReranker(r reranker.Reranker) Option {
 	return func(cfg *Config) {
 		if r != nil {
@@ -151,9 +154,10 @@ func (e *Engine) IndexDocuments(ctx context.Context, docs ...document.Document)
 				return err
 			}
 			if err := e.store.AddEmbedding(ctx, &vector.Embedding{
-				ID:     chunk.ID,
-				Vector: vec,
-				Text:   chunk.Content,
+				ID:       chunk.ID,
+				Vector:   vec,
+				Text:     chunk.Content,
+				Metadata: mergeMetadata(doc, chunk),
 			}); err != nil {
 				return err
 			}
@@ -169,11 +173,24 @@ func (e *Engine) IndexDocuments(ctx context.Context, docs ...document.Document)
 
 // Search returns retrieval results blending vector and keyword matches.
 func (e *Engine) Search(ctx context.Context, query string) ([]agentic.RetrievalResult, error) {
+	return e.SearchWithFilter(ctx, query, nil)
+}
+
+// SearchWithFilter works like Search but only returns chunks whose metadata,
+// merged with their document's metadata, matches filter. The filter is pushed
+// down to stores implementing vector.FilteredSearcher and applied to keyword
+// hits before they are cut to KeywordTopK.
+func (e *Engine) SearchWithFilter(ctx context.Context, query string, filter map[string]any) ([]agentic.RetrievalResult, error) {
 	queryVec, err := e.embedder.EmbedQuery(ctx, query)
 	if err != nil {
 		return nil, err
 	}
-	vecHits, err := e.store.Search(ctx, queryVec, e.cfg.VectorTopK)
+	var vecHits []*vector.Embedding
+	if searcher, ok := e.store.(vector.FilteredSearcher); ok && len(filter) > 0 {
+		vecHits, err = searcher.SearchWithFilter(ctx, queryVec, e.cfg.VectorTopK, filter)
+	} else {
+		vecHits, err = e.store.Search(ctx, queryVec, e.cfg.VectorTopK)
+	}
 	if err != nil {
 		return nil, err
 	}
@@ -181,7 +198,7 @@ func (e *Engine) Search(ctx context.Context, query string) ([]agentic.RetrievalR
 	vecCandidates := make([]reranker.Candidate, 0, len(vecHits))
 	for _, hit := range vecHits {
 		chunk, ok := e.chunk(hit.ID)
-		if !ok {
+		if !ok || !e.matchesFilter(chunk, filter) {
 			continue
 		}
 		vecCandidates = append(vecCandidates, reranker.Candidate{
@@ -193,7 +210,7 @@ func (e *Engine) Search(ctx context.Context, query string) ([]agentic.RetrievalR
 
 	var vecResults []reranker.Result
 	if len(vecCandidates) > 0 && e.reranker != nil {
-		vecResults, err = e.reranker.Rank(ctx, queryVec, vecCandidates)
+		vecResults, err = e.reranker.Rank(reranker.ContextWithQuery(ctx, query), queryVec, vecCandidates)
 		if err != nil {
 			return nil, err
 		}
@@ -202,7 +219,10 @@ func (e *Engine) Search(ctx context.Context, query string) ([]agentic.RetrievalR
 		}
 	}
 
-	keywordHits := e.keyword.search(query, e.cfg.KeywordTopK)
+	keywordHits := e.keyword.search(query, e.cfg.KeywordTopK, func(id string) bool {
+		chunk, ok := e.chunk(id)
+		return ok && e.matchesFilter(chunk, filter)
+	})
 
 	type scoredChunk struct {
 		chunk document.Chunk
@@ -253,6 +273,45 @@ func (e *Engine) Document(id string) (document.Document, bool) {
 	return doc.Clone(), ok
 }
 
+// Delete removes the documents with the given IDs and all of their chunks.
+func (e *Engine) Delete(ctx context.Context, ids ...string) error {
+	targets := make(map[string]struct{}, len(ids))
+	for _, id := range ids {
+		targets[id] = struct{}{}
+	}
+	e.mu.RLock()
+	var chunkIDs []string
+	for id, chunk := range e.chunks {
+		if _, ok := targets[chunk.DocumentID]; ok {
+			chunkIDs = append(chunkIDs, id)
+		}
+	}
+	e.mu.RUnlock()
+
+	if deleter, ok := e.store.(vector.BulkDeleter); ok {
+		if _, err := deleter.DeleteEmbeddings(ctx, chunkIDs...); err != nil {
+			return err
+		}
+	} else {
+		for _, id := range chunkIDs {
+			if err := e.store.DeleteEmbedding(ctx, id); err != nil {
+				return err
+			}
+		}
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	for _, id := range chunkIDs {
+		delete(e.chunks, id)
+		e.keyword.remove(id)
+	}
+	for id := range targets {
+		delete(e.documents, id)
+	}
+	return nil
+}
+
 // Clear removes all indexed state.
 func (e *Engine) Clear(ctx context.Context) error {
 	if err := e.store.Clear(ctx); err != nil {
@@ -271,6 +330,29 @@ func (e *Engine) Count(ctx context.Context) (int, error) {
 	return e.store.Count(ctx)
 }
 
+func (e *Engine) matchesFilter(chunk document.Chunk, filter map[string]any) bool {
+	if len(filter) == 0 {
+		return true
+	}
+	doc, _ := e.Document(chunk.DocumentID)
+	return vector.MatchesFilter(mergeMetadata(doc, chunk), filter)
+}
+
+// mergeMetadata merges document and chunk metadata; document keys win.
+func mergeMetadata(doc document.Document, chunk document.Chunk) map[string]any {
+	if len(doc.Metadata) == 0 && len(chunk.Metadata) == 0 {
+		return nil
+	}
+	merged := make(map[string]any, len(doc.Metadata)+len(chunk.Metadata))
+	for k, v := range chunk.Metadata {
+		merged[k] = v
+	}
+	for k, v := range doc.Metadata {
+		merged[k] = v
+	}
+	return merged
+}
+
 func (e *Engine) chunk(id string) (document.Chunk, bool) {
 	e.mu.RLock()
 	defer e.mu.RUnlock()
@@ -327,16 +409,61 @@ func (b *bm25Index) add(chunk document.Chunk) {
 	}
 }
 
+func (b *bm25Index) remove(chunkID string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	length, ok := b.chunkLength[chunkID]
+	if !ok {
+		return
+	}
+	delete(b.chunkLength, chunkID)
+	b.totalLength -= length
+	b.docCount--
+	for term, postings := range b.postings {
+		if _, ok := postings[chunkID]; !ok {
+			continue
+		}
+		delete(postings, chunkID)
+		b.docFreq[term]--
+		if len(postings) == 0 {
+			delete(b.postings, term)
+			delete(b.docFreq, term)
+		}
+	}
+}
+
 type keywordResult struct {
 	ID    string
 	Score float32
 }
 
-func (b *bm25Index) search(query string, limit int) []keywordResult {
+// search ranks chunks for query and returns the best limit among those keep
+// accepts, so filtered-out chunks never take a slot. keep runs after the index
+// lock is released since it may consult the engine's own state.
+func (b *bm25Index) search(query string, limit int, keep func(id string) bool) []keywordResult {
 	terms := unique(tokenize(query))
 	if len(terms) == 0 {
 		return nil
 	}
+	scores := b.score(terms)
+	results := make([]keywordResult, 0, len(scores))
+	for id, score := range scores {
+		if keep != nil && !keep(id) {
+			continue
+		}
+		results = append(results, keywordResult{ID: id, Score: float32(score)})
+	}
+	sort.Slice(results, func(i, j int) bool {
+		return results[i].Score > results[j].Score
+	})
+	if limit > 0 && len(results) > limit {
+		results = results[:limit]
+	}
+	return results
+}
+
+// score returns the BM25 score of every chunk matching at least one term.
+func (b *bm25Index) score(terms []string) map[string]float64 {
 	b.mu.RLock()
 	defer b.mu.RUnlock()
 	if b.docCount == 0 {
@@ -358,17 +485,7 @@ func (b *bm25Index) search(query string, limit int) []keywordResult {
 			scores[chunkID] += idf * (numerator / denominator)
 		}
 	}
-	results := make([]keywordResult, 0, len(scores))
-	for id, score := range scores {
-		results = append(results, keywordResult{ID: id, Score: float32(score)})
-	}
-	sort.Slice(results, func(i, j int) bool {
-		return results[i].Score > results[j].Score
-	})
-	if limit > 0 && len(results) > limit {
-		results = results[:limit]
-	}
-	return results
+	return scores
 }
 
 func tokenize(content string) []string {
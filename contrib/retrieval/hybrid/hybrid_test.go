@@ -44,6 +44,13 @@ func (s *stubVectorStore) Clear(ctx context.Context) error {
 	return nil
 }
 func (s *stubVectorStore) Count(ctx context.Context) (int, error) { return len(s.embeddings), nil }
+func (s *stubVectorStore) Stats(ctx context.Context) (vector.VectorStoreStats, error) {
+	return vector.VectorStoreStats{Chunks: len(s.embeddings)}, nil
+}
+
+func (s *stubVectorStore) WithCollection(string) vector.VectorStore {
+	return s
+}
 
 type stubEmbedder struct{}
 
@@ -82,3 +89,65 @@ func TestHybridEngineCombinesSignals(t *testing.T) {
 		t.Fatalf("expected doc-1 first, got %+v", results[0])
 	}
 }
+
+func TestHybridEngineDeleteRemovesChunks(t *testing.T) {
+	ctx := context.Background()
+	store := newStubVectorStore()
+	engine, err := New(store, tokenizer.NewSimpleTokenizer(), &stubEmbedder{}, WithChunker(chunking.NewSimpleChunker()))
+	if err != nil {
+		t.Fatalf("New error: %v", err)
+	}
+	err = engine.IndexDocuments(ctx,
+		document.Document{ID: "doc-1", Content: "AADDCC 是万能药物。"},
+		document.Document{ID: "doc-2", Content: "AADDCC 的副作用。"},
+	)
+	if err != nil {
+		t.Fatalf("index error: %v", err)
+	}
+
+	if err := engine.Delete(ctx, "doc-1", "unknown"); err != nil {
+		t.Fatalf("delete error: %v", err)
+	}
+	if _, ok := engine.Document("doc-1"); ok {
+		t.Fatal("expected doc-1 to be removed")
+	}
+	results, err := engine.Search(ctx, "AADDCC")
+	if err != nil {
+		t.Fatalf("search error: %v", err)
+	}
+	for _, res := range results {
+		if res.Chunk.DocumentID == "doc-1" {
+			t.Fatalf("expected no doc-1 chunks after delete, got %+v", res)
+		}
+	}
+	if len(results) == 0 || len(store.embeddings) != 1 {
+		t.Fatalf("expected doc-2 to remain alone, got %d results and %d embeddings", len(results), len(store.embeddings))
+	}
+}
+
+func TestHybridEngineFiltersBeforeKeywordTopK(t *testing.T) {
+	ctx := context.Background()
+	// Only keyword scores count, so tenant A's chunk scores above zero only if
+	// its keyword hit survives the cut to KeywordTopK.
+	engine, err := New(newStubVectorStore(), tokenizer.NewSimpleTokenizer(), &stubEmbedder{},
+		WithChunker(chunking.NewSimpleChunker()), WithKeywordTopK(1), WithWeights(0, 1))
+	if err != nil {
+		t.Fatalf("New error: %v", err)
+	}
+	err = engine.IndexDocuments(ctx,
+		document.Document{ID: "b-1", Content: "refund policy timeline", Metadata: map[string]any{"tenant": "b"}},
+		document.Document{ID: "b-2", Content: "refund policy timeline details", Metadata: map[string]any{"tenant": "b"}},
+		document.Document{ID: "a-1", Content: "refund", Metadata: map[string]any{"tenant": "a"}},
+	)
+	if err != nil {
+		t.Fatalf("index error: %v", err)
+	}
+
+	results, err := engine.SearchWithFilter(ctx, "refund policy timeline", map[string]any{"tenant": vector.AnyOf{"a"}})
+	if err != nil {
+		t.Fatalf("search error: %v", err)
+	}
+	if len(results) != 1 || results[0].Chunk.DocumentID != "a-1" || results[0].Score <= 0 {
+		t.Fatalf("expected tenant A's keyword hit, got %+v", results)
+	}
+}
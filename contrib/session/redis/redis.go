@@ -1,16 +1,25 @@
 package redis
 
 import (
+	"cmp"
 	"context"
 	"encoding/json"
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/redis/go-redis/v9"
 	"github.com/sweetpotato0/ai-allin/session"
 )
 
-// RedisStore implements session storage using Redis.
+// DefaultPrefix namespaces session keys when RedisConfig.Prefix is empty.
+const DefaultPrefix = "ai-allin:session:"
+
+// scanBatch is the COUNT hint passed to SCAN when listing sessions.
+const scanBatch = 100
+
+// RedisStore implements session storage using Redis. Each record is stored as
+// JSON under Prefix+ID and expires after TTL when one is set.
 type RedisStore struct {
 	client *redis.Client
 	prefix string
@@ -22,8 +31,8 @@ type RedisConfig struct {
 	Addr     string
 	Password string
 	DB       int
-	Prefix   string
-	TTL      time.Duration
+	Prefix   string        // Key prefix; DefaultPrefix when empty
+	TTL      time.Duration // Expiry refreshed on every Save; zero keeps records forever
 }
 
 // NewRedisStore creates a new Redis-based session store.
@@ -31,7 +40,7 @@ func NewRedisStore(config *RedisConfig) *RedisStore {
 	if config == nil {
 		config = &RedisConfig{
 			Addr:   "localhost:6379",
-			Prefix: "ai-allin:session:",
+			Prefix: DefaultPrefix,
 			TTL:    24 * time.Hour,
 		}
 	}
@@ -44,7 +53,7 @@ func NewRedisStore(config *RedisConfig) *RedisStore {
 
 	return &RedisStore{
 		client: client,
-		prefix: config.Prefix,
+		prefix: cmp.Or(config.Prefix, DefaultPrefix),
 		ttl:    config.TTL,
 	}
 }
@@ -66,11 +75,6 @@ func (s *RedisStore) Save(ctx context.Context, record *session.Record) error {
 		return fmt.Errorf("failed to save session: %w", err)
 	}
 
-	setKey := s.setKey()
-	if err := s.client.SAdd(ctx, setKey, record.ID).Err(); err != nil {
-		return fmt.Errorf("failed to add session to index: %w", err)
-	}
-
 	return nil
 }
 
@@ -95,22 +99,36 @@ func (s *RedisStore) Load(ctx context.Context, id string) (*session.Record, erro
 
 // Delete removes a session record from Redis.
 func (s *RedisStore) Delete(ctx context.Context, id string) error {
-	key := s.sessionKey(id)
-	if err := s.client.Del(ctx, key).Err(); err != nil {
+	removed, err := s.client.Del(ctx, s.sessionKey(id)).Result()
+	if err != nil {
 		return fmt.Errorf("failed to delete session: %w", err)
 	}
-	setKey := s.setKey()
-	if err := s.client.SRem(ctx, setKey, id).Err(); err != nil {
-		return fmt.Errorf("failed to update session index: %w", err)
+	if removed == 0 {
+		return fmt.Errorf("session %s not found", id)
 	}
 	return nil
 }
 
-// List returns all session IDs.
+// List returns all session IDs. Keys are walked with SCAN rather than KEYS, so
+// large keyspaces are never blocked by a single command.
 func (s *RedisStore) List(ctx context.Context) ([]string, error) {
-	setKey := s.setKey()
-	ids, err := s.client.SMembers(ctx, setKey).Result()
-	if err != nil {
+	seen := make(map[string]struct{})
+	ids := make([]string, 0)
+	iter := s.client.Scan(ctx, 0, escapeGlob(s.prefix)+"*", scanBatch).Iterator()
+	for iter.Next(ctx) {
+		key := iter.Val()
+		if key == s.legacyIndexKey() {
+			continue
+		}
+		// SCAN may return a key more than once.
+		id := strings.TrimPrefix(key, s.prefix)
+		if _, dup := seen[id]; dup {
+			continue
+		}
+		seen[id] = struct{}{}
+		ids = append(ids, id)
+	}
+	if err := iter.Err(); err != nil {
 		return nil, fmt.Errorf("failed to list sessions: %w", err)
 	}
 	return ids, nil
@@ -118,12 +136,11 @@ func (s *RedisStore) List(ctx context.Context) ([]string, error) {
 
 // Count returns the number of stored sessions.
 func (s *RedisStore) Count(ctx context.Context) (int, error) {
-	setKey := s.setKey()
-	count, err := s.client.SCard(ctx, setKey).Result()
+	ids, err := s.List(ctx)
 	if err != nil {
 		return 0, fmt.Errorf("failed to count sessions: %w", err)
 	}
-	return int(count), nil
+	return len(ids), nil
 }
 
 // Exists checks if a session exists.
@@ -150,6 +167,21 @@ func (s *RedisStore) sessionKey(id string) string {
 	return s.prefix + id
 }
 
-func (s *RedisStore) setKey() string {
+// legacyIndexKey is the set older versions kept session IDs in. It went stale
+// once records expired, so it is no longer maintained and List skips it.
+func (s *RedisStore) legacyIndexKey() string {
 	return s.prefix + "set"
 }
+
+// escapeGlob escapes SCAN MATCH metacharacters in a literal key prefix.
+func escapeGlob(s string) string {
+	var sb strings.Builder
+	for _, r := range s {
+		switch r {
+		case '*', '?', '[', ']', '\\':
+			sb.WriteByte('\\')
+		}
+		sb.WriteRune(r)
+	}
+	return sb.String()
+}
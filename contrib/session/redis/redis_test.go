@@ -0,0 +1,110 @@
+package redis
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/sweetpotato0/ai-allin/message"
+	"github.com/sweetpotato0/ai-allin/session"
+)
+
+// TestRedisStore tests the Redis session store.
+// Note: This test requires a running Redis server
+// Set the REDIS_ADDR environment variable (e.g. localhost:6379) to run it
+func TestRedisStore(t *testing.T) {
+	addr := os.Getenv("REDIS_ADDR")
+	if addr == "" {
+		t.Skip("REDIS_ADDR not set, skipping Redis session store tests")
+	}
+
+	ctx := context.Background()
+	prefix := fmt.Sprintf("ai-allin-test:%d:", time.Now().UnixNano())
+	store := NewRedisStore(&RedisConfig{Addr: addr, Prefix: prefix})
+	defer store.Close()
+	if err := store.Ping(ctx); err != nil {
+		t.Skipf("Failed to connect to Redis: %v", err)
+	}
+	t.Cleanup(func() {
+		ids, _ := store.List(ctx)
+		for _, id := range ids {
+			_ = store.Delete(ctx, id)
+		}
+	})
+
+	t.Run("save and load round trip", func(t *testing.T) {
+		record := &session.Record{
+			ID:       "sess1",
+			Type:     session.TypeSingleAgent,
+			State:    session.StateActive,
+			Messages: []*message.Message{message.NewMessage(message.RoleUser, "hello")},
+			Metadata: map[string]any{"user_id": "u1"},
+		}
+		if err := store.Save(ctx, record); err != nil {
+			t.Fatalf("Save failed: %v", err)
+		}
+		loaded, err := store.Load(ctx, "sess1")
+		if err != nil {
+			t.Fatalf("Load failed: %v", err)
+		}
+		if loaded.ID != "sess1" || len(loaded.Messages) != 1 || loaded.Messages[0].Text() != "hello" || loaded.Metadata["user_id"] != "u1" {
+			t.Errorf("unexpected loaded record %+v", loaded)
+		}
+		if err := store.Save(ctx, nil); err == nil {
+			t.Error("expected error saving nil record")
+		}
+	})
+
+	t.Run("list count and exists", func(t *testing.T) {
+		if err := store.Save(ctx, &session.Record{ID: "sess2"}); err != nil {
+			t.Fatalf("Save failed: %v", err)
+		}
+		ids, err := store.List(ctx)
+		if err != nil {
+			t.Fatalf("List failed: %v", err)
+		}
+		slices.Sort(ids)
+		if !slices.Equal(ids, []string{"sess1", "sess2"}) {
+			t.Errorf("expected [sess1 sess2], got %v", ids)
+		}
+		if count, err := store.Count(ctx); err != nil || count != 2 {
+			t.Errorf("expected count 2, got %d (%v)", count, err)
+		}
+		if exists, err := store.Exists(ctx, "sess2"); err != nil || !exists {
+			t.Errorf("expected sess2 to exist, got %v (%v)", exists, err)
+		}
+		if exists, _ := store.Exists(ctx, "missing"); exists {
+			t.Error("expected missing session not to exist")
+		}
+	})
+
+	t.Run("delete", func(t *testing.T) {
+		if err := store.Delete(ctx, "sess2"); err != nil {
+			t.Fatalf("Delete failed: %v", err)
+		}
+		if _, err := store.Load(ctx, "sess2"); err == nil {
+			t.Error("expected error loading deleted session")
+		}
+		if err := store.Delete(ctx, "sess2"); err == nil {
+			t.Error("expected error deleting missing session")
+		}
+	})
+
+	t.Run("records expire after ttl", func(t *testing.T) {
+		expiring := NewRedisStore(&RedisConfig{Addr: addr, Prefix: prefix + "ttl:", TTL: 100 * time.Millisecond})
+		defer expiring.Close()
+		if err := expiring.Save(ctx, &session.Record{ID: "short"}); err != nil {
+			t.Fatalf("Save failed: %v", err)
+		}
+		time.Sleep(300 * time.Millisecond)
+		if exists, _ := expiring.Exists(ctx, "short"); exists {
+			t.Error("expected record to expire")
+		}
+		if count, _ := expiring.Count(ctx); count != 0 {
+			t.Errorf("expected expired record to drop out of Count, got %d", count)
+		}
+	})
+}
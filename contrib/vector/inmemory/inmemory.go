@@ -6,26 +6,45 @@ import (
 	"sort"
 	"sync"
 
+	errorskg "github.com/sweetpotato0/ai-allin/pkg/errors"
 	"github.com/sweetpotato0/ai-allin/vector"
 )
 
-// InMemoryVectorStore implements VectorStore using in-memory storage
+// InMemoryVectorStore implements VectorStore using in-memory storage.
+// Views returned by WithCollection share the underlying storage.
 type InMemoryVectorStore struct {
-	embeddings map[string]*vector.Embedding
-	mu         sync.RWMutex
+	shared     *collections
+	collection string
+}
+
+// collections holds the embeddings of every collection, keyed by collection name.
+type collections struct {
+	mu   sync.RWMutex
+	data map[string]map[string]*vector.Embedding
 }
 
 // NewInMemoryVectorStore creates a new in-memory vector store
 func NewInMemoryVectorStore() *InMemoryVectorStore {
 	return &InMemoryVectorStore{
-		embeddings: make(map[string]*vector.Embedding),
+		shared:     &collections{data: make(map[string]map[string]*vector.Embedding)},
+		collection: vector.DefaultCollection,
 	}
 }
 
+// WithCollection returns a view of the store scoped to the named collection.
+func (s *InMemoryVectorStore) WithCollection(name string) vector.VectorStore {
+	return &InMemoryVectorStore{shared: s.shared, collection: vector.CollectionName(name)}
+}
+
+// embeddings returns the collection's embeddings; the caller must hold the lock.
+func (s *InMemoryVectorStore) embeddings() map[string]*vector.Embedding {
+	return s.shared.data[s.collection]
+}
+
 // AddEmbedding adds a new embedding to the store
 func (s *InMemoryVectorStore) AddEmbedding(ctx context.Context, embedding *vector.Embedding) error {
-	s.mu.Lock()
-	defer s.mu.Unlock()
+	s.shared.mu.Lock()
+	defer s.shared.mu.Unlock()
 
 	if embedding == nil {
 		return fmt.Errorf("embedding cannot be nil")
@@ -39,14 +58,25 @@ func (s *InMemoryVectorStore) AddEmbedding(ctx context.Context, embedding *vecto
 		return fmt.Errorf("embedding vector cannot be empty")
 	}
 
-	s.embeddings[embedding.ID] = embedding
+	bucket := s.embeddings()
+	if bucket == nil {
+		bucket = make(map[string]*vector.Embedding)
+		s.shared.data[s.collection] = bucket
+	}
+	bucket[embedding.ID] = embedding
 	return nil
 }
 
 // Search finds embeddings similar to the query vector
 func (s *InMemoryVectorStore) Search(ctx context.Context, queryVector []float32, topK int) ([]*vector.Embedding, error) {
-	s.mu.RLock()
-	defer s.mu.RUnlock()
+	return s.SearchWithFilter(ctx, queryVector, topK, nil)
+}
+
+// SearchWithFilter finds embeddings similar to the query vector among those
+// whose metadata exactly matches every key of filter.
+func (s *InMemoryVectorStore) SearchWithFilter(ctx context.Context, queryVector []float32, topK int, filter map[string]any) ([]*vector.Embedding, error) {
+	s.shared.mu.RLock()
+	defer s.shared.mu.RUnlock()
 
 	if len(queryVector) == 0 {
 		return nil, fmt.Errorf("query vector cannot be empty")
@@ -62,9 +92,10 @@ func (s *InMemoryVectorStore) Search(ctx context.Context, queryVector []float32,
 		similarity float32
 	}
 
-	results := make([]result, 0, len(s.embeddings))
-	for _, emb := range s.embeddings {
-		if len(emb.Vector) != len(queryVector) {
+	bucket := s.embeddings()
+	results := make([]result, 0, len(bucket))
+	for _, emb := range bucket {
+		if len(emb.Vector) != len(queryVector) || !vector.MatchesFilter(emb.Metadata, filter) {
 			continue
 		}
 
@@ -97,43 +128,97 @@ func (s *InMemoryVectorStore) Search(ctx context.Context, queryVector []float32,
 
 // DeleteEmbedding removes an embedding by ID
 func (s *InMemoryVectorStore) DeleteEmbedding(ctx context.Context, id string) error {
-	s.mu.Lock()
-	defer s.mu.Unlock()
+	s.shared.mu.Lock()
+	defer s.shared.mu.Unlock()
 
-	if _, exists := s.embeddings[id]; !exists {
-		return fmt.Errorf("embedding not found")
+	bucket := s.embeddings()
+	if _, exists := bucket[id]; !exists {
+		return fmt.Errorf("embedding %s: %w", id, errorskg.ErrNotFound)
 	}
 
-	delete(s.embeddings, id)
+	delete(bucket, id)
 	return nil
 }
 
+// DeleteEmbeddings removes the embeddings with the given IDs, ignoring unknown ones.
+func (s *InMemoryVectorStore) DeleteEmbeddings(ctx context.Context, ids ...string) (int, error) {
+	s.shared.mu.Lock()
+	defer s.shared.mu.Unlock()
+
+	bucket := s.embeddings()
+	deleted := 0
+	for _, id := range ids {
+		if _, exists := bucket[id]; exists {
+			delete(bucket, id)
+			deleted++
+		}
+	}
+	return deleted, nil
+}
+
 // GetEmbedding retrieves a specific embedding by ID
 func (s *InMemoryVectorStore) GetEmbedding(ctx context.Context, id string) (*vector.Embedding, error) {
-	s.mu.RLock()
-	defer s.mu.RUnlock()
+	s.shared.mu.RLock()
+	defer s.shared.mu.RUnlock()
 
-	emb, exists := s.embeddings[id]
+	emb, exists := s.embeddings()[id]
 	if !exists {
-		return nil, fmt.Errorf("embedding not found")
+		return nil, fmt.Errorf("embedding %s: %w", id, errorskg.ErrNotFound)
 	}
 
 	return emb, nil
 }
 
-// Clear removes all embeddings
+// Clear removes all embeddings of the collection
 func (s *InMemoryVectorStore) Clear(ctx context.Context) error {
-	s.mu.Lock()
-	defer s.mu.Unlock()
+	s.shared.mu.Lock()
+	defer s.shared.mu.Unlock()
 
-	s.embeddings = make(map[string]*vector.Embedding)
+	delete(s.shared.data, s.collection)
 	return nil
 }
 
-// Count returns the number of embeddings
+// Count returns the number of embeddings in the collection
 func (s *InMemoryVectorStore) Count(ctx context.Context) (int, error) {
-	s.mu.RLock()
-	defer s.mu.RUnlock()
+	s.shared.mu.RLock()
+	defer s.shared.mu.RUnlock()
+
+	return len(s.embeddings()), nil
+}
+
+// Stats reports the number of stored chunks and documents and an estimate of the memory they use.
+func (s *InMemoryVectorStore) Stats(ctx context.Context) (vector.VectorStoreStats, error) {
+	s.shared.mu.RLock()
+	defer s.shared.mu.RUnlock()
+
+	bucket := s.embeddings()
+	stats := vector.VectorStoreStats{
+		Chunks:    len(bucket),
+		IndexType: "flat",
+	}
+	docs := make(map[string]struct{}, len(bucket))
+	for id, emb := range bucket {
+		docs[vector.DocumentIDFromEmbeddingID(id)] = struct{}{}
+		if stats.Dimension == 0 {
+			stats.Dimension = len(emb.Vector)
+		}
+		stats.StorageBytes += int64(len(emb.Vector)*4 + len(emb.ID) + len(emb.Text))
+	}
+	stats.Documents = len(docs)
+	return stats, nil
+}
 
-	return len(s.embeddings), nil
+// Collections returns the names of all non-empty collections, sorted.
+func (s *InMemoryVectorStore) Collections() []string {
+	s.shared.mu.RLock()
+	defer s.shared.mu.RUnlock()
+
+	names := make([]string, 0, len(s.shared.data))
+	for name, bucket := range s.shared.data {
+		if len(bucket) > 0 {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
 }
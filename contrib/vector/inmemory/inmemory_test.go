@@ -84,6 +84,53 @@ func TestInMemoryVectorStore(t *testing.T) {
 		}
 	})
 
+	t.Run("delete embeddings in bulk", func(t *testing.T) {
+		store.Clear(ctx)
+
+		for _, id := range []string{"a", "b", "c"} {
+			store.AddEmbedding(ctx, &vector.Embedding{ID: id, Text: id, Vector: []float32{1, 0, 0}})
+		}
+		deleted, err := store.DeleteEmbeddings(ctx, "a", "c", "missing")
+		if err != nil {
+			t.Fatalf("DeleteEmbeddings failed: %v", err)
+		}
+		if count, _ := store.Count(ctx); deleted != 2 || count != 1 {
+			t.Errorf("Expected 2 deleted and 1 left, got %d and %d", deleted, count)
+		}
+	})
+
+	t.Run("search with metadata filter", func(t *testing.T) {
+		store.Clear(ctx)
+
+		store.AddEmbedding(ctx, &vector.Embedding{ID: "kb", Text: "kb", Vector: []float32{0.9, 0.1, 0}, Metadata: map[string]any{"source": "knowledge-base", "year": 2024}})
+		store.AddEmbedding(ctx, &vector.Embedding{ID: "web", Text: "web", Vector: []float32{1, 0, 0}, Metadata: map[string]any{"source": "web"}})
+		store.AddEmbedding(ctx, &vector.Embedding{ID: "bare", Text: "bare", Vector: []float32{1, 0, 0}})
+
+		results, err := store.SearchWithFilter(ctx, []float32{1, 0, 0}, 3, map[string]any{"source": "knowledge-base", "year": 2024.0})
+		if err != nil {
+			t.Fatalf("SearchWithFilter failed: %v", err)
+		}
+		if len(results) != 1 || results[0].ID != "kb" {
+			t.Errorf("Expected only kb to match, got %d results", len(results))
+		}
+		if results, _ := store.SearchWithFilter(ctx, []float32{1, 0, 0}, 3, nil); len(results) != 3 {
+			t.Errorf("Expected empty filter to match all, got %d", len(results))
+		}
+		store.AddEmbedding(ctx, &vector.Embedding{ID: "shared", Text: "shared", Vector: []float32{0, 1, 0}, Metadata: map[string]any{"source": []any{"wiki", "knowledge-base"}}})
+		if results, _ := store.SearchWithFilter(ctx, []float32{1, 0, 0}, 1, map[string]any{"source": vector.AnyOf{"knowledge-base"}}); len(results) != 1 || results[0].ID != "kb" {
+			t.Errorf("Expected kb to outrank shared for an any-of filter, got %d results", len(results))
+		}
+		if results, _ := store.SearchWithFilter(ctx, []float32{0, 1, 0}, 3, map[string]any{"source": vector.AnyOf{"wiki", "web"}}); len(results) != 2 {
+			t.Errorf("Expected any-of to match scalars and lists, got %d results", len(results))
+		}
+		if results, _ := store.SearchWithFilter(ctx, []float32{0, 1, 0}, 3, map[string]any{"source": "wiki"}); len(results) != 1 || results[0].ID != "shared" {
+			t.Errorf("Expected a scalar filter to match a list containing it, got %d results", len(results))
+		}
+		if results, _ := store.SearchWithFilter(ctx, []float32{1, 0, 0}, 3, map[string]any{"source": vector.AnyOf{}}); len(results) != 0 {
+			t.Errorf("Expected an empty any-of to match nothing, got %d", len(results))
+		}
+	})
+
 	t.Run("count embeddings", func(t *testing.T) {
 		store.Clear(ctx)
 
@@ -141,3 +188,64 @@ func TestEuclideanDistance(t *testing.T) {
 		t.Errorf("Expected distance ~5.0, got %f", dist)
 	}
 }
+
+func TestInMemoryVectorStoreStats(t *testing.T) {
+	store := NewInMemoryVectorStore()
+	ctx := context.Background()
+
+	for _, id := range []string{"doc1_chunk_1", "doc1_chunk_2", "doc2_chunk_1", "standalone"} {
+		if err := store.AddEmbedding(ctx, &vector.Embedding{ID: id, Text: "text", Vector: []float32{0.1, 0.2, 0.3}}); err != nil {
+			t.Fatalf("AddEmbedding failed: %v", err)
+		}
+	}
+
+	stats, err := store.Stats(ctx)
+	if err != nil {
+		t.Fatalf("Stats failed: %v", err)
+	}
+	if stats.Chunks != 4 || stats.Documents != 3 {
+		t.Errorf("Expected 4 chunks across 3 documents, got %d chunks and %d documents", stats.Chunks, stats.Documents)
+	}
+	if stats.Dimension != 3 || stats.IndexType != "flat" {
+		t.Errorf("Unexpected dimension %d or index type %q", stats.Dimension, stats.IndexType)
+	}
+	if stats.StorageBytes <= 0 {
+		t.Errorf("Expected positive storage estimate, got %d", stats.StorageBytes)
+	}
+}
+
+func TestInMemoryVectorStoreCollections(t *testing.T) {
+	ctx := context.Background()
+	store := NewInMemoryVectorStore()
+	billing := store.WithCollection("billing")
+	shipping := store.WithCollection("shipping")
+
+	if err := billing.AddEmbedding(ctx, &vector.Embedding{ID: "doc", Vector: []float32{1, 0}, Text: "invoice"}); err != nil {
+		t.Fatalf("add billing: %v", err)
+	}
+	if err := shipping.AddEmbedding(ctx, &vector.Embedding{ID: "doc", Vector: []float32{0, 1}, Text: "parcel"}); err != nil {
+		t.Fatalf("add shipping: %v", err)
+	}
+
+	if n, _ := store.Count(ctx); n != 0 {
+		t.Fatalf("expected default collection to be empty, got %d", n)
+	}
+	got, err := shipping.GetEmbedding(ctx, "doc")
+	if err != nil || got.Text != "parcel" {
+		t.Fatalf("expected same ID to be independent per collection, got %v, %v", got, err)
+	}
+	results, _ := billing.Search(ctx, []float32{0, 1}, 5)
+	if len(results) != 1 || results[0].Text != "invoice" {
+		t.Fatalf("expected search to stay within the collection, got %+v", results)
+	}
+
+	if err := billing.Clear(ctx); err != nil {
+		t.Fatalf("clear: %v", err)
+	}
+	if n, _ := shipping.Count(ctx); n != 1 {
+		t.Fatalf("expected clear to leave other collections intact, got %d", n)
+	}
+	if names := store.Collections(); len(names) != 1 || names[0] != "shipping" {
+		t.Fatalf("unexpected collections %v", names)
+	}
+}
@@ -3,10 +3,12 @@ package pg
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
 	"fmt"
+	"sort"
 	"strings"
 
-	_ "github.com/lib/pq"
+	"github.com/lib/pq"
 	errorskg "github.com/sweetpotato0/ai-allin/pkg/errors"
 	"github.com/sweetpotato0/ai-allin/vector"
 )
@@ -17,6 +19,7 @@ type PGVectorStore struct {
 	dimension   int
 	tableName   string
 	indexMethod string // HNSW or IVFFLAT
+	collection  string
 }
 
 // PGVectorConfig holds pgvector configuration
@@ -30,6 +33,8 @@ type PGVectorConfig struct {
 	Dimension int    // Embedding dimension (default: 1536 for OpenAI)
 	TableName string // Table name (default: vectors)
 	IndexType string // HNSW or IVFFLAT (default: HNSW)
+	// Collection scopes the store to a named collection (default: vector.DefaultCollection).
+	Collection string
 }
 
 // DefaultPGVectorConfig returns default pgvector configuration
@@ -71,6 +76,7 @@ func NewPGVectorStore(config *PGVectorConfig) (*PGVectorStore, error) {
 		dimension:   config.Dimension,
 		tableName:   config.TableName,
 		indexMethod: config.IndexType,
+		collection:  vector.CollectionName(config.Collection),
 	}
 
 	// Enable pgvector extension and create table
@@ -91,16 +97,39 @@ func (s *PGVectorStore) setup(ctx context.Context) error {
 	// Create table
 	createTableSQL := fmt.Sprintf(`
 	CREATE TABLE IF NOT EXISTS %s (
-		id VARCHAR(255) PRIMARY KEY,
+		collection VARCHAR(255) NOT NULL DEFAULT '%s',
+		id VARCHAR(255) NOT NULL,
 		text TEXT NOT NULL,
 		embedding vector(%d) NOT NULL,
-		created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
-	)`, s.tableName, s.dimension)
+		metadata JSONB NOT NULL DEFAULT '{}',
+		created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+		PRIMARY KEY (collection, id)
+	)`, s.tableName, vector.DefaultCollection, s.dimension)
 
 	if _, err := s.db.ExecContext(ctx, createTableSQL); err != nil {
 		return fmt.Errorf("failed to create table: %w", err)
 	}
 
+	// Tables created before collections existed are keyed by id alone: add the
+	// column and a unique index for upserts. IDs stay unique across collections there.
+	migrateSQL := fmt.Sprintf(`
+	ALTER TABLE %s ADD COLUMN IF NOT EXISTS collection VARCHAR(255) NOT NULL DEFAULT '%s'`,
+		s.tableName, vector.DefaultCollection)
+	if _, err := s.db.ExecContext(ctx, migrateSQL); err != nil {
+		return fmt.Errorf("failed to add collection column: %w", err)
+	}
+	metadataSQL := fmt.Sprintf(`
+	ALTER TABLE %s ADD COLUMN IF NOT EXISTS metadata JSONB NOT NULL DEFAULT '{}'`, s.tableName)
+	if _, err := s.db.ExecContext(ctx, metadataSQL); err != nil {
+		return fmt.Errorf("failed to add metadata column: %w", err)
+	}
+	uniqueSQL := fmt.Sprintf(`
+	CREATE UNIQUE INDEX IF NOT EXISTS %s_collection_id_idx ON %s (collection, id)`,
+		s.tableName, s.tableName)
+	if _, err := s.db.ExecContext(ctx, uniqueSQL); err != nil {
+		return fmt.Errorf("failed to create collection index: %w", err)
+	}
+
 	// Create index for similarity search (commented out as it depends on pgvector extension version)
 	// indexName := fmt.Sprintf("%s_embedding_idx", s.tableName)
 	// indexSQL := fmt.Sprintf(`
@@ -129,17 +158,22 @@ func (s *PGVectorStore) AddEmbedding(ctx context.Context, embedding *vector.Embe
 
 	// Convert vector to string format: [1, 2, 3]
 	vectorStr := s.vectorToString(embedding.Vector)
+	metadata, err := marshalMetadata(embedding.Metadata)
+	if err != nil {
+		return fmt.Errorf("failed to encode metadata: %w", err)
+	}
 
 	query := fmt.Sprintf(`
-	INSERT INTO %s (id, text, embedding)
-	VALUES ($1, $2, $3::vector)
-	ON CONFLICT (id) DO UPDATE SET
+	INSERT INTO %s (collection, id, text, embedding, metadata)
+	VALUES ($4, $1, $2, $3::vector, $5::jsonb)
+	ON CONFLICT (collection, id) DO UPDATE SET
 		text = EXCLUDED.text,
 		embedding = EXCLUDED.embedding,
+		metadata = EXCLUDED.metadata,
 		created_at = CURRENT_TIMESTAMP
 	`, s.tableName)
 
-	_, err := s.db.ExecContext(ctx, query, embedding.ID, embedding.Text, vectorStr)
+	_, err = s.db.ExecContext(ctx, query, embedding.ID, embedding.Text, vectorStr, s.collection, metadata)
 	if err != nil {
 		return fmt.Errorf("failed to add embedding: %w", err)
 	}
@@ -149,6 +183,13 @@ func (s *PGVectorStore) AddEmbedding(ctx context.Context, embedding *vector.Embe
 
 // Search finds embeddings similar to the query vector
 func (s *PGVectorStore) Search(ctx context.Context, queryVector []float32, topK int) ([]*vector.Embedding, error) {
+	return s.SearchWithFilter(ctx, queryVector, topK, nil)
+}
+
+// SearchWithFilter finds embeddings similar to the query vector among those whose
+// metadata matches filter. The filter is applied in SQL with JSONB containment,
+// so only matching rows are ranked and counted towards topK.
+func (s *PGVectorStore) SearchWithFilter(ctx context.Context, queryVector []float32, topK int, filter map[string]any) ([]*vector.Embedding, error) {
 	if len(queryVector) == 0 {
 		return nil, fmt.Errorf("query vector cannot be empty")
 	}
@@ -164,14 +205,26 @@ func (s *PGVectorStore) Search(ctx context.Context, queryVector []float32, topK
 	// Convert query vector to string format
 	vectorStr := s.vectorToString(queryVector)
 
+	args := []any{vectorStr, topK, s.collection}
+	where := "collection = $3"
+	if len(filter) > 0 {
+		clause, filterArgs, err := filterClause(filter, len(args))
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode metadata filter: %w", err)
+		}
+		args = append(args, filterArgs...)
+		where += " AND " + clause
+	}
+
 	query := fmt.Sprintf(`
-	SELECT id, text, embedding
+	SELECT id, text, embedding, metadata
 	FROM %s
+	WHERE %s
 	ORDER BY embedding <-> $1::vector
 	LIMIT $2
-	`, s.tableName)
+	`, s.tableName, where)
 
-	rows, err := s.db.QueryContext(ctx, query, vectorStr, topK)
+	rows, err := s.db.QueryContext(ctx, query, args...)
 	if err != nil {
 		return nil, fmt.Errorf("failed to search embeddings: %w", err)
 	}
@@ -181,8 +234,9 @@ func (s *PGVectorStore) Search(ctx context.Context, queryVector []float32, topK
 	for rows.Next() {
 		var id, text string
 		var vectorStr string
+		var metadataJSON []byte
 
-		err := rows.Scan(&id, &text, &vectorStr)
+		err := rows.Scan(&id, &text, &vectorStr, &metadataJSON)
 		if err != nil {
 			return nil, fmt.Errorf("failed to scan embedding: %w", err)
 		}
@@ -191,11 +245,16 @@ func (s *PGVectorStore) Search(ctx context.Context, queryVector []float32, topK
 		if err != nil {
 			return nil, fmt.Errorf("failed to parse vector for embedding %s: %w", id, err)
 		}
+		var metadata map[string]any
+		if err := json.Unmarshal(metadataJSON, &metadata); err != nil {
+			return nil, fmt.Errorf("failed to parse metadata for embedding %s: %w", id, err)
+		}
 
 		embeddings = append(embeddings, &vector.Embedding{
-			ID:     id,
-			Text:   text,
-			Vector: vec,
+			ID:       id,
+			Text:     text,
+			Vector:   vec,
+			Metadata: metadata,
 		})
 	}
 
@@ -208,8 +267,8 @@ func (s *PGVectorStore) Search(ctx context.Context, queryVector []float32, topK
 
 // DeleteEmbedding removes an embedding by ID
 func (s *PGVectorStore) DeleteEmbedding(ctx context.Context, id string) error {
-	query := fmt.Sprintf("DELETE FROM %s WHERE id = $1", s.tableName)
-	result, err := s.db.ExecContext(ctx, query, id)
+	query := fmt.Sprintf("DELETE FROM %s WHERE collection = $2 AND id = $1", s.tableName)
+	result, err := s.db.ExecContext(ctx, query, id, s.collection)
 	if err != nil {
 		return fmt.Errorf("failed to delete embedding: %w", err)
 	}
@@ -226,16 +285,35 @@ func (s *PGVectorStore) DeleteEmbedding(ctx context.Context, id string) error {
 	return nil
 }
 
+// DeleteEmbeddings removes the embeddings with the given IDs in one statement,
+// ignoring unknown ones.
+func (s *PGVectorStore) DeleteEmbeddings(ctx context.Context, ids ...string) (int, error) {
+	if len(ids) == 0 {
+		return 0, nil
+	}
+	query := fmt.Sprintf("DELETE FROM %s WHERE collection = $1 AND id = ANY($2)", s.tableName)
+	result, err := s.db.ExecContext(ctx, query, s.collection, pq.Array(ids))
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete embeddings: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	return int(rows), nil
+}
+
 // GetEmbedding retrieves a specific embedding by ID
 func (s *PGVectorStore) GetEmbedding(ctx context.Context, id string) (*vector.Embedding, error) {
 	query := fmt.Sprintf(`
 	SELECT id, text, embedding
 	FROM %s
-	WHERE id = $1
+	WHERE collection = $2 AND id = $1
 	`, s.tableName)
 
 	var embID, text, vectorStr string
-	err := s.db.QueryRowContext(ctx, query, id).Scan(&embID, &text, &vectorStr)
+	err := s.db.QueryRowContext(ctx, query, id, s.collection).Scan(&embID, &text, &vectorStr)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return nil, fmt.Errorf("embedding %s: %w", id, errorskg.ErrNotFound)
@@ -255,27 +333,56 @@ func (s *PGVectorStore) GetEmbedding(ctx context.Context, id string) (*vector.Em
 	}, nil
 }
 
-// Clear removes all embeddings
+// Clear removes all embeddings of the collection
 func (s *PGVectorStore) Clear(ctx context.Context) error {
-	query := fmt.Sprintf("TRUNCATE TABLE %s", s.tableName)
-	_, err := s.db.ExecContext(ctx, query)
+	query := fmt.Sprintf("DELETE FROM %s WHERE collection = $1", s.tableName)
+	_, err := s.db.ExecContext(ctx, query, s.collection)
 	if err != nil {
 		return fmt.Errorf("failed to clear embeddings: %w", err)
 	}
 	return nil
 }
 
-// Count returns the number of embeddings
+// Count returns the number of embeddings in the collection
 func (s *PGVectorStore) Count(ctx context.Context) (int, error) {
 	var count int
-	query := fmt.Sprintf("SELECT COUNT(*) FROM %s", s.tableName)
-	err := s.db.QueryRowContext(ctx, query).Scan(&count)
+	query := fmt.Sprintf("SELECT COUNT(*) FROM %s WHERE collection = $1", s.tableName)
+	err := s.db.QueryRowContext(ctx, query, s.collection).Scan(&count)
 	if err != nil {
 		return 0, fmt.Errorf("failed to count embeddings: %w", err)
 	}
 	return count, nil
 }
 
+// Stats reports row and document counts of the collection together with the table's
+// total on-disk size, including indexes and TOAST data, which is shared by all collections.
+func (s *PGVectorStore) Stats(ctx context.Context) (vector.VectorStoreStats, error) {
+	stats := vector.VectorStoreStats{
+		Dimension: s.dimension,
+		IndexType: s.indexMethod,
+	}
+	query := fmt.Sprintf(`
+	SELECT COUNT(*),
+		COUNT(DISTINCT regexp_replace(id, '^(.+)%s.*$', '\1')),
+		pg_total_relation_size($1::regclass)
+	FROM %s
+	WHERE collection = $2
+	`, vector.ChunkIDSeparator, s.tableName)
+	err := s.db.QueryRowContext(ctx, query, s.tableName, s.collection).Scan(&stats.Chunks, &stats.Documents, &stats.StorageBytes)
+	if err != nil {
+		return vector.VectorStoreStats{}, fmt.Errorf("failed to collect vector store stats: %w", err)
+	}
+	return stats, nil
+}
+
+// WithCollection returns a view of the store scoped to the named collection.
+// The view shares the database connection, so closing either closes both.
+func (s *PGVectorStore) WithCollection(name string) vector.VectorStore {
+	view := *s
+	view.collection = vector.CollectionName(name)
+	return &view
+}
+
 // Close closes the database connection
 func (s *PGVectorStore) Close() error {
 	return s.db.Close()
@@ -283,6 +390,74 @@ func (s *PGVectorStore) Close() error {
 
 // Helper functions
 
+// filterClause translates filter into a SQL condition on the metadata column
+// and its arguments, for use after WHERE, following the vector.MatchesFilter
+// rule. Placeholders are numbered after the first argN arguments of the
+// surrounding query and keys are handled in sorted order. Scalars and each item
+// of a vector.AnyOf become an OR of JSONB containments on the scalar and on a
+// list holding it; lists and objects are compared for equality. An empty AnyOf
+// matches nothing, and an empty filter yields an empty condition.
+func filterClause(filter map[string]any, argN int) (string, []any, error) {
+	var conditions []string
+	var args []any
+	placeholder := func(v any) (string, error) {
+		encoded, err := json.Marshal(v)
+		if err != nil {
+			return "", err
+		}
+		args = append(args, string(encoded))
+		return fmt.Sprintf("$%d::jsonb", argN+len(args)), nil
+	}
+	keys := make([]string, 0, len(filter))
+	for key := range filter {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		values, isAnyOf := filter[key].(vector.AnyOf)
+		if !isAnyOf {
+			if !vector.IsScalarFilterValue(filter[key]) {
+				args = append(args, key)
+				field := fmt.Sprintf("metadata -> $%d::text", argN+len(args))
+				value, err := placeholder(filter[key])
+				if err != nil {
+					return "", nil, err
+				}
+				conditions = append(conditions, field+" = "+value)
+				continue
+			}
+			values = vector.AnyOf{filter[key]}
+		}
+		if len(values) == 0 {
+			return "FALSE", nil, nil
+		}
+		alternatives := make([]string, 0, 2*len(values))
+		for _, value := range values {
+			for _, doc := range []map[string]any{{key: value}, {key: []any{value}}} {
+				cond, err := placeholder(doc)
+				if err != nil {
+					return "", nil, err
+				}
+				alternatives = append(alternatives, "metadata @> "+cond)
+			}
+		}
+		conditions = append(conditions, "("+strings.Join(alternatives, " OR ")+")")
+	}
+	return strings.Join(conditions, " AND "), args, nil
+}
+
+// marshalMetadata encodes metadata as a JSON object, using {} for nil maps.
+func marshalMetadata(metadata map[string]any) (string, error) {
+	if len(metadata) == 0 {
+		return "{}", nil
+	}
+	data, err := json.Marshal(metadata)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
 func (s *PGVectorStore) vectorToString(vec []float32) string {
 	parts := make([]string, len(vec))
 	for i, v := range vec {
@@ -0,0 +1,112 @@
+package pg
+
+import (
+	"fmt"
+	"math"
+	"testing"
+
+	"github.com/sweetpotato0/ai-allin/vector"
+)
+
+func TestFilterClause(t *testing.T) {
+	cases := []struct {
+		name     string
+		filter   map[string]any
+		argN     int
+		wantSQL  string
+		wantArgs []any
+	}{
+		{
+			name:    "scalars match values and lists",
+			filter:  map[string]any{"lang": "go", "year": 2024},
+			argN:    3,
+			wantSQL: "(metadata @> $4::jsonb OR metadata @> $5::jsonb) AND (metadata @> $6::jsonb OR metadata @> $7::jsonb)",
+			wantArgs: []any{
+				`{"lang":"go"}`, `{"lang":["go"]}`,
+				`{"year":2024}`, `{"year":[2024]}`,
+			},
+		},
+		{
+			name:    "any of matches each item like a scalar",
+			filter:  map[string]any{"tag": vector.AnyOf{"a", "b"}},
+			argN:    3,
+			wantSQL: "(metadata @> $4::jsonb OR metadata @> $5::jsonb OR metadata @> $6::jsonb OR metadata @> $7::jsonb)",
+			wantArgs: []any{
+				`{"tag":"a"}`, `{"tag":["a"]}`,
+				`{"tag":"b"}`, `{"tag":["b"]}`,
+			},
+		},
+		{
+			name:    "lists and objects must be equal",
+			filter:  map[string]any{"tags": []string{"a", "b"}, "owner": map[string]any{"team": "search"}},
+			argN:    1,
+			wantSQL: "metadata -> $2::text = $3::jsonb AND metadata -> $4::text = $5::jsonb",
+			wantArgs: []any{
+				"owner", `{"team":"search"}`,
+				"tags", `["a","b"]`,
+			},
+		},
+		{
+			name:    "keys are sorted and placeholders numbered in order",
+			filter:  map[string]any{"z": vector.AnyOf{1}, "lang": "go", "a": vector.AnyOf{true}},
+			argN:    1,
+			wantSQL: "(metadata @> $2::jsonb OR metadata @> $3::jsonb) AND (metadata @> $4::jsonb OR metadata @> $5::jsonb) AND (metadata @> $6::jsonb OR metadata @> $7::jsonb)",
+			wantArgs: []any{
+				`{"a":true}`, `{"a":[true]}`,
+				`{"lang":"go"}`, `{"lang":["go"]}`,
+				`{"z":1}`, `{"z":[1]}`,
+			},
+		},
+		{
+			name:    "empty any of matches nothing",
+			filter:  map[string]any{"lang": "go", "tag": vector.AnyOf{}},
+			argN:    3,
+			wantSQL: "FALSE",
+		},
+		{
+			name:    "empty filter",
+			filter:  map[string]any{},
+			argN:    3,
+			wantSQL: "",
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			sql, args, err := filterClause(tc.filter, tc.argN)
+			if err != nil {
+				t.Fatalf("filterClause: %v", err)
+			}
+			if sql != tc.wantSQL {
+				t.Fatalf("sql = %q, want %q", sql, tc.wantSQL)
+			}
+			if fmt.Sprint(args) != fmt.Sprint(tc.wantArgs) {
+				t.Fatalf("args = %q, want %q", args, tc.wantArgs)
+			}
+		})
+	}
+}
+
+func TestFilterClauseRejectsUnencodableValues(t *testing.T) {
+	if _, _, err := filterClause(map[string]any{"score": math.NaN()}, 0); err == nil {
+		t.Fatal("expected an error for an exact value that cannot be encoded")
+	}
+	if _, _, err := filterClause(map[string]any{"score": vector.AnyOf{math.Inf(1)}}, 0); err == nil {
+		t.Fatal("expected an error for an AnyOf value that cannot be encoded")
+	}
+}
+
+func TestMarshalMetadata(t *testing.T) {
+	for _, tc := range []struct {
+		metadata map[string]any
+		want     string
+	}{
+		{nil, "{}"},
+		{map[string]any{}, "{}"},
+		{map[string]any{"lang": "go", "tags": []string{"a"}}, `{"lang":"go","tags":["a"]}`},
+	} {
+		got, err := marshalMetadata(tc.metadata)
+		if err != nil || got != tc.want {
+			t.Fatalf("marshalMetadata(%v) = %q, %v; want %q", tc.metadata, got, err, tc.want)
+		}
+	}
+}
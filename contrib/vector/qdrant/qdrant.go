@@ -0,0 +1,588 @@
+package qdrant
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
+
+	"github.com/google/uuid"
+	errorskg "github.com/sweetpotato0/ai-allin/pkg/errors"
+	"github.com/sweetpotato0/ai-allin/vector"
+)
+
+// Distance metrics understood by Qdrant.
+const (
+	DistanceCosine    = "Cosine"
+	DistanceDot       = "Dot"
+	DistanceEuclid    = "Euclid"
+	DistanceManhattan = "Manhattan"
+)
+
+// Payload keys written for every point.
+const (
+	payloadCollection = "collection"
+	payloadID         = "id"
+	payloadText       = "text"
+	payloadMetadata   = "metadata"
+)
+
+// scrollPageSize is the number of points fetched per scroll request in Stats.
+const scrollPageSize = 256
+
+// Config holds Qdrant configuration.
+type Config struct {
+	Host   string
+	Port   int  // REST API port (default: 6333); the gRPC port (6334) is not used
+	UseTLS bool // Connect over https
+	APIKey string
+	// CollectionName is the Qdrant collection holding the points (default: ai_allin).
+	// It is created on first use when missing.
+	CollectionName string
+	Distance       string // Cosine, Dot, Euclid or Manhattan (default: Cosine)
+	Dimension      int    // Embedding dimension (default: 1536 for OpenAI)
+	// Collection scopes the store to a named vector.VectorStore collection
+	// (default: vector.DefaultCollection). Collections share CollectionName
+	// and are told apart by a payload field.
+	Collection string
+	// HTTPClient overrides the client used for requests (default: http.DefaultClient).
+	HTTPClient *http.Client
+}
+
+// DefaultConfig returns the default Qdrant configuration for a local server.
+func DefaultConfig() *Config {
+	return &Config{
+		Host:           "localhost",
+		Port:           6333,
+		CollectionName: "ai_allin",
+		Distance:       DistanceCosine,
+		Dimension:      1536,
+	}
+}
+
+// Store implements vector.VectorStore on a Qdrant collection through its REST API.
+// Embedding IDs are mapped to deterministic UUID point IDs; the original ID,
+// text and metadata are stored in the point payload.
+type Store struct {
+	client     *client
+	collection string
+}
+
+var (
+	_ vector.VectorStore      = (*Store)(nil)
+	_ vector.BulkDeleter      = (*Store)(nil)
+	_ vector.FilteredSearcher = (*Store)(nil)
+)
+
+// client is shared by a store and its collection views.
+type client struct {
+	http      *http.Client
+	baseURL   string
+	apiKey    string
+	name      string
+	distance  string
+	dimension int
+	mu        sync.Mutex
+	collReady bool
+}
+
+// New creates a Qdrant-backed vector store. The Qdrant collection is created
+// lazily on the first operation.
+func New(config *Config) (*Store, error) {
+	if config == nil {
+		config = DefaultConfig()
+	}
+	defaults := DefaultConfig()
+	if config.Dimension <= 0 {
+		return nil, fmt.Errorf("dimension must be positive, got %d", config.Dimension)
+	}
+	switch config.Distance {
+	case "":
+		config.Distance = defaults.Distance
+	case DistanceCosine, DistanceDot, DistanceEuclid, DistanceManhattan:
+	default:
+		return nil, fmt.Errorf("unsupported distance metric %q", config.Distance)
+	}
+
+	scheme := "http"
+	if config.UseTLS {
+		scheme = "https"
+	}
+	host := config.Host
+	if host == "" {
+		host = defaults.Host
+	}
+	port := config.Port
+	if port == 0 {
+		port = defaults.Port
+	}
+	name := config.CollectionName
+	if name == "" {
+		name = defaults.CollectionName
+	}
+	httpClient := config.HTTPClient
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+
+	return &Store{
+		client: &client{
+			http:      httpClient,
+			baseURL:   scheme + "://" + host + ":" + strconv.Itoa(port),
+			apiKey:    config.APIKey,
+			name:      name,
+			distance:  config.Distance,
+			dimension: config.Dimension,
+		},
+		collection: vector.CollectionName(config.Collection),
+	}, nil
+}
+
+// WithCollection returns a view of the store scoped to the named collection.
+// The view shares the Qdrant connection and collection.
+func (s *Store) WithCollection(name string) vector.VectorStore {
+	return &Store{client: s.client, collection: vector.CollectionName(name)}
+}
+
+// AddEmbedding upserts an embedding with its text and metadata as payload.
+func (s *Store) AddEmbedding(ctx context.Context, embedding *vector.Embedding) error {
+	if embedding == nil {
+		return fmt.Errorf("embedding cannot be nil")
+	}
+	if embedding.ID == "" {
+		return fmt.Errorf("embedding ID cannot be empty")
+	}
+	if len(embedding.Vector) != s.client.dimension {
+		return fmt.Errorf("embedding dimension mismatch: expected %d, got %d", s.client.dimension, len(embedding.Vector))
+	}
+	if err := s.client.ensureCollection(ctx); err != nil {
+		return err
+	}
+
+	metadata := embedding.Metadata
+	if metadata == nil {
+		metadata = map[string]any{}
+	}
+	body := map[string]any{
+		"points": []map[string]any{{
+			"id":     s.pointID(embedding.ID),
+			"vector": embedding.Vector,
+			"payload": map[string]any{
+				payloadCollection: s.collection,
+				payloadID:         embedding.ID,
+				payloadText:       embedding.Text,
+				payloadMetadata:   metadata,
+			},
+		}},
+	}
+	if err := s.client.do(ctx, http.MethodPut, s.client.pointsPath("?wait=true"), body, nil); err != nil {
+		return fmt.Errorf("failed to add embedding: %w", err)
+	}
+	return nil
+}
+
+// Search finds embeddings similar to the query vector.
+func (s *Store) Search(ctx context.Context, queryVector []float32, topK int) ([]*vector.Embedding, error) {
+	return s.SearchWithFilter(ctx, queryVector, topK, nil)
+}
+
+// SearchWithFilter finds embeddings similar to the query vector among those whose
+// metadata matches filter. The filter is translated into Qdrant match conditions
+// on the metadata payload, so only matching points count towards topK.
+func (s *Store) SearchWithFilter(ctx context.Context, queryVector []float32, topK int, filter map[string]any) ([]*vector.Embedding, error) {
+	if len(queryVector) == 0 {
+		return nil, fmt.Errorf("query vector cannot be empty")
+	}
+	if len(queryVector) != s.client.dimension {
+		return nil, fmt.Errorf("query vector dimension mismatch: expected %d, got %d", s.client.dimension, len(queryVector))
+	}
+	if topK <= 0 {
+		topK = 10
+	}
+	for _, value := range filter {
+		if anyOf, ok := value.(vector.AnyOf); ok && len(anyOf) == 0 {
+			return []*vector.Embedding{}, nil
+		}
+	}
+	conditions, err := metadataConditions(filter)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.client.ensureCollection(ctx); err != nil {
+		return nil, err
+	}
+
+	body := map[string]any{
+		"vector":       queryVector,
+		"limit":        topK,
+		"filter":       map[string]any{"must": append([]map[string]any{s.collectionCondition()}, conditions...)},
+		"with_payload": true,
+		"with_vector":  true,
+	}
+	var points []point
+	if err := s.client.do(ctx, http.MethodPost, s.client.pointsPath("/search"), body, &points); err != nil {
+		return nil, fmt.Errorf("failed to search embeddings: %w", err)
+	}
+
+	embeddings := make([]*vector.Embedding, 0, len(points))
+	for _, p := range points {
+		embeddings = append(embeddings, p.embedding())
+	}
+	return embeddings, nil
+}
+
+// DeleteEmbedding removes an embedding by ID.
+func (s *Store) DeleteEmbedding(ctx context.Context, id string) error {
+	n, err := s.DeleteEmbeddings(ctx, id)
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return fmt.Errorf("embedding %s: %w", id, errorskg.ErrNotFound)
+	}
+	return nil
+}
+
+// DeleteEmbeddings removes the embeddings with the given IDs, ignoring unknown
+// ones. Existing points are looked up first because Qdrant deletes do not
+// report how many points they removed.
+func (s *Store) DeleteEmbeddings(ctx context.Context, ids ...string) (int, error) {
+	if len(ids) == 0 {
+		return 0, nil
+	}
+	existing, err := s.retrieve(ctx, ids, false)
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete embeddings: %w", err)
+	}
+	if len(existing) == 0 {
+		return 0, nil
+	}
+	pointIDs := make([]string, 0, len(existing))
+	for _, p := range existing {
+		pointIDs = append(pointIDs, p.ID)
+	}
+	body := map[string]any{"points": pointIDs}
+	if err := s.client.do(ctx, http.MethodPost, s.client.pointsPath("/delete?wait=true"), body, nil); err != nil {
+		return 0, fmt.Errorf("failed to delete embeddings: %w", err)
+	}
+	return len(existing), nil
+}
+
+// GetEmbedding retrieves a specific embedding by ID.
+func (s *Store) GetEmbedding(ctx context.Context, id string) (*vector.Embedding, error) {
+	points, err := s.retrieve(ctx, []string{id}, true)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get embedding: %w", err)
+	}
+	if len(points) == 0 {
+		return nil, fmt.Errorf("embedding %s: %w", id, errorskg.ErrNotFound)
+	}
+	return points[0].embedding(), nil
+}
+
+// Clear removes all embeddings of the collection.
+func (s *Store) Clear(ctx context.Context) error {
+	if err := s.client.ensureCollection(ctx); err != nil {
+		return err
+	}
+	body := map[string]any{"filter": s.collectionFilter()}
+	if err := s.client.do(ctx, http.MethodPost, s.client.pointsPath("/delete?wait=true"), body, nil); err != nil {
+		return fmt.Errorf("failed to clear embeddings: %w", err)
+	}
+	return nil
+}
+
+// Count returns the number of embeddings in the collection.
+func (s *Store) Count(ctx context.Context) (int, error) {
+	if err := s.client.ensureCollection(ctx); err != nil {
+		return 0, err
+	}
+	body := map[string]any{"filter": s.collectionFilter(), "exact": true}
+	var result struct {
+		Count int `json:"count"`
+	}
+	if err := s.client.do(ctx, http.MethodPost, s.client.pointsPath("/count"), body, &result); err != nil {
+		return 0, fmt.Errorf("failed to count embeddings: %w", err)
+	}
+	return result.Count, nil
+}
+
+// Stats reports point and document counts of the collection. Documents are
+// derived by scrolling over the stored IDs; StorageBytes is not reported.
+func (s *Store) Stats(ctx context.Context) (vector.VectorStoreStats, error) {
+	if err := s.client.ensureCollection(ctx); err != nil {
+		return vector.VectorStoreStats{}, err
+	}
+	stats := vector.VectorStoreStats{Dimension: s.client.dimension, IndexType: "HNSW"}
+	documents := make(map[string]struct{})
+	var offset any
+	for {
+		body := map[string]any{
+			"filter":       s.collectionFilter(),
+			"limit":        scrollPageSize,
+			"with_payload": []string{payloadID},
+			"with_vector":  false,
+		}
+		if offset != nil {
+			body["offset"] = offset
+		}
+		var page struct {
+			Points         []point `json:"points"`
+			NextPageOffset any     `json:"next_page_offset"`
+		}
+		if err := s.client.do(ctx, http.MethodPost, s.client.pointsPath("/scroll"), body, &page); err != nil {
+			return vector.VectorStoreStats{}, fmt.Errorf("failed to collect vector store stats: %w", err)
+		}
+		for _, p := range page.Points {
+			stats.Chunks++
+			id, _ := p.Payload[payloadID].(string)
+			documents[vector.DocumentIDFromEmbeddingID(id)] = struct{}{}
+		}
+		if page.NextPageOffset == nil {
+			break
+		}
+		offset = page.NextPageOffset
+	}
+	stats.Documents = len(documents)
+	return stats, nil
+}
+
+// retrieve fetches the points stored for ids in this collection.
+func (s *Store) retrieve(ctx context.Context, ids []string, withVector bool) ([]point, error) {
+	if err := s.client.ensureCollection(ctx); err != nil {
+		return nil, err
+	}
+	pointIDs := make([]string, len(ids))
+	for i, id := range ids {
+		pointIDs[i] = s.pointID(id)
+	}
+	body := map[string]any{"ids": pointIDs, "with_payload": true, "with_vector": withVector}
+	var points []point
+	if err := s.client.do(ctx, http.MethodPost, s.client.pointsPath(""), body, &points); err != nil {
+		return nil, err
+	}
+	return points, nil
+}
+
+// pointID derives a name-based UUID from the collection and embedding ID, since
+// Qdrant only accepts UUIDs and integers as point IDs.
+func (s *Store) pointID(id string) string {
+	return uuid.NewSHA1(uuid.NameSpaceOID, []byte(s.collection+"\x00"+id)).String()
+}
+
+func (s *Store) collectionCondition() map[string]any {
+	return matchCondition(payloadCollection, s.collection)
+}
+
+func (s *Store) collectionFilter() map[string]any {
+	return map[string]any{"must": []map[string]any{s.collectionCondition()}}
+}
+
+// metadataConditions translates a vector.MatchesFilter style filter into Qdrant
+// conditions on the metadata payload. Strings, booleans and integral numbers
+// use exact matches; other numbers use a closed range on the value.
+func metadataConditions(filter map[string]any) ([]map[string]any, error) {
+	conditions := make([]map[string]any, 0, len(filter))
+	for key, value := range filter {
+		field := payloadMetadata + "." + key
+		switch v := value.(type) {
+		case string, bool:
+			conditions = append(conditions, matchCondition(field, v))
+		case vector.AnyOf:
+			values := make([]any, 0, len(v))
+			for _, item := range v {
+				keyword, err := anyOfValue(key, item)
+				if err != nil {
+					return nil, err
+				}
+				values = append(values, keyword)
+			}
+			conditions = append(conditions, map[string]any{"key": field, "match": map[string]any{"any": values}})
+		default:
+			f, ok := toFloat(v)
+			if !ok {
+				return nil, fmt.Errorf("unsupported metadata filter value for %q: %T", key, value)
+			}
+			if f == math.Trunc(f) && math.Abs(f) < 1<<53 {
+				conditions = append(conditions, matchCondition(field, int64(f)))
+			} else {
+				conditions = append(conditions, map[string]any{"key": field, "range": map[string]any{"gte": f, "lte": f}})
+			}
+		}
+	}
+	return conditions, nil
+}
+
+// anyOfValue converts a vector.AnyOf item to a value Qdrant's match-any
+// condition accepts: a string or an integer.
+func anyOfValue(key string, value any) (any, error) {
+	if str, ok := value.(string); ok {
+		return str, nil
+	}
+	if f, ok := toFloat(value); ok && f == math.Trunc(f) && math.Abs(f) < 1<<53 {
+		return int64(f), nil
+	}
+	return nil, fmt.Errorf("unsupported metadata filter value for %q: %T", key, value)
+}
+
+func matchCondition(key string, value any) map[string]any {
+	return map[string]any{"key": key, "match": map[string]any{"value": value}}
+}
+
+func toFloat(v any) (float64, bool) {
+	switch n := v.(type) {
+	case int:
+		return float64(n), true
+	case int8:
+		return float64(n), true
+	case int16:
+		return float64(n), true
+	case int32:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	case uint:
+		return float64(n), true
+	case uint8:
+		return float64(n), true
+	case uint16:
+		return float64(n), true
+	case uint32:
+		return float64(n), true
+	case uint64:
+		return float64(n), true
+	case float32:
+		return float64(n), true
+	case float64:
+		return n, true
+	case json.Number:
+		f, err := n.Float64()
+		return f, err == nil
+	}
+	return 0, false
+}
+
+// point is a Qdrant point as returned by search, retrieve and scroll.
+type point struct {
+	ID      string         `json:"id"`
+	Vector  []float32      `json:"vector"`
+	Payload map[string]any `json:"payload"`
+}
+
+func (p point) embedding() *vector.Embedding {
+	id, _ := p.Payload[payloadID].(string)
+	text, _ := p.Payload[payloadText].(string)
+	metadata, _ := p.Payload[payloadMetadata].(map[string]any)
+	return &vector.Embedding{ID: id, Vector: p.Vector, Text: text, Metadata: metadata}
+}
+
+// ensureCollection creates the Qdrant collection and its payload index on first
+// use. Failures are retried by the next operation.
+func (c *client) ensureCollection(ctx context.Context) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.collReady {
+		return nil
+	}
+
+	path := "/collections/" + url.PathEscape(c.name)
+	var exists struct {
+		Exists bool `json:"exists"`
+	}
+	if err := c.do(ctx, http.MethodGet, path+"/exists", nil, &exists); err != nil {
+		return fmt.Errorf("failed to check qdrant collection: %w", err)
+	}
+	if !exists.Exists {
+		body := map[string]any{"vectors": map[string]any{"size": c.dimension, "distance": c.distance}}
+		err := c.do(ctx, http.MethodPut, path, body, nil)
+		var apiErr *APIError
+		if err != nil && !(errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusConflict) {
+			return fmt.Errorf("failed to create qdrant collection: %w", err)
+		}
+	}
+	index := map[string]any{"field_name": payloadCollection, "field_schema": "keyword"}
+	if err := c.do(ctx, http.MethodPut, path+"/index?wait=true", index, nil); err != nil {
+		return fmt.Errorf("failed to create qdrant payload index: %w", err)
+	}
+	c.collReady = true
+	return nil
+}
+
+func (c *client) pointsPath(suffix string) string {
+	return "/collections/" + url.PathEscape(c.name) + "/points" + suffix
+}
+
+// APIError is a failed Qdrant REST call.
+type APIError struct {
+	StatusCode int
+	Message    string
+}
+
+// Error implements the error interface.
+func (e *APIError) Error() string {
+	return fmt.Sprintf("qdrant: %s (status %d)", e.Message, e.StatusCode)
+}
+
+// do sends body as JSON and decodes the "result" field of the response into out.
+func (c *client) do(ctx context.Context, method, path string, body, out any) error {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return fmt.Errorf("failed to encode request: %w", err)
+		}
+		reader = bytes.NewReader(data)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, reader)
+	if err != nil {
+		return err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if c.apiKey != "" {
+		req.Header.Set("api-key", c.apiKey)
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	var envelope struct {
+		Result json.RawMessage `json:"result"`
+		Status json.RawMessage `json:"status"`
+	}
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("failed to read response: %w", err)
+	}
+	if resp.StatusCode >= http.StatusBadRequest {
+		msg := http.StatusText(resp.StatusCode)
+		var status struct {
+			Error string `json:"error"`
+		}
+		if json.Unmarshal(data, &envelope) == nil && json.Unmarshal(envelope.Status, &status) == nil && status.Error != "" {
+			msg = status.Error
+		}
+		return &APIError{StatusCode: resp.StatusCode, Message: msg}
+	}
+	if out == nil {
+		return nil
+	}
+	if err := json.Unmarshal(data, &envelope); err != nil {
+		return fmt.Errorf("failed to decode response: %w", err)
+	}
+	if err := json.Unmarshal(envelope.Result, out); err != nil {
+		return fmt.Errorf("failed to decode result: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,189 @@
+package qdrant
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+
+	errorskg "github.com/sweetpotato0/ai-allin/pkg/errors"
+	"github.com/sweetpotato0/ai-allin/vector"
+)
+
+func TestPointIDIsStableUUIDPerCollection(t *testing.T) {
+	store, err := New(DefaultConfig())
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	other := store.WithCollection("other").(*Store)
+
+	id := store.pointID("doc_1_chunk_0")
+	if id != store.pointID("doc_1_chunk_0") {
+		t.Fatal("expected point IDs to be deterministic")
+	}
+	if id == other.pointID("doc_1_chunk_0") {
+		t.Fatal("expected point IDs to differ across collections")
+	}
+	if len(id) != 36 || id[14] != '5' {
+		t.Fatalf("expected a version 5 UUID, got %q", id)
+	}
+}
+
+func TestMetadataConditions(t *testing.T) {
+	conditions, err := metadataConditions(map[string]any{"year": float64(2024)})
+	if err != nil {
+		t.Fatalf("metadataConditions failed: %v", err)
+	}
+	want := []map[string]any{{"key": "metadata.year", "match": map[string]any{"value": int64(2024)}}}
+	if !reflect.DeepEqual(conditions, want) {
+		t.Fatalf("unexpected conditions %+v", conditions)
+	}
+
+	conditions, err = metadataConditions(map[string]any{"score": 0.5})
+	if err != nil || conditions[0]["range"] == nil {
+		t.Fatalf("expected a range condition for fractional numbers, got %+v (%v)", conditions, err)
+	}
+
+	if _, err := metadataConditions(map[string]any{"tags": []string{"a"}}); err == nil {
+		t.Fatal("expected an error for unsupported filter values")
+	}
+}
+
+func TestNewRejectsUnknownDistance(t *testing.T) {
+	config := DefaultConfig()
+	config.Distance = "Hamming"
+	if _, err := New(config); err == nil {
+		t.Fatal("expected an error for an unknown distance metric")
+	}
+}
+
+func TestAPIErrorsCarryServerMessage(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+		fmt.Fprint(w, `{"status":{"error":"Invalid api-key"},"time":0.0}`)
+	}))
+	defer srv.Close()
+	u, _ := url.Parse(srv.URL)
+	port, _ := strconv.Atoi(u.Port())
+
+	config := DefaultConfig()
+	config.Host, config.Port = u.Hostname(), port
+	store, err := New(config)
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	_, err = store.Count(context.Background())
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusForbidden || apiErr.Message != "Invalid api-key" {
+		t.Fatalf("expected API error with server message, got %v", err)
+	}
+}
+
+// TestQdrantStore tests the store against a running Qdrant server.
+// Set QDRANT_HOST (and optionally QDRANT_PORT) to run it.
+func TestQdrantStore(t *testing.T) {
+	host := os.Getenv("QDRANT_HOST")
+	if host == "" {
+		t.Skip("QDRANT_HOST not set, skipping Qdrant store tests")
+	}
+
+	config := DefaultConfig()
+	config.Host = host
+	if port, err := strconv.Atoi(os.Getenv("QDRANT_PORT")); err == nil {
+		config.Port = port
+	}
+	config.APIKey = os.Getenv("QDRANT_API_KEY")
+	config.CollectionName = fmt.Sprintf("ai_allin_test_%d", time.Now().UnixNano())
+	config.Dimension = 3
+
+	store, err := New(config)
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	ctx := context.Background()
+	t.Cleanup(func() {
+		_ = store.client.do(context.Background(), http.MethodDelete, "/collections/"+url.PathEscape(config.CollectionName), nil, nil)
+	})
+
+	embeddings := []*vector.Embedding{
+		{ID: "doc_1_chunk_0", Vector: []float32{1, 0, 0}, Text: "alpha", Metadata: map[string]any{"lang": "en", "year": 2024}},
+		{ID: "doc_1_chunk_1", Vector: []float32{0.9, 0.1, 0}, Text: "beta", Metadata: map[string]any{"lang": "de", "year": 2023}},
+		{ID: "doc_2_chunk_0", Vector: []float32{0, 1, 0}, Text: "gamma", Metadata: map[string]any{"lang": "en", "year": 2023}},
+	}
+	for _, emb := range embeddings {
+		if err := store.AddEmbedding(ctx, emb); err != nil {
+			t.Fatalf("AddEmbedding failed: %v", err)
+		}
+	}
+
+	t.Run("search and filter", func(t *testing.T) {
+		results, err := store.Search(ctx, []float32{1, 0, 0}, 2)
+		if err != nil {
+			t.Fatalf("Search failed: %v", err)
+		}
+		if len(results) != 2 || results[0].ID != "doc_1_chunk_0" || results[0].Text != "alpha" || results[0].Metadata["lang"] != "en" {
+			t.Fatalf("unexpected results %+v", results)
+		}
+
+		results, err = store.SearchWithFilter(ctx, []float32{1, 0, 0}, 5, map[string]any{"lang": "en", "year": 2023})
+		if err != nil {
+			t.Fatalf("SearchWithFilter failed: %v", err)
+		}
+		if len(results) != 1 || results[0].ID != "doc_2_chunk_0" {
+			t.Fatalf("unexpected filtered results %+v", results)
+		}
+
+		results, err = store.SearchWithFilter(ctx, []float32{1, 0, 0}, 1, map[string]any{"lang": vector.AnyOf{"de", "fr"}})
+		if err != nil || len(results) != 1 || results[0].ID != "doc_1_chunk_1" {
+			t.Fatalf("unexpected any-of results %+v (%v)", results, err)
+		}
+	})
+
+	t.Run("collections are isolated", func(t *testing.T) {
+		other := store.WithCollection("other")
+		if err := other.AddEmbedding(ctx, &vector.Embedding{ID: "doc_1_chunk_0", Vector: []float32{0, 0, 1}}); err != nil {
+			t.Fatalf("AddEmbedding failed: %v", err)
+		}
+		if count, err := store.Count(ctx); err != nil || count != 3 {
+			t.Fatalf("expected 3 embeddings in the default collection, got %d (%v)", count, err)
+		}
+		if err := other.Clear(ctx); err != nil {
+			t.Fatalf("Clear failed: %v", err)
+		}
+		if count, err := other.Count(ctx); err != nil || count != 0 {
+			t.Fatalf("expected cleared collection, got %d (%v)", count, err)
+		}
+	})
+
+	t.Run("get delete and stats", func(t *testing.T) {
+		emb, err := store.GetEmbedding(ctx, "doc_2_chunk_0")
+		if err != nil || emb.Text != "gamma" || len(emb.Vector) != 3 {
+			t.Fatalf("unexpected embedding %+v (%v)", emb, err)
+		}
+		stats, err := store.Stats(ctx)
+		if err != nil || stats.Chunks != 3 || stats.Documents != 2 {
+			t.Fatalf("unexpected stats %+v (%v)", stats, err)
+		}
+
+		n, err := store.DeleteEmbeddings(ctx, "doc_1_chunk_0", "doc_1_chunk_1", "missing")
+		if err != nil || n != 2 {
+			t.Fatalf("expected 2 deletions, got %d (%v)", n, err)
+		}
+		if err := store.DeleteEmbedding(ctx, "doc_1_chunk_0"); !errors.Is(err, errorskg.ErrNotFound) {
+			t.Fatalf("expected ErrNotFound, got %v", err)
+		}
+		if err := store.Clear(ctx); err != nil {
+			t.Fatalf("Clear failed: %v", err)
+		}
+		if count, err := store.Count(ctx); err != nil || count != 0 {
+			t.Fatalf("expected empty store, got %d (%v)", count, err)
+		}
+	})
+}
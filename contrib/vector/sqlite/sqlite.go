@@ -0,0 +1,375 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+
+	errorskg "github.com/sweetpotato0/ai-allin/pkg/errors"
+	"github.com/sweetpotato0/ai-allin/vector"
+	_ "modernc.org/sqlite"
+)
+
+// Store implements vector.VectorStore on a single SQLite file. Vectors are
+// stored as little-endian float32 blobs and searched by brute-force cosine
+// similarity in Go, which suits collections of up to a few thousand chunks.
+type Store struct {
+	db         *sql.DB
+	tableName  string
+	collection string
+}
+
+var (
+	_ vector.VectorStore      = (*Store)(nil)
+	_ vector.BulkDeleter      = (*Store)(nil)
+	_ vector.FilteredSearcher = (*Store)(nil)
+)
+
+// Config holds SQLite vector store configuration.
+type Config struct {
+	Path      string // Database file, created when missing (default: ai_allin_vectors.db); ":memory:" keeps it in memory
+	TableName string // Table name (default: vectors)
+	// Collection scopes the store to a named collection (default: vector.DefaultCollection).
+	Collection string
+}
+
+// DefaultConfig returns the default SQLite vector store configuration.
+func DefaultConfig() *Config {
+	return &Config{
+		Path:      "ai_allin_vectors.db",
+		TableName: "vectors",
+	}
+}
+
+// New opens (or creates) the SQLite database and its table.
+func New(config *Config) (*Store, error) {
+	if config == nil {
+		config = DefaultConfig()
+	}
+	defaults := DefaultConfig()
+	path := config.Path
+	if path == "" {
+		path = defaults.Path
+	}
+	tableName := config.TableName
+	if tableName == "" {
+		tableName = defaults.TableName
+	}
+
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open SQLite database: %w", err)
+	}
+	// A single connection serializes writers and keeps ":memory:" databases shared.
+	db.SetMaxOpenConns(1)
+
+	store := &Store{
+		db:         db,
+		tableName:  tableName,
+		collection: vector.CollectionName(config.Collection),
+	}
+	if err := store.setup(context.Background()); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to setup SQLite vector store: %w", err)
+	}
+	return store, nil
+}
+
+// setup creates the embeddings table.
+func (s *Store) setup(ctx context.Context) error {
+	createTableSQL := fmt.Sprintf(`
+	CREATE TABLE IF NOT EXISTS %s (
+		collection TEXT NOT NULL,
+		id TEXT NOT NULL,
+		text TEXT NOT NULL,
+		embedding BLOB NOT NULL,
+		metadata TEXT NOT NULL DEFAULT '{}',
+		created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+		PRIMARY KEY (collection, id)
+	)`, s.tableName)
+	if _, err := s.db.ExecContext(ctx, createTableSQL); err != nil {
+		return fmt.Errorf("failed to create table: %w", err)
+	}
+	return nil
+}
+
+// WithCollection returns a view of the store scoped to the named collection.
+// The view shares the database connection, so closing either closes both.
+func (s *Store) WithCollection(name string) vector.VectorStore {
+	view := *s
+	view.collection = vector.CollectionName(name)
+	return &view
+}
+
+// AddEmbedding adds or replaces an embedding
+func (s *Store) AddEmbedding(ctx context.Context, embedding *vector.Embedding) error {
+	if embedding == nil {
+		return fmt.Errorf("embedding cannot be nil")
+	}
+	if embedding.ID == "" {
+		return fmt.Errorf("embedding ID cannot be empty")
+	}
+	if len(embedding.Vector) == 0 {
+		return fmt.Errorf("embedding vector cannot be empty")
+	}
+
+	metadata, err := marshalMetadata(embedding.Metadata)
+	if err != nil {
+		return fmt.Errorf("failed to encode metadata: %w", err)
+	}
+	query := fmt.Sprintf(`
+	INSERT INTO %s (collection, id, text, embedding, metadata)
+	VALUES (?, ?, ?, ?, ?)
+	ON CONFLICT (collection, id) DO UPDATE SET
+		text = excluded.text,
+		embedding = excluded.embedding,
+		metadata = excluded.metadata,
+		created_at = CURRENT_TIMESTAMP
+	`, s.tableName)
+	_, err = s.db.ExecContext(ctx, query, s.collection, embedding.ID, embedding.Text, encodeVector(embedding.Vector), metadata)
+	if err != nil {
+		return fmt.Errorf("failed to add embedding: %w", err)
+	}
+	return nil
+}
+
+// Search finds embeddings similar to the query vector
+func (s *Store) Search(ctx context.Context, queryVector []float32, topK int) ([]*vector.Embedding, error) {
+	return s.SearchWithFilter(ctx, queryVector, topK, nil)
+}
+
+// SearchWithFilter scans the collection, ranks embeddings whose metadata matches
+// filter by cosine similarity and returns the topK best. Embeddings with a
+// different dimension than the query are skipped.
+func (s *Store) SearchWithFilter(ctx context.Context, queryVector []float32, topK int, filter map[string]any) ([]*vector.Embedding, error) {
+	if len(queryVector) == 0 {
+		return nil, fmt.Errorf("query vector cannot be empty")
+	}
+	if topK <= 0 {
+		topK = 10
+	}
+
+	query := fmt.Sprintf("SELECT id, text, embedding, metadata FROM %s WHERE collection = ?", s.tableName)
+	rows, err := s.db.QueryContext(ctx, query, s.collection)
+	if err != nil {
+		return nil, fmt.Errorf("failed to search embeddings: %w", err)
+	}
+	defer rows.Close()
+
+	// Metadata is decoded up front only when filtering; otherwise just for the top results.
+	type result struct {
+		embedding    *vector.Embedding
+		metadataJSON string
+		similarity   float32
+	}
+	var results []result
+	for rows.Next() {
+		var id, text, metadataJSON string
+		var blob []byte
+		if err := rows.Scan(&id, &text, &blob, &metadataJSON); err != nil {
+			return nil, fmt.Errorf("failed to scan embedding: %w", err)
+		}
+		if len(blob) != len(queryVector)*4 {
+			continue
+		}
+		emb := &vector.Embedding{ID: id, Text: text}
+		if len(filter) > 0 {
+			metadata, err := unmarshalMetadata(metadataJSON)
+			if err != nil {
+				return nil, fmt.Errorf("failed to parse metadata for embedding %s: %w", id, err)
+			}
+			if !vector.MatchesFilter(metadata, filter) {
+				continue
+			}
+			emb.Metadata, metadataJSON = metadata, ""
+		}
+		emb.Vector = decodeVector(blob)
+		results = append(results, result{
+			embedding:    emb,
+			metadataJSON: metadataJSON,
+			similarity:   vector.CosineSimilarity(queryVector, emb.Vector),
+		})
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating embeddings: %w", err)
+	}
+
+	sort.SliceStable(results, func(i, j int) bool {
+		return results[i].similarity > results[j].similarity
+	})
+	limit := min(topK, len(results))
+	embeddings := make([]*vector.Embedding, limit)
+	for i := range limit {
+		emb := results[i].embedding
+		if results[i].metadataJSON != "" {
+			metadata, err := unmarshalMetadata(results[i].metadataJSON)
+			if err != nil {
+				return nil, fmt.Errorf("failed to parse metadata for embedding %s: %w", emb.ID, err)
+			}
+			emb.Metadata = metadata
+		}
+		embeddings[i] = emb
+	}
+	return embeddings, nil
+}
+
+// DeleteEmbedding removes an embedding by ID
+func (s *Store) DeleteEmbedding(ctx context.Context, id string) error {
+	n, err := s.DeleteEmbeddings(ctx, id)
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return fmt.Errorf("embedding %s: %w", id, errorskg.ErrNotFound)
+	}
+	return nil
+}
+
+// DeleteEmbeddings removes the embeddings with the given IDs in one statement,
+// ignoring unknown ones.
+func (s *Store) DeleteEmbeddings(ctx context.Context, ids ...string) (int, error) {
+	if len(ids) == 0 {
+		return 0, nil
+	}
+	args := make([]any, 0, len(ids)+1)
+	args = append(args, s.collection)
+	for _, id := range ids {
+		args = append(args, id)
+	}
+	placeholders := strings.TrimSuffix(strings.Repeat("?,", len(ids)), ",")
+	query := fmt.Sprintf("DELETE FROM %s WHERE collection = ? AND id IN (%s)", s.tableName, placeholders)
+	result, err := s.db.ExecContext(ctx, query, args...)
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete embeddings: %w", err)
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	return int(rows), nil
+}
+
+// GetEmbedding retrieves a specific embedding by ID
+func (s *Store) GetEmbedding(ctx context.Context, id string) (*vector.Embedding, error) {
+	query := fmt.Sprintf("SELECT text, embedding, metadata FROM %s WHERE collection = ? AND id = ?", s.tableName)
+	var text, metadataJSON string
+	var blob []byte
+	err := s.db.QueryRowContext(ctx, query, s.collection, id).Scan(&text, &blob, &metadataJSON)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("embedding %s: %w", id, errorskg.ErrNotFound)
+		}
+		return nil, fmt.Errorf("failed to get embedding: %w", err)
+	}
+	metadata, err := unmarshalMetadata(metadataJSON)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse metadata: %w", err)
+	}
+	return &vector.Embedding{ID: id, Text: text, Vector: decodeVector(blob), Metadata: metadata}, nil
+}
+
+// Clear removes all embeddings of the collection
+func (s *Store) Clear(ctx context.Context) error {
+	query := fmt.Sprintf("DELETE FROM %s WHERE collection = ?", s.tableName)
+	if _, err := s.db.ExecContext(ctx, query, s.collection); err != nil {
+		return fmt.Errorf("failed to clear embeddings: %w", err)
+	}
+	return nil
+}
+
+// Count returns the number of embeddings in the collection
+func (s *Store) Count(ctx context.Context) (int, error) {
+	var count int
+	query := fmt.Sprintf("SELECT COUNT(*) FROM %s WHERE collection = ?", s.tableName)
+	if err := s.db.QueryRowContext(ctx, query, s.collection).Scan(&count); err != nil {
+		return 0, fmt.Errorf("failed to count embeddings: %w", err)
+	}
+	return count, nil
+}
+
+// Stats reports chunk and document counts of the collection together with the
+// size of the whole database file, which is shared by all collections.
+func (s *Store) Stats(ctx context.Context) (vector.VectorStoreStats, error) {
+	stats := vector.VectorStoreStats{IndexType: "flat"}
+	query := fmt.Sprintf("SELECT id, length(embedding) FROM %s WHERE collection = ?", s.tableName)
+	rows, err := s.db.QueryContext(ctx, query, s.collection)
+	if err != nil {
+		return vector.VectorStoreStats{}, fmt.Errorf("failed to collect vector store stats: %w", err)
+	}
+	defer rows.Close()
+
+	docs := make(map[string]struct{})
+	for rows.Next() {
+		var id string
+		var size int
+		if err := rows.Scan(&id, &size); err != nil {
+			return vector.VectorStoreStats{}, fmt.Errorf("failed to scan embedding: %w", err)
+		}
+		stats.Chunks++
+		docs[vector.DocumentIDFromEmbeddingID(id)] = struct{}{}
+		if stats.Dimension == 0 {
+			stats.Dimension = size / 4
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return vector.VectorStoreStats{}, fmt.Errorf("error iterating embeddings: %w", err)
+	}
+	stats.Documents = len(docs)
+
+	err = s.db.QueryRowContext(ctx, "SELECT page_count * page_size FROM pragma_page_count(), pragma_page_size()").Scan(&stats.StorageBytes)
+	if err != nil {
+		return vector.VectorStoreStats{}, fmt.Errorf("failed to collect database size: %w", err)
+	}
+	return stats, nil
+}
+
+// Close closes the database connection
+func (s *Store) Close() error {
+	return s.db.Close()
+}
+
+// encodeVector packs vec as little-endian float32 values.
+func encodeVector(vec []float32) []byte {
+	buf := make([]byte, len(vec)*4)
+	for i, v := range vec {
+		binary.LittleEndian.PutUint32(buf[i*4:], math.Float32bits(v))
+	}
+	return buf
+}
+
+func decodeVector(buf []byte) []float32 {
+	vec := make([]float32, len(buf)/4)
+	for i := range vec {
+		vec[i] = math.Float32frombits(binary.LittleEndian.Uint32(buf[i*4:]))
+	}
+	return vec
+}
+
+// marshalMetadata encodes metadata as a JSON object, using {} for nil maps.
+func marshalMetadata(metadata map[string]any) (string, error) {
+	if len(metadata) == 0 {
+		return "{}", nil
+	}
+	data, err := json.Marshal(metadata)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+// unmarshalMetadata decodes a metadata column, returning nil for empty objects.
+func unmarshalMetadata(data string) (map[string]any, error) {
+	if data == "" || data == "{}" {
+		return nil, nil
+	}
+	var metadata map[string]any
+	if err := json.Unmarshal([]byte(data), &metadata); err != nil {
+		return nil, err
+	}
+	return metadata, nil
+}
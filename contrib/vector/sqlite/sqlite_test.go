@@ -0,0 +1,160 @@
+package sqlite
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"path/filepath"
+	"testing"
+
+	"github.com/sweetpotato0/ai-allin/contrib/vector/inmemory"
+	errorskg "github.com/sweetpotato0/ai-allin/pkg/errors"
+	"github.com/sweetpotato0/ai-allin/vector"
+)
+
+func newTestStore(t testing.TB, path string) *Store {
+	t.Helper()
+	store, err := New(&Config{Path: path})
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	t.Cleanup(func() { store.Close() })
+	return store
+}
+
+func TestSQLiteVectorStore(t *testing.T) {
+	ctx := context.Background()
+	store := newTestStore(t, ":memory:")
+
+	embeddings := []*vector.Embedding{
+		{ID: "doc_1_chunk_0", Vector: []float32{1, 0, 0}, Text: "alpha", Metadata: map[string]any{"lang": "en", "year": 2024}},
+		{ID: "doc_1_chunk_1", Vector: []float32{0.8, 0.6, 0}, Text: "beta", Metadata: map[string]any{"lang": "de"}},
+		{ID: "doc_2_chunk_0", Vector: []float32{0, 1, 0}, Text: "gamma"},
+	}
+	for _, emb := range embeddings {
+		if err := store.AddEmbedding(ctx, emb); err != nil {
+			t.Fatalf("AddEmbedding failed: %v", err)
+		}
+	}
+
+	results, err := store.Search(ctx, []float32{1, 0, 0}, 2)
+	if err != nil {
+		t.Fatalf("Search failed: %v", err)
+	}
+	if len(results) != 2 || results[0].ID != "doc_1_chunk_0" || results[1].ID != "doc_1_chunk_1" || results[0].Metadata["lang"] != "en" {
+		t.Fatalf("unexpected results %+v", results)
+	}
+
+	results, err = store.SearchWithFilter(ctx, []float32{0, 1, 0}, 5, map[string]any{"year": 2024})
+	if err != nil || len(results) != 1 || results[0].ID != "doc_1_chunk_0" {
+		t.Fatalf("unexpected filtered results %+v (%v)", results, err)
+	}
+
+	if err := store.AddEmbedding(ctx, &vector.Embedding{ID: "doc_2_chunk_0", Vector: []float32{0, 0, 1}, Text: "gamma2"}); err != nil {
+		t.Fatalf("AddEmbedding upsert failed: %v", err)
+	}
+	emb, err := store.GetEmbedding(ctx, "doc_2_chunk_0")
+	if err != nil || emb.Text != "gamma2" || emb.Vector[2] != 1 {
+		t.Fatalf("expected upserted embedding, got %+v (%v)", emb, err)
+	}
+
+	stats, err := store.Stats(ctx)
+	if err != nil || stats.Chunks != 3 || stats.Documents != 2 || stats.Dimension != 3 || stats.StorageBytes == 0 {
+		t.Fatalf("unexpected stats %+v (%v)", stats, err)
+	}
+
+	n, err := store.DeleteEmbeddings(ctx, "doc_1_chunk_0", "doc_1_chunk_1", "missing")
+	if err != nil || n != 2 {
+		t.Fatalf("expected 2 deletions, got %d (%v)", n, err)
+	}
+	if err := store.DeleteEmbedding(ctx, "doc_1_chunk_0"); !errors.Is(err, errorskg.ErrNotFound) {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+	if _, err := store.GetEmbedding(ctx, "doc_1_chunk_0"); !errors.Is(err, errorskg.ErrNotFound) {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+	if count, err := store.Count(ctx); err != nil || count != 1 {
+		t.Fatalf("expected 1 embedding, got %d (%v)", count, err)
+	}
+}
+
+func TestSQLiteVectorStorePersistsAcrossReopen(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "vectors.db")
+
+	store, err := New(&Config{Path: path})
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	if err := store.AddEmbedding(ctx, &vector.Embedding{ID: "a", Vector: []float32{0.5, -1.25}, Text: "kept"}); err != nil {
+		t.Fatalf("AddEmbedding failed: %v", err)
+	}
+	store.Close()
+
+	reopened := newTestStore(t, path)
+	emb, err := reopened.GetEmbedding(ctx, "a")
+	if err != nil || emb.Text != "kept" || emb.Vector[0] != 0.5 || emb.Vector[1] != -1.25 {
+		t.Fatalf("expected persisted embedding, got %+v (%v)", emb, err)
+	}
+}
+
+func TestSQLiteVectorStoreCollections(t *testing.T) {
+	ctx := context.Background()
+	store := newTestStore(t, ":memory:")
+	other := store.WithCollection("other")
+
+	if err := store.AddEmbedding(ctx, &vector.Embedding{ID: "a", Vector: []float32{1, 0}}); err != nil {
+		t.Fatalf("AddEmbedding failed: %v", err)
+	}
+	if err := other.AddEmbedding(ctx, &vector.Embedding{ID: "a", Vector: []float32{0, 1}}); err != nil {
+		t.Fatalf("AddEmbedding failed: %v", err)
+	}
+	if err := other.Clear(ctx); err != nil {
+		t.Fatalf("Clear failed: %v", err)
+	}
+	if count, _ := other.Count(ctx); count != 0 {
+		t.Fatalf("expected cleared collection, got %d", count)
+	}
+	if count, _ := store.Count(ctx); count != 1 {
+		t.Fatalf("expected default collection untouched, got %d", count)
+	}
+}
+
+// benchmarkSearch loads n random vectors into store and measures top-10 search.
+func benchmarkSearch(b *testing.B, store vector.VectorStore, n int) {
+	ctx := context.Background()
+	rng := rand.New(rand.NewSource(1))
+	randomVector := func() []float32 {
+		vec := make([]float32, 384)
+		for i := range vec {
+			vec[i] = rng.Float32()
+		}
+		return vec
+	}
+	for i := range n {
+		emb := &vector.Embedding{ID: fmt.Sprintf("doc_%d_chunk_0", i), Vector: randomVector(), Text: "chunk"}
+		if err := store.AddEmbedding(ctx, emb); err != nil {
+			b.Fatalf("AddEmbedding failed: %v", err)
+		}
+	}
+	query := randomVector()
+
+	b.ResetTimer()
+	for range b.N {
+		if _, err := store.Search(ctx, query, 10); err != nil {
+			b.Fatalf("Search failed: %v", err)
+		}
+	}
+}
+
+func BenchmarkSearch(b *testing.B) {
+	for _, n := range []int{1000, 5000} {
+		b.Run(fmt.Sprintf("sqlite/%d", n), func(b *testing.B) {
+			benchmarkSearch(b, newTestStore(b, filepath.Join(b.TempDir(), "bench.db")), n)
+		})
+		b.Run(fmt.Sprintf("inmemory/%d", n), func(b *testing.B) {
+			benchmarkSearch(b, inmemory.NewInMemoryVectorStore(), n)
+		})
+	}
+}
@@ -4,9 +4,9 @@ import (
 	"context"
 	"fmt"
 	"log"
-	"os"
 
 	"github.com/sweetpotato0/ai-allin/agent"
+	"github.com/sweetpotato0/ai-allin/config"
 	"github.com/sweetpotato0/ai-allin/contrib/provider/openai"
 )
 
@@ -14,16 +14,11 @@ func main() {
 	fmt.Println("=== Basic Agent Example ===")
 
 	ctx := context.Background()
-	apiKey := os.Getenv("OPENAI_API_KEY")
-	if apiKey == "" {
-		log.Fatal("OPENAI_API_KEY is required to run the Agentic RAG example")
-	}
-
-	baseURL := os.Getenv("OPENAI_API_BASE_URL")
-	if apiKey == "" {
-		log.Fatal("OPENAI_API_KEY is required to run the Agentic RAG example")
+	cfg, err := config.LoadOpenAIFromEnv()
+	if err != nil {
+		log.Fatal(err)
 	}
-	llm := openai.New(openai.DefaultConfig().WithAPIKey(apiKey).WithBaseURL(baseURL))
+	llm := openai.New(cfg)
 
 	// Create agent with options pattern
 	ag := agent.New(
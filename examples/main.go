@@ -80,6 +80,13 @@ func basicAgentExample() {
 	fmt.Printf("Agent response: %s\n", result.Text())
 }
 
+// calculatorArgs are the arguments of the calculator tool.
+type calculatorArgs struct {
+	Operation string  `json:"operation" description:"Operation to perform" enum:"add,subtract,multiply,divide" required:"true"`
+	A         float64 `json:"a" description:"First number" required:"true"`
+	B         float64 `json:"b" description:"Second number" required:"true"`
+}
+
 func agentWithToolsExample() {
 	llm := &MockLLMClient{}
 
@@ -89,39 +96,31 @@ func agentWithToolsExample() {
 		agent.WithTools(true),
 	)
 
-	// Register a calculator tool
-	calculatorTool := &tool.Tool{
-		Name:        "calculator",
-		Description: "Performs basic arithmetic operations",
-		Parameters: []tool.Parameter{
-			{Name: "operation", Type: "string", Description: "Operation to perform (add, subtract, multiply, divide)", Required: true},
-			{Name: "a", Type: "number", Description: "First number", Required: true},
-			{Name: "b", Type: "number", Description: "Second number", Required: true},
-		},
-		Handler: func(ctx context.Context, args map[string]any) (string, error) {
-			op := args["operation"].(string)
-			a := args["a"].(float64)
-			b := args["b"].(float64)
-
+	// Register a calculator tool; its parameters are derived from calculatorArgs
+	calculatorTool, err := tool.FromFunc("calculator", "Performs basic arithmetic operations",
+		func(ctx context.Context, args calculatorArgs) (string, error) {
 			var result float64
-			switch op {
+			switch args.Operation {
 			case "add":
-				result = a + b
+				result = args.A + args.B
 			case "subtract":
-				result = a - b
+				result = args.A - args.B
 			case "multiply":
-				result = a * b
+				result = args.A * args.B
 			case "divide":
-				if b == 0 {
+				if args.B == 0 {
 					return "", fmt.Errorf("division by zero")
 				}
-				result = a / b
+				result = args.A / args.B
 			default:
-				return "", fmt.Errorf("unknown operation: %s", op)
+				return "", fmt.Errorf("unknown operation: %s", args.Operation)
 			}
 
 			return fmt.Sprintf("%.2f", result), nil
-		},
+		})
+	if err != nil {
+		log.Printf("Error creating tool: %v", err)
+		return
 	}
 
 	if err := ag.RegisterTool(calculatorTool); err != nil {
@@ -6,6 +6,8 @@ import (
 	"fmt"
 	"net/http"
 	"time"
+
+	"github.com/sweetpotato0/ai-allin/pkg/id"
 )
 
 // ================================
@@ -141,7 +143,7 @@ func (s *APIServer) handleInquiry(w http.ResponseWriter, r *http.Request) {
 	duration := time.Since(startTime).Milliseconds()
 
 	// 获取工单（这里简化了）
-	ticketID := fmt.Sprintf("TKT_%d", time.Now().Unix())
+	ticketID := id.NewWithPrefix("TKT_")
 
 	resp := CustomerInquiryResponse{
 		SessionID: req.SessionID,
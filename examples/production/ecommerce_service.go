@@ -14,6 +14,7 @@ import (
 	"github.com/sweetpotato0/ai-allin/middleware/errorhandler"
 	"github.com/sweetpotato0/ai-allin/middleware/limiter"
 	"github.com/sweetpotato0/ai-allin/middleware/logger"
+	"github.com/sweetpotato0/ai-allin/pkg/id"
 	"github.com/sweetpotato0/ai-allin/prompt"
 	"github.com/sweetpotato0/ai-allin/runner"
 	"github.com/sweetpotato0/ai-allin/session"
@@ -473,7 +474,7 @@ func (p *ECommerceServicePlatform) HandleCustomerInquiry(
 	log.Printf("✓ 客户验证成功: %s (VIP等级: %s)\n", customer.Name, customer.VIPLevel)
 
 	// 2. 创建Session（代表这个客户的本次服务会话）
-	sessionID := fmt.Sprintf("cs_%s_%d", customerID, time.Now().UnixNano())
+	sessionID := fmt.Sprintf("cs_%s_%s", customerID, id.New())
 	csAgent := p.agentFactory.CreateCustomerServiceAgent("cs_agent")
 
 	// 3. 配置Agent中间件
@@ -504,7 +505,7 @@ func (p *ECommerceServicePlatform) HandleCustomerInquiry(
 		subject = inquiry[:50]
 	}
 	ticket := &Ticket{
-		TicketID:    fmt.Sprintf("TKT_%d", time.Now().Unix()),
+		TicketID:    id.NewWithPrefix("TKT_"),
 		CustomerID:  customerID,
 		Subject:     subject,
 		Priority:    p.determinePriority(customer),
@@ -535,7 +536,7 @@ func (p *ECommerceServicePlatform) MultiTurnConversationScenario(customerID stri
 	log.Printf("客户ID: %s\n", customerID)
 
 	// 创建一个长期Session（一个用户会话）
-	sessionID := fmt.Sprintf("conv_%s_%d", customerID, time.Now().UnixNano())
+	sessionID := fmt.Sprintf("conv_%s_%s", customerID, id.New())
 	csAgent := p.agentFactory.CreateCustomerServiceAgent("cs_agent")
 	p.configureAgentMiddleware(csAgent)
 
@@ -602,7 +603,7 @@ func (p *ECommerceServicePlatform) MultiAgentOrchestration(customerID string) er
 	log.Printf("【阶段1】客服Agent处理客户问题\n")
 	log.Printf("──────────────────────────\n")
 
-	csSessionID := fmt.Sprintf("cs_%s_%d", customerID, time.Now().UnixNano())
+	csSessionID := fmt.Sprintf("cs_%s_%s", customerID, id.New())
 	csAgent := p.agentFactory.CreateCustomerServiceAgent("cs_agent")
 	p.configureAgentMiddleware(csAgent)
 
@@ -620,7 +621,7 @@ func (p *ECommerceServicePlatform) MultiAgentOrchestration(customerID string) er
 	log.Printf("【阶段2】运营Agent分析客户价值\n")
 	log.Printf("──────────────────────────\n")
 
-	opSessionID := fmt.Sprintf("op_%s_%d", customerID, time.Now().UnixNano())
+	opSessionID := fmt.Sprintf("op_%s_%s", customerID, id.New())
 	opAgent := p.agentFactory.CreateOperationAgent("op_agent")
 	p.configureAgentMiddleware(opAgent)
 
@@ -639,7 +640,7 @@ func (p *ECommerceServicePlatform) MultiAgentOrchestration(customerID string) er
 	log.Printf("【阶段3】QA Agent审查服务质量\n")
 	log.Printf("──────────────────────────\n")
 
-	qaSessionID := fmt.Sprintf("qa_%s_%d", customerID, time.Now().UnixNano())
+	qaSessionID := fmt.Sprintf("qa_%s_%s", customerID, id.New())
 	qaAgent := p.agentFactory.CreateQAAgent("qa_agent")
 	p.configureAgentMiddleware(qaAgent)
 
@@ -659,7 +660,7 @@ func (p *ECommerceServicePlatform) MultiAgentOrchestration(customerID string) er
 	log.Printf("【阶段4】知识管理Agent更新知识库\n")
 	log.Printf("──────────────────────────\n")
 
-	kbSessionID := fmt.Sprintf("kb_%s_%d", customerID, time.Now().UnixNano())
+	kbSessionID := fmt.Sprintf("kb_%s_%s", customerID, id.New())
 	kbAgent := p.agentFactory.CreateKnowledgeAgent("kb_agent")
 	p.configureAgentMiddleware(kbAgent)
 
@@ -905,3 +906,15 @@ func (m *MockMemoryStore) AddMemory(ctx context.Context, mem *memory.Memory) err
 func (m *MockMemoryStore) SearchMemory(ctx context.Context, query string) ([]*memory.Memory, error) {
 	return []*memory.Memory{}, nil
 }
+
+func (m *MockMemoryStore) DeleteMemory(ctx context.Context, id string) error {
+	return nil
+}
+
+func (m *MockMemoryStore) UpdateMemory(ctx context.Context, mem *memory.Memory) error {
+	return nil
+}
+
+func (m *MockMemoryStore) ListMemories(ctx context.Context, limit, offset int) ([]*memory.Memory, error) {
+	return []*memory.Memory{}, nil
+}
@@ -79,24 +79,22 @@ func main() {
 
 	// Method 3: Get existing shared session and continue
 	fmt.Println("\n=== Method 3: Getting Existing Shared Session ===")
-	existingSess, err := mgr.Get(ctx, sessionID)
+	sharedSess3, err := mgr.GetShared(ctx, sessionID)
 	if err != nil {
 		log.Fatalf("Failed to get session: %v", err)
 	}
 
-	if sharedSess3, ok := existingSess.(*session.SharedSession); ok {
-		runWithAgent3 := func(name string, ag *agent.Agent, input string) {
-			resp, err := sharedSess3.RunWithAgent(ctx, ag, input)
-			if err != nil {
-				log.Fatalf("run failed: %v", err)
-			}
-			fmt.Printf("[%s] input: %s\n", name, input)
-			fmt.Printf("[%s] response: %s\n\n", name, resp)
+	runWithAgent3 := func(name string, ag *agent.Agent, input string) {
+		resp, err := sharedSess3.RunWithAgent(ctx, ag, input)
+		if err != nil {
+			log.Fatalf("run failed: %v", err)
 		}
-
-		runWithAgent3("researcher", researcher, "Direct agent passing example.")
-		runWithAgent3("solver", solver, "Another direct agent passing example.")
+		fmt.Printf("[%s] input: %s\n", name, input)
+		fmt.Printf("[%s] response: %s\n\n", name, resp)
 	}
+
+	runWithAgent3("researcher", researcher, "Direct agent passing example.")
+	runWithAgent3("solver", solver, "Another direct agent passing example.")
 }
 
 type echoProvider struct {
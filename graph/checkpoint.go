@@ -0,0 +1,226 @@
+package graph
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// CheckpointKey is the state key under which Execute stores its scheduler
+// position in the states passed to a Checkpointer. It is removed again before
+// a resumed execution continues, so nodes never see it.
+const CheckpointKey = "__graph_checkpoint__"
+
+// Checkpointer persists execution progress so an interrupted run can resume.
+//
+// Save is called after each node completes with the node name and a copy of
+// the state that also holds the scheduler position under CheckpointKey. Load
+// returns the most recent checkpoint, or an empty name and a nil state when
+// there is none. State values must survive a JSON round trip: checkpointers
+// are expected to serialise states, so values such as channels or functions
+// cannot be checkpointed, and structs come back as map[string]any.
+type Checkpointer interface {
+	Save(nodeName string, state State) error
+	Load() (nodeName string, state State, err error)
+}
+
+// checkpoint is the scheduler position saved under CheckpointKey.
+type checkpoint struct {
+	Queue            []string       `json:"queue"`
+	CompletedParents map[string]int `json:"completed_parents,omitempty"`
+	ParentHits       map[string]int `json:"parent_hits,omitempty"`
+	Visited          map[string]int `json:"visited,omitempty"`
+	Steps            int            `json:"steps"`
+	Done             bool           `json:"done,omitempty"` // The run finished; the next Execute starts over
+}
+
+// SetCheckpointer makes Execute save progress after each node and resume from
+// the last checkpoint of an unfinished run. A resumed run continues with the
+// checkpointed state and ignores the initial state passed to Execute. In
+// parallel mode progress is saved once per step, under the step's last node.
+func (g *Graph) SetCheckpointer(checkpointer Checkpointer) {
+	g.checkpointer = checkpointer
+}
+
+// resume restores the execution and state of an unfinished checkpointed run.
+// It reports false when there is nothing to resume.
+func (e *execution) resume() (State, bool, error) {
+	if e.g.checkpointer == nil {
+		return nil, false, nil
+	}
+	name, saved, err := e.g.checkpointer.Load()
+	if err != nil {
+		return nil, false, fmt.Errorf("load checkpoint: %w", err)
+	}
+	if saved == nil {
+		return nil, false, nil
+	}
+	raw, ok := saved[CheckpointKey]
+	if !ok {
+		return nil, false, fmt.Errorf("checkpoint for node %s has no scheduler state", name)
+	}
+	// The position may come back as a map after deserialisation; a JSON round
+	// trip decodes either form.
+	data, err := json.Marshal(raw)
+	if err != nil {
+		return nil, false, fmt.Errorf("decode checkpoint for node %s: %w", name, err)
+	}
+	var cp checkpoint
+	if err := json.Unmarshal(data, &cp); err != nil {
+		return nil, false, fmt.Errorf("decode checkpoint for node %s: %w", name, err)
+	}
+	if cp.Done {
+		return nil, false, nil
+	}
+
+	e.queue = cp.Queue
+	e.awaiting = make(map[string]bool, len(cp.Queue))
+	for _, queued := range cp.Queue {
+		if _, exists := e.g.nodes[queued]; !exists {
+			return nil, false, fmt.Errorf("checkpoint for node %s references unknown node %s", name, queued)
+		}
+		e.awaiting[queued] = true
+	}
+	e.completedParents = orEmpty(cp.CompletedParents)
+	e.parentHits = orEmpty(cp.ParentHits)
+	e.visited = orEmpty(cp.Visited)
+	e.steps = cp.Steps
+
+	state := maps.Clone(saved)
+	delete(state, CheckpointKey)
+	return state, true, nil
+}
+
+// save records the progress made up to and including node.
+func (e *execution) save(node string, state State, done bool) error {
+	if e.g.checkpointer == nil {
+		return nil
+	}
+	snapshot := maps.Clone(state)
+	if snapshot == nil {
+		snapshot = make(State)
+	}
+	snapshot[CheckpointKey] = checkpoint{
+		Queue:            append([]string{}, e.queue...),
+		CompletedParents: maps.Clone(e.completedParents),
+		ParentHits:       maps.Clone(e.parentHits),
+		Visited:          maps.Clone(e.visited),
+		Steps:            e.steps,
+		Done:             done,
+	}
+	if err := e.g.checkpointer.Save(node, snapshot); err != nil {
+		return fmt.Errorf("save checkpoint after node %s: %w", node, err)
+	}
+	return nil
+}
+
+func orEmpty(m map[string]int) map[string]int {
+	if m == nil {
+		return make(map[string]int)
+	}
+	return m
+}
+
+// checkpointRecord is the serialised form used by the bundled checkpointers.
+type checkpointRecord struct {
+	Node  string `json:"node"`
+	State State  `json:"state"`
+}
+
+func encodeCheckpoint(nodeName string, state State) ([]byte, error) {
+	data, err := json.Marshal(checkpointRecord{Node: nodeName, State: state})
+	if err != nil {
+		return nil, fmt.Errorf("state is not JSON-serializable: %w", err)
+	}
+	return data, nil
+}
+
+func decodeCheckpoint(data []byte) (string, State, error) {
+	var record checkpointRecord
+	if err := json.Unmarshal(data, &record); err != nil {
+		return "", nil, err
+	}
+	return record.Node, record.State, nil
+}
+
+// MemoryCheckpointer keeps the latest checkpoint in memory, serialised as JSON
+// so it behaves like a persistent checkpointer. It is mostly useful in tests.
+type MemoryCheckpointer struct {
+	mu   sync.Mutex
+	data []byte
+}
+
+// NewMemoryCheckpointer creates an empty in-memory checkpointer.
+func NewMemoryCheckpointer() *MemoryCheckpointer {
+	return &MemoryCheckpointer{}
+}
+
+// Save implements Checkpointer.
+func (c *MemoryCheckpointer) Save(nodeName string, state State) error {
+	data, err := encodeCheckpoint(nodeName, state)
+	if err != nil {
+		return err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.data = data
+	return nil
+}
+
+// Load implements Checkpointer.
+func (c *MemoryCheckpointer) Load() (string, State, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.data == nil {
+		return "", nil, nil
+	}
+	return decodeCheckpoint(c.data)
+}
+
+// FileCheckpointer stores the latest checkpoint as a JSON file. Each save
+// replaces the file atomically, so a crash never leaves a partial checkpoint.
+type FileCheckpointer struct {
+	path string
+}
+
+// NewFileCheckpointer creates a checkpointer writing to path.
+func NewFileCheckpointer(path string) *FileCheckpointer {
+	return &FileCheckpointer{path: path}
+}
+
+// Save implements Checkpointer.
+func (c *FileCheckpointer) Save(nodeName string, state State) error {
+	data, err := encodeCheckpoint(nodeName, state)
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(c.path), filepath.Base(c.path)+".tmp*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), c.path)
+}
+
+// Load implements Checkpointer. A missing file means there is no checkpoint.
+func (c *FileCheckpointer) Load() (string, State, error) {
+	data, err := os.ReadFile(c.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return "", nil, nil
+	}
+	if err != nil {
+		return "", nil, err
+	}
+	return decodeCheckpoint(data)
+}
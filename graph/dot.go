@@ -0,0 +1,80 @@
+package graph
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// nodeShapes maps node types to Graphviz shapes.
+var nodeShapes = map[NodeType]string{
+	NodeTypeStart:     "oval",
+	NodeTypeEnd:       "oval",
+	NodeTypeCondition: "diamond",
+	NodeTypeLLM:       "box",
+	NodeTypeTool:      "component",
+	NodeTypeCustom:    "box",
+}
+
+// ToDOT renders the graph in Graphviz DOT format, e.g. for `dot -Tsvg`.
+// Nodes are labelled with their name and type; the start node is drawn bold and
+// the end node with a double border, and nodes waiting for all parents are
+// annotated. Static edges are solid, while condition branches are dashed and
+// labelled with the condition result that selects them. The output is
+// deterministic and rendering it does not affect execution.
+func (g *Graph) ToDOT() string {
+	var b strings.Builder
+	b.WriteString("digraph G {\n")
+	b.WriteString("  rankdir=TB;\n")
+	b.WriteString("  node [fontname=\"Helvetica\"];\n")
+
+	names := g.sortedNodeNames()
+	for _, name := range names {
+		node := g.nodes[name]
+		label := fmt.Sprintf("%s\n(%s)", name, node.Type)
+		if node.WaitAllParents {
+			label += "\nwaits for all parents"
+		}
+		shape, ok := nodeShapes[node.Type]
+		if !ok {
+			shape = "box"
+		}
+		attrs := []string{"label=" + dotQuote(label), "shape=" + shape}
+		if node.Type == NodeTypeLLM {
+			attrs = append(attrs, "style=rounded")
+		}
+		if name == g.startNode {
+			attrs = append(attrs, "penwidth=2")
+		}
+		if name == g.endNode {
+			attrs = append(attrs, "peripheries=2")
+		}
+		fmt.Fprintf(&b, "  %s [%s];\n", dotQuote(name), strings.Join(attrs, ", "))
+	}
+
+	for _, name := range names {
+		node := g.nodes[name]
+		for _, child := range node.NextNodes {
+			fmt.Fprintf(&b, "  %s -> %s;\n", dotQuote(name), dotQuote(child))
+		}
+		results := make([]string, 0, len(node.NextMap))
+		for result := range node.NextMap {
+			results = append(results, result)
+		}
+		sort.Strings(results)
+		for _, result := range results {
+			fmt.Fprintf(&b, "  %s -> %s [style=dashed, label=%s];\n", dotQuote(name), dotQuote(node.NextMap[result]), dotQuote(result))
+		}
+	}
+
+	b.WriteString("}\n")
+	return b.String()
+}
+
+// dotQuote returns s as a quoted DOT identifier.
+func dotQuote(s string) string {
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	s = strings.ReplaceAll(s, `"`, `\"`)
+	s = strings.ReplaceAll(s, "\n", `\n`)
+	return `"` + s + `"`
+}
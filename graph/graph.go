@@ -2,7 +2,12 @@ package graph
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"maps"
+	"reflect"
+	"sort"
+	"time"
 )
 
 // NodeType represents the type of a node in the graph
@@ -17,10 +22,16 @@ const (
 	NodeTypeCustom    NodeType = "custom"
 )
 
+// ErrMaxStepsExceeded is returned when a run executes more nodes than SetMaxSteps allows.
+var ErrMaxStepsExceeded = errors.New("graph: maximum steps exceeded")
+
 // State represents the execution state passed between nodes
 type State map[string]any
 
-// NodeFunc is the function executed by a node
+// NodeFunc is the function executed by a node. It may modify the state it is
+// given in place, return a different State, or both: the keys of a returned
+// State are merged into the state, while keys it omits are left untouched.
+// Keys are only removed by deleting them from the given state.
 type NodeFunc func(context.Context, State) (State, error)
 
 // ConditionFunc evaluates a condition and returns the next node name
@@ -32,9 +43,13 @@ type Node struct {
 	Type           NodeType
 	Execute        NodeFunc
 	Condition      ConditionFunc     // Only for condition nodes
-	NextNodes      []string          // Outgoing edges (order defines default)
-	NextMap        map[string]string // For condition nodes: condition result -> next node
+	NextNodes      []string          // Outgoing edges, activated in slice order
+	NextMap        map[string]string // For condition nodes: condition result -> next node, visited in sorted key order
 	WaitAllParents bool              // Whether execution waits for all parents to finish
+
+	RetryPolicy RetryPolicy   // How often a failing node is attempted
+	OnError     ErrorHandler  // Decides whether a failed attempt is retried, skipped or fails the run
+	Timeout     time.Duration // Deadline for a single attempt; zero means no limit
 }
 
 // Graph represents an execution flow graph
@@ -43,6 +58,13 @@ type Graph struct {
 	startNode string
 	endNode   string
 	maxVisits int
+	maxSteps  int
+
+	parallel bool         // Run independent queued nodes concurrently
+	reducer  StateReducer // Resolves keys written by several parallel nodes
+
+	checkpointer Checkpointer // Persists progress after each node, if set
+	observer     Observer     // Notified when nodes start and finish, if set
 }
 
 // NewGraph creates a new graph
@@ -139,6 +161,11 @@ func (g *Graph) SetEndNode(name string) {
 //  3. handleChildSignal inspects whether the current parent actually triggered a child
 //     (participated) and whether the child waits for all parents before enqueuing it,
 //     preventing missed or duplicated executions along conditional branches.
+//
+// Scheduling is deterministic: children are signalled in NextNodes slice order, and the
+// branches of a condition node in sorted NextMap key order, so the same graph and state
+// always execute nodes in the same sequence. See EnableParallel for running independent
+// nodes concurrently.
 func (g *Graph) Execute(ctx context.Context, initialState State) (State, error) {
 	if g.startNode == "" {
 		return nil, fmt.Errorf("start node not set")
@@ -149,99 +176,206 @@ func (g *Graph) Execute(ctx context.Context, initialState State) (State, error)
 		state = make(State)
 	}
 
-	// expectedParents stores how many unique parents each node has.
-	expectedParents := g.buildParentCounts()
-	// completedParents counts how many parents (participating or not) already reported completion.
-	completedParents := make(map[string]int)
-	// parentHits counts how many parents actually produced output for a child.
-	parentHits := make(map[string]int)
-	// awaiting tracks whether a node is already queued to avoid duplicates.
-	awaiting := make(map[string]bool)
-	// queue holds nodes pending execution, starting with the start node.
-	queue := []string{g.startNode}
-	awaiting[g.startNode] = true
-	visited := make(map[string]int)
-
-	for len(queue) > 0 {
-		currentNode := queue[0]
-		queue = queue[1:]
-		awaiting[currentNode] = false
-
-		// Fetch node metadata; failure means the graph definition is inconsistent.
-		node, exists := g.nodes[currentNode]
-		if !exists {
-			return nil, fmt.Errorf("node %s not found", currentNode)
-		}
+	run := g.newExecution()
+	if resumed, ok, err := run.resume(); err != nil {
+		return nil, err
+	} else if ok {
+		state = resumed
+	}
+
+	if g.parallel {
+		return g.executeParallel(ctx, run, state)
+	}
+
+	for len(run.queue) > 0 {
+		currentNode := run.queue[0]
+		run.queue = run.queue[1:]
 
-		// Detect runaway loops by counting how many times we revisit a node.
-		visited[currentNode]++
-		if visited[currentNode] > g.maxVisits {
-			return nil, fmt.Errorf("infinite loop detected at node %s", currentNode)
+		node, err := run.admit(currentNode)
+		if err != nil {
+			return nil, err
 		}
 
 		// End nodes terminate execution immediately and return the final state.
 		if node.Type == NodeTypeEnd {
-			return node.Execute(ctx, state)
+			return run.finish(ctx, node, state)
 		}
 
 		// Determine which child nodes should run next (e.g., the taken branch of a condition).
-		nextNodes, err := g.resolveNextNodes(ctx, node, state)
+		out, nextNodes, err := g.resolveNextNodes(ctx, node, state)
 		if err != nil {
 			return nil, err
 		}
+		mergeOutput(state, out)
+		if err := run.signalChildren(node, nextNodes); err != nil {
+			return nil, err
+		}
+		if err := run.save(node.Name, state, false); err != nil {
+			return nil, err
+		}
+	}
 
-		// allChildren captures every potential child; useful for notifying skipped branches.
-		allChildren := g.staticChildren(node)
-		triggered := make(map[string]struct{}, len(nextNodes))
+	return state, run.save("", state, true)
+}
 
-		// Send participation signals to children that were actually triggered.
-		for _, child := range nextNodes {
-			triggered[child] = struct{}{}
-			if err := g.handleChildSignal(child, true, parentHits, completedParents, expectedParents, awaiting, &queue); err != nil {
-				return nil, err
-			}
+// execution holds the scheduling state of a single Execute call.
+type execution struct {
+	g *Graph
+	// expectedParents stores how many unique parents each node has.
+	expectedParents map[string]int
+	// completedParents counts how many parents (participating or not) already reported completion.
+	completedParents map[string]int
+	// parentHits counts how many parents actually produced output for a child.
+	parentHits map[string]int
+	// awaiting tracks whether a node is already queued to avoid duplicates.
+	awaiting map[string]bool
+	// queue holds nodes pending execution, starting with the start node.
+	queue   []string
+	visited map[string]int
+	steps   int
+}
+
+func (g *Graph) newExecution() *execution {
+	return &execution{
+		g:                g,
+		expectedParents:  g.buildParentCounts(),
+		completedParents: make(map[string]int),
+		parentHits:       make(map[string]int),
+		awaiting:         map[string]bool{g.startNode: true},
+		queue:            []string{g.startNode},
+		visited:          make(map[string]int),
+	}
+}
+
+// admit marks a dequeued node as running and enforces the visit and step limits.
+func (e *execution) admit(name string) (*Node, error) {
+	e.awaiting[name] = false
+
+	// Fetch node metadata; failure means the graph definition is inconsistent.
+	node, exists := e.g.nodes[name]
+	if !exists {
+		return nil, fmt.Errorf("node %s not found", name)
+	}
+
+	// Detect runaway loops by counting how many times we revisit a node.
+	e.visited[name]++
+	if e.visited[name] > e.g.maxVisits {
+		return nil, fmt.Errorf("infinite loop detected at node %s", name)
+	}
+
+	// Bound the total work of a run regardless of which nodes are visited.
+	e.steps++
+	if e.g.maxSteps > 0 && e.steps > e.g.maxSteps {
+		return nil, fmt.Errorf("%w: limit %d reached before node %s", ErrMaxStepsExceeded, e.g.maxSteps, name)
+	}
+	return node, nil
+}
+
+// finish runs the end node and marks the run as completed.
+func (e *execution) finish(ctx context.Context, end *Node, state State) (State, error) {
+	var final State
+	err := e.g.observe(end, func() (err error) {
+		final, err = end.Execute(ctx, state)
+		return err
+	})
+	if err == nil {
+		err = e.save(end.Name, final, true)
+	}
+	return final, err
+}
+
+// signalChildren reports the completion of node to its children, enqueuing those
+// that are ready to run.
+func (e *execution) signalChildren(node *Node, nextNodes []string) error {
+	// allChildren captures every potential child; useful for notifying skipped branches.
+	allChildren := e.g.staticChildren(node)
+	triggered := make(map[string]struct{}, len(nextNodes))
+
+	// Send participation signals to children that were actually triggered.
+	for _, child := range nextNodes {
+		triggered[child] = struct{}{}
+		if err := e.g.handleChildSignal(child, true, e.parentHits, e.completedParents, e.expectedParents, e.awaiting, &e.queue); err != nil {
+			return err
 		}
+	}
 
-		// Inform remaining children that this parent finished without triggering them.
-		for _, child := range allChildren {
-			if _, ok := triggered[child]; ok {
-				continue
-			}
-			if err := g.handleChildSignal(child, false, parentHits, completedParents, expectedParents, awaiting, &queue); err != nil {
-				return nil, err
-			}
+	// Inform remaining children that this parent finished without triggering them.
+	for _, child := range allChildren {
+		if _, ok := triggered[child]; ok {
+			continue
+		}
+		if err := e.g.handleChildSignal(child, false, e.parentHits, e.completedParents, e.expectedParents, e.awaiting, &e.queue); err != nil {
+			return err
 		}
+	}
 
-		parentHits[currentNode] = 0
-		completedParents[currentNode] = 0
+	e.parentHits[node.Name] = 0
+	e.completedParents[node.Name] = 0
+	return nil
+}
+
+// resolveNextNodes runs node against state, honouring its retry and error
+// policy, and returns the state it produced together with the children to trigger.
+func (g *Graph) resolveNextNodes(ctx context.Context, node *Node, state State) (State, []string, error) {
+	if g.observer == nil {
+		return g.runWithPolicy(ctx, node, state)
 	}
+	var out State
+	var nextNodes []string
+	err := g.observe(node, func() (err error) {
+		out, nextNodes, err = g.runWithPolicy(ctx, node, state)
+		return err
+	})
+	return out, nextNodes, err
+}
 
-	return state, nil
+// mergeOutput copies the keys of a State returned by a node into state, unless
+// the node returned state itself or nil.
+func mergeOutput(state, out State) {
+	if out == nil || reflect.ValueOf(out).UnsafePointer() == reflect.ValueOf(state).UnsafePointer() {
+		return
+	}
+	maps.Copy(state, out)
 }
 
-func (g *Graph) resolveNextNodes(ctx context.Context, node *Node, state State) ([]string, error) {
+// attemptNode runs node once, within its Timeout if one is set. Nodes must honour
+// ctx for the timeout to interrupt them.
+func (g *Graph) attemptNode(ctx context.Context, node *Node, state State) (State, []string, error) {
+	if node.Timeout <= 0 {
+		return g.invokeNode(ctx, node, state)
+	}
+	nodeCtx, cancel := context.WithTimeout(ctx, node.Timeout)
+	defer cancel()
+	out, nextNodes, err := g.invokeNode(nodeCtx, node, state)
+	if err != nil && ctx.Err() == nil && errors.Is(nodeCtx.Err(), context.DeadlineExceeded) {
+		return nil, nil, fmt.Errorf("node %s timed out after %s: %w", node.Name, node.Timeout, context.DeadlineExceeded)
+	}
+	return out, nextNodes, err
+}
+
+func (g *Graph) invokeNode(ctx context.Context, node *Node, state State) (State, []string, error) {
 	switch node.Type {
 	case NodeTypeCondition:
 		result, err := node.Condition(ctx, state)
 		if err != nil {
-			return nil, fmt.Errorf("error evaluating condition at node %s: %w", node.Name, err)
+			return nil, nil, fmt.Errorf("error evaluating condition at node %s: %w", node.Name, err)
 		}
 		nextNode := node.NextMap[result]
 		if nextNode == "" {
-			return nil, fmt.Errorf("no next node specified for node %s", node.Name)
+			return nil, nil, fmt.Errorf("no next node specified for node %s", node.Name)
 		}
-		return []string{nextNode}, nil
+		return state, []string{nextNode}, nil
 	default:
 		var err error
 		state, err = node.Execute(ctx, state)
 		if err != nil {
-			return nil, fmt.Errorf("error executing node %s: %w", node.Name, err)
+			return nil, nil, fmt.Errorf("error executing node %s: %w", node.Name, err)
 		}
 		nextNodes := node.nextList()
 		if len(nextNodes) == 0 {
-			return nil, fmt.Errorf("no next node specified for node %s", node.Name)
+			return nil, nil, fmt.Errorf("no next node specified for node %s", node.Name)
 		}
-		return nextNodes, nil
+		return state, nextNodes, nil
 	}
 }
 
@@ -310,7 +444,7 @@ func (g *Graph) staticChildren(node *Node) []string {
 
 	var result []string
 	if node.Type == NodeTypeCondition {
-		for _, child := range node.NextMap {
+		for _, child := range node.branchTargets() {
 			add(&result, child)
 		}
 	}
@@ -321,6 +455,20 @@ func (g *Graph) staticChildren(node *Node) []string {
 	return result
 }
 
+// branchTargets returns the NextMap targets ordered by their condition result keys.
+func (n *Node) branchTargets() []string {
+	keys := make([]string, 0, len(n.NextMap))
+	for key := range n.NextMap {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	targets := make([]string, 0, len(keys))
+	for _, key := range keys {
+		targets = append(targets, n.NextMap[key])
+	}
+	return targets
+}
+
 // GetNode returns a node by name
 func (g *Graph) GetNode(name string) (*Node, error) {
 	node, exists := g.nodes[name]
@@ -330,11 +478,77 @@ func (g *Graph) GetNode(name string) (*Node, error) {
 	return node, nil
 }
 
+// Nodes returns copies of every node sorted by name.
+// Mutating the returned nodes does not affect the graph.
+func (g *Graph) Nodes() []*Node {
+	names := g.sortedNodeNames()
+	nodes := make([]*Node, 0, len(names))
+	for _, name := range names {
+		nodes = append(nodes, g.nodes[name].clone())
+	}
+	return nodes
+}
+
+// Edges returns every directed edge as [from, to] pairs, including conditional branches.
+// Edges are ordered by source node name; conditional branches follow their result keys.
+func (g *Graph) Edges() [][2]string {
+	var edges [][2]string
+	for _, name := range g.sortedNodeNames() {
+		node := g.nodes[name]
+		seen := make(map[string]struct{})
+		add := func(to string) {
+			if _, ok := seen[to]; ok {
+				return
+			}
+			seen[to] = struct{}{}
+			edges = append(edges, [2]string{name, to})
+		}
+		if node.Type == NodeTypeCondition {
+			for _, child := range node.branchTargets() {
+				add(child)
+			}
+		}
+		for _, child := range node.NextNodes {
+			add(child)
+		}
+	}
+	return edges
+}
+
+func (g *Graph) sortedNodeNames() []string {
+	names := make([]string, 0, len(g.nodes))
+	for name := range g.nodes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func (n *Node) clone() *Node {
+	cp := *n
+	if n.NextNodes != nil {
+		cp.NextNodes = append([]string(nil), n.NextNodes...)
+	}
+	if n.NextMap != nil {
+		cp.NextMap = make(map[string]string, len(n.NextMap))
+		for k, v := range n.NextMap {
+			cp.NextMap[k] = v
+		}
+	}
+	return &cp
+}
+
 // SetMaxVisits sets the maximum number of visits to a node
 func (g *Graph) SetMaxVisits(maxVisits int) {
 	g.maxVisits = maxVisits
 }
 
+// SetMaxSteps bounds the total number of node executions per run; zero means no limit.
+// Unlike SetMaxVisits it counts every node, so it also caps long acyclic runs.
+func (g *Graph) SetMaxSteps(maxSteps int) {
+	g.maxSteps = maxSteps
+}
+
 // Builder helps build graphs fluently
 type Builder struct {
 	graph *Graph
@@ -357,6 +571,17 @@ func (b *Builder) AddNode(name string, nodeType NodeType, execute NodeFunc) *Bui
 	return b
 }
 
+// AddNodeWithRetry adds a node that is retried according to policy when it fails.
+func (b *Builder) AddNodeWithRetry(name string, nodeType NodeType, execute NodeFunc, policy RetryPolicy) *Builder {
+	b.graph.AddNode(&Node{
+		Name:        name,
+		Type:        nodeType,
+		Execute:     execute,
+		RetryPolicy: policy,
+	})
+	return b
+}
+
 // AddConditionNode adds a condition node
 func (b *Builder) AddConditionNode(name string, condition ConditionFunc, nextMap map[string]string) *Builder {
 	b.graph.AddNode(&Node{
@@ -386,6 +611,28 @@ func (b *Builder) RequireAllParents(name string) *Builder {
 	return b
 }
 
+// OnError sets the handler deciding how failures of a node are handled.
+func (b *Builder) OnError(name string, handler ErrorHandler) *Builder {
+	node, exists := b.graph.nodes[name]
+	if !exists {
+		panic(fmt.Sprintf("node %s not found", name))
+	}
+	node.OnError = handler
+	return b
+}
+
+// SetTimeout bounds each attempt of a node to timeout; zero means no limit.
+// A timed out attempt counts as a failure, so it is retried under the node's
+// RetryPolicy.
+func (b *Builder) SetTimeout(name string, timeout time.Duration) *Builder {
+	node, exists := b.graph.nodes[name]
+	if !exists {
+		panic(fmt.Sprintf("node %s not found", name))
+	}
+	node.Timeout = timeout
+	return b
+}
+
 // SetStart sets the start node
 func (b *Builder) SetStart(name string) *Builder {
 	b.graph.SetStartNode(name)
@@ -404,6 +651,37 @@ func (b *Builder) SetMaxVisits(maxVisits int) *Builder {
 	return b
 }
 
+// SetMaxSteps bounds the total number of node executions per run
+func (b *Builder) SetMaxSteps(maxSteps int) *Builder {
+	b.graph.SetMaxSteps(maxSteps)
+	return b
+}
+
+// EnableParallel runs independent queued nodes concurrently; see Graph.EnableParallel.
+func (b *Builder) EnableParallel() *Builder {
+	b.graph.EnableParallel()
+	return b
+}
+
+// SetStateReducer sets how parallel nodes writing the same key are merged.
+func (b *Builder) SetStateReducer(reducer StateReducer) *Builder {
+	b.graph.SetStateReducer(reducer)
+	return b
+}
+
+// WithCheckpointer saves progress after each node and resumes unfinished runs;
+// see Graph.SetCheckpointer.
+func (b *Builder) WithCheckpointer(checkpointer Checkpointer) *Builder {
+	b.graph.SetCheckpointer(checkpointer)
+	return b
+}
+
+// WithObserver sets a callback notified when each node starts and finishes.
+func (b *Builder) WithObserver(observer Observer) *Builder {
+	b.graph.SetObserver(observer)
+	return b
+}
+
 // Build returns the constructed graph
 func (b *Builder) Build() *Graph {
 	return b.graph
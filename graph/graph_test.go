@@ -2,8 +2,12 @@ package graph
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"path/filepath"
+	"reflect"
 	"testing"
+	"time"
 )
 
 var noopExecute = func(ctx context.Context, state State) (State, error) {
@@ -587,3 +591,511 @@ func TestGetNodeNotFound(t *testing.T) {
 		t.Errorf("Expected error when getting non-existent node")
 	}
 }
+
+func TestNodesAndEdges(t *testing.T) {
+	g := NewBuilder().
+		AddNode("start", NodeTypeStart, noopExecute).
+		AddConditionNode("route", func(ctx context.Context, state State) (string, error) {
+			return "a", nil
+		}, map[string]string{"a": "left", "b": "right"}).
+		AddNode("left", NodeTypeCustom, noopExecute).
+		AddNode("right", NodeTypeCustom, noopExecute).
+		AddNode("end", NodeTypeEnd, noopExecute).
+		AddEdge("start", "route").
+		AddEdge("left", "end").
+		AddEdge("right", "end").
+		Build()
+
+	nodes := g.Nodes()
+	if len(nodes) != 5 {
+		t.Fatalf("expected 5 nodes, got %d", len(nodes))
+	}
+	if nodes[0].Name != "end" || nodes[4].Name != "start" {
+		t.Errorf("expected nodes sorted by name, got %s..%s", nodes[0].Name, nodes[4].Name)
+	}
+
+	for _, n := range nodes {
+		if n.Name == "route" {
+			n.NextMap["a"] = "right"
+		}
+		if n.Name == "start" {
+			n.NextNodes[0] = "end"
+		}
+	}
+	route, _ := g.GetNode("route")
+	start, _ := g.GetNode("start")
+	if route.NextMap["a"] != "left" || start.NextNodes[0] != "route" {
+		t.Errorf("mutating returned nodes should not affect the graph")
+	}
+
+	expected := [][2]string{
+		{"left", "end"},
+		{"right", "end"},
+		{"route", "left"},
+		{"route", "right"},
+		{"start", "route"},
+	}
+	edges := g.Edges()
+	if len(edges) != len(expected) {
+		t.Fatalf("expected %d edges, got %v", len(expected), edges)
+	}
+	for i, e := range expected {
+		if edges[i] != e {
+			t.Errorf("edge %d: expected %v, got %v", i, e, edges[i])
+		}
+	}
+}
+
+func TestMaxStepsBoundsTotalExecutions(t *testing.T) {
+	build := func(maxSteps int) *Graph {
+		b := NewBuilder().
+			AddNode("start", NodeTypeStart, noopExecute).
+			AddNode("a", NodeTypeCustom, noopExecute).
+			AddNode("b", NodeTypeCustom, noopExecute).
+			AddNode("c", NodeTypeCustom, noopExecute).
+			AddNode("end", NodeTypeEnd, noopExecute).
+			AddEdge("start", "a").
+			AddEdge("a", "b").
+			AddEdge("b", "c").
+			AddEdge("c", "end").
+			SetMaxSteps(maxSteps)
+		return b.Build()
+	}
+
+	if _, err := build(3).Execute(context.Background(), nil); !errors.Is(err, ErrMaxStepsExceeded) {
+		t.Fatalf("expected ErrMaxStepsExceeded, got %v", err)
+	}
+	if _, err := build(5).Execute(context.Background(), nil); err != nil {
+		t.Fatalf("expected run within the limit to succeed, got %v", err)
+	}
+	if _, err := build(0).Execute(context.Background(), nil); err != nil {
+		t.Fatalf("expected zero to disable the limit, got %v", err)
+	}
+}
+
+func TestExecuteOrderIsDeterministic(t *testing.T) {
+	record := func(name string) NodeFunc {
+		return func(ctx context.Context, state State) (State, error) {
+			order, _ := state["order"].([]string)
+			state["order"] = append(order, name)
+			return state, nil
+		}
+	}
+	build := func() *Graph {
+		return NewBuilder().
+			AddNode("start", NodeTypeStart, noopExecute).
+			AddNode("seed", NodeTypeCustom, record("seed")).
+			AddConditionNode("router", func(ctx context.Context, state State) (string, error) {
+				return "y", nil
+			}, map[string]string{"z": "r", "x": "p", "y": "q"}).
+			AddNode("p", NodeTypeCustom, record("p")).
+			AddNode("q", NodeTypeCustom, record("q")).
+			AddNode("r", NodeTypeCustom, record("r")).
+			AddNode("collect", NodeTypeCustom, record("collect")).
+			AddNode("end", NodeTypeEnd, noopExecute).
+			AddEdge("start", "seed").
+			AddEdge("start", "router").
+			AddEdge("seed", "r").
+			AddEdge("seed", "q").
+			AddEdge("seed", "p").
+			AddEdge("p", "collect").
+			AddEdge("q", "collect").
+			AddEdge("r", "collect").
+			AddEdge("collect", "end").
+			RequireAllParents("p").
+			RequireAllParents("q").
+			RequireAllParents("r").
+			RequireAllParents("collect").
+			Build()
+	}
+
+	// The taken branch runs first, then skipped branches in sorted NextMap key order.
+	want := "[seed q p r collect]"
+	for i := 0; i < 50; i++ {
+		state, err := build().Execute(context.Background(), nil)
+		if err != nil {
+			t.Fatalf("Graph execution failed: %v", err)
+		}
+		if got := fmt.Sprint(state["order"]); got != want {
+			t.Fatalf("run %d: expected order %s, got %s", i, want, got)
+		}
+	}
+}
+
+func TestExecuteParallelRunsIndependentNodesConcurrently(t *testing.T) {
+	// Each worker waits for the other to start, which only succeeds when both run at once.
+	started := map[string]chan struct{}{"worker_a": make(chan struct{}), "worker_b": make(chan struct{})}
+	worker := func(self, other, key string) NodeFunc {
+		return func(ctx context.Context, state State) (State, error) {
+			close(started[self])
+			select {
+			case <-started[other]:
+			case <-time.After(time.Second):
+				return nil, fmt.Errorf("%s did not overlap with %s", self, other)
+			}
+			state[key] = self
+			state["last"] = self
+			return state, nil
+		}
+	}
+	g := NewBuilder().
+		AddNode("start", NodeTypeStart, noopExecute).
+		AddNode("worker_a", NodeTypeCustom, worker("worker_a", "worker_b", "a")).
+		AddNode("worker_b", NodeTypeCustom, worker("worker_b", "worker_a", "b")).
+		AddNode("join", NodeTypeCustom, noopExecute).
+		AddNode("end", NodeTypeEnd, noopExecute).
+		AddEdge("start", "worker_a").
+		AddEdge("start", "worker_b").
+		AddEdge("worker_a", "join").
+		AddEdge("worker_b", "join").
+		AddEdge("join", "end").
+		RequireAllParents("join").
+		SetStart("start").
+		SetEnd("end").
+		EnableParallel().
+		Build()
+
+	state, err := g.Execute(context.Background(), State{"untouched": 1})
+	if err != nil {
+		t.Fatalf("Graph execution failed: %v", err)
+	}
+	if state["a"] != "worker_a" || state["b"] != "worker_b" || state["untouched"] != 1 {
+		t.Fatalf("expected both workers' writes to be merged, got %v", state)
+	}
+	if state["last"] != "worker_b" {
+		t.Fatalf("expected the node queued last to win a conflicting key, got %v", state["last"])
+	}
+}
+
+func TestExecuteParallelStateReducer(t *testing.T) {
+	appendItem := func(item string) NodeFunc {
+		return func(ctx context.Context, state State) (State, error) {
+			items, _ := state["items"].([]string)
+			state["items"] = append(append([]string(nil), items...), item)
+			return state, nil
+		}
+	}
+	g := NewBuilder().
+		AddNode("start", NodeTypeStart, noopExecute).
+		AddNode("x", NodeTypeCustom, appendItem("x")).
+		AddNode("y", NodeTypeCustom, appendItem("y")).
+		AddNode("z", NodeTypeCustom, appendItem("z")).
+		AddNode("end", NodeTypeEnd, noopExecute).
+		AddEdge("start", "x").
+		AddEdge("start", "y").
+		AddEdge("start", "z").
+		AddEdge("x", "end").
+		AddEdge("y", "end").
+		AddEdge("z", "end").
+		RequireAllParents("end").
+		SetStart("start").
+		SetEnd("end").
+		EnableParallel().
+		SetStateReducer(func(key string, current, update any) any {
+			// Each node appended one item to the shared prefix; keep the new tail.
+			merged := current.([]string)
+			added := update.([]string)
+			return append(merged, added[len(added)-1])
+		}).
+		Build()
+
+	state, err := g.Execute(context.Background(), State{"items": []string{"seed"}})
+	if err != nil {
+		t.Fatalf("Graph execution failed: %v", err)
+	}
+	if got := fmt.Sprint(state["items"]); got != "[seed x y z]" {
+		t.Fatalf("expected reducer to combine every write in queue order, got %s", got)
+	}
+}
+
+func TestExecuteParallelReportsNodeErrors(t *testing.T) {
+	boom := errors.New("boom")
+	g := NewBuilder().
+		AddNode("start", NodeTypeStart, noopExecute).
+		AddNode("ok", NodeTypeCustom, noopExecute).
+		AddNode("fail", NodeTypeCustom, func(ctx context.Context, state State) (State, error) {
+			return nil, boom
+		}).
+		AddNode("end", NodeTypeEnd, noopExecute).
+		AddEdge("start", "ok").
+		AddEdge("start", "fail").
+		AddEdge("ok", "end").
+		AddEdge("fail", "end").
+		SetStart("start").
+		SetEnd("end").
+		EnableParallel().
+		Build()
+
+	if _, err := g.Execute(context.Background(), nil); !errors.Is(err, boom) {
+		t.Fatalf("expected node error, got %v", err)
+	}
+}
+
+func TestExecuteMergesReturnedStateInBothModes(t *testing.T) {
+	build := func(parallel bool) *Graph {
+		b := NewBuilder().
+			AddNode("start", NodeTypeStart, noopExecute).
+			AddNode("fresh", NodeTypeCustom, func(ctx context.Context, state State) (State, error) {
+				// A new partial map: its keys are merged, the omitted ones survive.
+				return State{"answer": 42, "seed": "overwritten"}, nil
+			}).
+			AddNode("prune", NodeTypeCustom, func(ctx context.Context, state State) (State, error) {
+				delete(state, "scratch")
+				return nil, nil
+			}).
+			AddNode("end", NodeTypeEnd, noopExecute).
+			AddEdge("start", "fresh").
+			AddEdge("fresh", "prune").
+			AddEdge("prune", "end").
+			SetStart("start").
+			SetEnd("end")
+		if parallel {
+			b = b.EnableParallel()
+		}
+		return b.Build()
+	}
+
+	for _, parallel := range []bool{false, true} {
+		state, err := build(parallel).Execute(context.Background(), State{"seed": "initial", "kept": 1, "scratch": true})
+		if err != nil {
+			t.Fatalf("parallel=%v: Graph execution failed: %v", parallel, err)
+		}
+		want := State{"seed": "overwritten", "kept": 1, "answer": 42}
+		if !reflect.DeepEqual(state, want) {
+			t.Fatalf("parallel=%v: state = %v, want %v", parallel, state, want)
+		}
+	}
+}
+
+func TestExecuteRetriesFlakyNode(t *testing.T) {
+	attempts, failures := 0, 2
+	flaky := func(ctx context.Context, state State) (State, error) {
+		attempts++
+		state["partial"] = attempts
+		if attempts <= failures {
+			return state, errors.New("transient failure")
+		}
+		state["done"] = true
+		return state, nil
+	}
+	g := NewBuilder().
+		AddNode("start", NodeTypeStart, noopExecute).
+		AddNodeWithRetry("flaky", NodeTypeCustom, flaky, RetryPolicy{MaxAttempts: 3, Backoff: time.Millisecond, Multiplier: 2}).
+		AddNode("end", NodeTypeEnd, noopExecute).
+		AddEdge("start", "flaky").
+		AddEdge("flaky", "end").
+		Build()
+
+	state, err := g.Execute(context.Background(), nil)
+	if err != nil {
+		t.Fatalf("expected retries to recover, got %v", err)
+	}
+	if attempts != 3 || state["done"] != true || state["partial"] != 3 {
+		t.Fatalf("expected success on the third attempt, got %d attempts and state %v", attempts, state)
+	}
+
+	attempts, failures = 0, 5
+	if _, err := g.Execute(context.Background(), nil); err == nil || attempts != 3 {
+		t.Fatalf("expected failure after exhausting attempts, got %v after %d attempts", err, attempts)
+	}
+}
+
+func TestExecuteOnErrorSkipsNode(t *testing.T) {
+	var seen []int
+	g := NewBuilder().
+		AddNode("start", NodeTypeStart, noopExecute).
+		AddNodeWithRetry("broken", NodeTypeCustom, func(ctx context.Context, state State) (State, error) {
+			state["corrupted"] = true
+			return state, errors.New("always fails")
+		}, RetryPolicy{MaxAttempts: 2}).
+		AddNode("after", NodeTypeCustom, func(ctx context.Context, state State) (State, error) {
+			state["after"] = true
+			return state, nil
+		}).
+		AddNode("end", NodeTypeEnd, noopExecute).
+		AddEdge("start", "broken").
+		AddEdge("broken", "after").
+		AddEdge("after", "end").
+		OnError("broken", func(ctx context.Context, node string, attempt int, err error) ErrorAction {
+			seen = append(seen, attempt)
+			if attempt < 2 {
+				return Retry
+			}
+			return Skip
+		}).
+		Build()
+
+	state, err := g.Execute(context.Background(), State{"input": 1})
+	if err != nil {
+		t.Fatalf("expected skipped node to keep the run going, got %v", err)
+	}
+	if fmt.Sprint(seen) != "[1 2]" {
+		t.Fatalf("expected handler to see both attempts, got %v", seen)
+	}
+	if state["after"] != true || state["input"] != 1 || state["corrupted"] != nil {
+		t.Fatalf("expected children to run with the unchanged state, got %v", state)
+	}
+}
+
+func TestExecuteNodeTimeout(t *testing.T) {
+	attempts := 0
+	slow := func(ctx context.Context, state State) (State, error) {
+		attempts++
+		if attempts == 1 {
+			<-ctx.Done()
+			return nil, ctx.Err()
+		}
+		state["done"] = true
+		return state, nil
+	}
+	build := func(policy RetryPolicy) *Graph {
+		return NewBuilder().
+			AddNode("start", NodeTypeStart, noopExecute).
+			AddNodeWithRetry("slow", NodeTypeCustom, slow, policy).
+			AddNode("end", NodeTypeEnd, noopExecute).
+			AddEdge("start", "slow").
+			AddEdge("slow", "end").
+			SetTimeout("slow", 10*time.Millisecond).
+			Build()
+	}
+
+	_, err := build(RetryPolicy{}).Execute(context.Background(), nil)
+	if !errors.Is(err, context.DeadlineExceeded) || err.Error() != "node slow timed out after 10ms: context deadline exceeded" {
+		t.Fatalf("expected descriptive timeout error, got %v", err)
+	}
+
+	attempts = 0
+	state, err := build(RetryPolicy{MaxAttempts: 2}).Execute(context.Background(), nil)
+	if err != nil || state["done"] != true || attempts != 2 {
+		t.Fatalf("expected timed out attempt to be retried, got %v after %d attempts", err, attempts)
+	}
+}
+
+func TestToDOT(t *testing.T) {
+	g := NewBuilder().
+		AddNode("start", NodeTypeStart, noopExecute).
+		AddNode("retrieve", NodeTypeTool, noopExecute).
+		AddConditionNode("check", func(ctx context.Context, state State) (string, error) {
+			return "ok", nil
+		}, map[string]string{"ok": "answer", "retry": "retrieve"}).
+		AddNode("answer", NodeTypeLLM, noopExecute).
+		AddNode("end", NodeTypeEnd, noopExecute).
+		AddEdge("start", "retrieve").
+		AddEdge("retrieve", "check").
+		AddEdge("answer", "end").
+		RequireAllParents("end").
+		SetStart("start").
+		SetEnd("end").
+		Build()
+
+	want := `digraph G {
+  rankdir=TB;
+  node [fontname="Helvetica"];
+  "answer" [label="answer\n(llm)", shape=box, style=rounded];
+  "check" [label="check\n(condition)", shape=diamond];
+  "end" [label="end\n(end)\nwaits for all parents", shape=oval, peripheries=2];
+  "retrieve" [label="retrieve\n(tool)", shape=component];
+  "start" [label="start\n(start)", shape=oval, penwidth=2];
+  "answer" -> "end";
+  "check" -> "answer" [style=dashed, label="ok"];
+  "check" -> "retrieve" [style=dashed, label="retry"];
+  "retrieve" -> "check";
+  "start" -> "retrieve";
+}
+`
+	if got := g.ToDOT(); got != want {
+		t.Fatalf("unexpected DOT output:\n%s", got)
+	}
+	if got := dotQuote(`say "hi"`); got != `"say \"hi\""` {
+		t.Fatalf("expected quotes to be escaped, got %s", got)
+	}
+}
+
+func TestExecuteResumesFromCheckpoint(t *testing.T) {
+	runs := map[string]int{}
+	crash := true
+	step := func(name string) NodeFunc {
+		return func(ctx context.Context, state State) (State, error) {
+			runs[name]++
+			if name == "b" && crash {
+				return nil, errors.New("process crashed")
+			}
+			state[name] = runs[name]
+			return state, nil
+		}
+	}
+	checkpointer := NewFileCheckpointer(filepath.Join(t.TempDir(), "run.json"))
+	g := NewBuilder().
+		AddNode("start", NodeTypeStart, step("start")).
+		AddNode("a", NodeTypeCustom, step("a")).
+		AddNode("b", NodeTypeCustom, step("b")).
+		AddNode("end", NodeTypeEnd, noopExecute).
+		AddEdge("start", "a").
+		AddEdge("a", "b").
+		AddEdge("b", "end").
+		WithCheckpointer(checkpointer).
+		Build()
+
+	if _, err := g.Execute(context.Background(), State{"input": "x"}); err == nil {
+		t.Fatal("expected the first run to fail")
+	}
+	if node, saved, err := checkpointer.Load(); err != nil || node != "a" || saved["a"] != float64(1) {
+		t.Fatalf("expected checkpoint after node a, got %q %v (%v)", node, saved, err)
+	}
+
+	crash = false
+	state, err := g.Execute(context.Background(), nil)
+	if err != nil {
+		t.Fatalf("resumed run failed: %v", err)
+	}
+	if runs["start"] != 1 || runs["a"] != 1 || runs["b"] != 2 {
+		t.Fatalf("expected only node b to run again, got %v", runs)
+	}
+	if state["input"] != "x" || state["b"] != 2 || state[CheckpointKey] != nil {
+		t.Fatalf("expected checkpointed state to carry over, got %v", state)
+	}
+
+	if _, err := g.Execute(context.Background(), nil); err != nil || runs["start"] != 2 {
+		t.Fatalf("expected a finished run to start over, got %v after %v", err, runs)
+	}
+
+	unserializable := NewBuilder().
+		AddNode("start", NodeTypeStart, func(ctx context.Context, state State) (State, error) {
+			state["ch"] = make(chan int)
+			return state, nil
+		}).
+		AddNode("end", NodeTypeEnd, noopExecute).
+		AddEdge("start", "end").
+		WithCheckpointer(NewMemoryCheckpointer()).
+		Build()
+	if _, err := unserializable.Execute(context.Background(), nil); err == nil {
+		t.Fatal("expected non-JSON state to fail checkpointing")
+	}
+}
+
+func TestExecuteNotifiesObserver(t *testing.T) {
+	var events []string
+	g := NewBuilder().
+		AddNode("start", NodeTypeStart, noopExecute).
+		AddNode("work", NodeTypeTool, func(ctx context.Context, state State) (State, error) {
+			return nil, errors.New("tool failed")
+		}).
+		AddNode("end", NodeTypeEnd, noopExecute).
+		AddEdge("start", "work").
+		AddEdge("work", "end").
+		WithObserver(func(event NodeEvent) {
+			if event.StartedAt.IsZero() || (event.Phase == NodeExit) == event.EndedAt.IsZero() {
+				t.Errorf("unexpected timestamps in %+v", event)
+			}
+			events = append(events, fmt.Sprintf("%s:%s:%s:%v", event.Phase, event.Node, event.Type, event.Err))
+		}).
+		Build()
+
+	if _, err := g.Execute(context.Background(), nil); err == nil {
+		t.Fatal("expected execution to fail")
+	}
+	want := "[enter:start:start:<nil> exit:start:start:<nil> enter:work:tool:<nil> exit:work:tool:error executing node work: tool failed]"
+	if got := fmt.Sprint(events); got != want {
+		t.Fatalf("unexpected events:\n got %s\nwant %s", got, want)
+	}
+}
@@ -0,0 +1,45 @@
+package graph
+
+import "time"
+
+// NodeEventPhase tells whether a NodeEvent marks the start or the end of a node.
+type NodeEventPhase string
+
+const (
+	NodeEnter NodeEventPhase = "enter"
+	NodeExit  NodeEventPhase = "exit"
+)
+
+// NodeEvent describes a node entering or leaving execution. Retries of a node
+// are reported as a single enter/exit pair.
+type NodeEvent struct {
+	Node      string
+	Type      NodeType
+	Phase     NodeEventPhase
+	StartedAt time.Time
+	EndedAt   time.Time // Zero for NodeEnter
+	Err       error     // Set on NodeExit when the node failed
+}
+
+// Observer receives node events during Execute. It runs synchronously on the
+// executing goroutine, so it should return quickly; in parallel mode it may be
+// called concurrently and must be safe for that.
+type Observer func(event NodeEvent)
+
+// SetObserver sets the observer notified when nodes are entered and exited.
+func (g *Graph) SetObserver(observer Observer) {
+	g.observer = observer
+}
+
+// observe runs fn for node, reporting enter and exit events to the observer.
+func (g *Graph) observe(node *Node, fn func() error) error {
+	if g.observer == nil {
+		return fn()
+	}
+	event := NodeEvent{Node: node.Name, Type: node.Type, Phase: NodeEnter, StartedAt: time.Now()}
+	g.observer(event)
+	err := fn()
+	event.Phase, event.EndedAt, event.Err = NodeExit, time.Now(), err
+	g.observer(event)
+	return err
+}
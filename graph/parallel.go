@@ -0,0 +1,149 @@
+package graph
+
+import (
+	"context"
+	"maps"
+	"reflect"
+	"sync"
+)
+
+// StateReducer merges a key written by more than one node of the same parallel
+// step. current is the value merged so far and update the value written by the
+// next node in queue order; the returned value is stored in the state.
+type StateReducer func(key string, current, update any) any
+
+// EnableParallel makes Execute run the nodes that are ready at the same time on
+// separate goroutines instead of one after another.
+//
+// Nodes are scheduled in steps: every node queued at the start of a step has
+// all of its dependencies satisfied, so they run concurrently. Each node gets
+// its own shallow copy of the state, which keeps concurrent writes to the
+// top-level map safe. Nodes must still not mutate nested maps or slices in
+// place, as those are shared between copies. A returned State is folded into
+// the node's copy as described on NodeFunc. Once the step finishes, the keys
+// each node added, changed or deleted are merged into the state in queue
+// order, so the last writer wins per key unless a StateReducer is set. Children
+// are then signalled exactly as in sequential execution.
+//
+// When the end node is reached, the nodes queued before it finish their step
+// and the nodes queued after it are discarded, as in sequential execution.
+func (g *Graph) EnableParallel() {
+	g.parallel = true
+}
+
+// SetStateReducer sets how a key written by several nodes of one parallel step
+// is merged. Without a reducer the node queued last wins. A deletion discards
+// earlier writes and is not passed to the reducer.
+func (g *Graph) SetStateReducer(reducer StateReducer) {
+	g.reducer = reducer
+}
+
+// stepResult is the outcome of one node in a parallel step.
+type stepResult struct {
+	node      *Node
+	state     State
+	nextNodes []string
+	err       error
+}
+
+// executeParallel is Execute with the nodes of each step running concurrently.
+func (g *Graph) executeParallel(ctx context.Context, run *execution, state State) (State, error) {
+	for len(run.queue) > 0 {
+		step := run.queue
+		run.queue = nil
+
+		var end *Node
+		nodes := make([]*Node, 0, len(step))
+		for _, name := range step {
+			node, err := run.admit(name)
+			if err != nil {
+				return nil, err
+			}
+			if node.Type == NodeTypeEnd {
+				end = node
+				break
+			}
+			nodes = append(nodes, node)
+		}
+
+		results := g.runStep(ctx, nodes, state)
+		for _, result := range results {
+			if result.err != nil {
+				return nil, result.err
+			}
+		}
+		g.mergeStep(state, results)
+
+		if end != nil {
+			return run.finish(ctx, end, state)
+		}
+		for _, result := range results {
+			if err := run.signalChildren(result.node, result.nextNodes); err != nil {
+				return nil, err
+			}
+		}
+		if len(results) > 0 {
+			if err := run.save(results[len(results)-1].node.Name, state, false); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return state, run.save("", state, true)
+}
+
+// runStep runs nodes concurrently, each against its own copy of state.
+func (g *Graph) runStep(ctx context.Context, nodes []*Node, state State) []stepResult {
+	results := make([]stepResult, len(nodes))
+	if len(nodes) == 1 {
+		// A lone node cannot race with anyone; skip the goroutine but keep the copy
+		// so merging behaves the same regardless of step size.
+		results[0] = g.runNode(ctx, nodes[0], maps.Clone(state))
+		return results
+	}
+
+	var wg sync.WaitGroup
+	for i, node := range nodes {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i] = g.runNode(ctx, node, maps.Clone(state))
+		}()
+	}
+	wg.Wait()
+	return results
+}
+
+func (g *Graph) runNode(ctx context.Context, node *Node, state State) stepResult {
+	out, nextNodes, err := g.resolveNextNodes(ctx, node, state)
+	if err == nil {
+		// Fold the returned State into the node's copy exactly as sequential
+		// execution folds it into the shared state.
+		mergeOutput(state, out)
+	}
+	return stepResult{node: node, state: state, nextNodes: nextNodes, err: err}
+}
+
+// mergeStep applies the changes each result made relative to base, in order.
+func (g *Graph) mergeStep(base State, results []stepResult) {
+	original := maps.Clone(base)
+	written := make(map[string]bool)
+	for _, result := range results {
+		for key, value := range result.state {
+			if old, ok := original[key]; ok && reflect.DeepEqual(old, value) {
+				continue
+			}
+			if written[key] && g.reducer != nil {
+				value = g.reducer(key, base[key], value)
+			}
+			base[key] = value
+			written[key] = true
+		}
+		for key := range original {
+			if _, ok := result.state[key]; !ok {
+				delete(base, key)
+				written[key] = false
+			}
+		}
+	}
+}
@@ -0,0 +1,98 @@
+package graph
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"time"
+)
+
+// RetryPolicy controls how often a failing node is attempted. The zero value
+// runs a node once.
+type RetryPolicy struct {
+	MaxAttempts int           // Total attempts including the first one
+	Backoff     time.Duration // Delay before the first retry
+	Multiplier  float64       // Growth factor applied to the delay after each retry; below 1 keeps it constant
+}
+
+// delay returns the wait before the given retry (1-based).
+func (p RetryPolicy) delay(retry int) time.Duration {
+	d := float64(p.Backoff)
+	for i := 1; i < retry && p.Multiplier > 1; i++ {
+		d *= p.Multiplier
+	}
+	return time.Duration(d)
+}
+
+// ErrorAction tells Execute how to continue after a node attempt fails.
+type ErrorAction int
+
+const (
+	// Fail aborts the execution with the node's error.
+	Fail ErrorAction = iota
+	// Retry attempts the node again if its RetryPolicy allows another attempt,
+	// and fails otherwise.
+	Retry
+	// Skip gives up on the node and continues with its children as if it had
+	// succeeded without changing the state. A skipped condition node takes
+	// none of its branches.
+	Skip
+)
+
+// ErrorHandler is called after every failed attempt of a node with the 1-based
+// attempt number and the error, and decides how execution continues.
+type ErrorHandler func(ctx context.Context, node string, attempt int, err error) ErrorAction
+
+// runWithPolicy runs node under its RetryPolicy and OnError handler. The state is
+// restored after every failed attempt, so retries and skips start from the state
+// the node originally received.
+func (g *Graph) runWithPolicy(ctx context.Context, node *Node, state State) (State, []string, error) {
+	if node.RetryPolicy.MaxAttempts <= 1 && node.OnError == nil {
+		return g.attemptNode(ctx, node, state)
+	}
+
+	snapshot := maps.Clone(state)
+	for attempt := 1; ; attempt++ {
+		out, nextNodes, err := g.attemptNode(ctx, node, state)
+		if err == nil {
+			return out, nextNodes, nil
+		}
+		clear(state)
+		maps.Copy(state, snapshot)
+
+		action := Retry
+		if node.OnError != nil {
+			action = node.OnError(ctx, node.Name, attempt, err)
+		}
+		switch {
+		case action == Skip:
+			if node.Type == NodeTypeCondition {
+				return state, nil, nil
+			}
+			return state, node.nextList(), nil
+		case action == Retry && attempt < node.RetryPolicy.MaxAttempts:
+			if waitErr := sleep(ctx, node.RetryPolicy.delay(attempt)); waitErr != nil {
+				return nil, nil, fmt.Errorf("node %s: %w", node.Name, waitErr)
+			}
+		case attempt > 1:
+			return nil, nil, fmt.Errorf("node %s failed after %d attempts: %w", node.Name, attempt, err)
+		default:
+			return nil, nil, err
+		}
+	}
+}
+
+// sleep waits for d or until ctx is done.
+func sleep(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
@@ -2,9 +2,9 @@ package memory
 
 import (
 	"context"
-	"fmt"
-	"sync"
 	"time"
+
+	"github.com/sweetpotato0/ai-allin/pkg/id"
 )
 
 // Memory represents a stored memory/conversation entry
@@ -16,49 +16,27 @@ type Memory struct {
 	UpdatedAt time.Time      `json:"updated_at"`
 }
 
-// idGenerator provides efficient ID generation with minimal syscall overhead
-type idGenerator struct {
-	counter int64
-	mu      sync.Mutex
-	lastTs  int64
-}
-
-var defaultIDGenerator = &idGenerator{}
+// DefaultIDGenerator produces memory IDs for stores that are not given their own generator.
+var DefaultIDGenerator id.Generator = id.Prefixed("mem_", nil)
 
-// GenerateMemoryID generates a unique ID for a memory entry
-// Uses an efficient approach that minimizes syscall overhead:
-// - Only calls time.Now() when nanosecond changes
-// - Uses atomic counter for fast collision-free increments
-// - Fallback to timestamp for first call or major time jumps
+// GenerateMemoryID generates a unique ID for a memory entry using DefaultIDGenerator.
 func GenerateMemoryID() string {
-	return defaultIDGenerator.Generate()
-}
-
-// Generate creates a unique memory ID efficiently
-func (g *idGenerator) Generate() string {
-	// Get current time once
-	now := time.Now().UnixNano()
-
-	// Fast path: if we're in same nanosecond, just increment counter
-	g.mu.Lock()
-	if now > g.lastTs {
-		// Time moved forward, reset counter
-		g.lastTs = now
-		g.counter = 0
-		g.mu.Unlock()
-		return fmt.Sprintf("mem_%d", now)
-	}
-
-	// Still in same nanosecond, increment counter for uniqueness
-	g.counter++
-	counter := g.counter
-	g.mu.Unlock()
-
-	return fmt.Sprintf("mem_%d_%d", now, counter)
+	return DefaultIDGenerator.New()
 }
 
 // MemoryStore defines the interface for storing and retrieving memories.
 type MemoryStore interface {
 	AddMemory(context.Context, *Memory) error
 	SearchMemory(context.Context, string) ([]*Memory, error)
+
+	// DeleteMemory removes the memory with the given ID. Unknown IDs yield an
+	// error wrapping errors.ErrNotFound from pkg/errors.
+	DeleteMemory(ctx context.Context, id string) error
+	// UpdateMemory replaces the content and metadata of an existing memory,
+	// keeping its CreatedAt and refreshing UpdatedAt. Unknown IDs yield an error
+	// wrapping errors.ErrNotFound from pkg/errors.
+	UpdateMemory(ctx context.Context, mem *Memory) error
+	// ListMemories pages through memories newest first. A limit of zero or less
+	// returns every memory after offset.
+	ListMemories(ctx context.Context, limit, offset int) ([]*Memory, error)
 }
@@ -1,6 +1,7 @@
 package memory
 
 import (
+	"strings"
 	"testing"
 	"time"
 )
@@ -52,6 +53,10 @@ func TestGenerateMemoryID(t *testing.T) {
 	if id1 == id3 {
 		t.Errorf("IDs should be different even with tiny time difference")
 	}
+
+	if !strings.HasPrefix(id1, "mem_") {
+		t.Errorf("Expected mem_ prefix, got %s", id1)
+	}
 }
 
 func TestMemoryWithoutMetadata(t *testing.T) {
@@ -1,6 +1,11 @@
 package message
 
-import "time"
+import (
+	"encoding/base64"
+	"strconv"
+	"sync/atomic"
+	"time"
+)
 
 // Role represents the role of the message sender
 type Role string
@@ -23,6 +28,16 @@ type Message struct {
 	ToolID       string         `json:"tool_id,omitempty"` // For tool response messages
 	Metadata     map[string]any `json:"metadata,omitempty"`
 	CreatedAt    time.Time      `json:"created_at"`
+	// CacheControl marks the message as the end of a cacheable prompt prefix for
+	// providers with explicit prompt caching. Nil leaves caching to the provider.
+	CacheControl *CacheControl `json:"cache_control,omitempty"`
+}
+
+// CacheControl is a prompt caching breakpoint.
+type CacheControl struct {
+	// TTL is the cache lifetime understood by the provider, e.g. "5m" or "1h".
+	// Empty uses the provider default.
+	TTL string `json:"ttl,omitempty"`
 }
 
 // Content encapsulates the payload produced by an LLM.
@@ -30,9 +45,44 @@ type Content struct {
 	Parts []Part `json:"parts,omitempty"`
 }
 
-// Part represents a unit of content. New fields can be added (e.g. tables, images).
+// Part represents a unit of content: text, or an image when Image is set.
 type Part struct {
-	Text string `json:"text,omitempty"`
+	Text  string `json:"text,omitempty"`
+	Image *Image `json:"image,omitempty"`
+}
+
+// Image is image input referenced by URL or carried inline as raw bytes.
+// Exactly one of URL and Data should be set.
+type Image struct {
+	URL       string `json:"url,omitempty"`
+	Data      []byte `json:"data,omitempty"`
+	MediaType string `json:"media_type,omitempty"` // e.g. "image/png"; required with Data
+	Detail    string `json:"detail,omitempty"`     // Optional fidelity hint: "low", "high" or "auto"
+}
+
+// DataURL returns the image as a URL: URL when set, otherwise Data encoded as
+// a base64 data: URL.
+func (img *Image) DataURL() string {
+	if img.URL != "" {
+		return img.URL
+	}
+	return "data:" + img.MediaType + ";base64," + base64.StdEncoding.EncodeToString(img.Data)
+}
+
+// TextPart returns a text content part.
+func TextPart(text string) Part {
+	return Part{Text: text}
+}
+
+// ImageURLPart returns an image part referencing url, which may also be a
+// data: URL.
+func ImageURLPart(url string) Part {
+	return Part{Image: &Image{URL: url}}
+}
+
+// ImageDataPart returns an image part carrying the encoded image bytes.
+func ImageDataPart(mediaType string, data []byte) Part {
+	return Part{Image: &Image{MediaType: mediaType, Data: data}}
 }
 
 // ToolCall represents a tool invocation request
@@ -55,6 +105,13 @@ func NewMessage(role Role, content string) *Message {
 	return msg
 }
 
+// NewMultimodalMessage creates a message from content parts, e.g. text and images.
+func NewMultimodalMessage(role Role, parts ...Part) *Message {
+	msg := NewEmptyMessage(role)
+	msg.Content.Parts = append([]Part(nil), parts...)
+	return msg
+}
+
 // NewEmptyMessage creates a new empty message with the given role
 func NewEmptyMessage(role Role) *Message {
 	msg := &Message{
@@ -75,6 +132,13 @@ func Clone(msg *Message) *Message {
 	if len(msg.Content.Parts) > 0 {
 		cloned.Content.Parts = make([]Part, len(msg.Content.Parts))
 		copy(cloned.Content.Parts, msg.Content.Parts)
+		for i, part := range cloned.Content.Parts {
+			if part.Image != nil {
+				img := *part.Image
+				img.Data = append([]byte(nil), part.Image.Data...)
+				cloned.Content.Parts[i].Image = &img
+			}
+		}
 	}
 	if msg.Metadata != nil {
 		cloned.Metadata = make(map[string]any, len(msg.Metadata))
@@ -82,6 +146,10 @@ func Clone(msg *Message) *Message {
 			cloned.Metadata[k] = v
 		}
 	}
+	if msg.CacheControl != nil {
+		cc := *msg.CacheControl
+		cloned.CacheControl = &cc
+	}
 	if len(msg.ToolCalls) > 0 {
 		cloned.ToolCalls = make([]ToolCall, len(msg.ToolCalls))
 		for i, tc := range msg.ToolCalls {
@@ -144,14 +212,17 @@ func NewToolResponseMessage(toolID, content string) *Message {
 	return msg
 }
 
+// idSeq distinguishes messages created within the same microsecond.
+var idSeq atomic.Uint64
+
 // generateID generates a unique message ID
 func generateID() string {
-	// Simple implementation using timestamp
+	// Simple implementation using timestamp and a process-wide sequence
 	// In production, consider using UUID
-	return time.Now().Format("20060102150405.000000")
+	return time.Now().Format("20060102150405.000000") + "-" + strconv.FormatUint(idSeq.Add(1), 10)
 }
 
-// Text returns the first textual part within the message.
+// Text returns the concatenated text parts of the message; image parts are skipped.
 func (m *Message) Text() string {
 	if m == nil || len(m.Content.Parts) == 0 {
 		return ""
@@ -163,18 +234,29 @@ func (m *Message) Text() string {
 	return msg
 }
 
-// SetText replaces the message content with a single text part.
+// SetText replaces the text of the message with text, keeping image parts in
+// place. The text goes where the first text part was, or before the images if
+// there was none; any other text parts are removed.
 func (m *Message) SetText(text string) {
 	if m == nil {
 		return
 	}
-	if len(m.Content.Parts) == 0 {
-		m.Content.Parts = make([]Part, 1)
+	parts := make([]Part, 0, len(m.Content.Parts)+1)
+	placed := false
+	for _, part := range m.Content.Parts {
+		if part.Image != nil {
+			parts = append(parts, part)
+			continue
+		}
+		if !placed {
+			parts = append(parts, Part{Text: text})
+			placed = true
+		}
 	}
-	m.Content.Parts[0] = Part{Text: text}
-	if len(m.Content.Parts) > 1 {
-		m.Content.Parts = m.Content.Parts[:1]
+	if !placed {
+		parts = append([]Part{{Text: text}}, parts...)
 	}
+	m.Content.Parts = parts
 }
 
 // AppendText appends text to the last part, creating one if needed.
@@ -186,5 +268,23 @@ func (m *Message) AppendText(text string) {
 		m.Content.Parts = []Part{{Text: text}}
 		return
 	}
-	m.Content.Parts[len(m.Content.Parts)-1].Text += text
+	last := &m.Content.Parts[len(m.Content.Parts)-1]
+	if last.Image != nil {
+		m.Content.Parts = append(m.Content.Parts, Part{Text: text})
+		return
+	}
+	last.Text += text
+}
+
+// HasImages reports whether any content part is an image.
+func (m *Message) HasImages() bool {
+	if m == nil {
+		return false
+	}
+	for _, part := range m.Content.Parts {
+		if part.Image != nil {
+			return true
+		}
+	}
+	return false
 }
@@ -59,3 +59,54 @@ func TestNewToolResponseMessage(t *testing.T) {
 		t.Errorf("Expected tool ID 'call1', got '%s'", msg.ToolID)
 	}
 }
+
+func TestNewMultimodalMessage(t *testing.T) {
+	msg := NewMultimodalMessage(RoleUser,
+		TextPart("What is in "),
+		ImageDataPart("image/png", []byte{1, 2, 3}),
+		TextPart("this image?"),
+	)
+
+	if msg.Text() != "What is in this image?" {
+		t.Errorf("Expected text parts only, got '%s'", msg.Text())
+	}
+	if !msg.HasImages() || NewMessage(RoleUser, "hi").HasImages() {
+		t.Error("Expected HasImages to report image parts only")
+	}
+
+	cloned := Clone(msg)
+	cloned.Content.Parts[1].Image.Data[0] = 9
+	if msg.Content.Parts[1].Image.Data[0] != 1 {
+		t.Error("Expected Clone to copy image data")
+	}
+
+	msg = NewMultimodalMessage(RoleUser, ImageURLPart("https://example.com/cat.png"))
+	msg.AppendText("describe it")
+	if len(msg.Content.Parts) != 2 || msg.Content.Parts[0].Image.URL != "https://example.com/cat.png" || msg.Text() != "describe it" {
+		t.Errorf("Expected AppendText to add a text part after the image, got %+v", msg.Content.Parts)
+	}
+}
+
+func TestSetTextKeepsImages(t *testing.T) {
+	msg := NewMultimodalMessage(RoleUser,
+		TextPart("What is in "),
+		ImageURLPart("https://example.com/cat.png"),
+		TextPart("this image?"),
+	)
+	msg.SetText("Describe")
+	if len(msg.Content.Parts) != 2 || msg.Content.Parts[0].Text != "Describe" || msg.Content.Parts[1].Image == nil {
+		t.Errorf("Expected the text parts to be replaced in place of the first one, got %+v", msg.Content.Parts)
+	}
+
+	msg = NewMultimodalMessage(RoleUser, ImageURLPart("https://example.com/cat.png"))
+	msg.SetText("caption")
+	if len(msg.Content.Parts) != 2 || msg.Content.Parts[0].Text != "caption" || !msg.HasImages() {
+		t.Errorf("Expected a text part before the image, got %+v", msg.Content.Parts)
+	}
+
+	msg = NewMessage(RoleUser, "old")
+	msg.SetText("new")
+	if len(msg.Content.Parts) != 1 || msg.Text() != "new" {
+		t.Errorf("Expected a single text part, got %+v", msg.Content.Parts)
+	}
+}
@@ -0,0 +1,88 @@
+// Package cache provides a middleware that answers repeated prompts from a cache
+// instead of calling the LLM again.
+package cache
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"time"
+
+	"github.com/sweetpotato0/ai-allin/message"
+	"github.com/sweetpotato0/ai-allin/middleware"
+)
+
+// MetadataHit is the middleware.Context metadata key set to true when the
+// response was served from the cache.
+const MetadataHit = "cache_hit"
+
+// ResponseCache short-circuits the chain with a stored response when the same
+// input arrives under the same system prompt. Responses served from the cache are
+// not added to the agent conversation, so it suits stateless, single-turn agents.
+// Store errors are treated as misses and never fail the request.
+type ResponseCache struct {
+	store Store
+	ttl   time.Duration
+}
+
+// Option customises a ResponseCache.
+type Option func(*ResponseCache)
+
+// WithStore replaces the default in-memory store, e.g. with a Redis-backed one.
+func WithStore(store Store) Option {
+	return func(c *ResponseCache) {
+		if store != nil {
+			c.store = store
+		}
+	}
+}
+
+// New creates a caching middleware keeping responses for ttl. A non-positive
+// ttl keeps them until the store evicts them.
+func New(ttl time.Duration, opts ...Option) *ResponseCache {
+	c := &ResponseCache{store: NewMemoryStore(), ttl: ttl}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// Name returns the middleware name
+func (m *ResponseCache) Name() string {
+	return "ResponseCache"
+}
+
+// Execute serves a cached response or runs the chain and caches its answer.
+// Responses that still request tool calls are not cached.
+func (m *ResponseCache) Execute(ctx *middleware.Context, next middleware.Handler) error {
+	key := Key(ctx)
+	if cached, ok, err := m.store.Get(ctx.Context(), key); err == nil && ok {
+		ctx.Response = cached
+		if ctx.Metadata == nil {
+			ctx.Metadata = make(map[string]any)
+		}
+		ctx.Metadata[MetadataHit] = true
+		return nil
+	}
+
+	if err := next(ctx); err != nil {
+		return err
+	}
+	if ctx.Response != nil && ctx.Error == nil && len(ctx.Response.ToolCalls) == 0 {
+		_ = m.store.Set(ctx.Context(), key, ctx.Response, m.ttl)
+	}
+	return nil
+}
+
+// Key hashes the system messages and input of ctx into a cache key.
+func Key(ctx *middleware.Context) string {
+	h := sha256.New()
+	for _, msg := range ctx.Messages {
+		if msg != nil && msg.Role == message.RoleSystem {
+			h.Write([]byte(msg.Text()))
+			h.Write([]byte{0})
+		}
+	}
+	h.Write([]byte{0})
+	h.Write([]byte(ctx.Input))
+	return hex.EncodeToString(h.Sum(nil))
+}
@@ -0,0 +1,108 @@
+package cache
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/sweetpotato0/ai-allin/agent"
+	"github.com/sweetpotato0/ai-allin/message"
+	"github.com/sweetpotato0/ai-allin/middleware"
+)
+
+// countingLLM answers every call with the same reply and counts the calls.
+type countingLLM struct {
+	calls int
+}
+
+func (c *countingLLM) Generate(ctx context.Context, req *agent.GenerateRequest) (*agent.GenerateResponse, error) {
+	c.calls++
+	return &agent.GenerateResponse{Message: message.NewMessage(message.RoleAssistant, "cached answer")}, nil
+}
+
+func (c *countingLLM) SetTemperature(float64) {}
+func (c *countingLLM) SetMaxTokens(int64)     {}
+func (c *countingLLM) SetModel(string)        {}
+
+func TestResponseCacheShortCircuitsRepeatedInput(t *testing.T) {
+	llm := &countingLLM{}
+	ag := agent.New(agent.WithProvider(llm), agent.WithMiddleware(New(time.Minute)))
+
+	for i := 0; i < 3; i++ {
+		resp, err := ag.Run(context.Background(), "what is 2+2?")
+		if err != nil {
+			t.Fatalf("Run %d failed: %v", i+1, err)
+		}
+		if resp.Text() != "cached answer" {
+			t.Fatalf("unexpected response %q", resp.Text())
+		}
+	}
+	if llm.calls != 1 {
+		t.Fatalf("expected a single LLM call, got %d", llm.calls)
+	}
+
+	if _, err := ag.Run(context.Background(), "what is 3+3?"); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	if llm.calls != 2 {
+		t.Fatalf("expected a different input to miss the cache, got %d calls", llm.calls)
+	}
+}
+
+func TestResponseCacheKeyIncludesSystemPrompt(t *testing.T) {
+	withSystem := func(prompt string) *middleware.Context {
+		ctx := middleware.NewContext(context.Background())
+		ctx.Input = "hello"
+		ctx.Messages = []*message.Message{
+			message.NewMessage(message.RoleSystem, prompt),
+			message.NewMessage(message.RoleUser, "earlier turn"),
+		}
+		return ctx
+	}
+	if Key(withSystem("be terse")) == Key(withSystem("be verbose")) {
+		t.Fatal("expected different system prompts to produce different keys")
+	}
+
+	store := NewMemoryStore()
+	mw := New(time.Minute, WithStore(store))
+	calls := 0
+	handler := func(ctx *middleware.Context) error {
+		calls++
+		ctx.Response = message.NewMessage(message.RoleAssistant, "hi")
+		return nil
+	}
+	for _, prompt := range []string{"be terse", "be verbose", "be terse"} {
+		ctx := withSystem(prompt)
+		if err := mw.Execute(ctx, handler); err != nil {
+			t.Fatalf("Execute failed: %v", err)
+		}
+		if hit, _ := ctx.Metadata[MetadataHit].(bool); hit != (calls == 2 && prompt == "be terse") {
+			t.Fatalf("unexpected cache hit flag %v for %q", hit, prompt)
+		}
+	}
+	if calls != 2 || store.Len() != 2 {
+		t.Fatalf("expected 2 handler calls and 2 entries, got %d and %d", calls, store.Len())
+	}
+}
+
+func TestMemoryStoreExpiresEntries(t *testing.T) {
+	store := NewMemoryStore()
+	ctx := context.Background()
+	if err := store.Set(ctx, "k", message.NewMessage(message.RoleAssistant, "v"), time.Millisecond); err != nil {
+		t.Fatalf("Set failed: %v", err)
+	}
+	time.Sleep(5 * time.Millisecond)
+	if _, ok, _ := store.Get(ctx, "k"); ok {
+		t.Fatal("expected entry to expire")
+	}
+
+	_ = store.Set(ctx, "forever", message.NewMessage(message.RoleAssistant, "v"), 0)
+	got, ok, _ := store.Get(ctx, "forever")
+	if !ok || got.Text() != "v" {
+		t.Fatalf("expected entry without ttl to persist, got %v", got)
+	}
+	got.SetText("mutated")
+	if again, _, _ := store.Get(ctx, "forever"); again.Text() != "v" {
+		t.Fatal("expected stored message to be isolated from callers")
+	}
+}
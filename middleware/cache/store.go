@@ -0,0 +1,88 @@
+package cache
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/sweetpotato0/ai-allin/message"
+)
+
+// Store persists cached responses. Implementations must be safe for concurrent
+// use. A Redis implementation maps Set to SET with an expiry and Get to GET.
+type Store interface {
+	// Get returns the response stored under key, or false when there is none
+	// or it has expired.
+	Get(ctx context.Context, key string) (*message.Message, bool, error)
+	// Set stores msg under key for ttl. A non-positive ttl keeps it indefinitely.
+	Set(ctx context.Context, key string, msg *message.Message, ttl time.Duration) error
+}
+
+var _ Store = (*MemoryStore)(nil)
+
+// MemoryStore is an in-process Store. Expired entries are removed lazily.
+type MemoryStore struct {
+	mu        sync.Mutex
+	entries   map[string]memoryEntry
+	lastSweep time.Time
+}
+
+type memoryEntry struct {
+	msg       *message.Message
+	expiresAt time.Time // Zero means no expiry
+}
+
+// sweepInterval bounds how often Set scans for expired entries.
+const sweepInterval = time.Minute
+
+// NewMemoryStore creates an empty in-memory store.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{entries: make(map[string]memoryEntry)}
+}
+
+// Get implements Store.
+func (s *MemoryStore) Get(ctx context.Context, key string) (*message.Message, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	entry, ok := s.entries[key]
+	if !ok {
+		return nil, false, nil
+	}
+	if entry.expired(time.Now()) {
+		delete(s.entries, key)
+		return nil, false, nil
+	}
+	return message.Clone(entry.msg), true, nil
+}
+
+// Set implements Store.
+func (s *MemoryStore) Set(ctx context.Context, key string, msg *message.Message, ttl time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	if now.Sub(s.lastSweep) >= sweepInterval {
+		s.lastSweep = now
+		for k, entry := range s.entries {
+			if entry.expired(now) {
+				delete(s.entries, k)
+			}
+		}
+	}
+	entry := memoryEntry{msg: message.Clone(msg)}
+	if ttl > 0 {
+		entry.expiresAt = now.Add(ttl)
+	}
+	s.entries[key] = entry
+	return nil
+}
+
+// Len returns the number of stored entries, including expired ones not yet removed.
+func (s *MemoryStore) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.entries)
+}
+
+func (e memoryEntry) expired(now time.Time) bool {
+	return !e.expiresAt.IsZero() && !now.Before(e.expiresAt)
+}
@@ -0,0 +1,115 @@
+// Package jsoncontract provides a middleware that makes an agent answer in JSON.
+// It appends a format instruction to the input, checks that the response parses
+// as JSON and re-runs the rest of the chain with a correction prompt when it does not.
+package jsoncontract
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/sweetpotato0/ai-allin/middleware"
+	"github.com/sweetpotato0/ai-allin/parser"
+)
+
+// MetadataKey is the middleware.Context metadata key holding the decoded JSON value.
+const MetadataKey = "json_contract"
+
+// ErrContractViolation is returned when the response is still not valid JSON
+// after all retries.
+var ErrContractViolation = errors.New("response violates JSON contract")
+
+// JSONContract enforces JSON responses.
+type JSONContract struct {
+	schema     map[string]any
+	maxRetries int
+}
+
+// Option customises a JSONContract.
+type Option func(*JSONContract)
+
+// WithSchema sets a JSON Schema that is shown to the model. Responses are checked
+// against its top-level "type" and "required" keywords; nested constraints are
+// only communicated, not enforced.
+func WithSchema(schema map[string]any) Option {
+	return func(c *JSONContract) {
+		c.schema = schema
+	}
+}
+
+// WithMaxRetries sets how many correction prompts are sent after an invalid
+// response (default 1). Zero disables re-running.
+func WithMaxRetries(n int) Option {
+	return func(c *JSONContract) {
+		if n >= 0 {
+			c.maxRetries = n
+		}
+	}
+}
+
+// New creates a JSON contract middleware.
+func New(opts ...Option) *JSONContract {
+	c := &JSONContract{maxRetries: 1}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// Name returns the middleware name
+func (m *JSONContract) Name() string {
+	return "JSONContract"
+}
+
+// Execute injects the format instruction and validates the response.
+func (m *JSONContract) Execute(ctx *middleware.Context, next middleware.Handler) error {
+	instructions := m.instructions()
+	ctx.Input = ctx.Input + "\n\n" + instructions
+
+	for attempt := 0; ; attempt++ {
+		if err := next(ctx); err != nil {
+			return err
+		}
+		if ctx.Response == nil {
+			return nil
+		}
+		value, err := m.validate(ctx.Response.Text())
+		if err == nil {
+			if ctx.Metadata == nil {
+				ctx.Metadata = make(map[string]any)
+			}
+			ctx.Metadata[MetadataKey] = value
+			return nil
+		}
+		if attempt >= m.maxRetries {
+			return fmt.Errorf("%w: %v", ErrContractViolation, err)
+		}
+		ctx.Input = fmt.Sprintf("Your previous answer was not valid JSON: %v\nAnswer again with JSON only.\n\n%s", err, instructions)
+		ctx.Response = nil
+		ctx.Error = nil
+	}
+}
+
+// instructions describes the expected output to the model.
+func (m *JSONContract) instructions() string {
+	if len(m.schema) == 0 {
+		return "Respond with a single valid JSON value and nothing else."
+	}
+	schema, err := json.Marshal(m.schema)
+	if err != nil {
+		return "Respond with a single valid JSON value and nothing else."
+	}
+	return fmt.Sprintf("Respond with a single valid JSON value matching this JSON Schema and nothing else:\n%s", schema)
+}
+
+// validate decodes text and checks it against the schema's top-level keywords.
+func (m *JSONContract) validate(text string) (any, error) {
+	value, err := parser.NewJSONParser().Parse(text)
+	if err != nil {
+		return nil, err
+	}
+	if err := parser.ValidateSchema(value, m.schema); err != nil {
+		return nil, err
+	}
+	return value, nil
+}
@@ -0,0 +1,119 @@
+package jsoncontract
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/sweetpotato0/ai-allin/agent"
+	"github.com/sweetpotato0/ai-allin/message"
+	"github.com/sweetpotato0/ai-allin/middleware"
+)
+
+// replyHandler answers each call with the next reply and records the inputs it saw.
+func replyHandler(inputs *[]string, replies ...string) middleware.Handler {
+	return func(ctx *middleware.Context) error {
+		*inputs = append(*inputs, ctx.Input)
+		reply := replies[0]
+		replies = replies[1:]
+		ctx.Response = message.NewMessage(message.RoleAssistant, reply)
+		return nil
+	}
+}
+
+func TestJSONContractInjectsInstructions(t *testing.T) {
+	var inputs []string
+	ctx := middleware.NewContext(context.Background())
+	ctx.Input = "list the colours"
+
+	err := New().Execute(ctx, replyHandler(&inputs, "```json\n[\"red\", \"blue\"]\n```"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(inputs) != 1 || !strings.HasPrefix(inputs[0], "list the colours\n\n") || !strings.Contains(inputs[0], "JSON") {
+		t.Fatalf("expected format instructions appended to input, got %q", inputs)
+	}
+	value, ok := ctx.Metadata[MetadataKey].([]any)
+	if !ok || len(value) != 2 {
+		t.Fatalf("expected decoded value in metadata, got %#v", ctx.Metadata[MetadataKey])
+	}
+}
+
+func TestJSONContractRetriesWithCorrection(t *testing.T) {
+	var inputs []string
+	ctx := middleware.NewContext(context.Background())
+	ctx.Input = "describe the user"
+	contract := New(WithSchema(map[string]any{
+		"type":     "object",
+		"required": []any{"name"},
+	}))
+
+	err := contract.Execute(ctx, replyHandler(&inputs, `{"age": 3}`, `{"name": "Ada"}`))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(inputs) != 2 {
+		t.Fatalf("expected one retry, got %d calls", len(inputs))
+	}
+	if !strings.Contains(inputs[0], `"required":["name"]`) {
+		t.Fatalf("expected schema in instructions, got %q", inputs[0])
+	}
+	if !strings.Contains(inputs[1], `missing required field "name"`) {
+		t.Fatalf("expected correction prompt to explain the error, got %q", inputs[1])
+	}
+	if obj := ctx.Metadata[MetadataKey].(map[string]any); obj["name"] != "Ada" {
+		t.Fatalf("unexpected decoded value %#v", obj)
+	}
+}
+
+func TestJSONContractFailsAfterMaxRetries(t *testing.T) {
+	var inputs []string
+	ctx := middleware.NewContext(context.Background())
+
+	err := New(WithMaxRetries(2)).Execute(ctx, replyHandler(&inputs, "nope", "still no", "never"))
+	if !errors.Is(err, ErrContractViolation) {
+		t.Fatalf("expected ErrContractViolation, got %v", err)
+	}
+	if len(inputs) != 3 {
+		t.Fatalf("expected 3 attempts, got %d", len(inputs))
+	}
+
+	inputs = nil
+	err = New(WithSchema(map[string]any{"type": "object"}), WithMaxRetries(0)).Execute(ctx, replyHandler(&inputs, "[1]"))
+	if !errors.Is(err, ErrContractViolation) || len(inputs) != 1 {
+		t.Fatalf("expected immediate type violation, got %v after %d calls", err, len(inputs))
+	}
+}
+
+type queuedLLM struct {
+	replies []string
+	seen    []string
+}
+
+func (q *queuedLLM) Generate(ctx context.Context, req *agent.GenerateRequest) (*agent.GenerateResponse, error) {
+	q.seen = append(q.seen, req.Messages[len(req.Messages)-1].Text())
+	reply := q.replies[0]
+	q.replies = q.replies[1:]
+	return &agent.GenerateResponse{Message: message.NewMessage(message.RoleAssistant, reply)}, nil
+}
+
+func (q *queuedLLM) SetTemperature(float64) {}
+func (q *queuedLLM) SetMaxTokens(int64)     {}
+func (q *queuedLLM) SetModel(string)        {}
+
+func TestJSONContractWithAgent(t *testing.T) {
+	llm := &queuedLLM{replies: []string{"Sure! Here it is.", `{"ok": true}`}}
+	ag := agent.New(agent.WithProvider(llm), agent.WithMiddleware(New()))
+
+	resp, err := ag.Run(context.Background(), "status?")
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	if resp.Text() != `{"ok": true}` {
+		t.Fatalf("unexpected response %q", resp.Text())
+	}
+	if len(llm.seen) != 2 || !strings.HasPrefix(llm.seen[0], "status?\n\n") || !strings.Contains(llm.seen[1], "not valid JSON") {
+		t.Fatalf("expected instructed prompt then correction, got %q", llm.seen)
+	}
+}
@@ -0,0 +1,103 @@
+package limiter
+
+import (
+	"sync"
+	"time"
+
+	"github.com/sweetpotato0/ai-allin/middleware"
+)
+
+// KeyedLimiter middleware applies an independent sliding-window limit per key,
+// for example per user. Requests whose key is empty share one default bucket.
+// Buckets without admissions in the last window are evicted, so memory stays
+// proportional to the number of recently active keys.
+type KeyedLimiter struct {
+	cfg    windowConfig
+	max    int
+	window time.Duration
+	keyFn  func(*middleware.Context) string
+
+	mu        sync.Mutex
+	buckets   map[string]*slidingWindow
+	lastSweep time.Time
+}
+
+// NewKeyedLimiter creates a middleware allowing max requests per window for each
+// key returned by keyFn. A nil keyFn puts every request in the default bucket.
+func NewKeyedLimiter(max int, window time.Duration, keyFn func(*middleware.Context) string, opts ...WindowOption) *KeyedLimiter {
+	return &KeyedLimiter{
+		cfg:     newWindowConfig(opts),
+		max:     max,
+		window:  window,
+		keyFn:   keyFn,
+		buckets: make(map[string]*slidingWindow),
+	}
+}
+
+// MetadataKey returns a key function reading a string value from the middleware
+// context metadata, such as the "user_id" set by an enricher.
+func MetadataKey(name string) func(*middleware.Context) string {
+	return func(ctx *middleware.Context) string {
+		value, _ := ctx.Metadata[name].(string)
+		return value
+	}
+}
+
+// Name returns the middleware name
+func (l *KeyedLimiter) Name() string {
+	return "KeyedLimiter"
+}
+
+// Execute admits the request when the bucket for its key has room and calls next.
+func (l *KeyedLimiter) Execute(ctx *middleware.Context, next middleware.Handler) error {
+	var key string
+	if l.keyFn != nil {
+		key = l.keyFn(ctx)
+	}
+	err := l.cfg.wait(ctx, func(now time.Time) (time.Duration, bool) {
+		l.mu.Lock()
+		defer l.mu.Unlock()
+		l.sweep(now)
+		bucket, ok := l.buckets[key]
+		if !ok {
+			bucket = &slidingWindow{max: l.max, window: l.window}
+			l.buckets[key] = bucket
+		}
+		return bucket.reserve(now)
+	})
+	if err != nil {
+		return err
+	}
+	return next(ctx)
+}
+
+// Available returns how many requests with key would be admitted right now.
+func (l *KeyedLimiter) Available(key string) int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	bucket, ok := l.buckets[key]
+	if !ok {
+		return max(l.max, 0)
+	}
+	return bucket.available(l.cfg.now())
+}
+
+// Len returns the number of buckets currently tracked.
+func (l *KeyedLimiter) Len() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return len(l.buckets)
+}
+
+// sweep evicts idle buckets, at most once per window.
+func (l *KeyedLimiter) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < l.window {
+		return
+	}
+	l.lastSweep = now
+	for key, bucket := range l.buckets {
+		if bucket.idle(now) {
+			delete(l.buckets, key)
+		}
+	}
+}
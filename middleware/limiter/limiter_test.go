@@ -1,7 +1,12 @@
 package limiter
 
 import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/sweetpotato0/ai-allin/middleware"
 )
@@ -71,3 +76,115 @@ func TestRateLimiter(t *testing.T) {
 		}
 	})
 }
+
+func TestWindowLimiter(t *testing.T) {
+	pass := func(c *middleware.Context) error { return nil }
+
+	t.Run("rejects requests over the window rate", func(t *testing.T) {
+		now := time.Unix(0, 0)
+		limiter := NewWindowLimiter(2, time.Minute)
+		limiter.cfg.now = func() time.Time { return now }
+		ctx := &middleware.Context{}
+
+		for i := 0; i < 2; i++ {
+			if err := limiter.Execute(ctx, pass); err != nil {
+				t.Fatalf("request %d failed: %v", i+1, err)
+			}
+		}
+		if err := limiter.Execute(ctx, pass); !errors.Is(err, ErrRateLimitExceeded) {
+			t.Fatalf("expected ErrRateLimitExceeded, got %v", err)
+		}
+		if limiter.Available() != 0 {
+			t.Errorf("expected no available slots, got %d", limiter.Available())
+		}
+
+		now = now.Add(time.Minute)
+		if limiter.Available() != 2 {
+			t.Errorf("expected slots to free after the window, got %d", limiter.Available())
+		}
+		if err := limiter.Execute(ctx, pass); err != nil {
+			t.Errorf("request after the window failed: %v", err)
+		}
+	})
+
+	t.Run("blocks until a slot frees", func(t *testing.T) {
+		limiter := NewWindowLimiter(1, 20*time.Millisecond, WithBlocking())
+		ctx := middleware.NewContext(context.Background())
+
+		start := time.Now()
+		for i := 0; i < 2; i++ {
+			if err := limiter.Execute(ctx, pass); err != nil {
+				t.Fatalf("request %d failed: %v", i+1, err)
+			}
+		}
+		if elapsed := time.Since(start); elapsed < 20*time.Millisecond {
+			t.Errorf("expected second request to wait for the window, took %v", elapsed)
+		}
+
+		cancelled, cancel := context.WithCancel(context.Background())
+		cancel()
+		if err := limiter.Execute(middleware.NewContext(cancelled), pass); !errors.Is(err, context.Canceled) {
+			t.Errorf("expected context error while waiting, got %v", err)
+		}
+	})
+
+	t.Run("is safe for concurrent use", func(t *testing.T) {
+		limiter := NewWindowLimiter(5, time.Hour)
+		ctx := &middleware.Context{}
+		var (
+			wg       sync.WaitGroup
+			admitted atomic.Int32
+		)
+		for i := 0; i < 20; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				if limiter.Execute(ctx, pass) == nil {
+					admitted.Add(1)
+				}
+			}()
+		}
+		wg.Wait()
+		if admitted.Load() != 5 {
+			t.Errorf("expected exactly 5 admitted requests, got %d", admitted.Load())
+		}
+	})
+}
+
+func TestKeyedLimiter(t *testing.T) {
+	pass := func(c *middleware.Context) error { return nil }
+	withUser := func(user string) *middleware.Context {
+		ctx := middleware.NewContext(context.Background())
+		if user != "" {
+			ctx.Metadata["user_id"] = user
+		}
+		return ctx
+	}
+
+	now := time.Unix(0, 0)
+	limiter := NewKeyedLimiter(1, time.Minute, MetadataKey("user_id"))
+	limiter.cfg.now = func() time.Time { return now }
+
+	for _, user := range []string{"alice", "bob", ""} {
+		if err := limiter.Execute(withUser(user), pass); err != nil {
+			t.Fatalf("first request for %q failed: %v", user, err)
+		}
+	}
+	if err := limiter.Execute(withUser("alice"), pass); !errors.Is(err, ErrRateLimitExceeded) {
+		t.Fatalf("expected alice to be limited, got %v", err)
+	}
+	if err := limiter.Execute(withUser(""), pass); !errors.Is(err, ErrRateLimitExceeded) {
+		t.Fatalf("expected anonymous requests to share the default bucket, got %v", err)
+	}
+	if limiter.Available("alice") != 0 || limiter.Available("carol") != 1 {
+		t.Fatalf("unexpected availability alice=%d carol=%d", limiter.Available("alice"), limiter.Available("carol"))
+	}
+
+	now = now.Add(2 * time.Minute)
+	if err := limiter.Execute(withUser("carol"), pass); err != nil {
+		t.Fatalf("request after the window failed: %v", err)
+	}
+	if limiter.Len() != 1 {
+		t.Fatalf("expected idle buckets to be evicted, got %d buckets", limiter.Len())
+	}
+}
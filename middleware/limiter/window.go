@@ -0,0 +1,147 @@
+package limiter
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/sweetpotato0/ai-allin/middleware"
+)
+
+// WindowLimiter middleware admits at most max requests in any sliding window of
+// the configured duration. Excess requests are rejected with ErrRateLimitExceeded
+// or, with WithBlocking, wait until a slot frees up.
+type WindowLimiter struct {
+	cfg windowConfig
+
+	mu     sync.Mutex
+	bucket slidingWindow
+}
+
+// WindowOption customises a WindowLimiter or KeyedLimiter.
+type WindowOption func(*windowConfig)
+
+type windowConfig struct {
+	blocking bool
+	now      func() time.Time
+}
+
+// WithBlocking makes requests over the limit wait for a free slot instead of
+// failing. Waiting stops with the context error when the request context ends.
+func WithBlocking() WindowOption {
+	return func(c *windowConfig) {
+		c.blocking = true
+	}
+}
+
+func newWindowConfig(opts []WindowOption) windowConfig {
+	cfg := windowConfig{now: time.Now}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	return cfg
+}
+
+// NewWindowLimiter creates a middleware allowing max requests per window, e.g.
+// NewWindowLimiter(10, time.Minute) for ten requests a minute.
+func NewWindowLimiter(max int, window time.Duration, opts ...WindowOption) *WindowLimiter {
+	return &WindowLimiter{
+		cfg:    newWindowConfig(opts),
+		bucket: slidingWindow{max: max, window: window},
+	}
+}
+
+// Name returns the middleware name
+func (l *WindowLimiter) Name() string {
+	return "WindowLimiter"
+}
+
+// Execute admits the request when the window has room and calls next.
+func (l *WindowLimiter) Execute(ctx *middleware.Context, next middleware.Handler) error {
+	err := l.cfg.wait(ctx, func(now time.Time) (time.Duration, bool) {
+		l.mu.Lock()
+		defer l.mu.Unlock()
+		return l.bucket.reserve(now)
+	})
+	if err != nil {
+		return err
+	}
+	return next(ctx)
+}
+
+// Available returns how many requests would be admitted right now.
+func (l *WindowLimiter) Available() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.bucket.available(l.cfg.now())
+}
+
+// wait calls reserve until it admits the request. Without blocking, or when no
+// slot can ever free up, a refused request fails with ErrRateLimitExceeded.
+func (c windowConfig) wait(ctx *middleware.Context, reserve func(now time.Time) (time.Duration, bool)) error {
+	reqCtx := ctx.Context()
+	if reqCtx == nil {
+		reqCtx = context.Background()
+	}
+	for {
+		delay, ok := reserve(c.now())
+		if ok {
+			return nil
+		}
+		if !c.blocking || delay <= 0 {
+			return ErrRateLimitExceeded
+		}
+		timer := time.NewTimer(delay)
+		select {
+		case <-reqCtx.Done():
+			timer.Stop()
+			return reqCtx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// slidingWindow records admission times to enforce max requests per window.
+// It is not safe for concurrent use; limiters guard it with their mutex.
+type slidingWindow struct {
+	max      int
+	window   time.Duration
+	admitted []time.Time // Admission times within the current window, oldest first
+}
+
+// reserve records an admission at now when the window has room. Otherwise it
+// returns how long until the oldest admission leaves the window.
+func (w *slidingWindow) reserve(now time.Time) (time.Duration, bool) {
+	w.expire(now)
+	if len(w.admitted) < w.max {
+		w.admitted = append(w.admitted, now)
+		return 0, true
+	}
+	if len(w.admitted) == 0 {
+		return 0, false
+	}
+	return w.admitted[0].Add(w.window).Sub(now), false
+}
+
+// available returns how many admissions the window has room for at now.
+func (w *slidingWindow) available(now time.Time) int {
+	w.expire(now)
+	return max(w.max-len(w.admitted), 0)
+}
+
+// expire drops admissions that have left the window ending at now.
+func (w *slidingWindow) expire(now time.Time) {
+	cutoff := now.Add(-w.window)
+	i := 0
+	for i < len(w.admitted) && !w.admitted[i].After(cutoff) {
+		i++
+	}
+	w.admitted = w.admitted[i:]
+}
+
+// idle reports whether the window holds no admissions at now, in which case it
+// behaves exactly like a fresh one.
+func (w *slidingWindow) idle(now time.Time) bool {
+	w.expire(now)
+	return len(w.admitted) == 0
+}
@@ -0,0 +1,270 @@
+// Package parser extracts structured data from free-form model answers.
+package parser
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// ErrNoMatch is returned when the text contains nothing the parser can extract.
+var ErrNoMatch = errors.New("no parsable output found")
+
+// OutputParser turns a model answer into structured data.
+type OutputParser interface {
+	// Parse extracts the structured value from text.
+	Parse(text string) (any, error)
+	// FormatInstructions describes the expected answer format to the model.
+	// An empty string means no instructions are added to the prompt.
+	FormatInstructions() string
+}
+
+var (
+	_ OutputParser = (*JSONParser)(nil)
+	_ OutputParser = (*CodeBlockParser)(nil)
+	_ OutputParser = (*ListParser)(nil)
+	_ OutputParser = (*RegexParser)(nil)
+)
+
+var codeBlockPattern = regexp.MustCompile("(?s)```([\\w+-]*)[^\\n]*\\n(.*?)```")
+
+// JSONParser decodes a JSON value from the answer. Fenced code blocks are
+// unwrapped and surrounding prose is ignored.
+type JSONParser struct {
+	newTarget func() any
+}
+
+// NewJSONParser returns a parser producing generic JSON values (maps, slices, strings, ...).
+func NewJSONParser() *JSONParser {
+	return &JSONParser{}
+}
+
+// NewJSONParserFor returns a parser that decodes into a fresh value from newTarget,
+// which must return a pointer. The pointer is returned by Parse.
+func NewJSONParserFor(newTarget func() any) *JSONParser {
+	return &JSONParser{newTarget: newTarget}
+}
+
+// Parse implements OutputParser.
+func (p *JSONParser) Parse(text string) (any, error) {
+	raw := extractJSON(text)
+	if raw == "" {
+		return nil, fmt.Errorf("json: %w", ErrNoMatch)
+	}
+	if p.newTarget != nil {
+		target := p.newTarget()
+		if err := json.Unmarshal([]byte(raw), target); err != nil {
+			return nil, fmt.Errorf("json: %w", err)
+		}
+		return target, nil
+	}
+	var value any
+	if err := json.Unmarshal([]byte(raw), &value); err != nil {
+		return nil, fmt.Errorf("json: %w", err)
+	}
+	return value, nil
+}
+
+// FormatInstructions implements OutputParser.
+func (p *JSONParser) FormatInstructions() string {
+	return "Respond with a single valid JSON value and nothing else."
+}
+
+// extractJSON returns the JSON candidate in text: the first json code block, or
+// the span from the first opening brace or bracket to the matching last one.
+func extractJSON(text string) string {
+	for _, m := range codeBlockPattern.FindAllStringSubmatch(text, -1) {
+		if m[1] == "" || strings.EqualFold(m[1], "json") {
+			text = m[2]
+			break
+		}
+	}
+	text = strings.TrimSpace(text)
+	start := strings.IndexAny(text, "{[")
+	if start < 0 {
+		return ""
+	}
+	closing := "}"
+	if text[start] == '[' {
+		closing = "]"
+	}
+	end := strings.LastIndex(text, closing)
+	if end < start {
+		return ""
+	}
+	return text[start : end+1]
+}
+
+// CodeBlockParser returns the contents of the first fenced markdown code block.
+type CodeBlockParser struct {
+	language string
+}
+
+// NewCodeBlockParser returns a parser for code blocks tagged with language.
+// An empty language accepts the first block regardless of its tag.
+func NewCodeBlockParser(language string) *CodeBlockParser {
+	return &CodeBlockParser{language: language}
+}
+
+// Parse implements OutputParser. The result is a string.
+func (p *CodeBlockParser) Parse(text string) (any, error) {
+	for _, m := range codeBlockPattern.FindAllStringSubmatch(text, -1) {
+		if p.language == "" || strings.EqualFold(m[1], p.language) {
+			return strings.TrimRight(m[2], "\n"), nil
+		}
+	}
+	if p.language != "" {
+		return nil, fmt.Errorf("code block (%s): %w", p.language, ErrNoMatch)
+	}
+	return nil, fmt.Errorf("code block: %w", ErrNoMatch)
+}
+
+// FormatInstructions implements OutputParser.
+func (p *CodeBlockParser) FormatInstructions() string {
+	if p.language == "" {
+		return "Put your answer in a fenced markdown code block."
+	}
+	return fmt.Sprintf("Put your answer in a fenced markdown code block tagged %q.", p.language)
+}
+
+var listItemPattern = regexp.MustCompile(`^\s*(?:[-*+•]|\d+[.)])\s+(.*\S)\s*$`)
+
+// ListParser collects bullet and numbered list items.
+type ListParser struct{}
+
+// NewListParser returns a parser for markdown bullet and numbered lists.
+func NewListParser() *ListParser {
+	return &ListParser{}
+}
+
+// Parse implements OutputParser. The result is a []string with one entry per item.
+func (p *ListParser) Parse(text string) (any, error) {
+	var items []string
+	for _, line := range strings.Split(text, "\n") {
+		if m := listItemPattern.FindStringSubmatch(line); m != nil {
+			items = append(items, m[1])
+		}
+	}
+	if len(items) == 0 {
+		return nil, fmt.Errorf("list: %w", ErrNoMatch)
+	}
+	return items, nil
+}
+
+// FormatInstructions implements OutputParser.
+func (p *ListParser) FormatInstructions() string {
+	return "Respond with a bullet list, one item per line starting with \"- \"."
+}
+
+// RegexParser extracts the named capture groups of every match of a pattern,
+// which makes it suitable for key-value answers such as "name: value" lines.
+type RegexParser struct {
+	re           *regexp.Regexp
+	instructions string
+}
+
+// NewRegexParser compiles pattern; it must contain at least one named group.
+// instructions are sent to the model as the expected format.
+func NewRegexParser(pattern, instructions string) (*RegexParser, error) {
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("invalid pattern: %w", err)
+	}
+	named := false
+	for _, name := range re.SubexpNames() {
+		if name != "" {
+			named = true
+			break
+		}
+	}
+	if !named {
+		return nil, fmt.Errorf("pattern %q has no named groups", pattern)
+	}
+	return &RegexParser{re: re, instructions: instructions}, nil
+}
+
+// Parse implements OutputParser. The result is a []map[string]string holding the
+// named groups of each match in order.
+func (p *RegexParser) Parse(text string) (any, error) {
+	matches := p.re.FindAllStringSubmatch(text, -1)
+	if len(matches) == 0 {
+		return nil, fmt.Errorf("regex: %w", ErrNoMatch)
+	}
+	names := p.re.SubexpNames()
+	results := make([]map[string]string, 0, len(matches))
+	for _, m := range matches {
+		groups := make(map[string]string)
+		for i, name := range names {
+			if name != "" {
+				groups[name] = m[i]
+			}
+		}
+		results = append(results, groups)
+	}
+	return results, nil
+}
+
+// FormatInstructions implements OutputParser.
+func (p *RegexParser) FormatInstructions() string {
+	return p.instructions
+}
+
+// ValidateSchema checks value, as decoded by JSONParser, against the top-level
+// "type" and "required" keywords of a JSON Schema. Nested constraints are not
+// checked. A nil schema accepts any value.
+func ValidateSchema(value any, schema map[string]any) error {
+	if want, ok := schema["type"].(string); ok {
+		if got := jsonType(value); got != want && !(want == "number" && got == "integer") {
+			return fmt.Errorf("expected a JSON %s, got %s", want, got)
+		}
+	}
+	obj, isObject := value.(map[string]any)
+	if !isObject {
+		return nil
+	}
+	for _, key := range requiredKeys(schema["required"]) {
+		if _, ok := obj[key]; !ok {
+			return fmt.Errorf("missing required field %q", key)
+		}
+	}
+	return nil
+}
+
+func jsonType(value any) string {
+	switch v := value.(type) {
+	case map[string]any:
+		return "object"
+	case []any:
+		return "array"
+	case string:
+		return "string"
+	case bool:
+		return "boolean"
+	case float64:
+		if v == float64(int64(v)) {
+			return "integer"
+		}
+		return "number"
+	default:
+		return "null"
+	}
+}
+
+func requiredKeys(raw any) []string {
+	switch v := raw.(type) {
+	case []string:
+		return v
+	case []any:
+		keys := make([]string, 0, len(v))
+		for _, item := range v {
+			if key, ok := item.(string); ok {
+				keys = append(keys, key)
+			}
+		}
+		return keys
+	default:
+		return nil
+	}
+}
@@ -0,0 +1,92 @@
+package parser
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestJSONParser(t *testing.T) {
+	text := "Here you go:\n```json\n{\"name\": \"go\", \"tags\": [\"fast\"]}\n```\nAnything else?"
+	value, err := NewJSONParser().Parse(text)
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	obj, ok := value.(map[string]any)
+	if !ok || obj["name"] != "go" {
+		t.Fatalf("unexpected value %v", value)
+	}
+
+	type lang struct {
+		Name string `json:"name"`
+	}
+	typed, err := NewJSONParserFor(func() any { return &lang{} }).Parse(`The result is {"name": "rust"}.`)
+	if err != nil || typed.(*lang).Name != "rust" {
+		t.Fatalf("unexpected typed value %v (%v)", typed, err)
+	}
+
+	if _, err := NewJSONParser().Parse("no json here"); !errors.Is(err, ErrNoMatch) {
+		t.Fatalf("expected ErrNoMatch, got %v", err)
+	}
+}
+
+func TestValidateSchema(t *testing.T) {
+	schema := map[string]any{"type": "object", "required": []any{"name"}}
+	if err := ValidateSchema(map[string]any{"name": "go"}, schema); err != nil {
+		t.Fatalf("expected valid object, got %v", err)
+	}
+	if err := ValidateSchema(map[string]any{"age": 3.0}, schema); err == nil {
+		t.Fatal("expected missing required field error")
+	}
+	if err := ValidateSchema([]any{1.0}, schema); err == nil {
+		t.Fatal("expected type mismatch error")
+	}
+	if err := ValidateSchema(3.0, map[string]any{"type": "number"}); err != nil {
+		t.Fatalf("expected integer to satisfy number, got %v", err)
+	}
+	if err := ValidateSchema("anything", nil); err != nil {
+		t.Fatalf("expected nil schema to accept any value, got %v", err)
+	}
+}
+
+func TestCodeBlockParser(t *testing.T) {
+	text := "```text\nignored\n```\n```go\nfmt.Println(\"hi\")\n```"
+	value, err := NewCodeBlockParser("go").Parse(text)
+	if err != nil || value != `fmt.Println("hi")` {
+		t.Fatalf("unexpected value %q (%v)", value, err)
+	}
+	if value, _ := NewCodeBlockParser("").Parse(text); value != "ignored" {
+		t.Fatalf("expected first block, got %q", value)
+	}
+	if _, err := NewCodeBlockParser("python").Parse(text); !errors.Is(err, ErrNoMatch) {
+		t.Fatalf("expected ErrNoMatch, got %v", err)
+	}
+}
+
+func TestListParser(t *testing.T) {
+	value, err := NewListParser().Parse("Fruits:\n- apple\n* pear \n2. plum\nnot an item")
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	if fmt.Sprint(value) != "[apple pear plum]" {
+		t.Fatalf("unexpected items %v", value)
+	}
+}
+
+func TestRegexParser(t *testing.T) {
+	p, err := NewRegexParser(`(?m)^(?P<key>\w+):\s*(?P<value>.+)$`, "Answer with key: value lines.")
+	if err != nil {
+		t.Fatalf("NewRegexParser failed: %v", err)
+	}
+	value, err := p.Parse("name: gopher\ncolor: blue")
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	pairs := value.([]map[string]string)
+	if len(pairs) != 2 || pairs[1]["key"] != "color" || pairs[1]["value"] != "blue" {
+		t.Fatalf("unexpected pairs %v", pairs)
+	}
+	if _, err := NewRegexParser(`(\w+)`, ""); err == nil {
+		t.Fatal("expected error for pattern without named groups")
+	}
+}
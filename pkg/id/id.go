@@ -0,0 +1,53 @@
+package id
+
+import (
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+// Generator produces unique identifiers.
+type Generator interface {
+	New() string
+}
+
+// GeneratorFunc adapts a plain function to the Generator interface.
+type GeneratorFunc func() string
+
+// New calls f.
+func (f GeneratorFunc) New() string {
+	return f()
+}
+
+// Default generates time-ordered UUIDv7 strings that are safe to create concurrently
+// across goroutines and processes.
+var Default Generator = GeneratorFunc(newUUID)
+
+// New returns a new identifier from the default generator.
+func New() string {
+	return Default.New()
+}
+
+// NewWithPrefix returns a new identifier from the default generator with the given prefix.
+func NewWithPrefix(prefix string) string {
+	return prefix + Default.New()
+}
+
+// Prefixed wraps gen so every identifier starts with prefix.
+// A nil gen falls back to the default generator.
+func Prefixed(prefix string, gen Generator) Generator {
+	return GeneratorFunc(func() string {
+		if gen == nil {
+			return NewWithPrefix(prefix)
+		}
+		return prefix + gen.New()
+	})
+}
+
+func newUUID() string {
+	u, err := uuid.NewV7()
+	if err != nil {
+		u = uuid.New()
+	}
+	return strings.ReplaceAll(u.String(), "-", "")
+}
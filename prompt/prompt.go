@@ -2,35 +2,61 @@ package prompt
 
 import (
 	"fmt"
+	"regexp"
 	"strings"
 	"sync"
 	"text/template"
 )
 
+// partialPattern matches {{> name}} includes of other registered templates.
+var partialPattern = regexp.MustCompile(`\{\{>\s*([\w.\-/]+)\s*\}\}`)
+
 // Template represents a prompt template with variables
 type Template struct {
 	Name     string
 	Content  string
 	template *template.Template
+	partials []string
 }
 
-// NewTemplate creates a new prompt template
+// NewTemplate creates a new prompt template.
+// Content may include other templates with {{> name}}; such templates are
+// resolved when rendered through a Manager.
 func NewTemplate(name, content string) (*Template, error) {
-	tmpl, err := template.New(name).Parse(content)
+	tmpl, err := template.New(name).Parse(partialPattern.ReplaceAllString(content, ""))
 	if err != nil {
 		return nil, fmt.Errorf("failed to parse template: %w", err)
 	}
+	var partials []string
+	for _, match := range partialPattern.FindAllStringSubmatch(content, -1) {
+		partials = append(partials, match[1])
+	}
 	return &Template{
 		Name:     name,
 		Content:  content,
 		template: tmpl,
+		partials: partials,
 	}, nil
 }
 
-// Render renders the template with given variables
+// Partials returns the names of the templates included with {{> name}}.
+func (t *Template) Partials() []string {
+	return append([]string(nil), t.partials...)
+}
+
+// Render renders the template with given variables. Output is not escaped, and
+// variables missing from vars render as "<no value>"; guard optional ones with
+// {{if .name}}.
 func (t *Template) Render(vars map[string]any) (string, error) {
+	if len(t.partials) > 0 {
+		return "", fmt.Errorf("template %s includes partials; render it through a Manager", t.Name)
+	}
+	return execute(t.template, vars)
+}
+
+func execute(tmpl *template.Template, vars map[string]any) (string, error) {
 	var buf strings.Builder
-	if err := t.template.Execute(&buf, vars); err != nil {
+	if err := tmpl.Execute(&buf, vars); err != nil {
 		return "", fmt.Errorf("failed to render template: %w", err)
 	}
 	return buf.String(), nil
@@ -39,14 +65,16 @@ func (t *Template) Render(vars map[string]any) (string, error) {
 // Manager manages prompt templates
 // All operations are thread-safe using RWMutex protection
 type Manager struct {
-	mu        sync.RWMutex // Protects templates map
+	mu        sync.RWMutex // Protects templates and composed maps
 	templates map[string]*Template
+	composed  map[string]*template.Template // templates with partials expanded
 }
 
 // NewManager creates a new prompt manager
 func NewManager() *Manager {
 	return &Manager{
 		templates: make(map[string]*Template),
+		composed:  make(map[string]*template.Template),
 	}
 }
 
@@ -87,13 +115,69 @@ func (m *Manager) Get(name string) (*Template, error) {
 	return tmpl, nil
 }
 
-// Render renders a template by name with given variables
+// Render renders a template by name with given variables.
+// {{> partial}} includes are replaced by the content of the named templates,
+// recursively; missing partials and include cycles are reported as errors.
 func (m *Manager) Render(name string, vars map[string]any) (string, error) {
 	tmpl, err := m.Get(name)
 	if err != nil {
 		return "", err
 	}
-	return tmpl.Render(vars)
+	if len(tmpl.partials) == 0 {
+		return tmpl.Render(vars)
+	}
+
+	m.mu.RLock()
+	composed, ok := m.composed[name]
+	m.mu.RUnlock()
+	if !ok {
+		m.mu.RLock()
+		content, err := m.expand(name, nil)
+		m.mu.RUnlock()
+		if err != nil {
+			return "", err
+		}
+		composed, err = template.New(name).Parse(content)
+		if err != nil {
+			return "", fmt.Errorf("failed to parse template %s with partials: %w", name, err)
+		}
+		// Templates cannot be replaced once registered, so the expansion stays valid.
+		m.mu.Lock()
+		m.composed[name] = composed
+		m.mu.Unlock()
+	}
+	return execute(composed, vars)
+}
+
+// expand returns the content of name with all partials inlined. The caller must hold m.mu.
+func (m *Manager) expand(name string, stack []string) (string, error) {
+	for i, seen := range stack {
+		if seen == name {
+			return "", fmt.Errorf("partial cycle detected: %s", strings.Join(append(stack[i:], name), " -> "))
+		}
+	}
+	tmpl, ok := m.templates[name]
+	if !ok {
+		return "", fmt.Errorf("partial %s not found (included by %s)", name, stack[len(stack)-1])
+	}
+	stack = append(stack, name)
+
+	var expandErr error
+	content := partialPattern.ReplaceAllStringFunc(tmpl.Content, func(include string) string {
+		if expandErr != nil {
+			return ""
+		}
+		partial := partialPattern.FindStringSubmatch(include)[1]
+		expanded, err := m.expand(partial, stack)
+		if err != nil {
+			expandErr = err
+		}
+		return expanded
+	})
+	if expandErr != nil {
+		return "", expandErr
+	}
+	return content, nil
 }
 
 // List returns all registered template names
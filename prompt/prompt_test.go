@@ -0,0 +1,120 @@
+package prompt
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestTemplateRender(t *testing.T) {
+	cases := []struct {
+		name    string
+		content string
+		vars    map[string]any
+		want    string
+	}{
+		{"plain text", "You are helpful.", nil, "You are helpful."},
+		{"variables", "Hello {{.name}}, you have {{.count}} tasks.", map[string]any{"name": "Ada", "count": 3}, "Hello Ada, you have 3 tasks."},
+		{"missing variable", "Hello {{.name}}.", map[string]any{}, "Hello <no value>."},
+		{"optional section", "Answer.{{if .tone}} Be {{.tone}}.{{end}}", map[string]any{}, "Answer."},
+		{"no html escaping", "Echo: {{.input}}", map[string]any{"input": `<b>"x" & 'y'</b>`}, `Echo: <b>"x" & 'y'</b>`},
+		{"literal braces", `Use {{"{{"}} and {{"}}"}} verbatim.`, nil, "Use {{ and }} verbatim."},
+		{"values are not parsed", "Input: {{.input}}", map[string]any{"input": "{{.secret}} {{> other}}"}, "Input: {{.secret}} {{> other}}"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			tmpl, err := NewTemplate(tc.name, tc.content)
+			if err != nil {
+				t.Fatalf("NewTemplate: %v", err)
+			}
+			got, err := tmpl.Render(tc.vars)
+			if err != nil {
+				t.Fatalf("Render: %v", err)
+			}
+			if got != tc.want {
+				t.Fatalf("Render() = %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestTemplateErrors(t *testing.T) {
+	if _, err := NewTemplate("bad", "Hello {{.name"); err == nil {
+		t.Fatal("expected a parse error")
+	}
+	tmpl, err := NewTemplate("call", "{{.name.First}}")
+	if err != nil {
+		t.Fatalf("NewTemplate: %v", err)
+	}
+	if _, err := tmpl.Render(map[string]any{"name": 42}); err == nil {
+		t.Fatal("expected an execution error")
+	}
+	tmpl, err = NewTemplate("with-partial", "{{> header}} body")
+	if err != nil {
+		t.Fatalf("NewTemplate: %v", err)
+	}
+	if _, err := tmpl.Render(nil); err == nil {
+		t.Fatal("expected an error rendering partials without a Manager")
+	}
+}
+
+func TestManagerRenderPartials(t *testing.T) {
+	m := NewManager()
+	for name, content := range map[string]string{
+		"header": "[{{.team}}]",
+		"footer": "{{> sign}}",
+		"sign":   "-- {{.author}}",
+		"page":   "{{> header}} {{.body}}\n{{>footer}}",
+	} {
+		if err := m.RegisterString(name, content); err != nil {
+			t.Fatalf("RegisterString(%s): %v", name, err)
+		}
+	}
+	got, err := m.Render("page", map[string]any{"team": "search", "body": "Hi", "author": "bot"})
+	if err != nil {
+		t.Fatalf("Render: %v", err)
+	}
+	if want := "[search] Hi\n-- bot"; got != want {
+		t.Fatalf("Render() = %q, want %q", got, want)
+	}
+	// The composed template is cached and still uses the new variables.
+	if got, _ := m.Render("page", map[string]any{"team": "ops", "body": "Yo", "author": "me"}); got != "[ops] Yo\n-- me" {
+		t.Fatalf("second Render() = %q", got)
+	}
+	tmpl, _ := m.Get("page")
+	if got := strings.Join(tmpl.Partials(), ","); got != "header,footer" {
+		t.Fatalf("Partials() = %q", got)
+	}
+}
+
+func TestManagerRenderErrors(t *testing.T) {
+	m := NewManager()
+	_ = m.RegisterString("a", "A {{> b}}")
+	_ = m.RegisterString("b", "B {{> a}}")
+	_ = m.RegisterString("orphan", "{{> missing}}")
+
+	if _, err := m.Render("a", nil); err == nil || !strings.Contains(err.Error(), "a -> b -> a") {
+		t.Fatalf("expected a cycle error, got %v", err)
+	}
+	if _, err := m.Render("orphan", nil); err == nil || !strings.Contains(err.Error(), "partial missing not found") {
+		t.Fatalf("expected a missing partial error, got %v", err)
+	}
+	if _, err := m.Render("nope", nil); err == nil {
+		t.Fatal("expected an error for an unknown template")
+	}
+	if err := m.RegisterString("a", "again"); err == nil {
+		t.Fatal("expected an error registering a duplicate name")
+	}
+	if err := m.RegisterString("", "x"); err == nil {
+		t.Fatal("expected an error for an empty name")
+	}
+}
+
+func TestBuilder(t *testing.T) {
+	b := NewBuilder().Add("Intro. ").AddFormat("%d items", 2).AddLine("").AddSection("Rules", "Be brief.")
+	if got, want := b.Build(), "Intro. 2 items\n## Rules\nBe brief.\n"; got != want {
+		t.Fatalf("Build() = %q, want %q", got, want)
+	}
+	if got := b.Reset().Build(); got != "" {
+		t.Fatalf("Build() after Reset = %q", got)
+	}
+}
@@ -0,0 +1,81 @@
+package agentic
+
+import (
+	"regexp"
+	"strings"
+)
+
+// Citation locates one [doc-id] marker in the final answer.
+type Citation struct {
+	DocID string `json:"doc_id"`
+	// Start and End are the byte offsets of the whole bracketed marker in
+	// Response.FinalAnswer. Ids cited together, as in [a, b], share a span.
+	Start int `json:"start"`
+	End   int `json:"end"`
+	// Evidence holds the indexes into Response.Evidence of the chunks taken
+	// from the cited document.
+	Evidence []int `json:"evidence,omitempty"`
+	// Resolved reports whether the document is part of the evidence. Unresolved
+	// citations usually point at hallucinated or mistyped ids.
+	Resolved bool `json:"resolved"`
+}
+
+// citationPattern matches bracketed markers that are not markdown links.
+var citationPattern = regexp.MustCompile(`\[([^\[\]\n]+)\]`)
+
+// parseCitations extracts the [doc-id] markers of answer, also accepting the
+// [Doc:doc-id] form used in the evidence block and several ids separated by
+// commas or semicolons. Every marker is reported in order, so a document cited
+// twice yields two citations; an id repeated inside one marker is reported once.
+// Bracketed text containing whitespace is not treated as a citation.
+func parseCitations(answer string, evidence []Evidence) []Citation {
+	byDoc := make(map[string][]int)
+	for i, ev := range evidence {
+		byDoc[ev.Chunk.DocumentID] = append(byDoc[ev.Chunk.DocumentID], i)
+	}
+
+	var citations []Citation
+	for _, loc := range citationPattern.FindAllStringSubmatchIndex(answer, -1) {
+		start, end := loc[0], loc[1]
+		if end < len(answer) && answer[end] == '(' {
+			continue // Markdown link text
+		}
+		ids, ok := citationIDs(answer[loc[2]:loc[3]])
+		if !ok {
+			continue
+		}
+		for _, id := range ids {
+			indexes, resolved := byDoc[id]
+			citations = append(citations, Citation{
+				DocID:    id,
+				Start:    start,
+				End:      end,
+				Evidence: indexes,
+				Resolved: resolved,
+			})
+		}
+	}
+	return citations
+}
+
+// citationIDs splits the inside of a marker into document ids. It reports false
+// when the marker does not look like a citation.
+func citationIDs(inner string) ([]string, bool) {
+	var ids []string
+	seen := make(map[string]struct{})
+	for _, part := range strings.FieldsFunc(inner, func(r rune) bool { return r == ',' || r == ';' }) {
+		id := strings.TrimSpace(part)
+		if len(id) > 4 && strings.EqualFold(id[:4], "doc:") {
+			id = strings.TrimSpace(id[4:])
+		}
+		if id == "" || strings.ContainsAny(id, " \t") {
+			return nil, false
+		}
+		if _, dup := seen[id]; dup {
+			continue
+		}
+		seen[id] = struct{}{}
+		ids = append(ids, id)
+	}
+	return ids, len(ids) > 0
+}
@@ -0,0 +1,135 @@
+package agentic
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	"github.com/sweetpotato0/ai-allin/session"
+)
+
+// ConversationMetadataKey is the session metadata key holding the agentic conversation.
+const ConversationMetadataKey = "agentic_rag_conversation"
+
+// Conversation tracks prior turns so follow-up questions can reuse earlier evidence.
+type Conversation struct {
+	Turns []Turn `json:"turns"`
+}
+
+// ConversationFromSession loads the conversation stored on sess.
+// Records restored from JSON stores are decoded transparently.
+func ConversationFromSession(sess session.Session) (*Conversation, error) {
+	if sess == nil {
+		return &Conversation{}, nil
+	}
+	raw, ok := sess.GetMetadata(ConversationMetadataKey)
+	if !ok || raw == nil {
+		return &Conversation{}, nil
+	}
+	switch conv := raw.(type) {
+	case *Conversation:
+		return conv, nil
+	case Conversation:
+		return &conv, nil
+	}
+	data, err := json.Marshal(raw)
+	if err != nil {
+		return nil, fmt.Errorf("encode conversation metadata: %w", err)
+	}
+	conv := &Conversation{}
+	if err := json.Unmarshal(data, conv); err != nil {
+		return nil, fmt.Errorf("decode conversation metadata: %w", err)
+	}
+	return conv, nil
+}
+
+// RunInSession executes the pipeline as a follow-up turn of the conversation stored on sess.
+// Prior questions, answers and evidence feed into planning, research and synthesis so
+// references like "tell me more about that" resolve. The new turn is appended to the
+// session metadata and persists with the session snapshot. Only the turn's own top
+// evidence is stored (see WithMaxHistoryEvidence), without parent document content,
+// so the metadata stays small.
+func (p *Pipeline) RunInSession(ctx context.Context, sess session.Session, question string) (*Response, error) {
+	if sess == nil {
+		return nil, fmt.Errorf("session cannot be nil")
+	}
+	conv, err := ConversationFromSession(sess)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := p.run(ctx, &pipelineState{Question: question, History: p.recentTurns(conv.Turns)})
+	if err != nil {
+		return nil, err
+	}
+
+	turns := append(append([]Turn(nil), conv.Turns...), Turn{
+		Question: resp.Question,
+		Answer:   resp.FinalAnswer,
+		Evidence: p.turnEvidence(resp.Evidence),
+	})
+	sess.SetMetadata(ConversationMetadataKey, &Conversation{Turns: p.recentTurns(turns)})
+	return resp, nil
+}
+
+func (p *Pipeline) recentTurns(turns []Turn) []Turn {
+	limit := p.cfg.MaxHistoryTurns
+	if limit <= 0 {
+		return nil
+	}
+	if len(turns) > limit {
+		return turns[len(turns)-limit:]
+	}
+	return turns
+}
+
+// turnEvidence returns the evidence to store with a turn: the highest scoring items
+// found by the turn itself, up to MaxHistoryEvidence, with parent documents reduced
+// to their metadata. Evidence carried over from earlier turns is already stored
+// with those turns.
+func (p *Pipeline) turnEvidence(evidence []Evidence) []Evidence {
+	var kept []Evidence
+	for _, ev := range evidence {
+		if ev.StepID == "history" {
+			continue
+		}
+		if ev.Document != nil {
+			doc := *ev.Document
+			doc.Content = ""
+			ev.Document = &doc
+		}
+		kept = append(kept, ev)
+	}
+	sort.SliceStable(kept, func(i, j int) bool { return kept[i].Score > kept[j].Score })
+	if len(kept) > p.cfg.MaxHistoryEvidence {
+		kept = kept[:p.cfg.MaxHistoryEvidence]
+	}
+	return kept
+}
+
+// appendPriorEvidence carries forward evidence from earlier turns that the current
+// research did not surface again. Prior evidence still has to satisfy the active scope.
+func appendPriorEvidence(collected []Evidence, history []Turn, scope *RetrievalScope) []Evidence {
+	if len(history) == 0 {
+		return collected
+	}
+	seen := make(map[string]struct{}, len(collected))
+	for _, ev := range collected {
+		seen[ev.Chunk.ID] = struct{}{}
+	}
+	for _, turn := range history {
+		for _, ev := range turn.Evidence {
+			if _, ok := seen[ev.Chunk.ID]; ok {
+				continue
+			}
+			if !scope.Permits(scopeMetadata(ev.Document, ev.Chunk)) {
+				continue
+			}
+			seen[ev.Chunk.ID] = struct{}{}
+			ev.StepID = "history"
+			collected = append(collected, ev)
+		}
+	}
+	return collected
+}
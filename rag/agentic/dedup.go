@@ -0,0 +1,115 @@
+package agentic
+
+import (
+	"context"
+	"math"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// dedupeEvidence collapses near-duplicate evidence, keeping the highest-scored
+// representative of each group in its original position. Chunks whose content is
+// identical after normalisation always collapse; when the threshold is below 1
+// and an embedder is available, chunks whose embeddings have a cosine similarity
+// at or above the threshold collapse too. It returns the kept evidence and the
+// number of items removed.
+func (p *Pipeline) dedupeEvidence(ctx context.Context, evidence []Evidence) ([]Evidence, int) {
+	threshold := p.cfg.NearDuplicateThreshold
+	if threshold <= 0 || len(evidence) < 2 {
+		return evidence, 0
+	}
+
+	order := make([]int, len(evidence))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(i, j int) bool {
+		return evidence[order[i]].Score > evidence[order[j]].Score
+	})
+
+	var vectors [][]float32
+	if threshold < 1 && p.embedder != nil {
+		texts := make([]string, len(evidence))
+		for i, ev := range evidence {
+			texts[i] = ev.Chunk.Content
+		}
+		embedded, err := p.embedder.EmbedBatch(ctx, texts)
+		if err != nil || len(embedded) != len(evidence) {
+			p.logger.Warn("near-duplicate embedding failed, using content hashes only", "error", err)
+		} else {
+			vectors = embedded
+		}
+	}
+
+	keep := make([]bool, len(evidence))
+	seen := make(map[string]struct{}, len(evidence))
+	var kept []int
+	for _, idx := range order {
+		key := normalizeForDedup(evidence[idx].Chunk.Content)
+		if _, dup := seen[key]; dup {
+			continue
+		}
+		if vectors != nil && similarToAny(vectors, kept, idx, threshold) {
+			continue
+		}
+		seen[key] = struct{}{}
+		keep[idx] = true
+		kept = append(kept, idx)
+	}
+
+	result := make([]Evidence, 0, len(kept))
+	for i, ev := range evidence {
+		if keep[i] {
+			result = append(result, ev)
+		}
+	}
+	return result, len(evidence) - len(result)
+}
+
+// similarToAny reports whether vectors[idx] reaches threshold against any kept vector.
+func similarToAny(vectors [][]float32, kept []int, idx int, threshold float32) bool {
+	for _, k := range kept {
+		if cosine(vectors[k], vectors[idx]) >= threshold {
+			return true
+		}
+	}
+	return false
+}
+
+// cosine returns the cosine similarity of a and b without assuming unit vectors.
+func cosine(a, b []float32) float32 {
+	if len(a) != len(b) || len(a) == 0 {
+		return 0
+	}
+	var dot, normA, normB float64
+	for i := range a {
+		dot += float64(a[i]) * float64(b[i])
+		normA += float64(a[i]) * float64(a[i])
+		normB += float64(b[i]) * float64(b[i])
+	}
+	if normA == 0 || normB == 0 {
+		return 0
+	}
+	return float32(dot / (math.Sqrt(normA) * math.Sqrt(normB)))
+}
+
+// normalizeForDedup lowercases text, drops punctuation and collapses whitespace
+// so copies that differ only in formatting compare equal.
+func normalizeForDedup(text string) string {
+	var sb strings.Builder
+	space := false
+	for _, r := range strings.ToLower(text) {
+		switch {
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			if space && sb.Len() > 0 {
+				sb.WriteByte(' ')
+			}
+			space = false
+			sb.WriteRune(r)
+		case unicode.IsSpace(r):
+			space = true
+		}
+	}
+	return sb.String()
+}
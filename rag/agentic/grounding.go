@@ -0,0 +1,74 @@
+package agentic
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/sweetpotato0/ai-allin/agent"
+	"github.com/sweetpotato0/ai-allin/message"
+)
+
+type groundingChecker struct {
+	llm    agent.LLMClient
+	prompt string
+}
+
+func newGroundingChecker(llm agent.LLMClient, cfg *Config) *groundingChecker {
+	if llm == nil {
+		return nil
+	}
+	return &groundingChecker{
+		llm:    llm,
+		prompt: cfg.GroundingPrompt,
+	}
+}
+
+type groundingOutput struct {
+	Sentences []SentenceGrounding `json:"sentences"`
+}
+
+// Check asks the LLM to verify every factual sentence of answer against the evidence.
+func (g *groundingChecker) Check(ctx context.Context, answer string, evidence []Evidence) (*GroundingReport, error) {
+	if g == nil || g.llm == nil {
+		return nil, nil
+	}
+
+	userPrompt := fmt.Sprintf("Evidence:\n%s\n\nAnswer:\n%s\n\nReturn JSON only.", formatEvidence(evidence), answer)
+	resp, err := g.llm.Generate(ctx, &agent.GenerateRequest{
+		Messages: []*message.Message{
+			message.NewMessage(message.RoleSystem, g.prompt),
+			message.NewMessage(message.RoleUser, userPrompt),
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("grounding check failed: %w", err)
+	}
+
+	out, err := decodeJSON[groundingOutput](resp.Message.Text())
+	if err != nil {
+		return &GroundingReport{Notes: fmt.Sprintf("grounding output parse error: %v", err)}, nil
+	}
+	return newGroundingReport(out.Sentences), nil
+}
+
+// newGroundingReport scores the sentences: the score is the supported fraction,
+// and 1 when the answer contains no factual sentences.
+func newGroundingReport(sentences []SentenceGrounding) *GroundingReport {
+	report := &GroundingReport{Score: 1, Sentences: sentences}
+	if len(sentences) == 0 {
+		return report
+	}
+	supported := 0
+	for _, s := range sentences {
+		if s.Supported {
+			supported++
+			continue
+		}
+		if text := strings.TrimSpace(s.Sentence); text != "" {
+			report.Unsupported = append(report.Unsupported, text)
+		}
+	}
+	report.Score = float64(supported) / float64(len(sentences))
+	return report
+}
@@ -2,6 +2,7 @@ package agentic
 
 import (
 	"strings"
+	"time"
 
 	"github.com/sweetpotato0/ai-allin/rag/chunking"
 	"github.com/sweetpotato0/ai-allin/rag/preprocess"
@@ -14,23 +15,38 @@ import (
 // It intentionally groups prompt/middleware knobs and low-level retrieval parameters
 // so callers can construct reproducible agents from a single struct.
 type Config struct {
-	Name                string // Logical name for tracing/logging
-	TopK                int    // How many neighbors to pull from the vector store
-	RerankTopK          int    // How many results survive reranking
-	MaxPlanSteps        int    // Upper bound for planner emitted steps
-	EnableCritic        bool   // Toggle critic agent execution
-	GraphMaxVisits      int    // Safety guard for graph execution
-	MinEvidenceCount    int    // Minimum evidence items required before synthesis runs
+	Name                string        // Logical name for tracing/logging
+	TopK                int           // How many neighbors to pull from the vector store
+	RerankTopK          int           // How many results survive reranking
+	MaxPlanSteps        int           // Upper bound for planner emitted steps
+	EnableCritic        bool          // Toggle critic agent execution
+	AsyncCritic         bool          // Let RunStream emit the draft before the critic finishes
+	EnableGrounding     bool          // Verify each factual sentence of the answer against the evidence
+	ParseCitations      bool          // Populate Response.Citations from the answer's [doc-id] markers
+	GraphMaxVisits      int           // Safety guard for graph execution
+	RunTimeout          time.Duration // Internal deadline for a single run (0 disables)
+	MinEvidenceCount    int           // Minimum evidence items required before synthesis runs
+	MaxReplanRounds     int           // Re-planning rounds allowed when evidence is insufficient (0 disables)
 	MinSearchScore      float32
 	EnableHybridSearch  bool
 	HybridTopK          int
 	TitleScorePenalty   float32
 	NormalizeEmbeddings bool
 
+	RetrievalConcurrency int // Plan steps researched concurrently (default 4)
+
+	MetadataFilter map[string]any // Only retrieve chunks whose metadata matches every key exactly
+
+	AdaptiveScoreThreshold bool    // Derive a per-query score cutoff; MinSearchScore remains the floor
+	AdaptiveScoreRatio     float32 // Results within this fraction of the top score pass the adaptive cutoff (default 0.8)
+
+	NearDuplicateThreshold float32 // Collapse evidence at or above this similarity (0 disables)
+
 	PlannerPrompt   string // Custom system prompt for planner agent
 	QueryPrompt     string // System prompt for researcher/query agent
 	SynthesisPrompt string // System prompt for writer/synthesizer agent
 	CriticPrompt    string // System prompt for critic agent
+	GroundingPrompt string // System prompt for the grounding checker
 	NoAnswerMessage string // Message emitted when evidence is insufficient
 
 	QueryLLMRetries int // How many times the researcher retries invalid LLM output
@@ -38,6 +54,11 @@ type Config struct {
 
 	ChunkOverlap int // Overlap between consecutive chunks
 
+	MaxHistoryTurns    int // Prior turns carried forward by RunInSession
+	MaxHistoryEvidence int // Evidence items RunInSession stores per turn
+
+	Collection string // Vector store collection the default retrieval engine reads and writes
+
 	tokenizer  tokenizer.Tokenizer   // Optional override for chunking strategy
 	chunker    chunking.Chunker      // Optional override for chunking strategy
 	summarizer summarizer.Summarizer // Optional override for reranking stage
@@ -119,6 +140,22 @@ func WithCritic(enabled bool) Option {
 	}
 }
 
+// WithAsyncCritic makes RunStream emit the draft immediately and run the critic afterwards.
+// Run is unaffected and always waits for the critic.
+func WithAsyncCritic(enabled bool) Option {
+	return func(cfg *Config) {
+		cfg.AsyncCritic = enabled
+	}
+}
+
+// WithCitationParsing extracts the [doc-id] markers of the final answer into
+// Response.Citations, linking each to the evidence it references.
+func WithCitationParsing(enabled bool) Option {
+	return func(cfg *Config) {
+		cfg.ParseCitations = enabled
+	}
+}
+
 // WithMinEvidenceCount sets the minimum amount of evidence required before synthesis runs.
 func WithMinEvidenceCount(count int) Option {
 	return func(cfg *Config) {
@@ -128,6 +165,39 @@ func WithMinEvidenceCount(count int) Option {
 	}
 }
 
+// WithMaxReplanRounds lets the pipeline re-plan up to n times when research
+// collects fewer than MinEvidenceCount items. Each round asks the planner for
+// additional or alternative steps and researches them, keeping the evidence
+// already found. Zero disables re-planning.
+func WithMaxReplanRounds(n int) Option {
+	return func(cfg *Config) {
+		if n >= 0 {
+			cfg.MaxReplanRounds = n
+		}
+	}
+}
+
+// WithRetrievalConcurrency sets how many plan steps are researched at the same
+// time. Evidence is merged in plan order, so results do not depend on it.
+func WithRetrievalConcurrency(n int) Option {
+	return func(cfg *Config) {
+		if n > 0 {
+			cfg.RetrievalConcurrency = n
+		}
+	}
+}
+
+// WithMetadataFilter restricts retrieval to documents whose metadata matches
+// every key of filter as defined by vector.MatchesFilter, e.g.
+// {"source": "knowledge-base"} matches that source or a list holding it. The filter
+// is passed to RetrievalEngine.SearchWithFilter, so stores that support it
+// apply it before ranking.
+func WithMetadataFilter(filter map[string]any) Option {
+	return func(cfg *Config) {
+		cfg.MetadataFilter = cloneMetadata(filter)
+	}
+}
+
 // WithMinSearchScore filters retrieval results below the provided score.
 func WithMinSearchScore(score float32) Option {
 	return func(cfg *Config) {
@@ -137,6 +207,39 @@ func WithMinSearchScore(score float32) Option {
 	}
 }
 
+// WithAdaptiveScoreThreshold computes the score cutoff per query instead of using
+// MinSearchScore alone. A result is kept when its score reaches AdaptiveScoreRatio
+// of the top score (measured by magnitude, so it also works for negative scores)
+// or exceeds the mean plus one standard deviation of the query's scores, and it is
+// never below MinSearchScore.
+func WithAdaptiveScoreThreshold(enabled bool) Option {
+	return func(cfg *Config) {
+		cfg.AdaptiveScoreThreshold = enabled
+	}
+}
+
+// WithAdaptiveScoreRatio sets the fraction of the top score used by the adaptive threshold.
+func WithAdaptiveScoreRatio(ratio float32) Option {
+	return func(cfg *Config) {
+		if ratio > 0 && ratio <= 1 {
+			cfg.AdaptiveScoreRatio = ratio
+		}
+	}
+}
+
+// WithNearDuplicateDedup collapses near-identical evidence after retrieval and
+// keeps the highest-scored copy. Chunks with the same normalised content always
+// collapse; a threshold below 1 also collapses chunks whose embeddings reach that
+// cosine similarity, which requires the pipeline to have an embedder. A
+// non-positive threshold disables de-duplication.
+func WithNearDuplicateDedup(threshold float32) Option {
+	return func(cfg *Config) {
+		if threshold <= 1 {
+			cfg.NearDuplicateThreshold = threshold
+		}
+	}
+}
+
 // WithHybridSearch toggles the keyword fallback search.
 func WithHybridSearch(enabled bool) Option {
 	return func(cfg *Config) {
@@ -214,6 +317,23 @@ func WithSynthesisPrompt(prompt string) Option {
 	}
 }
 
+// WithGroundingCheck enables the grounding check, which flags answer sentences that
+// the evidence does not support and reports a grounding score on the Response.
+func WithGroundingCheck(enabled bool) Option {
+	return func(cfg *Config) {
+		cfg.EnableGrounding = enabled
+	}
+}
+
+// WithGroundingPrompt sets the grounding checker system prompt.
+func WithGroundingPrompt(prompt string) Option {
+	return func(cfg *Config) {
+		if prompt != "" {
+			cfg.GroundingPrompt = prompt
+		}
+	}
+}
+
 // WithCriticPrompt sets the critic system prompt.
 func WithCriticPrompt(prompt string) Option {
 	return func(cfg *Config) {
@@ -232,6 +352,14 @@ func WithNoAnswerMessage(message string) Option {
 	}
 }
 
+// WithCollection scopes the vector store to the named collection so one store
+// can serve several pipelines. It has no effect with a custom retrieval engine.
+func WithCollection(name string) Option {
+	return func(cfg *Config) {
+		cfg.Collection = name
+	}
+}
+
 // WithMaxPlanSteps caps the number of steps that the planner may emit.
 func WithMaxPlanSteps(max int) Option {
 	return func(cfg *Config) {
@@ -302,6 +430,35 @@ func WithRetriever(engine RetrievalEngine) Option {
 	}
 }
 
+// WithMaxHistoryTurns caps how many prior turns RunInSession feeds into planning and synthesis.
+func WithMaxHistoryTurns(turns int) Option {
+	return func(cfg *Config) {
+		if turns >= 0 {
+			cfg.MaxHistoryTurns = turns
+		}
+	}
+}
+
+// WithMaxHistoryEvidence caps how many evidence items RunInSession stores with each
+// turn in the session metadata; the highest scoring items are kept.
+func WithMaxHistoryEvidence(items int) Option {
+	return func(cfg *Config) {
+		if items >= 0 {
+			cfg.MaxHistoryEvidence = items
+		}
+	}
+}
+
+// WithRunTimeout bounds every pipeline run with an internal deadline.
+// Timeouts surface as *TimeoutError naming the stage that was executing.
+func WithRunTimeout(d time.Duration) Option {
+	return func(cfg *Config) {
+		if d > 0 {
+			cfg.RunTimeout = d
+		}
+	}
+}
+
 // WithGraphMaxVisits tweaks the safety guard for graph traversal.
 func WithGraphMaxVisits(max int) Option {
 	return func(cfg *Config) {
@@ -313,14 +470,16 @@ func WithGraphMaxVisits(max int) Option {
 
 func defaultConfig() *Config {
 	cfg := &Config{
-		Name:             "agentic-rag",
-		MaxPlanSteps:     3,
-		EnableCritic:     true,
-		GraphMaxVisits:   20,
-		MinEvidenceCount: 1,
-		QueryLLMRetries:  2,
-		QueryMaxResults:  3,
-		ChunkOverlap:     120,
+		Name:               "agentic-rag",
+		MaxPlanSteps:       3,
+		EnableCritic:       true,
+		GraphMaxVisits:     20,
+		MinEvidenceCount:   1,
+		QueryLLMRetries:    2,
+		QueryMaxResults:    3,
+		ChunkOverlap:       120,
+		MaxHistoryTurns:    3,
+		MaxHistoryEvidence: 5,
 		PlannerPrompt: `You are the lead planner for an agentic RAG pipeline. Break the user question into at most {{max_steps}} sequential research steps that collect the evidence needed for a final answer. Output compact JSON only matching {"strategy":"...", "steps":[{"id":"step-1","goal":"...","questions":["..."],"expected_evidence":"...","downstream_support":"..."}]}.
 Planning rules:
 - "strategy" is a single sentence describing the overall approach.
@@ -349,8 +508,17 @@ Rules:
 - List concrete problems in "issues" (missing evidence, wrong citations, unanswered sub-questions) referencing plan step IDs or [doc-id] when helpful.
 - If revision is needed, set "verdict":"revise" and provide an improved, citation-backed answer in "final_answer"; otherwise copy the draft verbatim.
 - Match the language of the original question (Chinese stays Chinese, else English).`,
+		GroundingPrompt: `You verify that an answer is grounded in the supplied evidence.
+Split the answer into sentences and evaluate every sentence that states a fact; skip greetings, transitions and statements about missing information.
+Return JSON only: {"sentences":[{"sentence":"...","supported":true,"citations":["doc-id"]}]}.
+Rules:
+- Mark "supported":true only when the evidence explicitly states or directly implies the claim; list the supporting document IDs in "citations".
+- Mark "supported":false for claims that are absent from or contradicted by the evidence, even if they are generally true.
+- Copy each sentence verbatim from the answer.`,
 		NoAnswerMessage: "抱歉，我没有在知识库中找到与该问题相关的答案，请提供更多上下文或重新描述问题。",
 		preprocess:      preprocess.Preprocess,
+
+		RetrievalConcurrency: 4,
 	}
 	WithRetrievalPreset(RetrievalPresetHybrid)(cfg)
 	return cfg
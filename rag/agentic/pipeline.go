@@ -2,9 +2,13 @@ package agentic
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log/slog"
+	"sort"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/sweetpotato0/ai-allin/agent"
 	"github.com/sweetpotato0/ai-allin/graph"
@@ -19,6 +23,10 @@ import (
 
 const ragStateKey = "__agentic_rag_state"
 
+// maxContextRetries bounds how often synthesis is retried with less evidence
+// after the writer model reports a context-length error.
+const maxContextRetries = 3
+
 // Clients groups the LLM clients used by the different pipeline agents.
 type Clients struct {
 	Default    agent.LLMClient
@@ -26,6 +34,7 @@ type Clients struct {
 	Researcher agent.LLMClient
 	Writer     agent.LLMClient
 	Critic     agent.LLMClient
+	Grounding  agent.LLMClient // Falls back to Critic, then Default
 }
 
 // Pipeline wires the multi-agent RAG workflow together.
@@ -42,7 +51,9 @@ type Pipeline struct {
 	researcher *researcher
 	writer     *synthesizer
 	critic     *critic
+	grounding  *groundingChecker
 	retrieval  RetrievalEngine
+	embedder   vector.Embedder
 	graph      *graph.Graph
 	logger     *slog.Logger
 }
@@ -50,11 +61,27 @@ type Pipeline struct {
 var pipelineTracer = otel.Tracer("github.com/sweetpotato0/ai-allin/rag/agentic/pipeline")
 
 type pipelineState struct {
-	Question string          // Original user question
-	Plan     *Plan           // Plan produced by planner node
-	Evidence []Evidence      // Collected evidence per step
-	Draft    string          // Writer response before critique
-	Critic   *CriticFeedback // Optional critic verdict
+	Question  string           // Original user question
+	Plan      *Plan            // Plan produced by planner node
+	Evidence  []Evidence       // Collected evidence per step
+	Draft     string           // Writer response before critique
+	Critic    *CriticFeedback  // Optional critic verdict
+	Grounding *GroundingReport // Optional grounding verdict
+
+	History     []Turn // Prior turns carried forward from the conversation
+	DeferCritic bool   // Skip the critic gate so the caller can review asynchronously
+	NoAnswer    bool   // Draft is the no-answer message because evidence was insufficient
+	Stage       string // Stage currently executing, reported on timeouts
+
+	Scope *RetrievalScope // Mandatory metadata scope merged into every search, if set
+
+	EvidenceDropped int // Evidence removed to fit the writer's context window
+
+	OnToken func(token string) error // Receives the answer as it is synthesized, if set
+
+	ReplanRounds int  // Re-planning rounds run because evidence was insufficient
+	Researched   int  // Plan steps already researched; later rounds only research new steps
+	ReplanDone   bool // Re-planning gave up, e.g. because the planner found no new steps
 }
 
 // NewPipeline creates a fully wired Agentic RAG pipeline.
@@ -86,39 +113,54 @@ func NewPipeline(clients Clients, embedder vector.Embedder, store vector.VectorS
 		writer:     newSynthesizer(writerLLM, cfg),
 		critic:     nil,
 		retrieval:  engine,
+		embedder:   embedder,
 		logger:     logging.WithComponent("agentic_pipeline").With("pipeline", cfg.Name),
 	}
 	if cfg.EnableCritic {
 		p.critic = newCritic(pickClient(clients.Critic, clients.Default), cfg)
 	}
+	if cfg.EnableGrounding {
+		p.grounding = newGroundingChecker(pickClient(clients.Grounding, pickClient(clients.Critic, clients.Default)), cfg)
+	}
 
 	builder := graph.NewBuilder().
 		AddNode("start", graph.NodeTypeStart, p.startNode).
 		AddNode("planner", graph.NodeTypeLLM, p.planNode).
 		AddNode("research", graph.NodeTypeTool, p.researchNode).
+		AddConditionNode("evidence_gate", p.evidenceGate, map[string]string{
+			"replan": "replan",
+			"enough": "synthesis",
+		}).
+		AddNode("replan", graph.NodeTypeLLM, p.replanNode).
 		AddNode("synthesis", graph.NodeTypeLLM, p.synthesizeNode).
 		AddConditionNode("critic_gate", p.criticGate, map[string]string{
 			"run":  "critic",
-			"skip": "end",
+			"skip": "grounding",
 		}).
 		AddNode("critic", graph.NodeTypeLLM, p.criticNode).
+		AddNode("grounding", graph.NodeTypeLLM, p.groundingNode).
 		AddNode("end", graph.NodeTypeEnd, p.endNode).
 		AddEdge("start", "planner").
 		AddEdge("planner", "research").
-		AddEdge("research", "synthesis").
+		AddEdge("research", "evidence_gate").
+		AddEdge("replan", "research").
 		AddEdge("synthesis", "critic_gate").
-		AddEdge("critic", "end").
+		AddEdge("critic", "grounding").
+		AddEdge("grounding", "end").
 		SetStart("start").
 		SetEnd("end")
 
 	g := builder.Build()
-	g.SetMaxVisits(cfg.GraphMaxVisits)
+	// Each re-planning round revisits the research stage.
+	g.SetMaxVisits(max(cfg.GraphMaxVisits, cfg.MaxReplanRounds+2))
 	p.graph = g
 	p.logger.Info("agentic pipeline initialised",
 		"top_k", cfg.TopK,
 		"rerank_top_k", cfg.RerankTopK,
 		"hybrid", cfg.EnableHybridSearch,
 		"critic_enabled", cfg.EnableCritic,
+		"grounding_enabled", cfg.EnableGrounding,
+		"max_replan_rounds", cfg.MaxReplanRounds,
 	)
 	return p, nil
 }
@@ -132,6 +174,11 @@ func pickClient(primary, fallback agent.LLMClient) agent.LLMClient {
 
 // Run executes the pipeline for a new question.
 func (p *Pipeline) Run(ctx context.Context, question string) (*Response, error) {
+	return p.run(ctx, &pipelineState{Question: question})
+}
+
+func (p *Pipeline) run(ctx context.Context, seed *pipelineState) (*Response, error) {
+	question := seed.Question
 	ctx, span := pipelineTracer.Start(ctx, "Pipeline.Run",
 		oteltrace.WithAttributes(
 			attribute.String("pipeline.name", p.cfg.Name),
@@ -145,24 +192,139 @@ func (p *Pipeline) Run(ctx context.Context, question string) (*Response, error)
 	}
 	p.logger.Info("pipeline run started", "question", trimForLog(question, 120))
 
-	initial := graph.State{
-		ragStateKey: &pipelineState{
-			Question: strings.TrimSpace(question),
-		},
+	st, err := p.execute(ctx, seed)
+	if err != nil {
+		spanErr = err
+		return nil, err
 	}
 
-	finalState, err := p.graph.Execute(ctx, initial)
+	resp := p.buildResponse(st)
+	planSteps := 0
+	if resp.Plan != nil {
+		planSteps = len(resp.Plan.Steps)
+	}
+	p.logger.Info("pipeline run completed",
+		"question", trimForLog(question, 120),
+		"plan_steps", planSteps,
+		"evidence_count", len(resp.Evidence),
+		"critic", st.Critic != nil,
+	)
+	span.SetAttributes(
+		attribute.Int("plan.steps", planSteps),
+		attribute.Int("evidence.count", len(resp.Evidence)),
+		attribute.Bool("critic.enabled", st.Critic != nil),
+	)
+	return resp, nil
+}
+
+// RunWithScope executes the pipeline while restricting every search to the given scope.
+// The scope is merged into the metadata filter passed to the retrieval engine, so
+// documents outside it never take top-k slots and are never surfaced as evidence.
+func (p *Pipeline) RunWithScope(ctx context.Context, question string, scope *RetrievalScope) (*Response, error) {
+	return p.run(ctx, &pipelineState{Question: question, Scope: scope})
+}
+
+// Retrieve runs a single search restricted to the configured metadata filter and
+// to scope, which may be nil. It skips planning and synthesis.
+func (p *Pipeline) Retrieve(ctx context.Context, query string, scope *RetrievalScope) ([]RetrievalResult, error) {
+	if strings.TrimSpace(query) == "" {
+		return nil, fmt.Errorf("query cannot be empty")
+	}
+	results, err := p.search(ctx, query, scope)
 	if err != nil {
-		spanErr = err
 		return nil, err
 	}
+	permitted := results[:0]
+	for _, res := range results {
+		doc, ok := p.retrieval.Document(res.Chunk.DocumentID)
+		if ok && scope.Permits(scopeMetadata(&doc, res.Chunk)) {
+			permitted = append(permitted, res)
+		}
+	}
+	return permitted, nil
+}
+
+// RunWithRetry executes the pipeline and retries the whole run on transient provider errors.
+// Only errors classified by agent.IsRetriable trigger another attempt; each attempt starts from
+// a fresh state, so no partial results leak between attempts.
+func (p *Pipeline) RunWithRetry(ctx context.Context, question string, policy agent.RetryPolicy) (*Response, error) {
+	attempts := policy.MaxAttempts
+	if attempts <= 0 {
+		attempts = 1
+	}
+	var lastErr error
+	for attempt := 1; attempt <= attempts; attempt++ {
+		if attempt > 1 {
+			if err := policy.Wait(ctx, attempt-1); err != nil {
+				return nil, fmt.Errorf("pipeline retry aborted: %w", errors.Join(err, lastErr))
+			}
+			p.logger.Warn("retrying pipeline run", "attempt", attempt, "error", lastErr)
+		}
+		resp, err := p.Run(ctx, question)
+		if err == nil {
+			return resp, nil
+		}
+		lastErr = err
+		if !agent.IsRetriable(err) || ctx.Err() != nil {
+			return nil, err
+		}
+	}
+	return nil, fmt.Errorf("pipeline failed after %d attempts: %w", attempts, lastErr)
+}
+
+// execute runs the pipeline graph seeded with st and returns the resulting state.
+// When RunTimeout is configured the graph runs under an internal deadline.
+func (p *Pipeline) execute(ctx context.Context, st *pipelineState) (*pipelineState, error) {
+	st.Question = strings.TrimSpace(st.Question)
+	if st.Scope == nil {
+		st.Scope = RetrievalScopeFromContext(ctx)
+	}
+	initial := graph.State{ragStateKey: st}
 
-	state, err := getState(finalState)
+	if p.cfg.RunTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, p.cfg.RunTimeout)
+		defer cancel()
+	}
+
+	finalState, err := p.graph.Execute(ctx, initial)
 	if err != nil {
-		spanErr = err
+		if p.cfg.RunTimeout > 0 && errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			p.logger.Error("pipeline run timed out", "stage", st.Stage, "timeout", p.cfg.RunTimeout)
+			return nil, &TimeoutError{Stage: st.Stage, Timeout: p.cfg.RunTimeout}
+		}
 		return nil, err
 	}
+	return getState(finalState)
+}
 
+// TimeoutError reports that the pipeline exceeded its RunTimeout.
+// It matches context.DeadlineExceeded via errors.Is.
+type TimeoutError struct {
+	Stage   string        // Stage that was executing when the deadline fired
+	Timeout time.Duration // Configured run timeout
+}
+
+// Error implements the error interface.
+func (e *TimeoutError) Error() string {
+	return fmt.Sprintf("agentic pipeline timed out after %s during %s stage", e.Timeout, e.Stage)
+}
+
+// Unwrap returns context.DeadlineExceeded.
+func (e *TimeoutError) Unwrap() error {
+	return context.DeadlineExceeded
+}
+
+// buildResponse assembles the response for st, adding citations when enabled.
+func (p *Pipeline) buildResponse(st *pipelineState) *Response {
+	resp := buildResponse(st)
+	if p.cfg.ParseCitations && !st.NoAnswer {
+		resp.Citations = parseCitations(resp.FinalAnswer, resp.Evidence)
+	}
+	return resp
+}
+
+func buildResponse(state *pipelineState) *Response {
 	resp := &Response{
 		Question:    state.Question,
 		Plan:        state.Plan,
@@ -170,26 +332,15 @@ func (p *Pipeline) Run(ctx context.Context, question string) (*Response, error)
 		DraftAnswer: state.Draft,
 		FinalAnswer: state.Draft,
 		Critic:      state.Critic,
+		Grounding:   state.Grounding,
+
+		EvidenceDropped: state.EvidenceDropped,
+		ReplanRounds:    state.ReplanRounds,
 	}
 	if state.Critic != nil && state.Critic.FinalAnswer != "" {
 		resp.FinalAnswer = state.Critic.FinalAnswer
 	}
-	planSteps := 0
-	if resp.Plan != nil {
-		planSteps = len(resp.Plan.Steps)
-	}
-	p.logger.Info("pipeline run completed",
-		"question", trimForLog(question, 120),
-		"plan_steps", planSteps,
-		"evidence_count", len(resp.Evidence),
-		"critic", state.Critic != nil,
-	)
-	span.SetAttributes(
-		attribute.Int("plan.steps", planSteps),
-		attribute.Int("evidence.count", len(resp.Evidence)),
-		attribute.Bool("critic.enabled", state.Critic != nil),
-	)
-	return resp, nil
+	return resp
 }
 
 // IndexDocuments ingests documents into the vector store.
@@ -200,12 +351,56 @@ func (p *Pipeline) IndexDocuments(ctx context.Context, docs ...Document) error {
 		return nil
 	}
 	p.logger.Info("indexing documents", "count", len(docs))
+	casts, err := p.castDocuments(docs)
+	if err != nil {
+		return err
+	}
+	return p.retrieval.IndexDocuments(ctx, casts...)
+}
+
+// UpsertDocuments indexes documents, first removing every chunk previously
+// indexed under the same IDs so stale content cannot be retrieved.
+func (p *Pipeline) UpsertDocuments(ctx context.Context, docs ...Document) error {
+	if len(docs) == 0 {
+		return nil
+	}
+	p.logger.Info("upserting documents", "count", len(docs))
+	casts, err := p.castDocuments(docs)
+	if err != nil {
+		return err
+	}
+	ids := make([]string, 0, len(casts))
+	for _, doc := range casts {
+		if doc.ID != "" {
+			ids = append(ids, doc.ID)
+		}
+	}
+	if len(ids) > 0 {
+		if err := p.retrieval.Delete(ctx, ids...); err != nil {
+			p.logger.Error("delete stale documents failed", "error", err)
+			return fmt.Errorf("delete stale documents: %w", err)
+		}
+	}
+	return p.retrieval.IndexDocuments(ctx, casts...)
+}
+
+// DeleteDocuments removes the documents with the given IDs and all of their chunks.
+func (p *Pipeline) DeleteDocuments(ctx context.Context, ids ...string) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	p.logger.Info("deleting documents", "count", len(ids))
+	return p.retrieval.Delete(ctx, ids...)
+}
+
+// castDocuments validates docs and converts them to retrieval documents.
+func (p *Pipeline) castDocuments(docs []Document) ([]document.Document, error) {
 	casts := make([]document.Document, len(docs))
 	for i, doc := range docs {
 		if strings.TrimSpace(doc.Content) == "" {
 			err := fmt.Errorf("document content cannot be empty")
 			p.logger.Error("index document failed", "error", err, "doc_id", doc.ID)
-			return err
+			return nil, err
 		}
 		casts[i] = document.Document{
 			ID:       doc.ID,
@@ -214,10 +409,7 @@ func (p *Pipeline) IndexDocuments(ctx context.Context, docs ...Document) error {
 			Metadata: cloneMetadata(doc.Metadata),
 		}
 	}
-	if len(casts) == 0 {
-		return nil
-	}
-	return p.retrieval.IndexDocuments(ctx, casts...)
+	return casts, nil
 }
 
 // ClearDocuments removes all indexed documents.
@@ -245,8 +437,9 @@ func (p *Pipeline) planNode(ctx context.Context, state graph.State) (graph.State
 		spanErr = err
 		return state, err
 	}
+	st.Stage = "planner"
 
-	plan, err := p.planner.Plan(ctx, st.Question)
+	plan, err := p.planner.Plan(ctx, st.Question, st.History)
 	if err != nil {
 		p.logger.Error("planner failed", "error", err)
 		spanErr = err
@@ -267,70 +460,239 @@ func (p *Pipeline) researchNode(ctx context.Context, state graph.State) (graph.S
 		spanErr = err
 		return state, err
 	}
+	st.Stage = "research"
 	if st.Plan == nil {
 		spanErr = fmt.Errorf("plan not available for research node")
 		return state, spanErr
 	}
 
-	collected := make([]Evidence, 0)
+	// Later re-planning rounds keep the evidence found so far and only research
+	// the steps added since.
+	collected := append(make([]Evidence, 0, len(st.Evidence)), st.Evidence...)
 	type evidenceKey struct {
 		step  string
 		chunk string
 	}
 	index := make(map[evidenceKey]int)
+	for i, ev := range collected {
+		index[evidenceKey{step: ev.StepID, chunk: ev.Chunk.ID}] = i
+	}
+	scope := st.Scope
+	denied := 0
 
-	for _, step := range st.Plan.Steps {
-		p.logger.Debug("research step started", "step", step.ID, "goal", trimForLog(step.Goal, 80))
-		queries, err := p.researcher.buildQueries(ctx, st.Question, step)
-		if err != nil {
-			spanErr = err
-			p.logger.Error("query generation failed", "step", step.ID, "error", err)
-			return state, err
-		}
-		p.logger.Debug("queries generated", "step", step.ID, "count", len(queries))
-		span.AddEvent("queries_generated", oteltrace.WithAttributes(attribute.String("step", step.ID), attribute.Int("count", len(queries))))
-		for _, q := range queries {
-			results, err := p.retrieval.Search(ctx, q)
-			if err != nil {
-				spanErr = err
-				p.logger.Error("vector search failed", "step", step.ID, "error", err)
-				return state, fmt.Errorf("vector search failed: %w", err)
-			}
-			p.logger.Debug("retrieval results", "step", step.ID, "query", trimForLog(q, 80), "hits", len(results))
-			for _, candidate := range results {
-				doc, ok := p.retrieval.Document(candidate.Chunk.DocumentID)
-				if !ok {
-					continue
-				}
-				score := candidate.Score
-				key := evidenceKey{step: step.ID, chunk: candidate.Chunk.ID}
-				if idx, ok := index[key]; ok {
-					if score > collected[idx].Score {
-						collected[idx].Score = score
-						collected[idx].Query = q
-					}
-					continue
-				}
-				ev := Evidence{
-					StepID:   step.ID,
-					Query:    q,
-					Document: &doc,
-					Chunk:    candidate.Chunk,
-					Score:    score,
-					Summary:  summarizeChunk(candidate.Chunk, 320),
+	steps := st.Plan.Steps[min(st.Researched, len(st.Plan.Steps)):]
+	found, err := p.researchSteps(ctx, span, st.Question, steps, scope)
+	if err != nil {
+		spanErr = err
+		return state, err
+	}
+	// Merge in plan order so the evidence order does not depend on which step
+	// finished first.
+	for _, res := range found {
+		denied += res.denied
+		for _, ev := range res.evidence {
+			key := evidenceKey{step: ev.StepID, chunk: ev.Chunk.ID}
+			if idx, ok := index[key]; ok {
+				if ev.Score > collected[idx].Score {
+					collected[idx].Score = ev.Score
+					collected[idx].Query = ev.Query
 				}
-				index[key] = len(collected)
-				collected = append(collected, ev)
+				continue
 			}
+			index[key] = len(collected)
+			collected = append(collected, ev)
 		}
 	}
 
+	collected, duplicates := p.dedupeEvidence(ctx, collected)
+	if duplicates > 0 {
+		span.SetAttributes(attribute.Int("evidence.duplicates", duplicates))
+		p.logger.Debug("near-duplicate evidence collapsed", "removed", duplicates)
+	}
+	collected = appendPriorEvidence(collected, st.History, scope)
 	st.Evidence = collected
+	st.Researched = len(st.Plan.Steps)
 	span.SetAttributes(attribute.Int("evidence.count", len(collected)))
+	if denied > 0 {
+		span.SetAttributes(attribute.Int("evidence.denied", denied))
+		p.logger.Debug("retrieval scope filtered results", "denied", denied)
+	}
 	p.logger.Info("research completed", "evidence_count", len(collected))
 	return state, nil
 }
 
+// search queries the retrieval engine with the configured metadata filter and
+// scope merged into one filter, so the store applies both before cutting top-k.
+// Callers still check results against scope in case an engine ignores the filter.
+func (p *Pipeline) search(ctx context.Context, query string, scope *RetrievalScope) ([]RetrievalResult, error) {
+	filter, ok := scope.Filter(p.cfg.MetadataFilter)
+	if !ok {
+		return nil, nil
+	}
+	if len(filter) > 0 {
+		return p.retrieval.SearchWithFilter(ctx, query, filter)
+	}
+	return p.retrieval.Search(ctx, query)
+}
+
+// stepEvidence is the outcome of researching one plan step.
+type stepEvidence struct {
+	evidence []Evidence // Evidence in retrieval order, one item per chunk
+	denied   int        // Results rejected by the retrieval scope
+}
+
+// researchSteps researches steps concurrently, at most RetrievalConcurrency at
+// a time, and returns their results in step order. The first failing step
+// cancels the others and its error is returned.
+func (p *Pipeline) researchSteps(ctx context.Context, span oteltrace.Span, question string, steps []PlanStep, scope *RetrievalScope) ([]stepEvidence, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	results := make([]stepEvidence, len(steps))
+	sem := make(chan struct{}, max(p.cfg.RetrievalConcurrency, 1))
+	var (
+		wg       sync.WaitGroup
+		mu       sync.Mutex
+		firstErr error
+	)
+	for i, step := range steps {
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+		}
+		if ctx.Err() != nil {
+			break
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+			res, err := p.researchStep(ctx, span, question, step, scope)
+			if err != nil {
+				mu.Lock()
+				if firstErr == nil {
+					firstErr = err
+					cancel()
+				}
+				mu.Unlock()
+				return
+			}
+			results[i] = res
+		}()
+	}
+	wg.Wait()
+	if firstErr != nil {
+		return nil, firstErr
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+// researchStep generates the queries of one plan step and collects the evidence
+// they retrieve, keeping the best-scoring query for each chunk.
+func (p *Pipeline) researchStep(ctx context.Context, span oteltrace.Span, question string, step PlanStep, scope *RetrievalScope) (stepEvidence, error) {
+	var res stepEvidence
+	p.logger.Debug("research step started", "step", step.ID, "goal", trimForLog(step.Goal, 80))
+	queries, err := p.researcher.buildQueries(ctx, question, step)
+	if err != nil {
+		p.logger.Error("query generation failed", "step", step.ID, "error", err)
+		return res, err
+	}
+	p.logger.Debug("queries generated", "step", step.ID, "count", len(queries))
+	span.AddEvent("queries_generated", oteltrace.WithAttributes(attribute.String("step", step.ID), attribute.Int("count", len(queries))))
+
+	index := make(map[string]int)
+	for _, q := range queries {
+		results, err := p.search(ctx, q, scope)
+		if err != nil {
+			p.logger.Error("vector search failed", "step", step.ID, "error", err)
+			return res, fmt.Errorf("vector search failed: %w", err)
+		}
+		p.logger.Debug("retrieval results", "step", step.ID, "query", trimForLog(q, 80), "hits", len(results))
+		for _, candidate := range results {
+			doc, ok := p.retrieval.Document(candidate.Chunk.DocumentID)
+			if !ok {
+				continue
+			}
+			if !scope.Permits(scopeMetadata(&doc, candidate.Chunk)) {
+				res.denied++
+				continue
+			}
+			score := candidate.Score
+			if idx, ok := index[candidate.Chunk.ID]; ok {
+				if score > res.evidence[idx].Score {
+					res.evidence[idx].Score = score
+					res.evidence[idx].Query = q
+				}
+				continue
+			}
+			index[candidate.Chunk.ID] = len(res.evidence)
+			res.evidence = append(res.evidence, Evidence{
+				StepID:   step.ID,
+				Query:    q,
+				Document: &doc,
+				Chunk:    candidate.Chunk,
+				Score:    score,
+				Summary:  summarizeChunk(candidate.Chunk, 320),
+			})
+		}
+	}
+	return res, nil
+}
+
+// requiredEvidence returns how much evidence synthesis needs.
+func (p *Pipeline) requiredEvidence() int {
+	return max(p.cfg.MinEvidenceCount, 0)
+}
+
+// evidenceGate sends runs with insufficient evidence back to the planner while
+// re-planning rounds remain.
+func (p *Pipeline) evidenceGate(ctx context.Context, state graph.State) (string, error) {
+	st, err := getState(state)
+	if err != nil {
+		return "", err
+	}
+	if len(st.Evidence) >= p.requiredEvidence() || st.ReplanDone || st.ReplanRounds >= p.cfg.MaxReplanRounds {
+		return "enough", nil
+	}
+	return "replan", nil
+}
+
+// replanNode asks the planner for further steps after research came up short.
+func (p *Pipeline) replanNode(ctx context.Context, state graph.State) (graph.State, error) {
+	ctx, span := pipelineTracer.Start(ctx, "Pipeline.Replan")
+	var spanErr error
+	defer func() { telemetry.End(span, spanErr) }()
+	st, err := getState(state)
+	if err != nil {
+		spanErr = err
+		return state, err
+	}
+	st.Stage = "replan"
+	st.ReplanRounds++
+	span.SetAttributes(attribute.Int("replan.round", st.ReplanRounds))
+	p.logger.Info("replanning after insufficient evidence", "round", st.ReplanRounds, "have", len(st.Evidence), "required", p.requiredEvidence())
+
+	plan, err := p.planner.Replan(ctx, st.Question, st.History, st.Plan, len(st.Evidence), p.requiredEvidence(), st.ReplanRounds)
+	if err != nil {
+		if ctx.Err() != nil {
+			spanErr = err
+			return state, err
+		}
+		// A failed round ends re-planning; synthesis reports the missing evidence.
+		st.ReplanDone = true
+		p.logger.Warn("replanning failed", "round", st.ReplanRounds, "error", err)
+		span.AddEvent("replan_failed")
+		return state, nil
+	}
+	st.Plan.Steps = append(st.Plan.Steps, plan.Steps...)
+	span.SetAttributes(attribute.Int("plan.new_steps", len(plan.Steps)))
+	p.logger.Info("plan extended", "round", st.ReplanRounds, "new_steps", len(plan.Steps))
+	return state, nil
+}
+
 func (p *Pipeline) synthesizeNode(ctx context.Context, state graph.State) (graph.State, error) {
 	ctx, span := pipelineTracer.Start(ctx, "Pipeline.Synthesis")
 	var spanErr error
@@ -340,10 +702,8 @@ func (p *Pipeline) synthesizeNode(ctx context.Context, state graph.State) (graph
 		spanErr = err
 		return state, err
 	}
-	required := p.cfg.MinEvidenceCount
-	if required < 0 {
-		required = 0
-	}
+	st.Stage = "synthesis"
+	required := p.requiredEvidence()
 	p.logger.Info("synthesis started", "evidence_count", len(st.Evidence), "required", required)
 	span.SetAttributes(
 		attribute.Int("evidence.count", len(st.Evidence)),
@@ -355,11 +715,29 @@ func (p *Pipeline) synthesizeNode(ctx context.Context, state graph.State) (graph
 			fallback = "No supporting evidence was found for this question."
 		}
 		st.Draft = fallback
+		st.NoAnswer = true
 		p.logger.Warn("not enough evidence for synthesis", "have", len(st.Evidence), "required", required)
 		span.AddEvent("insufficient_evidence")
+		if st.OnToken != nil {
+			if err := st.OnToken(fallback); err != nil {
+				spanErr = err
+				return state, err
+			}
+		}
 		return state, nil
 	}
-	draft, err := p.writer.Compose(ctx, st.Question, st.Plan, st.Evidence)
+	draft, streamed, err := p.compose(ctx, st)
+	for retry := 0; err != nil && !streamed && retry < maxContextRetries && agent.IsContextLengthExceeded(err) && len(st.Evidence) > 1; retry++ {
+		drop := max(len(st.Evidence)/4, 1)
+		st.Evidence = dropLowestScored(st.Evidence, drop)
+		st.EvidenceDropped += drop
+		p.logger.Warn("synthesis exceeded context length, dropping evidence", "dropped", drop, "remaining", len(st.Evidence))
+		span.AddEvent("context_length_exceeded", oteltrace.WithAttributes(attribute.Int("evidence.dropped", drop)))
+		draft, streamed, err = p.compose(ctx, st)
+	}
+	if st.EvidenceDropped > 0 {
+		span.SetAttributes(attribute.Int("evidence.dropped", st.EvidenceDropped))
+	}
 	if err != nil {
 		spanErr = err
 		p.logger.Error("synthesis failed", "error", err)
@@ -371,11 +749,51 @@ func (p *Pipeline) synthesizeNode(ctx context.Context, state graph.State) (graph
 	return state, nil
 }
 
+// compose runs the writer, streaming its output when the run has a token
+// callback. It reports whether any output reached the callback.
+func (p *Pipeline) compose(ctx context.Context, st *pipelineState) (string, bool, error) {
+	if st.OnToken == nil {
+		draft, err := p.writer.Compose(ctx, st.Question, st.Plan, st.Evidence, st.History)
+		return draft, false, err
+	}
+	return p.writer.ComposeStream(ctx, st.Question, st.Plan, st.Evidence, st.History, st.OnToken)
+}
+
+// dropLowestScored removes the n lowest-scored items from evidence while
+// preserving the order of the rest.
+func dropLowestScored(evidence []Evidence, n int) []Evidence {
+	if n >= len(evidence) {
+		return nil
+	}
+	order := make([]int, len(evidence))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(i, j int) bool {
+		return evidence[order[i]].Score < evidence[order[j]].Score
+	})
+	dropped := make(map[int]struct{}, n)
+	for _, idx := range order[:n] {
+		dropped[idx] = struct{}{}
+	}
+	kept := make([]Evidence, 0, len(evidence)-n)
+	for i, ev := range evidence {
+		if _, ok := dropped[i]; !ok {
+			kept = append(kept, ev)
+		}
+	}
+	return kept
+}
+
 func (p *Pipeline) criticGate(ctx context.Context, state graph.State) (string, error) {
 	if !p.cfg.EnableCritic || p.critic == nil {
 		p.logger.Debug("critic skipped for run")
 		return "skip", nil
 	}
+	if st, err := getState(state); err == nil && st.DeferCritic {
+		p.logger.Debug("critic deferred for streaming run")
+		return "skip", nil
+	}
 	p.logger.Debug("critic enabled for run")
 	return "run", nil
 }
@@ -389,6 +807,7 @@ func (p *Pipeline) criticNode(ctx context.Context, state graph.State) (graph.Sta
 		spanErr = err
 		return state, err
 	}
+	st.Stage = "critic"
 	if p.critic == nil {
 		return state, nil
 	}
@@ -407,6 +826,42 @@ func (p *Pipeline) criticNode(ctx context.Context, state graph.State) (graph.Sta
 	return state, nil
 }
 
+func (p *Pipeline) groundingNode(ctx context.Context, state graph.State) (graph.State, error) {
+	st, err := getState(state)
+	if err != nil {
+		return state, err
+	}
+	// Deferred runs check grounding after the asynchronous critic settles the answer.
+	if p.grounding == nil || st.NoAnswer || st.DeferCritic {
+		return state, nil
+	}
+	ctx, span := pipelineTracer.Start(ctx, "Pipeline.Grounding")
+	var spanErr error
+	defer func() { telemetry.End(span, spanErr) }()
+	st.Stage = "grounding"
+
+	answer := st.Draft
+	if st.Critic != nil && st.Critic.FinalAnswer != "" {
+		answer = st.Critic.FinalAnswer
+	}
+	p.logger.Info("grounding check started")
+	report, err := p.grounding.Check(ctx, answer, st.Evidence)
+	if err != nil {
+		spanErr = err
+		p.logger.Error("grounding check failed", "error", err)
+		return state, err
+	}
+	st.Grounding = report
+	if report != nil {
+		span.SetAttributes(
+			attribute.Float64("grounding.score", report.Score),
+			attribute.Int("grounding.unsupported", len(report.Unsupported)),
+		)
+		p.logger.Info("grounding check completed", "score", report.Score, "unsupported", len(report.Unsupported))
+	}
+	return state, nil
+}
+
 func (p *Pipeline) endNode(ctx context.Context, state graph.State) (graph.State, error) {
 	_, err := getState(state)
 	return state, err
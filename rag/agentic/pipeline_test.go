@@ -2,13 +2,20 @@ package agentic
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"iter"
 	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/sweetpotato0/ai-allin/agent"
 	"github.com/sweetpotato0/ai-allin/contrib/vector/inmemory"
 	"github.com/sweetpotato0/ai-allin/message"
 	"github.com/sweetpotato0/ai-allin/rag/document"
+	"github.com/sweetpotato0/ai-allin/session"
+	"github.com/sweetpotato0/ai-allin/vector"
 )
 
 func TestPipelineRunProducesResponse(t *testing.T) {
@@ -226,13 +233,561 @@ func TestPipelineKeepsMultipleChunksPerDocument(t *testing.T) {
 	}
 }
 
+func TestPipelineRunStreamAsyncCritic(t *testing.T) {
+	ctx := context.Background()
+
+	planLLM := &stubLLM{
+		response: `{"strategy":"baseline","steps":[{"id":"step-1","goal":"Check shipping policy","questions":["shipping policy"]}]}`,
+	}
+	writerLLM := &stubLLM{response: "Draft shipping answer."}
+	criticLLM := &stubLLM{response: `{"verdict":"revise","issues":["missing timeline"],"final_answer":"Revised shipping answer."}`}
+
+	pipe, err := NewPipeline(
+		Clients{Planner: planLLM, Writer: writerLLM, Critic: criticLLM},
+		&keywordEmbedder{},
+		inmemory.NewInMemoryVectorStore(),
+		WithAsyncCritic(true),
+	)
+	if err != nil {
+		t.Fatalf("NewPipeline error: %v", err)
+	}
+	if err := pipe.IndexDocuments(ctx, Document{ID: "shipping", Title: "Shipping", Content: "Shipping policy details."}); err != nil {
+		t.Fatalf("IndexDocuments error: %v", err)
+	}
+
+	var events []*StreamEvent
+	for ev, err := range pipe.RunStream(ctx, "What is the shipping policy?") {
+		if err != nil {
+			t.Fatalf("stream error: %v", err)
+		}
+		events = append(events, ev)
+	}
+
+	if len(events) != 2 {
+		t.Fatalf("expected draft and critic events, got %d", len(events))
+	}
+	if events[0].Type != StreamEventDraft || events[0].Response.FinalAnswer != "Draft shipping answer." {
+		t.Fatalf("unexpected draft event: %#v", events[0])
+	}
+	if events[0].Response.Critic != nil {
+		t.Fatalf("draft event should not carry critic feedback")
+	}
+	if events[1].Type != StreamEventCritic || events[1].Response.FinalAnswer != "Revised shipping answer." {
+		t.Fatalf("unexpected critic event: %#v", events[1])
+	}
+	if criticLLM.calls != 1 {
+		t.Fatalf("expected critic to run once, got %d", criticLLM.calls)
+	}
+}
+
+func TestPipelineRunStreamAsyncCriticSharesRunTimeout(t *testing.T) {
+	ctx := context.Background()
+	writerLLM := &deadlineLLM{stubLLM: stubLLM{response: "Draft shipping answer."}}
+	criticLLM := &deadlineLLM{stubLLM: stubLLM{response: `{"verdict":"accept","final_answer":"Draft shipping answer."}`}}
+	pipe, err := NewPipeline(
+		Clients{
+			Planner: &stubLLM{response: `{"strategy":"baseline","steps":[{"id":"step-1","goal":"shipping policy"}]}`},
+			Writer:  writerLLM,
+			Critic:  criticLLM,
+		},
+		&keywordEmbedder{},
+		inmemory.NewInMemoryVectorStore(),
+		WithAsyncCritic(true),
+		WithRunTimeout(time.Minute),
+	)
+	if err != nil {
+		t.Fatalf("NewPipeline error: %v", err)
+	}
+	if err := pipe.IndexDocuments(ctx, Document{ID: "shipping", Title: "Shipping", Content: "Shipping policy details."}); err != nil {
+		t.Fatalf("IndexDocuments error: %v", err)
+	}
+
+	for _, err := range pipe.RunStream(ctx, "What is the shipping policy?") {
+		if err != nil {
+			t.Fatalf("stream error: %v", err)
+		}
+	}
+	if writerLLM.deadline.IsZero() || !criticLLM.deadline.Equal(writerLLM.deadline) {
+		t.Fatalf("expected the critic to share the run's deadline %v, got %v", writerLLM.deadline, criticLLM.deadline)
+	}
+}
+
+func TestPipelineRunStreamWithoutAsyncCritic(t *testing.T) {
+	ctx := context.Background()
+
+	pipe, err := NewPipeline(
+		Clients{
+			Planner: &stubLLM{response: `{"strategy":"baseline","steps":[{"id":"step-1","goal":"returns"}]}`},
+			Writer:  &stubLLM{response: "Return answer."},
+			Critic:  &stubLLM{response: `{"verdict":"approve","final_answer":"Return answer."}`},
+		},
+		&keywordEmbedder{},
+		inmemory.NewInMemoryVectorStore(),
+	)
+	if err != nil {
+		t.Fatalf("NewPipeline error: %v", err)
+	}
+	if err := pipe.IndexDocuments(ctx, Document{ID: "returns", Title: "Returns", Content: "Return policy details."}); err != nil {
+		t.Fatalf("IndexDocuments error: %v", err)
+	}
+
+	var events []*StreamEvent
+	for ev, err := range pipe.RunStream(ctx, "What is the return policy?") {
+		if err != nil {
+			t.Fatalf("stream error: %v", err)
+		}
+		events = append(events, ev)
+	}
+	if len(events) != 1 || events[0].Type != StreamEventFinal {
+		t.Fatalf("expected a single final event, got %#v", events)
+	}
+	if events[0].Response.Critic == nil {
+		t.Fatalf("expected critic feedback on final event")
+	}
+}
+
+func TestPipelineRunWithScopeFiltersEvidence(t *testing.T) {
+	ctx := context.Background()
+
+	retr := newStubRetrieval([]RetrievalResult{
+		{Chunk: document.Chunk{ID: "a_1", DocumentID: "tenant-a", Content: "Tenant A shipping policy."}, Score: 0.9},
+		{Chunk: document.Chunk{ID: "b_1", DocumentID: "tenant-b", Content: "Tenant B shipping policy."}, Score: 0.8},
+	})
+	pipe, err := NewPipeline(
+		Clients{
+			Planner: &stubLLM{response: `{"strategy":"baseline","steps":[{"id":"step-1","goal":"shipping policy"}]}`},
+			Writer:  &stubLLM{response: "Scoped answer."},
+		},
+		nil,
+		nil,
+		WithRetriever(retr),
+		WithCritic(false),
+	)
+	if err != nil {
+		t.Fatalf("NewPipeline error: %v", err)
+	}
+	err = pipe.IndexDocuments(ctx,
+		Document{ID: "tenant-a", Title: "A", Content: "Tenant A shipping policy.", Metadata: map[string]any{"tenant": "a"}},
+		Document{ID: "tenant-b", Title: "B", Content: "Tenant B shipping policy.", Metadata: map[string]any{"tenant": "b"}},
+	)
+	if err != nil {
+		t.Fatalf("IndexDocuments error: %v", err)
+	}
+
+	resp, err := pipe.RunWithScope(ctx, "shipping policy?", NewRetrievalScope("tenant", "a"))
+	if err != nil {
+		t.Fatalf("pipeline run failed: %v", err)
+	}
+	if len(resp.Evidence) != 1 || resp.Evidence[0].Document.ID != "tenant-a" {
+		t.Fatalf("expected only tenant-a evidence, got %#v", resp.Evidence)
+	}
+
+	resp, err = pipe.RunWithScope(ctx, "shipping policy?", &RetrievalScope{})
+	if err != nil {
+		t.Fatalf("pipeline run failed: %v", err)
+	}
+	if len(resp.Evidence) != 0 {
+		t.Fatalf("expected empty scope to deny all evidence, got %d", len(resp.Evidence))
+	}
+
+	resp, err = pipe.Run(ctx, "shipping policy?")
+	if err != nil {
+		t.Fatalf("pipeline run failed: %v", err)
+	}
+	if len(resp.Evidence) != 2 {
+		t.Fatalf("expected unscoped run to return all evidence, got %d", len(resp.Evidence))
+	}
+}
+
+func TestPipelineRunWithRetry(t *testing.T) {
+	ctx := context.Background()
+
+	planLLM := &flakyLLM{
+		stubLLM:  stubLLM{response: `{"strategy":"baseline","steps":[{"id":"step-1","goal":"returns"}]}`},
+		failures: []error{agent.NewAPIError("test", 503, errors.New("unavailable"))},
+	}
+	pipe, err := NewPipeline(
+		Clients{Planner: planLLM, Writer: &stubLLM{response: "Return answer."}},
+		&keywordEmbedder{},
+		inmemory.NewInMemoryVectorStore(),
+		WithCritic(false),
+	)
+	if err != nil {
+		t.Fatalf("NewPipeline error: %v", err)
+	}
+	if err := pipe.IndexDocuments(ctx, Document{ID: "returns", Title: "Returns", Content: "Return policy details."}); err != nil {
+		t.Fatalf("IndexDocuments error: %v", err)
+	}
+
+	policy := agent.RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Millisecond}
+	resp, err := pipe.RunWithRetry(ctx, "What is the return policy?", policy)
+	if err != nil {
+		t.Fatalf("expected retry to succeed, got %v", err)
+	}
+	if resp.FinalAnswer != "Return answer." {
+		t.Fatalf("unexpected answer %q", resp.FinalAnswer)
+	}
+	if planLLM.calls != 2 {
+		t.Fatalf("expected 2 planner calls, got %d", planLLM.calls)
+	}
+
+	planLLM.calls = 0
+	planLLM.failures = []error{agent.NewAPIError("test", 400, errors.New("bad request"))}
+	if _, err := pipe.RunWithRetry(ctx, "What is the return policy?", policy); err == nil {
+		t.Fatalf("expected non-retriable error to surface")
+	}
+	if planLLM.calls != 1 {
+		t.Fatalf("expected no retry for non-retriable error, got %d calls", planLLM.calls)
+	}
+
+	planLLM.failures = []error{agent.NewAPIError("test", 503, errors.New("unavailable"))}
+	shortCtx, cancel := context.WithTimeout(ctx, 20*time.Millisecond)
+	defer cancel()
+	_, err = pipe.RunWithRetry(shortCtx, "What is the return policy?", agent.RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Hour})
+	var apiErr *agent.APIError
+	if !errors.Is(err, context.DeadlineExceeded) || !errors.As(err, &apiErr) {
+		t.Fatalf("expected an aborted retry to wrap both the context and the last error, got %v", err)
+	}
+}
+
+func TestPipelineSynthesisDropsEvidenceOnContextLengthError(t *testing.T) {
+	ctx := context.Background()
+
+	retr := newStubRetrieval([]RetrievalResult{
+		{Chunk: document.Chunk{ID: "a_1", DocumentID: "a", Content: "Shipping takes two days."}, Score: 0.9},
+		{Chunk: document.Chunk{ID: "b_1", DocumentID: "b", Content: "Shipping is free over $50."}, Score: 0.2},
+		{Chunk: document.Chunk{ID: "c_1", DocumentID: "c", Content: "Shipping uses tracked mail."}, Score: 0.7},
+		{Chunk: document.Chunk{ID: "d_1", DocumentID: "d", Content: "Shipping to islands costs extra."}, Score: 0.5},
+	})
+	writer := &flakyLLM{
+		stubLLM:  stubLLM{response: "Shipping takes two days."},
+		failures: []error{agent.NewAPIError("test", 400, errors.New("maximum context length exceeded"))},
+	}
+	pipe, err := NewPipeline(
+		Clients{
+			Planner: &stubLLM{response: `{"strategy":"baseline","steps":[{"id":"step-1","goal":"shipping"}]}`},
+			Writer:  writer,
+		},
+		nil,
+		nil,
+		WithRetriever(retr),
+		WithCritic(false),
+	)
+	if err != nil {
+		t.Fatalf("NewPipeline error: %v", err)
+	}
+	for _, id := range []string{"a", "b", "c", "d"} {
+		if err := pipe.IndexDocuments(ctx, Document{ID: id, Title: id, Content: "Shipping details."}); err != nil {
+			t.Fatalf("IndexDocuments error: %v", err)
+		}
+	}
+
+	resp, err := pipe.Run(ctx, "How does shipping work?")
+	if err != nil {
+		t.Fatalf("pipeline run failed: %v", err)
+	}
+	if writer.calls != 2 {
+		t.Fatalf("expected synthesis to be retried once, got %d calls", writer.calls)
+	}
+	if resp.EvidenceDropped != 1 || len(resp.Evidence) != 3 {
+		t.Fatalf("expected 1 dropped and 3 kept, got dropped=%d kept=%d", resp.EvidenceDropped, len(resp.Evidence))
+	}
+	for _, ev := range resp.Evidence {
+		if ev.Chunk.ID == "b_1" {
+			t.Fatalf("expected lowest-scored evidence to be dropped, got %#v", resp.Evidence)
+		}
+	}
+
+	writer.calls = 0
+	writer.failures = []error{agent.NewAPIError("test", 400, errors.New("invalid request"))}
+	if _, err := pipe.Run(ctx, "How does shipping work?"); err == nil {
+		t.Fatalf("expected other errors to surface without retry")
+	}
+	if writer.calls != 1 {
+		t.Fatalf("expected no synthesis retry for other errors, got %d calls", writer.calls)
+	}
+}
+
+func TestPipelineNearDuplicateDedup(t *testing.T) {
+	ctx := context.Background()
+
+	results := []RetrievalResult{
+		{Chunk: document.Chunk{ID: "faq_1", DocumentID: "faq", Content: "Returns are accepted within 30 days."}, Score: 0.6},
+		{Chunk: document.Chunk{ID: "copy_1", DocumentID: "copy", Content: "returns are accepted  within 30 days"}, Score: 0.8},
+		{Chunk: document.Chunk{ID: "ship_1", DocumentID: "ship", Content: "Shipping policy: orders leave in two days."}, Score: 0.7},
+		{Chunk: document.Chunk{ID: "ship_2", DocumentID: "ship2", Content: "Our shipping policy ships orders within two days."}, Score: 0.5},
+	}
+	run := func(threshold float32) *Response {
+		t.Helper()
+		pipe, err := NewPipeline(
+			Clients{
+				Planner: &stubLLM{response: `{"strategy":"baseline","steps":[{"id":"step-1","goal":"returns"}]}`},
+				Writer:  &stubLLM{response: "Answer."},
+			},
+			&keywordEmbedder{},
+			nil,
+			WithRetriever(newStubRetrieval(results)),
+			WithCritic(false),
+			WithNearDuplicateDedup(threshold),
+		)
+		if err != nil {
+			t.Fatalf("NewPipeline error: %v", err)
+		}
+		for _, id := range []string{"faq", "copy", "ship", "ship2"} {
+			if err := pipe.IndexDocuments(ctx, Document{ID: id, Title: id, Content: "placeholder"}); err != nil {
+				t.Fatalf("IndexDocuments error: %v", err)
+			}
+		}
+		resp, err := pipe.Run(ctx, "What is the return policy?")
+		if err != nil {
+			t.Fatalf("pipeline run failed: %v", err)
+		}
+		return resp
+	}
+	ids := func(resp *Response) string {
+		var out []string
+		for _, ev := range resp.Evidence {
+			out = append(out, ev.Chunk.ID)
+		}
+		return strings.Join(out, ",")
+	}
+
+	if got := ids(run(0)); got != "faq_1,copy_1,ship_1,ship_2" {
+		t.Fatalf("expected no dedup when disabled, got %s", got)
+	}
+	if got := ids(run(1)); got != "copy_1,ship_1,ship_2" {
+		t.Fatalf("expected normalised copies collapsed to the best score, got %s", got)
+	}
+	if got := ids(run(0.95)); got != "copy_1,ship_1" {
+		t.Fatalf("expected embedding-similar evidence collapsed, got %s", got)
+	}
+}
+
+func TestPipelineRunInSessionCarriesHistory(t *testing.T) {
+	ctx := context.Background()
+
+	retr := newStubRetrieval([]RetrievalResult{
+		{Chunk: document.Chunk{ID: "warranty_1", DocumentID: "warranty", Content: "Warranty lasts two years."}, Score: 0.9},
+	})
+	planLLM := &stubLLM{response: `{"strategy":"baseline","steps":[{"id":"step-1","goal":"warranty"}]}`}
+	writerLLM := &stubLLM{response: "The warranty lasts two years."}
+	pipe, err := NewPipeline(
+		Clients{Planner: planLLM, Writer: writerLLM},
+		nil,
+		nil,
+		WithRetriever(retr),
+		WithCritic(false),
+	)
+	if err != nil {
+		t.Fatalf("NewPipeline error: %v", err)
+	}
+	if err := pipe.IndexDocuments(ctx, Document{ID: "warranty", Title: "Warranty", Content: "Warranty lasts two years."}); err != nil {
+		t.Fatalf("IndexDocuments error: %v", err)
+	}
+
+	sess := session.NewShared("rag-session")
+	if _, err := pipe.RunInSession(ctx, sess, "How long is the warranty?"); err != nil {
+		t.Fatalf("first turn failed: %v", err)
+	}
+
+	retr.results = nil
+	resp, err := pipe.RunInSession(ctx, sess, "Tell me more about that.")
+	if err != nil {
+		t.Fatalf("follow-up turn failed: %v", err)
+	}
+	if len(resp.Evidence) != 1 || resp.Evidence[0].StepID != "history" {
+		t.Fatalf("expected prior evidence to be carried forward, got %#v", resp.Evidence)
+	}
+	if !strings.Contains(planLLM.last.Messages[1].Text(), "How long is the warranty?") {
+		t.Fatalf("expected planner prompt to include prior question, got %q", planLLM.last.Messages[1].Text())
+	}
+
+	conv, err := ConversationFromSession(sess)
+	if err != nil {
+		t.Fatalf("ConversationFromSession error: %v", err)
+	}
+	if len(conv.Turns) != 2 {
+		t.Fatalf("expected 2 stored turns, got %d", len(conv.Turns))
+	}
+	if len(conv.Turns[1].Evidence) != 0 {
+		t.Fatalf("expected carried-over evidence not to be stored again, got %#v", conv.Turns[1].Evidence)
+	}
+}
+
+func TestPipelineRunInSessionStoresTopEvidence(t *testing.T) {
+	ctx := context.Background()
+
+	var results []RetrievalResult
+	for i, score := range []float32{0.2, 0.9, 0.5, 0.7} {
+		id := fmt.Sprintf("manual_%d", i)
+		results = append(results, RetrievalResult{Chunk: document.Chunk{ID: id, DocumentID: "manual", Content: "Section " + id}, Score: score})
+	}
+	pipe, err := NewPipeline(
+		Clients{
+			Planner: &stubLLM{response: `{"strategy":"baseline","steps":[{"id":"step-1","goal":"manual"}]}`},
+			Writer:  &stubLLM{response: "See the manual."},
+		},
+		nil,
+		nil,
+		WithRetriever(newStubRetrieval(results)),
+		WithCritic(false),
+		WithMaxHistoryEvidence(2),
+	)
+	if err != nil {
+		t.Fatalf("NewPipeline error: %v", err)
+	}
+	if err := pipe.IndexDocuments(ctx, Document{ID: "manual", Title: "Manual", Content: strings.Repeat("long manual text ", 100)}); err != nil {
+		t.Fatalf("IndexDocuments error: %v", err)
+	}
+
+	sess := session.NewShared("rag-session")
+	resp, err := pipe.RunInSession(ctx, sess, "What does the manual say?")
+	if err != nil {
+		t.Fatalf("RunInSession error: %v", err)
+	}
+	if len(resp.Evidence) != 4 {
+		t.Fatalf("expected the response to keep all evidence, got %d", len(resp.Evidence))
+	}
+	conv, err := ConversationFromSession(sess)
+	if err != nil {
+		t.Fatalf("ConversationFromSession error: %v", err)
+	}
+	stored := conv.Turns[0].Evidence
+	if len(stored) != 2 || stored[0].Chunk.ID != "manual_1" || stored[1].Chunk.ID != "manual_3" {
+		t.Fatalf("expected the two highest scoring items to be stored, got %#v", stored)
+	}
+	for _, ev := range stored {
+		if ev.Document == nil || ev.Document.Title != "Manual" || ev.Document.Content != "" {
+			t.Fatalf("expected stored documents without content, got %#v", ev.Document)
+		}
+	}
+	for _, ev := range resp.Evidence {
+		if ev.Document != nil && ev.Document.Content == "" {
+			t.Fatal("stripping stored documents must not modify the response")
+		}
+	}
+}
+
+func TestPipelineRunTimeoutNamesStage(t *testing.T) {
+	ctx := context.Background()
+
+	pipe, err := NewPipeline(
+		Clients{
+			Planner: &stubLLM{response: `{"strategy":"baseline","steps":[{"id":"step-1","goal":"returns"}]}`},
+			Writer:  &blockingLLM{},
+		},
+		&keywordEmbedder{},
+		inmemory.NewInMemoryVectorStore(),
+		WithCritic(false),
+		WithRunTimeout(20*time.Millisecond),
+	)
+	if err != nil {
+		t.Fatalf("NewPipeline error: %v", err)
+	}
+	if err := pipe.IndexDocuments(ctx, Document{ID: "returns", Title: "Returns", Content: "Return policy details."}); err != nil {
+		t.Fatalf("IndexDocuments error: %v", err)
+	}
+
+	_, err = pipe.Run(ctx, "What is the return policy?")
+	var timeoutErr *TimeoutError
+	if !errors.As(err, &timeoutErr) {
+		t.Fatalf("expected TimeoutError, got %v", err)
+	}
+	if timeoutErr.Stage != "synthesis" {
+		t.Fatalf("expected synthesis stage, got %q", timeoutErr.Stage)
+	}
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected timeout to match context.DeadlineExceeded")
+	}
+}
+
+func TestPipelineGroundingCheck(t *testing.T) {
+	ctx := context.Background()
+
+	groundingLLM := &stubLLM{
+		response: `{"sentences":[{"sentence":"Returns are accepted within 30 days.","supported":true,"citations":["returns"]},{"sentence":"Refunds arrive instantly.","supported":false}]}`,
+	}
+	pipe, err := NewPipeline(
+		Clients{
+			Planner:   &stubLLM{response: `{"strategy":"baseline","steps":[{"id":"step-1","goal":"returns","questions":["returns policy"]}]}`},
+			Writer:    &stubLLM{response: "Returns are accepted within 30 days. Refunds arrive instantly."},
+			Grounding: groundingLLM,
+		},
+		&keywordEmbedder{},
+		inmemory.NewInMemoryVectorStore(),
+		WithCritic(false),
+		WithGroundingCheck(true),
+	)
+	if err != nil {
+		t.Fatalf("NewPipeline error: %v", err)
+	}
+	if err := pipe.IndexDocuments(ctx, Document{ID: "returns", Title: "Returns", Content: "Return policy: returns accepted within 30 days."}); err != nil {
+		t.Fatalf("IndexDocuments error: %v", err)
+	}
+
+	resp, err := pipe.Run(ctx, "What is the return policy?")
+	if err != nil {
+		t.Fatalf("pipeline run failed: %v", err)
+	}
+	if resp.Grounding == nil {
+		t.Fatalf("expected grounding report")
+	}
+	if resp.Grounding.Score != 0.5 {
+		t.Errorf("expected grounding score 0.5, got %v", resp.Grounding.Score)
+	}
+	if len(resp.Grounding.Unsupported) != 1 || resp.Grounding.Unsupported[0] != "Refunds arrive instantly." {
+		t.Errorf("unexpected unsupported sentences %v", resp.Grounding.Unsupported)
+	}
+	if groundingLLM.calls != 1 || !strings.Contains(groundingLLM.last.Messages[1].Text(), "Refunds arrive instantly.") {
+		t.Errorf("expected grounding checker to receive the final answer")
+	}
+}
+
+// deadlineLLM records the deadline of the context it is called with.
+type deadlineLLM struct {
+	stubLLM
+	deadline time.Time
+}
+
+func (d *deadlineLLM) Generate(ctx context.Context, req *agent.GenerateRequest) (*agent.GenerateResponse, error) {
+	d.deadline, _ = ctx.Deadline()
+	return d.stubLLM.Generate(ctx, req)
+}
+
+// blockingLLM waits until the context is cancelled.
+type blockingLLM struct {
+	stubLLM
+}
+
+func (b *blockingLLM) Generate(ctx context.Context, req *agent.GenerateRequest) (*agent.GenerateResponse, error) {
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
+type flakyLLM struct {
+	stubLLM
+	failures []error
+}
+
+func (f *flakyLLM) Generate(ctx context.Context, req *agent.GenerateRequest) (*agent.GenerateResponse, error) {
+	if len(f.failures) > 0 {
+		err := f.failures[0]
+		f.failures = f.failures[1:]
+		f.calls++
+		return nil, err
+	}
+	return f.stubLLM.Generate(ctx, req)
+}
+
 type stubLLM struct {
 	response string
 	calls    int
+	last     *agent.GenerateRequest
 }
 
 func (s *stubLLM) Generate(ctx context.Context, req *agent.GenerateRequest) (*agent.GenerateResponse, error) {
 	s.calls++
+	s.last = req
 	msg := message.NewMessage(message.RoleAssistant, s.response)
 	msg.Completed = true
 	return &agent.GenerateResponse{Message: msg}, nil
@@ -296,11 +851,28 @@ func (s *stubRetrieval) Search(ctx context.Context, query string) ([]RetrievalRe
 	return s.results, nil
 }
 
+func (s *stubRetrieval) SearchWithFilter(ctx context.Context, query string, filter map[string]any) ([]RetrievalResult, error) {
+	var out []RetrievalResult
+	for _, res := range s.results {
+		if doc, ok := s.docs[res.Chunk.DocumentID]; ok && vector.MatchesFilter(doc.Metadata, filter) {
+			out = append(out, res)
+		}
+	}
+	return out, nil
+}
+
 func (s *stubRetrieval) Document(id string) (document.Document, bool) {
 	doc, ok := s.docs[id]
 	return doc, ok
 }
 
+func (s *stubRetrieval) Delete(ctx context.Context, ids ...string) error {
+	for _, id := range ids {
+		delete(s.docs, id)
+	}
+	return nil
+}
+
 func (s *stubRetrieval) Clear(ctx context.Context) error {
 	s.docs = make(map[string]document.Document)
 	return nil
@@ -309,3 +881,429 @@ func (s *stubRetrieval) Clear(ctx context.Context) error {
 func (s *stubRetrieval) Count(ctx context.Context) (int, error) {
 	return len(s.docs), nil
 }
+
+// streamingLLM streams its response in chunks before the completed message.
+type streamingLLM struct {
+	stubLLM
+	chunks []string
+}
+
+func (s *streamingLLM) GenerateStream(ctx context.Context, req *agent.GenerateRequest) iter.Seq2[*agent.GenerateResponse, error] {
+	return func(yield func(*agent.GenerateResponse, error) bool) {
+		s.calls++
+		for _, chunk := range s.chunks {
+			if !yield(&agent.GenerateResponse{Message: message.NewMessage(message.RoleAssistant, chunk)}, nil) {
+				return
+			}
+		}
+		msg := message.NewMessage(message.RoleAssistant, strings.Join(s.chunks, ""))
+		msg.Completed = true
+		yield(&agent.GenerateResponse{Message: msg}, nil)
+	}
+}
+
+func TestPipelineRunStreamTokens(t *testing.T) {
+	ctx := context.Background()
+	newPipe := func(writer agent.LLMClient, critic string) *Pipeline {
+		opts := []Option{}
+		if critic == "" {
+			opts = append(opts, WithCritic(false))
+		}
+		pipe, err := NewPipeline(
+			Clients{
+				Planner: &stubLLM{response: `{"strategy":"baseline","steps":[{"id":"step-1","goal":"shipping policy"}]}`},
+				Writer:  writer,
+				Critic:  &stubLLM{response: critic},
+			},
+			&keywordEmbedder{},
+			inmemory.NewInMemoryVectorStore(),
+			opts...,
+		)
+		if err != nil {
+			t.Fatalf("NewPipeline error: %v", err)
+		}
+		if err := pipe.IndexDocuments(ctx, Document{ID: "shipping", Title: "Shipping", Content: "Shipping policy details."}); err != nil {
+			t.Fatalf("IndexDocuments error: %v", err)
+		}
+		return pipe
+	}
+
+	writer := &streamingLLM{chunks: []string{"Ships ", "in ", "2 days."}}
+	pipe := newPipe(writer, `{"verdict":"revise","final_answer":"Ships in 2 business days."}`)
+	var tokens []string
+	var revised *Response
+	resp, err := pipe.RunStreamTokens(ctx, "What is the shipping policy?", func(token string) error {
+		tokens = append(tokens, token)
+		return nil
+	}, func(r *Response) error {
+		if len(tokens) != 3 {
+			t.Errorf("expected the revision after the stream, got it after %d tokens", len(tokens))
+		}
+		revised = r
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("RunStreamTokens error: %v", err)
+	}
+	if strings.Join(tokens, "|") != "Ships |in |2 days." || resp.DraftAnswer != "Ships in 2 days." {
+		t.Fatalf("expected streamed draft tokens, got %q (draft %q)", tokens, resp.DraftAnswer)
+	}
+	if revised == nil || revised.FinalAnswer != "Ships in 2 business days." || resp.FinalAnswer != revised.FinalAnswer {
+		t.Fatalf("expected critic revision to be delivered, got %#v", revised)
+	}
+
+	tokens = nil
+	pipe = newPipe(&stubLLM{response: "Whole answer."}, "")
+	if _, err := pipe.RunStreamTokens(ctx, "What is the shipping policy?", func(token string) error {
+		tokens = append(tokens, token)
+		return nil
+	}, nil); err != nil || len(tokens) != 1 || tokens[0] != "Whole answer." {
+		t.Fatalf("expected non-streaming writer to deliver one token, got %q (%v)", tokens, err)
+	}
+
+	stop := errors.New("client went away")
+	pipe = newPipe(&streamingLLM{chunks: []string{"a", "b"}}, "")
+	if _, err := pipe.RunStreamTokens(ctx, "What is the shipping policy?", func(string) error { return stop }, nil); !errors.Is(err, stop) {
+		t.Fatalf("expected callback error to abort the run, got %v", err)
+	}
+}
+
+func TestPipelineParsesCitations(t *testing.T) {
+	ctx := context.Background()
+	answer := "Ships in 2 days [shipping]. Labels are free [Doc:shipping; ghost, ghost] and tracked [shipping]. See [the docs](https://example.com) [not a citation]."
+	pipe, err := NewPipeline(
+		Clients{
+			Planner: &stubLLM{response: `{"strategy":"baseline","steps":[{"id":"step-1","goal":"shipping policy"}]}`},
+			Writer:  &stubLLM{response: answer},
+		},
+		&keywordEmbedder{},
+		inmemory.NewInMemoryVectorStore(),
+		WithCritic(false),
+		WithCitationParsing(true),
+	)
+	if err != nil {
+		t.Fatalf("NewPipeline error: %v", err)
+	}
+	if err := pipe.IndexDocuments(ctx, Document{ID: "shipping", Title: "Shipping", Content: "Shipping policy details."}); err != nil {
+		t.Fatalf("IndexDocuments error: %v", err)
+	}
+
+	resp, err := pipe.Run(ctx, "What is the shipping policy?")
+	if err != nil {
+		t.Fatalf("Run error: %v", err)
+	}
+	var got []string
+	for _, c := range resp.Citations {
+		got = append(got, fmt.Sprintf("%s=%s/%v/%d", c.DocID, resp.FinalAnswer[c.Start:c.End], c.Resolved, len(c.Evidence)))
+	}
+	want := "[shipping=[shipping]/true/2 shipping=[Doc:shipping; ghost, ghost]/true/2 ghost=[Doc:shipping; ghost, ghost]/false/0 shipping=[shipping]/true/2]"
+	if fmt.Sprint(got) != want {
+		t.Fatalf("unexpected citations:\n got %v\nwant %s", got, want)
+	}
+	if ev := resp.Evidence[resp.Citations[0].Evidence[0]]; ev.Chunk.DocumentID != "shipping" {
+		t.Fatalf("expected citation to reference shipping evidence, got %s", ev.Chunk.DocumentID)
+	}
+}
+
+// sequenceLLM answers with its responses in order, repeating the last one.
+type sequenceLLM struct {
+	stubLLM
+	responses []string
+}
+
+func (s *sequenceLLM) Generate(ctx context.Context, req *agent.GenerateRequest) (*agent.GenerateResponse, error) {
+	s.response = s.responses[min(s.calls, len(s.responses)-1)]
+	return s.stubLLM.Generate(ctx, req)
+}
+
+func TestPipelineReplansWhenEvidenceIsInsufficient(t *testing.T) {
+	ctx := context.Background()
+	offTopic := `{"strategy":"baseline","steps":[{"id":"step-1","goal":"escalation process"}]}`
+	onTopic := `{"strategy":"retry","steps":[{"id":"step-1","goal":"shipping policy"}]}`
+	newPipe := func(planner agent.LLMClient, writer agent.LLMClient) *Pipeline {
+		pipe, err := NewPipeline(
+			Clients{Planner: planner, Writer: writer},
+			&keywordEmbedder{},
+			inmemory.NewInMemoryVectorStore(),
+			WithCritic(false),
+			WithMaxReplanRounds(2),
+		)
+		if err != nil {
+			t.Fatalf("NewPipeline error: %v", err)
+		}
+		if err := pipe.IndexDocuments(ctx, Document{ID: "shipping", Title: "Shipping", Content: "Shipping policy details."}); err != nil {
+			t.Fatalf("IndexDocuments error: %v", err)
+		}
+		return pipe
+	}
+
+	planner := &sequenceLLM{responses: []string{offTopic, onTopic}}
+	writer := &stubLLM{response: "Ships in 2 days [shipping]."}
+	resp, err := newPipe(planner, writer).Run(ctx, "How does it work?")
+	if err != nil {
+		t.Fatalf("Run error: %v", err)
+	}
+	if resp.ReplanRounds != 1 || planner.calls != 2 || writer.calls != 1 || len(resp.Evidence) == 0 {
+		t.Fatalf("expected one replan round to find evidence, got %d rounds, %d planner calls, %d evidence", resp.ReplanRounds, planner.calls, len(resp.Evidence))
+	}
+	if len(resp.Plan.Steps) != 2 || resp.Plan.Steps[1].ID != "replan-1-step-1" {
+		t.Fatalf("expected replanned step to be appended with a distinct id, got %#v", resp.Plan.Steps)
+	}
+	if !strings.Contains(planner.last.Messages[1].Text(), "retrieved only 0 of the 1 evidence items") {
+		t.Fatalf("expected the evidence gap to be fed back to the planner, got %q", planner.last.Messages[1].Text())
+	}
+
+	planner = &sequenceLLM{responses: []string{offTopic}}
+	writer = &stubLLM{response: "unused"}
+	resp, err = newPipe(planner, writer).Run(ctx, "How does it work?")
+	if err != nil {
+		t.Fatalf("Run error: %v", err)
+	}
+	if resp.ReplanRounds != 2 || planner.calls != 3 || writer.calls != 0 {
+		t.Fatalf("expected re-planning to stop at the round cap, got %d rounds and %d planner calls", resp.ReplanRounds, planner.calls)
+	}
+}
+
+// stepRetrieval answers each query with the chunk named after the query's first
+// word, after a delay that makes later steps finish first. It records how many
+// searches ran at once and fails queries starting with "broken".
+type stepRetrieval struct {
+	stubRetrieval
+	mu      sync.Mutex
+	active  int
+	maxSeen int
+}
+
+func (s *stepRetrieval) Search(ctx context.Context, query string) ([]RetrievalResult, error) {
+	s.mu.Lock()
+	s.active++
+	s.maxSeen = max(s.maxSeen, s.active)
+	s.mu.Unlock()
+	defer func() {
+		s.mu.Lock()
+		s.active--
+		s.mu.Unlock()
+	}()
+
+	word := strings.Fields(query)[0]
+	if word == "broken" {
+		return nil, errors.New("index offline")
+	}
+	delay := map[string]time.Duration{"alpha": 100 * time.Millisecond, "beta": 20 * time.Millisecond}[word]
+	select {
+	case <-time.After(delay):
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	return []RetrievalResult{{Chunk: document.Chunk{ID: word + "_1", DocumentID: word, Content: word + " facts."}, Score: 0.9}}, nil
+}
+
+func TestPipelineResearchesStepsConcurrently(t *testing.T) {
+	ctx := context.Background()
+	newPipe := func(plan string, concurrency int) (*Pipeline, *stepRetrieval) {
+		retr := &stepRetrieval{stubRetrieval: *newStubRetrieval(nil)}
+		pipe, err := NewPipeline(
+			Clients{Planner: &stubLLM{response: plan}, Writer: &stubLLM{response: "Answer."}},
+			nil,
+			nil,
+			WithRetriever(retr),
+			WithCritic(false),
+			WithRetrievalConcurrency(concurrency),
+		)
+		if err != nil {
+			t.Fatalf("NewPipeline error: %v", err)
+		}
+		for _, id := range []string{"alpha", "beta", "gamma"} {
+			if err := pipe.IndexDocuments(ctx, Document{ID: id, Title: id, Content: id + " facts."}); err != nil {
+				t.Fatalf("IndexDocuments error: %v", err)
+			}
+		}
+		return pipe, retr
+	}
+	plan := `{"strategy":"s","steps":[{"id":"step-1","goal":"alpha"},{"id":"step-2","goal":"beta"},{"id":"step-3","goal":"gamma"}]}`
+
+	for _, concurrency := range []int{1, 3} {
+		pipe, retr := newPipe(plan, concurrency)
+		resp, err := pipe.Run(ctx, "alpha beta gamma?")
+		if err != nil {
+			t.Fatalf("pipeline run failed: %v", err)
+		}
+		var order []string
+		for _, ev := range resp.Evidence {
+			order = append(order, ev.StepID+":"+ev.Document.ID)
+		}
+		if got := strings.Join(order, ","); got != "step-1:alpha,step-2:beta,step-3:gamma" {
+			t.Fatalf("concurrency %d: expected evidence in plan order, got %s", concurrency, got)
+		}
+		if retr.maxSeen > concurrency || (concurrency > 1 && retr.maxSeen < 2) {
+			t.Fatalf("concurrency %d: unexpected %d concurrent searches", concurrency, retr.maxSeen)
+		}
+	}
+
+	pipe, _ := newPipe(`{"strategy":"s","steps":[{"id":"step-1","goal":"alpha"},{"id":"step-2","goal":"broken"}]}`, 2)
+	start := time.Now()
+	_, err := pipe.Run(ctx, "alpha broken?")
+	if err == nil || !strings.Contains(err.Error(), "vector search failed: index offline") {
+		t.Fatalf("expected search error to surface, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 80*time.Millisecond {
+		t.Fatalf("expected failing step to cancel the others, took %s", elapsed)
+	}
+}
+
+func TestPipelineUpsertAndDeleteDocuments(t *testing.T) {
+	ctx := context.Background()
+	store := inmemory.NewInMemoryVectorStore()
+	pipe, err := NewPipeline(
+		Clients{
+			Planner: &stubLLM{response: `{"strategy":"baseline","steps":[{"id":"step-1","goal":"shipping policy"}]}`},
+			Writer:  &stubLLM{response: "Answer."},
+		},
+		&keywordEmbedder{},
+		store,
+		WithCritic(false),
+	)
+	if err != nil {
+		t.Fatalf("NewPipeline error: %v", err)
+	}
+	err = pipe.IndexDocuments(ctx,
+		Document{ID: "shipping", Title: "Shipping", Content: "Shipping policy: orders ship in five days."},
+		Document{ID: "returns", Title: "Returns", Content: "Return policy timeline is thirty days."},
+	)
+	if err != nil {
+		t.Fatalf("IndexDocuments error: %v", err)
+	}
+	before, _ := pipe.CountDocuments(ctx)
+
+	if err := pipe.UpsertDocuments(ctx, Document{ID: "shipping", Title: "Shipping", Content: "Shipping policy: orders ship in two days."}); err != nil {
+		t.Fatalf("UpsertDocuments error: %v", err)
+	}
+	if after, _ := pipe.CountDocuments(ctx); after != before {
+		t.Fatalf("expected upsert to replace chunks, count went from %d to %d", before, after)
+	}
+	resp, err := pipe.Run(ctx, "What is the shipping policy?")
+	if err != nil {
+		t.Fatalf("pipeline run failed: %v", err)
+	}
+	for _, ev := range resp.Evidence {
+		if strings.Contains(ev.Chunk.Content, "five days") {
+			t.Fatalf("expected stale chunk to be gone, got %q", ev.Chunk.Content)
+		}
+	}
+
+	if err := pipe.DeleteDocuments(ctx, "shipping", "unknown"); err != nil {
+		t.Fatalf("DeleteDocuments error: %v", err)
+	}
+	resp, err = pipe.Run(ctx, "What is the shipping policy?")
+	if err != nil {
+		t.Fatalf("pipeline run failed: %v", err)
+	}
+	for _, ev := range resp.Evidence {
+		if ev.Document.ID == "shipping" {
+			t.Fatalf("expected deleted document to be unretrievable, got %q", ev.Chunk.Content)
+		}
+	}
+	if count, _ := pipe.CountDocuments(ctx); count == 0 || count >= before {
+		t.Fatalf("expected only the returns chunks to remain, got %d of %d", count, before)
+	}
+}
+
+func TestPipelineMetadataFilterRestrictsRetrieval(t *testing.T) {
+	ctx := context.Background()
+	newPipe := func(opts ...Option) *Pipeline {
+		opts = append(opts, WithCritic(false), WithRetrievalPreset(RetrievalPresetSimple))
+		pipe, err := NewPipeline(
+			Clients{
+				Planner: &stubLLM{response: `{"strategy":"baseline","steps":[{"id":"step-1","goal":"shipping policy"}]}`},
+				Writer:  &stubLLM{response: "Answer."},
+			},
+			&keywordEmbedder{},
+			inmemory.NewInMemoryVectorStore(),
+			opts...,
+		)
+		if err != nil {
+			t.Fatalf("NewPipeline error: %v", err)
+		}
+		err = pipe.IndexDocuments(ctx,
+			Document{ID: "kb", Title: "KB", Content: "Shipping policy from the knowledge base.", Metadata: map[string]any{"source": "knowledge-base"}},
+			Document{ID: "forum", Title: "Forum", Content: "Shipping policy rumours from the forum.", Metadata: map[string]any{"source": "forum"}},
+		)
+		if err != nil {
+			t.Fatalf("IndexDocuments error: %v", err)
+		}
+		return pipe
+	}
+	docIDs := func(resp *Response) map[string]bool {
+		ids := make(map[string]bool)
+		for _, ev := range resp.Evidence {
+			ids[ev.Document.ID] = true
+		}
+		return ids
+	}
+
+	resp, err := newPipe().Run(ctx, "What is the shipping policy?")
+	if err != nil {
+		t.Fatalf("pipeline run failed: %v", err)
+	}
+	if ids := docIDs(resp); !ids["kb"] || !ids["forum"] {
+		t.Fatalf("expected unfiltered run to use both documents, got %v", ids)
+	}
+
+	resp, err = newPipe(WithMetadataFilter(map[string]any{"source": "knowledge-base"})).Run(ctx, "What is the shipping policy?")
+	if err != nil {
+		t.Fatalf("pipeline run failed: %v", err)
+	}
+	if ids := docIDs(resp); len(ids) != 1 || !ids["kb"] {
+		t.Fatalf("expected only knowledge-base evidence, got %v", ids)
+	}
+}
+
+func TestPipelineScopeAppliesBeforeTopK(t *testing.T) {
+	ctx := context.Background()
+	pipe, err := NewPipeline(
+		Clients{
+			Planner: &stubLLM{response: `{"strategy":"baseline","steps":[{"id":"step-1","goal":"shipping policy timeline"}]}`},
+			Writer:  &stubLLM{response: "Answer."},
+		},
+		&keywordEmbedder{},
+		inmemory.NewInMemoryVectorStore(),
+		WithCritic(false),
+		WithRetrievalPreset(RetrievalPresetSimple),
+		WithTopK(1),
+		WithRerankTopK(1),
+	)
+	if err != nil {
+		t.Fatalf("NewPipeline error: %v", err)
+	}
+	// Tenant B's documents match the query better than tenant A's, so they
+	// would fill the top-k if the scope were only applied afterwards.
+	err = pipe.IndexDocuments(ctx,
+		Document{ID: "b-1", Title: "B", Content: "Shipping policy timeline of tenant B.", Metadata: map[string]any{"tenant": "b"}},
+		Document{ID: "b-2", Title: "B", Content: "Another shipping policy timeline of tenant B.", Metadata: map[string]any{"tenant": "b"}},
+		Document{ID: "a-1", Title: "A", Content: "Return shipping for tenant A.", Metadata: map[string]any{"tenant": "a"}},
+	)
+	if err != nil {
+		t.Fatalf("IndexDocuments error: %v", err)
+	}
+	scope := NewRetrievalScope("tenant", "a")
+
+	results, err := pipe.Retrieve(ctx, "shipping policy timeline", scope)
+	if err != nil {
+		t.Fatalf("Retrieve failed: %v", err)
+	}
+	if len(results) != 1 || results[0].Chunk.DocumentID != "a-1" {
+		t.Fatalf("expected tenant A's document despite better tenant B matches, got %#v", results)
+	}
+
+	resp, err := pipe.RunWithScope(ctx, "What is the shipping policy timeline?", scope)
+	if err != nil {
+		t.Fatalf("pipeline run failed: %v", err)
+	}
+	if len(resp.Evidence) != 1 || resp.Evidence[0].Document.ID != "a-1" {
+		t.Fatalf("expected tenant A evidence, got %#v", resp.Evidence)
+	}
+
+	if results, _ := pipe.Retrieve(ctx, "shipping policy timeline", &RetrievalScope{}); len(results) != 0 {
+		t.Fatalf("expected an empty scope to deny everything, got %#v", results)
+	}
+}
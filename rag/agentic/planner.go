@@ -2,6 +2,7 @@ package agentic
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"strconv"
 	"strings"
@@ -26,7 +27,45 @@ func newPlanner(llm agent.LLMClient, cfg *Config) *planner {
 	}
 }
 
-func (p *planner) Plan(ctx context.Context, question string) (*Plan, error) {
+func (p *planner) Plan(ctx context.Context, question string, history []Turn) (*Plan, error) {
+	return p.generate(ctx, fmt.Sprintf("%sUser question: %s\nReturn JSON only.", formatHistory(history), question))
+}
+
+// Replan asks for additional or alternative steps when the steps of prev
+// collected fewer evidence items than required: found is how many they
+// collected and round is the 1-based replanning round. The returned plan holds
+// only the new steps, with IDs distinct from those of prev.
+func (p *planner) Replan(ctx context.Context, question string, history []Turn, prev *Plan, found, required, round int) (*Plan, error) {
+	var prevJSON string
+	if prev != nil {
+		if data, err := json.Marshal(prev); err == nil {
+			prevJSON = string(data)
+		}
+	}
+	prompt := fmt.Sprintf("%sUser question: %s\n\nPrevious plan:\n%s\n\nThe previous steps retrieved only %d of the %d evidence items required to answer. "+
+		"Propose additional or alternative research steps that approach the question from different angles, with other keywords, synonyms or document types. "+
+		"Do not repeat previous steps.\nReturn JSON only.", formatHistory(history), question, prevJSON, found, required)
+	plan, err := p.generate(ctx, prompt)
+	if err != nil {
+		return nil, err
+	}
+
+	used := make(map[string]struct{})
+	if prev != nil {
+		for _, step := range prev.Steps {
+			used[step.ID] = struct{}{}
+		}
+	}
+	for idx := range plan.Steps {
+		if _, taken := used[plan.Steps[idx].ID]; taken {
+			plan.Steps[idx].ID = fmt.Sprintf("replan-%d-step-%d", round, idx+1)
+		}
+		used[plan.Steps[idx].ID] = struct{}{}
+	}
+	return plan, nil
+}
+
+func (p *planner) generate(ctx context.Context, userPrompt string) (*Plan, error) {
 	if p.llm == nil {
 		return nil, fmt.Errorf("planner LLM is not configured")
 	}
@@ -34,7 +73,7 @@ func (p *planner) Plan(ctx context.Context, question string) (*Plan, error) {
 	systemPrompt := strings.ReplaceAll(p.prompt, "{{max_steps}}", strconv.Itoa(p.maxSteps))
 	messages := []*message.Message{
 		message.NewMessage(message.RoleSystem, systemPrompt),
-		message.NewMessage(message.RoleUser, fmt.Sprintf("User question: %s\nReturn JSON only.", question)),
+		message.NewMessage(message.RoleUser, userPrompt),
 	}
 
 	genResp, err := p.llm.Generate(ctx, &agent.GenerateRequest{
@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"log/slog"
+	"math"
 	"sort"
 	"strings"
 	"sync"
@@ -34,7 +35,13 @@ type RetrievalResult struct {
 type RetrievalEngine interface {
 	IndexDocuments(ctx context.Context, docs ...document.Document) error
 	Search(ctx context.Context, query string) ([]RetrievalResult, error)
+	// SearchWithFilter restricts Search to chunks whose metadata, merged with
+	// their document's metadata, matches every key of filter as defined by
+	// vector.MatchesFilter, including vector.AnyOf values. The filter must apply
+	// before results are cut to top-k since it carries the RetrievalScope.
+	SearchWithFilter(ctx context.Context, query string, filter map[string]any) ([]RetrievalResult, error)
 	Document(id string) (document.Document, bool)
+	Delete(ctx context.Context, ids ...string) error
 	Clear(ctx context.Context) error
 	Count(ctx context.Context) (int, error)
 }
@@ -69,14 +76,18 @@ func (d *defaultRetrieval) IndexDocuments(ctx context.Context, docs ...document.
 }
 
 func (d *defaultRetrieval) Search(ctx context.Context, query string) ([]RetrievalResult, error) {
+	return d.SearchWithFilter(ctx, query, nil)
+}
+
+func (d *defaultRetrieval) SearchWithFilter(ctx context.Context, query string, filter map[string]any) ([]RetrievalResult, error) {
 	ctx, span := agenticRetrievalTracer.Start(ctx, "DefaultRetrieval.Search",
-		oteltrace.WithAttributes(attribute.String("query", trimLogString(query, 80))))
+		oteltrace.WithAttributes(attribute.String("query", trimLogString(query, 80)), attribute.Int("filter.keys", len(filter))))
 	var spanErr error
 	defer func() { telemetry.End(span, spanErr) }()
 	if d.logger != nil {
 		d.logger.Debug("default retrieval search started", "query", trimLogString(query, 80))
 	}
-	results, err := d.base.Search(ctx, query)
+	results, err := d.base.SearchWithFilter(ctx, query, filter)
 	if err != nil {
 		if d.logger != nil {
 			d.logger.Error("base retrieval search failed", "error", err)
@@ -84,18 +95,29 @@ func (d *defaultRetrieval) Search(ctx context.Context, query string) ([]Retrieva
 		spanErr = err
 		return nil, err
 	}
-	out := make([]RetrievalResult, 0, len(results))
-	seen := make(map[string]struct{}, len(results))
+	candidates := make([]RetrievalResult, 0, len(results))
+	scores := make([]float32, 0, len(results))
 	for _, res := range results {
 		score := d.adjustScore(res.Chunk, res.Score)
-		if score < d.cfg.MinSearchScore {
-			continue
-		}
-		seen[res.Chunk.ID] = struct{}{}
-		out = append(out, RetrievalResult{
+		candidates = append(candidates, RetrievalResult{
 			Chunk: res.Chunk,
 			Score: score,
 		})
+		scores = append(scores, score)
+	}
+	cutoff := d.cfg.MinSearchScore
+	if d.cfg.AdaptiveScoreThreshold {
+		cutoff = adaptiveScoreCutoff(scores, d.cfg.AdaptiveScoreRatio, d.cfg.MinSearchScore)
+		span.SetAttributes(attribute.Float64("score.cutoff", float64(cutoff)))
+	}
+	out := make([]RetrievalResult, 0, len(candidates))
+	seen := make(map[string]struct{}, len(candidates))
+	for _, res := range candidates {
+		if res.Score < cutoff {
+			continue
+		}
+		seen[res.Chunk.ID] = struct{}{}
+		out = append(out, res)
 	}
 	target := d.cfg.RerankTopK
 	if d.cfg.HybridTopK > 0 {
@@ -105,8 +127,7 @@ func (d *defaultRetrieval) Search(ctx context.Context, query string) ([]Retrieva
 		if d.logger != nil {
 			d.logger.Debug("hybrid search fallback triggered", "missing", target-len(out))
 		}
-		extras := d.keywords.search(query, target-len(out), seen)
-		out = append(out, extras...)
+		out = append(out, d.keywords.search(query, target-len(out), seen, filter)...)
 	}
 	if d.logger != nil {
 		d.logger.Debug("default retrieval search completed", "query", trimLogString(query, 80), "hits", len(out))
@@ -115,10 +136,52 @@ func (d *defaultRetrieval) Search(ctx context.Context, query string) ([]Retrieva
 	return out, nil
 }
 
+// defaultAdaptiveScoreRatio is used when Config.AdaptiveScoreRatio is unset.
+const defaultAdaptiveScoreRatio = 0.8
+
+// adaptiveScoreCutoff returns the lower of the ratio cutoff and mean+stddev over
+// scores, raised to floor. The ratio cutoff lies (1-ratio)*|top| below the top
+// score, which is ratio*top for positive scores and still below the top score
+// when scores are zero or negative. The top result always passes unless it is
+// below floor.
+func adaptiveScoreCutoff(scores []float32, ratio, floor float32) float32 {
+	if len(scores) == 0 {
+		return floor
+	}
+	if ratio <= 0 {
+		ratio = defaultAdaptiveScoreRatio
+	}
+	var top, sum float64
+	top = math.Inf(-1)
+	for _, s := range scores {
+		top = math.Max(top, float64(s))
+		sum += float64(s)
+	}
+	mean := sum / float64(len(scores))
+	var variance float64
+	for _, s := range scores {
+		variance += (float64(s) - mean) * (float64(s) - mean)
+	}
+	stddev := math.Sqrt(variance / float64(len(scores)))
+	cutoff := math.Min(top-(1-float64(ratio))*math.Abs(top), mean+stddev)
+	return float32(math.Max(cutoff, float64(floor)))
+}
+
 func (d *defaultRetrieval) Document(id string) (document.Document, bool) {
 	return d.base.Document(id)
 }
 
+func (d *defaultRetrieval) Delete(ctx context.Context, ids ...string) error {
+	if d.logger != nil {
+		d.logger.Info("default retrieval deleting documents", "count", len(ids))
+	}
+	if err := d.base.DeleteDocuments(ctx, ids...); err != nil {
+		return err
+	}
+	d.keywords.remove(ids...)
+	return nil
+}
+
 func (d *defaultRetrieval) Clear(ctx context.Context) error {
 	if d.logger != nil {
 		d.logger.Warn("clearing default retrieval index")
@@ -155,6 +218,9 @@ func newDefaultRetrievalEngine(vec vector.VectorStore, emb vector.Embedder, cfg
 	if emb == nil {
 		return nil, fmt.Errorf("embedder is required")
 	}
+	if cfg.Collection != "" {
+		vec = vec.WithCollection(cfg.Collection)
+	}
 
 	overlap := cfg.ChunkOverlap
 	if overlap < 0 {
@@ -234,6 +300,17 @@ func (k *keywordIndex) add(docs ...document.Document) {
 	}
 }
 
+func (k *keywordIndex) remove(ids ...string) {
+	if k == nil {
+		return
+	}
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	for _, id := range ids {
+		delete(k.docs, id)
+	}
+}
+
 func (k *keywordIndex) reset() {
 	if k == nil {
 		return
@@ -243,7 +320,9 @@ func (k *keywordIndex) reset() {
 	k.docs = make(map[string]document.Document)
 }
 
-func (k *keywordIndex) search(query string, limit int, seen map[string]struct{}) []RetrievalResult {
+// search returns up to limit keyword matches among documents whose metadata
+// matches filter; the filter applies before the limit is cut.
+func (k *keywordIndex) search(query string, limit int, seen map[string]struct{}, filter map[string]any) []RetrievalResult {
 	if k == nil || limit <= 0 {
 		return nil
 	}
@@ -266,7 +345,7 @@ func (k *keywordIndex) search(query string, limit int, seen map[string]struct{})
 				hits++
 			}
 		}
-		if hits == 0 {
+		if hits == 0 || !vector.MatchesFilter(doc.Metadata, filter) {
 			continue
 		}
 		score := float32(hits) / float32(len(tokens))
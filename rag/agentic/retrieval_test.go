@@ -2,6 +2,7 @@ package agentic
 
 import (
 	"context"
+	"math"
 	"strings"
 	"testing"
 
@@ -71,3 +72,43 @@ func (c *constantEmbedder) EmbedBatch(ctx context.Context, texts []string) ([][]
 func (c *constantEmbedder) Dimension() int {
 	return 4
 }
+
+func TestAdaptiveScoreCutoff(t *testing.T) {
+	scores := []float32{0.9, 0.85, 0.4, 0.35, 0.3}
+	cutoff := adaptiveScoreCutoff(scores, 0.8, 0.1)
+	var kept int
+	for _, s := range scores {
+		if s >= cutoff {
+			kept++
+		}
+	}
+	if kept != 2 {
+		t.Fatalf("expected the two leading results to pass cutoff %.3f, kept %d", cutoff, kept)
+	}
+
+	if got := adaptiveScoreCutoff([]float32{0.2, 0.19}, 0.8, 0.5); got != 0.5 {
+		t.Fatalf("expected MinSearchScore floor 0.5, got %.3f", got)
+	}
+	if got := adaptiveScoreCutoff(nil, 0.8, 0.25); got != 0.25 {
+		t.Fatalf("expected floor for empty scores, got %.3f", got)
+	}
+
+	// Distance-like scores can be zero or negative; the top result must still pass.
+	noFloor := float32(math.Inf(-1))
+	for _, scores := range [][]float32{
+		{-0.5, -0.55, -2, -3},
+		{0, -0.1, -1},
+		{0.1, -0.4, -0.9},
+	} {
+		cutoff := adaptiveScoreCutoff(scores, 0.8, noFloor)
+		if scores[0] < cutoff {
+			t.Fatalf("expected the top score %.2f of %v to pass cutoff %.3f", scores[0], scores, cutoff)
+		}
+		if scores[len(scores)-1] >= cutoff {
+			t.Fatalf("expected the weakest score of %v to fail cutoff %.3f", scores, cutoff)
+		}
+	}
+	if got := adaptiveScoreCutoff([]float32{-0.5, -0.55, -2, -3}, 0.8, noFloor); got != -0.6 {
+		t.Fatalf("expected the ratio cutoff 20%% of |top| below the top score, got %.3f", got)
+	}
+}
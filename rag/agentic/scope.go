@@ -0,0 +1,159 @@
+package agentic
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/sweetpotato0/ai-allin/rag/document"
+	"github.com/sweetpotato0/ai-allin/vector"
+)
+
+type scopeContextKey struct{}
+
+// RetrievalScope restricts retrieval to documents whose metadata matches the allowed values.
+// Every key in Allowed must be present in the document (or chunk) metadata and hold one of
+// the listed values. A non-nil scope without any rules denies everything so a misconfigured
+// caller fails closed rather than leaking documents.
+type RetrievalScope struct {
+	Allowed map[string][]string
+}
+
+// NewRetrievalScope builds a scope that allows values for a single metadata key.
+func NewRetrievalScope(key string, values ...string) *RetrievalScope {
+	return &RetrievalScope{Allowed: map[string][]string{key: values}}
+}
+
+// Allow adds permitted values for the metadata key and returns the scope for chaining.
+func (s *RetrievalScope) Allow(key string, values ...string) *RetrievalScope {
+	if s.Allowed == nil {
+		s.Allowed = make(map[string][]string)
+	}
+	s.Allowed[key] = append(s.Allowed[key], values...)
+	return s
+}
+
+// Permits reports whether metadata satisfies every rule of the scope.
+// A nil scope permits everything.
+func (s *RetrievalScope) Permits(metadata map[string]any) bool {
+	if s == nil {
+		return true
+	}
+	if len(s.Allowed) == 0 {
+		return false
+	}
+	for key, allowed := range s.Allowed {
+		raw, ok := metadata[key]
+		if !ok || !matchesAny(raw, allowed) {
+			return false
+		}
+	}
+	return true
+}
+
+// Filter merges the scope into base so retrieval engines can push it down to
+// the vector store before results are ranked and cut to top-k. Each scope key
+// becomes a vector.AnyOf of its allowed values; a key also present in base
+// keeps base's value when the scope permits it. ok is false when nothing can
+// match, i.e. the scope has no rules or contradicts base. A nil scope returns
+// base unchanged.
+func (s *RetrievalScope) Filter(base map[string]any) (filter map[string]any, ok bool) {
+	if s == nil {
+		return base, true
+	}
+	if len(s.Allowed) == 0 {
+		return nil, false
+	}
+	filter = make(map[string]any, len(base)+len(s.Allowed))
+	for k, v := range base {
+		filter[k] = v
+	}
+	for key, allowed := range s.Allowed {
+		if want, exists := base[key]; exists {
+			if anyOf, isAnyOf := want.(vector.AnyOf); isAnyOf {
+				var kept vector.AnyOf
+				for _, v := range anyOf {
+					if matchesAny(v, allowed) {
+						kept = append(kept, v)
+					}
+				}
+				if len(kept) == 0 {
+					return nil, false
+				}
+				filter[key] = kept
+				continue
+			}
+			if !matchesAny(want, allowed) {
+				return nil, false
+			}
+			continue
+		}
+		values := make(vector.AnyOf, 0, len(allowed))
+		for _, v := range allowed {
+			values = append(values, v)
+		}
+		filter[key] = values
+	}
+	return filter, true
+}
+
+// ContextWithRetrievalScope attaches a mandatory retrieval scope to ctx, for
+// entry points without a scope argument such as RunStream and RunInSession.
+// The pipeline merges it into the metadata filter of every search.
+func ContextWithRetrievalScope(ctx context.Context, scope *RetrievalScope) context.Context {
+	return context.WithValue(ctx, scopeContextKey{}, scope)
+}
+
+// RetrievalScopeFromContext returns the scope attached to ctx, or nil when unset.
+func RetrievalScopeFromContext(ctx context.Context) *RetrievalScope {
+	if ctx == nil {
+		return nil
+	}
+	scope, _ := ctx.Value(scopeContextKey{}).(*RetrievalScope)
+	return scope
+}
+
+func matchesAny(raw any, allowed []string) bool {
+	switch v := raw.(type) {
+	case []string:
+		for _, item := range v {
+			if containsString(allowed, item) {
+				return true
+			}
+		}
+		return false
+	case []any:
+		for _, item := range v {
+			if containsString(allowed, fmt.Sprint(item)) {
+				return true
+			}
+		}
+		return false
+	case nil:
+		return false
+	default:
+		return containsString(allowed, fmt.Sprint(v))
+	}
+}
+
+func containsString(values []string, target string) bool {
+	for _, v := range values {
+		if v == target {
+			return true
+		}
+	}
+	return false
+}
+
+func scopeMetadata(doc *document.Document, chunk document.Chunk) map[string]any {
+	merged := cloneMetadata(chunk.Metadata)
+	if doc == nil || len(doc.Metadata) == 0 {
+		return merged
+	}
+	if merged == nil {
+		merged = make(map[string]any, len(doc.Metadata))
+	}
+	for k, v := range doc.Metadata {
+		merged[k] = v
+	}
+	return merged
+}
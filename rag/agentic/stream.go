@@ -0,0 +1,121 @@
+package agentic
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"iter"
+	"strings"
+
+	"github.com/sweetpotato0/ai-allin/graph"
+)
+
+// StreamEventType identifies the kind of event emitted by RunStream.
+type StreamEventType string
+
+const (
+	// StreamEventDraft carries the synthesized draft before the critic has reviewed it.
+	StreamEventDraft StreamEventType = "draft"
+	// StreamEventCritic carries the reviewed response once the asynchronous critic finishes.
+	StreamEventCritic StreamEventType = "critic"
+	// StreamEventFinal carries the complete response when the critic runs synchronously.
+	StreamEventFinal StreamEventType = "final"
+)
+
+// StreamEvent is a single update emitted by RunStream.
+type StreamEvent struct {
+	Type     StreamEventType `json:"type"`
+	Response *Response       `json:"response"`
+}
+
+// RunStream executes the pipeline and yields intermediate responses.
+// When AsyncCritic is enabled and a critic is configured, the draft is emitted as soon as
+// synthesis completes and the critic runs in the background; its verdict is emitted as a
+// follow-up StreamEventCritic event that may replace the answer (and carries the grounding
+// report when enabled). Otherwise a single
+// StreamEventFinal event mirrors the result of Run.
+func (p *Pipeline) RunStream(ctx context.Context, question string) iter.Seq2[*StreamEvent, error] {
+	return func(yield func(*StreamEvent, error) bool) {
+		if !p.cfg.AsyncCritic || !p.cfg.EnableCritic || p.critic == nil {
+			resp, err := p.Run(ctx, question)
+			if err != nil {
+				yield(nil, err)
+				return
+			}
+			yield(&StreamEvent{Type: StreamEventFinal, Response: resp}, nil)
+			return
+		}
+
+		if strings.TrimSpace(question) == "" {
+			yield(nil, fmt.Errorf("question cannot be empty"))
+			return
+		}
+		p.logger.Info("pipeline stream started", "question", trimForLog(question, 120))
+
+		// The background critic shares the run's deadline, so RunTimeout bounds
+		// the whole streamed run rather than each half of it.
+		var cancel context.CancelFunc
+		if p.cfg.RunTimeout > 0 {
+			ctx, cancel = context.WithTimeout(ctx, p.cfg.RunTimeout)
+		} else {
+			ctx, cancel = context.WithCancel(ctx)
+		}
+		defer cancel()
+
+		st, err := p.execute(ctx, &pipelineState{Question: question, DeferCritic: true})
+		if err != nil {
+			yield(nil, err)
+			return
+		}
+
+		draft := p.buildResponse(st)
+		done := make(chan error, 1)
+		go func() {
+			_, err := p.criticNode(ctx, graph.State{ragStateKey: st})
+			if err == nil {
+				st.DeferCritic = false
+				_, err = p.groundingNode(ctx, graph.State{ragStateKey: st})
+			}
+			if err != nil && p.cfg.RunTimeout > 0 && errors.Is(ctx.Err(), context.DeadlineExceeded) {
+				err = &TimeoutError{Stage: st.Stage, Timeout: p.cfg.RunTimeout}
+			}
+			done <- err
+		}()
+
+		if !yield(&StreamEvent{Type: StreamEventDraft, Response: draft}, nil) {
+			return
+		}
+
+		if err := <-done; err != nil {
+			yield(nil, fmt.Errorf("critic review failed: %w", err))
+			return
+		}
+		yield(&StreamEvent{Type: StreamEventCritic, Response: p.buildResponse(st)}, nil)
+	}
+}
+
+// RunStreamTokens executes the pipeline and delivers the answer to onToken as
+// the writer composes it. Planning and research run first; when the writer
+// client does not implement agent.StreamLLMClient the whole answer arrives as
+// a single token, and when evidence is insufficient the no-answer message does.
+// An error returned by onToken aborts the run.
+//
+// The critic, if enabled, reviews the answer after the stream completes. When
+// it revises the streamed answer, onRevision receives the final response so
+// the caller can replace what was displayed; it may be nil. The returned
+// response always carries the final answer.
+func (p *Pipeline) RunStreamTokens(ctx context.Context, question string, onToken func(token string) error, onRevision func(resp *Response) error) (*Response, error) {
+	if onToken == nil {
+		return nil, fmt.Errorf("token callback cannot be nil")
+	}
+	resp, err := p.run(ctx, &pipelineState{Question: question, OnToken: onToken})
+	if err != nil {
+		return nil, err
+	}
+	if onRevision != nil && resp.FinalAnswer != resp.DraftAnswer {
+		if err := onRevision(resp); err != nil {
+			return resp, err
+		}
+	}
+	return resp, nil
+}
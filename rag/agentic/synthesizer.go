@@ -22,9 +22,80 @@ func newSynthesizer(llm agent.LLMClient, cfg *Config) *synthesizer {
 	}
 }
 
-func (s *synthesizer) Compose(ctx context.Context, question string, plan *Plan, evidence []Evidence) (string, error) {
+func (s *synthesizer) Compose(ctx context.Context, question string, plan *Plan, evidence []Evidence, history []Turn) (string, error) {
+	req, err := s.request(question, plan, evidence, history)
+	if err != nil {
+		return "", err
+	}
+
+	genResp, err := s.llm.Generate(ctx, req)
+	if err != nil {
+		return "", fmt.Errorf("synthesizer failed: %w", err)
+	}
+
+	if genResp == nil || genResp.Message == nil {
+		return "", fmt.Errorf("synthesizer returned empty response")
+	}
+
+	return strings.TrimSpace(genResp.Message.Text()), nil
+}
+
+// ComposeStream is Compose delivering the answer to onToken as the writer
+// produces it. Writers without streaming support compose the whole answer and
+// deliver it as a single token. It reports whether any token was delivered, in
+// which case the answer can no longer be retried without duplicating output.
+func (s *synthesizer) ComposeStream(ctx context.Context, question string, plan *Plan, evidence []Evidence, history []Turn, onToken func(string) error) (string, bool, error) {
+	streamer, ok := s.llm.(agent.StreamLLMClient)
+	if !ok {
+		draft, err := s.Compose(ctx, question, plan, evidence, history)
+		if err != nil {
+			return "", false, err
+		}
+		return draft, true, onToken(draft)
+	}
+
+	req, err := s.request(question, plan, evidence, history)
+	if err != nil {
+		return "", false, err
+	}
+
+	var text strings.Builder
+	var final string
+	streamed := false
+	for resp, err := range streamer.GenerateStream(ctx, req) {
+		if err != nil {
+			return "", streamed, fmt.Errorf("synthesizer failed: %w", err)
+		}
+		if resp == nil || resp.Message == nil {
+			continue
+		}
+		if resp.Message.Completed {
+			final = resp.Message.Text()
+			continue
+		}
+		delta := resp.Message.Text()
+		if delta == "" {
+			continue
+		}
+		text.WriteString(delta)
+		streamed = true
+		if err := onToken(delta); err != nil {
+			return "", true, err
+		}
+	}
+	if final == "" {
+		final = text.String()
+	}
+	if strings.TrimSpace(final) == "" {
+		return "", streamed, fmt.Errorf("synthesizer returned empty response")
+	}
+	return strings.TrimSpace(final), streamed, nil
+}
+
+// request builds the synthesis prompt.
+func (s *synthesizer) request(question string, plan *Plan, evidence []Evidence, history []Turn) (*agent.GenerateRequest, error) {
 	if s.llm == nil {
-		return "", fmt.Errorf("synthesizer LLM is not configured")
+		return nil, fmt.Errorf("synthesizer LLM is not configured")
 	}
 
 	var planJSON string
@@ -35,25 +106,13 @@ func (s *synthesizer) Compose(ctx context.Context, question string, plan *Plan,
 	}
 
 	contextBlock := formatEvidence(evidence)
-	userPrompt := fmt.Sprintf("Question:\n%s\n\nPlan:\n%s\n\nEvidence:\n%s", question, planJSON, contextBlock)
+	userPrompt := fmt.Sprintf("%sQuestion:\n%s\n\nPlan:\n%s\n\nEvidence:\n%s", formatHistory(history), question, planJSON, contextBlock)
 
 	msgs := []*message.Message{
 		message.NewMessage(message.RoleSystem, s.prompt),
 		message.NewMessage(message.RoleUser, userPrompt),
 	}
-
-	genResp, err := s.llm.Generate(ctx, &agent.GenerateRequest{
-		Messages: msgs,
-	})
-	if err != nil {
-		return "", fmt.Errorf("synthesizer failed: %w", err)
-	}
-
-	if genResp == nil || genResp.Message == nil {
-		return "", fmt.Errorf("synthesizer returned empty response")
-	}
-
-	return strings.TrimSpace(genResp.Message.Text()), nil
+	return &agent.GenerateRequest{Messages: msgs}, nil
 }
 
 func formatEvidence(evidence []Evidence) string {
@@ -76,3 +135,17 @@ func formatEvidence(evidence []Evidence) string {
 	}
 	return b.String()
 }
+
+// formatHistory renders prior turns so follow-up questions can be resolved.
+func formatHistory(history []Turn) string {
+	if len(history) == 0 {
+		return ""
+	}
+	var b strings.Builder
+	b.WriteString("Conversation so far:\n")
+	for _, turn := range history {
+		fmt.Fprintf(&b, "Q: %s\nA: %s\n", turn.Question, trimForLog(turn.Answer, 600))
+	}
+	b.WriteString("\n")
+	return b.String()
+}
@@ -39,12 +39,47 @@ type CriticFeedback struct {
 	FinalAnswer string   `json:"final_answer,omitempty"` // Final answer (may equal draft)
 }
 
+// SentenceGrounding records whether one factual sentence is backed by the evidence.
+type SentenceGrounding struct {
+	Sentence  string   `json:"sentence"`
+	Supported bool     `json:"supported"`
+	Citations []string `json:"citations,omitempty"` // Document IDs that support the sentence
+}
+
+// GroundingReport is produced by the grounding check when enabled. It measures how much
+// of the final answer is supported by the retrieved evidence.
+type GroundingReport struct {
+	Score       float64             `json:"score"`                 // Fraction of factual sentences supported (0-1)
+	Sentences   []SentenceGrounding `json:"sentences,omitempty"`   // Per-sentence verdicts
+	Unsupported []string            `json:"unsupported,omitempty"` // Sentences without supporting evidence
+	Notes       string              `json:"notes,omitempty"`       // Set when the checker output could not be parsed
+}
+
 // Response captures the structured pipeline result that applications consume.
 type Response struct {
-	Question    string          `json:"question"`
-	Plan        *Plan           `json:"plan,omitempty"`
-	Evidence    []Evidence      `json:"evidence,omitempty"`
-	DraftAnswer string          `json:"draft_answer,omitempty"`
-	FinalAnswer string          `json:"final_answer,omitempty"`
-	Critic      *CriticFeedback `json:"critic,omitempty"`
+	Question    string           `json:"question"`
+	Plan        *Plan            `json:"plan,omitempty"`
+	Evidence    []Evidence       `json:"evidence,omitempty"`
+	DraftAnswer string           `json:"draft_answer,omitempty"`
+	FinalAnswer string           `json:"final_answer,omitempty"`
+	Critic      *CriticFeedback  `json:"critic,omitempty"`
+	Grounding   *GroundingReport `json:"grounding,omitempty"`
+
+	// EvidenceDropped counts the lowest-scored evidence removed so the synthesis
+	// prompt fits the writer's context window. Evidence lists what was kept.
+	EvidenceDropped int `json:"evidence_dropped,omitempty"`
+	// ReplanRounds counts the re-planning rounds run because research found
+	// too little evidence.
+	ReplanRounds int `json:"replan_rounds,omitempty"`
+
+	// Citations lists the [doc-id] markers of FinalAnswer when citation parsing
+	// is enabled.
+	Citations []Citation `json:"citations,omitempty"`
+}
+
+// Turn records one completed question/answer exchange within a conversation.
+type Turn struct {
+	Question string     `json:"question"`
+	Answer   string     `json:"answer"`
+	Evidence []Evidence `json:"evidence,omitempty"`
 }
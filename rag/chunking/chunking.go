@@ -3,6 +3,7 @@ package chunking
 import (
 	"context"
 	"strings"
+	"unicode/utf8"
 
 	"github.com/sweetpotato0/ai-allin/rag/document"
 	"github.com/sweetpotato0/ai-allin/rag/tokenizer"
@@ -14,17 +15,22 @@ type Chunker interface {
 }
 
 type Options struct {
-	Overlap   int
-	MaxTokens int
-	Tokenizer tokenizer.Tokenizer
+	Overlap           int
+	MaxTokens         int
+	Tokenizer         tokenizer.Tokenizer
+	PropagateMetadata bool
 }
 
+// ChunkIndexKey is the metadata key holding a chunk's position within its document.
+const ChunkIndexKey = "chunk_index"
+
 var _ Chunker = (*SimpleChunker)(nil)
 
 // SimpleChunker splits documents by separator and enforces max character lengths.
 type SimpleChunker struct {
 	overlap   int
 	maxTokens int
+	propagate bool
 
 	tk tokenizer.Tokenizer
 }
@@ -59,12 +65,21 @@ func WithTokenizer(t tokenizer.Tokenizer) Option {
 	}
 }
 
+// WithPropagateMetadata controls whether each chunk receives the metadata built by
+// ChunkMetadata. Enabled by default so metadata filters match chunks.
+func WithPropagateMetadata(enabled bool) Option {
+	return func(o *Options) {
+		o.PropagateMetadata = enabled
+	}
+}
+
 // NewSimpleChunker constructs a chunker with sane defaults for most knowledge bases.
 func NewSimpleChunker(opts ...Option) *SimpleChunker {
 	cfg := &Options{
-		Overlap:   120,
-		MaxTokens: 1024,
-		Tokenizer: tokenizer.NewSimpleTokenizer(),
+		Overlap:           120,
+		MaxTokens:         1024,
+		Tokenizer:         tokenizer.NewSimpleTokenizer(),
+		PropagateMetadata: true,
 	}
 	for _, opt := range opts {
 		opt(cfg)
@@ -73,16 +88,20 @@ func NewSimpleChunker(opts ...Option) *SimpleChunker {
 	return &SimpleChunker{
 		overlap:   cfg.Overlap,
 		maxTokens: cfg.MaxTokens,
+		propagate: cfg.PropagateMetadata,
 		tk:        cfg.Tokenizer,
 	}
 }
 
 // Chunk splits the document into bounded pieces.
+// Every chunk records StartRune/EndRune, the half-open rune range of doc.Content it was cut from,
+// and its Content is that range with CRLF line endings normalized to LF.
 func (c *SimpleChunker) Chunk(ctx context.Context, doc document.Document) ([]document.Chunk, error) {
-	text := strings.ReplaceAll(doc.Content, "\r\n", "\n")
+	text, offsets := normalizeNewlines(doc.Content)
 	// split by headings
 	parts := splitByHeadings(text)
-	var chunks []document.Chunk
+	var pieces []piece
+	cursor := 0
 	for _, p := range parts {
 		paras := paragraphSplit(p.SectionText)
 		for _, para := range paras {
@@ -90,31 +109,71 @@ func (c *SimpleChunker) Chunk(ctx context.Context, doc document.Document) ([]doc
 			if para == "" {
 				continue
 			}
+			start := cursor
+			if idx := strings.Index(text[cursor:], para); idx >= 0 {
+				start = cursor + idx
+				cursor = start + len(para)
+			}
 			// estimate tokens
 			tokCount := c.tk.CountTokens(para)
 			if tokCount <= c.maxTokens {
-				chunks = append(chunks, document.Chunk{
-					ID:         document.GenChunkID("", doc.ID),
-					DocumentID: doc.ID,
-					Section:    p.Heading,
-					Content:    para,
-					TokenCount: tokCount,
+				pieces = append(pieces, piece{
+					chunk: document.Chunk{
+						ID:         document.GenChunkID("", doc.ID),
+						DocumentID: doc.ID,
+						Section:    p.Heading,
+						Content:    para,
+						TokenCount: tokCount,
+					},
+					start: start,
+					end:   start + len(para),
 				})
 			} else {
 				// split para by token windows
-				sub := c.splitLargeText(doc.ID, para)
+				sub := c.splitLargeText(doc.ID, para, start)
 				for _, s := range sub {
-					s.Section = p.Heading
-					chunks = append(chunks, s)
+					s.chunk.Section = p.Heading
+					pieces = append(pieces, s)
 				}
 			}
 		}
 	}
 	// sliding window merge adjacent small chunks to reach min size
-	chunks = mergeTinyChunks(chunks, 40)
+	pieces = c.mergeTinyChunks(text, pieces, 40)
+	chunks := make([]document.Chunk, len(pieces))
+	for i, p := range pieces {
+		chunks[i] = p.chunk
+		chunks[i].StartRune, chunks[i].EndRune = offsets.span(p.start, p.end)
+		chunks[i].Ordinal = i
+		if c.propagate {
+			chunks[i].Metadata = ChunkMetadata(doc, i)
+		}
+	}
 	return chunks, nil
 }
 
+// piece is a chunk under construction with its byte range in the normalized text.
+type piece struct {
+	chunk      document.Chunk
+	start, end int
+}
+
+// ChunkMetadata returns the metadata for the chunk at index of doc: a copy of the
+// document's metadata plus its source (unless the metadata already has one) and
+// the index under ChunkIndexKey. Chunkers use it so metadata filters written for
+// documents also match their chunks.
+func ChunkMetadata(doc document.Document, index int) map[string]any {
+	meta := make(map[string]any, len(doc.Metadata)+2)
+	for k, v := range doc.Metadata {
+		meta[k] = v
+	}
+	if _, ok := meta["source"]; !ok && doc.Source != "" {
+		meta["source"] = doc.Source
+	}
+	meta[ChunkIndexKey] = index
+	return meta
+}
+
 type headingPart struct {
 	Heading     string
 	SectionText string
@@ -158,12 +217,18 @@ func paragraphSplit(text string) []string {
 	return out
 }
 
-func (c *SimpleChunker) splitLargeText(docID, text string) []document.Chunk {
+// splitLargeText cuts text, found at byte base of the normalized document, into
+// overlapping token windows. When the tokenizer's output cannot be aligned with
+// the source, each window is located by its decoded text, falling back to the
+// whole paragraph's range.
+func (c *SimpleChunker) splitLargeText(docID, text string, base int) []piece {
 	// token-level split using tokenizer
 	ids := c.tk.Encode(text)
+	spans, aligned := c.tokenSpans(text, ids)
 	w := c.maxTokens
 	o := c.overlap
-	var out []document.Chunk
+	var out []piece
+	cursor := 0
 	for i := 0; i < len(ids); i += (w - o) {
 		end := i + w
 		if end > len(ids) {
@@ -171,11 +236,24 @@ func (c *SimpleChunker) splitLargeText(docID, text string) []document.Chunk {
 		}
 		segIDs := ids[i:end]
 		segText := c.tk.DecodeIds(segIDs)
-		out = append(out, document.Chunk{
-			ID:         document.GenDocumentID(docID, segText),
-			DocumentID: docID,
-			Content:    segText,
-			TokenCount: len(segIDs),
+		startByte, endByte := 0, len(text)
+		if aligned {
+			// Use the exact source slice so the content matches the highlighted region.
+			startByte, endByte = spans[i][0], spans[end-1][1]
+			segText = text[startByte:endByte]
+		} else if idx := strings.Index(text[cursor:], segText); segText != "" && idx >= 0 {
+			startByte, endByte = cursor+idx, cursor+idx+len(segText)
+			cursor = startByte + 1
+		}
+		out = append(out, piece{
+			chunk: document.Chunk{
+				ID:         document.GenDocumentID(docID, segText),
+				DocumentID: docID,
+				Content:    segText,
+				TokenCount: len(segIDs),
+			},
+			start: base + startByte,
+			end:   base + endByte,
 		})
 		if end == len(ids) {
 			break
@@ -184,27 +262,86 @@ func (c *SimpleChunker) splitLargeText(docID, text string) []document.Chunk {
 	return out
 }
 
-func mergeTinyChunks(chunks []document.Chunk, minTokens int) []document.Chunk {
-	if len(chunks) == 0 {
-		return chunks
+// tokenSpans locates each token in text and returns its byte range. aligned is false
+// when a decoded token cannot be found, e.g. for tokenizers that normalize their output.
+func (c *SimpleChunker) tokenSpans(text string, ids []int) (spans [][2]int, aligned bool) {
+	spans = make([][2]int, len(ids))
+	cursor := 0
+	for i, id := range ids {
+		tok := c.tk.DecodeIds([]int{id})
+		idx := strings.Index(text[cursor:], tok)
+		if tok == "" || idx < 0 {
+			return nil, false
+		}
+		spans[i] = [2]int{cursor + idx, cursor + idx + len(tok)}
+		cursor = spans[i][1]
 	}
-	var out []document.Chunk
-	for i := 0; i < len(chunks); i++ {
-		c := chunks[i]
-		if c.TokenCount >= minTokens {
-			out = append(out, c)
+	return spans, true
+}
+
+// runeOffsets maps byte positions in the normalized text to rune positions in the original.
+type runeOffsets []int
+
+// normalizeNewlines converts CRLF line endings to LF and records where each byte of the
+// result came from in the original text.
+func normalizeNewlines(orig string) (string, runeOffsets) {
+	var sb strings.Builder
+	sb.Grow(len(orig))
+	offsets := make(runeOffsets, 0, len(orig)+1)
+	r := 0
+	for i := 0; i < len(orig); {
+		if orig[i] == '\r' && i+1 < len(orig) && orig[i+1] == '\n' {
+			i++
+			r++
+			continue
+		}
+		_, size := utf8.DecodeRuneInString(orig[i:])
+		for k := 0; k < size; k++ {
+			offsets = append(offsets, r)
+		}
+		sb.WriteString(orig[i : i+size])
+		i += size
+		r++
+	}
+	offsets = append(offsets, r)
+	return sb.String(), offsets
+}
+
+// span converts a half-open byte range of the normalized text into original rune offsets.
+func (o runeOffsets) span(start, end int) (int, int) {
+	if end <= start {
+		return o[start], o[start]
+	}
+	return o[start], o[end-1] + 1
+}
+
+// mergeTinyChunks folds chunks below minTokens into a neighbour. A merged chunk
+// covers both ranges and its content is that slice of text, so it may include
+// separators or headings that lay between them.
+func (c *SimpleChunker) mergeTinyChunks(text string, pieces []piece, minTokens int) []piece {
+	if len(pieces) == 0 {
+		return pieces
+	}
+	var out []piece
+	for i := 0; i < len(pieces); i++ {
+		p := pieces[i]
+		if p.chunk.TokenCount >= minTokens {
+			out = append(out, p)
 			continue
 		}
 		if len(out) > 0 {
 			prev := &out[len(out)-1]
-			prev.Content = prev.Content + "\n\n" + c.Content
-			prev.TokenCount = prev.TokenCount + c.TokenCount
-		} else if i+1 < len(chunks) {
+			prev.end = max(prev.end, p.end)
+			prev.chunk.Content = text[prev.start:prev.end]
+			prev.chunk.TokenCount = c.tk.CountTokens(prev.chunk.Content)
+		} else if i+1 < len(pieces) {
 			// merge with next
-			chunks[i+1].Content = c.Content + "\n\n" + chunks[i+1].Content
-			chunks[i+1].TokenCount += c.TokenCount
+			next := &pieces[i+1]
+			next.start = min(next.start, p.start)
+			next.chunk.Content = text[next.start:next.end]
+			next.chunk.TokenCount = c.tk.CountTokens(next.chunk.Content)
 		} else {
-			out = append(out, c)
+			out = append(out, p)
 		}
 	}
 	return out
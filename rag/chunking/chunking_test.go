@@ -0,0 +1,135 @@
+package chunking
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/sweetpotato0/ai-allin/rag/document"
+)
+
+// words returns n distinct space-separated words starting with prefix.
+func words(prefix string, n int) string {
+	out := make([]string, n)
+	for i := range out {
+		out[i] = prefix + strings.Repeat("x", i%5) + string(rune('a'+i%26))
+	}
+	return strings.Join(out, " ")
+}
+
+// checkOffsets asserts that every chunk's content is the source range it records.
+func checkOffsets(t *testing.T, source string, chunks []document.Chunk) {
+	t.Helper()
+	runes := []rune(source)
+	for i, chunk := range chunks {
+		if chunk.StartRune < 0 || chunk.EndRune > len(runes) || chunk.StartRune > chunk.EndRune {
+			t.Fatalf("chunk %d has invalid offsets [%d,%d)", i, chunk.StartRune, chunk.EndRune)
+		}
+		got := strings.ReplaceAll(string(runes[chunk.StartRune:chunk.EndRune]), "\r\n", "\n")
+		if got != chunk.Content {
+			t.Fatalf("chunk %d offsets [%d,%d) select %q, content is %q", i, chunk.StartRune, chunk.EndRune, got, chunk.Content)
+		}
+	}
+}
+
+func TestChunkOffsetsMatchContent(t *testing.T) {
+	cases := []struct {
+		name    string
+		content string
+		opts    []Option
+	}{
+		{
+			name:    "paragraphs",
+			content: "  " + words("alpha", 50) + "  \n\n\n" + words("beta", 45) + "\n",
+		},
+		{
+			name:    "tiny paragraphs are merged",
+			content: "# Intro\n\nshort one.\n\n" + words("gamma", 60) + "\n\n# Tail\n\ntiny\n",
+		},
+		{
+			name:    "overlapping windows",
+			content: "lead in\n\n" + words("delta", 200),
+			opts:    []Option{WithMaxToken(60), WithOverlap(15)},
+		},
+		{
+			name:    "multibyte text",
+			content: "序言：" + strings.Repeat("知识库检索。", 30) + "\n\n" + words("épée", 50),
+			opts:    []Option{WithMaxToken(50), WithOverlap(10)},
+		},
+		{
+			name:    "crlf line endings",
+			content: "# Title\r\n\r\n" + words("eps", 45) + "\r\n\r\n" + words("zeta", 45) + "\r\n",
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			chunks, err := NewSimpleChunker(tc.opts...).Chunk(context.Background(), document.Document{ID: "doc", Content: tc.content})
+			if err != nil {
+				t.Fatalf("Chunk: %v", err)
+			}
+			if len(chunks) == 0 {
+				t.Fatal("expected chunks")
+			}
+			checkOffsets(t, tc.content, chunks)
+			for i, chunk := range chunks {
+				if chunk.Ordinal != i {
+					t.Fatalf("chunk %d has ordinal %d", i, chunk.Ordinal)
+				}
+			}
+		})
+	}
+}
+
+func TestChunkWindowsOverlap(t *testing.T) {
+	content := words("omega", 200)
+	chunks, err := NewSimpleChunker(WithMaxToken(60), WithOverlap(15)).Chunk(context.Background(), document.Document{ID: "doc", Content: content})
+	if err != nil {
+		t.Fatalf("Chunk: %v", err)
+	}
+	if len(chunks) < 3 {
+		t.Fatalf("expected several windows, got %d", len(chunks))
+	}
+	for i := 1; i < len(chunks); i++ {
+		if chunks[i].StartRune >= chunks[i-1].EndRune {
+			t.Fatalf("chunk %d [%d,%d) does not overlap chunk %d ending at %d", i, chunks[i].StartRune, chunks[i].EndRune, i-1, chunks[i-1].EndRune)
+		}
+	}
+	if last := chunks[len(chunks)-1]; last.EndRune != len([]rune(content)) {
+		t.Fatalf("expected the last window to end the document, got %d", last.EndRune)
+	}
+}
+
+func TestChunkMetadata(t *testing.T) {
+	doc := document.Document{
+		ID:       "doc",
+		Source:   "handbook.md",
+		Content:  words("alpha", 50) + "\n\n" + words("beta", 50),
+		Metadata: map[string]any{"team": "search"},
+	}
+	chunks, err := NewSimpleChunker().Chunk(context.Background(), doc)
+	if err != nil {
+		t.Fatalf("Chunk: %v", err)
+	}
+	if len(chunks) != 2 {
+		t.Fatalf("expected 2 chunks, got %d", len(chunks))
+	}
+	for i, chunk := range chunks {
+		if chunk.Metadata["team"] != "search" || chunk.Metadata["source"] != "handbook.md" || chunk.Metadata[ChunkIndexKey] != i {
+			t.Fatalf("chunk %d metadata = %v", i, chunk.Metadata)
+		}
+	}
+	chunks[0].Metadata["team"] = "changed"
+	if doc.Metadata["team"] != "search" {
+		t.Fatal("chunk metadata must not alias the document's")
+	}
+
+	chunks, err = NewSimpleChunker(WithPropagateMetadata(false)).Chunk(context.Background(), doc)
+	if err != nil {
+		t.Fatalf("Chunk: %v", err)
+	}
+	for i, chunk := range chunks {
+		if chunk.Metadata != nil {
+			t.Fatalf("chunk %d has metadata %v with propagation disabled", i, chunk.Metadata)
+		}
+	}
+}
@@ -5,6 +5,8 @@ import (
 	"encoding/hex"
 	"fmt"
 	"time"
+
+	"github.com/sweetpotato0/ai-allin/vector"
 )
 
 // Document represents a knowledge source that can be chunked and indexed.
@@ -42,13 +44,14 @@ func GenDocumentID(prefix, s string) string {
 	return hex.EncodeToString(h[:])[:16]
 }
 
-// GenChunkID returns a globally unique chunk identifier derived from document ID.
+// GenChunkID returns a globally unique chunk identifier derived from document ID,
+// joined with vector.ChunkIDSeparator so the document can be recovered from it.
 func GenChunkID(prefix, docID string) string {
 	next := prefix + fmt.Sprintf("%d", time.Now().UnixNano())
 	if docID == "" {
 		return fmt.Sprintf("chunk_%s", next)
 	}
-	return fmt.Sprintf("%s_chunk_%s", docID, next)
+	return docID + vector.ChunkIDSeparator + next
 }
 
 // Clone returns a deep copy of the document.
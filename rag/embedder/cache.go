@@ -0,0 +1,153 @@
+package embedder
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"slices"
+	"strconv"
+	"sync"
+
+	"github.com/sweetpotato0/ai-allin/vector"
+)
+
+var _ vector.Embedder = (*Cached)(nil)
+
+// CacheStore persists embedding vectors keyed by a hash of their text.
+// Implementations must be safe for concurrent use. A Redis implementation maps
+// Get to GET and Set to SET, storing each vector as its float32 values in
+// little-endian order (4 bytes per value).
+type CacheStore interface {
+	// Get returns the vector stored under key, or false when there is none.
+	Get(ctx context.Context, key string) ([]float32, bool, error)
+	// Set stores vec under key.
+	Set(ctx context.Context, key string, vec []float32) error
+}
+
+// Cached implements vector.Embedder by serving vectors for previously embedded
+// text from a CacheStore and calling the base embedder only on misses. Keys
+// include the base dimension, but not the model, so give each model its own
+// store. Store errors are treated as misses and never fail a call.
+type Cached struct {
+	base  vector.Embedder
+	store CacheStore
+}
+
+// NewCached wraps base with a cache. A nil store selects a new MemoryCacheStore.
+func NewCached(base vector.Embedder, store CacheStore) *Cached {
+	if store == nil {
+		store = NewMemoryCacheStore()
+	}
+	return &Cached{base: base, store: store}
+}
+
+// Embed returns the cached vector for text, embedding and caching it on a miss.
+func (c *Cached) Embed(ctx context.Context, text string) ([]float32, error) {
+	key := c.key(text)
+	if vec, ok, err := c.store.Get(ctx, key); err == nil && ok {
+		return vec, nil
+	}
+	vec, err := c.base.Embed(ctx, text)
+	if err != nil {
+		return nil, err
+	}
+	_ = c.store.Set(ctx, key, vec)
+	return vec, nil
+}
+
+// EmbedBatch serves cached vectors and embeds the remaining distinct texts with
+// a single base EmbedBatch call. Results keep the order of texts, and repeated
+// texts receive separate copies of the same vector.
+func (c *Cached) EmbedBatch(ctx context.Context, texts []string) ([][]float32, error) {
+	out := make([][]float32, len(texts))
+	keys := make([]string, len(texts))
+	pending := make(map[string][]int) // Missed key -> positions in texts
+	var missed []string
+	for i, text := range texts {
+		keys[i] = c.key(text)
+		if positions, ok := pending[keys[i]]; ok {
+			pending[keys[i]] = append(positions, i)
+			continue
+		}
+		if vec, ok, err := c.store.Get(ctx, keys[i]); err == nil && ok {
+			out[i] = vec
+			continue
+		}
+		pending[keys[i]] = []int{i}
+		missed = append(missed, text)
+	}
+	if len(missed) == 0 {
+		return out, nil
+	}
+
+	vecs, err := c.base.EmbedBatch(ctx, missed)
+	if err != nil {
+		return nil, err
+	}
+	if len(vecs) != len(missed) {
+		return nil, fmt.Errorf("embed batch returned %d vectors for %d texts", len(vecs), len(missed))
+	}
+	for i, text := range missed {
+		key := c.key(text)
+		for j, pos := range pending[key] {
+			if j == 0 {
+				out[pos] = vecs[i]
+			} else {
+				// Duplicate texts get their own copy so callers can modify them.
+				out[pos] = slices.Clone(vecs[i])
+			}
+		}
+		_ = c.store.Set(ctx, key, vecs[i])
+	}
+	return out, nil
+}
+
+// Dimension returns the dimension of the base embedder.
+func (c *Cached) Dimension() int {
+	return c.base.Dimension()
+}
+
+func (c *Cached) key(text string) string {
+	sum := sha256.Sum256([]byte(text))
+	return strconv.Itoa(c.base.Dimension()) + ":" + hex.EncodeToString(sum[:])
+}
+
+var _ CacheStore = (*MemoryCacheStore)(nil)
+
+// MemoryCacheStore is an in-process CacheStore without eviction.
+type MemoryCacheStore struct {
+	mu      sync.RWMutex
+	vectors map[string][]float32
+}
+
+// NewMemoryCacheStore creates an empty in-memory cache store.
+func NewMemoryCacheStore() *MemoryCacheStore {
+	return &MemoryCacheStore{vectors: make(map[string][]float32)}
+}
+
+// Get implements CacheStore.
+func (s *MemoryCacheStore) Get(ctx context.Context, key string) ([]float32, bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	vec, ok := s.vectors[key]
+	if !ok {
+		return nil, false, nil
+	}
+	return append([]float32(nil), vec...), true, nil
+}
+
+// Set implements CacheStore.
+func (s *MemoryCacheStore) Set(ctx context.Context, key string, vec []float32) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.vectors[key] = append([]float32(nil), vec...)
+	return nil
+}
+
+// Len returns the number of cached vectors.
+func (s *MemoryCacheStore) Len() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return len(s.vectors)
+}
@@ -0,0 +1,115 @@
+package embedder
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+)
+
+// stubEmbedder embeds a text as its length and records the texts it was asked for.
+type stubEmbedder struct {
+	dim   int
+	err   error
+	calls [][]string
+}
+
+func (s *stubEmbedder) Embed(ctx context.Context, text string) ([]float32, error) {
+	vecs, err := s.EmbedBatch(ctx, []string{text})
+	if err != nil {
+		return nil, err
+	}
+	return vecs[0], nil
+}
+
+func (s *stubEmbedder) EmbedBatch(ctx context.Context, texts []string) ([][]float32, error) {
+	s.calls = append(s.calls, texts)
+	if s.err != nil {
+		return nil, s.err
+	}
+	vecs := make([][]float32, len(texts))
+	for i, text := range texts {
+		vecs[i] = make([]float32, s.dim)
+		vecs[i][0] = float32(len(text))
+	}
+	return vecs, nil
+}
+
+func (s *stubEmbedder) Dimension() int { return s.dim }
+
+func TestCachedEmbedHitAndMiss(t *testing.T) {
+	base := &stubEmbedder{dim: 2}
+	store := NewMemoryCacheStore()
+	c := NewCached(base, store)
+	ctx := context.Background()
+
+	first, err := c.Embed(ctx, "hello")
+	if err != nil {
+		t.Fatalf("Embed: %v", err)
+	}
+	first[0] = 99 // callers may modify the result without affecting the cache
+	second, err := c.Embed(ctx, "hello")
+	if err != nil {
+		t.Fatalf("Embed: %v", err)
+	}
+	if len(base.calls) != 1 || store.Len() != 1 {
+		t.Fatalf("expected one base call and one cached vector, got %d and %d", len(base.calls), store.Len())
+	}
+	if second[0] != 5 {
+		t.Fatalf("expected the cached vector, got %v", second)
+	}
+}
+
+func TestCachedEmbedBatch(t *testing.T) {
+	base := &stubEmbedder{dim: 2}
+	c := NewCached(base, nil)
+	ctx := context.Background()
+	if _, err := c.Embed(ctx, "cached"); err != nil {
+		t.Fatalf("Embed: %v", err)
+	}
+
+	vecs, err := c.EmbedBatch(ctx, []string{"a", "cached", "bb", "a"})
+	if err != nil {
+		t.Fatalf("EmbedBatch: %v", err)
+	}
+	if got := fmt.Sprint(base.calls[1]); got != "[a bb]" {
+		t.Fatalf("expected only distinct misses to reach the base embedder, got %s", got)
+	}
+	want := []float32{1, 6, 2, 1}
+	for i, vec := range vecs {
+		if vec[0] != want[i] {
+			t.Fatalf("vector %d = %v, want first value %v", i, vec, want[i])
+		}
+	}
+
+	// Duplicate texts must not share a backing array.
+	vecs[0][0] = 42
+	if vecs[3][0] != 1 {
+		t.Fatalf("modifying one duplicate changed the other: %v", vecs[3])
+	}
+
+	again, err := c.EmbedBatch(ctx, []string{"a", "bb"})
+	if err != nil {
+		t.Fatalf("EmbedBatch: %v", err)
+	}
+	if len(base.calls) != 2 || again[0][0] != 1 || again[1][0] != 2 {
+		t.Fatalf("expected all hits, got %d base calls and %v", len(base.calls), again)
+	}
+}
+
+func TestCachedPropagatesErrors(t *testing.T) {
+	boom := errors.New("boom")
+	store := NewMemoryCacheStore()
+	c := NewCached(&stubEmbedder{dim: 2, err: boom}, store)
+	ctx := context.Background()
+
+	if _, err := c.Embed(ctx, "a"); !errors.Is(err, boom) {
+		t.Fatalf("Embed error = %v, want %v", err, boom)
+	}
+	if _, err := c.EmbedBatch(ctx, []string{"a", "b"}); !errors.Is(err, boom) {
+		t.Fatalf("EmbedBatch error = %v, want %v", err, boom)
+	}
+	if store.Len() != 0 {
+		t.Fatalf("expected nothing cached after errors, got %d", store.Len())
+	}
+}
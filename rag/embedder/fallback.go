@@ -0,0 +1,75 @@
+package embedder
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/sweetpotato0/ai-allin/vector"
+)
+
+var _ vector.Embedder = (*Fallback)(nil)
+
+// Fallback implements vector.Embedder by trying a primary embedder and moving on to
+// the fallbacks in order when a call fails. Vectors from different models live in
+// different spaces even when dimensions match, so fallbacks should be replicas of
+// the same model (e.g. another region or host) for results to stay comparable.
+type Fallback struct {
+	embedders []vector.Embedder
+	dimension int
+}
+
+// NewFallback builds a fallback embedder. All embedders must report the same dimension.
+func NewFallback(primary vector.Embedder, fallbacks ...vector.Embedder) (*Fallback, error) {
+	if primary == nil {
+		return nil, fmt.Errorf("primary embedder cannot be nil")
+	}
+	embedders := append([]vector.Embedder{primary}, fallbacks...)
+	dim := primary.Dimension()
+	for i, emb := range embedders[1:] {
+		if emb == nil {
+			return nil, fmt.Errorf("fallback embedder %d is nil", i)
+		}
+		if d := emb.Dimension(); d != dim {
+			return nil, fmt.Errorf("fallback embedder %d has dimension %d, primary has %d", i, d, dim)
+		}
+	}
+	return &Fallback{embedders: embedders, dimension: dim}, nil
+}
+
+// Embed returns the first successful embedding.
+func (f *Fallback) Embed(ctx context.Context, text string) ([]float32, error) {
+	var errs []error
+	for i, emb := range f.embedders {
+		vec, err := emb.Embed(ctx, text)
+		if err == nil {
+			return vec, nil
+		}
+		errs = append(errs, fmt.Errorf("embedder %d: %w", i, err))
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	return nil, fmt.Errorf("all embedders failed: %w", errors.Join(errs...))
+}
+
+// EmbedBatch returns the first successful batch embedding.
+func (f *Fallback) EmbedBatch(ctx context.Context, texts []string) ([][]float32, error) {
+	var errs []error
+	for i, emb := range f.embedders {
+		vecs, err := emb.EmbedBatch(ctx, texts)
+		if err == nil {
+			return vecs, nil
+		}
+		errs = append(errs, fmt.Errorf("embedder %d: %w", i, err))
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	return nil, fmt.Errorf("all embedders failed: %w", errors.Join(errs...))
+}
+
+// Dimension returns the shared embedding dimension.
+func (f *Fallback) Dimension() int {
+	return f.dimension
+}
@@ -0,0 +1,91 @@
+package embedder
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestFallbackUsesNextEmbedderOnError(t *testing.T) {
+	primary := &stubEmbedder{dim: 2, err: errors.New("primary down")}
+	replica := &stubEmbedder{dim: 2}
+	f, err := NewFallback(primary, replica)
+	if err != nil {
+		t.Fatalf("NewFallback: %v", err)
+	}
+	ctx := context.Background()
+
+	vec, err := f.Embed(ctx, "abc")
+	if err != nil || vec[0] != 3 {
+		t.Fatalf("Embed = %v, %v; want the replica's vector", vec, err)
+	}
+	vecs, err := f.EmbedBatch(ctx, []string{"a", "bb"})
+	if err != nil || len(vecs) != 2 || vecs[1][0] != 2 {
+		t.Fatalf("EmbedBatch = %v, %v; want the replica's vectors", vecs, err)
+	}
+	if len(primary.calls) != 2 || len(replica.calls) != 2 {
+		t.Fatalf("expected both embedders to be tried per call, got %d and %d", len(primary.calls), len(replica.calls))
+	}
+	if f.Dimension() != 2 {
+		t.Fatalf("Dimension() = %d, want 2", f.Dimension())
+	}
+}
+
+func TestFallbackSkipsFallbacksOnSuccess(t *testing.T) {
+	primary, replica := &stubEmbedder{dim: 2}, &stubEmbedder{dim: 2}
+	f, err := NewFallback(primary, replica)
+	if err != nil {
+		t.Fatalf("NewFallback: %v", err)
+	}
+	if _, err := f.Embed(context.Background(), "a"); err != nil {
+		t.Fatalf("Embed: %v", err)
+	}
+	if len(replica.calls) != 0 {
+		t.Fatalf("expected the fallback to stay unused, got %d calls", len(replica.calls))
+	}
+}
+
+func TestNewFallbackValidatesEmbedders(t *testing.T) {
+	if _, err := NewFallback(nil); err == nil {
+		t.Fatal("expected an error for a nil primary")
+	}
+	if _, err := NewFallback(&stubEmbedder{dim: 2}, nil); err == nil {
+		t.Fatal("expected an error for a nil fallback")
+	}
+	if _, err := NewFallback(&stubEmbedder{dim: 2}, &stubEmbedder{dim: 3}); err == nil {
+		t.Fatal("expected an error for a dimension mismatch")
+	}
+}
+
+func TestFallbackReportsAllErrors(t *testing.T) {
+	errPrimary, errReplica := errors.New("primary down"), errors.New("replica down")
+	f, err := NewFallback(&stubEmbedder{dim: 2, err: errPrimary}, &stubEmbedder{dim: 2, err: errReplica})
+	if err != nil {
+		t.Fatalf("NewFallback: %v", err)
+	}
+	ctx := context.Background()
+
+	if _, err := f.Embed(ctx, "a"); !errors.Is(err, errPrimary) || !errors.Is(err, errReplica) {
+		t.Fatalf("Embed error = %v, want both embedder errors", err)
+	}
+	if _, err := f.EmbedBatch(ctx, []string{"a"}); !errors.Is(err, errPrimary) || !errors.Is(err, errReplica) {
+		t.Fatalf("EmbedBatch error = %v, want both embedder errors", err)
+	}
+}
+
+func TestFallbackStopsWhenContextIsDone(t *testing.T) {
+	primary := &stubEmbedder{dim: 2, err: context.Canceled}
+	replica := &stubEmbedder{dim: 2}
+	f, err := NewFallback(primary, replica)
+	if err != nil {
+		t.Fatalf("NewFallback: %v", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := f.Embed(ctx, "a"); !errors.Is(err, context.Canceled) {
+		t.Fatalf("Embed error = %v, want context.Canceled", err)
+	}
+	if len(replica.calls) != 0 {
+		t.Fatalf("expected no fallback after cancellation, got %d calls", len(replica.calls))
+	}
+}
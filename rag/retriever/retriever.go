@@ -26,6 +26,7 @@ type Config struct {
 	RerankTopK   int
 	Preprocessor PreprocessFunc
 	Logger       *slog.Logger
+	Collection   string
 }
 
 // Option customizes retriever config.
@@ -58,6 +59,13 @@ func WithPreprocessor(fn PreprocessFunc) Option {
 	}
 }
 
+// WithCollection scopes the vector store to the named collection.
+func WithCollection(name string) Option {
+	return func(cfg *Config) {
+		cfg.Collection = name
+	}
+}
+
 // WithLogger injects a structured logger.
 func WithLogger(logger *slog.Logger) Option {
 	return func(cfg *Config) {
@@ -99,6 +107,9 @@ func New(store vector.VectorStore, emb embedder.Embedder, chunker chunking.Chunk
 	if logger == nil {
 		logger = logging.WithComponent("retriever")
 	}
+	if cfg.Collection != "" && store != nil {
+		store = store.WithCollection(cfg.Collection)
+	}
 	return &Retriever{
 		store:      store,
 		embedder:   emb,
@@ -181,9 +192,10 @@ func (r *Retriever) IndexDocuments(ctx context.Context, docs ...document.Documen
 				return spanErr
 			}
 			embedding := &vector.Embedding{
-				ID:     chunk.ID,
-				Vector: vec,
-				Text:   chunk.Content,
+				ID:       chunk.ID,
+				Vector:   vec,
+				Text:     chunk.Content,
+				Metadata: embeddingMetadata(doc, chunk),
 			}
 			if err := r.store.AddEmbedding(ctx, embedding); err != nil {
 				if r.logger != nil {
@@ -217,9 +229,10 @@ func (r *Retriever) IndexDocuments(ctx context.Context, docs ...document.Documen
 					return spanErr
 				}
 				summary := &vector.Embedding{
-					ID:     summaryChunk.ID,
-					Vector: vec,
-					Text:   summaries[i].Summary,
+					ID:       summaryChunk.ID,
+					Vector:   vec,
+					Text:     summaries[i].Summary,
+					Metadata: embeddingMetadata(doc, summaryChunk),
 				}
 				if err := r.store.AddEmbedding(ctx, summary); err != nil {
 					if r.logger != nil {
@@ -240,7 +253,15 @@ func (r *Retriever) IndexDocuments(ctx context.Context, docs ...document.Documen
 
 // Search executes semantic search followed by reranking.
 func (r *Retriever) Search(ctx context.Context, query string) ([]reranker.Result, error) {
-	ctx, span := retrieverTracer.Start(ctx, "Retriever.Search", oteltrace.WithAttributes(attribute.String("query", trimLogText(query, 80))))
+	return r.SearchWithFilter(ctx, query, nil)
+}
+
+// SearchWithFilter works like Search but only returns chunks whose metadata,
+// merged with their document's metadata, matches filter exactly. Stores that
+// implement vector.FilteredSearcher apply the filter before ranking; others are
+// searched as usual and the hits filtered afterwards.
+func (r *Retriever) SearchWithFilter(ctx context.Context, query string, filter map[string]any) ([]reranker.Result, error) {
+	ctx, span := retrieverTracer.Start(ctx, "Retriever.Search", oteltrace.WithAttributes(attribute.String("query", trimLogText(query, 80)), attribute.Int("filter.keys", len(filter))))
 	var spanErr error
 	defer func() { telemetry.End(span, spanErr) }()
 	if r.logger != nil {
@@ -254,7 +275,12 @@ func (r *Retriever) Search(ctx context.Context, query string) ([]reranker.Result
 		spanErr = fmt.Errorf("embed query: %w", err)
 		return nil, spanErr
 	}
-	results, err := r.store.Search(ctx, queryVec, r.cfg.SearchTopK)
+	var results []*vector.Embedding
+	if searcher, ok := r.store.(vector.FilteredSearcher); ok && len(filter) > 0 {
+		results, err = searcher.SearchWithFilter(ctx, queryVec, r.cfg.SearchTopK, filter)
+	} else {
+		results, err = r.store.Search(ctx, queryVec, r.cfg.SearchTopK)
+	}
 	if err != nil {
 		if r.logger != nil {
 			r.logger.Error("vector search failed", "error", err)
@@ -266,7 +292,7 @@ func (r *Retriever) Search(ctx context.Context, query string) ([]reranker.Result
 	candidates := make([]reranker.Candidate, 0, len(results))
 	for _, hit := range results {
 		chunk, ok := r.lookupChunk(hit.ID)
-		if !ok {
+		if !ok || !r.matchesFilter(chunk, filter) {
 			continue
 		}
 		candidates = append(candidates, reranker.Candidate{
@@ -316,6 +342,30 @@ func (r *Retriever) Document(id string) (document.Document, bool) {
 	return doc.Clone(), ok
 }
 
+// matchesFilter reports whether the metadata of chunk and its document match filter.
+func (r *Retriever) matchesFilter(chunk document.Chunk, filter map[string]any) bool {
+	if len(filter) == 0 {
+		return true
+	}
+	doc, _ := r.Document(chunk.DocumentID)
+	return vector.MatchesFilter(embeddingMetadata(doc, chunk), filter)
+}
+
+// embeddingMetadata merges document and chunk metadata; document keys win.
+func embeddingMetadata(doc document.Document, chunk document.Chunk) map[string]any {
+	if len(doc.Metadata) == 0 && len(chunk.Metadata) == 0 {
+		return nil
+	}
+	merged := make(map[string]any, len(doc.Metadata)+len(chunk.Metadata))
+	for k, v := range chunk.Metadata {
+		merged[k] = v
+	}
+	for k, v := range doc.Metadata {
+		merged[k] = v
+	}
+	return merged
+}
+
 // lookupChunk retrieves chunk metadata.
 func (r *Retriever) lookupChunk(id string) (document.Chunk, bool) {
 	r.mu.RLock()
@@ -341,6 +391,58 @@ func (r *Retriever) Clear(ctx context.Context) error {
 	return nil
 }
 
+// DeleteDocuments removes the documents and all of their chunks, including
+// summary chunks, from the retriever and the vector store. Unknown IDs are ignored.
+func (r *Retriever) DeleteDocuments(ctx context.Context, ids ...string) error {
+	ctx, span := retrieverTracer.Start(ctx, "Retriever.DeleteDocuments",
+		oteltrace.WithAttributes(attribute.Int("docs.count", len(ids))))
+	var spanErr error
+	defer func() { telemetry.End(span, spanErr) }()
+
+	targets := make(map[string]struct{}, len(ids))
+	for _, id := range ids {
+		targets[id] = struct{}{}
+	}
+	r.mu.RLock()
+	var chunkIDs []string
+	for id, chunk := range r.chunks {
+		if _, ok := targets[chunk.DocumentID]; ok {
+			chunkIDs = append(chunkIDs, id)
+		}
+	}
+	r.mu.RUnlock()
+
+	if r.store != nil && len(chunkIDs) > 0 {
+		if deleter, ok := r.store.(vector.BulkDeleter); ok {
+			if _, err := deleter.DeleteEmbeddings(ctx, chunkIDs...); err != nil {
+				spanErr = fmt.Errorf("delete chunks: %w", err)
+				return spanErr
+			}
+		} else {
+			for _, id := range chunkIDs {
+				if err := r.store.DeleteEmbedding(ctx, id); err != nil {
+					spanErr = fmt.Errorf("delete chunk %s: %w", id, err)
+					return spanErr
+				}
+			}
+		}
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, id := range chunkIDs {
+		delete(r.chunks, id)
+	}
+	for id := range targets {
+		delete(r.documents, id)
+	}
+	if r.logger != nil {
+		r.logger.Info("retriever deleted documents", "count", len(ids), "chunks", len(chunkIDs))
+	}
+	span.SetAttributes(attribute.Int("chunks.count", len(chunkIDs)))
+	return nil
+}
+
 // Count returns number of chunks indexed.
 func (r *Retriever) Count(ctx context.Context) (int, error) {
 	if r.store == nil {
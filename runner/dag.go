@@ -0,0 +1,196 @@
+package runner
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// ErrDependencyFailed marks results of DAG tasks skipped because a task they
+// depend on failed.
+var ErrDependencyFailed = errors.New("dependency failed")
+
+// DAGRunner executes tasks in dependency order. A task starts as soon as every
+// task in its DependsOn has succeeded, and independent tasks run in parallel up
+// to the concurrency limit.
+type DAGRunner struct {
+	runner Runner
+}
+
+// NewDAGRunner creates a DAG runner running at most maxConcurrency tasks at once.
+func NewDAGRunner(maxConcurrency int) *DAGRunner {
+	return &DAGRunner{
+		runner: New(maxConcurrency),
+	}
+}
+
+// RunDAG executes tasks honouring their DependsOn lists and returns results
+// indexed like tasks, together with the task errors joined.
+//
+// Each task receives the outputs of its dependencies: BuildInput is called with
+// a state whose Values map dependency IDs to their outputs and whose Output is
+// the output of the last dependency listed. Without BuildInput the task's Input
+// is used, or the dependency outputs joined by blank lines when Input is empty.
+// Tasks depending on a failed task are not run; their error wraps
+// ErrDependencyFailed. Duplicate or empty IDs, unknown dependencies and cycles
+// are reported before any task runs.
+func (dr *DAGRunner) RunDAG(ctx context.Context, tasks []*Task) (Results, error) {
+	index, err := validateDAG(tasks)
+	if err != nil {
+		return nil, err
+	}
+
+	results := make(Results, len(tasks))
+	pending := make([]int, len(tasks))
+	dependents := make([][]int, len(tasks))
+	for i, task := range tasks {
+		pending[i] = len(task.DependsOn)
+		for _, dep := range task.DependsOn {
+			dependents[index[dep]] = append(dependents[index[dep]], i)
+		}
+	}
+
+	done := make(chan int)
+	running := 0
+	start := func(i int) {
+		task := tasks[i]
+		state := dependencyState(task, index, results)
+		running++
+		go func() {
+			if err := ctx.Err(); err != nil {
+				results[i] = &Result{TaskID: task.ID, Error: fmt.Errorf("%w: %w", ErrTaskNotStarted, context.Cause(ctx))}
+			} else {
+				results[i] = runTask(ctx, dr.runner, task, func() (string, error) {
+					return dagInput(ctx, task, state)
+				})
+			}
+			done <- i
+		}()
+	}
+
+	for i := range tasks {
+		if pending[i] == 0 {
+			start(i)
+		}
+	}
+	for running > 0 {
+		finished := []int{<-done}
+		running--
+		for len(finished) > 0 {
+			i := finished[0]
+			finished = finished[1:]
+			for _, next := range dependents[i] {
+				pending[next]--
+				if pending[next] > 0 {
+					continue
+				}
+				if failed := failedDependency(tasks[next], index, results); failed != "" {
+					results[next] = &Result{TaskID: tasks[next].ID, Error: fmt.Errorf("%w: %s", ErrDependencyFailed, failed)}
+					finished = append(finished, next)
+					continue
+				}
+				start(next)
+			}
+		}
+	}
+
+	return results, errors.Join(results.Errors()...)
+}
+
+// validateDAG checks task IDs and dependencies and returns the index of each ID.
+func validateDAG(tasks []*Task) (map[string]int, error) {
+	index := make(map[string]int, len(tasks))
+	for i, task := range tasks {
+		if task == nil {
+			return nil, fmt.Errorf("task %d is nil", i)
+		}
+		if task.ID == "" {
+			return nil, fmt.Errorf("task %d has no ID", i)
+		}
+		if _, dup := index[task.ID]; dup {
+			return nil, fmt.Errorf("duplicate task ID %s", task.ID)
+		}
+		index[task.ID] = i
+	}
+
+	pending := make([]int, len(tasks))
+	dependents := make([][]int, len(tasks))
+	for i, task := range tasks {
+		for _, dep := range task.DependsOn {
+			j, ok := index[dep]
+			if !ok {
+				return nil, fmt.Errorf("task %s depends on unknown task %s", task.ID, dep)
+			}
+			pending[i]++
+			dependents[j] = append(dependents[j], i)
+		}
+	}
+
+	// Kahn's algorithm: whatever cannot be ordered is part of, or behind, a cycle.
+	var queue []int
+	for i := range tasks {
+		if pending[i] == 0 {
+			queue = append(queue, i)
+		}
+	}
+	for len(queue) > 0 {
+		i := queue[0]
+		queue = queue[1:]
+		for _, next := range dependents[i] {
+			pending[next]--
+			if pending[next] == 0 {
+				queue = append(queue, next)
+			}
+		}
+	}
+	var blocked []string
+	for i, task := range tasks {
+		if pending[i] > 0 {
+			blocked = append(blocked, task.ID)
+		}
+	}
+	if len(blocked) > 0 {
+		sort.Strings(blocked)
+		return nil, fmt.Errorf("dependency cycle among tasks %s", strings.Join(blocked, ", "))
+	}
+	return index, nil
+}
+
+// dependencyState collects the outputs of the dependencies of task.
+func dependencyState(task *Task, index map[string]int, results Results) *SequentialState {
+	state := NewSequentialState(nil)
+	for _, dep := range task.DependsOn {
+		result := results[index[dep]]
+		state.Values[dep] = result.Output
+		state.Output = result.Output
+		state.Results = append(state.Results, result)
+	}
+	return state
+}
+
+// dagInput derives the input of task from the outputs of its dependencies.
+func dagInput(ctx context.Context, task *Task, state *SequentialState) (string, error) {
+	if task.BuildInput != nil {
+		return task.BuildInput(ctx, state)
+	}
+	if task.Input != "" || len(task.DependsOn) == 0 {
+		return task.Input, nil
+	}
+	outputs := make([]string, 0, len(task.DependsOn))
+	for _, dep := range task.DependsOn {
+		outputs = append(outputs, state.Values[dep].(string))
+	}
+	return strings.Join(outputs, "\n\n"), nil
+}
+
+// failedDependency returns the ID of a dependency of task that did not succeed.
+func failedDependency(task *Task, index map[string]int, results Results) string {
+	for _, dep := range task.DependsOn {
+		if results[index[dep]].Error != nil {
+			return dep
+		}
+	}
+	return ""
+}
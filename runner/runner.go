@@ -2,8 +2,10 @@ package runner
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"sync"
+	"time"
 
 	"github.com/sweetpotato0/ai-allin/agent"
 	"github.com/sweetpotato0/ai-allin/graph"
@@ -69,12 +71,15 @@ func (r *runner) RunGraph(ctx context.Context, g *graph.Graph, initialState grap
 // ParallelRunner executes multiple agents in parallel
 type ParallelRunner struct {
 	runner Runner
+	slots  chan struct{} // Dispatch slots used by RunParallelContext
 }
 
 // NewParallelRunner creates a new parallel runner
 func NewParallelRunner(maxConcurrency int) *ParallelRunner {
+	r := New(maxConcurrency).(*runner)
 	return &ParallelRunner{
-		runner: New(maxConcurrency),
+		runner: r,
+		slots:  make(chan struct{}, r.maxConcurrency),
 	}
 }
 
@@ -83,6 +88,19 @@ type Task struct {
 	ID    string
 	Agent *agent.Agent
 	Input string
+	// BuildInput, when set, derives the input from the shared state of a
+	// sequential run, or from the dependency outputs of a DAG run. Other
+	// runners ignore it.
+	BuildInput func(ctx context.Context, state *SequentialState) (string, error)
+	// DependsOn lists the IDs of tasks whose outputs this task consumes. Only
+	// DAGRunner honours it.
+	DependsOn []string
+	// Timeout bounds each attempt of the task in parallel and DAG runs; zero
+	// means no limit.
+	Timeout time.Duration
+	// MaxRetries is how many times parallel and DAG runs re-run a failed task
+	// with the same input, as long as the run's context has not ended.
+	MaxRetries int
 }
 
 // Result represents the result of a task execution
@@ -92,31 +110,80 @@ type Result struct {
 	Error  error
 }
 
-// RunParallel executes multiple tasks in parallel
-func (pr *ParallelRunner) RunParallel(ctx context.Context, tasks []*Task) []*Result {
-	results := make([]*Result, len(tasks))
+// ErrTaskNotStarted marks results of tasks that were never run because the
+// context ended first.
+var ErrTaskNotStarted = errors.New("task not started")
+
+// Results holds the results of a parallel run, indexed like its tasks.
+type Results []*Result
+
+// Errors returns the error of every failed task in task order, prefixed with
+// the task ID.
+func (rs Results) Errors() []error {
+	var errs []error
+	for _, result := range rs {
+		if result != nil && result.Error != nil {
+			errs = append(errs, fmt.Errorf("task %s: %w", result.TaskID, result.Error))
+		}
+	}
+	return errs
+}
+
+// FirstError returns the error of the first failed task in task order, or nil
+// when every task succeeded.
+func (rs Results) FirstError() error {
+	for _, result := range rs {
+		if result != nil && result.Error != nil {
+			return fmt.Errorf("task %s: %w", result.TaskID, result.Error)
+		}
+	}
+	return nil
+}
+
+// RunParallel executes multiple tasks in parallel. The result at index i
+// belongs to tasks[i]. Every task is started at once and waits for a
+// concurrency slot, so tasks still waiting when ctx ends fail with its error.
+func (pr *ParallelRunner) RunParallel(ctx context.Context, tasks []*Task) Results {
+	results := make(Results, len(tasks))
 	var wg sync.WaitGroup
 
 	for i, task := range tasks {
 		wg.Add(1)
 		go func(index int, t *Task) {
 			defer wg.Done()
-			defer func() {
-				if r := recover(); r != nil {
-					results[index] = &Result{
-						TaskID: t.ID,
-						Output: "",
-						Error:  fmt.Errorf("panic in task %s: %v", t.ID, r),
-					}
-				}
-			}()
+			results[index] = runTask(ctx, pr.runner, t, nil)
+		}(i, task)
+	}
 
-			output, err := pr.runner.Run(ctx, t.Agent, t.Input)
-			results[index] = &Result{
-				TaskID: t.ID,
-				Output: output,
-				Error:  err,
+	wg.Wait()
+	return results
+}
+
+// RunParallelContext executes tasks in parallel, starting them in order as
+// concurrency slots free up. Once ctx ends no further task is started: running
+// tasks see the cancellation through their context, and every task not yet
+// started gets a result whose error wraps ErrTaskNotStarted and the context
+// error. The result at index i belongs to tasks[i].
+func (pr *ParallelRunner) RunParallelContext(ctx context.Context, tasks []*Task) Results {
+	results := make(Results, len(tasks))
+	var wg sync.WaitGroup
+
+	for i, task := range tasks {
+		if !pr.acquireSlot(ctx) {
+			for j := i; j < len(tasks); j++ {
+				results[j] = &Result{
+					TaskID: tasks[j].ID,
+					Error:  fmt.Errorf("%w: %w", ErrTaskNotStarted, context.Cause(ctx)),
+				}
 			}
+			break
+		}
+
+		wg.Add(1)
+		go func(index int, t *Task) {
+			defer wg.Done()
+			defer func() { <-pr.slots }()
+			results[index] = runTask(ctx, pr.runner, t, nil)
 		}(i, task)
 	}
 
@@ -124,46 +191,208 @@ func (pr *ParallelRunner) RunParallel(ctx context.Context, tasks []*Task) []*Res
 	return results
 }
 
+// acquireSlot waits for a dispatch slot and reports whether one was taken
+// before ctx ended.
+func (pr *ParallelRunner) acquireSlot(ctx context.Context) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+	select {
+	case pr.slots <- struct{}{}:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// runTask runs a single task under its Timeout and MaxRetries. The input comes
+// from buildInput when set and from the task's Input otherwise, and is reused
+// unchanged for every attempt.
+func runTask(ctx context.Context, r Runner, t *Task, buildInput func() (string, error)) (result *Result) {
+	defer func() {
+		if r := recover(); r != nil {
+			result = &Result{
+				TaskID: t.ID,
+				Output: "",
+				Error:  fmt.Errorf("panic in task %s: %v", t.ID, r),
+			}
+		}
+	}()
+
+	input := t.Input
+	if buildInput != nil {
+		var err error
+		if input, err = buildInput(); err != nil {
+			return &Result{TaskID: t.ID, Error: fmt.Errorf("build input: %w", err)}
+		}
+	}
+
+	var output string
+	var err error
+	attempts := 0
+	for attempts <= max(t.MaxRetries, 0) {
+		// Retries never outlive the caller's context.
+		if attempts > 0 && ctx.Err() != nil {
+			break
+		}
+		attempts++
+		output, err = runAttempt(ctx, r, t, input)
+		if err == nil {
+			break
+		}
+	}
+	if err != nil && attempts > 1 {
+		err = fmt.Errorf("failed after %d attempts: %w", attempts, err)
+	}
+	return &Result{
+		TaskID: t.ID,
+		Output: output,
+		Error:  err,
+	}
+}
+
+// runAttempt runs the task agent once within the task timeout. A panic fails
+// the attempt; the runner semaphore is released by its deferred receive.
+func runAttempt(ctx context.Context, r Runner, t *Task, input string) (output string, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic in task %s: %v", t.ID, r)
+		}
+	}()
+
+	if t.Timeout <= 0 {
+		return r.Run(ctx, t.Agent, input)
+	}
+	attemptCtx, cancel := context.WithTimeout(ctx, t.Timeout)
+	defer cancel()
+	output, err = r.Run(attemptCtx, t.Agent, input)
+	if err != nil && ctx.Err() == nil && errors.Is(attemptCtx.Err(), context.DeadlineExceeded) {
+		return "", fmt.Errorf("timed out after %s: %w", t.Timeout, context.DeadlineExceeded)
+	}
+	return output, err
+}
+
 // SequentialRunner executes agents sequentially
 type SequentialRunner struct {
-	runner Runner
+	runner          Runner
+	continueOnError bool
+}
+
+// SequentialOption configures a SequentialRunner.
+type SequentialOption func(*SequentialRunner)
+
+// WithContinueOnError keeps running the remaining tasks after a task fails.
+// Failed tasks do not change the output passed to the next task.
+func WithContinueOnError(enabled bool) SequentialOption {
+	return func(sr *SequentialRunner) {
+		sr.continueOnError = enabled
+	}
 }
 
 // NewSequentialRunner creates a new sequential runner
-func NewSequentialRunner() *SequentialRunner {
-	return &SequentialRunner{
+func NewSequentialRunner(opts ...SequentialOption) *SequentialRunner {
+	sr := &SequentialRunner{
 		runner: New(1), // Single concurrency for sequential execution
 	}
+	for _, opt := range opts {
+		opt(sr)
+	}
+	return sr
+}
+
+// SequentialState is threaded through a sequential run.
+type SequentialState struct {
+	// Values holds shared data; the output of each successful task is stored under its ID.
+	Values map[string]any
+	// Output is the output of the last successful task.
+	Output string
+	// Results records every executed task in order.
+	Results []*Result
+}
+
+// NewSequentialState creates a state seeded with values.
+func NewSequentialState(values map[string]any) *SequentialState {
+	state := &SequentialState{Values: make(map[string]any, len(values))}
+	for k, v := range values {
+		state.Values[k] = v
+	}
+	return state
 }
 
 // RunSequential executes tasks sequentially, passing output to the next task
 func (sr *SequentialRunner) RunSequential(ctx context.Context, tasks []*Task) (*Result, error) {
-	var lastOutput string
+	state, err := sr.RunSequentialState(ctx, tasks, nil)
+	if len(state.Results) == 0 {
+		return nil, err
+	}
+	return state.Results[len(state.Results)-1], err
+}
 
+// RunSequentialState executes tasks in order, threading state between them.
+// A task's input is built by its BuildInput func when set; otherwise the previous
+// output is used, falling back to the task's Input for the first task.
+// The run stops at the first error unless WithContinueOnError is set, in which
+// case all task errors are joined. A nil state starts empty.
+func (sr *SequentialRunner) RunSequentialState(ctx context.Context, tasks []*Task, state *SequentialState) (*SequentialState, error) {
+	if state == nil {
+		state = NewSequentialState(nil)
+	}
+	if state.Values == nil {
+		state.Values = make(map[string]any)
+	}
+	if len(tasks) == 0 {
+		return state, fmt.Errorf("no tasks to run")
+	}
+
+	var errs []error
 	for _, task := range tasks {
-		// Use previous output as input for current task (if not the first task)
-		input := task.Input
-		if lastOutput != "" {
-			input = lastOutput
+		if err := ctx.Err(); err != nil {
+			return state, errors.Join(append(errs, err)...)
 		}
 
-		output, err := sr.runner.Run(ctx, task.Agent, input)
+		output, err := sr.runTask(ctx, task, state)
+		result := &Result{
+			TaskID: task.ID,
+			Output: output,
+			Error:  err,
+		}
+		state.Results = append(state.Results, result)
+
 		if err != nil {
-			return &Result{
-				TaskID: task.ID,
-				Output: output,
-				Error:  err,
-			}, err
+			err = fmt.Errorf("task %s: %w", task.ID, err)
+			if !sr.continueOnError {
+				return state, err
+			}
+			errs = append(errs, err)
+			continue
 		}
 
-		lastOutput = output
+		state.Output = output
+		state.Values[task.ID] = output
 	}
 
-	return &Result{
-		TaskID: tasks[len(tasks)-1].ID,
-		Output: lastOutput,
-		Error:  nil,
-	}, nil
+	return state, errors.Join(errs...)
+}
+
+func (sr *SequentialRunner) runTask(ctx context.Context, task *Task, state *SequentialState) (output string, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic in task %s: %v", task.ID, r)
+		}
+	}()
+
+	input := task.Input
+	if task.BuildInput != nil {
+		input, err = task.BuildInput(ctx, state)
+		if err != nil {
+			return "", fmt.Errorf("build input: %w", err)
+		}
+	} else if state.Output != "" {
+		// Use previous output as input for current task (if not the first task)
+		input = state.Output
+	}
+
+	return sr.runner.Run(ctx, task.Agent, input)
 }
 
 // ConditionalRunner executes agents based on conditions
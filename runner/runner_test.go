@@ -2,11 +2,15 @@ package runner
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 
 	"github.com/sweetpotato0/ai-allin/agent"
+	"github.com/sweetpotato0/ai-allin/message"
 )
 
 func TestNewRunner(t *testing.T) {
@@ -198,3 +202,256 @@ func TestRunAndRunGraph(t *testing.T) {
 	}
 }
 
+
+// prefixLLM answers with its prefix followed by the last user message, or fails when fail is set.
+type prefixLLM struct {
+	prefix string
+	fail   bool
+}
+
+func (p *prefixLLM) Generate(ctx context.Context, req *agent.GenerateRequest) (*agent.GenerateResponse, error) {
+	if p.fail {
+		return nil, fmt.Errorf("%s failed", p.prefix)
+	}
+	var last string
+	for _, msg := range req.Messages {
+		if msg.Role == message.RoleUser {
+			last = msg.Text()
+		}
+	}
+	return &agent.GenerateResponse{Message: message.NewMessage(message.RoleAssistant, p.prefix+last)}, nil
+}
+
+func (p *prefixLLM) SetTemperature(float64) {}
+func (p *prefixLLM) SetMaxTokens(int64)     {}
+func (p *prefixLLM) SetModel(string)        {}
+
+func prefixAgent(prefix string, fail bool) *agent.Agent {
+	return agent.New(agent.WithProvider(&prefixLLM{prefix: prefix, fail: fail}))
+}
+
+func TestRunSequentialThreadsState(t *testing.T) {
+	sr := NewSequentialRunner()
+	tasks := []*Task{
+		{ID: "draft", Agent: prefixAgent("draft:", false), Input: "topic"},
+		{ID: "edit", Agent: prefixAgent("edit:", false)},
+		{ID: "title", Agent: prefixAgent("title:", false), BuildInput: func(_ context.Context, state *SequentialState) (string, error) {
+			return fmt.Sprintf("%v/%v", state.Values["audience"], state.Values["draft"]), nil
+		}},
+	}
+
+	state, err := sr.RunSequentialState(context.Background(), tasks, NewSequentialState(map[string]any{"audience": "devs"}))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := state.Values["edit"]; got != "edit:draft:topic" {
+		t.Fatalf("expected output to feed the next task, got %v", got)
+	}
+	if state.Output != "title:devs/draft:topic" {
+		t.Fatalf("expected BuildInput to read shared state, got %q", state.Output)
+	}
+	if len(state.Results) != 3 {
+		t.Fatalf("expected 3 results, got %d", len(state.Results))
+	}
+}
+
+func TestRunSequentialErrorHandling(t *testing.T) {
+	tasks := []*Task{
+		{ID: "a", Agent: prefixAgent("a:", false), Input: "x"},
+		{ID: "b", Agent: prefixAgent("b", true)},
+		{ID: "c", Agent: prefixAgent("c:", false)},
+	}
+
+	result, err := NewSequentialRunner().RunSequential(context.Background(), tasks)
+	if err == nil || result == nil || result.TaskID != "b" {
+		t.Fatalf("expected run to stop at task b, got %+v, %v", result, err)
+	}
+
+	state, err := NewSequentialRunner(WithContinueOnError(true)).RunSequentialState(context.Background(), tasks, nil)
+	if err == nil {
+		t.Fatal("expected joined error when continuing")
+	}
+	if len(state.Results) != 3 || state.Output != "c:a:x" {
+		t.Fatalf("expected c to receive a's output, got %q (%d results)", state.Output, len(state.Results))
+	}
+
+	if _, err := NewSequentialRunner().RunSequential(context.Background(), nil); err == nil {
+		t.Fatal("expected error for empty task list")
+	}
+}
+
+// blockingLLM reports each call on started and blocks until the request context ends.
+type blockingLLM struct {
+	started chan struct{}
+}
+
+func (b *blockingLLM) Generate(ctx context.Context, req *agent.GenerateRequest) (*agent.GenerateResponse, error) {
+	b.started <- struct{}{}
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
+func (b *blockingLLM) SetTemperature(float64) {}
+func (b *blockingLLM) SetMaxTokens(int64)     {}
+func (b *blockingLLM) SetModel(string)        {}
+
+func TestRunParallelContextMarksUnstartedTasks(t *testing.T) {
+	llm := &blockingLLM{started: make(chan struct{}, 1)}
+	tasks := []*Task{
+		{ID: "slow", Agent: agent.New(agent.WithProvider(llm)), Input: "x"},
+		{ID: "queued1", Agent: prefixAgent("q:", false), Input: "y"},
+		{ID: "queued2", Agent: prefixAgent("q:", false), Input: "z"},
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		<-llm.started
+		cancel()
+	}()
+	results := NewParallelRunner(1).RunParallelContext(ctx, tasks)
+
+	if len(results) != 3 {
+		t.Fatalf("expected a result for every task, got %d", len(results))
+	}
+	for i, result := range results {
+		if result.TaskID != tasks[i].ID {
+			t.Fatalf("result %d: expected task %s, got %s", i, tasks[i].ID, result.TaskID)
+		}
+	}
+	if !errors.Is(results[0].Error, context.Canceled) || errors.Is(results[0].Error, ErrTaskNotStarted) {
+		t.Fatalf("expected running task to be cancelled, got %v", results[0].Error)
+	}
+	for _, result := range results[1:] {
+		if !errors.Is(result.Error, ErrTaskNotStarted) || !errors.Is(result.Error, context.Canceled) {
+			t.Fatalf("expected %s to be marked not started, got %v", result.TaskID, result.Error)
+		}
+	}
+	if errs := results.Errors(); len(errs) != 3 {
+		t.Fatalf("expected 3 errors, got %v", errs)
+	}
+	if err := results.FirstError(); err == nil || !strings.HasPrefix(err.Error(), "task slow:") {
+		t.Fatalf("expected first error to belong to task slow, got %v", err)
+	}
+}
+
+func TestResultsErrorsEmptyOnSuccess(t *testing.T) {
+	tasks := []*Task{
+		{ID: "a", Agent: prefixAgent("a:", false), Input: "x"},
+		{ID: "b", Agent: prefixAgent("b:", false), Input: "y"},
+	}
+	results := NewParallelRunner(2).RunParallelContext(context.Background(), tasks)
+	if results.FirstError() != nil || len(results.Errors()) != 0 {
+		t.Fatalf("expected no errors, got %v", results.Errors())
+	}
+	if results[0].Output != "a:x" || results[1].Output != "b:y" {
+		t.Fatalf("expected outputs in task order, got %q and %q", results[0].Output, results[1].Output)
+	}
+}
+
+func TestRunDAGFeedsDependencyOutputs(t *testing.T) {
+	tasks := []*Task{
+		{ID: "summary", Agent: prefixAgent("sum:", false), DependsOn: []string{"facts", "quotes"}},
+		{ID: "facts", Agent: prefixAgent("facts:", false), Input: "topic"},
+		{ID: "quotes", Agent: prefixAgent("quotes:", false), Input: "topic"},
+		{ID: "title", Agent: prefixAgent("title:", false), DependsOn: []string{"summary"}, BuildInput: func(_ context.Context, state *SequentialState) (string, error) {
+			return fmt.Sprintf("%v|%s", state.Values["summary"], state.Output), nil
+		}},
+		{ID: "broken", Agent: prefixAgent("broken", true), Input: "x"},
+		{ID: "downstream", Agent: prefixAgent("never:", false), DependsOn: []string{"broken"}},
+	}
+
+	results, err := NewDAGRunner(2).RunDAG(context.Background(), tasks)
+	if !errors.Is(err, ErrDependencyFailed) {
+		t.Fatalf("expected joined error to report the skipped task, got %v", err)
+	}
+	if got := results[0].Output; got != "sum:facts:topic\n\nquotes:topic" {
+		t.Fatalf("expected summary to receive both dependency outputs, got %q", got)
+	}
+	if got := results[3].Output; got != "title:"+results[0].Output+"|"+results[0].Output {
+		t.Fatalf("expected BuildInput to read dependency outputs, got %q", got)
+	}
+	if results[4].Error == nil || !errors.Is(results[5].Error, ErrDependencyFailed) || results[5].Output != "" {
+		t.Fatalf("expected downstream to be skipped, got %+v", results[5])
+	}
+}
+
+func TestRunDAGRejectsInvalidGraphs(t *testing.T) {
+	ag := prefixAgent("x:", false)
+	cases := map[string][]*Task{
+		"dependency cycle among tasks a, b": {
+			{ID: "a", Agent: ag, DependsOn: []string{"b"}},
+			{ID: "b", Agent: ag, DependsOn: []string{"a"}},
+			{ID: "c", Agent: ag},
+		},
+		"task a depends on unknown task z": {{ID: "a", Agent: ag, DependsOn: []string{"z"}}},
+		"duplicate task ID a":              {{ID: "a", Agent: ag}, {ID: "a", Agent: ag}},
+	}
+	for want, tasks := range cases {
+		results, err := NewDAGRunner(2).RunDAG(context.Background(), tasks)
+		if err == nil || err.Error() != want || results != nil {
+			t.Fatalf("expected %q before running anything, got %v", want, err)
+		}
+	}
+}
+
+// flakyLLM fails the first failures calls, panicking instead when panics is set,
+// and records every input it receives.
+type flakyLLM struct {
+	mu       sync.Mutex
+	failures int
+	panics   bool
+	inputs   []string
+}
+
+func (f *flakyLLM) Generate(ctx context.Context, req *agent.GenerateRequest) (*agent.GenerateResponse, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.inputs = append(f.inputs, req.Messages[len(req.Messages)-1].Text())
+	if len(f.inputs) <= f.failures {
+		if f.panics {
+			panic("provider exploded")
+		}
+		return nil, errors.New("transient failure")
+	}
+	return &agent.GenerateResponse{Message: message.NewMessage(message.RoleAssistant, "ok")}, nil
+}
+
+func (f *flakyLLM) SetTemperature(float64) {}
+func (f *flakyLLM) SetMaxTokens(int64)     {}
+func (f *flakyLLM) SetModel(string)        {}
+
+func TestParallelTaskRetriesAndTimeout(t *testing.T) {
+	flaky := &flakyLLM{failures: 2}
+	exploding := &flakyLLM{failures: 1, panics: true}
+	slow := &blockingLLM{started: make(chan struct{}, 2)}
+	tasks := []*Task{
+		{ID: "flaky", Agent: agent.New(agent.WithProvider(flaky)), Input: "question", MaxRetries: 2},
+		{ID: "exploding", Agent: agent.New(agent.WithProvider(exploding)), Input: "boom", MaxRetries: 1},
+		{ID: "slow", Agent: agent.New(agent.WithProvider(slow)), Input: "wait", Timeout: 10 * time.Millisecond, MaxRetries: 1},
+		{ID: "unreliable", Agent: agent.New(agent.WithProvider(&flakyLLM{failures: 5})), Input: "x", MaxRetries: 1},
+	}
+
+	// A single slot shows that panics and timeouts release the concurrency limit.
+	results := NewParallelRunner(1).RunParallelContext(context.Background(), tasks)
+
+	if results[0].Error != nil || results[0].Output != "ok" || fmt.Sprint(flaky.inputs) != "[question question question]" {
+		t.Fatalf("expected flaky task to succeed on the third attempt with the same input, got %v after %v", results[0].Error, flaky.inputs)
+	}
+	if results[1].Error != nil || len(exploding.inputs) != 2 {
+		t.Fatalf("expected a panicking attempt to be retried, got %v", results[1].Error)
+	}
+	if err := results[2].Error; !errors.Is(err, context.DeadlineExceeded) || !strings.Contains(err.Error(), "timed out after 10ms") || len(slow.started) != 2 {
+		t.Fatalf("expected both attempts of slow task to time out, got %v", err)
+	}
+	if err := results[3].Error; err == nil || !strings.Contains(err.Error(), "failed after 2 attempts") {
+		t.Fatalf("expected retries to stop at the limit, got %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	retried := &flakyLLM{failures: 5}
+	result := NewParallelRunner(1).RunParallel(ctx, []*Task{{ID: "t", Agent: agent.New(agent.WithProvider(retried)), Input: "x", MaxRetries: 3}})
+	if result[0].Error == nil || len(retried.inputs) > 1 {
+		t.Fatalf("expected no retries after the context ended, got %v after %d calls", result[0].Error, len(retried.inputs))
+	}
+}
@@ -29,6 +29,7 @@ type TurnResult struct {
 	Messages    []*message.Message
 	LastMessage *message.Message
 	Duration    time.Duration
+	Usage       agent.Usage // Tokens consumed by the LLM calls of this turn
 }
 
 // Executor defines the contract for runtime executors.
@@ -105,5 +106,6 @@ func (e *AgentExecutor) Execute(ctx context.Context, req *Request) (*TurnResult,
 		Messages:    messages,
 		LastMessage: last,
 		Duration:    duration,
+		Usage:       runner.Usage(),
 	}, nil
 }
@@ -0,0 +1,150 @@
+package session
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/sweetpotato0/ai-allin/agent"
+	"github.com/sweetpotato0/ai-allin/message"
+	"github.com/sweetpotato0/ai-allin/pkg/telemetry"
+	"go.opentelemetry.io/otel/attribute"
+	oteltrace "go.opentelemetry.io/otel/trace"
+)
+
+// CompactionSummaryPrefix starts the content of the system message produced by Compact.
+const CompactionSummaryPrefix = "Summary of earlier conversation:\n"
+
+const compactionPrompt = "You compress conversation history. Summarize the transcript below so the " +
+	"conversation can continue without it. Keep facts, decisions, user preferences, open questions " +
+	"and tool results that may matter later. Reply with the summary only."
+
+// WithCompactionLLM sets the LLM used by Compact to summarize older messages.
+func WithCompactionLLM(llm agent.LLMClient) Option {
+	return func(m *Manager) {
+		if llm != nil {
+			m.compactLLM = llm
+		}
+	}
+}
+
+type messageSetter interface {
+	SetMessages(msgs []*message.Message)
+}
+
+// Compact summarizes all but the most recent keepRecent messages of a session into a
+// single system message and persists the rewritten history. Leading system messages
+// (the system prompt) are kept verbatim. Compact should not run concurrently with Run
+// on the same session; messages appended in between would be lost.
+func (m *Manager) Compact(ctx context.Context, id string, keepRecent int) error {
+	ctx, span := sessionTracer.Start(ctx, "SessionManager.Compact",
+		oteltrace.WithAttributes(attribute.String("session.id", id), attribute.Int("session.keep_recent", keepRecent)))
+	var spanErr error
+	defer func() { telemetry.End(span, spanErr) }()
+
+	if m.compactLLM == nil {
+		spanErr = fmt.Errorf("session manager compaction LLM is not configured")
+		return spanErr
+	}
+	if keepRecent < 0 {
+		keepRecent = 0
+	}
+
+	sess, err := m.Get(ctx, id)
+	if err != nil {
+		spanErr = err
+		return err
+	}
+	setter, ok := sess.(messageSetter)
+	if !ok {
+		spanErr = fmt.Errorf("session %s does not support compaction", id)
+		return spanErr
+	}
+
+	msgs := sess.GetMessages()
+	head, older, recent := splitForCompaction(msgs, keepRecent)
+	if len(older) == 0 {
+		span.AddEvent("compaction_skipped")
+		return nil
+	}
+
+	summary, err := m.summarize(ctx, older)
+	if err != nil {
+		if m.logger != nil {
+			m.logger.Error("compact session summarize failed", "id", id, "error", err)
+		}
+		spanErr = err
+		return err
+	}
+
+	compacted := make([]*message.Message, 0, len(head)+1+len(recent))
+	compacted = append(compacted, head...)
+	compacted = append(compacted, message.NewMessage(message.RoleSystem, CompactionSummaryPrefix+summary))
+	compacted = append(compacted, recent...)
+	setter.SetMessages(compacted)
+
+	if err := m.ensureStore(); err != nil {
+		spanErr = err
+		return err
+	}
+	if err := m.store.Save(ctx, sess.Snapshot()); err != nil {
+		if m.logger != nil {
+			m.logger.Error("compact session save failed", "id", id, "error", err)
+		}
+		spanErr = err
+		return err
+	}
+	if m.logger != nil {
+		m.logger.Info("session compacted", "id", id, "before", len(msgs), "after", len(compacted))
+	}
+	span.SetAttributes(attribute.Int("messages.before", len(msgs)), attribute.Int("messages.after", len(compacted)))
+	return nil
+}
+
+// splitForCompaction separates leading system messages, the messages to summarize and
+// the recent messages to keep. The boundary moves back so tool responses stay next to
+// the assistant message that requested them.
+func splitForCompaction(msgs []*message.Message, keepRecent int) (head, older, recent []*message.Message) {
+	start := 0
+	for start < len(msgs) && msgs[start].Role == message.RoleSystem {
+		start++
+	}
+	head, rest := msgs[:start], msgs[start:]
+
+	cut := len(rest) - keepRecent
+	if cut <= 0 {
+		return head, nil, rest
+	}
+	for cut > 0 && cut < len(rest) && rest[cut].Role == message.RoleTool {
+		cut--
+	}
+	return head, rest[:cut], rest[cut:]
+}
+
+func (m *Manager) summarize(ctx context.Context, msgs []*message.Message) (string, error) {
+	var transcript strings.Builder
+	for _, msg := range msgs {
+		text := msg.Text()
+		for _, call := range msg.ToolCalls {
+			text += fmt.Sprintf(" [tool call %s %v]", call.Name, call.Args)
+		}
+		if text == "" {
+			continue
+		}
+		fmt.Fprintf(&transcript, "%s: %s\n", msg.Role, text)
+	}
+
+	resp, err := m.compactLLM.Generate(ctx, &agent.GenerateRequest{
+		Messages: []*message.Message{
+			message.NewMessage(message.RoleSystem, compactionPrompt),
+			message.NewMessage(message.RoleUser, transcript.String()),
+		},
+	})
+	if err != nil {
+		return "", fmt.Errorf("compaction summary failed: %w", err)
+	}
+	if resp == nil || resp.Message == nil || strings.TrimSpace(resp.Message.Text()) == "" {
+		return "", fmt.Errorf("compaction summary is empty")
+	}
+	return strings.TrimSpace(resp.Message.Text()), nil
+}
@@ -0,0 +1,157 @@
+package session
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrSessionExpired is returned by Get for a session idle longer than the TTL
+// configured with WithTTL.
+var ErrSessionExpired = errors.New("session expired")
+
+// maxSweepInterval bounds how long an expired session can stay active in memory.
+const maxSweepInterval = time.Minute
+
+// WithTTL expires sessions that have not been run or updated for ttl. Get returns
+// ErrSessionExpired for them, and a background sweeper marks idle cached sessions
+// StateInactive until Close is called. A non-positive ttl disables expiry.
+func WithTTL(ttl time.Duration) Option {
+	return func(m *Manager) {
+		m.ttl = ttl
+	}
+}
+
+// WithDeleteExpired deletes expired sessions from the manager and the store
+// instead of only marking them inactive. The sweeper then also removes stored
+// sessions that are not cached (see CleanupInactive).
+func WithDeleteExpired() Option {
+	return func(m *Manager) {
+		m.deleteExpired = true
+	}
+}
+
+// expirable is implemented by the session types that track their activity.
+type expirable interface {
+	expire(now time.Time, ttl time.Duration) (expired, changed bool)
+}
+
+// checkExpired returns ErrSessionExpired for a cached session idle past the TTL,
+// persisting or deleting it first.
+func (m *Manager) checkExpired(ctx context.Context, sess Session) error {
+	retire, err := m.expireSession(sess)
+	if retire {
+		m.mu.Lock()
+		m.retireLocked(ctx, sess.ID(), sess.Snapshot())
+		m.mu.Unlock()
+	}
+	return err
+}
+
+// checkExpiredLocked is checkExpired for callers holding m.mu.
+func (m *Manager) checkExpiredLocked(ctx context.Context, sess Session) error {
+	retire, err := m.expireSession(sess)
+	if retire {
+		m.retireLocked(ctx, sess.ID(), sess.Snapshot())
+	}
+	return err
+}
+
+// expireSession applies the TTL to sess. It returns ErrSessionExpired for an
+// expired session and reports whether the store needs updating.
+func (m *Manager) expireSession(sess Session) (bool, error) {
+	e, ok := sess.(expirable)
+	if !ok || m.ttl <= 0 {
+		return false, nil
+	}
+	expired, changed := e.expire(time.Now(), m.ttl)
+	if !expired {
+		return false, nil
+	}
+	return changed || m.deleteExpired, fmt.Errorf("session %s: %w", sess.ID(), ErrSessionExpired)
+}
+
+// checkExpiredRecordLocked is checkExpiredLocked for a session only present in
+// the store.
+func (m *Manager) checkExpiredRecordLocked(ctx context.Context, record *Record) error {
+	if m.ttl <= 0 || time.Since(record.LastActivity()) <= m.ttl {
+		return nil
+	}
+	if record.State == StateActive || m.deleteExpired {
+		if record.State == StateActive {
+			record.State = StateInactive
+		}
+		m.retireLocked(ctx, record.ID, record)
+	}
+	return fmt.Errorf("session %s: %w", record.ID, ErrSessionExpired)
+}
+
+// retireLocked deletes an expired session or saves its inactive record. Failures
+// are logged; the session is reported as expired either way. m.mu must be held.
+func (m *Manager) retireLocked(ctx context.Context, id string, record *Record) {
+	if m.store == nil {
+		return
+	}
+	var err error
+	if m.deleteExpired {
+		if sess, ok := m.sessions[id]; ok {
+			_ = sess.Close()
+		}
+		delete(m.sessions, id)
+		delete(m.sessionAgents, id)
+		err = m.store.Delete(ctx, id)
+	} else {
+		err = m.store.Save(ctx, record)
+	}
+	if m.logger != nil {
+		if err != nil {
+			m.logger.Warn("retiring expired session failed", "id", id, "delete", m.deleteExpired, "error", err)
+		} else {
+			m.logger.Info("session expired", "id", id, "deleted", m.deleteExpired)
+		}
+	}
+}
+
+// expireIdle retires cached sessions that have been idle past the TTL and, when
+// expired sessions are deleted, stored ones too.
+func (m *Manager) expireIdle(ctx context.Context) {
+	m.mu.RLock()
+	sessions := make([]Session, 0, len(m.sessions))
+	for _, sess := range m.sessions {
+		sessions = append(sessions, sess)
+	}
+	m.mu.RUnlock()
+
+	for _, sess := range sessions {
+		// Checked outside m.mu: expiry waits for a running turn to finish.
+		_ = m.checkExpired(ctx, sess)
+	}
+	if m.deleteExpired {
+		if _, err := m.CleanupInactive(ctx, m.ttl); err != nil && m.logger != nil {
+			m.logger.Warn("expired session cleanup failed", "error", err)
+		}
+	}
+}
+
+// startExpiryLoop runs expireIdle periodically until Close is called.
+func (m *Manager) startExpiryLoop() {
+	if m.ttl <= 0 || m.store == nil {
+		return
+	}
+	interval := min(max(m.ttl/2, time.Millisecond), maxSweepInterval)
+	m.loops.Add(1)
+	go func() {
+		defer m.loops.Done()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-m.stop:
+				return
+			case <-ticker.C:
+				m.expireIdle(context.Background())
+			}
+		}
+	}()
+}
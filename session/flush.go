@@ -0,0 +1,91 @@
+package session
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/sweetpotato0/ai-allin/pkg/telemetry"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// WithFlushInterval persists all cached sessions every interval in the background,
+// bounding how much in-memory state a crash can lose. Call Close to stop flushing.
+// A non-positive interval disables background flushing.
+func WithFlushInterval(interval time.Duration) Option {
+	return func(m *Manager) {
+		m.flushInterval = interval
+	}
+}
+
+// FlushAll saves the current snapshot of every cached session to the store.
+// All sessions are attempted; failures are joined into the returned error.
+func (m *Manager) FlushAll(ctx context.Context) error {
+	ctx, span := sessionTracer.Start(ctx, "SessionManager.FlushAll")
+	var spanErr error
+	defer func() { telemetry.End(span, spanErr) }()
+	if err := m.ensureStore(); err != nil {
+		spanErr = err
+		return err
+	}
+
+	m.mu.RLock()
+	sessions := make([]Session, 0, len(m.sessions))
+	for _, sess := range m.sessions {
+		sessions = append(sessions, sess)
+	}
+	m.mu.RUnlock()
+
+	var errs []error
+	for _, sess := range sessions {
+		if err := m.store.Save(ctx, sess.Snapshot()); err != nil {
+			if m.logger != nil {
+				m.logger.Error("flush session failed", "id", sess.ID(), "error", err)
+			}
+			errs = append(errs, fmt.Errorf("failed to flush session %s: %w", sess.ID(), err))
+		}
+	}
+	span.SetAttributes(attribute.Int("sessions.flushed", len(sessions)-len(errs)), attribute.Int("sessions.failed", len(errs)))
+	if m.logger != nil {
+		m.logger.Debug("flushed sessions", "count", len(sessions), "failed", len(errs))
+	}
+	spanErr = errors.Join(errs...)
+	return spanErr
+}
+
+// Close stops background flushing and expiry and flushes all cached sessions
+// one last time.
+func (m *Manager) Close(ctx context.Context) error {
+	m.closeOnce.Do(func() {
+		close(m.stop)
+		m.loops.Wait()
+	})
+	if m.store == nil {
+		return nil
+	}
+	return m.FlushAll(ctx)
+}
+
+// startFlushLoop runs FlushAll every flushInterval until Close is called.
+func (m *Manager) startFlushLoop() {
+	if m.flushInterval <= 0 {
+		return
+	}
+	m.loops.Add(1)
+	go func() {
+		defer m.loops.Done()
+		ticker := time.NewTicker(m.flushInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-m.stop:
+				return
+			case <-ticker.C:
+				if err := m.FlushAll(context.Background()); err != nil && m.logger != nil {
+					m.logger.Warn("background session flush failed", "error", err)
+				}
+			}
+		}
+	}()
+}
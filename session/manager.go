@@ -2,12 +2,14 @@ package session
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log/slog"
 	"sync"
 	"time"
 
 	"github.com/sweetpotato0/ai-allin/agent"
+	idgen "github.com/sweetpotato0/ai-allin/pkg/id"
 	"github.com/sweetpotato0/ai-allin/pkg/logging"
 	"github.com/sweetpotato0/ai-allin/pkg/telemetry"
 	"go.opentelemetry.io/otel"
@@ -37,7 +39,17 @@ type Manager struct {
 	resolver      AgentResolver
 	sessions      map[string]Session
 	sessionAgents map[string]*agent.Agent
+	idGen         idgen.Generator
+	compactLLM    agent.LLMClient
 	logger        *slog.Logger
+
+	flushInterval time.Duration
+	stop          chan struct{}  // Closed by Close to stop background loops
+	loops         sync.WaitGroup // Background flush and expiry loops
+	closeOnce     sync.Once
+
+	ttl           time.Duration // Idle time after which sessions expire; zero disables expiry
+	deleteExpired bool          // Delete expired sessions instead of marking them inactive
 }
 
 var sessionTracer = otel.Tracer("github.com/sweetpotato0/ai-allin/session/manager")
@@ -69,6 +81,15 @@ func WithLogger(logger *slog.Logger) Option {
 	}
 }
 
+// WithIDGenerator sets the generator used when sessions are created without an ID.
+func WithIDGenerator(gen idgen.Generator) Option {
+	return func(m *Manager) {
+		if gen != nil {
+			m.idGen = gen
+		}
+	}
+}
+
 // NewManager creates a new session manager with the given options.
 //
 // Example:
@@ -78,6 +99,7 @@ func NewManager(opts ...Option) *Manager {
 	m := &Manager{
 		sessions:      make(map[string]Session),
 		sessionAgents: make(map[string]*agent.Agent),
+		stop:          make(chan struct{}),
 	}
 	for _, opt := range opts {
 		opt(m)
@@ -85,9 +107,19 @@ func NewManager(opts ...Option) *Manager {
 	if m.logger == nil {
 		m.logger = logging.WithComponent("session_manager")
 	}
+	if m.idGen == nil {
+		m.idGen = idgen.Prefixed("sess_", nil)
+	}
+	m.startFlushLoop()
+	m.startExpiryLoop()
 	return m
 }
 
+// NewID returns a fresh session ID from the manager's ID generator.
+func (m *Manager) NewID() string {
+	return m.idGen.New()
+}
+
 // NewManagerWithStore creates a new session manager with a custom store.
 // Deprecated: Use NewManager(WithStore(store)) instead.
 func NewManagerWithStore(s Store) *Manager {
@@ -95,7 +127,11 @@ func NewManagerWithStore(s Store) *Manager {
 }
 
 // Create creates a new single-agent session.
+// An empty id is replaced by one from the manager's ID generator.
 func (m *Manager) Create(ctx context.Context, id string, ag *agent.Agent) (*SingleAgentSession, error) {
+	if id == "" {
+		id = m.NewID()
+	}
 	ctx, span := sessionTracer.Start(ctx, "SessionManager.Create",
 		oteltrace.WithAttributes(attribute.String("session.id", id)))
 	var spanErr error
@@ -148,7 +184,11 @@ func (m *Manager) Create(ctx context.Context, id string, ag *agent.Agent) (*Sing
 }
 
 // CreateShared creates a new shared (multi-agent) session.
+// An empty id is replaced by one from the manager's ID generator.
 func (m *Manager) CreateShared(ctx context.Context, id string) (*SharedSession, error) {
+	if id == "" {
+		id = m.NewID()
+	}
 	ctx, span := sessionTracer.Start(ctx, "SessionManager.CreateShared",
 		oteltrace.WithAttributes(attribute.String("session.id", id)))
 	var spanErr error
@@ -200,7 +240,8 @@ func (m *Manager) CreateShared(ctx context.Context, id string) (*SharedSession,
 	return sess, nil
 }
 
-// Get retrieves a session by ID.
+// Get retrieves a session by ID. With WithTTL, a session idle longer than the
+// TTL yields an error wrapping ErrSessionExpired.
 func (m *Manager) Get(ctx context.Context, id string) (Session, error) {
 	ctx, span := sessionTracer.Start(ctx, "SessionManager.Get",
 		oteltrace.WithAttributes(attribute.String("session.id", id)))
@@ -213,6 +254,10 @@ func (m *Manager) Get(ctx context.Context, id string) (Session, error) {
 		if m.logger != nil {
 			m.logger.Debug("session hit cache", "id", id)
 		}
+		if err := m.checkExpired(ctx, sess); err != nil {
+			spanErr = err
+			return nil, err
+		}
 		return sess, nil
 	}
 
@@ -223,6 +268,10 @@ func (m *Manager) Get(ctx context.Context, id string) (Session, error) {
 		if m.logger != nil {
 			m.logger.Debug("session found in memory", "id", id)
 		}
+		if err := m.checkExpiredLocked(ctx, sess); err != nil {
+			spanErr = err
+			return nil, err
+		}
 		return sess, nil
 	}
 
@@ -242,6 +291,10 @@ func (m *Manager) Get(ctx context.Context, id string) (Session, error) {
 		spanErr = err
 		return nil, err
 	}
+	if err := m.checkExpiredRecordLocked(ctx, record); err != nil {
+		spanErr = err
+		return nil, err
+	}
 
 	sess, err := m.instantiate(record)
 	if err != nil {
@@ -260,6 +313,34 @@ func (m *Manager) Get(ctx context.Context, id string) (Session, error) {
 	return sess, nil
 }
 
+// ErrSessionTypeMismatch is returned by GetTyped when the stored session has a different type.
+var ErrSessionTypeMismatch = errors.New("session type mismatch")
+
+// GetTyped retrieves a session by ID and asserts it to the concrete type T.
+// A session of another type yields an error wrapping ErrSessionTypeMismatch.
+func GetTyped[T Session](ctx context.Context, m *Manager, id string) (T, error) {
+	var zero T
+	sess, err := m.Get(ctx, id)
+	if err != nil {
+		return zero, err
+	}
+	typed, ok := sess.(T)
+	if !ok {
+		return zero, fmt.Errorf("session %s has type %s, want %T: %w", id, sess.Type(), zero, ErrSessionTypeMismatch)
+	}
+	return typed, nil
+}
+
+// GetSingle retrieves a single-agent session by ID.
+func (m *Manager) GetSingle(ctx context.Context, id string) (*SingleAgentSession, error) {
+	return GetTyped[*SingleAgentSession](ctx, m, id)
+}
+
+// GetShared retrieves a shared (multi-agent) session by ID.
+func (m *Manager) GetShared(ctx context.Context, id string) (*SharedSession, error) {
+	return GetTyped[*SharedSession](ctx, m, id)
+}
+
 // GetOrCreate retrieves a session by ID or creates a new single-agent session if it doesn't exist.
 func (m *Manager) GetOrCreate(ctx context.Context, id string, ag *agent.Agent) (*SingleAgentSession, error) {
 	ctx, span := sessionTracer.Start(ctx, "SessionManager.GetOrCreate",
@@ -437,12 +518,17 @@ func (m *Manager) Delete(ctx context.Context, id string) error {
 	if m.logger != nil {
 		m.logger.Warn("deleting session", "id", id)
 	}
+	// Close waits for a running turn, so it runs after m.mu is released.
+	var closing Session
+	defer func() {
+		if closing != nil {
+			_ = closing.Close()
+		}
+	}()
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
-	if sess, ok := m.sessions[id]; ok {
-		_ = sess.Close()
-	}
+	closing = m.sessions[id]
 	delete(m.sessions, id)
 	delete(m.sessionAgents, id)
 
@@ -520,9 +606,14 @@ func (m *Manager) Count(ctx context.Context) (int, error) {
 	return count, nil
 }
 
-// CleanupInactive removes inactive sessions older than the specified duration.
-func (m *Manager) CleanupInactive(ctx context.Context, _ time.Duration) (int, error) {
-	ctx, span := sessionTracer.Start(ctx, "SessionManager.CleanupInactive")
+// CleanupInactive deletes sessions in StateInactive and sessions whose last
+// activity (see Record.LastActivity) is older than maxIdle, whatever their state.
+// Cached sessions are judged by their live state rather than the stored record.
+// A non-positive maxIdle only removes StateInactive sessions. It returns the
+// number of sessions removed.
+func (m *Manager) CleanupInactive(ctx context.Context, maxIdle time.Duration) (int, error) {
+	ctx, span := sessionTracer.Start(ctx, "SessionManager.CleanupInactive",
+		oteltrace.WithAttributes(attribute.String("session.max_idle", maxIdle.String())))
 	var spanErr error
 	defer func() { telemetry.End(span, spanErr) }()
 	if err := m.ensureStore(); err != nil {
@@ -542,29 +633,42 @@ func (m *Manager) CleanupInactive(ctx context.Context, _ time.Duration) (int, er
 		return 0, err
 	}
 
+	now := time.Now()
 	count := 0
 	for _, id := range ids {
-		record, err := m.store.Load(ctx, id)
-		if err != nil {
+		var record *Record
+		if sess, ok := m.getCached(id); ok {
+			record = sess.Snapshot()
+		} else if record, err = m.store.Load(ctx, id); err != nil {
 			if m.logger != nil {
 				m.logger.Warn("cleanup inactive load failed", "id", id, "error", err)
 			}
 			continue
 		}
-		if record.State == StateInactive {
-			if sess, ok := m.sessions[id]; ok {
-				_ = sess.Close()
-			}
-			if err := m.store.Delete(ctx, id); err == nil {
-				count++
-				delete(m.sessions, id)
-				delete(m.sessionAgents, id)
-				if m.logger != nil {
-					m.logger.Info("cleaned inactive session", "id", id)
-				}
-			} else {
-				span.AddEvent("cleanup_delete_failed", oteltrace.WithAttributes(attribute.String("session.id", id), attribute.String("error", err.Error())))
-			}
+		idle := maxIdle > 0 && now.Sub(record.LastActivity()) > maxIdle
+		if record.State != StateInactive && !idle {
+			continue
+		}
+
+		m.mu.Lock()
+		sess, cached := m.sessions[id]
+		err := m.store.Delete(ctx, id)
+		if err == nil {
+			delete(m.sessions, id)
+			delete(m.sessionAgents, id)
+		}
+		m.mu.Unlock()
+		// Close waits for a running turn, so it runs after m.mu is released.
+		if err == nil && cached {
+			_ = sess.Close()
+		}
+		if err != nil {
+			span.AddEvent("cleanup_delete_failed", oteltrace.WithAttributes(attribute.String("session.id", id), attribute.String("error", err.Error())))
+			continue
+		}
+		count++
+		if m.logger != nil {
+			m.logger.Info("cleaned inactive session", "id", id, "idle", idle)
 		}
 	}
 	if m.logger != nil {
@@ -4,6 +4,7 @@ import (
 	"context"
 	"time"
 
+	"github.com/sweetpotato0/ai-allin/agent"
 	"github.com/sweetpotato0/ai-allin/message"
 )
 
@@ -35,6 +36,27 @@ type Record struct {
 	Metadata     map[string]any     `json:"metadata"`
 	CreatedAt    time.Time          `json:"created_at"`
 	UpdatedAt    time.Time          `json:"updated_at"`
+	// Token usage accumulated over every run of the session.
+	TotalPromptTokens     int64 `json:"total_prompt_tokens,omitempty"`
+	TotalCompletionTokens int64 `json:"total_completion_tokens,omitempty"`
+	TotalTokens           int64 `json:"total_tokens,omitempty"`
+	// LastAccessedAt is when the session was last run, successfully or not.
+	LastAccessedAt time.Time `json:"last_accessed_at,omitzero"`
+}
+
+// LastActivity returns the latest of the creation, update and access times.
+func (r *Record) LastActivity() time.Time {
+	return latestOf(r.CreatedAt, r.UpdatedAt, r.LastAccessedAt)
+}
+
+func latestOf(times ...time.Time) time.Time {
+	var latest time.Time
+	for _, t := range times {
+		if t.After(latest) {
+			latest = t
+		}
+	}
+	return latest
 }
 
 // Clone returns a deep copy of the record to prevent accidental mutation.
@@ -71,6 +93,15 @@ type Session interface {
 
 	// Snapshot returns a serializable record of the session state
 	Snapshot() *Record
+
+	// SetMetadata attaches application data (customer ID, tier, channel...) to the session
+	SetMetadata(key string, value any)
+
+	// GetMetadata returns application data previously attached to the session
+	GetMetadata(key string) (any, bool)
+
+	// Usage returns the token usage accumulated over all runs of the session
+	Usage() agent.Usage
 }
 
 // Base provides common fields and methods for session implementations
@@ -84,6 +115,9 @@ type Base struct {
 	messages     []*message.Message
 	lastMessage  *message.Message
 	lastDuration time.Duration
+	usage        agent.Usage
+
+	lastAccessedAt time.Time
 }
 
 // NewBase initializes a new base session
@@ -144,6 +178,12 @@ func (b *Base) SetMessages(msgs []*message.Message) {
 	b.touch()
 }
 
+// appendMessages adds cloned msgs to the end of the tracked messages.
+func (b *Base) appendMessages(msgs []*message.Message) {
+	b.messages = append(b.messages, message.CloneMessages(msgs)...)
+	b.touch()
+}
+
 // SetLastMessage stores the last assistant message returned by the runtime.
 func (b *Base) SetLastMessage(msg *message.Message) {
 	if msg == nil {
@@ -160,6 +200,15 @@ func (b *Base) SetLastDuration(d time.Duration) {
 	b.touch()
 }
 
+// AddUsage adds the token usage of a run to the session total.
+func (b *Base) AddUsage(u agent.Usage) {
+	if u.IsZero() {
+		return
+	}
+	b.usage = b.usage.Add(u)
+	b.touch()
+}
+
 // Snapshot returns a serializable representation of the base state.
 func (b *Base) Snapshot() *Record {
 	return &Record{
@@ -172,6 +221,11 @@ func (b *Base) Snapshot() *Record {
 		Metadata:     cloneMetadata(b.Metadata),
 		CreatedAt:    b.CreatedAt,
 		UpdatedAt:    b.UpdatedAt,
+
+		TotalPromptTokens:     b.usage.PromptTokens,
+		TotalCompletionTokens: b.usage.CompletionTokens,
+		TotalTokens:           b.usage.TotalTokens,
+		LastAccessedAt:        b.lastAccessedAt,
 	}
 }
 
@@ -179,6 +233,31 @@ func (b *Base) touch() {
 	b.UpdatedAt = time.Now()
 }
 
+// markAccessed records that the session is being run.
+func (b *Base) markAccessed() {
+	b.lastAccessedAt = time.Now()
+}
+
+// LastAccessedAt returns when the session was last run, successfully or not,
+// or the zero time if it never was.
+func (b *Base) LastAccessedAt() time.Time {
+	return b.lastAccessedAt
+}
+
+// expire reports whether the session has been idle longer than ttl at now and
+// whether that moved it from StateActive to StateInactive. Timestamps are left
+// alone so the session stays expired.
+func (b *Base) expire(now time.Time, ttl time.Duration) (expired, changed bool) {
+	if ttl <= 0 || now.Sub(latestOf(b.CreatedAt, b.UpdatedAt, b.lastAccessedAt)) <= ttl {
+		return false, false
+	}
+	if b.State != StateActive {
+		return true, false
+	}
+	b.State = StateInactive
+	return true, true
+}
+
 func cloneMetadata(src map[string]any) map[string]any {
 	if len(src) == 0 {
 		return nil
@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"sync"
+	"time"
 
 	"github.com/sweetpotato0/ai-allin/agent"
 	"github.com/sweetpotato0/ai-allin/message"
@@ -12,6 +13,11 @@ import (
 
 // SharedSession represents a session that can be used by multiple agents.
 // It maintains shared conversation history that can be replayed across agents.
+//
+// All methods are safe for concurrent use. RunWithAgent holds the session lock for
+// the whole turn, so concurrent turns run one after another and each sees the
+// history left by the previous one. RunWithAgentConcurrent runs turns in parallel
+// against a snapshot and appends each turn's messages as one block.
 type SharedSession struct {
 	Base
 	mu sync.RWMutex
@@ -37,6 +43,9 @@ func NewSharedFromRecord(record *Record) *SharedSession {
 			CreatedAt:   record.CreatedAt,
 			UpdatedAt:   record.UpdatedAt,
 			Metadata:    cloneMetadata(record.Metadata),
+			usage:       agent.Usage{PromptTokens: record.TotalPromptTokens, CompletionTokens: record.TotalCompletionTokens, TotalTokens: record.TotalTokens}.WithTotal(),
+
+			lastAccessedAt: record.LastAccessedAt,
 		},
 	}
 	sess.Base.SetMessages(record.Messages)
@@ -58,6 +67,7 @@ func (s *SharedSession) Run(ctx context.Context, input string) (string, error) {
 // RunWithAgent replays the conversation into the provided agent and captures the result.
 // It creates a clone of the agent to avoid modifying the original, replays the conversation
 // history, executes the agent with the input, and updates the conversation history.
+// Turns are serialized: the session stays locked until the agent has answered.
 func (s *SharedSession) RunWithAgent(ctx context.Context, ag *agent.Agent, input string) (string, error) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
@@ -65,6 +75,7 @@ func (s *SharedSession) RunWithAgent(ctx context.Context, ag *agent.Agent, input
 	if s.State != StateActive {
 		return "", fmt.Errorf("session %s is not active", s.ID())
 	}
+	s.Base.markAccessed()
 
 	executor := runtime.NewAgentExecutor(ag)
 	result, err := executor.Execute(ctx, &runtime.Request{
@@ -84,10 +95,74 @@ func (s *SharedSession) RunWithAgent(ctx context.Context, ag *agent.Agent, input
 		s.Base.SetLastMessage(nil)
 	}
 	s.Base.SetLastDuration(result.Duration)
+	s.Base.AddUsage(result.Usage)
 
 	return result.Output, nil
 }
 
+// RunWithAgentConcurrent runs a turn without holding the session lock for its
+// duration. The agent sees the history as it was when the turn started; the
+// messages the turn adds are then appended to the current history in one step,
+// so turns never interleave with each other, though a turn does not see turns
+// that finished while it ran.
+func (s *SharedSession) RunWithAgentConcurrent(ctx context.Context, ag *agent.Agent, input string) (string, error) {
+	s.mu.Lock()
+	if s.State != StateActive {
+		s.mu.Unlock()
+		return "", fmt.Errorf("session %s is not active", s.ID())
+	}
+	s.Base.markAccessed()
+	history := s.Base.Messages()
+	s.mu.Unlock()
+
+	executor := runtime.NewAgentExecutor(ag)
+	result, err := executor.Execute(ctx, &runtime.Request{
+		SessionID: s.ID(),
+		Input:     input,
+		History:   history,
+	})
+	if err != nil {
+		return "", fmt.Errorf("agent execution failed: %w", err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.State != StateActive {
+		return "", fmt.Errorf("session %s is not active", s.ID())
+	}
+	added := addedMessages(history, result.Messages)
+	if len(history) == 0 && len(s.Base.messages) == 0 {
+		// The first turn to finish on a new session also stores the system prompt.
+		added = result.Messages
+	}
+	s.Base.appendMessages(added)
+	s.Base.SetLastMessage(result.LastMessage)
+	s.Base.SetLastDuration(result.Duration)
+	s.Base.AddUsage(result.Usage)
+	return result.Output, nil
+}
+
+// addedMessages returns the messages of after that follow the last message of
+// before, i.e. the messages a turn added to its history. When before is empty or
+// the context trimmed that message away, every non-system message of after is new.
+func addedMessages(before, after []*message.Message) []*message.Message {
+	if len(before) > 0 {
+		last := before[len(before)-1]
+		for i := len(after) - 1; i >= 0; i-- {
+			if m := after[i]; m.ID == last.ID && m.Role == last.Role && m.Text() == last.Text() {
+				return after[i+1:]
+			}
+		}
+	}
+	added := make([]*message.Message, 0, len(after))
+	for _, m := range after {
+		if m.Role != message.RoleSystem {
+			added = append(added, m)
+		}
+	}
+	return added
+}
+
 // GetMessages returns all messages in the session (implements Session interface)
 func (s *SharedSession) GetMessages() []*message.Message {
 	s.mu.RLock()
@@ -119,3 +194,45 @@ func (s *SharedSession) Snapshot() *Record {
 	defer s.mu.RUnlock()
 	return s.Base.Snapshot()
 }
+
+// SetMetadata attaches application data to the session; it is persisted with Snapshot.
+func (s *SharedSession) SetMetadata(key string, value any) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.Base.SetMetadata(key, value)
+}
+
+// GetMetadata returns application data previously attached to the session.
+func (s *SharedSession) GetMetadata(key string) (any, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.Base.GetMetadata(key)
+}
+
+// SetMessages replaces the conversation history of the session.
+func (s *SharedSession) SetMessages(msgs []*message.Message) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.Base.SetMessages(msgs)
+}
+
+// Usage returns the token usage accumulated over all runs of the session.
+func (s *SharedSession) Usage() agent.Usage {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.Base.usage
+}
+
+// LastAccessedAt returns when the session was last run, successfully or not.
+func (s *SharedSession) LastAccessedAt() time.Time {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.Base.LastAccessedAt()
+}
+
+// expire marks the session inactive once it has been idle longer than ttl.
+func (s *SharedSession) expire(now time.Time, ttl time.Duration) (expired, changed bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.Base.expire(now, ttl)
+}
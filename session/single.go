@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"sync"
+	"time"
 
 	"github.com/sweetpotato0/ai-allin/agent"
 	"github.com/sweetpotato0/ai-allin/message"
@@ -49,6 +50,9 @@ func NewSingleFromRecord(record *Record, ag *agent.Agent) *SingleAgentSession {
 			CreatedAt:   record.CreatedAt,
 			UpdatedAt:   record.UpdatedAt,
 			Metadata:    cloneMetadata(record.Metadata),
+			usage:       agent.Usage{PromptTokens: record.TotalPromptTokens, CompletionTokens: record.TotalCompletionTokens, TotalTokens: record.TotalTokens}.WithTotal(),
+
+			lastAccessedAt: record.LastAccessedAt,
 		},
 		prototype: ag,
 		executor:  runtime.NewAgentExecutor(ag),
@@ -71,6 +75,7 @@ func (s *SingleAgentSession) Run(ctx context.Context, input string) (string, err
 	if s.State != StateActive {
 		return "", fmt.Errorf("session is not active (state: %s)", s.State)
 	}
+	s.Base.markAccessed()
 
 	if s.executor == nil {
 		s.executor = runtime.NewAgentExecutor(s.prototype)
@@ -92,6 +97,7 @@ func (s *SingleAgentSession) Run(ctx context.Context, input string) (string, err
 		s.Base.SetLastMessage(nil)
 	}
 	s.Base.SetLastDuration(result.Duration)
+	s.Base.AddUsage(result.Usage)
 	return result.Output, nil
 }
 
@@ -135,3 +141,45 @@ func (s *SingleAgentSession) Agent() *agent.Agent {
 	defer s.mu.RUnlock()
 	return s.prototype
 }
+
+// SetMetadata attaches application data to the session; it is persisted with Snapshot.
+func (s *SingleAgentSession) SetMetadata(key string, value any) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.Base.SetMetadata(key, value)
+}
+
+// GetMetadata returns application data previously attached to the session.
+func (s *SingleAgentSession) GetMetadata(key string) (any, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.Base.GetMetadata(key)
+}
+
+// SetMessages replaces the conversation history of the session.
+func (s *SingleAgentSession) SetMessages(msgs []*message.Message) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.Base.SetMessages(msgs)
+}
+
+// Usage returns the token usage accumulated over all runs of the session.
+func (s *SingleAgentSession) Usage() agent.Usage {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.Base.usage
+}
+
+// LastAccessedAt returns when the session was last run, successfully or not.
+func (s *SingleAgentSession) LastAccessedAt() time.Time {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.Base.LastAccessedAt()
+}
+
+// expire marks the session inactive once it has been idle longer than ttl.
+func (s *SingleAgentSession) expire(now time.Time, ttl time.Duration) (expired, changed bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.Base.expire(now, ttl)
+}
@@ -2,13 +2,16 @@ package session
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"strings"
 	"sync"
 	"testing"
 	"time"
 
 	"github.com/sweetpotato0/ai-allin/agent"
 	"github.com/sweetpotato0/ai-allin/message"
+	"github.com/sweetpotato0/ai-allin/pkg/id"
 )
 
 func TestNewSession(t *testing.T) {
@@ -100,6 +103,36 @@ func TestManagerCreate(t *testing.T) {
 	}
 }
 
+func TestManagerCreateGeneratesID(t *testing.T) {
+	var n int
+	gen := id.GeneratorFunc(func() string {
+		n++
+		return fmt.Sprintf("custom-%d", n)
+	})
+	manager := NewManager(WithStore(newTestStore()), WithIDGenerator(gen))
+
+	sess, err := manager.Create(context.Background(), "", agent.New())
+	if err != nil {
+		t.Fatalf("Failed to create session: %v", err)
+	}
+	if sess.ID() != "custom-1" {
+		t.Errorf("Expected generated ID custom-1, got %s", sess.ID())
+	}
+
+	shared, err := manager.CreateShared(context.Background(), "")
+	if err != nil {
+		t.Fatalf("Failed to create shared session: %v", err)
+	}
+	if shared.ID() != "custom-2" {
+		t.Errorf("Expected generated ID custom-2, got %s", shared.ID())
+	}
+
+	defaults := NewManager(WithStore(newTestStore()))
+	if a, b := defaults.NewID(), defaults.NewID(); a == b || !strings.HasPrefix(a, "sess_") {
+		t.Errorf("Expected unique sess_-prefixed IDs, got %s and %s", a, b)
+	}
+}
+
 func TestManagerCreateDuplicate(t *testing.T) {
 	manager := NewManager(WithStore(newTestStore()))
 	ag := agent.New()
@@ -347,6 +380,29 @@ func TestSharedSessionSnapshotRoundTrip(t *testing.T) {
 	}
 }
 
+func TestSessionMetadataRoundTrip(t *testing.T) {
+	ag := agent.New(agent.WithName("meta"))
+	var sess Session = New("sess-meta", ag)
+	sess.SetMetadata("customer_id", "c-42")
+	sess.SetMetadata("tier", "vip")
+
+	snap := sess.Snapshot()
+	if snap.Metadata["customer_id"] != "c-42" {
+		t.Fatalf("expected metadata in snapshot, got %+v", snap.Metadata)
+	}
+
+	restored := NewSingleFromRecord(snap, ag)
+	if v, ok := restored.GetMetadata("tier"); !ok || v != "vip" {
+		t.Fatalf("expected tier to survive restore, got %v (ok=%v)", v, ok)
+	}
+
+	shared := NewSharedFromRecord(&Record{ID: "shared-meta", Type: TypeShared, State: StateActive})
+	shared.SetMetadata("channel", "web")
+	if v, ok := NewSharedFromRecord(shared.Snapshot()).GetMetadata("channel"); !ok || v != "web" {
+		t.Fatalf("expected channel metadata to round-trip, got %v (ok=%v)", v, ok)
+	}
+}
+
 // newTestStore creates a simple test store implementation
 func newTestStore() Store {
 	return &testStore{
@@ -412,3 +468,461 @@ func (s *testStore) Exists(ctx context.Context, id string) (bool, error) {
 	_, exists := s.records[id]
 	return exists, nil
 }
+
+func TestManagerGetTyped(t *testing.T) {
+	ctx := context.Background()
+	manager := NewManager(WithStore(newTestStore()))
+	if _, err := manager.Create(ctx, "single", agent.New()); err != nil {
+		t.Fatalf("Failed to create session: %v", err)
+	}
+	if _, err := manager.CreateShared(ctx, "shared"); err != nil {
+		t.Fatalf("Failed to create shared session: %v", err)
+	}
+
+	single, err := manager.GetSingle(ctx, "single")
+	if err != nil || single.ID() != "single" {
+		t.Fatalf("GetSingle returned %v, %v", single, err)
+	}
+	shared, err := manager.GetShared(ctx, "shared")
+	if err != nil || shared.ID() != "shared" {
+		t.Fatalf("GetShared returned %v, %v", shared, err)
+	}
+
+	if _, err := manager.GetShared(ctx, "single"); !errors.Is(err, ErrSessionTypeMismatch) {
+		t.Errorf("Expected ErrSessionTypeMismatch, got %v", err)
+	}
+	if _, err := GetTyped[*SingleAgentSession](ctx, manager, "shared"); !errors.Is(err, ErrSessionTypeMismatch) {
+		t.Errorf("Expected ErrSessionTypeMismatch, got %v", err)
+	}
+}
+
+type summaryLLM struct {
+	transcript string
+}
+
+func (s *summaryLLM) Generate(ctx context.Context, req *agent.GenerateRequest) (*agent.GenerateResponse, error) {
+	s.transcript = req.Messages[len(req.Messages)-1].Text()
+	return &agent.GenerateResponse{Message: message.NewMessage(message.RoleAssistant, "user asked about billing")}, nil
+}
+
+func (s *summaryLLM) SetTemperature(float64) {}
+func (s *summaryLLM) SetMaxTokens(int64)     {}
+func (s *summaryLLM) SetModel(string)        {}
+
+func TestManagerCompact(t *testing.T) {
+	ctx := context.Background()
+	llm := &summaryLLM{}
+	store := newTestStore()
+	mgr := NewManager(WithStore(store), WithCompactionLLM(llm))
+
+	sess, err := mgr.CreateShared(ctx, "compact")
+	if err != nil {
+		t.Fatalf("create shared: %v", err)
+	}
+	sess.SetMessages([]*message.Message{
+		message.NewMessage(message.RoleSystem, "be helpful"),
+		message.NewMessage(message.RoleUser, "billing question"),
+		message.NewMessage(message.RoleAssistant, "sure"),
+		message.NewToolCallMessage([]message.ToolCall{{ID: "c1", Name: "lookup"}}),
+		message.NewToolResponseMessage("c1", "invoice 42"),
+		message.NewMessage(message.RoleAssistant, "found invoice 42"),
+	})
+
+	if err := mgr.Compact(ctx, "compact", 2); err != nil {
+		t.Fatalf("compact: %v", err)
+	}
+	if !strings.Contains(llm.transcript, "billing question") {
+		t.Fatalf("expected older messages in transcript, got %q", llm.transcript)
+	}
+
+	msgs := sess.GetMessages()
+	if len(msgs) != 5 {
+		t.Fatalf("expected 5 messages after compaction, got %d", len(msgs))
+	}
+	if msgs[0].Text() != "be helpful" {
+		t.Fatalf("expected system prompt to be kept, got %q", msgs[0].Text())
+	}
+	if msgs[1].Role != message.RoleSystem || !strings.HasPrefix(msgs[1].Text(), CompactionSummaryPrefix) {
+		t.Fatalf("expected summary message, got %+v", msgs[1])
+	}
+	if len(msgs[2].ToolCalls) != 1 || msgs[3].Role != message.RoleTool {
+		t.Fatalf("expected tool call and response to stay together, got %+v", msgs[2:])
+	}
+
+	record, err := store.Load(ctx, "compact")
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if len(record.Messages) != 5 {
+		t.Fatalf("expected compacted record to be persisted, got %d messages", len(record.Messages))
+	}
+
+	if err := NewManager(WithStore(store)).Compact(ctx, "compact", 2); err == nil {
+		t.Fatal("expected error without compaction LLM")
+	}
+}
+
+func TestSplitForCompactionKeepNothing(t *testing.T) {
+	msgs := []*message.Message{
+		message.NewMessage(message.RoleSystem, "be helpful"),
+		message.NewToolCallMessage([]message.ToolCall{{ID: "c1", Name: "lookup"}}),
+		message.NewToolResponseMessage("c1", "invoice 42"),
+	}
+	head, older, recent := splitForCompaction(msgs, 0)
+	if len(head) != 1 || len(older) != 2 || len(recent) != 0 {
+		t.Fatalf("expected everything after the system prompt to be summarized, got %d/%d/%d", len(head), len(older), len(recent))
+	}
+}
+
+func TestManagerFlushAll(t *testing.T) {
+	ctx := context.Background()
+	store := newTestStore()
+	mgr := NewManager(WithStore(store), WithFlushInterval(10*time.Millisecond))
+
+	sess, err := mgr.CreateShared(ctx, "flush")
+	if err != nil {
+		t.Fatalf("create shared: %v", err)
+	}
+	sess.SetMessages([]*message.Message{message.NewMessage(message.RoleUser, "unsaved")})
+
+	deadline := time.Now().Add(time.Second)
+	for {
+		record, err := store.Load(ctx, "flush")
+		if err != nil {
+			t.Fatalf("load: %v", err)
+		}
+		if len(record.Messages) == 1 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("expected background flush to persist the session")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	sess.SetMessages(append(sess.GetMessages(), message.NewMessage(message.RoleAssistant, "reply")))
+	if err := mgr.Close(ctx); err != nil {
+		t.Fatalf("close: %v", err)
+	}
+	record, err := store.Load(ctx, "flush")
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if len(record.Messages) != 2 {
+		t.Fatalf("expected Close to flush pending changes, got %d messages", len(record.Messages))
+	}
+	if err := NewManager().FlushAll(ctx); err == nil {
+		t.Fatal("expected error without a store")
+	}
+}
+
+// meteredLLM answers every call and reports fixed token usage.
+type meteredLLM struct{}
+
+func (meteredLLM) Generate(ctx context.Context, req *agent.GenerateRequest) (*agent.GenerateResponse, error) {
+	msg := message.NewMessage(message.RoleAssistant, "ok")
+	msg.Completed = true
+	return &agent.GenerateResponse{Message: msg, Usage: agent.Usage{PromptTokens: 10, CompletionTokens: 3}}, nil
+}
+
+func (meteredLLM) SetTemperature(float64) {}
+func (meteredLLM) SetMaxTokens(int64)     {}
+func (meteredLLM) SetModel(string)        {}
+
+func TestSessionUsageAccumulates(t *testing.T) {
+	ctx := context.Background()
+	ag := agent.New(agent.WithProvider(meteredLLM{}))
+	sess := New("usage", ag)
+	for _, input := range []string{"one", "two"} {
+		if _, err := sess.Run(ctx, input); err != nil {
+			t.Fatalf("run: %v", err)
+		}
+	}
+
+	want := agent.Usage{PromptTokens: 20, CompletionTokens: 6, TotalTokens: 26}
+	if got := sess.Usage(); got != want {
+		t.Fatalf("expected usage %+v, got %+v", want, got)
+	}
+	record := sess.Snapshot()
+	if record.TotalPromptTokens != 20 || record.TotalCompletionTokens != 6 || record.TotalTokens != 26 {
+		t.Fatalf("expected usage in snapshot, got %d/%d/%d", record.TotalPromptTokens, record.TotalCompletionTokens, record.TotalTokens)
+	}
+	if got := NewSingleFromRecord(record, ag).Usage(); got != want {
+		t.Fatalf("expected usage to survive rehydration, got %+v", got)
+	}
+	if !ag.Usage().IsZero() {
+		t.Fatalf("expected prototype agent usage to stay untouched, got %+v", ag.Usage())
+	}
+}
+
+func TestCleanupInactiveHonoursIdleDuration(t *testing.T) {
+	ctx := context.Background()
+	store := newTestStore()
+	manager := NewManager(WithStore(store))
+	ag := agent.New(agent.WithProvider(meteredLLM{}))
+
+	stale, err := manager.Create(ctx, "stale", ag)
+	if err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	fresh, err := manager.Create(ctx, "fresh", ag)
+	if err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	if _, err := fresh.Run(ctx, "hi"); err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	if fresh.LastAccessedAt().IsZero() {
+		t.Fatal("expected Run to record the access time")
+	}
+
+	// A stored-only record that went idle long ago, still marked active.
+	old := time.Now().Add(-2 * time.Hour)
+	if err := store.Save(ctx, &Record{ID: "stored", Type: TypeShared, State: StateActive, CreatedAt: old, UpdatedAt: old}); err != nil {
+		t.Fatalf("save: %v", err)
+	}
+	stale.mu.Lock()
+	stale.CreatedAt, stale.UpdatedAt = old, old
+	stale.mu.Unlock()
+
+	removed, err := manager.CleanupInactive(ctx, time.Hour)
+	if err != nil {
+		t.Fatalf("cleanup: %v", err)
+	}
+	if removed != 2 {
+		t.Fatalf("expected 2 idle sessions removed, got %d", removed)
+	}
+	ids, _ := manager.List(ctx)
+	if len(ids) != 1 || ids[0] != "fresh" {
+		t.Fatalf("expected only the fresh session to remain, got %v", ids)
+	}
+	if stale.GetState() != StateClosed {
+		t.Fatalf("expected removed cached session to be closed, got %s", stale.GetState())
+	}
+}
+
+func TestManagerTTLExpiresIdleSessions(t *testing.T) {
+	ctx := context.Background()
+	store := newTestStore()
+	manager := NewManager(WithStore(store), WithTTL(time.Hour))
+	defer manager.Close(ctx)
+	ag := agent.New(agent.WithProvider(meteredLLM{}))
+
+	sess, err := manager.Create(ctx, "idle", ag)
+	if err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	if _, err := manager.Get(ctx, "idle"); err != nil {
+		t.Fatalf("expected fresh session, got %v", err)
+	}
+	old := time.Now().Add(-2 * time.Hour)
+	sess.mu.Lock()
+	sess.CreatedAt, sess.UpdatedAt = old, old
+	sess.mu.Unlock()
+
+	for i := 0; i < 2; i++ {
+		if _, err := manager.Get(ctx, "idle"); !errors.Is(err, ErrSessionExpired) {
+			t.Fatalf("expected ErrSessionExpired, got %v", err)
+		}
+	}
+	if sess.GetState() != StateInactive {
+		t.Fatalf("expected expired session to be inactive, got %s", sess.GetState())
+	}
+	if record, _ := store.Load(ctx, "idle"); record.State != StateInactive {
+		t.Fatalf("expected inactive state persisted, got %s", record.State)
+	}
+
+	if err := store.Save(ctx, &Record{ID: "stored", Type: TypeShared, State: StateActive, CreatedAt: old, UpdatedAt: old}); err != nil {
+		t.Fatalf("save: %v", err)
+	}
+	if _, err := manager.Get(ctx, "stored"); !errors.Is(err, ErrSessionExpired) {
+		t.Fatalf("expected stored session to expire, got %v", err)
+	}
+}
+
+func TestManagerTTLSweeperDeletesExpiredSessions(t *testing.T) {
+	ctx := context.Background()
+	store := newTestStore()
+	manager := NewManager(WithStore(store), WithTTL(20*time.Millisecond), WithDeleteExpired())
+	ag := agent.New(agent.WithProvider(meteredLLM{}))
+
+	if _, err := manager.Create(ctx, "abandoned", ag); err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		if exists, _ := store.Exists(ctx, "abandoned"); !exists {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("expected sweeper to delete the abandoned session")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	if _, ok := manager.getCached("abandoned"); ok {
+		t.Fatal("expected expired session to leave the cache")
+	}
+
+	if err := manager.Close(ctx); err != nil {
+		t.Fatalf("close: %v", err)
+	}
+	if err := manager.Close(ctx); err != nil {
+		t.Fatalf("second close: %v", err)
+	}
+}
+
+// echoLLM answers each turn by echoing the latest user message.
+type echoLLM struct{}
+
+func (echoLLM) Generate(ctx context.Context, req *agent.GenerateRequest) (*agent.GenerateResponse, error) {
+	msg := message.NewMessage(message.RoleAssistant, "re: "+req.Messages[len(req.Messages)-1].Text())
+	msg.Completed = true
+	return &agent.GenerateResponse{Message: msg}, nil
+}
+
+func (echoLLM) SetTemperature(float64) {}
+func (echoLLM) SetMaxTokens(int64)     {}
+func (echoLLM) SetModel(string)        {}
+
+func TestSharedSessionConcurrentAgents(t *testing.T) {
+	const agents, turns = 8, 5
+	run := map[string]func(*SharedSession, context.Context, *agent.Agent, string) (string, error){
+		"serialized": (*SharedSession).RunWithAgent,
+		"concurrent": (*SharedSession).RunWithAgentConcurrent,
+	}
+	for name, runTurn := range run {
+		t.Run(name, func(t *testing.T) {
+			sess := NewShared(name)
+			var wg sync.WaitGroup
+			for a := 0; a < agents; a++ {
+				ag := agent.New(agent.WithProvider(echoLLM{}), agent.WithSystemPrompt("be brief"))
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					for turn := 0; turn < turns; turn++ {
+						if _, err := runTurn(sess, context.Background(), ag, fmt.Sprintf("agent %d turn %d", a, turn)); err != nil {
+							t.Errorf("turn failed: %v", err)
+						}
+					}
+				}()
+			}
+			wg.Wait()
+
+			msgs := sess.GetMessages()
+			if len(msgs) != 1+2*agents*turns {
+				t.Fatalf("expected %d messages, got %d", 1+2*agents*turns, len(msgs))
+			}
+			if msgs[0].Role != message.RoleSystem || msgs[0].Text() != "be brief" {
+				t.Fatalf("expected the system prompt first, got %+v", msgs[0])
+			}
+			msgs = msgs[1:]
+			for _, msg := range msgs {
+				if msg.Role == message.RoleSystem {
+					t.Fatalf("expected exactly one system message, found another: %q", msg.Text())
+				}
+			}
+			for i := 0; i < len(msgs); i += 2 {
+				if msgs[i].Role != message.RoleUser || msgs[i+1].Text() != "re: "+msgs[i].Text() {
+					t.Fatalf("turn at %d interleaved: %q then %q", i, msgs[i].Text(), msgs[i+1].Text())
+				}
+			}
+		})
+	}
+}
+
+// barrierLLM holds every call until n calls are in flight, so turns overlap.
+type barrierLLM struct {
+	echoLLM
+	wg *sync.WaitGroup
+}
+
+func (b barrierLLM) Generate(ctx context.Context, req *agent.GenerateRequest) (*agent.GenerateResponse, error) {
+	b.wg.Done()
+	b.wg.Wait()
+	return b.echoLLM.Generate(ctx, req)
+}
+
+func TestSharedSessionConcurrentFirstTurnsStoreOneSystemPrompt(t *testing.T) {
+	const agents = 4
+	sess := NewShared("first-turns")
+	barrier := &sync.WaitGroup{}
+	barrier.Add(agents)
+	var wg sync.WaitGroup
+	for a := 0; a < agents; a++ {
+		ag := agent.New(agent.WithProvider(barrierLLM{wg: barrier}), agent.WithSystemPrompt("be brief"))
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := sess.RunWithAgentConcurrent(context.Background(), ag, fmt.Sprintf("agent %d", a)); err != nil {
+				t.Errorf("turn failed: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	msgs := sess.GetMessages()
+	systems := 0
+	for _, msg := range msgs {
+		if msg.Role == message.RoleSystem {
+			systems++
+		}
+	}
+	if len(msgs) != 1+2*agents || systems != 1 {
+		t.Fatalf("expected one system prompt and %d turns, got %d messages with %d system", agents, len(msgs), systems)
+	}
+}
+
+// reentrantSession calls back into the manager when closed, as a session
+// finishing a turn on another goroutine would.
+type reentrantSession struct {
+	*SingleAgentSession
+	manager *Manager
+}
+
+func (s reentrantSession) Close() error {
+	_, _ = s.manager.getCached("other")
+	return s.SingleAgentSession.Close()
+}
+
+func TestManagerClosesSessionsOutsideItsLock(t *testing.T) {
+	ctx := context.Background()
+	manager := NewManager(WithStore(newTestStore()))
+	ag := agent.New(agent.WithProvider(meteredLLM{}))
+
+	for _, id := range []string{"deleted", "idle"} {
+		sess, err := manager.Create(ctx, id, ag)
+		if err != nil {
+			t.Fatalf("create: %v", err)
+		}
+		manager.mu.Lock()
+		manager.sessions[id] = reentrantSession{SingleAgentSession: sess, manager: manager}
+		manager.mu.Unlock()
+		if id == "idle" {
+			sess.SetState(StateInactive)
+		}
+	}
+
+	done := make(chan error)
+	go func() {
+		if err := manager.Delete(ctx, "deleted"); err != nil {
+			done <- err
+			return
+		}
+		_, err := manager.CleanupInactive(ctx, 0)
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("closing a session while holding the manager lock deadlocked")
+	}
+	if ids, _ := manager.List(ctx); len(ids) != 0 {
+		t.Fatalf("expected both sessions to be removed, got %v", ids)
+	}
+	if _, ok := manager.getCached("idle"); ok {
+		t.Fatal("expected the idle session to leave the cache")
+	}
+}
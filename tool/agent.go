@@ -0,0 +1,60 @@
+package tool
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/sweetpotato0/ai-allin/message"
+)
+
+// AgentInputParam is the argument name carrying the task for an agent-backed tool.
+const AgentInputParam = "input"
+
+// AgentRunner is the subset of *agent.Agent needed to expose an agent as a tool.
+// It is declared here to avoid an import cycle between the tool and agent packages.
+type AgentRunner interface {
+	Run(ctx context.Context, input string) (*message.Message, error)
+}
+
+// FromAgent wraps a sub-agent as a tool so a coordinator agent can delegate to it.
+// The handler runs the wrapped agent with the "input" argument and returns its reply text.
+// The sub-agent keeps its own conversation across calls; pass a fresh clone when each
+// invocation should start from a clean history. Calls are serialized because they
+// share that conversation, so parallel tool calls to the same agent tool run one at
+// a time.
+func FromAgent(name, description string, ag AgentRunner) *Tool {
+	var mu sync.Mutex
+	return &Tool{
+		Name:        name,
+		Description: description,
+		Parameters: []Parameter{
+			{
+				Name:        AgentInputParam,
+				Type:        "string",
+				Description: "Task or question for the " + name + " agent",
+				Required:    true,
+			},
+		},
+		Handler: func(ctx context.Context, args map[string]any) (string, error) {
+			if ag == nil {
+				return "", fmt.Errorf("agent tool %s has no agent", name)
+			}
+			input, ok := args[AgentInputParam].(string)
+			if !ok || strings.TrimSpace(input) == "" {
+				return "", fmt.Errorf("agent tool %s requires a non-empty %q argument", name, AgentInputParam)
+			}
+			mu.Lock()
+			reply, err := ag.Run(ctx, input)
+			mu.Unlock()
+			if err != nil {
+				return "", fmt.Errorf("agent tool %s failed: %w", name, err)
+			}
+			if reply == nil {
+				return "", nil
+			}
+			return reply.Text(), nil
+		},
+	}
+}
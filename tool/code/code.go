@@ -0,0 +1,157 @@
+// Package code provides an opt-in tool that executes code or commands through a
+// pluggable Executor. The built-in CommandExecutor only runs allowlisted programs
+// with a minimal environment. It is not a sandbox: allowlisting an interpreter
+// such as python3 or sh lets the model run any code it writes, so plug in a real
+// sandbox for anything that needs isolation.
+package code
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/sweetpotato0/ai-allin/tool"
+)
+
+// CodeParam is the argument name carrying the code or command to run.
+const CodeParam = "code"
+
+// Result is the outcome of a single execution.
+type Result struct {
+	Stdout   string `json:"stdout"`
+	Stderr   string `json:"stderr"`
+	ExitCode int    `json:"exit_code"`
+	// Truncated reports that the executor dropped output beyond its capture limit.
+	Truncated bool `json:"truncated,omitempty"`
+}
+
+// Executor runs code. Implementations must stop when ctx is done.
+// A non-zero exit code is reported in Result, not as an error.
+type Executor interface {
+	Execute(ctx context.Context, code string) (*Result, error)
+}
+
+type config struct {
+	name        string
+	description string
+	timeout     time.Duration
+	maxOutput   int
+}
+
+// Option customises the code execution tool.
+type Option func(*config)
+
+// WithName overrides the tool name (default "run_code").
+func WithName(name string) Option {
+	return func(c *config) {
+		if name != "" {
+			c.name = name
+		}
+	}
+}
+
+// WithDescription overrides the tool description shown to the model.
+func WithDescription(description string) Option {
+	return func(c *config) {
+		if description != "" {
+			c.description = description
+		}
+	}
+}
+
+// WithTimeout bounds each execution (default 30s).
+func WithTimeout(d time.Duration) Option {
+	return func(c *config) {
+		if d > 0 {
+			c.timeout = d
+		}
+	}
+}
+
+// WithMaxOutput truncates stdout and stderr to n bytes each (default 16 KiB).
+func WithMaxOutput(n int) Option {
+	return func(c *config) {
+		if n > 0 {
+			c.maxOutput = n
+		}
+	}
+}
+
+// New creates a tool that runs the model-supplied code with executor and returns
+// stdout, stderr and the exit code.
+//
+// Example:
+//
+//	exec := code.NewCommandExecutor([]string{"wc", "date"})
+//	_ = ag.RegisterTool(code.New(exec, code.WithTimeout(10*time.Second)))
+func New(executor Executor, opts ...Option) *tool.Tool {
+	cfg := &config{
+		name:        "run_code",
+		description: "Execute code in a restricted environment. Returns stdout, stderr and the exit code.",
+		timeout:     30 * time.Second,
+		maxOutput:   16 << 10,
+	}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+
+	return &tool.Tool{
+		Name:        cfg.name,
+		Description: cfg.description,
+		Parameters: []tool.Parameter{
+			{
+				Name:        CodeParam,
+				Type:        "string",
+				Description: "Code or command to execute",
+				Required:    true,
+			},
+		},
+		Handler: func(ctx context.Context, args map[string]any) (string, error) {
+			if executor == nil {
+				return "", fmt.Errorf("code tool %s has no executor", cfg.name)
+			}
+			code, ok := args[CodeParam].(string)
+			if !ok || strings.TrimSpace(code) == "" {
+				return "", fmt.Errorf("code execution requires a non-empty %q argument", CodeParam)
+			}
+			ctx, cancel := context.WithTimeout(ctx, cfg.timeout)
+			defer cancel()
+			res, err := executor.Execute(ctx, code)
+			if err != nil {
+				if ctx.Err() == context.DeadlineExceeded {
+					return "", fmt.Errorf("code execution timed out after %s: %w", cfg.timeout, err)
+				}
+				return "", fmt.Errorf("code execution failed: %w", err)
+			}
+			return FormatResult(res, cfg.maxOutput), nil
+		},
+	}
+}
+
+// FormatResult renders res for an LLM, truncating each stream to maxOutput bytes
+// when maxOutput is positive.
+func FormatResult(res *Result, maxOutput int) string {
+	if res == nil {
+		return "exit_code: 0"
+	}
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "exit_code: %d", res.ExitCode)
+	if out := truncate(res.Stdout, maxOutput); out != "" {
+		fmt.Fprintf(&sb, "\nstdout:\n%s", out)
+	}
+	if errOut := truncate(res.Stderr, maxOutput); errOut != "" {
+		fmt.Fprintf(&sb, "\nstderr:\n%s", errOut)
+	}
+	return sb.String()
+}
+
+// truncatedMarker is appended to output that was cut short.
+const truncatedMarker = "\n... (truncated)"
+
+func truncate(s string, limit int) string {
+	if limit <= 0 || len(s) <= limit {
+		return s
+	}
+	return s[:limit] + truncatedMarker
+}
@@ -0,0 +1,95 @@
+package code
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestToolRunsAllowlistedCommand(t *testing.T) {
+	runTool := New(NewCommandExecutor([]string{"echo", "sh"}))
+	out, err := runTool.Execute(context.Background(), map[string]any{CodeParam: `echo "hello world"`})
+	if err != nil {
+		t.Fatalf("Execute failed: %v", err)
+	}
+	if out != "exit_code: 0\nstdout:\nhello world\n" {
+		t.Errorf("unexpected output %q", out)
+	}
+
+	out, err = runTool.Execute(context.Background(), map[string]any{CodeParam: `sh -c 'echo oops >&2; exit 3'`})
+	if err != nil {
+		t.Fatalf("Execute failed: %v", err)
+	}
+	if !strings.HasPrefix(out, "exit_code: 3") || !strings.Contains(out, "stderr:\noops") {
+		t.Errorf("expected exit code and stderr, got %q", out)
+	}
+}
+
+func TestCommandExecutorRejectsUnlistedCommand(t *testing.T) {
+	exec := NewCommandExecutor([]string{"echo"})
+	for _, code := range []string{"rm -rf /tmp/x", "/bin/echo hi"} {
+		if _, err := exec.Execute(context.Background(), code); !errors.Is(err, ErrCommandNotAllowed) {
+			t.Errorf("expected ErrCommandNotAllowed for %q, got %v", code, err)
+		}
+	}
+	if _, err := exec.Execute(context.Background(), `echo "unterminated`); err == nil {
+		t.Error("expected error for unterminated quote")
+	}
+}
+
+func TestToolTimeout(t *testing.T) {
+	runTool := New(NewCommandExecutor([]string{"sleep"}), WithTimeout(50*time.Millisecond))
+	start := time.Now()
+	_, err := runTool.Execute(context.Background(), map[string]any{CodeParam: "sleep 5"})
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected deadline exceeded, got %v", err)
+	}
+	if time.Since(start) > 3*time.Second {
+		t.Fatalf("command was not stopped at the timeout")
+	}
+}
+
+func TestCommandExecutorEnvironment(t *testing.T) {
+	t.Setenv("CODE_TEST_SECRET", "s3cret")
+	ctx := context.Background()
+
+	res, err := NewCommandExecutor([]string{"env"}, WithEnv([]string{"GREETING=hi"})).Execute(ctx, "env")
+	if err != nil {
+		t.Fatalf("Execute failed: %v", err)
+	}
+	if strings.Contains(res.Stdout, "CODE_TEST_SECRET") || !strings.Contains(res.Stdout, "GREETING=hi") || !strings.Contains(res.Stdout, "PATH=") {
+		t.Fatalf("expected only PATH and explicit variables, got %q", res.Stdout)
+	}
+
+	res, err = NewCommandExecutor([]string{"env"}, WithInheritedEnv()).Execute(ctx, "env")
+	if err != nil {
+		t.Fatalf("Execute failed: %v", err)
+	}
+	if !strings.Contains(res.Stdout, "CODE_TEST_SECRET=s3cret") {
+		t.Fatalf("expected the inherited environment, got %q", res.Stdout)
+	}
+}
+
+func TestCommandExecutorCapsCapturedOutput(t *testing.T) {
+	exec := NewCommandExecutor([]string{"sh"}, WithMaxCapture(10))
+	res, err := exec.Execute(context.Background(), `sh -c 'yes | head -c 100000; echo warning >&2'`)
+	if err != nil {
+		t.Fatalf("Execute failed: %v", err)
+	}
+	if !res.Truncated || res.Stdout != "y\ny\ny\ny\ny\n"+truncatedMarker {
+		t.Fatalf("expected stdout capped at 10 bytes and marked, got %q (truncated=%v)", res.Stdout, res.Truncated)
+	}
+	if res.Stderr != "warning\n" || res.ExitCode != 0 {
+		t.Fatalf("expected stderr within the cap and a clean exit, got %q and %d", res.Stderr, res.ExitCode)
+	}
+
+	res, err = exec.Execute(context.Background(), `sh -c 'echo short'`)
+	if err != nil {
+		t.Fatalf("Execute failed: %v", err)
+	}
+	if res.Truncated || res.Stdout != "short\n" {
+		t.Fatalf("expected untruncated output, got %q (truncated=%v)", res.Stdout, res.Truncated)
+	}
+}
@@ -0,0 +1,206 @@
+package code
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// ErrCommandNotAllowed is returned when a command is not on the executor's allowlist.
+var ErrCommandNotAllowed = errors.New("command not allowed")
+
+var _ Executor = (*CommandExecutor)(nil)
+
+// CommandExecutor runs a single allowlisted program directly, without a shell.
+// The code is split into arguments on whitespace, honouring single and double
+// quotes; pipes, redirection and variable expansion are not interpreted.
+//
+// Commands do not inherit the process environment: they only see PATH plus the
+// variables passed to WithEnv, so API keys and other secrets stay out of reach
+// unless WithInheritedEnv is set.
+//
+// The allowlist is not a sandbox. Allowing an interpreter or any program that
+// runs its arguments (python3, node, sh, env, xargs, ...) lets the model run
+// arbitrary code with the permissions of the current process.
+type CommandExecutor struct {
+	allowed    map[string]struct{}
+	dir        string
+	env        []string
+	inheritEnv bool
+	maxCapture int
+}
+
+// DefaultMaxCapture is how many bytes of stdout and stderr each a
+// CommandExecutor keeps by default.
+const DefaultMaxCapture = 1 << 20
+
+// CommandOption customises a CommandExecutor.
+type CommandOption func(*CommandExecutor)
+
+// WithDir sets the working directory of executed commands.
+func WithDir(dir string) CommandOption {
+	return func(e *CommandExecutor) {
+		e.dir = dir
+	}
+}
+
+// WithEnv adds "KEY=value" variables to the environment of executed commands.
+func WithEnv(env []string) CommandOption {
+	return func(e *CommandExecutor) {
+		e.env = append([]string(nil), env...)
+	}
+}
+
+// WithInheritedEnv lets executed commands inherit the full environment of the
+// current process, including any secrets it holds. Variables from WithEnv
+// still take precedence.
+func WithInheritedEnv() CommandOption {
+	return func(e *CommandExecutor) {
+		e.inheritEnv = true
+	}
+}
+
+// WithMaxCapture keeps at most n bytes of stdout and stderr each (default
+// DefaultMaxCapture). Further output is discarded, so a chatty command cannot
+// exhaust memory, and the result is marked truncated.
+func WithMaxCapture(n int) CommandOption {
+	return func(e *CommandExecutor) {
+		if n > 0 {
+			e.maxCapture = n
+		}
+	}
+}
+
+// NewCommandExecutor returns an executor that only runs the programs named in
+// allowed. Names are matched exactly against the first argument, so "ls" does
+// not permit "/bin/ls". An empty allowlist rejects every command.
+func NewCommandExecutor(allowed []string, opts ...CommandOption) *CommandExecutor {
+	e := &CommandExecutor{allowed: make(map[string]struct{}, len(allowed)), maxCapture: DefaultMaxCapture}
+	for _, name := range allowed {
+		if name = strings.TrimSpace(name); name != "" {
+			e.allowed[name] = struct{}{}
+		}
+	}
+	for _, opt := range opts {
+		opt(e)
+	}
+	return e
+}
+
+// Execute implements Executor.
+func (e *CommandExecutor) Execute(ctx context.Context, code string) (*Result, error) {
+	args, err := splitArgs(code)
+	if err != nil {
+		return nil, err
+	}
+	if len(args) == 0 {
+		return nil, fmt.Errorf("empty command")
+	}
+	if _, ok := e.allowed[args[0]]; !ok {
+		return nil, fmt.Errorf("%w: %s", ErrCommandNotAllowed, args[0])
+	}
+
+	cmd := exec.CommandContext(ctx, args[0], args[1:]...)
+	cmd.Dir = e.dir
+	cmd.Env = e.environ()
+	// Do not wait forever for children that inherited the output pipes.
+	cmd.WaitDelay = time.Second
+	stdout, stderr := &cappedBuffer{limit: e.maxCapture}, &cappedBuffer{limit: e.maxCapture}
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+
+	err = cmd.Run()
+	res := &Result{Stdout: stdout.String(), Stderr: stderr.String(), Truncated: stdout.dropped || stderr.dropped}
+	if err != nil {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return nil, ctxErr
+		}
+		var exitErr *exec.ExitError
+		if !errors.As(err, &exitErr) {
+			return nil, fmt.Errorf("failed to run %s: %w", args[0], err)
+		}
+		res.ExitCode = exitErr.ExitCode()
+	}
+	return res, nil
+}
+
+// cappedBuffer keeps the first limit bytes written to it and discards the rest
+// while still reporting success, so the command is not killed by a write error.
+type cappedBuffer struct {
+	buf     bytes.Buffer
+	limit   int
+	dropped bool
+}
+
+func (b *cappedBuffer) Write(p []byte) (int, error) {
+	if room := b.limit - b.buf.Len(); room < len(p) {
+		b.dropped = true
+		b.buf.Write(p[:max(room, 0)])
+		return len(p), nil
+	}
+	return b.buf.Write(p)
+}
+
+// String returns the kept output, marked when some was discarded.
+func (b *cappedBuffer) String() string {
+	if b.dropped {
+		return b.buf.String() + truncatedMarker
+	}
+	return b.buf.String()
+}
+
+// environ returns the environment of executed commands. It is never nil, since
+// a nil exec.Cmd.Env would inherit the process environment.
+func (e *CommandExecutor) environ() []string {
+	var base []string
+	if e.inheritEnv {
+		base = os.Environ()
+	} else if path, ok := os.LookupEnv("PATH"); ok {
+		base = []string{"PATH=" + path}
+	}
+	return append(append(make([]string, 0, len(base)+len(e.env)), base...), e.env...)
+}
+
+// splitArgs splits s on whitespace, keeping quoted sections together.
+func splitArgs(s string) ([]string, error) {
+	var (
+		args    []string
+		current strings.Builder
+		quote   rune
+		inArg   bool
+	)
+	for _, r := range s {
+		switch {
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			} else {
+				current.WriteRune(r)
+			}
+		case r == '\'' || r == '"':
+			quote = r
+			inArg = true
+		case r == ' ' || r == '\t' || r == '\n' || r == '\r':
+			if inArg {
+				args = append(args, current.String())
+				current.Reset()
+				inArg = false
+			}
+		default:
+			current.WriteRune(r)
+			inArg = true
+		}
+	}
+	if quote != 0 {
+		return nil, fmt.Errorf("unterminated %c quote in command", quote)
+	}
+	if inArg {
+		args = append(args, current.String())
+	}
+	return args, nil
+}
@@ -0,0 +1,200 @@
+package tool
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+)
+
+var (
+	contextType = reflect.TypeOf((*context.Context)(nil)).Elem()
+	errorType   = reflect.TypeOf((*error)(nil)).Elem()
+	timeType    = reflect.TypeOf(time.Time{})
+)
+
+// FromFunc builds a tool from a typed handler of the form
+//
+//	func(ctx context.Context, args T) (string, error)
+//
+// where T is a struct or a pointer to one. The parameters are derived from the
+// exported fields of T: the json tag names them (fields tagged "-" are skipped),
+// and the description, required:"true" and enum:"a,b,c" tags fill in the rest.
+// Embedded structs are flattened like encoding/json does. Incoming arguments
+// are decoded into a fresh T before the handler runs.
+func FromFunc(name, description string, handler any) (*Tool, error) {
+	fn := reflect.ValueOf(handler)
+	if fn.Kind() != reflect.Func || fn.IsNil() {
+		return nil, fmt.Errorf("tool %s: handler must be func(context.Context, T) (string, error), got %T", name, handler)
+	}
+	ft := fn.Type()
+	if ft.NumIn() != 2 || ft.NumOut() != 2 || ft.In(0) != contextType || ft.Out(0).Kind() != reflect.String || ft.Out(1) != errorType {
+		return nil, fmt.Errorf("tool %s: handler must be func(context.Context, T) (string, error), got %s", name, ft)
+	}
+	argType := ft.In(1)
+	structType := argType
+	if structType.Kind() == reflect.Pointer {
+		structType = structType.Elem()
+	}
+	if structType.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("tool %s: handler arguments must be a struct, got %s", name, argType)
+	}
+
+	var params []Parameter
+	err := visitFields(structType, func(field reflect.StructField, jsonName string) error {
+		schema, err := schemaFor(field.Type, map[reflect.Type]bool{structType: true})
+		if err != nil {
+			return fmt.Errorf("field %s: %w", field.Name, err)
+		}
+		param := Parameter{
+			Name:        jsonName,
+			Type:        schema["type"].(string),
+			Description: field.Tag.Get("description"),
+			Required:    field.Tag.Get("required") == "true",
+		}
+		if enum := field.Tag.Get("enum"); enum != "" {
+			param.Enum = strings.Split(enum, ",")
+		}
+		if len(schema) > 1 {
+			param.Schema = schema
+		}
+		params = append(params, param)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("tool %s: %w", name, err)
+	}
+
+	return &Tool{
+		Name:        name,
+		Description: description,
+		Parameters:  params,
+		Handler: func(ctx context.Context, args map[string]any) (string, error) {
+			raw, err := json.Marshal(args)
+			if err != nil {
+				return "", fmt.Errorf("encode arguments for tool %s: %w", name, err)
+			}
+			target := reflect.New(structType)
+			if err := json.Unmarshal(raw, target.Interface()); err != nil {
+				return "", fmt.Errorf("decode arguments for tool %s: %w", name, err)
+			}
+			arg := target
+			if argType.Kind() != reflect.Pointer {
+				arg = target.Elem()
+			}
+			out := fn.Call([]reflect.Value{reflect.ValueOf(ctx), arg})
+			if err, _ := out[1].Interface().(error); err != nil {
+				return "", err
+			}
+			return out[0].String(), nil
+		},
+	}, nil
+}
+
+// visitFields calls visit for every exported field of t that encoding/json
+// would decode, flattening embedded structs.
+func visitFields(t reflect.Type, visit func(field reflect.StructField, jsonName string) error) error {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag := field.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		jsonName, _, _ := strings.Cut(tag, ",")
+		if field.Anonymous && jsonName == "" {
+			embedded := field.Type
+			if embedded.Kind() == reflect.Pointer {
+				embedded = embedded.Elem()
+			}
+			if embedded.Kind() == reflect.Struct {
+				if err := visitFields(embedded, visit); err != nil {
+					return err
+				}
+				continue
+			}
+		}
+		if !field.IsExported() {
+			continue
+		}
+		if jsonName == "" {
+			jsonName = field.Name
+		}
+		if err := visit(field, jsonName); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// schemaFor returns the JSON schema of t. seen holds the struct types being
+// expanded so recursive types are reported instead of looping forever.
+func schemaFor(t reflect.Type, seen map[reflect.Type]bool) (map[string]any, error) {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t == timeType {
+		return map[string]any{"type": "string", "format": "date-time"}, nil
+	}
+	switch t.Kind() {
+	case reflect.String:
+		return map[string]any{"type": "string"}, nil
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}, nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]any{"type": "integer"}, nil
+	case reflect.Float32, reflect.Float64:
+		return map[string]any{"type": "number"}, nil
+	case reflect.Slice, reflect.Array:
+		if t.Kind() == reflect.Slice && t.Elem().Kind() == reflect.Uint8 {
+			return map[string]any{"type": "string", "contentEncoding": "base64"}, nil
+		}
+		items, err := schemaFor(t.Elem(), seen)
+		if err != nil {
+			return nil, err
+		}
+		return map[string]any{"type": "array", "items": items}, nil
+	case reflect.Map:
+		if t.Key().Kind() != reflect.String {
+			return nil, fmt.Errorf("unsupported map key type %s", t.Key())
+		}
+		values, err := schemaFor(t.Elem(), seen)
+		if err != nil {
+			return nil, err
+		}
+		return map[string]any{"type": "object", "additionalProperties": values}, nil
+	case reflect.Struct:
+		if seen[t] {
+			return nil, fmt.Errorf("recursive type %s", t)
+		}
+		seen[t] = true
+		defer delete(seen, t)
+
+		properties := make(map[string]any)
+		required := make([]string, 0)
+		err := visitFields(t, func(field reflect.StructField, jsonName string) error {
+			schema, err := schemaFor(field.Type, seen)
+			if err != nil {
+				return fmt.Errorf("field %s: %w", field.Name, err)
+			}
+			if desc := field.Tag.Get("description"); desc != "" {
+				schema["description"] = desc
+			}
+			if enum := field.Tag.Get("enum"); enum != "" {
+				schema["enum"] = strings.Split(enum, ",")
+			}
+			if field.Tag.Get("required") == "true" {
+				required = append(required, jsonName)
+			}
+			properties[jsonName] = schema
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+		return map[string]any{"type": "object", "properties": properties, "required": required}, nil
+	}
+	return nil, fmt.Errorf("unsupported type %s", t)
+}
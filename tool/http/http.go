@@ -0,0 +1,305 @@
+// Package http builds tools that call REST endpoints.
+package http
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	nethttp "net/http"
+	"net/url"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sweetpotato0/ai-allin/tool"
+)
+
+// Endpoint describes the HTTP call behind a tool.
+type Endpoint struct {
+	Name        string
+	Description string
+	Method      string // Defaults to GET
+	// URL may contain {param} placeholders that are filled with the escaped
+	// argument of the same name, e.g. "https://api.example.com/users/{id}".
+	URL        string
+	Parameters []tool.Parameter
+	// Query, Header and Body name the parameters sent in the query string, as
+	// request headers and as fields of the JSON body. Parameters not used by the
+	// URL template or listed here go to the query string for GET, HEAD and
+	// DELETE requests and to the body otherwise.
+	Query  []string
+	Header []string
+	Body   []string
+	// BodyParam, when set, names a parameter whose value is sent as the whole
+	// JSON body instead of an object built from the body parameters.
+	BodyParam string
+	Headers   map[string]string // Static headers sent with every request
+}
+
+type config struct {
+	client   *nethttp.Client
+	timeout  time.Duration
+	headers  map[string]string
+	maxBytes int64
+}
+
+// Option customises the HTTP tool.
+type Option func(*config)
+
+// WithTimeout bounds each request (default 30s).
+func WithTimeout(d time.Duration) Option {
+	return func(c *config) {
+		if d > 0 {
+			c.timeout = d
+		}
+	}
+}
+
+// WithHTTPClient swaps the HTTP client (useful for proxies or custom transports).
+func WithHTTPClient(client *nethttp.Client) Option {
+	return func(c *config) {
+		if client != nil {
+			c.client = client
+		}
+	}
+}
+
+// WithHeader sets a header on every request, overriding the endpoint's static headers.
+func WithHeader(name, value string) Option {
+	return func(c *config) {
+		if name != "" {
+			c.headers[name] = value
+		}
+	}
+}
+
+// WithAuthHeader sets the header carrying credentials, e.g.
+// WithAuthHeader("X-API-Key", key).
+func WithAuthHeader(name, value string) Option {
+	return WithHeader(name, value)
+}
+
+// WithBearerToken authenticates with an "Authorization: Bearer <token>" header.
+func WithBearerToken(token string) Option {
+	return WithHeader("Authorization", "Bearer "+token)
+}
+
+// WithMaxResponseBytes truncates response bodies longer than n bytes (default 1 MiB).
+func WithMaxResponseBytes(n int64) Option {
+	return func(c *config) {
+		if n > 0 {
+			c.maxBytes = n
+		}
+	}
+}
+
+var placeholder = regexp.MustCompile(`\{([^{}]+)\}`)
+
+// New creates a tool that issues the endpoint's HTTP request with the call
+// arguments and returns the response body. Responses with a status of 400 or
+// above are reported as errors that include the body.
+func New(ep Endpoint, opts ...Option) (*tool.Tool, error) {
+	if ep.Name == "" {
+		return nil, fmt.Errorf("endpoint name cannot be empty")
+	}
+	method := strings.ToUpper(ep.Method)
+	if method == "" {
+		method = nethttp.MethodGet
+	}
+	if _, err := url.Parse(placeholder.ReplaceAllString(ep.URL, "x")); err != nil || ep.URL == "" {
+		return nil, fmt.Errorf("endpoint %s: invalid URL %q", ep.Name, ep.URL)
+	}
+	cfg := &config{
+		client:   &nethttp.Client{},
+		timeout:  30 * time.Second,
+		headers:  make(map[string]string),
+		maxBytes: 1 << 20,
+	}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+
+	caller := &caller{endpoint: ep, method: method, cfg: cfg, placement: make(map[string]string)}
+	for _, match := range placeholder.FindAllStringSubmatch(ep.URL, -1) {
+		caller.placement[match[1]] = "path"
+	}
+	for _, group := range []struct {
+		in    string
+		names []string
+	}{{"query", ep.Query}, {"header", ep.Header}, {"body", ep.Body}} {
+		for _, name := range group.names {
+			if _, taken := caller.placement[name]; !taken {
+				caller.placement[name] = group.in
+			}
+		}
+	}
+	if ep.BodyParam != "" {
+		caller.placement[ep.BodyParam] = "raw"
+	}
+	for _, param := range ep.Parameters {
+		if _, placed := caller.placement[param.Name]; !placed {
+			if hasBody(method) {
+				caller.placement[param.Name] = "body"
+			} else {
+				caller.placement[param.Name] = "query"
+			}
+		}
+	}
+
+	return &tool.Tool{
+		Name:        ep.Name,
+		Description: ep.Description,
+		Parameters:  ep.Parameters,
+		Handler:     caller.call,
+	}, nil
+}
+
+type caller struct {
+	endpoint  Endpoint
+	method    string
+	cfg       *config
+	placement map[string]string // parameter name -> path, query, header, body or raw
+}
+
+func (c *caller) call(ctx context.Context, args map[string]any) (string, error) {
+	req, err := c.request(ctx, args)
+	if err != nil {
+		return "", fmt.Errorf("tool %s: %w", c.endpoint.Name, err)
+	}
+	ctx, cancel := context.WithTimeout(req.Context(), c.cfg.timeout)
+	defer cancel()
+
+	resp, err := c.cfg.client.Do(req.WithContext(ctx))
+	if err != nil {
+		return "", fmt.Errorf("tool %s: %s %s: %w", c.endpoint.Name, c.method, req.URL.Redacted(), err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, c.cfg.maxBytes))
+	if err != nil {
+		return "", fmt.Errorf("tool %s: read response: %w", c.endpoint.Name, err)
+	}
+	if resp.StatusCode >= nethttp.StatusBadRequest {
+		return "", fmt.Errorf("tool %s: %s %s returned %s: %s", c.endpoint.Name, c.method, req.URL.Redacted(), resp.Status, strings.TrimSpace(string(body)))
+	}
+	return string(body), nil
+}
+
+// request builds the HTTP request for args.
+func (c *caller) request(ctx context.Context, args map[string]any) (*nethttp.Request, error) {
+	var missing []string
+	rawURL := placeholder.ReplaceAllStringFunc(c.endpoint.URL, func(m string) string {
+		name := m[1 : len(m)-1]
+		value, ok := args[name]
+		if !ok || value == nil {
+			missing = append(missing, name)
+			return m
+		}
+		return url.PathEscape(formatValue(value))
+	})
+	if len(missing) > 0 {
+		return nil, fmt.Errorf("missing path parameter %s", strings.Join(missing, ", "))
+	}
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("build URL: %w", err)
+	}
+
+	query := u.Query()
+	headers := make(nethttp.Header)
+	fields := make(map[string]any)
+	var raw any
+	hasRaw := false
+	for _, name := range sortedKeys(args) {
+		value := args[name]
+		if value == nil {
+			continue
+		}
+		switch c.placement[name] {
+		case "query":
+			if list, ok := value.([]any); ok {
+				for _, item := range list {
+					query.Add(name, formatValue(item))
+				}
+			} else {
+				query.Set(name, formatValue(value))
+			}
+		case "header":
+			headers.Set(name, formatValue(value))
+		case "body":
+			fields[name] = value
+		case "raw":
+			raw, hasRaw = value, true
+		}
+	}
+	u.RawQuery = query.Encode()
+
+	var body io.Reader
+	if hasRaw || len(fields) > 0 {
+		payload := any(fields)
+		if hasRaw {
+			payload = raw
+		}
+		data, err := json.Marshal(payload)
+		if err != nil {
+			return nil, fmt.Errorf("encode body: %w", err)
+		}
+		body = bytes.NewReader(data)
+	}
+
+	req, err := nethttp.NewRequestWithContext(ctx, c.method, u.String(), body)
+	if err != nil {
+		return nil, err
+	}
+	for name, value := range c.endpoint.Headers {
+		req.Header.Set(name, value)
+	}
+	for name, value := range c.cfg.headers {
+		req.Header.Set(name, value)
+	}
+	for name, values := range headers {
+		req.Header[name] = values
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if req.Header.Get("Accept") == "" {
+		req.Header.Set("Accept", "application/json")
+	}
+	return req, nil
+}
+
+func hasBody(method string) bool {
+	switch method {
+	case nethttp.MethodGet, nethttp.MethodHead, nethttp.MethodDelete, nethttp.MethodOptions:
+		return false
+	}
+	return true
+}
+
+// formatValue renders a JSON-decoded argument for a URL or header. Integral
+// numbers are written without an exponent; arrays and objects are JSON-encoded.
+func formatValue(value any) string {
+	switch v := value.(type) {
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case []any, map[string]any:
+		data, _ := json.Marshal(v)
+		return string(data)
+	}
+	return fmt.Sprint(value)
+}
+
+func sortedKeys(args map[string]any) []string {
+	keys := make([]string, 0, len(args))
+	for k := range args {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+	return keys
+}
@@ -0,0 +1,133 @@
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	nethttp "net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sweetpotato0/ai-allin/tool"
+)
+
+func TestEndpointMapsArguments(t *testing.T) {
+	var got *nethttp.Request
+	var gotBody map[string]any
+	srv := httptest.NewServer(nethttp.HandlerFunc(func(w nethttp.ResponseWriter, r *nethttp.Request) {
+		got = r
+		data, _ := io.ReadAll(r.Body)
+		_ = json.Unmarshal(data, &gotBody)
+		w.Write([]byte(`{"ok":true}`))
+	}))
+	defer srv.Close()
+
+	orderTool, err := New(Endpoint{
+		Name:   "update_order",
+		Method: "patch",
+		URL:    srv.URL + "/users/{user}/orders/{id}",
+		Parameters: []tool.Parameter{
+			{Name: "user", Type: "string", Required: true},
+			{Name: "id", Type: "integer", Required: true},
+			{Name: "notify", Type: "boolean"},
+			{Name: "tags", Type: "array"},
+			{Name: "X-Request-Id", Type: "string"},
+			{Name: "status", Type: "string"},
+			{Name: "quantity", Type: "integer"},
+		},
+		Query:   []string{"notify", "tags"},
+		Header:  []string{"X-Request-Id"},
+		Headers: map[string]string{"X-Client": "agent"},
+	}, WithBearerToken("secret"))
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+
+	result, err := orderTool.Execute(context.Background(), map[string]any{
+		"user": "a b", "id": float64(42), "notify": true, "tags": []any{"x", "y"},
+		"X-Request-Id": "req-1", "status": "shipped", "quantity": float64(3), "unknown": "dropped",
+	})
+	if err != nil {
+		t.Fatalf("Execute failed: %v", err)
+	}
+	if result != `{"ok":true}` {
+		t.Fatalf("unexpected result %q", result)
+	}
+	if got.Method != nethttp.MethodPatch || got.URL.EscapedPath() != "/users/a%20b/orders/42" {
+		t.Fatalf("unexpected request %s %s", got.Method, got.URL.EscapedPath())
+	}
+	if q := got.URL.Query(); q.Get("notify") != "true" || strings.Join(q["tags"], ",") != "x,y" || q.Has("status") {
+		t.Fatalf("unexpected query %v", q)
+	}
+	if got.Header.Get("Authorization") != "Bearer secret" || got.Header.Get("X-Request-Id") != "req-1" ||
+		got.Header.Get("X-Client") != "agent" || got.Header.Get("Content-Type") != "application/json" {
+		t.Fatalf("unexpected headers %v", got.Header)
+	}
+	if len(gotBody) != 2 || gotBody["status"] != "shipped" || gotBody["quantity"] != float64(3) {
+		t.Fatalf("unexpected body %v", gotBody)
+	}
+}
+
+func TestEndpointDefaultsAndRawBody(t *testing.T) {
+	var paths, bodies []string
+	srv := httptest.NewServer(nethttp.HandlerFunc(func(w nethttp.ResponseWriter, r *nethttp.Request) {
+		data, _ := io.ReadAll(r.Body)
+		paths = append(paths, r.Method+" "+r.URL.RequestURI())
+		bodies = append(bodies, string(data))
+	}))
+	defer srv.Close()
+
+	search, err := New(Endpoint{Name: "search", URL: srv.URL + "/search", Parameters: []tool.Parameter{{Name: "q", Type: "string"}}})
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	if _, err := search.Execute(context.Background(), map[string]any{"q": "go tools"}); err != nil {
+		t.Fatalf("Execute failed: %v", err)
+	}
+	create, err := New(Endpoint{Name: "create", Method: "POST", URL: srv.URL + "/items", BodyParam: "items",
+		Parameters: []tool.Parameter{{Name: "items", Type: "array", Required: true}}})
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	if _, err := create.Execute(context.Background(), map[string]any{"items": []any{1, 2}}); err != nil {
+		t.Fatalf("Execute failed: %v", err)
+	}
+	if strings.Join(paths, "|") != "GET /search?q=go+tools|POST /items" || bodies[0] != "" || bodies[1] != "[1,2]" {
+		t.Fatalf("unexpected requests %q with bodies %q", paths, bodies)
+	}
+}
+
+func TestEndpointErrors(t *testing.T) {
+	srv := httptest.NewServer(nethttp.HandlerFunc(func(w nethttp.ResponseWriter, r *nethttp.Request) {
+		if r.URL.Path == "/slow" {
+			select {
+			case <-r.Context().Done():
+			case <-time.After(time.Second):
+			}
+			return
+		}
+		nethttp.Error(w, "no such user", nethttp.StatusNotFound)
+	}))
+	defer srv.Close()
+
+	missing, _ := New(Endpoint{Name: "user", URL: srv.URL + "/users/{id}"})
+	if _, err := missing.Execute(context.Background(), map[string]any{"id": "7"}); err == nil ||
+		!strings.Contains(err.Error(), "404 Not Found: no such user") {
+		t.Fatalf("expected status error with body, got %v", err)
+	}
+	if _, err := missing.Execute(context.Background(), map[string]any{}); err == nil || !strings.Contains(err.Error(), "missing path parameter id") {
+		t.Fatalf("expected missing path parameter error, got %v", err)
+	}
+
+	slow, _ := New(Endpoint{Name: "slow", URL: srv.URL + "/slow"}, WithTimeout(20*time.Millisecond))
+	start := time.Now()
+	if _, err := slow.Execute(context.Background(), nil); err == nil || time.Since(start) > 500*time.Millisecond {
+		t.Fatalf("expected the request to time out quickly, got %v after %s", err, time.Since(start))
+	}
+
+	if _, err := New(Endpoint{Name: "bad", URL: "://nowhere"}); err == nil {
+		t.Fatal("expected invalid URL to be rejected")
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"io"
 	"log"
+	"net"
 	"net/http"
 	"os"
 	"os/exec"
@@ -34,6 +35,12 @@ type clientConfig struct {
 	terminateTimeout  time.Duration
 	httpClient        *http.Client
 	streamableRetries *int
+	callTimeout       time.Duration
+
+	namespaceSeparator string
+
+	reconnectAttempts int
+	reconnectBackoff  time.Duration
 }
 
 // WithClientInfo sets the client metadata advertised to the MCP server.
@@ -108,6 +115,43 @@ func WithStreamableMaxRetries(retries int) Option {
 	}
 }
 
+// WithCallTimeout bounds every tool call; a call exceeding d fails with a *TimeoutError.
+// Zero disables the per-call timeout, leaving only the caller's context.
+func WithCallTimeout(d time.Duration) Option {
+	return func(cfg *clientConfig) {
+		if d >= 0 {
+			cfg.callTimeout = d
+		}
+	}
+}
+
+// WithNamespaceSeparator overrides the separator NewMultiProvider places between
+// the server name and the tool name (default "__"). It may only contain letters,
+// digits, "_" and "-"; NewMultiProvider rejects anything else. Single-server
+// providers ignore it.
+func WithNamespaceSeparator(sep string) Option {
+	return func(cfg *clientConfig) {
+		cfg.namespaceSeparator = sep
+	}
+}
+
+// WithReconnect re-establishes a broken session with up to maxAttempts connection
+// attempts, waiting backoff before the second attempt and doubling the wait after
+// each failure (capped at 30s). A list or call that fails with a transport error
+// (a closed connection, EOF or network failure) is retried once on the new
+// session. For the command transport a reconnect respawns the server process, so
+// a crashed server is restarted. Zero attempts (the default) disables reconnection.
+func WithReconnect(maxAttempts int, backoff time.Duration) Option {
+	return func(cfg *clientConfig) {
+		if maxAttempts >= 0 {
+			cfg.reconnectAttempts = maxAttempts
+		}
+		if backoff > 0 {
+			cfg.reconnectBackoff = backoff
+		}
+	}
+}
+
 // ClientInfo describes the client metadata sent to the MCP server.
 type ClientInfo struct {
 	Name    string `json:"name"`
@@ -118,9 +162,18 @@ type ClientInfo struct {
 // Client wraps the official MCP Go SDK client and session.
 type Client struct {
 	sdkClient *sdkmcp.Client
-	session   *sdkmcp.ClientSession
+	connect   func(context.Context) (*sdkmcp.ClientSession, error)
 
-	logger *log.Logger
+	mu      sync.Mutex
+	session *sdkmcp.ClientSession
+	closed  bool
+
+	logger      *log.Logger
+	callTimeout time.Duration
+
+	reconnectMu       sync.Mutex
+	reconnectAttempts int
+	reconnectBackoff  time.Duration
 
 	toolsChanged chan struct{}
 	done         chan struct{}
@@ -141,20 +194,7 @@ func NewStdioClient(ctx context.Context, command string, opts ...Option) (*Clien
 		opt(&cfg)
 	}
 
-	cmd := exec.Command(command, cfg.args...)
-	if cfg.dir != "" {
-		cmd.Dir = cfg.dir
-	}
-	if len(cfg.env) > 0 {
-		cmd.Env = append(os.Environ(), cfg.env...)
-	}
-	cmd.Stderr = logWriter{logger: cfg.logger}
-
-	client := &Client{
-		logger:       cfg.logger,
-		toolsChanged: make(chan struct{}, 1),
-		done:         make(chan struct{}),
-	}
+	client := newClient(cfg)
 
 	clientOpts := &sdkmcp.ClientOptions{
 		ToolListChangedHandler: func(context.Context, *sdkmcp.ToolListChangedRequest) {
@@ -173,20 +213,23 @@ func NewStdioClient(ctx context.Context, command string, opts ...Option) (*Clien
 
 	client.sdkClient = sdkmcp.NewClient(&cfg.implementation, clientOpts)
 
-	transport := &sdkmcp.CommandTransport{
-		Command:           cmd,
-		TerminateDuration: cfg.terminateTimeout,
-	}
-
-	session, err := client.sdkClient.Connect(ctx, transport, nil)
-	if err != nil {
-		return nil, fmt.Errorf("mcp: connect failed: %w", err)
+	// Every connection launches a fresh process, so reconnecting respawns the server.
+	client.connect = func(ctx context.Context) (*sdkmcp.ClientSession, error) {
+		cmd := exec.Command(command, cfg.args...)
+		if cfg.dir != "" {
+			cmd.Dir = cfg.dir
+		}
+		if len(cfg.env) > 0 {
+			cmd.Env = append(os.Environ(), cfg.env...)
+		}
+		cmd.Stderr = logWriter{logger: cfg.logger}
+		return client.sdkClient.Connect(ctx, &sdkmcp.CommandTransport{
+			Command:           cmd,
+			TerminateDuration: cfg.terminateTimeout,
+		}, nil)
 	}
-	client.session = session
 
-	go client.monitorSession()
-
-	return client, nil
+	return client.start(ctx)
 }
 
 // NewStreamableClient connects to an MCP server over the streamable HTTP transport
@@ -201,11 +244,7 @@ func NewStreamableClient(ctx context.Context, endpoint string, opts ...Option) (
 		opt(&cfg)
 	}
 
-	client := &Client{
-		logger:       cfg.logger,
-		toolsChanged: make(chan struct{}, 1),
-		done:         make(chan struct{}),
-	}
+	client := newClient(cfg)
 
 	clientOpts := &sdkmcp.ClientOptions{
 		ToolListChangedHandler: func(context.Context, *sdkmcp.ToolListChangedRequest) {
@@ -224,32 +263,56 @@ func NewStreamableClient(ctx context.Context, endpoint string, opts ...Option) (
 
 	client.sdkClient = sdkmcp.NewClient(&cfg.implementation, clientOpts)
 
-	transport := &sdkmcp.StreamableClientTransport{
-		Endpoint: endpoint,
-	}
-	if cfg.httpClient != nil {
-		transport.HTTPClient = cfg.httpClient
+	// The SDK flattens HTTP failures into strings, so keep the typed error on the
+	// side for isTransportError.
+	httpClient := recordTransportErrors(cfg.httpClient)
+	client.connect = func(ctx context.Context) (*sdkmcp.ClientSession, error) {
+		transport := &sdkmcp.StreamableClientTransport{
+			Endpoint:   endpoint,
+			HTTPClient: httpClient,
+		}
+		if cfg.streamableRetries != nil {
+			transport.MaxRetries = *cfg.streamableRetries
+		}
+		return client.sdkClient.Connect(ctx, transport, nil)
 	}
-	if cfg.streamableRetries != nil {
-		transport.MaxRetries = *cfg.streamableRetries
+
+	return client.start(ctx)
+}
+
+func newClient(cfg clientConfig) *Client {
+	return &Client{
+		logger:            cfg.logger,
+		callTimeout:       cfg.callTimeout,
+		reconnectAttempts: cfg.reconnectAttempts,
+		reconnectBackoff:  cfg.reconnectBackoff,
+		toolsChanged:      make(chan struct{}, 1),
+		done:              make(chan struct{}),
 	}
+}
 
-	session, err := client.sdkClient.Connect(ctx, transport, nil)
+// start opens the first session.
+func (c *Client) start(ctx context.Context) (*Client, error) {
+	session, err := c.connect(ctx)
 	if err != nil {
 		return nil, fmt.Errorf("mcp: connect failed: %w", err)
 	}
-	client.session = session
+	c.session = session
 
-	go client.monitorSession()
+	go c.monitorSession(session)
 
-	return client, nil
+	return c, nil
 }
 
 // Close terminates the MCP client and underlying transport.
 func (c *Client) Close() error {
 	c.closeOnce.Do(func() {
-		if c.session != nil {
-			c.closeErr = c.session.Close()
+		c.mu.Lock()
+		c.closed = true
+		session := c.session
+		c.mu.Unlock()
+		if session != nil {
+			c.closeErr = session.Close()
 		}
 		close(c.done)
 	})
@@ -266,15 +329,200 @@ func (c *Client) ToolsChanged() <-chan struct{} {
 	return c.toolsChanged
 }
 
-func (c *Client) monitorSession() {
-	if c.session == nil {
-		close(c.done)
+// monitorSession watches a session until it ends. Without reconnection the
+// client shuts down with it; otherwise a replacement session is dialled right
+// away, which restarts a crashed stdio server.
+func (c *Client) monitorSession(session *sdkmcp.ClientSession) {
+	if err := session.Wait(); err != nil && !errors.Is(err, sdkmcp.ErrConnectionClosed) {
+		c.logger.Printf("mcp: session ended with error: %v", err)
+	}
+	if c.reconnectAttempts <= 0 {
+		_ = c.Close()
 		return
 	}
-	if err := c.session.Wait(); err != nil && !errors.Is(err, sdkmcp.ErrConnectionClosed) {
-		c.logger.Printf("mcp: session ended with error: %v", err)
+	if _, err := c.reconnect(context.Background(), session); err != nil && !errors.Is(err, ErrClientClosed) {
+		c.logger.Printf("mcp: %v", err)
+	}
+}
+
+// currentSession returns the live session, or nil once the client is closed.
+func (c *Client) currentSession() *sdkmcp.ClientSession {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.closed {
+		return nil
+	}
+	return c.session
+}
+
+// do runs op against the current session. When op fails with a transport error
+// and reconnection is enabled, the session is re-established and op is retried
+// once on the new session.
+func (c *Client) do(ctx context.Context, op func(context.Context, *sdkmcp.ClientSession) error) error {
+	session := c.currentSession()
+	if session == nil {
+		return ErrClientClosed
+	}
+	err := runRecorded(ctx, session, op)
+	if err == nil || c.reconnectAttempts <= 0 || ctx.Err() != nil || !isTransportError(err) {
+		return err
+	}
+	c.logger.Printf("mcp: transport error, reconnecting: %v", err)
+	session, rerr := c.reconnect(ctx, session)
+	if rerr != nil {
+		return errors.Join(err, rerr)
+	}
+	return runRecorded(ctx, session, op)
+}
+
+// maxReconnectBackoff caps the wait between reconnection attempts.
+const maxReconnectBackoff = 30 * time.Second
+
+// reconnect replaces the failed session with a new one, retrying with
+// exponential backoff. Callers that observed the same failed session share a
+// single reconnection.
+func (c *Client) reconnect(ctx context.Context, failed *sdkmcp.ClientSession) (*sdkmcp.ClientSession, error) {
+	if c.connect == nil {
+		return nil, errors.New("mcp: client cannot reconnect")
+	}
+	c.reconnectMu.Lock()
+	defer c.reconnectMu.Unlock()
+
+	c.mu.Lock()
+	current, closed := c.session, c.closed
+	c.mu.Unlock()
+	if closed {
+		return nil, ErrClientClosed
+	}
+	if current != failed {
+		return current, nil
+	}
+	_ = failed.Close()
+
+	delay := c.reconnectBackoff
+	if delay <= 0 {
+		delay = 500 * time.Millisecond
+	}
+	var lastErr error
+	for attempt := 1; attempt <= c.reconnectAttempts; attempt++ {
+		session, err := c.connect(ctx)
+		if err == nil {
+			c.mu.Lock()
+			if c.closed {
+				c.mu.Unlock()
+				_ = session.Close()
+				return nil, ErrClientClosed
+			}
+			c.session = session
+			c.mu.Unlock()
+			c.logger.Printf("mcp: reconnected after %d attempt(s)", attempt)
+
+			go c.monitorSession(session)
+			// The new server may expose a different tool set.
+			select {
+			case c.toolsChanged <- struct{}{}:
+			default:
+			}
+			return session, nil
+		}
+		lastErr = err
+		if attempt == c.reconnectAttempts {
+			break
+		}
+		timer := time.NewTimer(delay)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, fmt.Errorf("mcp: reconnect aborted: %w", ctx.Err())
+		case <-c.done:
+			timer.Stop()
+			return nil, ErrClientClosed
+		}
+		delay = min(delay*2, maxReconnectBackoff)
+	}
+	return nil, fmt.Errorf("mcp: reconnect failed after %d attempt(s): %w", c.reconnectAttempts, lastErr)
+}
+
+// isTransportError reports whether err means the connection itself broke, as
+// opposed to the server answering with a JSON-RPC error: a closed connection,
+// an unexpected EOF or a network failure.
+func isTransportError(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	if errors.Is(err, sdkmcp.ErrConnectionClosed) || errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, io.ErrClosedPipe) {
+		return true
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr)
+}
+
+// transportErrorsKey carries a *transportErrors through a call's context.
+type transportErrorsKey struct{}
+
+// transportErrors collects the HTTP failures seen while serving one call.
+type transportErrors struct {
+	mu  sync.Mutex
+	err error
+}
+
+// transportError keeps the SDK's message while also unwrapping to the
+// underlying HTTP failure it flattened.
+type transportError struct {
+	err   error
+	cause error
+}
+
+func (e *transportError) Error() string   { return e.err.Error() }
+func (e *transportError) Unwrap() []error { return []error{e.err, e.cause} }
+
+// runRecorded runs op and attaches any HTTP failure it hit to the returned error.
+func runRecorded(ctx context.Context, session *sdkmcp.ClientSession, op func(context.Context, *sdkmcp.ClientSession) error) error {
+	rec := &transportErrors{}
+	err := op(context.WithValue(ctx, transportErrorsKey{}, rec), session)
+	if err == nil {
+		return nil
+	}
+	rec.mu.Lock()
+	cause := rec.err
+	rec.mu.Unlock()
+	if cause == nil || errors.Is(err, cause) {
+		return err
+	}
+	return &transportError{err: err, cause: cause}
+}
+
+// recordingTransport notes round-trip failures in the request's call context.
+type recordingTransport struct {
+	base http.RoundTripper
+}
+
+func (t recordingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.base.RoundTrip(req)
+	if err != nil {
+		if rec, ok := req.Context().Value(transportErrorsKey{}).(*transportErrors); ok {
+			rec.mu.Lock()
+			rec.err = errors.Join(rec.err, err)
+			rec.mu.Unlock()
+		}
+	}
+	return resp, err
+}
+
+// recordTransportErrors returns a copy of client whose round-trip failures are
+// recorded for runRecorded.
+func recordTransportErrors(client *http.Client) *http.Client {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	wrapped := *client
+	base := client.Transport
+	if base == nil {
+		base = http.DefaultTransport
 	}
-	_ = c.Close()
+	wrapped.Transport = recordingTransport{base: base}
+	return &wrapped
 }
 
 func defaultConfig() clientConfig {
@@ -0,0 +1,183 @@
+package mcp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strconv"
+	"testing"
+	"time"
+
+	sdkmcp "github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+// TestMain lets the test binary double as a stdio MCP server for the respawn test.
+func TestMain(m *testing.M) {
+	if os.Getenv("MCP_TEST_STDIO_SERVER") == "1" {
+		runStdioTestServer()
+		return
+	}
+	os.Exit(m.Run())
+}
+
+func runStdioTestServer() {
+	server := sdkmcp.NewServer(&sdkmcp.Implementation{Name: "stdio", Version: "0.0.1"}, nil)
+	server.AddTool(&sdkmcp.Tool{Name: "pid", InputSchema: map[string]any{"type": "object"}},
+		func(context.Context, *sdkmcp.CallToolRequest) (*sdkmcp.CallToolResult, error) {
+			return &sdkmcp.CallToolResult{Content: []sdkmcp.Content{&sdkmcp.TextContent{Text: strconv.Itoa(os.Getpid())}}}, nil
+		})
+	server.AddTool(&sdkmcp.Tool{Name: "crash", InputSchema: map[string]any{"type": "object"}},
+		func(context.Context, *sdkmcp.CallToolRequest) (*sdkmcp.CallToolResult, error) {
+			os.Exit(3)
+			return nil, nil
+		})
+	_ = server.Run(context.Background(), &sdkmcp.StdioTransport{})
+}
+
+// serveOn starts a streamable MCP test server on addr, or on a free port when addr is empty.
+func serveOn(t *testing.T, addr string) *httptest.Server {
+	t.Helper()
+	server := sdkmcp.NewServer(&sdkmcp.Implementation{Name: "test", Version: "0.0.1"}, nil)
+	server.AddTool(&sdkmcp.Tool{Name: "ping", InputSchema: map[string]any{"type": "object"}},
+		func(context.Context, *sdkmcp.CallToolRequest) (*sdkmcp.CallToolResult, error) {
+			return &sdkmcp.CallToolResult{Content: []sdkmcp.Content{&sdkmcp.TextContent{Text: "pong"}}}, nil
+		})
+	srv := httptest.NewUnstartedServer(sdkmcp.NewStreamableHTTPHandler(func(*http.Request) *sdkmcp.Server { return server }, nil))
+	if addr != "" {
+		listener, err := net.Listen("tcp", addr)
+		if err != nil {
+			t.Fatalf("listen %s: %v", addr, err)
+		}
+		srv.Listener = listener
+	}
+	srv.Start()
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestStreamableClientReconnects(t *testing.T) {
+	ctx := context.Background()
+	srv := serveOn(t, "")
+	addr := srv.Listener.Addr().String()
+
+	client, err := NewStreamableClient(ctx, srv.URL, WithStreamableMaxRetries(0), WithReconnect(20, 10*time.Millisecond))
+	if err != nil {
+		t.Fatalf("connect: %v", err)
+	}
+	defer client.Close()
+	if got, err := client.CallTool(ctx, "ping", nil); err != nil || got != "pong" {
+		t.Fatalf("unexpected first call %q (%v)", got, err)
+	}
+
+	// Take the server down and bring it back while the client is retrying.
+	srv.CloseClientConnections()
+	srv.Close()
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		serveOn(t, addr)
+	}()
+
+	if got, err := client.CallTool(ctx, "ping", nil); err != nil || got != "pong" {
+		t.Fatalf("expected the call to succeed after reconnecting, got %q (%v)", got, err)
+	}
+	if tools, err := client.ListAllTools(ctx); err != nil || len(tools) != 1 {
+		t.Fatalf("unexpected tools after reconnecting %v (%v)", tools, err)
+	}
+	select {
+	case <-client.ToolsChanged():
+	default:
+		t.Fatal("expected a reconnect to signal a tool list change")
+	}
+}
+
+func TestStreamableClientWithoutReconnectFails(t *testing.T) {
+	ctx := context.Background()
+	srv := serveOn(t, "")
+
+	client, err := NewStreamableClient(ctx, srv.URL, WithStreamableMaxRetries(0))
+	if err != nil {
+		t.Fatalf("connect: %v", err)
+	}
+	defer client.Close()
+	srv.CloseClientConnections()
+	srv.Close()
+
+	if _, err := client.CallTool(ctx, "ping", nil); err == nil || !isTransportError(err) {
+		t.Fatalf("expected a transport error, got %v", err)
+	}
+}
+
+func TestServerErrorsAreNotTransportErrors(t *testing.T) {
+	ctx := context.Background()
+	srv := serveOn(t, "")
+	client, err := NewStreamableClient(ctx, srv.URL, WithReconnect(3, time.Millisecond))
+	if err != nil {
+		t.Fatalf("connect: %v", err)
+	}
+	defer client.Close()
+
+	session := client.currentSession()
+	_, err = client.CallTool(ctx, "missing", nil)
+	if err == nil || isTransportError(err) {
+		t.Fatalf("expected a server error for an unknown tool, got %v", err)
+	}
+	if client.currentSession() != session {
+		t.Fatal("a server error must not trigger a reconnect")
+	}
+}
+
+func TestIsTransportError(t *testing.T) {
+	cases := []struct {
+		err  error
+		want bool
+	}{
+		{fmt.Errorf("calling %q: %w", "tools/call", sdkmcp.ErrConnectionClosed), true},
+		{fmt.Errorf("reading: %w", io.EOF), true},
+		{&net.OpError{Op: "dial", Net: "tcp", Err: errors.New("connection refused")}, true},
+		{&transportError{err: errors.New("sending \"tools/call\": connection refused"), cause: &net.OpError{Op: "dial", Err: errors.New("refused")}}, true},
+		{errors.New("tool not found"), false},
+		{context.DeadlineExceeded, false},
+		{ErrClientClosed, false},
+		{nil, false},
+	}
+	for _, tc := range cases {
+		if got := isTransportError(tc.err); got != tc.want {
+			t.Errorf("isTransportError(%v) = %v, want %v", tc.err, got, tc.want)
+		}
+	}
+}
+
+func TestStdioClientRespawnsCrashedServer(t *testing.T) {
+	ctx := context.Background()
+	client, err := NewStdioClient(ctx, os.Args[0], WithCommandEnv("MCP_TEST_STDIO_SERVER=1"), WithReconnect(3, 10*time.Millisecond))
+	if err != nil {
+		t.Fatalf("connect: %v", err)
+	}
+	defer client.Close()
+
+	first, err := client.CallTool(ctx, "pid", nil)
+	if err != nil {
+		t.Fatalf("pid call failed: %v", err)
+	}
+	if _, err := client.CallTool(ctx, "crash", nil); err == nil {
+		t.Fatal("expected the crashing call to fail")
+	}
+
+	var second string
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		second, err = client.CallTool(ctx, "pid", nil)
+		if err == nil || time.Now().After(deadline) {
+			break
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	if err != nil || second == first {
+		t.Fatalf("expected a respawned server, got pid %q after %q (%v)", second, first, err)
+	}
+}
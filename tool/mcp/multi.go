@@ -0,0 +1,238 @@
+package mcp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/sweetpotato0/ai-allin/tool"
+)
+
+// DefaultNamespaceSeparator joins the server name and the remote tool name in
+// the tools exposed by a MultiProvider, e.g. "filesystem__read_file". Provider
+// tool names are restricted to letters, digits, "_" and "-", so the separator is
+// too.
+const DefaultNamespaceSeparator = "__"
+
+// MultiProvider aggregates the tools of several MCP servers behind a single
+// tool.Provider. Tool names are prefixed with the server's Config.Name so tools
+// from different servers cannot collide, and every call is routed to the server
+// that exposes the tool.
+type MultiProvider struct {
+	separator string
+	names     []string
+	providers map[string]Provider
+
+	mu       sync.Mutex
+	failures map[string]error
+
+	toolsChanged chan struct{}
+	done         chan struct{}
+	closeOnce    sync.Once
+}
+
+var _ tool.Provider = (*MultiProvider)(nil)
+
+// NewMultiProvider connects to every configured server concurrently. Each Config
+// must carry a unique Name, used as the tool namespace. Servers that fail to
+// connect are skipped and reported by Failures; an error is returned only when
+// the configuration is invalid or no server could be reached. opts apply to
+// every client.
+func NewMultiProvider(ctx context.Context, configs []Config, opts ...Option) (*MultiProvider, error) {
+	if len(configs) == 0 {
+		return nil, errors.New("mcp: at least one server config is required")
+	}
+	separator, err := multiSeparator(opts)
+	if err != nil {
+		return nil, err
+	}
+	m := &MultiProvider{
+		separator:    separator,
+		providers:    make(map[string]Provider, len(configs)),
+		failures:     make(map[string]error),
+		toolsChanged: make(chan struct{}, 1),
+		done:         make(chan struct{}),
+	}
+	seen := make(map[string]bool, len(configs))
+	for _, cfg := range configs {
+		name := strings.TrimSpace(cfg.Name)
+		if name == "" {
+			return nil, errors.New("mcp: every server config needs a name")
+		}
+		if seen[name] {
+			return nil, fmt.Errorf("mcp: duplicate server name %q", name)
+		}
+		seen[name] = true
+		m.names = append(m.names, name)
+	}
+
+	type result struct {
+		provider Provider
+		err      error
+	}
+	results := make([]result, len(configs))
+	var wg sync.WaitGroup
+	for i, cfg := range configs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			p, err := NewProvider(ctx, cfg, opts...)
+			results[i] = result{provider: p, err: err}
+		}()
+	}
+	wg.Wait()
+
+	var errs []error
+	for i, res := range results {
+		name := m.names[i]
+		if res.err != nil {
+			err := fmt.Errorf("mcp: server %s: %w", name, res.err)
+			m.failures[name] = err
+			errs = append(errs, err)
+			continue
+		}
+		m.providers[name] = res.provider
+		go m.forwardChanges(res.provider)
+	}
+	if len(m.providers) == 0 {
+		return nil, errors.Join(errs...)
+	}
+	return m, nil
+}
+
+// Tools returns the namespaced tools of every connected server. A server that
+// fails to list its tools is skipped and recorded in Failures; an error is
+// returned only when no server answered.
+func (m *MultiProvider) Tools(ctx context.Context) ([]*tool.Tool, error) {
+	var (
+		tools []*tool.Tool
+		errs  []error
+		ok    bool
+	)
+	for _, name := range m.names {
+		p, connected := m.providers[name]
+		if !connected {
+			continue
+		}
+		serverTools, err := p.Tools(ctx)
+		if err != nil {
+			err = fmt.Errorf("mcp: server %s: %w", name, err)
+			m.setFailure(name, err)
+			errs = append(errs, err)
+			continue
+		}
+		m.setFailure(name, nil)
+		ok = true
+		for _, t := range serverTools {
+			t.Name = name + m.separator + t.Name
+			tools = append(tools, t)
+		}
+	}
+	if !ok {
+		return nil, errors.Join(errs...)
+	}
+	return tools, nil
+}
+
+// Failures returns the latest connection or listing error of each failing
+// server, keyed by server name.
+func (m *MultiProvider) Failures() map[string]error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	out := make(map[string]error, len(m.failures))
+	for name, err := range m.failures {
+		out[name] = err
+	}
+	return out
+}
+
+// Servers returns the names of the connected servers in configuration order.
+func (m *MultiProvider) Servers() []string {
+	names := make([]string, 0, len(m.providers))
+	for _, name := range m.names {
+		if _, ok := m.providers[name]; ok {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// Client returns the client connected to the named server, or nil.
+func (m *MultiProvider) Client(name string) *Client {
+	if p, ok := m.providers[name]; ok {
+		return p.Client()
+	}
+	return nil
+}
+
+// ToolsChanged fires when any connected server reports a tool list change.
+func (m *MultiProvider) ToolsChanged() <-chan struct{} {
+	return m.toolsChanged
+}
+
+// Close closes every server connection.
+func (m *MultiProvider) Close() error {
+	var errs []error
+	m.closeOnce.Do(func() {
+		close(m.done)
+		for _, name := range m.names {
+			if p, ok := m.providers[name]; ok {
+				if err := p.Close(); err != nil {
+					errs = append(errs, fmt.Errorf("mcp: close server %s: %w", name, err))
+				}
+			}
+		}
+	})
+	return errors.Join(errs...)
+}
+
+func (m *MultiProvider) setFailure(name string, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if err == nil {
+		delete(m.failures, name)
+	} else {
+		m.failures[name] = err
+	}
+}
+
+// forwardChanges relays tool list notifications of one server until either
+// side shuts down.
+func (m *MultiProvider) forwardChanges(p Provider) {
+	client := p.Client()
+	for {
+		select {
+		case <-m.done:
+			return
+		case <-client.Done():
+			return
+		case <-client.ToolsChanged():
+			select {
+			case m.toolsChanged <- struct{}{}:
+			default:
+			}
+		}
+	}
+}
+
+func multiSeparator(opts []Option) (string, error) {
+	cfg := defaultConfig()
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	if cfg.namespaceSeparator == "" {
+		return DefaultNamespaceSeparator, nil
+	}
+	for _, r := range cfg.namespaceSeparator {
+		if !isToolNameRune(r) {
+			return "", fmt.Errorf("mcp: namespace separator %q may only contain letters, digits, '_' and '-'", cfg.namespaceSeparator)
+		}
+	}
+	return cfg.namespaceSeparator, nil
+}
+
+func isToolNameRune(r rune) bool {
+	return r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '_' || r == '-'
+}
@@ -0,0 +1,112 @@
+package mcp
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+
+	sdkmcp "github.com/modelcontextprotocol/go-sdk/mcp"
+	"github.com/sweetpotato0/ai-allin/tool"
+)
+
+// newTestServer serves an MCP server over streamable HTTP whose "whoami" tool
+// answers with the server name.
+func newTestServer(t *testing.T, name string) *httptest.Server {
+	t.Helper()
+	server := sdkmcp.NewServer(&sdkmcp.Implementation{Name: name, Version: "0.0.1"}, nil)
+	server.AddTool(&sdkmcp.Tool{Name: "whoami", InputSchema: map[string]any{"type": "object"}},
+		func(context.Context, *sdkmcp.CallToolRequest) (*sdkmcp.CallToolResult, error) {
+			return &sdkmcp.CallToolResult{Content: []sdkmcp.Content{&sdkmcp.TextContent{Text: name}}}, nil
+		})
+	handler := sdkmcp.NewStreamableHTTPHandler(func(*http.Request) *sdkmcp.Server { return server }, nil)
+	srv := httptest.NewServer(handler)
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestMultiProviderNamespacesAndRoutes(t *testing.T) {
+	ctx := context.Background()
+	fs := newTestServer(t, "fs")
+	web := newTestServer(t, "web")
+	down := httptest.NewServer(http.NotFoundHandler())
+	down.Close()
+
+	multi, err := NewMultiProvider(ctx, []Config{
+		{Name: "fs", Endpoint: fs.URL},
+		{Name: "down", Endpoint: down.URL},
+		{Name: "web", Endpoint: web.URL},
+	}, WithStreamableMaxRetries(0))
+	if err != nil {
+		t.Fatalf("NewMultiProvider failed: %v", err)
+	}
+	defer multi.Close()
+
+	if got := multi.Servers(); !slices.Equal(got, []string{"fs", "web"}) {
+		t.Fatalf("unexpected connected servers %v", got)
+	}
+	if failures := multi.Failures(); len(failures) != 1 || !strings.Contains(failures["down"].Error(), "server down") {
+		t.Fatalf("expected the unreachable server to be reported, got %v", failures)
+	}
+
+	tools, err := multi.Tools(ctx)
+	if err != nil {
+		t.Fatalf("Tools failed: %v", err)
+	}
+	registry := tool.NewRegistry()
+	for _, tl := range tools {
+		if err := registry.Register(tl); err != nil {
+			t.Fatalf("Register failed: %v", err)
+		}
+	}
+	for _, name := range []string{"fs", "web"} {
+		got, err := registry.Execute(ctx, name+"__whoami", nil)
+		if err != nil || got != name {
+			t.Fatalf("expected %s__whoami to reach %s, got %q (%v)", name, name, got, err)
+		}
+	}
+
+	if err := multi.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	for _, name := range []string{"fs", "web"} {
+		select {
+		case <-multi.Client(name).Done():
+		default:
+			t.Fatalf("expected client %s to be closed", name)
+		}
+	}
+}
+
+func TestMultiProviderConfigErrors(t *testing.T) {
+	ctx := context.Background()
+	srv := newTestServer(t, "only")
+
+	if _, err := NewMultiProvider(ctx, []Config{{Endpoint: srv.URL}}); err == nil {
+		t.Fatal("expected a missing name to be rejected")
+	}
+	if _, err := NewMultiProvider(ctx, []Config{{Name: "a", Endpoint: srv.URL}, {Name: "a", Endpoint: srv.URL}}); err == nil {
+		t.Fatal("expected duplicate names to be rejected")
+	}
+	if _, err := NewMultiProvider(ctx, []Config{{Name: "a", Command: "/nonexistent/mcp-server"}}); err == nil {
+		t.Fatal("expected an error when no server connects")
+	}
+
+	for _, sep := range []string{".", "/", " ", "::"} {
+		if _, err := NewMultiProvider(ctx, []Config{{Name: "only", Endpoint: srv.URL}}, WithNamespaceSeparator(sep)); err == nil {
+			t.Fatalf("expected separator %q to be rejected", sep)
+		}
+	}
+
+	multi, err := NewMultiProvider(ctx, []Config{{Name: "only", Endpoint: srv.URL}}, WithNamespaceSeparator("-"))
+	if err != nil {
+		t.Fatalf("NewMultiProvider failed: %v", err)
+	}
+	defer multi.Close()
+	tools, err := multi.Tools(ctx)
+	if err != nil || len(tools) != 1 || tools[0].Name != "only-whoami" {
+		t.Fatalf("unexpected tools %v (%v)", tools, err)
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"errors"
 	"fmt"
 	"strings"
+	"time"
 
 	"github.com/sweetpotato0/ai-allin/tool"
 )
@@ -28,6 +29,9 @@ const (
 
 // Config describes how to connect to an MCP server.
 type Config struct {
+	// Name identifies the server within a MultiProvider and prefixes its tool
+	// names. It is ignored by NewProvider.
+	Name string
 	// Transport selects how to connect to the MCP server. If empty, defaults to
 	// streamable HTTP when Endpoint is provided, otherwise command transport.
 	Transport Transport
@@ -35,6 +39,14 @@ type Config struct {
 	Endpoint string
 	// Command is required for command transport connections.
 	Command string
+	// CallTimeout bounds each tool call. Zero means calls are limited only by the
+	// caller's context. WithCallTimeout passed to NewProvider takes precedence.
+	CallTimeout time.Duration
+	// ReconnectAttempts and ReconnectBackoff enable reconnection after transport
+	// failures, see WithReconnect. For the command transport this respawns a
+	// crashed server process. WithReconnect passed to NewProvider takes precedence.
+	ReconnectAttempts int
+	ReconnectBackoff  time.Duration
 }
 
 type provider struct {
@@ -52,6 +64,13 @@ func NewProvider(ctx context.Context, cfg Config, opts ...Option) (Provider, err
 		}
 	}
 
+	if cfg.CallTimeout > 0 {
+		opts = append([]Option{WithCallTimeout(cfg.CallTimeout)}, opts...)
+	}
+	if cfg.ReconnectAttempts > 0 {
+		opts = append([]Option{WithReconnect(cfg.ReconnectAttempts, cfg.ReconnectBackoff)}, opts...)
+	}
+
 	var (
 		client *Client
 		err    error
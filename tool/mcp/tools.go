@@ -3,9 +3,11 @@ package mcp
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"sort"
 	"strings"
+	"time"
 
 	sdkmcp "github.com/modelcontextprotocol/go-sdk/mcp"
 	"github.com/sweetpotato0/ai-allin/tool"
@@ -21,66 +23,112 @@ func (e *ToolError) Error() string {
 	return fmt.Sprintf("mcp tool %s: %s", e.Name, e.Message)
 }
 
+// TimeoutError is returned when a tool call exceeds the client's call timeout.
+// It matches context.DeadlineExceeded with errors.Is.
+type TimeoutError struct {
+	Name    string
+	Timeout time.Duration
+}
+
+func (e *TimeoutError) Error() string {
+	return fmt.Sprintf("mcp tool %s: timed out after %s", e.Name, e.Timeout)
+}
+
+func (e *TimeoutError) Unwrap() error {
+	return context.DeadlineExceeded
+}
+
 // ListTools retrieves a single page of tools from the MCP server.
 func (c *Client) ListTools(ctx context.Context, cursor string) (*sdkmcp.ListToolsResult, error) {
-	if c.session == nil {
-		return nil, ErrClientClosed
-	}
 	params := &sdkmcp.ListToolsParams{}
 	if cursor != "" {
 		params.Cursor = cursor
 	}
-	return c.session.ListTools(ctx, params)
+	var res *sdkmcp.ListToolsResult
+	err := c.do(ctx, func(ctx context.Context, session *sdkmcp.ClientSession) error {
+		var err error
+		res, err = session.ListTools(ctx, params)
+		return err
+	})
+	return res, err
 }
 
 // ListAllTools returns the full set of tools exposed by the MCP server.
 func (c *Client) ListAllTools(ctx context.Context) ([]*sdkmcp.Tool, error) {
-	if c.session == nil {
-		return nil, ErrClientClosed
-	}
-
-	params := &sdkmcp.ListToolsParams{}
-	var (
-		cursor string
-		tools  []*sdkmcp.Tool
-	)
-
-	for {
-		if cursor != "" {
-			params.Cursor = cursor
-		}
-		res, err := c.session.ListTools(ctx, params)
-		if err != nil {
-			return nil, err
-		}
-		tools = append(tools, res.Tools...)
-		if res.NextCursor == "" {
-			break
+	var tools []*sdkmcp.Tool
+	err := c.do(ctx, func(ctx context.Context, session *sdkmcp.ClientSession) error {
+		// Restart pagination from scratch if the listing is retried on a new session.
+		tools = nil
+		params := &sdkmcp.ListToolsParams{}
+		for {
+			res, err := session.ListTools(ctx, params)
+			if err != nil {
+				return err
+			}
+			tools = append(tools, res.Tools...)
+			if res.NextCursor == "" {
+				return nil
+			}
+			params.Cursor = res.NextCursor
 		}
-		cursor = res.NextCursor
+	})
+	if err != nil {
+		return nil, err
 	}
-
 	return tools, nil
 }
 
 // CallTool invokes a remote MCP tool and returns the textual response.
+// The call is abandoned as soon as ctx is done or the configured call timeout
+// elapses, even if the server never answers. With reconnection enabled, a call
+// that fails because the connection broke is sent again on the new session.
 func (c *Client) CallTool(ctx context.Context, name string, args map[string]any) (string, error) {
-	if c.session == nil {
+	if c.currentSession() == nil {
 		return "", ErrClientClosed
 	}
 
+	parent := ctx
+	if c.callTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.callTimeout)
+		defer cancel()
+	}
+
 	params := &sdkmcp.CallToolParams{
 		Name:      name,
 		Arguments: args,
 	}
 
-	result, err := c.session.CallTool(ctx, params)
-	if err != nil {
-		return "", err
+	type callResult struct {
+		result *sdkmcp.CallToolResult
+		err    error
+	}
+	done := make(chan callResult, 1)
+	go func() {
+		var result *sdkmcp.CallToolResult
+		err := c.do(ctx, func(ctx context.Context, session *sdkmcp.ClientSession) error {
+			var err error
+			result, err = session.CallTool(ctx, params)
+			return err
+		})
+		done <- callResult{result: result, err: err}
+	}()
+
+	var res callResult
+	select {
+	case res = <-done:
+	case <-ctx.Done():
+		return "", c.callError(parent, ctx, name)
+	}
+	if res.err != nil {
+		if ctx.Err() != nil {
+			return "", c.callError(parent, ctx, name)
+		}
+		return "", res.err
 	}
 
-	message := normalizeContent(result.Content)
-	if result.IsError {
+	message := normalizeContent(res.result.Content)
+	if res.result.IsError {
 		if message == "" {
 			message = "tool returned error without message"
 		}
@@ -90,6 +138,15 @@ func (c *Client) CallTool(ctx context.Context, name string, args map[string]any)
 	return message, nil
 }
 
+// callError converts a finished call context into the error reported to the caller.
+// Only an expiry of the client's own call timeout yields a *TimeoutError.
+func (c *Client) callError(parent, ctx context.Context, name string) error {
+	if c.callTimeout > 0 && parent.Err() == nil && errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return &TimeoutError{Name: name, Timeout: c.callTimeout}
+	}
+	return fmt.Errorf("mcp tool %s: %w", name, ctx.Err())
+}
+
 // BuildTools converts MCP tool definitions to ai-allin tool registrations.
 func (c *Client) BuildTools(ctx context.Context) ([]*tool.Tool, error) {
 	defs, err := c.ListAllTools(ctx)
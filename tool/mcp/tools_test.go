@@ -1,8 +1,11 @@
 package mcp
 
 import (
+	"context"
+	"errors"
 	"strings"
 	"testing"
+	"time"
 
 	sdkmcp "github.com/modelcontextprotocol/go-sdk/mcp"
 )
@@ -56,3 +59,45 @@ func TestParametersFromSchema(t *testing.T) {
 		t.Fatalf("expected 'query' to be required")
 	}
 }
+
+func TestCallToolTimeout(t *testing.T) {
+	ctx := context.Background()
+	release := make(chan struct{})
+	server := sdkmcp.NewServer(&sdkmcp.Implementation{Name: "test", Version: "0.0.1"}, nil)
+	server.AddTool(&sdkmcp.Tool{Name: "hang", InputSchema: map[string]any{"type": "object"}},
+		func(ctx context.Context, _ *sdkmcp.CallToolRequest) (*sdkmcp.CallToolResult, error) {
+			<-release // simulate a server that ignores cancellation
+			return &sdkmcp.CallToolResult{}, nil
+		})
+	serverTransport, clientTransport := sdkmcp.NewInMemoryTransports()
+	serverSession, err := server.Connect(ctx, serverTransport, nil)
+	if err != nil {
+		t.Fatalf("server connect: %v", err)
+	}
+	defer serverSession.Close()
+
+	sdkClient := sdkmcp.NewClient(&sdkmcp.Implementation{Name: "client", Version: "0.0.1"}, nil)
+	session, err := sdkClient.Connect(ctx, clientTransport, nil)
+	if err != nil {
+		t.Fatalf("client connect: %v", err)
+	}
+	client := &Client{sdkClient: sdkClient, session: session, callTimeout: 50 * time.Millisecond}
+	defer session.Close()
+	defer close(release)
+
+	_, err = client.CallTool(ctx, "hang", nil)
+	var timeoutErr *TimeoutError
+	if !errors.As(err, &timeoutErr) || timeoutErr.Name != "hang" {
+		t.Fatalf("expected TimeoutError, got %v", err)
+	}
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected error to match context.DeadlineExceeded")
+	}
+
+	client.callTimeout = 0
+	cancelCtx, cancel := context.WithCancel(ctx)
+	cancel()
+	if _, err := client.CallTool(cancelCtx, "hang", nil); !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+}
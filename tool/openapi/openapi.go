@@ -0,0 +1,476 @@
+// Package openapi turns the operations of an OpenAPI 3 document into HTTP tools.
+package openapi
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/sweetpotato0/ai-allin/tool"
+	httptool "github.com/sweetpotato0/ai-allin/tool/http"
+)
+
+// Document is the subset of an OpenAPI 3 document needed to build tools.
+type Document struct {
+	OpenAPI    string              `json:"openapi" yaml:"openapi"`
+	Servers    []Server            `json:"servers" yaml:"servers"`
+	Paths      map[string]PathItem `json:"paths" yaml:"paths"`
+	Components Components          `json:"components" yaml:"components"`
+}
+
+// Server is an API base URL.
+type Server struct {
+	URL string `json:"url" yaml:"url"`
+}
+
+// PathItem holds the operations available on a path.
+type PathItem struct {
+	Parameters []Parameter `json:"parameters" yaml:"parameters"`
+	Get        *Operation  `json:"get" yaml:"get"`
+	Put        *Operation  `json:"put" yaml:"put"`
+	Post       *Operation  `json:"post" yaml:"post"`
+	Delete     *Operation  `json:"delete" yaml:"delete"`
+	Patch      *Operation  `json:"patch" yaml:"patch"`
+	Head       *Operation  `json:"head" yaml:"head"`
+	Options    *Operation  `json:"options" yaml:"options"`
+}
+
+// Operation is a single API operation.
+type Operation struct {
+	OperationID string       `json:"operationId" yaml:"operationId"`
+	Summary     string       `json:"summary" yaml:"summary"`
+	Description string       `json:"description" yaml:"description"`
+	Parameters  []Parameter  `json:"parameters" yaml:"parameters"`
+	RequestBody *RequestBody `json:"requestBody" yaml:"requestBody"`
+}
+
+// Parameter is an operation parameter, or a reference to one.
+type Parameter struct {
+	Ref         string         `json:"$ref" yaml:"$ref"`
+	Name        string         `json:"name" yaml:"name"`
+	In          string         `json:"in" yaml:"in"`
+	Description string         `json:"description" yaml:"description"`
+	Required    bool           `json:"required" yaml:"required"`
+	Schema      map[string]any `json:"schema" yaml:"schema"`
+}
+
+// RequestBody is an operation request body, or a reference to one.
+type RequestBody struct {
+	Ref         string               `json:"$ref" yaml:"$ref"`
+	Description string               `json:"description" yaml:"description"`
+	Required    bool                 `json:"required" yaml:"required"`
+	Content     map[string]MediaType `json:"content" yaml:"content"`
+}
+
+// MediaType holds the schema of one request body encoding.
+type MediaType struct {
+	Schema map[string]any `json:"schema" yaml:"schema"`
+}
+
+// Components holds the reusable objects operations refer to.
+type Components struct {
+	Schemas       map[string]map[string]any `json:"schemas" yaml:"schemas"`
+	Parameters    map[string]Parameter      `json:"parameters" yaml:"parameters"`
+	RequestBodies map[string]RequestBody    `json:"requestBodies" yaml:"requestBodies"`
+}
+
+// Parse decodes an OpenAPI 3 document in JSON or YAML.
+func Parse(data []byte) (*Document, error) {
+	var doc Document
+	var err error
+	if trimmed := bytes.TrimSpace(data); len(trimmed) > 0 && trimmed[0] == '{' {
+		err = json.Unmarshal(trimmed, &doc)
+	} else {
+		err = yaml.Unmarshal(data, &doc)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("parse OpenAPI document: %w", err)
+	}
+	if !strings.HasPrefix(doc.OpenAPI, "3.") {
+		return nil, fmt.Errorf("unsupported OpenAPI version %q", doc.OpenAPI)
+	}
+	return &doc, nil
+}
+
+type config struct {
+	baseURL     string
+	httpOptions []httptool.Option
+	include     func(name string) bool
+}
+
+// Option customises how tools are built from a document.
+type Option func(*config)
+
+// WithBaseURL overrides the server URL of the document.
+func WithBaseURL(baseURL string) Option {
+	return func(c *config) {
+		if baseURL != "" {
+			c.baseURL = baseURL
+		}
+	}
+}
+
+// WithHTTPOptions configures the HTTP calls, e.g. timeouts and auth headers.
+func WithHTTPOptions(opts ...httptool.Option) Option {
+	return func(c *config) {
+		c.httpOptions = append(c.httpOptions, opts...)
+	}
+}
+
+// WithOperations only builds tools for the named operations.
+func WithOperations(names ...string) Option {
+	return func(c *config) {
+		c.include = func(name string) bool { return slices.Contains(names, name) }
+	}
+}
+
+var methods = []string{"get", "put", "post", "delete", "patch", "head", "options"}
+
+// Tools builds one tool per operation, ordered by path and method. Tools are
+// named after the operationId, or the method and path when it is missing, with
+// characters other than letters, digits, '_' and '-' replaced by '_'. Path,
+// query and header parameters become tool parameters, as do the properties of
+// a JSON object request body; any other JSON body is passed as a "body" parameter.
+func (d *Document) Tools(opts ...Option) ([]*tool.Tool, error) {
+	cfg := &config{}
+	if len(d.Servers) > 0 {
+		cfg.baseURL = d.Servers[0].URL
+	}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+	if cfg.baseURL == "" || !strings.Contains(cfg.baseURL, "://") {
+		return nil, fmt.Errorf("OpenAPI document has no absolute server URL %q; use WithBaseURL", cfg.baseURL)
+	}
+
+	paths := make([]string, 0, len(d.Paths))
+	for path := range d.Paths {
+		paths = append(paths, path)
+	}
+	slices.Sort(paths)
+
+	var tools []*tool.Tool
+	for _, path := range paths {
+		item := d.Paths[path]
+		for _, method := range methods {
+			op := item.operation(method)
+			if op == nil {
+				continue
+			}
+			ep, err := d.endpoint(cfg.baseURL, path, method, item.Parameters, op)
+			if err != nil {
+				return nil, fmt.Errorf("%s %s: %w", strings.ToUpper(method), path, err)
+			}
+			if cfg.include != nil && !cfg.include(ep.Name) {
+				continue
+			}
+			t, err := httptool.New(ep, cfg.httpOptions...)
+			if err != nil {
+				return nil, err
+			}
+			tools = append(tools, t)
+		}
+	}
+	return tools, nil
+}
+
+// Register parses data and registers a tool for each of its operations.
+func Register(registry *tool.Registry, data []byte, opts ...Option) error {
+	doc, err := Parse(data)
+	if err != nil {
+		return err
+	}
+	tools, err := doc.Tools(opts...)
+	if err != nil {
+		return err
+	}
+	for _, t := range tools {
+		if err := registry.Register(t); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Provider exposes the tools of a document through tool.Provider, so they can
+// be attached with agent.WithToolProvider.
+type Provider struct {
+	tools []*tool.Tool
+}
+
+var _ tool.Provider = (*Provider)(nil)
+
+// NewProvider parses data and builds its tools.
+func NewProvider(data []byte, opts ...Option) (*Provider, error) {
+	doc, err := Parse(data)
+	if err != nil {
+		return nil, err
+	}
+	tools, err := doc.Tools(opts...)
+	if err != nil {
+		return nil, err
+	}
+	return &Provider{tools: tools}, nil
+}
+
+// Tools returns the document's tools.
+func (p *Provider) Tools(ctx context.Context) ([]*tool.Tool, error) {
+	return slices.Clone(p.tools), nil
+}
+
+// Close is a no-op; the tools hold no connections.
+func (p *Provider) Close() error { return nil }
+
+// ToolsChanged returns nil because the tool set never changes.
+func (p *Provider) ToolsChanged() <-chan struct{} { return nil }
+
+func (item PathItem) operation(method string) *Operation {
+	switch method {
+	case "get":
+		return item.Get
+	case "put":
+		return item.Put
+	case "post":
+		return item.Post
+	case "delete":
+		return item.Delete
+	case "patch":
+		return item.Patch
+	case "head":
+		return item.Head
+	case "options":
+		return item.Options
+	}
+	return nil
+}
+
+var invalidNameChars = regexp.MustCompile(`[^a-zA-Z0-9_-]+`)
+
+// endpoint maps an operation to an HTTP endpoint.
+func (d *Document) endpoint(baseURL, path, method string, shared []Parameter, op *Operation) (httptool.Endpoint, error) {
+	name := op.OperationID
+	if name == "" {
+		name = method + " " + path
+	}
+	name = strings.Trim(invalidNameChars.ReplaceAllString(name, "_"), "_")
+	if len(name) > 64 {
+		name = name[:64]
+	}
+	ep := httptool.Endpoint{
+		Name:        name,
+		Description: strings.TrimSpace(cmp.Or(op.Summary, op.Description)),
+		Method:      strings.ToUpper(method),
+		URL:         strings.TrimRight(baseURL, "/") + path,
+	}
+	if op.Summary != "" && op.Description != "" && op.Description != op.Summary {
+		ep.Description = strings.TrimSpace(op.Summary + "\n\n" + op.Description)
+	}
+
+	// Operation parameters override path-level ones with the same name and location.
+	params := make([]Parameter, 0, len(shared)+len(op.Parameters))
+	for _, raw := range append(slices.Clone(shared), op.Parameters...) {
+		p, err := d.resolveParameter(raw)
+		if err != nil {
+			return ep, err
+		}
+		if i := slices.IndexFunc(params, func(q Parameter) bool { return q.Name == p.Name && q.In == p.In }); i >= 0 {
+			params[i] = p
+			continue
+		}
+		params = append(params, p)
+	}
+	for _, p := range params {
+		switch p.In {
+		case "path":
+			p.Required = true
+		case "query":
+			ep.Query = append(ep.Query, p.Name)
+		case "header":
+			ep.Header = append(ep.Header, p.Name)
+		default:
+			continue
+		}
+		schema, err := d.resolveSchema(p.Schema, 0)
+		if err != nil {
+			return ep, err
+		}
+		ep.Parameters = append(ep.Parameters, toolParameter(p.Name, p.Description, p.Required, schema))
+	}
+
+	if op.RequestBody != nil {
+		if err := d.addBody(&ep, op.RequestBody); err != nil {
+			return ep, err
+		}
+	}
+	return ep, nil
+}
+
+// addBody maps a JSON request body onto tool parameters.
+func (d *Document) addBody(ep *httptool.Endpoint, body *RequestBody) error {
+	if body.Ref != "" {
+		resolved, ok := d.Components.RequestBodies[strings.TrimPrefix(body.Ref, "#/components/requestBodies/")]
+		if !ok || !strings.HasPrefix(body.Ref, "#/components/requestBodies/") {
+			return fmt.Errorf("unresolved reference %s", body.Ref)
+		}
+		body = &resolved
+	}
+	media, ok := body.Content["application/json"]
+	if !ok {
+		for contentType, m := range body.Content {
+			if strings.HasSuffix(contentType, "+json") {
+				media, ok = m, true
+				break
+			}
+		}
+	}
+	if !ok || media.Schema == nil {
+		return nil
+	}
+	schema, err := d.resolveSchema(media.Schema, 0)
+	if err != nil {
+		return err
+	}
+
+	properties, isObject := schema["properties"].(map[string]any)
+	if !isObject || len(properties) == 0 {
+		ep.BodyParam = "body"
+		ep.Parameters = append(ep.Parameters, toolParameter("body", cmp.Or(body.Description, "Request body"), body.Required, schema))
+		return nil
+	}
+	required := stringList(schema["required"])
+	names := make([]string, 0, len(properties))
+	for name := range properties {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	for _, name := range names {
+		if slices.ContainsFunc(ep.Parameters, func(p tool.Parameter) bool { return p.Name == name }) {
+			continue
+		}
+		prop, _ := properties[name].(map[string]any)
+		desc, _ := prop["description"].(string)
+		ep.Body = append(ep.Body, name)
+		ep.Parameters = append(ep.Parameters, toolParameter(name, desc, body.Required && slices.Contains(required, name), prop))
+	}
+	return nil
+}
+
+func (d *Document) resolveParameter(p Parameter) (Parameter, error) {
+	if p.Ref == "" {
+		return p, nil
+	}
+	const prefix = "#/components/parameters/"
+	resolved, ok := d.Components.Parameters[strings.TrimPrefix(p.Ref, prefix)]
+	if !ok || !strings.HasPrefix(p.Ref, prefix) {
+		return p, fmt.Errorf("unresolved reference %s", p.Ref)
+	}
+	return resolved, nil
+}
+
+// maxRefDepth bounds $ref expansion so recursive schemas terminate.
+const maxRefDepth = 8
+
+// resolveSchema returns a copy of schema with "#/components/schemas/" references inlined.
+// References nested deeper than maxRefDepth are replaced by an untyped schema.
+func (d *Document) resolveSchema(schema map[string]any, depth int) (map[string]any, error) {
+	if schema == nil {
+		return nil, nil
+	}
+	if ref, ok := schema["$ref"].(string); ok {
+		const prefix = "#/components/schemas/"
+		target, found := d.Components.Schemas[strings.TrimPrefix(ref, prefix)]
+		if !found || !strings.HasPrefix(ref, prefix) {
+			return nil, fmt.Errorf("unresolved reference %s", ref)
+		}
+		if depth >= maxRefDepth {
+			return map[string]any{}, nil
+		}
+		return d.resolveSchema(target, depth+1)
+	}
+	out := make(map[string]any, len(schema))
+	for key, value := range schema {
+		resolved, err := d.resolveValue(value, depth)
+		if err != nil {
+			return nil, err
+		}
+		out[key] = resolved
+	}
+	return out, nil
+}
+
+func (d *Document) resolveValue(value any, depth int) (any, error) {
+	switch v := value.(type) {
+	case map[string]any:
+		return d.resolveSchema(v, depth)
+	case []any:
+		out := make([]any, len(v))
+		for i, item := range v {
+			resolved, err := d.resolveValue(item, depth)
+			if err != nil {
+				return nil, err
+			}
+			out[i] = resolved
+		}
+		return out, nil
+	}
+	return value, nil
+}
+
+// toolParameter converts a parameter schema to a tool parameter. Object and
+// array schemas are kept whole so the model sees their structure.
+func toolParameter(name, description string, required bool, schema map[string]any) tool.Parameter {
+	param := tool.Parameter{Name: name, Description: description, Required: required, Type: schemaType(schema)}
+	if param.Description == "" {
+		param.Description, _ = schema["description"].(string)
+	}
+	if enum, ok := schema["enum"].([]any); ok {
+		for _, value := range enum {
+			param.Enum = append(param.Enum, fmt.Sprint(value))
+		}
+	}
+	param.Default = schema["default"]
+	if param.Type == "object" || param.Type == "array" {
+		param.Schema = schema
+	}
+	return param
+}
+
+// schemaType returns the JSON type of schema, picking the first non-null type of
+// an OpenAPI 3.1 type list and defaulting to "string".
+func schemaType(schema map[string]any) string {
+	switch t := schema["type"].(type) {
+	case string:
+		return t
+	case []any:
+		for _, item := range t {
+			if s, ok := item.(string); ok && s != "null" {
+				return s
+			}
+		}
+	}
+	if _, ok := schema["properties"]; ok {
+		return "object"
+	}
+	if _, ok := schema["items"]; ok {
+		return "array"
+	}
+	return "string"
+}
+
+func stringList(value any) []string {
+	list, _ := value.([]any)
+	out := make([]string, 0, len(list))
+	for _, item := range list {
+		if s, ok := item.(string); ok {
+			out = append(out, s)
+		}
+	}
+	return out
+}
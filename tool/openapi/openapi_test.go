@@ -0,0 +1,218 @@
+package openapi
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/sweetpotato0/ai-allin/tool"
+	httptool "github.com/sweetpotato0/ai-allin/tool/http"
+)
+
+const petstore = `
+openapi: 3.0.3
+servers:
+  - url: https://petstore.example.com/v1
+paths:
+  /pets:
+    get:
+      operationId: listPets
+      summary: List pets
+      parameters:
+        - $ref: '#/components/parameters/Limit'
+        - name: status
+          in: query
+          schema:
+            type: string
+            enum: [available, sold]
+    post:
+      operationId: createPet
+      summary: Create a pet
+      requestBody:
+        required: true
+        content:
+          application/json:
+            schema:
+              $ref: '#/components/schemas/NewPet'
+      responses:
+        '201':
+          description: Created
+  /pets/{petId}:
+    parameters:
+      - name: petId
+        in: path
+        description: Pet identifier
+        schema:
+          type: integer
+    get:
+      summary: Get a pet
+      parameters:
+        - name: X-Trace
+          in: header
+          schema:
+            type: string
+        - name: session
+          in: cookie
+          schema:
+            type: string
+components:
+  parameters:
+    Limit:
+      name: limit
+      in: query
+      description: Page size
+      schema:
+        type: integer
+        default: 20
+  schemas:
+    NewPet:
+      type: object
+      required: [name]
+      properties:
+        name:
+          type: string
+          description: Pet name
+        tags:
+          type: array
+          items:
+            $ref: '#/components/schemas/Tag'
+    Tag:
+      type: object
+      properties:
+        label:
+          type: string
+`
+
+func toolsByName(t *testing.T, tools []*tool.Tool) map[string]*tool.Tool {
+	t.Helper()
+	out := make(map[string]*tool.Tool, len(tools))
+	for _, tl := range tools {
+		out[tl.Name] = tl
+	}
+	return out
+}
+
+func param(tl *tool.Tool, name string) tool.Parameter {
+	for _, p := range tl.Parameters {
+		if p.Name == name {
+			return p
+		}
+	}
+	return tool.Parameter{}
+}
+
+func TestToolsFromDocument(t *testing.T) {
+	doc, err := Parse([]byte(petstore))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	tools, err := doc.Tools()
+	if err != nil {
+		t.Fatalf("Tools failed: %v", err)
+	}
+	byName := toolsByName(t, tools)
+	if len(tools) != 3 || byName["listPets"] == nil || byName["createPet"] == nil || byName["get_pets_petId"] == nil {
+		t.Fatalf("unexpected tools %v", byName)
+	}
+
+	list := byName["listPets"]
+	if limit := param(list, "limit"); limit.Type != "integer" || limit.Description != "Page size" || limit.Default != 20 {
+		t.Errorf("unexpected limit parameter %+v", limit)
+	}
+	if status := param(list, "status"); strings.Join(status.Enum, ",") != "available,sold" || status.Required {
+		t.Errorf("unexpected status parameter %+v", status)
+	}
+
+	create := byName["createPet"]
+	if name := param(create, "name"); !name.Required || name.Type != "string" || name.Description != "Pet name" {
+		t.Errorf("unexpected name parameter %+v", name)
+	}
+	tags := param(create, "tags")
+	items := tags.Schema["items"].(map[string]any)
+	if tags.Type != "array" || items["properties"].(map[string]any)["label"] == nil {
+		t.Errorf("expected the tag reference to be inlined, got %+v", tags)
+	}
+
+	get := byName["get_pets_petId"]
+	if id := param(get, "petId"); !id.Required || id.Type != "integer" || id.Description != "Pet identifier" {
+		t.Errorf("unexpected petId parameter %+v", id)
+	}
+	if len(get.Parameters) != 2 || param(get, "X-Trace").Name == "" || get.Description != "Get a pet" {
+		t.Errorf("expected path and header parameters only, got %+v", get.Parameters)
+	}
+}
+
+func TestToolsCallServer(t *testing.T) {
+	var requests []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		requests = append(requests, r.Method+" "+r.URL.RequestURI()+" "+r.Header.Get("X-Api-Key")+" "+string(body))
+		w.Write([]byte(`{"id":1}`))
+	}))
+	defer srv.Close()
+
+	provider, err := NewProvider([]byte(petstore), WithBaseURL(srv.URL+"/v1"),
+		WithHTTPOptions(httptool.WithAuthHeader("X-API-Key", "k")))
+	if err != nil {
+		t.Fatalf("NewProvider failed: %v", err)
+	}
+	tools, _ := provider.Tools(context.Background())
+	byName := toolsByName(t, tools)
+
+	if _, err := byName["createPet"].Execute(context.Background(), map[string]any{"name": "Rex", "tags": []any{map[string]any{"label": "dog"}}}); err != nil {
+		t.Fatalf("createPet failed: %v", err)
+	}
+	if _, err := byName["get_pets_petId"].Execute(context.Background(), map[string]any{"petId": float64(7)}); err != nil {
+		t.Fatalf("get pet failed: %v", err)
+	}
+	want := []string{
+		`POST /v1/pets k {"name":"Rex","tags":[{"label":"dog"}]}`,
+		"GET /v1/pets/7 k ",
+	}
+	if strings.Join(requests, "\n") != strings.Join(want, "\n") {
+		t.Fatalf("unexpected requests:\n%s", strings.Join(requests, "\n"))
+	}
+}
+
+func TestRegisterAndErrors(t *testing.T) {
+	registry := tool.NewRegistry()
+	if err := Register(registry, []byte(petstore), WithOperations("listPets")); err != nil {
+		t.Fatalf("Register failed: %v", err)
+	}
+	if len(registry.List()) != 1 {
+		t.Fatalf("expected only listPets, got %d tools", len(registry.List()))
+	}
+
+	// JSON documents parse too, and a non-object body becomes a single "body" parameter.
+	spec, _ := json.Marshal(map[string]any{
+		"openapi": "3.1.0",
+		"paths": map[string]any{"/batch": map[string]any{"put": map[string]any{
+			"requestBody": map[string]any{"content": map[string]any{"application/json": map[string]any{
+				"schema": map[string]any{"type": "array", "items": map[string]any{"type": "string"}},
+			}}},
+		}}},
+	})
+	doc, err := Parse(spec)
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	if _, err := doc.Tools(); err == nil {
+		t.Fatal("expected an error without a server URL")
+	}
+	tools, err := doc.Tools(WithBaseURL("https://api.example.com"))
+	if err != nil || len(tools) != 1 || tools[0].Name != "put_batch" || param(tools[0], "body").Type != "array" {
+		t.Fatalf("unexpected tools %+v (%v)", tools, err)
+	}
+
+	if _, err := Parse([]byte(`swagger: "2.0"`)); err == nil {
+		t.Fatal("expected Swagger 2 to be rejected")
+	}
+	broken := strings.Replace(petstore, "#/components/schemas/NewPet", "#/components/schemas/Missing", 1)
+	if _, err := NewProvider([]byte(broken)); err == nil || !strings.Contains(err.Error(), "unresolved reference") {
+		t.Fatalf("expected unresolved reference error, got %v", err)
+	}
+}
@@ -3,8 +3,14 @@ package tool
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"math"
+	"reflect"
+	"slices"
+	"strings"
 	"sync"
+	"time"
 )
 
 // Parameter defines a tool parameter
@@ -15,6 +21,11 @@ type Parameter struct {
 	Required    bool     `json:"required"`
 	Enum        []string `json:"enum,omitempty"`
 	Default     any      `json:"default,omitempty"`
+
+	// Schema, when set, is the full JSON schema of the parameter, describing
+	// array items and object properties. Description, Enum and Default are
+	// still applied on top of it.
+	Schema map[string]any `json:"schema,omitempty"`
 }
 
 // Tool represents a callable tool/function
@@ -23,44 +34,159 @@ type Tool struct {
 	Description string                                                `json:"description"`
 	Parameters  []Parameter                                           `json:"parameters"`
 	Handler     func(context.Context, map[string]any) (string, error) `json:"-"`
+
+	// Timeout bounds a single execution; zero falls back to the registry default.
+	Timeout time.Duration `json:"-"`
 }
 
+// ErrTimeout is returned when a tool runs longer than its timeout.
+var ErrTimeout = errors.New("tool execution timed out")
+
 // Execute runs the tool with given arguments
 func (t *Tool) Execute(ctx context.Context, args map[string]any) (string, error) {
+	return t.execute(ctx, args, true, t.Timeout)
+}
+
+// execute validates args and runs the handler. When ctx can be cancelled or a
+// timeout applies, the handler runs in its own goroutine so that Execute returns
+// as soon as ctx is done or the timeout expires; the handler receives the
+// derived context and should stop early, otherwise it finishes in the background
+// and its result is discarded.
+func (t *Tool) execute(ctx context.Context, args map[string]any, validate bool, timeout time.Duration) (string, error) {
 	if t.Handler == nil {
 		return "", fmt.Errorf("tool %s has no handler", t.Name)
 	}
 
-	// Validate required parameters
-	if err := t.ValidateArgs(args); err != nil {
-		return "", fmt.Errorf("invalid arguments: %w", err)
+	if validate {
+		if err := t.ValidateArgs(args); err != nil {
+			return "", fmt.Errorf("invalid arguments: %w", err)
+		}
+	}
+
+	parent := ctx
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	if ctx.Done() == nil {
+		return t.Handler(ctx, args)
 	}
 
-	return t.Handler(ctx, args)
+	type outcome struct {
+		result string
+		err    error
+	}
+	done := make(chan outcome, 1)
+	go func() {
+		defer func() {
+			if r := recover(); r != nil {
+				done <- outcome{err: fmt.Errorf("tool %s panicked: %v", t.Name, r)}
+			}
+		}()
+		result, err := t.Handler(ctx, args)
+		done <- outcome{result: result, err: err}
+	}()
+
+	select {
+	case out := <-done:
+		return out.result, out.err
+	case <-ctx.Done():
+		if err := parent.Err(); err != nil {
+			return "", fmt.Errorf("tool %s cancelled: %w", t.Name, err)
+		}
+		return "", fmt.Errorf("%w: tool %s exceeded %s", ErrTimeout, t.Name, timeout)
+	}
 }
 
-// ValidateArgs validates the provided arguments against the tool's parameters
+// ValidateArgs validates the provided arguments against the tool's parameters:
+// required parameters must be present and non-null, values must match the
+// declared type and, when an enum is declared, be one of its values. All
+// problems are reported together.
 func (t *Tool) ValidateArgs(args map[string]any) error {
+	var errs []error
 	for _, param := range t.Parameters {
-		if param.Required {
-			if _, ok := args[param.Name]; !ok {
-				return fmt.Errorf("missing required parameter: %s", param.Name)
+		value, ok := args[param.Name]
+		if !ok || value == nil {
+			if param.Required {
+				errs = append(errs, fmt.Errorf("missing required parameter: %s", param.Name))
 			}
+			continue
+		}
+		if err := checkType(param.Type, value); err != nil {
+			errs = append(errs, fmt.Errorf("parameter %s %w", param.Name, err))
+			continue
+		}
+		if len(param.Enum) > 0 && !slices.Contains(param.Enum, fmt.Sprint(value)) {
+			errs = append(errs, fmt.Errorf("parameter %s must be one of %s, got %v", param.Name, strings.Join(param.Enum, ", "), value))
 		}
 	}
+	return errors.Join(errs...)
+}
+
+// checkType reports whether value, as decoded from JSON, matches the JSON schema
+// type name. Unknown type names accept any value.
+func checkType(typ string, value any) error {
+	var ok bool
+	switch typ {
+	case "string":
+		_, ok = value.(string)
+	case "boolean":
+		_, ok = value.(bool)
+	case "number":
+		_, ok = toFloat(value)
+	case "integer":
+		f, isNumber := toFloat(value)
+		ok = isNumber && f == math.Trunc(f) && !math.IsInf(f, 0)
+	case "array":
+		kind := reflect.ValueOf(value).Kind()
+		ok = kind == reflect.Slice || kind == reflect.Array
+	case "object":
+		kind := reflect.ValueOf(value).Kind()
+		ok = kind == reflect.Map || kind == reflect.Struct
+	default:
+		return nil
+	}
+	if !ok {
+		return fmt.Errorf("must be of type %s, got %T", typ, value)
+	}
 	return nil
 }
 
+func toFloat(value any) (float64, bool) {
+	switch v := value.(type) {
+	case float64:
+		return v, true
+	case float32:
+		return float64(v), true
+	case json.Number:
+		f, err := v.Float64()
+		return f, err == nil
+	}
+	rv := reflect.ValueOf(value)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(rv.Int()), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(rv.Uint()), true
+	}
+	return 0, false
+}
+
 // ToJSONSchema returns the tool definition in JSON schema format for LLM
 func (t *Tool) ToJSONSchema() map[string]any {
 	properties := make(map[string]any)
 	required := make([]string, 0)
 
 	for _, param := range t.Parameters {
-		prop := map[string]any{
-			"type":        param.Type,
-			"description": param.Description,
+		prop := make(map[string]any, len(param.Schema)+2)
+		for k, v := range param.Schema {
+			prop[k] = v
+		}
+		if _, ok := prop["type"]; !ok || param.Type != "" {
+			prop["type"] = param.Type
 		}
+		prop["description"] = param.Description
 		if len(param.Enum) > 0 {
 			prop["enum"] = param.Enum
 		}
@@ -93,6 +219,9 @@ func (t *Tool) ToJSONSchema() map[string]any {
 type Registry struct {
 	mu    sync.RWMutex // Protects tools map
 	tools map[string]*Tool
+
+	skipValidation bool          // Set by SetArgValidation(false)
+	defaultTimeout time.Duration // Applies to tools without their own Timeout
 }
 
 // NewRegistry creates a new tool registry
@@ -169,13 +298,69 @@ func (r *Registry) ToJSONSchemas() []map[string]any {
 	return schemas
 }
 
-// Execute runs a tool by name with given arguments
+// ToJSONSchemasFor returns the named tools in JSON schema format, in the given
+// order. Unknown and repeated names are skipped.
+func (r *Registry) ToJSONSchemasFor(names ...string) []map[string]any {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	schemas := make([]map[string]any, 0, len(names))
+	seen := make(map[string]struct{}, len(names))
+	for _, name := range names {
+		tool, ok := r.tools[name]
+		if _, dup := seen[name]; !ok || dup {
+			continue
+		}
+		seen[name] = struct{}{}
+		schemas = append(schemas, tool.ToJSONSchema())
+	}
+	return schemas
+}
+
+// SetArgValidation controls whether Execute validates arguments against the
+// tool parameters before calling the handler. It is enabled by default; disable
+// it when handlers inspect the raw arguments themselves.
+func (r *Registry) SetArgValidation(enabled bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.skipValidation = !enabled
+}
+
+// ArgValidation reports whether Execute validates arguments.
+func (r *Registry) ArgValidation() bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return !r.skipValidation
+}
+
+// SetDefaultTimeout bounds executions of tools that have no Timeout of their
+// own. Zero, the default, lets them run until ctx is done.
+func (r *Registry) SetDefaultTimeout(d time.Duration) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.defaultTimeout = max(d, 0)
+}
+
+// DefaultTimeout returns the timeout applied to tools without their own.
+func (r *Registry) DefaultTimeout() time.Duration {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.defaultTimeout
+}
+
+// Execute runs a tool by name with given arguments. The handler runs under the
+// tool's Timeout, or the registry default, and Execute returns an error wrapping
+// ErrTimeout when it expires or the context error when ctx is cancelled first.
 func (r *Registry) Execute(ctx context.Context, name string, args map[string]any) (string, error) {
 	tool, err := r.Get(name)
 	if err != nil {
 		return "", err
 	}
-	return tool.Execute(ctx, args)
+	timeout := tool.Timeout
+	if timeout <= 0 {
+		timeout = r.DefaultTimeout()
+	}
+	return tool.execute(ctx, args, r.ArgValidation(), timeout)
 }
 
 // MarshalJSON customizes JSON marshaling for Registry
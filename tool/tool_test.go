@@ -2,7 +2,14 @@ package tool
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
 	"testing"
+	"time"
+
+	"github.com/sweetpotato0/ai-allin/message"
 )
 
 func TestToolExecution(t *testing.T) {
@@ -56,6 +63,145 @@ func TestToolValidation(t *testing.T) {
 	}
 }
 
+func TestToolTypeAndEnumValidation(t *testing.T) {
+	calc := &Tool{
+		Name: "calculator",
+		Parameters: []Parameter{
+			{Name: "operation", Type: "string", Required: true, Enum: []string{"add", "subtract"}},
+			{Name: "a", Type: "number", Required: true},
+			{Name: "times", Type: "integer"},
+			{Name: "tags", Type: "array"},
+			{Name: "options", Type: "object"},
+			{Name: "verbose", Type: "boolean"},
+		},
+		Handler: func(ctx context.Context, args map[string]any) (string, error) {
+			return fmt.Sprint(args["a"].(float64)), nil
+		},
+	}
+
+	valid := map[string]any{
+		"operation": "add", "a": 1.5, "times": float64(2), "tags": []any{"x"},
+		"options": map[string]any{"k": "v"}, "verbose": true,
+	}
+	if err := calc.ValidateArgs(valid); err != nil {
+		t.Fatalf("expected valid arguments, got %v", err)
+	}
+	if err := calc.ValidateArgs(map[string]any{"operation": "add", "a": 1, "times": nil}); err != nil {
+		t.Fatalf("expected Go integers and null optionals to pass, got %v", err)
+	}
+
+	err := calc.ValidateArgs(map[string]any{
+		"operation": "pow", "a": "1", "times": 2.5, "tags": "x", "options": []any{}, "verbose": "yes",
+	})
+	if err == nil {
+		t.Fatal("expected validation errors")
+	}
+	for _, want := range []string{
+		"operation must be one of add, subtract, got pow",
+		"a must be of type number, got string",
+		"times must be of type integer",
+		"tags must be of type array",
+		"options must be of type object",
+		"verbose must be of type boolean",
+	} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("expected %q in %v", want, err)
+		}
+	}
+
+	// The handler is not reached with mistyped arguments.
+	if _, err := calc.Execute(context.Background(), map[string]any{"operation": "add", "a": "1"}); err == nil {
+		t.Error("expected Execute to reject mistyped arguments")
+	}
+}
+
+func TestRegistryArgValidationOptOut(t *testing.T) {
+	registry := NewRegistry()
+	raw := &Tool{
+		Name:       "raw",
+		Parameters: []Parameter{{Name: "count", Type: "integer", Required: true}},
+		Handler: func(ctx context.Context, args map[string]any) (string, error) {
+			return fmt.Sprint(args["count"]), nil
+		},
+	}
+	if err := registry.Register(raw); err != nil {
+		t.Fatalf("register: %v", err)
+	}
+
+	if _, err := registry.Execute(context.Background(), "raw", map[string]any{"count": "3"}); err == nil || !registry.ArgValidation() {
+		t.Fatalf("expected validation by default, got %v", err)
+	}
+	registry.SetArgValidation(false)
+	result, err := registry.Execute(context.Background(), "raw", map[string]any{"count": "3"})
+	if err != nil || result != "3" {
+		t.Fatalf("expected raw arguments to reach the handler, got %q (%v)", result, err)
+	}
+}
+
+func TestRegistryExecuteTimeout(t *testing.T) {
+	stopped := make(chan error, 3)
+	slow := func(ctx context.Context, args map[string]any) (string, error) {
+		select {
+		case <-ctx.Done():
+			stopped <- ctx.Err()
+			return "", ctx.Err()
+		case <-time.After(5 * time.Second):
+			return "finished", nil
+		}
+	}
+	registry := NewRegistry()
+	_ = registry.Register(&Tool{Name: "own", Handler: slow, Timeout: 20 * time.Millisecond})
+	_ = registry.Register(&Tool{Name: "default", Handler: slow})
+	_ = registry.Register(&Tool{Name: "fast", Handler: func(ctx context.Context, args map[string]any) (string, error) {
+		return "done", nil
+	}})
+	registry.SetDefaultTimeout(30 * time.Millisecond)
+
+	for _, name := range []string{"own", "default"} {
+		start := time.Now()
+		_, err := registry.Execute(context.Background(), name, nil)
+		if !errors.Is(err, ErrTimeout) || time.Since(start) > time.Second {
+			t.Fatalf("%s: expected a timeout error, got %v after %s", name, err, time.Since(start))
+		}
+		if err := <-stopped; !errors.Is(err, context.DeadlineExceeded) {
+			t.Fatalf("%s: expected the handler to observe the deadline, got %v", name, err)
+		}
+	}
+	if result, err := registry.Execute(context.Background(), "fast", nil); err != nil || result != "done" {
+		t.Fatalf("unexpected fast result %q (%v)", result, err)
+	}
+
+	// Cancelling the parent context reaches in-flight handlers without a timeout.
+	registry.SetDefaultTimeout(0)
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(20*time.Millisecond, cancel)
+	if _, err := registry.Execute(ctx, "default", nil); !errors.Is(err, context.Canceled) || errors.Is(err, ErrTimeout) {
+		t.Fatalf("expected cancellation error, got %v", err)
+	}
+	if err := <-stopped; !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected the handler to observe cancellation, got %v", err)
+	}
+}
+
+func TestExecuteReturnsWhenHandlerIgnoresContext(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+	stuck := &Tool{Name: "stuck", Timeout: 20 * time.Millisecond, Handler: func(ctx context.Context, args map[string]any) (string, error) {
+		<-release
+		return "late", nil
+	}}
+	if _, err := stuck.Execute(context.Background(), nil); !errors.Is(err, ErrTimeout) {
+		t.Fatalf("expected a timeout error, got %v", err)
+	}
+
+	panicky := &Tool{Name: "panicky", Timeout: time.Second, Handler: func(ctx context.Context, args map[string]any) (string, error) {
+		panic("boom")
+	}}
+	if _, err := panicky.Execute(context.Background(), nil); err == nil || !strings.Contains(err.Error(), "panicked: boom") {
+		t.Fatalf("expected the panic as an error, got %v", err)
+	}
+}
+
 func TestRegistry(t *testing.T) {
 	registry := NewRegistry()
 
@@ -91,4 +237,182 @@ func TestRegistry(t *testing.T) {
 	if len(tools) != 2 {
 		t.Errorf("Expected 2 tools, got %d", len(tools))
 	}
+
+	// Test ToJSONSchemasFor keeps the requested order and skips unknown names
+	schemas := registry.ToJSONSchemasFor("tool2", "missing", "tool1", "tool2")
+	name := func(schema map[string]any) any { return schema["function"].(map[string]any)["name"] }
+	if len(schemas) != 2 || name(schemas[0]) != "tool2" || name(schemas[1]) != "tool1" {
+		t.Errorf("Expected schemas for tool2 and tool1, got %v", schemas)
+	}
+}
+
+type echoAgent struct {
+	inputs []string
+}
+
+func (e *echoAgent) Run(ctx context.Context, input string) (*message.Message, error) {
+	e.inputs = append(e.inputs, input)
+	return message.NewMessage(message.RoleAssistant, "sub-agent: "+input), nil
+}
+
+func TestFromAgent(t *testing.T) {
+	sub := &echoAgent{}
+	agentTool := FromAgent("researcher", "Delegates research questions", sub)
+
+	registry := NewRegistry()
+	if err := registry.Register(agentTool); err != nil {
+		t.Fatalf("register agent tool: %v", err)
+	}
+
+	result, err := registry.Execute(context.Background(), "researcher", map[string]any{"input": "find sources"})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if result != "sub-agent: find sources" {
+		t.Errorf("unexpected tool result %q", result)
+	}
+	if len(sub.inputs) != 1 || sub.inputs[0] != "find sources" {
+		t.Errorf("expected sub-agent to receive input, got %v", sub.inputs)
+	}
+
+	if _, err := agentTool.Execute(context.Background(), map[string]any{}); err == nil {
+		t.Errorf("expected missing input to fail validation")
+	}
+}
+
+type Paging struct {
+	Limit int `json:"limit,omitempty" description:"Maximum number of results"`
+}
+
+type searchArgs struct {
+	Query   string            `json:"query" description:"Search terms" required:"true"`
+	Sort    string            `json:"sort" enum:"relevance,date"`
+	Tags    []string          `json:"tags"`
+	Filter  *searchFilter     `json:"filter"`
+	Labels  map[string]string `json:"labels"`
+	Skipped string            `json:"-"`
+	Paging
+	internal string
+}
+
+type searchFilter struct {
+	Since time.Time `json:"since" required:"true"`
+	Score float64   `json:"score"`
+}
+
+func TestFromFunc(t *testing.T) {
+	var got searchArgs
+	searchTool, err := FromFunc("search", "Search the index", func(ctx context.Context, args searchArgs) (string, error) {
+		got = args
+		return fmt.Sprintf("%s:%d", args.Query, args.Limit), nil
+	})
+	if err != nil {
+		t.Fatalf("FromFunc failed: %v", err)
+	}
+
+	params := make(map[string]Parameter)
+	for _, p := range searchTool.Parameters {
+		params[p.Name] = p
+	}
+	if len(params) != 6 || params["Skipped"].Name != "" || params["internal"].Name != "" {
+		t.Fatalf("unexpected parameters %+v", searchTool.Parameters)
+	}
+	if q := params["query"]; q.Type != "string" || !q.Required || q.Description != "Search terms" || q.Schema != nil {
+		t.Errorf("unexpected query parameter %+v", q)
+	}
+	if s := params["sort"]; s.Required || len(s.Enum) != 2 || s.Enum[1] != "date" {
+		t.Errorf("unexpected sort parameter %+v", s)
+	}
+	if l := params["limit"]; l.Type != "integer" || l.Description != "Maximum number of results" {
+		t.Errorf("expected embedded limit parameter, got %+v", l)
+	}
+
+	schema := searchTool.ToJSONSchema()["function"].(map[string]any)["parameters"].(map[string]any)
+	props := schema["properties"].(map[string]any)
+	if tags := props["tags"].(map[string]any); tags["type"] != "array" || tags["items"].(map[string]any)["type"] != "string" {
+		t.Errorf("unexpected tags schema %v", tags)
+	}
+	filter := props["filter"].(map[string]any)
+	since := filter["properties"].(map[string]any)["since"].(map[string]any)
+	if filter["type"] != "object" || since["format"] != "date-time" || filter["required"].([]string)[0] != "since" {
+		t.Errorf("unexpected filter schema %v", filter)
+	}
+	if labels := props["labels"].(map[string]any); labels["additionalProperties"].(map[string]any)["type"] != "string" {
+		t.Errorf("unexpected labels schema %v", labels)
+	}
+
+	result, err := searchTool.Execute(context.Background(), map[string]any{
+		"query":  "go",
+		"limit":  float64(3),
+		"tags":   []any{"lang"},
+		"filter": map[string]any{"since": "2024-01-02T00:00:00Z", "score": 0.5},
+	})
+	if err != nil {
+		t.Fatalf("Execute failed: %v", err)
+	}
+	if result != "go:3" || got.Tags[0] != "lang" || got.Filter.Score != 0.5 || got.Filter.Since.Year() != 2024 {
+		t.Errorf("unexpected result %q with args %+v", result, got)
+	}
+
+	if _, err := searchTool.Execute(context.Background(), map[string]any{"query": "go", "limit": "three"}); err == nil {
+		t.Error("expected mistyped argument to fail decoding")
+	}
+	if _, err := searchTool.Execute(context.Background(), map[string]any{}); err == nil {
+		t.Error("expected missing required argument to fail validation")
+	}
+}
+
+func TestFromFuncPointerArgsAndErrors(t *testing.T) {
+	failure := errors.New("boom")
+	ptrTool, err := FromFunc("ptr", "", func(ctx context.Context, args *Paging) (string, error) {
+		if args.Limit < 0 {
+			return "", failure
+		}
+		return fmt.Sprint(args.Limit), nil
+	})
+	if err != nil {
+		t.Fatalf("FromFunc failed: %v", err)
+	}
+	if result, err := ptrTool.Execute(context.Background(), map[string]any{"limit": 2}); err != nil || result != "2" {
+		t.Errorf("unexpected result %q (%v)", result, err)
+	}
+	if _, err := ptrTool.Execute(context.Background(), map[string]any{"limit": -1}); !errors.Is(err, failure) {
+		t.Errorf("expected handler error, got %v", err)
+	}
+
+	type node struct {
+		Children []node `json:"children"`
+	}
+	for _, handler := range []any{
+		nil,
+		func(args Paging) (string, error) { return "", nil },
+		func(ctx context.Context, query string) (string, error) { return "", nil },
+		func(ctx context.Context, args Paging) string { return "" },
+		func(ctx context.Context, args struct{ C chan int }) (string, error) { return "", nil },
+		func(ctx context.Context, args node) (string, error) { return "", nil },
+	} {
+		if _, err := FromFunc("bad", "", handler); err == nil {
+			t.Errorf("expected %T to be rejected", handler)
+		}
+	}
+}
+
+func TestFromAgentSerializesCalls(t *testing.T) {
+	sub := &echoAgent{}
+	agentTool := FromAgent("researcher", "Delegates research questions", sub)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := agentTool.Execute(context.Background(), map[string]any{"input": "task"}); err != nil {
+				t.Errorf("Execute: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+	if len(sub.inputs) != 8 {
+		t.Fatalf("expected 8 serialized runs, got %d", len(sub.inputs))
+	}
 }
@@ -0,0 +1,121 @@
+package websearch
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+const defaultTavilyEndpoint = "https://api.tavily.com/search"
+
+var _ Backend = (*Tavily)(nil)
+
+// Tavily implements Backend using the Tavily search API.
+type Tavily struct {
+	apiKey      string
+	endpoint    string
+	searchDepth string
+	httpClient  *http.Client
+}
+
+// TavilyOption customises the Tavily backend.
+type TavilyOption func(*Tavily)
+
+// WithTavilyEndpoint overrides the Tavily API endpoint.
+func WithTavilyEndpoint(endpoint string) TavilyOption {
+	return func(t *Tavily) {
+		if endpoint != "" {
+			t.endpoint = endpoint
+		}
+	}
+}
+
+// WithSearchDepth sets the Tavily search depth ("basic" or "advanced").
+func WithSearchDepth(depth string) TavilyOption {
+	return func(t *Tavily) {
+		if depth != "" {
+			t.searchDepth = depth
+		}
+	}
+}
+
+// WithHTTPClient swaps the HTTP client (useful for timeouts or proxies).
+func WithHTTPClient(client *http.Client) TavilyOption {
+	return func(t *Tavily) {
+		if client != nil {
+			t.httpClient = client
+		}
+	}
+}
+
+// NewTavily creates a Tavily backend authenticated with apiKey.
+func NewTavily(apiKey string, opts ...TavilyOption) *Tavily {
+	t := &Tavily{
+		apiKey:      apiKey,
+		endpoint:    defaultTavilyEndpoint,
+		searchDepth: "basic",
+		httpClient:  &http.Client{Timeout: 15 * time.Second},
+	}
+	for _, opt := range opts {
+		opt(t)
+	}
+	return t
+}
+
+type tavilyRequest struct {
+	Query       string `json:"query"`
+	MaxResults  int    `json:"max_results,omitempty"`
+	SearchDepth string `json:"search_depth,omitempty"`
+}
+
+type tavilyResponse struct {
+	Results []struct {
+		Title   string `json:"title"`
+		URL     string `json:"url"`
+		Content string `json:"content"`
+	} `json:"results"`
+}
+
+// Search implements Backend.
+func (t *Tavily) Search(ctx context.Context, query string, maxResults int) ([]Result, error) {
+	if t.apiKey == "" {
+		return nil, fmt.Errorf("tavily API key not configured")
+	}
+	body, err := json.Marshal(tavilyRequest{
+		Query:       query,
+		MaxResults:  maxResults,
+		SearchDepth: t.searchDepth,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, t.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", t.apiKey))
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := t.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("tavily request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return nil, fmt.Errorf("tavily search failed: status %d", resp.StatusCode)
+	}
+
+	var tr tavilyResponse
+	if err := json.NewDecoder(resp.Body).Decode(&tr); err != nil {
+		return nil, fmt.Errorf("failed to decode tavily response: %w", err)
+	}
+	results := make([]Result, 0, len(tr.Results))
+	for _, r := range tr.Results {
+		results = append(results, Result{Title: r.Title, URL: r.URL, Snippet: r.Content})
+	}
+	return results, nil
+}
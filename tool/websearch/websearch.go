@@ -0,0 +1,123 @@
+// Package websearch provides a ready-made web search tool backed by a pluggable search API.
+package websearch
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/sweetpotato0/ai-allin/tool"
+)
+
+// QueryParam is the argument name carrying the search query.
+const QueryParam = "query"
+
+// Result is a single search hit.
+type Result struct {
+	Title   string `json:"title"`
+	URL     string `json:"url"`
+	Snippet string `json:"snippet"`
+}
+
+// Backend performs web searches.
+type Backend interface {
+	Search(ctx context.Context, query string, maxResults int) ([]Result, error)
+}
+
+type config struct {
+	name        string
+	description string
+	maxResults  int
+}
+
+// Option customises the search tool.
+type Option func(*config)
+
+// WithName overrides the tool name (default "web_search").
+func WithName(name string) Option {
+	return func(c *config) {
+		if name != "" {
+			c.name = name
+		}
+	}
+}
+
+// WithDescription overrides the tool description shown to the model.
+func WithDescription(description string) Option {
+	return func(c *config) {
+		if description != "" {
+			c.description = description
+		}
+	}
+}
+
+// WithMaxResults limits how many results are returned per search (default 5).
+func WithMaxResults(n int) Option {
+	return func(c *config) {
+		if n > 0 {
+			c.maxResults = n
+		}
+	}
+}
+
+// New creates a web search tool that queries backend and returns formatted snippets.
+//
+// Example:
+//
+//	ag := agent.New(agent.WithProvider(llm))
+//	_ = ag.RegisterTool(websearch.New(websearch.NewTavily(os.Getenv("TAVILY_API_KEY"))))
+func New(backend Backend, opts ...Option) *tool.Tool {
+	cfg := &config{
+		name:        "web_search",
+		description: "Search the web for up-to-date information. Returns titles, URLs and snippets.",
+		maxResults:  5,
+	}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+
+	return &tool.Tool{
+		Name:        cfg.name,
+		Description: cfg.description,
+		Parameters: []tool.Parameter{
+			{
+				Name:        QueryParam,
+				Type:        "string",
+				Description: "Search query",
+				Required:    true,
+			},
+		},
+		Handler: func(ctx context.Context, args map[string]any) (string, error) {
+			if backend == nil {
+				return "", fmt.Errorf("web search tool %s has no backend", cfg.name)
+			}
+			query, ok := args[QueryParam].(string)
+			if !ok || strings.TrimSpace(query) == "" {
+				return "", fmt.Errorf("web search requires a non-empty %q argument", QueryParam)
+			}
+			results, err := backend.Search(ctx, query, cfg.maxResults)
+			if err != nil {
+				return "", fmt.Errorf("web search failed: %w", err)
+			}
+			return FormatResults(results), nil
+		},
+	}
+}
+
+// FormatResults renders results as a numbered list suitable for an LLM.
+func FormatResults(results []Result) string {
+	if len(results) == 0 {
+		return "No results found."
+	}
+	var sb strings.Builder
+	for i, res := range results {
+		if i > 0 {
+			sb.WriteString("\n")
+		}
+		fmt.Fprintf(&sb, "%d. %s\n   %s\n", i+1, res.Title, res.URL)
+		if snippet := strings.TrimSpace(res.Snippet); snippet != "" {
+			fmt.Fprintf(&sb, "   %s\n", snippet)
+		}
+	}
+	return sb.String()
+}
@@ -0,0 +1,43 @@
+package websearch
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestToolWithTavilyBackend(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if got := r.Header.Get("Authorization"); got != "Bearer test-key" {
+			t.Errorf("unexpected authorization header %q", got)
+		}
+		var req tavilyRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Fatalf("failed to decode request: %v", err)
+		}
+		if req.Query != "golang iterators" || req.MaxResults != 2 {
+			t.Errorf("unexpected request %+v", req)
+		}
+		_, _ = w.Write([]byte(`{"results":[{"title":"Range over func","url":"https://go.dev/blog/range-functions","content":"Go 1.23 adds iterators."}]}`))
+	}))
+	defer srv.Close()
+
+	searchTool := New(NewTavily("test-key", WithTavilyEndpoint(srv.URL)), WithMaxResults(2))
+	out, err := searchTool.Execute(context.Background(), map[string]any{QueryParam: "golang iterators"})
+	if err != nil {
+		t.Fatalf("Execute failed: %v", err)
+	}
+	if !strings.Contains(out, "1. Range over func") || !strings.Contains(out, "https://go.dev/blog/range-functions") {
+		t.Errorf("unexpected output %q", out)
+	}
+}
+
+func TestToolRejectsEmptyQuery(t *testing.T) {
+	searchTool := New(NewTavily("test-key"))
+	if _, err := searchTool.Execute(context.Background(), map[string]any{QueryParam: "  "}); err == nil {
+		t.Error("expected error for empty query")
+	}
+}
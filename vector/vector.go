@@ -3,6 +3,8 @@ package vector
 import (
 	"context"
 	"math"
+	"reflect"
+	"strings"
 )
 
 // Embedding represents a vector embedding
@@ -10,6 +12,9 @@ type Embedding struct {
 	ID     string
 	Vector []float32
 	Text   string
+
+	// Metadata holds the attributes that SearchWithFilter matches against.
+	Metadata map[string]any
 }
 
 // VectorStore defines the interface for vector storage and similarity search
@@ -31,6 +36,176 @@ type VectorStore interface {
 
 	// Count returns the number of embeddings
 	Count(ctx context.Context) (int, error)
+
+	// Stats reports index size and health information
+	Stats(ctx context.Context) (VectorStoreStats, error)
+
+	// WithCollection returns a view of the same backend scoped to the named
+	// collection. Every operation on the view, including Clear and Count, only
+	// affects that collection. An empty name selects DefaultCollection.
+	WithCollection(name string) VectorStore
+}
+
+// BulkDeleter is implemented by stores that can remove many embeddings in one
+// call. Callers fall back to DeleteEmbedding for stores without it.
+type BulkDeleter interface {
+	// DeleteEmbeddings removes the embeddings with the given IDs and returns how
+	// many existed. Unknown IDs are ignored.
+	DeleteEmbeddings(ctx context.Context, ids ...string) (int, error)
+}
+
+// FilteredSearcher is implemented by stores that can restrict similarity
+// search to embeddings whose metadata matches a filter. Every store follows the
+// rule implemented by MatchesFilter: a scalar value matches a stored value equal
+// to it or a stored list containing it, lists and objects must be equal, and
+// AnyOf matches any of its items the way a scalar does. Stores may reject filter
+// values their backend cannot express.
+type FilteredSearcher interface {
+	// SearchWithFilter works like Search but only considers embeddings whose
+	// metadata matches filter. An empty filter matches every embedding.
+	SearchWithFilter(ctx context.Context, queryVector []float32, topK int, filter map[string]any) ([]*Embedding, error)
+}
+
+// DefaultCollection is the collection used by stores that were not scoped with WithCollection.
+const DefaultCollection = "default"
+
+// CollectionName returns name, or DefaultCollection when name is empty.
+func CollectionName(name string) string {
+	if name == "" {
+		return DefaultCollection
+	}
+	return name
+}
+
+// ChunkIDSeparator separates the parent document ID from the chunk suffix in
+// embedding IDs produced by the RAG ingestion pipeline (e.g. "doc_1_chunk_42").
+const ChunkIDSeparator = "_chunk_"
+
+// VectorStoreStats summarizes the contents and footprint of a vector store.
+type VectorStoreStats struct {
+	Documents    int    `json:"documents"`     // Distinct parent documents, derived from embedding IDs
+	Chunks       int    `json:"chunks"`        // Stored embeddings
+	Dimension    int    `json:"dimension"`     // Embedding dimension (0 when unknown or empty)
+	IndexType    string `json:"index_type"`    // Similarity index, e.g. "flat", "HNSW", "IVFFLAT"
+	StorageBytes int64  `json:"storage_bytes"` // Approximate memory or on-disk size
+}
+
+// DocumentIDFromEmbeddingID returns the parent document ID encoded in a chunk embedding ID.
+// IDs without ChunkIDSeparator are treated as their own document.
+func DocumentIDFromEmbeddingID(id string) string {
+	if idx := strings.LastIndex(id, ChunkIDSeparator); idx > 0 {
+		return id[:idx]
+	}
+	return id
+}
+
+// AnyOf is a filter value matching metadata that holds one of the listed
+// values, or a list containing one of them. An empty AnyOf matches nothing.
+type AnyOf []any
+
+// MatchesFilter reports whether metadata matches every key of filter. A scalar
+// filter value matches a stored value equal to it or a stored list containing
+// it, so {"tag": "go"} matches both "go" and ["go", "rust"]. Lists and objects in
+// the filter must equal the stored value. Numbers compare by value regardless of
+// their Go type, so a filter of 2024 matches a stored float64(2024). AnyOf values
+// match any of their items.
+func MatchesFilter(metadata, filter map[string]any) bool {
+	for key, want := range filter {
+		got, ok := metadata[key]
+		if !ok {
+			return false
+		}
+		switch want := want.(type) {
+		case AnyOf:
+			if !matchesAnyOf(got, want) {
+				return false
+			}
+		default:
+			if IsScalarFilterValue(want) {
+				if !matchesAnyOf(got, AnyOf{want}) {
+					return false
+				}
+			} else if !filterValueEqual(got, want) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// IsScalarFilterValue reports whether a filter value is a scalar, i.e. not a
+// list, array or map, and so also matches stored lists containing it.
+func IsScalarFilterValue(v any) bool {
+	switch reflect.ValueOf(v).Kind() {
+	case reflect.Slice, reflect.Array, reflect.Map:
+		return false
+	}
+	return true
+}
+
+func matchesAnyOf(got any, allowed AnyOf) bool {
+	items := []any{got}
+	if rv := reflect.ValueOf(got); rv.Kind() == reflect.Slice || rv.Kind() == reflect.Array {
+		items = make([]any, rv.Len())
+		for i := range items {
+			items[i] = rv.Index(i).Interface()
+		}
+	}
+	for _, item := range items {
+		for _, want := range allowed {
+			if filterValueEqual(item, want) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// filterValueEqual compares values as their JSON encodings would, so []string
+// and []any lists, or maps of different value types, with equal items are equal.
+func filterValueEqual(a, b any) bool {
+	if x, ok := toFloat(a); ok {
+		y, ok := toFloat(b)
+		return ok && x == y
+	}
+	ra, rb := reflect.ValueOf(a), reflect.ValueOf(b)
+	switch {
+	case !IsScalarFilterValue(a) && ra.Kind() != reflect.Map && !IsScalarFilterValue(b) && rb.Kind() != reflect.Map:
+		if ra.Len() != rb.Len() {
+			return false
+		}
+		for i := 0; i < ra.Len(); i++ {
+			if !filterValueEqual(ra.Index(i).Interface(), rb.Index(i).Interface()) {
+				return false
+			}
+		}
+		return true
+	case ra.Kind() == reflect.Map && rb.Kind() == reflect.Map && ra.Type().Key() == rb.Type().Key():
+		if ra.Len() != rb.Len() {
+			return false
+		}
+		for iter := ra.MapRange(); iter.Next(); {
+			other := rb.MapIndex(iter.Key())
+			if !other.IsValid() || !filterValueEqual(iter.Value().Interface(), other.Interface()) {
+				return false
+			}
+		}
+		return true
+	}
+	return reflect.DeepEqual(a, b)
+}
+
+func toFloat(v any) (float64, bool) {
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(rv.Int()), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(rv.Uint()), true
+	case reflect.Float32, reflect.Float64:
+		return rv.Float(), true
+	}
+	return 0, false
 }
 
 // Embedder defines the interface for creating embeddings from text
@@ -0,0 +1,46 @@
+package vector
+
+import "testing"
+
+func TestMatchesFilter(t *testing.T) {
+	metadata := map[string]any{
+		"source": "wiki",
+		"year":   float64(2024),
+		"tags":   []any{"go", "rag"},
+		"langs":  []string{"en", "zh"},
+		"owner":  map[string]any{"team": "search", "size": float64(3)},
+		"draft":  false,
+	}
+	cases := []struct {
+		name   string
+		filter map[string]any
+		want   bool
+	}{
+		{"empty filter", nil, true},
+		{"equal scalar", map[string]any{"source": "wiki"}, true},
+		{"different scalar", map[string]any{"source": "web"}, false},
+		{"missing key", map[string]any{"lang": "en"}, false},
+		{"number of another type", map[string]any{"year": 2024}, true},
+		{"boolean", map[string]any{"draft": false}, true},
+		{"scalar in stored list", map[string]any{"tags": "rag"}, true},
+		{"scalar in typed stored list", map[string]any{"langs": "zh"}, true},
+		{"scalar not in stored list", map[string]any{"tags": "java"}, false},
+		{"equal list", map[string]any{"tags": []string{"go", "rag"}}, true},
+		{"sub-list is not equal", map[string]any{"tags": []any{"go"}}, false},
+		{"list in another order", map[string]any{"tags": []any{"rag", "go"}}, false},
+		{"equal object", map[string]any{"owner": map[string]any{"team": "search", "size": 3}}, true},
+		{"partial object", map[string]any{"owner": map[string]any{"team": "search"}}, false},
+		{"any of", map[string]any{"source": AnyOf{"web", "wiki"}}, true},
+		{"any of in stored list", map[string]any{"tags": AnyOf{"java", "go"}}, true},
+		{"any of without match", map[string]any{"tags": AnyOf{"java"}}, false},
+		{"empty any of", map[string]any{"source": AnyOf{}}, false},
+		{"all keys must match", map[string]any{"source": "wiki", "tags": "java"}, false},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := MatchesFilter(metadata, tc.filter); got != tc.want {
+				t.Fatalf("MatchesFilter(%v) = %v, want %v", tc.filter, got, tc.want)
+			}
+		})
+	}
+}